@@ -2,61 +2,445 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
+	"maps"
 	"os"
-	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"ryn-cx/i-saw-that/pkg/watcher"
 )
 
 type App struct {
 	ctx context.Context
+	// Guards the config and the watchers, only held while they are read or written
+	// since the methods are called concurrently by the GUI and clients of the daemon.
+	mu sync.Mutex
+	// Held by the methods that change folder pairs for as long as they run, so their
+	// changes are made one at a time without holding mu while watchers start and stop.
+	pairsMu sync.Mutex
 	// List of folder pairs from the config file.
-	config []*WatcherConfig
+	config []*watcher.WatcherConfig
+	// Values inherited by folder pairs that do not override them.
+	defaults Defaults
+	// max_concurrent_backups from the config file, kept so saving the config keeps it.
+	maxConcurrentBackups int
+	// Shared by every watcher so only a few of them copy at the same time.
+	scheduler *backupScheduler
 	// Map of active watchers by their ID.
-	watchers map[string]*Watcher
+	watchers map[string]*watcher.Watcher
 	// Path to the config file that saves the folders being watched.
 	configPath string
+	// Format of the config file.
+	configFormat ConfigFormat
+	// Options the app was started with.
+	options *Options
+	// Current run of the app, recorded in the data directory.
+	session Session
+	// Set when a daemon runs the watchers, the methods of the app are called on the
+	// daemon instead.
+	remote *daemonClient
+	// Clients of the daemon that receive its events, nil unless the app is the daemon.
+	events *eventHub
+	// Keys backups are signed and checked with, nil without a data directory.
+	keyring *watcher.Keyring
 }
 
-type WatcherConfig struct {
-	ID           string  `json:"id"`
-	Source       string  `json:"source"`
-	Destination  string  `json:"destination"`
-	Enabled      bool    `json:"enabled"`
-	WaitTime     float64 `json:"wait_time"`
-	FolderFormat string  `json:"folder_format"`
+func NewApp(options *Options) *App {
+	return &App{
+		watchers:     make(map[string]*watcher.Watcher),
+		defaults:     builtinDefaults(),
+		scheduler:    newBackupScheduler(0),
+		configPath:   options.ConfigPath,
+		configFormat: options.configFormat(),
+		options:      options,
+	}
 }
 
-func NewApp() *App {
-	configDir, err := os.UserConfigDir()
+// startup is called when the app starts
+func (a *App) startup(ctx context.Context) {
+	a.ctx = ctx
+	if a.remote != nil {
+		go a.remote.forwardEvents(ctx, a.emit)
+	} else {
+		a.startEngine()
+	}
+	if !a.options.NoUpdateCheck {
+		go a.notifyUpdate()
+	}
+}
+
+// startEngine loads the config and starts the watchers, in the GUI or in the daemon.
+func (a *App) startEngine() {
+	unclean := a.startSession()
+	keyring, err := loadKeyring(a.options)
 	if err != nil {
-		log.Printf("Error getting config dir: %v", err)
-		configDir = "."
+		watcher.Logf("", watcher.LogLevelError, "Error loading signing keys: %v", err)
+	}
+	a.keyring = keyring
+	if err := a.loadConfig(); err != nil {
+		watcher.Logf("", watcher.LogLevelError, "Error loading config: %v", err)
+	}
+	if unclean {
+		a.mu.Lock()
+		watchers := slices.Collect(maps.Values(a.watchers))
+		a.mu.Unlock()
+		go a.checkLatestBackups(watchers)
 	}
+}
 
-	appConfigDir := filepath.Join(configDir, "i-saw-that")
-	os.MkdirAll(appConfigDir, 0755)
+// showWindow brings the window to the front when another instance hands off to this
+// one.
+func (a *App) showWindow() {
+	if a.ctx == nil {
+		return
+	}
+	runtime.WindowUnminimise(a.ctx)
+	runtime.WindowShow(a.ctx)
+}
 
-	return &App{
-		watchers:   make(map[string]*Watcher),
-		configPath: filepath.Join(appConfigDir, "config.json"),
+// CheckForUpdates compares the running version with the latest release
+func (a *App) CheckForUpdates() (UpdateInfo, error) {
+	url, _, err := a.options.updateSource()
+	if err != nil {
+		return UpdateInfo{}, err
 	}
+	return checkForUpdates(updateClient, url, version)
 }
 
-// startup is called when the app starts
-func (a *App) startup(ctx context.Context) {
-	a.ctx = ctx
-	if err := a.loadConfig(); err != nil {
-		log.Printf("Error loading config: %v", err)
+// SetStartAtLogin adds or removes the login item that starts the app with the current
+// profile when the user logs in
+func (a *App) SetStartAtLogin(enabled bool) error {
+	item, err := a.options.loginItem()
+	if err != nil {
+		return err
+	}
+	if err := item.set(enabled); err != nil {
+		return err
 	}
+	watcher.Logf("", watcher.LogLevelInfo, "Start at login set to %t", enabled)
+	return nil
 }
 
-// GetFolderPairs returns all folder pairs
-func (a *App) GetFolderPairs() []*WatcherConfig {
-	return a.config
+// GetStartAtLogin returns true if the app starts with the current profile when the user
+// logs in
+func (a *App) GetStartAtLogin() (bool, error) {
+	item, err := a.options.loginItem()
+	if err != nil {
+		return false, err
+	}
+	return item.installed()
+}
+
+// notifyUpdate tells the frontend when a newer release is available. The check is
+// only logged at the debug level when it fails since the computer may be offline.
+func (a *App) notifyUpdate() {
+	info, err := a.CheckForUpdates()
+	if err != nil {
+		watcher.Logf("", watcher.LogLevelDebug, "%v", err)
+		return
+	}
+	if info.Available {
+		watcher.Logf("", watcher.LogLevelInfo, "Version %s is available, run self-update to install it", info.Latest)
+		a.emit("update-available", info)
+	}
+}
+
+// GetFolderPairs returns all folder pairs with defaults applied
+func (a *App) GetFolderPairs() []*watcher.WatcherConfig {
+	if a.remote != nil {
+		result, err := callDaemon[[]*watcher.WatcherConfig](a.remote, "GetFolderPairs")
+		if err != nil {
+			watcher.Logf("", watcher.LogLevelError, "%v", err)
+		}
+		return result
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pairs := make([]*watcher.WatcherConfig, len(a.config))
+	for i, pair := range a.config {
+		pairs[i] = a.defaults.resolve(pair)
+	}
+	return pairs
+}
+
+// runningWatcher returns the watcher of a folder pair if it is running.
+func (a *App) runningWatcher(id string) (*watcher.Watcher, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	w, exists := a.watchers[id]
+	return w, exists
+}
+
+// folderPair returns the index of a folder pair in the config and the pair, nil if
+// there is no pair with the ID.
+func (a *App) folderPair(id string) (int, *watcher.WatcherConfig) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, pair := range a.config {
+		if pair.ID == id {
+			return i, pair
+		}
+	}
+	return -1, nil
+}
+
+// resolve applies the defaults to a folder pair.
+func (a *App) resolve(pair *watcher.WatcherConfig) *watcher.WatcherConfig {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.defaults.resolve(pair)
+}
+
+// GetRecentLogs returns the most recent log entries for the log panel. An empty
+// watcherID returns entries for every watcher and level is the minimum level shown.
+func (a *App) GetRecentLogs(watcherID string, level string, limit int) ([]watcher.LogEntry, error) {
+	if a.remote != nil {
+		return callDaemon[[]watcher.LogEntry](a.remote, "GetRecentLogs", watcherID, level, limit)
+	}
+	minLevel, err := watcher.ParseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return watcher.Logs.Recent(watcherID, minLevel, limit), nil
+}
+
+// GetProfile returns the name of the active profile, an empty string is the default
+// profile.
+func (a *App) GetProfile() string {
+	return a.options.Profile
+}
+
+// GetProfiles returns the names of every named profile.
+func (a *App) GetProfiles() ([]string, error) {
+	return a.options.listProfiles()
+}
+
+// GetWatcherStatus returns the status of a folder pair's watcher
+func (a *App) GetWatcherStatus(id string) watcher.WatcherStatus {
+	if a.remote != nil {
+		result, err := callDaemon[watcher.WatcherStatus](a.remote, "GetWatcherStatus", id)
+		if err != nil {
+			watcher.Logf("", watcher.LogLevelError, "%v", err)
+		}
+		return result
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return watcher.WatcherStatus{}
+	}
+	return w.Status()
+}
+
+// SelfTest checks that file events and writing to the destination work for a folder
+// pair
+func (a *App) SelfTest(id string) (watcher.SelfTestResult, error) {
+	if a.remote != nil {
+		return callDaemon[watcher.SelfTestResult](a.remote, "SelfTest", id)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return watcher.SelfTestResult{}, fmt.Errorf("watcher not running")
+	}
+	return w.SelfTest(), nil
+}
+
+// GetBackups returns the backups of an active watcher from all of its destinations
+func (a *App) GetBackups(id string) ([]watcher.Backup, error) {
+	if a.remote != nil {
+		return callDaemon[[]watcher.Backup](a.remote, "GetBackups", id)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return nil, fmt.Errorf("watcher not running")
+	}
+	return w.ListBackups(), nil
+}
+
+// AnnotateBackup stores a note with a backup of an active watcher, an empty note
+// removes it
+func (a *App) AnnotateBackup(id, backupID, note string) error {
+	if a.remote != nil {
+		return a.remote.call("AnnotateBackup", nil, id, backupID, note)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return fmt.Errorf("watcher not running")
+	}
+	return w.AnnotateBackup(backupID, note)
+}
+
+// PinBackup pins or unpins a backup of an active watcher, pinned backups are never
+// pruned
+func (a *App) PinBackup(id, backupID string, pinned bool) error {
+	if a.remote != nil {
+		return a.remote.call("PinBackup", nil, id, backupID, pinned)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return fmt.Errorf("watcher not running")
+	}
+	return w.PinBackup(backupID, pinned)
+}
+
+// ResumeRetention lets pruning delete backups of an active watcher again after a mass
+// change
+func (a *App) ResumeRetention(id string) error {
+	if a.remote != nil {
+		return a.remote.call("ResumeRetention", nil, id)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return fmt.Errorf("watcher not running")
+	}
+	w.ResumeRetention()
+	return nil
+}
+
+// GetHistory returns the backup events of an active watcher ordered from oldest to
+// newest
+func (a *App) GetHistory(id string) ([]watcher.HistoryEvent, error) {
+	if a.remote != nil {
+		return callDaemon[[]watcher.HistoryEvent](a.remote, "GetHistory", id)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return nil, fmt.Errorf("watcher not running")
+	}
+	return w.History(), nil
+}
+
+// GetStats returns the counters of an active watcher since they were last reset
+func (a *App) GetStats(id string) (watcher.WatcherStats, error) {
+	if a.remote != nil {
+		return callDaemon[watcher.WatcherStats](a.remote, "GetStats", id)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return watcher.WatcherStats{}, fmt.Errorf("watcher not running")
+	}
+	return w.Stats(), nil
+}
+
+// ResetStats sets the counters of an active watcher back to zero
+func (a *App) ResetStats(id string) error {
+	if a.remote != nil {
+		return a.remote.call("ResetStats", nil, id)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return fmt.Errorf("watcher not running")
+	}
+	return w.ResetStats()
+}
+
+// ExportReport writes the statistics, backups and history of an active watcher to a
+// JSON or CSV file, an empty format is detected from the extension of targetPath
+func (a *App) ExportReport(id, targetPath, format string) error {
+	if a.remote != nil {
+		return a.remote.call("ExportReport", nil, id, targetPath, format)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return fmt.Errorf("watcher not running")
+	}
+	return w.ExportReport(targetPath, watcher.ReportFormat(format))
+}
+
+// GetBackupTimeline returns the number and size of the backups made during each hour
+// or day between from and to, granularity is "hour" or "day"
+func (a *App) GetBackupTimeline(id string, from, to time.Time, granularity string) ([]watcher.TimelineBucket, error) {
+	if a.remote != nil {
+		return callDaemon[[]watcher.TimelineBucket](a.remote, "GetBackupTimeline", id, from, to, granularity)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return nil, fmt.Errorf("watcher not running")
+	}
+	return w.Timeline(from, to, watcher.TimelineGranularity(granularity))
+}
+
+// ExportBackup writes a backup to a zip or tar archive, an empty format is detected from
+// the extension of targetPath
+func (a *App) ExportBackup(id, backupID, targetPath, format string) error {
+	if a.remote != nil {
+		return a.remote.call("ExportBackup", nil, id, backupID, targetPath, format)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return fmt.Errorf("watcher not running")
+	}
+	return w.ExportBackup(backupID, targetPath, watcher.ArchiveFormat(format))
+}
+
+// CompareBackup lists how a folder differs from a backup of an active watcher
+func (a *App) CompareBackup(id, backupID, dir string) (watcher.BackupComparison, error) {
+	if a.remote != nil {
+		return callDaemon[watcher.BackupComparison](a.remote, "CompareBackup", id, backupID, dir)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return watcher.BackupComparison{}, fmt.Errorf("watcher not running")
+	}
+	return w.CompareBackup(backupID, dir)
+}
+
+// ImportBackup adds an archive created by ExportBackup to a folder pair's backups
+func (a *App) ImportBackup(id, archivePath string) (watcher.Backup, error) {
+	if a.remote != nil {
+		return callDaemon[watcher.Backup](a.remote, "ImportBackup", id, archivePath)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return watcher.Backup{}, fmt.Errorf("watcher not running")
+	}
+	return w.ImportBackup(archivePath)
+}
+
+// RestoreFile restores a single file from a backup of an active watcher, without
+// overwrite an existing file is kept and the backup is restored next to it with
+// .restored added to its name
+func (a *App) RestoreFile(id, backupID, relPath string, overwrite bool) (string, error) {
+	if a.remote != nil {
+		return callDaemon[string](a.remote, "RestoreFile", id, backupID, relPath, overwrite)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return "", fmt.Errorf("watcher not running")
+	}
+	return w.RestoreFile(backupID, relPath, overwrite)
+}
+
+// OpenBackupInExplorer opens the folder of a backup of an active watcher in the file
+// manager
+func (a *App) OpenBackupInExplorer(id, backupID string) error {
+	if a.remote != nil {
+		return a.remote.call("OpenBackupInExplorer", nil, id, backupID)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return fmt.Errorf("watcher not running")
+	}
+	path, err := w.BackupFolder(backupID)
+	if err != nil {
+		return err
+	}
+	return openInFileManager(path)
+}
+
+// OpenDestination opens the primary destination of an active watcher in the file
+// manager
+func (a *App) OpenDestination(id string) error {
+	if a.remote != nil {
+		return a.remote.call("OpenDestination", nil, id)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return fmt.Errorf("watcher not running")
+	}
+	return openInFileManager(w.Destination)
 }
 
 func (a *App) SelectFolder() (string, error) {
@@ -71,224 +455,389 @@ func (a *App) SelectFolder() (string, error) {
 
 // ToggleFolderPair enables or disables a folder pair
 func (a *App) ToggleFolderPair(id string, enabled bool) error {
-	for i, pair := range a.config {
-		if pair.ID == id {
-			if enabled {
-				// Start watcher
-				watcher, err := NewWatcher(
-					pair.ID,
-					pair.Source,
-					pair.Destination,
-					pair.WaitTime,
-					pair.FolderFormat,
-				)
-				if err != nil {
-					return fmt.Errorf("error creating watcher: %w", err)
-				}
-
-				if err := watcher.StartWatcher(); err != nil {
-					return fmt.Errorf("error starting watcher: %w", err)
-				}
-
-				a.watchers[id] = watcher
-				log.Printf("Enabled folder pair: %s -> %s", pair.Source, pair.Destination)
-			} else {
-				// Stop watcher
-				if watcher, exists := a.watchers[id]; exists {
-					if err := watcher.StopWatcher(); err != nil {
-						log.Printf("Error stopping watcher: %v", err)
-					}
-					delete(a.watchers, id)
-				}
-				log.Printf("Disabled folder pair: %s -> %s", pair.Source, pair.Destination)
-			}
+	if a.remote != nil {
+		return a.remote.call("ToggleFolderPair", nil, id, enabled)
+	}
+	a.pairsMu.Lock()
+	defer a.pairsMu.Unlock()
+	_, pair := a.folderPair(id)
+	if pair == nil {
+		return fmt.Errorf("folder pair not found")
+	}
+	if enabled {
+		// Start watcher
+		w, err := a.startWatcher(pair)
+		if err != nil {
+			return err
+		}
 
-			a.config[i].Enabled = enabled
-			a.saveConfig()
-			return nil
+		a.mu.Lock()
+		a.watchers[id] = w
+		pair.Enabled = enabled
+		a.mu.Unlock()
+		watcher.Logf(id, watcher.LogLevelInfo, "Enabled folder pair: %s -> %s", pair.Source, pair.Destination)
+	} else {
+		// Stop watcher
+		a.mu.Lock()
+		w, exists := a.watchers[id]
+		delete(a.watchers, id)
+		pair.Enabled = enabled
+		a.mu.Unlock()
+		if exists {
+			if err := w.StopWatcher(); err != nil {
+				watcher.Logf(id, watcher.LogLevelError, "Error stopping watcher: %v", err)
+			}
 		}
+		watcher.Logf(id, watcher.LogLevelInfo, "Disabled folder pair: %s -> %s", pair.Source, pair.Destination)
 	}
-	return fmt.Errorf("folder pair not found")
-}
 
-// AddFolderPair adds a new folder pair
-func (a *App) AddFolderPair(source, destination string, waitTime float64, folderFormat string) error {
-	id := fmt.Sprintf("watcher-%d", len(a.config))
+	a.saveConfig()
+	return nil
+}
 
-	// Use defaults if not provided
-	if waitTime <= 0 {
-		waitTime = 1.0
-	}
-	if folderFormat == "" {
-		folderFormat = "2006-01-02_15-04-05.000000"
+// AddFolderPair adds a new folder pair, the wait time is a duration such as "2s".
+func (a *App) AddFolderPair(source, destination, waitTime, folderFormat string) error {
+	if a.remote != nil {
+		return a.remote.call("AddFolderPair", nil, source, destination, waitTime, folderFormat)
 	}
+	a.pairsMu.Lock()
+	defer a.pairsMu.Unlock()
+	a.mu.Lock()
+	id := fmt.Sprintf("watcher-%d", len(a.config))
+	a.mu.Unlock()
 
-	watcher, err := NewWatcher(
-		id,
-		source,
-		destination,
-		waitTime,
-		folderFormat,
-	)
+	// Values that are not provided are inherited from the defaults
+	wait, err := watcher.ParseWaitTime(waitTime)
 	if err != nil {
-		return fmt.Errorf("error creating watcher: %w", err)
-	}
-
-	if err := watcher.StartWatcher(); err != nil {
-		return fmt.Errorf("error starting watcher: %w", err)
+		return err
 	}
 
-	pair := &WatcherConfig{
+	pair := &watcher.WatcherConfig{
 		ID:           id,
 		Source:       source,
 		Destination:  destination,
 		Enabled:      true,
-		WaitTime:     waitTime,
+		WaitTime:     wait,
 		FolderFormat: folderFormat,
 	}
+	if err := a.checkPairConflicts(pair); err != nil {
+		return err
+	}
+
+	w, err := a.startWatcher(pair)
+	if err != nil {
+		return err
+	}
 
+	a.mu.Lock()
 	a.config = append(a.config, pair)
-	a.watchers[id] = watcher
+	a.watchers[id] = w
+	a.mu.Unlock()
+	a.updateExcludedPaths()
 
-	log.Printf("Added folder pair: %s -> %s\n", source, destination)
+	// Check the new pair works in the background, problems are reported in the log.
+	go w.SelfTest()
+
+	watcher.Logf(id, watcher.LogLevelInfo, "Added folder pair: %s -> %s", source, destination)
 	a.saveConfig()
 	return nil
 }
 
 // UpdateFolderPair updates an existing folder pair
-func (a *App) UpdateFolderPair(id, source, destination string, waitTime float64, folderFormat string) error {
-	for i, pair := range a.config {
-		if pair.ID == id {
-			// Use existing values if not provided
-			if waitTime <= 0 {
-				waitTime = pair.WaitTime
-			}
-			if folderFormat == "" {
-				folderFormat = pair.FolderFormat
-			}
-
-			// Stop old watcher if enabled
-			if watcher, exists := a.watchers[id]; exists {
-				if err := watcher.StopWatcher(); err != nil {
-					log.Printf("Error stopping watcher: %v", err)
-				}
-				delete(a.watchers, id)
-			}
+func (a *App) UpdateFolderPair(id, source, destination, waitTime, folderFormat string) error {
+	if a.remote != nil {
+		return a.remote.call("UpdateFolderPair", nil, id, source, destination, waitTime, folderFormat)
+	}
+	wait, err := watcher.ParseWaitTime(waitTime)
+	if err != nil {
+		return err
+	}
+	a.pairsMu.Lock()
+	defer a.pairsMu.Unlock()
+	i, pair := a.folderPair(id)
+	if pair == nil {
+		return fmt.Errorf("folder pair not found")
+	}
+	// Use existing values if not provided
+	if wait <= 0 {
+		wait = pair.WaitTime
+	}
+	if folderFormat == "" {
+		folderFormat = pair.FolderFormat
+	}
 
-			// Create new watcher if enabled
-			if pair.Enabled {
-				watcher, err := NewWatcher(
-					id,
-					source,
-					destination,
-					waitTime,
-					folderFormat,
-				)
-				if err != nil {
-					return fmt.Errorf("error creating watcher: %w", err)
-				}
-
-				if err := watcher.StartWatcher(); err != nil {
-					return fmt.Errorf("error starting watcher: %w", err)
-				}
-
-				a.watchers[id] = watcher
-			}
+	updated := *pair
+	updated.Source = source
+	updated.Destination = destination
+	updated.WaitTime = wait
+	updated.FolderFormat = folderFormat
+	if err := a.checkPairConflicts(&updated); err != nil {
+		return err
+	}
 
-			// Update pair
-			a.config[i].Source = source
-			a.config[i].Destination = destination
-			a.config[i].WaitTime = waitTime
-			a.config[i].FolderFormat = folderFormat
+	// Stop old watcher if enabled
+	a.mu.Lock()
+	running, exists := a.watchers[id]
+	delete(a.watchers, id)
+	a.mu.Unlock()
+	if exists {
+		if err := running.StopWatcher(); err != nil {
+			watcher.Logf(id, watcher.LogLevelError, "Error stopping watcher: %v", err)
+		}
+	}
 
-			log.Printf("Updated folder pair: %s -> %s\n", source, destination)
-			a.saveConfig()
-			return nil
+	// Create new watcher if enabled
+	var w *watcher.Watcher
+	if pair.Enabled {
+		if w, err = a.startWatcher(&updated); err != nil {
+			return err
 		}
 	}
-	return fmt.Errorf("folder pair not found")
+
+	// Update pair
+	a.mu.Lock()
+	if w != nil {
+		a.watchers[id] = w
+	}
+	a.config[i] = &updated
+	a.mu.Unlock()
+	a.updateExcludedPaths()
+
+	watcher.Logf(id, watcher.LogLevelInfo, "Updated folder pair: %s -> %s", source, destination)
+	a.saveConfig()
+	return nil
 }
 
-// RemoveFolderPair removes a folder pair by ID
-func (a *App) RemoveFolderPair(id string) error {
-	for i, pair := range a.config {
-		if pair.ID == id {
-			// Stop the watcher
-			if watcher, exists := a.watchers[id]; exists {
-				if err := watcher.StopWatcher(); err != nil {
-					log.Printf("Error stopping watcher: %v", err)
-				}
-				delete(a.watchers, id)
-			}
+// GetFolderPairData returns the backups a folder pair has in its destinations and
+// their size, so deleting them with RemoveFolderPair can be confirmed first
+func (a *App) GetFolderPairData(id string) (watcher.DestinationData, error) {
+	if a.remote != nil {
+		return callDaemon[watcher.DestinationData](a.remote, "GetFolderPairData", id)
+	}
+	_, pair := a.folderPair(id)
+	if pair == nil {
+		return watcher.DestinationData{}, fmt.Errorf("folder pair not found")
+	}
+	w, err := a.folderPairWatcher(pair)
+	if err != nil {
+		return watcher.DestinationData{}, err
+	}
+	return w.DestinationData(), nil
+}
 
-			// Remove from slice
-			a.config = append(a.config[:i], a.config[i+1:]...)
-			a.saveConfig()
-			return nil
+// CheckFolderPair returns how a folder pair with the given source and destination
+// would overlap with the other folder pairs, id is empty for a new pair
+func (a *App) CheckFolderPair(id, source, destination string) []watcher.PairConflict {
+	if a.remote != nil {
+		result, err := callDaemon[[]watcher.PairConflict](a.remote, "CheckFolderPair", id, source, destination)
+		if err != nil {
+			watcher.Logf("", watcher.LogLevelError, "%v", err)
 		}
+		return result
 	}
-	return fmt.Errorf("folder pair not found")
+	pair := &watcher.WatcherConfig{ID: id, Source: source, Destination: destination}
+	if _, existing := a.folderPair(id); existing != nil {
+		pair.RotationDestinations = a.resolve(existing).RotationDestinations
+	}
+	return watcher.FindPairConflicts(pair, a.GetFolderPairs())
 }
 
-// loadConfig loads folder pairs from config file
-func (a *App) loadConfig() error {
-	data, err := os.ReadFile(a.configPath)
-	if os.IsNotExist(err) {
+// checkPairConflicts returns a PairConflictError if a folder pair can not be saved
+// because it overlaps with another folder pair, other overlaps are logged as warnings.
+func (a *App) checkPairConflicts(pair *watcher.WatcherConfig) error {
+	blocking := []watcher.PairConflict{}
+	for _, conflict := range watcher.FindPairConflicts(a.resolve(pair), a.GetFolderPairs()) {
+		if conflict.Blocking {
+			blocking = append(blocking, conflict)
+		} else {
+			watcher.Logf(pair.ID, watcher.LogLevelWarn, "Folder pair overlaps with %s: %s", conflict.Pair, conflict.Message)
+		}
+	}
+	if len(blocking) > 0 {
+		return &watcher.PairConflictError{Conflicts: blocking}
+	}
+	return nil
+}
+
+// RemoveFolderPair removes a folder pair by ID. If deleteBackups is true its backups
+// and metadata are deleted from the destinations, otherwise they are left in place and
+// a folder pair using the same destination picks them up again.
+func (a *App) RemoveFolderPair(id string, deleteBackups bool) error {
+	if a.remote != nil {
+		return a.remote.call("RemoveFolderPair", nil, id, deleteBackups)
+	}
+	a.pairsMu.Lock()
+	defer a.pairsMu.Unlock()
+	i, pair := a.folderPair(id)
+	if pair == nil {
+		return fmt.Errorf("folder pair not found")
+	}
+	var w *watcher.Watcher
+	if deleteBackups {
+		// Created before the running watcher is stopped so a pair whose settings are
+		// no longer valid is not removed without its backups.
+		var err error
+		if w, err = a.folderPairWatcher(pair); err != nil {
+			return fmt.Errorf("error loading backups: %w", err)
+		}
+	}
+
+	// Stop the watcher, a backup in progress is finished so it is not left half
+	// written or deleted while it is being copied.
+	a.mu.Lock()
+	running, exists := a.watchers[id]
+	delete(a.watchers, id)
+	a.config = append(a.config[:i], a.config[i+1:]...)
+	a.mu.Unlock()
+	if exists {
+		if _, err := running.Shutdown(); err != nil {
+			watcher.Logf(id, watcher.LogLevelError, "Error stopping watcher: %v", err)
+		}
+	}
+	a.updateExcludedPaths()
+	a.saveConfig()
+
+	if !deleteBackups {
+		watcher.Logf(id, watcher.LogLevelInfo, "Removed folder pair, its backups were kept in %s", pair.Destination)
 		return nil
 	}
+	if err := w.DeleteDestinationData(); err != nil {
+		return fmt.Errorf("folder pair removed but not every backup could be deleted: %w", err)
+	}
+	watcher.Logf(id, watcher.LogLevelInfo, "Removed folder pair and deleted its backups")
+	return nil
+}
+
+// folderPairWatcher returns the running watcher of a folder pair, or creates one
+// without starting it if the pair is disabled.
+func (a *App) folderPairWatcher(pair *watcher.WatcherConfig) (*watcher.Watcher, error) {
+	if w, exists := a.runningWatcher(pair.ID); exists {
+		return w, nil
+	}
+	return watcher.NewWatcher(*a.resolve(pair))
+}
+
+// startWatcher creates and starts a watcher for a folder pair, using the defaults for
+// any values the pair does not set.
+func (a *App) startWatcher(pair *watcher.WatcherConfig) (*watcher.Watcher, error) {
+	resolved := a.resolve(pair)
+	// Excluded before the watcher starts so backups of the other watchers never
+	// trigger it, pairs added later are excluded by updateExcludedPaths.
+	w, err := watcher.NewWatcher(*resolved,
+		watcher.WithObserver(a),
+		watcher.WithExcludedPaths(watcher.ChainedDestinations(resolved, a.GetFolderPairs())),
+		watcher.WithBackupLimiter(a.scheduler),
+		watcher.WithKeyring(a.keyring),
+		watcher.WithCrashDumpDir(a.options.crashDumpDir()),
+	)
 	if err != nil {
-		return fmt.Errorf("error reading config file: %w", err)
+		return nil, err
 	}
 
-	var pairs []*WatcherConfig
-	if err := json.Unmarshal(data, &pairs); err != nil {
-		return fmt.Errorf("error parsing config: %w", err)
+	if err := w.StartWatcher(); err != nil {
+		return nil, fmt.Errorf("error starting watcher: %w", err)
 	}
+	return w, nil
+}
 
-	// Start watchers for each pair
+// updateExcludedPaths excludes the destinations of every folder pair from the
+// watchers whose source contains them. It is called whenever folder pairs change.
+func (a *App) updateExcludedPaths() {
+	pairs := a.GetFolderPairs()
 	for _, pair := range pairs {
-		// Set defaults if missing
-		if pair.WaitTime <= 0 {
-			pair.WaitTime = 1.0
-		}
-		if pair.FolderFormat == "" {
-			pair.FolderFormat = "2006-01-02_15-04-05.000000"
+		if w, exists := a.runningWatcher(pair.ID); exists {
+			w.SetExcludedPaths(watcher.ChainedDestinations(pair, pairs))
 		}
+	}
+}
 
+// OnBackupCompletion forwards completed backups to the frontend
+func (a *App) OnBackupCompletion(w *watcher.Watcher) {
+	a.emit("backup-complete", w.Name)
+}
+
+// OnWarning forwards watcher warnings to the frontend
+func (a *App) OnWarning(w *watcher.Watcher, message string) {
+	a.emit("watcher-warning", w.Name, message)
+}
+
+// OnSourceMoved saves the new source of a watcher that followed its source after it was
+// renamed or moved.
+func (a *App) OnSourceMoved(w *watcher.Watcher, oldSource, newSource string) {
+	// Not waited for, a method changing folder pairs can be waiting for the observers of
+	// the watcher to finish.
+	go a.saveMovedSource(w, oldSource, newSource)
+}
+
+func (a *App) saveMovedSource(w *watcher.Watcher, oldSource, newSource string) {
+	a.pairsMu.Lock()
+	defer a.pairsMu.Unlock()
+	_, pair := a.folderPair(w.Name)
+	if pair == nil {
+		return
+	}
+	a.mu.Lock()
+	pair.Source = newSource
+	a.mu.Unlock()
+	a.updateExcludedPaths()
+	if err := a.saveConfig(); err != nil {
+		watcher.Logf(pair.ID, watcher.LogLevelError, "%v", err)
+	}
+	a.emit("watcher-source-moved", w.Name, oldSource, newSource)
+}
+
+// loadConfig loads folder pairs from config file
+func (a *App) loadConfig() error {
+	config, err := readConfig(a.configPath, a.configFormat)
+	if err != nil {
+		return err
+	}
+	a.pairsMu.Lock()
+	defer a.pairsMu.Unlock()
+	a.mu.Lock()
+	a.defaults = config.Defaults
+	a.maxConcurrentBackups = config.MaxConcurrentBackups
+	a.scheduler = newBackupScheduler(config.MaxConcurrentBackups)
+	a.mu.Unlock()
+
+	// Start watchers for each pair
+	for _, pair := range config.Watchers {
 		// Only start watcher if enabled
 		if pair.Enabled {
-			watcher, err := NewWatcher(
-				pair.ID,
-				pair.Source,
-				pair.Destination,
-				pair.WaitTime,
-				pair.FolderFormat,
-			)
+			w, err := a.startWatcher(pair)
 			if err != nil {
-				log.Printf("Error creating watcher for %s: %v", pair.ID, err)
+				watcher.Logf(pair.ID, watcher.LogLevelError, "%v", err)
+				a.mu.Lock()
 				a.config = append(a.config, pair)
+				a.mu.Unlock()
 				continue
 			}
 
-			if err := watcher.StartWatcher(); err != nil {
-				log.Printf("Error starting watcher for %s: %v", pair.ID, err)
-				a.config = append(a.config, pair)
-				continue
-			}
-
-			a.watchers[pair.ID] = watcher
+			a.mu.Lock()
+			a.watchers[pair.ID] = w
+			a.mu.Unlock()
 		}
 
+		a.mu.Lock()
 		a.config = append(a.config, pair)
-		log.Printf("Loaded folder pair: %s -> %s", pair.Source, pair.Destination)
+		a.mu.Unlock()
+		watcher.Logf(pair.ID, watcher.LogLevelInfo, "Loaded folder pair: %s -> %s", pair.Source, pair.Destination)
 	}
+	a.updateExcludedPaths()
 
 	return nil
 }
 
 // saveConfig saves folder pairs to config file
 func (a *App) saveConfig() error {
-	data, err := json.MarshalIndent(a.config, "", "  ")
+	a.mu.Lock()
+	config := &Config{
+		Defaults:             a.defaults,
+		MaxConcurrentBackups: a.maxConcurrentBackups,
+		Watchers:             a.config,
+	}
+	data, err := marshalConfig(config, a.configFormat)
+	a.mu.Unlock()
 	if err != nil {
 		return fmt.Errorf("error marshaling config: %w", err)
 	}
@@ -297,6 +846,6 @@ func (a *App) saveConfig() error {
 		return fmt.Errorf("error writing config file: %w", err)
 	}
 
-	log.Printf("Config saved to %s", a.configPath)
+	watcher.Logf("", watcher.LogLevelDebug, "Config saved to %s", a.configPath)
 	return nil
 }
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+	"ryn-cx/i-saw-that/pkg/watcher/watchertest"
+)
+
+func TestExcludedPathEvents(t *testing.T) {
+	t.Parallel()
+	temp := watchertest.DefaultTempWatcherConfig(t)
+	w, err := watchertest.NewTempWatcher(temp)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	other := filepath.Join(temp.Source, "other-backups")
+
+	app := NewApp(&Options{ConfigPath: filepath.Join(temp.TempPath, "config.json")})
+	app.config = []*watcher.WatcherConfig{
+		{ID: temp.Name, Source: temp.Source, Destination: temp.Destination},
+		{ID: "other", Source: filepath.Join(temp.TempPath, "other"), Destination: other},
+	}
+	app.watchers[temp.Name] = w
+	app.updateExcludedPaths()
+
+	if !w.IsExcludedPath(filepath.Join(other, "2024-05-01_18-30-00.000000", "file.txt")) {
+		t.Errorf("Expected events in the other destination to be ignored")
+	}
+	if w.IsExcludedPath(filepath.Join(temp.Source, "file.txt")) {
+		t.Errorf("Expected events elsewhere in the source to not be ignored")
+	}
+
+	// Removing the other pair stops excluding its destination.
+	app.config = app.config[:1]
+	app.updateExcludedPaths()
+	if w.IsExcludedPath(filepath.Join(other, "file.txt")) {
+		t.Errorf("Expected the destination to no longer be ignored")
+	}
+}
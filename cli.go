@@ -0,0 +1,1481 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+)
+
+// Exit codes used by the command line interface, wrapper scripts can rely on these
+// staying the same.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+	// The config file could not be read or parsed.
+	exitConfig = 3
+	// The settings of a folder pair are invalid.
+	exitValidation = 4
+	// A backup could not be created.
+	exitBackupFailed = 5
+	// A backup was changed or is missing.
+	exitVerifyFailed = 6
+	// The command had nothing to do, such as pruning without a retention policy.
+	exitNothingToDo = 7
+	// A folder is different from the backup it was compared with.
+	exitDifferent = 8
+	// self-update --check found a newer release.
+	exitUpdateAvailable = 9
+	// Another instance is using the same data directory.
+	exitInstanceRunning = 10
+	// The stress test found backups that broke an invariant.
+	exitStressFailed = 11
+)
+
+var (
+	errUsage        = errors.New("usage error")
+	errConfig       = errors.New("invalid config")
+	errValidation   = errors.New("invalid folder pair")
+	errVerifyFailed = errors.New("verification failed")
+	errDifferent    = errors.New("folder differs from the backup")
+	errStressFailed = errors.New("stress test failed")
+	// errUpdateAvailable is returned after the update check has explained that a
+	// newer release is available.
+	errUpdateAvailable = errors.New("update available")
+	// errNothingToDo is returned after a command has explained why it did nothing, it
+	// is not reported as an error.
+	errNothingToDo = errors.New("nothing to do")
+)
+
+// exitCodes maps the errors returned by commands to exit codes, the first match wins
+// and any other error exits with exitError.
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{errUsage, exitUsage},
+	{errConfig, exitConfig},
+	{errValidation, exitValidation},
+	{watcher.ErrSafetyBackup, exitBackupFailed},
+	{errVerifyFailed, exitVerifyFailed},
+	{watcher.ErrUntrustedBackup, exitVerifyFailed},
+	{errNothingToDo, exitNothingToDo},
+	{errDifferent, exitDifferent},
+	{errUpdateAvailable, exitUpdateAvailable},
+	{errInstanceRunning, exitInstanceRunning},
+	{errStressFailed, exitStressFailed},
+}
+
+// exitCode returns the exit code for the error returned by a command.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	for _, entry := range exitCodes {
+		if errors.Is(err, entry.err) {
+			return entry.code
+		}
+	}
+	return exitError
+}
+
+// cliContext is passed to every command.
+type cliContext struct {
+	options *Options
+	stdin   *bufio.Reader
+	// The file stdin reads from if it is one, so typing a password can be hidden.
+	stdinFile *os.File
+	stdout    io.Writer
+	stderr    io.Writer
+	// Set by --json, output is written as JSON instead of text.
+	json bool
+	// True if stdout is a terminal that colors can be written to.
+	color bool
+}
+
+type cliCommand struct {
+	name        string
+	usage       string
+	description string
+	run         func(ctx *cliContext, args []string) error
+}
+
+// Commands that can be run instead of the GUI.
+var cliCommands []cliCommand
+
+func init() {
+	cliCommands = []cliCommand{
+		{
+			name:        "status",
+			usage:       "status",
+			description: "Show every folder pair and whether its source is backed up",
+			run:         runStatus,
+		},
+		{
+			name:        "list",
+			usage:       "list <watcher>",
+			description: "List the backups of a folder pair",
+			run:         runList,
+		},
+		{
+			name:        "verify",
+			usage:       "verify <watcher> [--backup <id>] [--drill]",
+			description: "Check that backups have not changed since they were made",
+			run:         runVerify,
+		},
+		{
+			name:        "annotate",
+			usage:       "annotate <watcher> <backup> <note>",
+			description: "Add a note to a backup, an empty note removes it",
+			run:         runAnnotate,
+		},
+		{
+			name:        "events",
+			usage:       "events <watcher> <backup>",
+			description: "Show the file events that led to a backup, needs audit_log",
+			run:         runEvents,
+		},
+		{
+			name:        "compare",
+			usage:       "compare <watcher> <backup> <folder>",
+			description: "Show how a folder differs from a backup",
+			run:         runCompare,
+		},
+		{
+			name:        "prune",
+			usage:       "prune <watcher> [--dry-run]",
+			description: "Delete the backups that are not kept by the retention policy",
+			run:         runPrune,
+		},
+		{
+			name:        "resume",
+			usage:       "resume <watcher>",
+			description: "Let pruning delete backups again after a mass change",
+			run:         runResume,
+		},
+		{
+			name:        "restore",
+			usage:       "restore <watcher> [--at <time> | --latest] [--yes]",
+			description: "Replace the source with a backup, the source is backed up first",
+			run:         runRestore,
+		},
+		{
+			name:        "share",
+			usage:       "share <share> [--user <user>] [--forget]",
+			description: "Save the credentials of a network share in the keychain",
+			run:         runShare,
+		},
+		{
+			name:        "remote-login",
+			usage:       "remote-login <watcher> [--forget]",
+			description: "Sign in to the Google Drive or OneDrive account backups are copied to",
+			run:         runRemoteLogin,
+		},
+		{
+			name:        "secrets",
+			usage:       "secrets [set <name> | delete <name> | migrate]",
+			description: "List, save or move the passwords and keys settings keep in the keychain",
+			run:         runSecrets,
+		},
+		{
+			name:        "keys",
+			usage:       "keys [generate | trust <public key> | untrust <id>]",
+			description: "List, create or trust the keys backups are signed with",
+			run:         runKeys,
+		},
+		{
+			name:        "self-update",
+			usage:       "self-update [--check]",
+			description: "Replace this executable with the latest release",
+			run:         runSelfUpdate,
+		},
+		{
+			name:        "daemon",
+			usage:       "daemon",
+			description: "Run the backups in the background, the GUI connects to the daemon",
+			run:         runDaemon,
+		},
+		{
+			name:        "stress",
+			usage:       "stress [--watcher <id>] [--duration <time>]",
+			description: "Back up random changes to a temporary folder and check every backup",
+			run:         runStress,
+		},
+		{
+			name:        "help",
+			usage:       "help",
+			description: "Show the available commands",
+			run:         runHelp,
+		},
+	}
+}
+
+func findCLICommand(name string) (*cliCommand, bool) {
+	for i := range cliCommands {
+		if cliCommands[i].name == name {
+			return &cliCommands[i], true
+		}
+	}
+	return nil, false
+}
+
+// runCLI runs a command and returns the exit code for the process.
+func runCLI(options *Options, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	ctx := &cliContext{options: options, stdin: bufio.NewReader(stdin), stdout: stdout, stderr: stderr, color: colorEnabled(stdout)}
+	ctx.stdinFile, _ = stdin.(*os.File)
+
+	command, ok := findCLICommand(args[0])
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command: %s\n\n", args[0])
+		runHelp(ctx, nil)
+		return exitUsage
+	}
+
+	err := command.run(ctx, args[1:])
+	code := exitCode(err)
+
+	switch {
+	case code == exitNothingToDo || code == exitUpdateAvailable:
+		// The command has already explained why it did nothing.
+	case err != nil && ctx.json:
+		output := struct {
+			Error string `json:"error"`
+			Usage string `json:"usage,omitempty"`
+		}{Error: err.Error()}
+		if code == exitUsage {
+			output.Usage = "i-saw-that " + command.usage
+		}
+		json.NewEncoder(stderr).Encode(output)
+	case code == exitUsage:
+		fmt.Fprintf(stderr, "Usage: i-saw-that %s\n", command.usage)
+	case err != nil:
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+	}
+	return code
+}
+
+// writeJSON writes the output of a command in --json mode.
+func (ctx *cliContext) writeJSON(value any) error {
+	encoder := json.NewEncoder(ctx.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func runHelp(ctx *cliContext, args []string) error {
+	if _, err := parseCommandArgs(ctx.newFlags("help"), args, 0); err != nil {
+		return err
+	}
+	if ctx.json {
+		type commandHelp struct {
+			Name        string `json:"name"`
+			Usage       string `json:"usage"`
+			Description string `json:"description"`
+		}
+		commands := []commandHelp{}
+		for _, command := range cliCommands {
+			commands = append(commands, commandHelp{command.name, command.usage, command.description})
+		}
+		return ctx.writeJSON(commands)
+	}
+
+	fmt.Fprintln(ctx.stderr, "Usage: i-saw-that [options] [command]")
+	fmt.Fprintln(ctx.stderr, "\nThe GUI is started when no command is given.")
+	fmt.Fprintln(ctx.stderr, "\nCommands:")
+	for _, command := range cliCommands {
+		fmt.Fprintf(ctx.stderr, "  %-52s %s\n", command.usage, command.description)
+	}
+	fmt.Fprintln(ctx.stderr, "\nEvery command accepts --json to write machine-readable output.")
+	return nil
+}
+
+// newFlags creates a flag set for a command that reports errors through the command's
+// usage message instead of printing its own. Every command accepts --json.
+func (ctx *cliContext) newFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.BoolVar(&ctx.json, "json", false, "write the output as JSON")
+	return flags
+}
+
+// parseCommandArgs parses flags that may appear before or after the positional
+// arguments and checks the number of positional arguments.
+func parseCommandArgs(flags *flag.FlagSet, args []string, positional int) ([]string, error) {
+	values, err := collectCommandArgs(flags, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != positional {
+		return nil, errUsage
+	}
+	return values, nil
+}
+
+// collectCommandArgs parses flags that may appear before or after the positional
+// arguments, for commands where the number of arguments depends on the action.
+func collectCommandArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	values := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %w", errUsage, err)
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return values, nil
+		}
+		values = append(values, args[0])
+		args = args[1:]
+	}
+}
+
+// readCLIConfig reads the config file for a command.
+func readCLIConfig(options *Options) (*Config, error) {
+	config, err := readConfig(options.ConfigPath, options.configFormat())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errConfig, err)
+	}
+	return config, nil
+}
+
+// loadCLIWatcher creates the watcher for a folder pair in the config without starting
+// it.
+func loadCLIWatcher(options *Options, id string) (*watcher.Watcher, error) {
+	config, err := readCLIConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring, err := loadKeyring(options)
+	if err != nil {
+		return nil, err
+	}
+	for _, pair := range config.Watchers {
+		if pair.ID == id {
+			w, err := watcher.NewWatcher(*config.Defaults.resolve(pair), watcher.WithKeyring(keyring), watcher.WithKeychain(options.osKeychain()))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errValidation, err)
+			}
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("folder pair not found: %s", id)
+}
+
+// WatcherSummary is a single folder pair in the output of the status command.
+type WatcherSummary struct {
+	ID           string   `json:"id"`
+	Enabled      bool     `json:"enabled"`
+	Source       string   `json:"source"`
+	Destinations []string `json:"destinations"`
+	Backups      int      `json:"backups"`
+	// Time of the newest backup, zero if there are no backups.
+	LatestBackup time.Time `json:"latest_backup,omitzero"`
+	// True if the source matches the newest backup.
+	UpToDate bool `json:"up_to_date"`
+	// Total size of the backups in bytes.
+	Size int64 `json:"size"`
+	// Failed backups since the statistics were last reset and the time of the last one.
+	Failed      int       `json:"failed"`
+	LastFailure time.Time `json:"last_failure,omitzero"`
+	// Mass change that paused retention, empty if retention is not paused.
+	RetentionPaused string `json:"retention_paused,omitempty"`
+	// State of the watcher in the daemon, nil if no daemon is running.
+	Status *watcher.WatcherStatus `json:"status,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+func runStatus(ctx *cliContext, args []string) error {
+	if _, err := parseCommandArgs(ctx.newFlags("status"), args, 0); err != nil {
+		return err
+	}
+	config, err := readCLIConfig(ctx.options)
+	if err != nil {
+		return err
+	}
+
+	// The statistics of a running daemon are newer than the ones it last saved.
+	remote := runningDaemon(ctx.options.DataDir)
+	summaries := []WatcherSummary{}
+	for _, pair := range config.Watchers {
+		resolved := config.Defaults.resolve(pair)
+		summary := WatcherSummary{
+			ID:           pair.ID,
+			Enabled:      pair.Enabled,
+			Source:       resolved.Source,
+			Destinations: append([]string{resolved.Destination}, resolved.RotationDestinations...),
+		}
+
+		w, err := watcher.NewWatcher(*resolved)
+		if err == nil {
+			backups := w.ListBackups()
+			summary.Backups = len(backups)
+			if len(backups) > 0 {
+				summary.LatestBackup = watcher.BackupTime(backups[len(backups)-1])
+			}
+			summary.Size = w.DestinationData().Size
+			stats := w.Stats()
+			if remote != nil {
+				status := remote.watcherStatus(pair.ID)
+				summary.Status = &status
+				if remoteStats, err := callDaemon[watcher.WatcherStats](remote, "GetStats", pair.ID); err == nil {
+					stats = remoteStats
+				}
+			}
+			summary.Failed, summary.LastFailure = stats.Failed, stats.LastFailure
+			summary.RetentionPaused = w.RetentionPaused()
+			summary.UpToDate, err = w.SourceMatchesLatestBackup()
+		}
+		if err != nil {
+			summary.Error = err.Error()
+		}
+		summaries = append(summaries, summary)
+	}
+
+	if ctx.json {
+		return ctx.writeJSON(summaries)
+	}
+	if len(summaries) == 0 {
+		fmt.Fprintln(ctx.stdout, "No folder pairs are configured")
+		return nil
+	}
+
+	now := time.Now()
+	rows := [][]tableCell{{{text: "WATCHER"}, {text: "STATE"}, {text: "BACKUPS"}, {text: "LATEST"}, {text: "SIZE"}}}
+	notes := []string{}
+	for _, summary := range summaries {
+		state, color := summary.state()
+		if !summary.Enabled {
+			state += ", disabled"
+		}
+		latest := "-"
+		if summary.Backups > 0 {
+			latest = formatAge(now.Sub(summary.LatestBackup))
+		}
+		rows = append(rows, []tableCell{
+			{text: summary.ID},
+			{text: state, color: color},
+			{text: strconv.Itoa(summary.Backups)},
+			{text: latest},
+			{text: watcher.FormatBytes(summary.Size)},
+		})
+
+		switch {
+		case summary.Error != "":
+			notes = append(notes, fmt.Sprintf("%s: %s", summary.ID, summary.Error))
+		case summary.Status != nil && summary.Status.Error != "":
+			notes = append(notes, fmt.Sprintf("%s: %s", summary.ID, summary.Status.Error))
+		case summary.Status != nil && summary.Status.Deferred != "":
+			notes = append(notes, fmt.Sprintf("%s: %s", summary.ID, summary.Status.Deferred))
+		}
+		if summary.RetentionPaused != "" {
+			notes = append(notes, fmt.Sprintf("%s: %s, retention is paused until `resume %s`", summary.ID, summary.RetentionPaused, summary.ID))
+		}
+		if summary.Status != nil && summary.Status.CrashDump != "" {
+			notes = append(notes, fmt.Sprintf("%s: %s, the crash dump is %s", summary.ID, summary.Status.Crashed, summary.Status.CrashDump))
+		}
+		if summary.Status != nil && summary.Status.Stale != "" {
+			notes = append(notes, fmt.Sprintf("%s: %s", summary.ID, summary.Status.Stale))
+		}
+		if summary.lastBackupFailed() {
+			notes = append(notes, fmt.Sprintf("%s: the last backup failed %s, %d failed in total", summary.ID, formatAge(now.Sub(summary.LastFailure)), summary.Failed))
+		}
+		for _, health := range summary.unhealthy() {
+			notes = append(notes, fmt.Sprintf("%s: health check of %s failed %s: %s", summary.ID, health.Backend, formatAge(now.Sub(health.CheckedAt)), health.Error))
+		}
+	}
+	ctx.writeTable(rows)
+	if len(notes) > 0 {
+		fmt.Fprintln(ctx.stdout)
+		for _, note := range notes {
+			fmt.Fprintln(ctx.stdout, note)
+		}
+	}
+	return nil
+}
+
+// lastBackupFailed returns true if a backup failed after the latest backup was made.
+func (s WatcherSummary) lastBackupFailed() bool {
+	return !s.LastFailure.IsZero() && s.LastFailure.After(s.LatestBackup)
+}
+
+// unhealthy returns the destinations and remote that failed their last health check.
+func (s WatcherSummary) unhealthy() []watcher.BackendHealth {
+	if s.Status == nil {
+		return nil
+	}
+	failed := []watcher.BackendHealth{}
+	for _, health := range s.Status.Health {
+		if !health.Healthy {
+			failed = append(failed, health)
+		}
+	}
+	return failed
+}
+
+// state describes a folder pair in the status table along with the color to show it in.
+func (s WatcherSummary) state() (string, string) {
+	switch {
+	case s.Status != nil && s.Status.Crashed != "":
+		return "crashed", colorRed
+	case s.Error != "" || (s.Status != nil && s.Status.Error != ""):
+		return "error", colorRed
+	case s.RetentionPaused != "":
+		return "mass change", colorRed
+	case s.lastBackupFailed():
+		return "backup failed", colorRed
+	case len(s.unhealthy()) > 0:
+		return "unhealthy", colorRed
+	case s.Status != nil && s.Status.Stale != "":
+		return "stale", colorYellow
+	case s.Backups == 0:
+		return "no backups", colorYellow
+	case !s.UpToDate:
+		return "changed", colorYellow
+	}
+	return "up to date", colorGreen
+}
+
+// BackupListing is a single backup in the output of the list command.
+type BackupListing struct {
+	watcher.Backup
+	Time time.Time `json:"time"`
+	// Full path of the backup folder.
+	Location string `json:"location"`
+	// Size of the backup in bytes.
+	Size int64 `json:"size"`
+	// True if the backup folder does not exist.
+	Missing bool `json:"missing,omitempty"`
+	// Reason the backup was made, empty if the backup has no sidecar.
+	Trigger watcher.BackupTrigger `json:"trigger,omitempty"`
+}
+
+func newBackupListing(w *watcher.Watcher, backup watcher.Backup) BackupListing {
+	listing := BackupListing{
+		Backup:   backup,
+		Time:     watcher.BackupTime(backup),
+		Location: w.BackupPath(backup),
+	}
+	if backup.ResticSnapshot != "" {
+		listing.Location = "restic:" + w.Restic.Repository + "#" + backup.ResticSnapshot
+		return listing
+	}
+	size, err := watcher.DirSize(listing.Location)
+	listing.Size = size
+	listing.Missing = errors.Is(err, os.ErrNotExist)
+	if sidecar, err := watcher.ReadBackupSidecar(listing.Location); err == nil {
+		listing.Trigger = sidecar.Trigger
+	}
+	return listing
+}
+
+// formatBackupListing formats a backup as a line of the text output.
+func formatBackupListing(listing BackupListing, now time.Time) string {
+	size := watcher.FormatBytes(listing.Size)
+	if listing.Missing {
+		size = "missing"
+	}
+	name := listing.Name
+	if listing.Fuzzy {
+		name = strings.TrimSpace(name + " (fuzzy)")
+	}
+	if len(listing.HighEntropy) > 0 {
+		name = strings.TrimSpace(name + " (possibly encrypted)")
+	}
+	if listing.Note != "" {
+		name = strings.TrimSpace(name + " " + strconv.Quote(listing.Note))
+	}
+	return fmt.Sprintf("%s  %-16s %10s  %s", listing.Time.Format(time.DateTime), formatAge(now.Sub(listing.Time)), size, name)
+}
+
+func runList(ctx *cliContext, args []string) error {
+	values, err := parseCommandArgs(ctx.newFlags("list"), args, 1)
+	if err != nil {
+		return err
+	}
+	w, err := loadCLIWatcher(ctx.options, values[0])
+	if err != nil {
+		return err
+	}
+
+	listings := []BackupListing{}
+	for _, backup := range w.ListBackups() {
+		listings = append(listings, newBackupListing(w, backup))
+	}
+	if ctx.json {
+		return ctx.writeJSON(listings)
+	}
+
+	if len(listings) == 0 {
+		fmt.Fprintln(ctx.stdout, "There are no backups")
+	}
+	now := time.Now()
+	for i := len(listings) - 1; i >= 0; i-- {
+		fmt.Fprintf(ctx.stdout, "%s  %s\n", formatBackupListing(listings[i], now), listings[i].Path)
+	}
+	return nil
+}
+
+func runVerify(ctx *cliContext, args []string) error {
+	flags := ctx.newFlags("verify")
+	backupID := flags.String("backup", "", "only verify this backup")
+	drill := flags.Bool("drill", false, "restore a recent backup into a temporary folder and check it")
+	values, err := parseCommandArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	w, err := loadCLIWatcher(ctx.options, values[0])
+	if err != nil {
+		return err
+	}
+
+	backups := w.ListBackups()
+	if *backupID != "" {
+		backup, err := w.FindBackup(*backupID)
+		if err != nil {
+			return err
+		}
+		backups = []watcher.Backup{backup}
+	}
+
+	results := []watcher.BackupVerification{}
+	if *drill {
+		result, err := w.RunRestoreDrill(*backupID)
+		if err != nil && !errors.Is(err, watcher.ErrNoBackupsToDrill) {
+			return err
+		}
+		if err == nil {
+			results = append(results, result)
+		}
+	} else {
+		for _, backup := range backups {
+			results = append(results, w.VerifyBackup(backup))
+		}
+	}
+	failed := 0
+	for _, result := range results {
+		if result.Failed() {
+			failed++
+		}
+	}
+
+	if ctx.json {
+		if err := ctx.writeJSON(results); err != nil {
+			return err
+		}
+	} else {
+		if len(results) == 0 {
+			fmt.Fprintln(ctx.stdout, "There are no backups")
+		}
+		for _, result := range results {
+			line := fmt.Sprintf("%-12s %s", result.Status, w.BackupPath(result.Backup))
+			if result.Error != "" {
+				line += ": " + result.Error
+			}
+			fmt.Fprintln(ctx.stdout, line)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d backups failed", errVerifyFailed, failed, len(results))
+	}
+	if len(results) == 0 {
+		return errNothingToDo
+	}
+	return nil
+}
+
+func runAnnotate(ctx *cliContext, args []string) error {
+	values, err := parseCommandArgs(ctx.newFlags("annotate"), args, 3)
+	if err != nil {
+		return err
+	}
+	w, err := loadCLIWatcher(ctx.options, values[0])
+	if err != nil {
+		return err
+	}
+
+	if err := w.AnnotateBackup(values[1], values[2]); err != nil {
+		return err
+	}
+	backup, err := w.FindBackup(values[1])
+	if err != nil {
+		return err
+	}
+	if ctx.json {
+		return ctx.writeJSON(newBackupListing(w, backup))
+	}
+	fmt.Fprintf(ctx.stdout, "%s  %s\n", formatBackupListing(newBackupListing(w, backup), time.Now()), backup.Path)
+	return nil
+}
+
+func runEvents(ctx *cliContext, args []string) error {
+	values, err := parseCommandArgs(ctx.newFlags("events"), args, 2)
+	if err != nil {
+		return err
+	}
+	w, err := loadCLIWatcher(ctx.options, values[0])
+	if err != nil {
+		return err
+	}
+
+	backup, err := w.FindBackup(values[1])
+	if err != nil {
+		return err
+	}
+	if ctx.json {
+		events := backup.Events
+		if events == nil {
+			events = []watcher.FileEvent{}
+		}
+		return ctx.writeJSON(events)
+	}
+
+	if len(backup.Events) == 0 {
+		fmt.Fprintf(ctx.stdout, "No file events were recorded for %s\n", backup.Path)
+	}
+	for _, event := range backup.Events {
+		fmt.Fprintf(ctx.stdout, "%s  %-14s %s\n", event.Time.Local().Format("2006-01-02 15:04:05.000"), event.Op, event.Path)
+	}
+	if backup.DroppedEvents > 0 {
+		fmt.Fprintf(ctx.stdout, "%d more events were not recorded\n", backup.DroppedEvents)
+	}
+	return nil
+}
+
+func runCompare(ctx *cliContext, args []string) error {
+	values, err := parseCommandArgs(ctx.newFlags("compare"), args, 3)
+	if err != nil {
+		return err
+	}
+	w, err := loadCLIWatcher(ctx.options, values[0])
+	if err != nil {
+		return err
+	}
+
+	comparison, err := w.CompareBackup(values[1], values[2])
+	if err != nil {
+		return err
+	}
+	if ctx.json {
+		if err := ctx.writeJSON(comparison); err != nil {
+			return err
+		}
+	} else {
+		for _, difference := range comparison.Differences {
+			fmt.Fprintf(ctx.stdout, "%-8s %s\n", difference.Kind, difference.Path)
+		}
+		if len(comparison.Differences) == 0 {
+			fmt.Fprintf(ctx.stdout, "%s matches the backup\n", comparison.Directory)
+		}
+	}
+
+	if len(comparison.Differences) > 0 {
+		return fmt.Errorf("%w: %d differences", errDifferent, len(comparison.Differences))
+	}
+	return nil
+}
+
+func runPrune(ctx *cliContext, args []string) error {
+	flags := ctx.newFlags("prune")
+	dryRun := flags.Bool("dry-run", false, "only show what would be deleted")
+	values, err := parseCommandArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	w, err := loadCLIWatcher(ctx.options, values[0])
+	if err != nil {
+		return err
+	}
+	if !w.Retention.Enabled() {
+		if ctx.json {
+			return errors.Join(errNothingToDo, ctx.writeJSON(watcher.PruneResult{DryRun: *dryRun, Pruned: []watcher.PrunedBackup{}}))
+		}
+		fmt.Fprintln(ctx.stdout, "No retention policy is set, nothing to prune")
+		return errNothingToDo
+	}
+
+	// The source is backed up before the backups to delete are chosen, so the safety
+	// backup counts toward the retention policy like any other backup. Without any
+	// backups there is nothing to prune, so nothing needs backing up either.
+	if !*dryRun && len(w.ListBackups()) > 0 {
+		if _, err := w.SafetyBackup("pruning"); err != nil {
+			return err
+		}
+	}
+
+	result, err := w.Prune(*dryRun)
+	if err == nil && len(result.Pruned) == 0 {
+		err = errNothingToDo
+	}
+	if ctx.json {
+		if jsonErr := ctx.writeJSON(result); jsonErr != nil {
+			return errors.Join(err, jsonErr)
+		}
+		return err
+	}
+
+	action, reclaimed := "Deleted", "Reclaimed"
+	switch {
+	case result.DryRun:
+		action, reclaimed = "Would delete", "Would reclaim"
+	case result.Trashed:
+		action, reclaimed = "Moved to the trash", "Will reclaim"
+	}
+	for _, pruned := range result.Pruned {
+		fmt.Fprintf(ctx.stdout, "%s %s (%s)\n", action, w.BackupPath(pruned.Backup), watcher.FormatBytes(pruned.Size))
+	}
+
+	fmt.Fprintf(ctx.stdout, "%s %s from %d backups", reclaimed, watcher.FormatBytes(result.Reclaimed), len(result.Pruned))
+	if result.Trashed && !result.DryRun {
+		fmt.Fprint(ctx.stdout, " once the trash is emptied")
+	}
+	fmt.Fprintln(ctx.stdout)
+	return err
+}
+
+func runResume(ctx *cliContext, args []string) error {
+	values, err := parseCommandArgs(ctx.newFlags("resume"), args, 1)
+	if err != nil {
+		return err
+	}
+	w, err := loadCLIWatcher(ctx.options, values[0])
+	if err != nil {
+		return err
+	}
+
+	massChange := w.RetentionPaused()
+	w.ResumeRetention()
+	if ctx.json {
+		output := struct {
+			Resumed    bool   `json:"resumed"`
+			MassChange string `json:"mass_change,omitempty"`
+		}{massChange != "", massChange}
+		if massChange == "" {
+			return errors.Join(errNothingToDo, ctx.writeJSON(output))
+		}
+		return ctx.writeJSON(output)
+	}
+	if massChange == "" {
+		fmt.Fprintln(ctx.stdout, "Retention is not paused")
+		return errNothingToDo
+	}
+	fmt.Fprintf(ctx.stdout, "Resumed retention, it was paused after %s\n", massChange)
+	return nil
+}
+
+func runShare(ctx *cliContext, args []string) error {
+	flags := ctx.newFlags("share")
+	user := flags.String("user", "", "user to connect as, asked for if it is not given")
+	forget := flags.Bool("forget", false, "remove the saved credentials")
+	values, err := parseCommandArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	share, ok := watcher.SharePath(values[0])
+	if !ok {
+		return fmt.Errorf(`%w: %s is not a network share, such as \\nas\backups`, errUsage, values[0])
+	}
+	keychain := watcher.OSKeychain()
+
+	if *forget {
+		if err := watcher.DeleteShareCredentials(keychain, share); err != nil {
+			return err
+		}
+		if ctx.json {
+			return ctx.writeJSON(struct {
+				Share     string `json:"share"`
+				Forgotten bool   `json:"forgotten"`
+			}{share, true})
+		}
+		fmt.Fprintf(ctx.stdout, "Removed the credentials of %s\n", share)
+		return nil
+	}
+
+	// There is no one to answer the question for the user in --json mode, the password
+	// is still read from stdin so it does not end up in the arguments.
+	if *user == "" && ctx.json {
+		return fmt.Errorf("%w: --json requires --user", errUsage)
+	}
+	if *user == "" {
+		if *user, err = ctx.readLine(fmt.Sprintf("User for %s: ", share), false); err != nil {
+			return err
+		}
+	}
+	password, err := ctx.readLine("Password: ", true)
+	if err != nil {
+		return err
+	}
+	credentials := watcher.ShareCredentials{User: *user, Password: password}
+	if err := watcher.SaveShareCredentials(keychain, share, credentials); err != nil {
+		return err
+	}
+	// Shares are only connected by the app on Windows, elsewhere they are mounted.
+	connectErr := watcher.ConnectShare(keychain, share)
+	if errors.Is(connectErr, errors.ErrUnsupported) {
+		connectErr = nil
+	}
+	if ctx.json {
+		output := struct {
+			Share     string `json:"share"`
+			Saved     bool   `json:"saved"`
+			Connected bool   `json:"connected"`
+		}{share, true, connectErr == nil && runtime.GOOS == "windows"}
+		return errors.Join(ctx.writeJSON(output), connectErr)
+	}
+	fmt.Fprintf(ctx.stdout, "Saved the credentials of %s\n", share)
+	return connectErr
+}
+
+func runRemoteLogin(ctx *cliContext, args []string) error {
+	flags := ctx.newFlags("remote-login")
+	forget := flags.Bool("forget", false, "remove the saved token")
+	values, err := parseCommandArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	w, err := loadCLIWatcher(ctx.options, values[0])
+	if err != nil {
+		return err
+	}
+
+	if *forget {
+		if err := w.ForgetRemote(); err != nil {
+			return err
+		}
+		if ctx.json {
+			return ctx.writeJSON(struct {
+				ID        string `json:"id"`
+				Forgotten bool   `json:"forgotten"`
+			}{values[0], true})
+		}
+		fmt.Fprintf(ctx.stdout, "Removed the token of the remote of %s\n", values[0])
+		return nil
+	}
+
+	// The address is printed too, the browser may not open on a machine without a
+	// desktop.
+	open := func(address string) error {
+		if !ctx.json {
+			fmt.Fprintf(ctx.stdout, "Sign in at %s\n", address)
+		}
+		if err := openInBrowser(address); err != nil && !ctx.json {
+			fmt.Fprintln(ctx.stdout, "Open the address in a browser to continue.")
+		}
+		return nil
+	}
+	if err := w.AuthorizeRemote(open); err != nil {
+		return err
+	}
+	if ctx.json {
+		return ctx.writeJSON(struct {
+			ID       string `json:"id"`
+			SignedIn bool   `json:"signed_in"`
+		}{values[0], true})
+	}
+	fmt.Fprintf(ctx.stdout, "Signed in to the remote of %s\n", values[0])
+	return nil
+}
+
+// readLine asks for a line of input, what is typed is hidden for secrets if stdin is a
+// terminal.
+func (ctx *cliContext) readLine(prompt string, secret bool) (string, error) {
+	if !ctx.json {
+		fmt.Fprint(ctx.stdout, prompt)
+	}
+	if secret && ctx.stdinFile != nil {
+		if restore, ok := disableEcho(ctx.stdinFile); ok {
+			defer func() {
+				restore()
+				// The newline typed by the user was not shown either.
+				fmt.Fprintln(ctx.stdout)
+			}()
+		}
+	}
+	line, err := ctx.stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("no input: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// SecretSummary is a setting that holds a secret in the output of the secrets command.
+type SecretSummary struct {
+	ID      string `json:"id"`
+	Setting string `json:"setting"`
+	// Name of the secret in the keychain, empty if the setting holds the secret itself.
+	Secret string `json:"secret,omitempty"`
+	// "saved", "missing" or "plaintext".
+	State string `json:"state"`
+}
+
+func runSecrets(ctx *cliContext, args []string) error {
+	args, err := collectCommandArgs(ctx.newFlags("secrets"), args)
+	if err != nil {
+		return err
+	}
+	config, err := readCLIConfig(ctx.options)
+	if err != nil {
+		return err
+	}
+	secrets := watcher.NewSecrets(ctx.options.osKeychain())
+
+	switch {
+	case len(args) == 0:
+	case args[0] == "set" && len(args) == 2:
+		// The secret is read from stdin in --json mode too so it does not end up in the
+		// arguments.
+		secret, err := ctx.readLine(fmt.Sprintf("Secret for %s: ", args[1]), true)
+		if err != nil {
+			return err
+		}
+		if err := secrets.Set(args[1], secret); err != nil {
+			return err
+		}
+		if !ctx.json {
+			fmt.Fprintf(ctx.stdout, "Saved %s, settings refer to it as %s\n\n", args[1], watcher.SecretReference(args[1]))
+		}
+	case args[0] == "delete" && len(args) == 2:
+		if err := secrets.Delete(args[1]); err != nil {
+			return err
+		}
+		if !ctx.json {
+			fmt.Fprintf(ctx.stdout, "Removed %s\n\n", args[1])
+		}
+	case args[0] == "migrate" && len(args) == 1:
+		moved := []string{}
+		for _, pair := range config.Watchers {
+			names, err := secrets.MigrateSecrets(pair)
+			moved = append(moved, names...)
+			if err != nil {
+				return err
+			}
+		}
+		if len(moved) > 0 {
+			data, err := marshalConfig(config, ctx.options.configFormat())
+			if err != nil {
+				return fmt.Errorf("error marshaling config: %w", err)
+			}
+			if err := os.WriteFile(ctx.options.ConfigPath, data, 0644); err != nil {
+				return fmt.Errorf("error writing config file: %w", err)
+			}
+		}
+		if !ctx.json {
+			for _, name := range moved {
+				fmt.Fprintf(ctx.stdout, "Moved %s into the keychain\n", name)
+			}
+			if len(moved) == 0 {
+				fmt.Fprintln(ctx.stdout, "There are no secrets left in the config file")
+			}
+			fmt.Fprintln(ctx.stdout)
+		}
+	default:
+		return errUsage
+	}
+
+	summaries := []SecretSummary{}
+	for _, pair := range config.Watchers {
+		for _, setting := range pair.SecretSettings() {
+			summary := SecretSummary{ID: pair.ID, Setting: setting.Setting, State: "plaintext"}
+			if name, ok := setting.Reference(); ok {
+				saved, err := secrets.Exists(name)
+				if err != nil {
+					return err
+				}
+				summary.Secret, summary.State = name, "missing"
+				if saved {
+					summary.State = "saved"
+				}
+			} else if *setting.Value == "" {
+				continue
+			}
+			summaries = append(summaries, summary)
+		}
+	}
+	if ctx.json {
+		return ctx.writeJSON(summaries)
+	}
+	if len(summaries) == 0 {
+		fmt.Fprintln(ctx.stdout, "No settings hold a secret")
+		return nil
+	}
+	for _, summary := range summaries {
+		switch summary.State {
+		case "plaintext":
+			fmt.Fprintf(ctx.stdout, "%s  %s  written in the config file, move it with: i-saw-that secrets migrate\n", summary.ID, summary.Setting)
+		case "missing":
+			fmt.Fprintf(ctx.stdout, "%s  %s  %s is missing, save it with: i-saw-that secrets set %s\n", summary.ID, summary.Setting, summary.Secret, summary.Secret)
+		default:
+			fmt.Fprintf(ctx.stdout, "%s  %s  %s\n", summary.ID, summary.Setting, summary.Secret)
+		}
+	}
+	return nil
+}
+
+func runKeys(ctx *cliContext, args []string) error {
+	args, err := collectCommandArgs(ctx.newFlags("keys"), args)
+	if err != nil {
+		return err
+	}
+	keyring, err := loadKeyring(ctx.options)
+	if err != nil {
+		return err
+	}
+	if keyring == nil {
+		return errNoKeyring
+	}
+
+	switch {
+	case len(args) == 0:
+	case args[0] == "generate" && len(args) == 1:
+		id, err := keyring.GenerateKey()
+		if err != nil {
+			return err
+		}
+		if !ctx.json {
+			fmt.Fprintf(ctx.stdout, "Generated signing key %s\n\n", id)
+		}
+	case args[0] == "trust" && len(args) >= 2:
+		// The public key has a space after its type, it may be passed unquoted.
+		id, err := keyring.TrustKey(strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+		if !ctx.json {
+			fmt.Fprintf(ctx.stdout, "Trusted key %s\n\n", id)
+		}
+	case args[0] == "untrust" && len(args) == 2:
+		if err := keyring.UntrustKey(args[1]); err != nil {
+			return err
+		}
+		if !ctx.json {
+			fmt.Fprintf(ctx.stdout, "Removed key %s\n\n", args[1])
+		}
+	default:
+		return errUsage
+	}
+
+	keys := keyring.TrustedKeys()
+	if ctx.json {
+		return ctx.writeJSON(keys)
+	}
+	if len(keys) == 0 {
+		fmt.Fprintln(ctx.stdout, "There is no signing key, create one with: i-saw-that keys generate")
+		return nil
+	}
+	for _, key := range keys {
+		label := "trusted"
+		if key.Own {
+			label = "signing"
+		}
+		fmt.Fprintf(ctx.stdout, "%s  %-7s  %s\n", key.ID, label, key.PublicKey)
+	}
+	return nil
+}
+
+func runRestore(ctx *cliContext, args []string) error {
+	flags := ctx.newFlags("restore")
+	at := flags.String("at", "", "restore the latest backup made at or before this time")
+	latest := flags.Bool("latest", false, "restore the latest backup")
+	yes := flags.Bool("yes", false, "do not ask for confirmation")
+	values, err := parseCommandArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *at != "" && *latest {
+		return fmt.Errorf("%w: --at and --latest cannot be used together", errUsage)
+	}
+	// There is no one to answer questions when the output is read by another program.
+	if ctx.json && ((*at == "" && !*latest) || !*yes) {
+		return fmt.Errorf("%w: --json requires --yes and either --at or --latest", errUsage)
+	}
+
+	w, err := loadCLIWatcher(ctx.options, values[0])
+	if err != nil {
+		return err
+	}
+	backups := w.ListBackups()
+	if len(backups) == 0 {
+		if !ctx.json {
+			fmt.Fprintln(ctx.stdout, "There are no backups to restore")
+		}
+		return errNothingToDo
+	}
+
+	var backup watcher.Backup
+	switch {
+	case *latest:
+		backup = backups[len(backups)-1]
+	case *at != "":
+		if backup, err = backupAt(backups, *at); err != nil {
+			return err
+		}
+	default:
+		if backup, err = pickBackup(ctx, w, backups); err != nil {
+			return err
+		}
+	}
+
+	if !*yes {
+		question := fmt.Sprintf("Replace %s with the backup from %s?", w.Source, watcher.BackupTime(backup).Format(time.DateTime))
+		if !w.SkipSafetyBackups {
+			question += " The source is backed up first."
+		}
+		if confirmed, err := confirm(ctx, question); err != nil || !confirmed {
+			if err == nil {
+				fmt.Fprintln(ctx.stdout, "Restore cancelled")
+			}
+			return err
+		}
+	}
+
+	safety, err := w.RestoreBackup(backup.Path)
+	if err != nil {
+		return err
+	}
+	if ctx.json {
+		return ctx.writeJSON(struct {
+			Restored watcher.Backup `json:"restored"`
+			Safety   watcher.Backup `json:"safety"`
+		}{backup, safety})
+	}
+	if safety.Path == "" {
+		fmt.Fprintf(ctx.stdout, "Restored the backup from %s\n", watcher.BackupTime(backup).Format(time.DateTime))
+		return nil
+	}
+	fmt.Fprintf(ctx.stdout, "Restored the backup from %s, the previous source was saved as %s\n", watcher.BackupTime(backup).Format(time.DateTime), w.BackupPath(safety))
+	return nil
+}
+
+// Time formats accepted by --at, times without a zone are local.
+var cliTimeLayouts = []string{time.RFC3339, time.DateTime, "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04", time.DateOnly}
+
+// backupAt returns the backup with the given ID, or the latest backup made at or before
+// the given time. A date on its own includes the whole day.
+func backupAt(backups []watcher.Backup, value string) (watcher.Backup, error) {
+	if backup, ok := watcher.FindBackupByID(backups, value); ok {
+		return backup, nil
+	}
+
+	for _, layout := range cliTimeLayouts {
+		at, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			continue
+		}
+		if layout == time.DateOnly {
+			at = at.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+
+		for i := len(backups) - 1; i >= 0; i-- {
+			if !watcher.BackupTime(backups[i]).After(at) {
+				return backups[i], nil
+			}
+		}
+		return watcher.Backup{}, fmt.Errorf("there are no backups from before %s", value)
+	}
+	return watcher.Backup{}, fmt.Errorf("%w: invalid time %s", errUsage, value)
+}
+
+// pickBackup lists the backups newest first and asks which one to use.
+func pickBackup(ctx *cliContext, w *watcher.Watcher, backups []watcher.Backup) (watcher.Backup, error) {
+	now := time.Now()
+	for i := len(backups) - 1; i >= 0; i-- {
+		fmt.Fprintf(ctx.stdout, "%3d) %s\n", len(backups)-i, formatBackupListing(newBackupListing(w, backups[i]), now))
+	}
+
+	fmt.Fprint(ctx.stdout, "Backup to restore: ")
+	line, err := ctx.stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return watcher.Backup{}, fmt.Errorf("no backup selected")
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(backups) {
+		return watcher.Backup{}, fmt.Errorf("invalid choice: %s", strings.TrimSpace(line))
+	}
+	return backups[len(backups)-choice], nil
+}
+
+// confirm asks a yes or no question, anything other than yes is treated as no.
+func confirm(ctx *cliContext, question string) (bool, error) {
+	fmt.Fprintf(ctx.stdout, "%s [y/N] ", question)
+	line, err := ctx.stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return false, nil
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
+
+// formatAge formats a duration as a rough age such as "3 hours ago".
+func formatAge(age time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return plural(int(age.Minutes()), "minute")
+	case age < 24*time.Hour:
+		return plural(int(age.Hours()), "hour")
+	}
+	return plural(int(age.Hours()/24), "day")
+}
+
+// SelfUpdateResult is the output of the self-update command.
+type SelfUpdateResult struct {
+	UpdateInfo
+	Updated bool `json:"updated"`
+}
+
+func runSelfUpdate(ctx *cliContext, args []string) error {
+	flags := ctx.newFlags("self-update")
+	check := flags.Bool("check", false, "only check if a newer release is available")
+	if _, err := parseCommandArgs(flags, args, 0); err != nil {
+		return err
+	}
+	url, executable, err := ctx.options.updateSource()
+	if err != nil {
+		return err
+	}
+	info, err := checkForUpdates(updateClient, url, version)
+	if err != nil {
+		return err
+	}
+
+	result := SelfUpdateResult{UpdateInfo: info}
+	if info.Available && !*check {
+		if !ctx.json {
+			fmt.Fprintf(ctx.stdout, "Updating from %s to %s\n", info.Current, info.Latest)
+		}
+		if err := selfUpdate(updateClient, info, executable); err != nil {
+			return err
+		}
+		result.Updated = true
+	}
+
+	if ctx.json {
+		if err := ctx.writeJSON(result); err != nil {
+			return err
+		}
+	} else {
+		switch {
+		case result.Updated:
+			fmt.Fprintf(ctx.stdout, "Updated to %s, restart I Saw That to use it\n", info.Latest)
+		case info.Available:
+			fmt.Fprintf(ctx.stdout, "Version %s is available, the running version is %s\n%s\n", info.Latest, info.Current, info.URL)
+		default:
+			fmt.Fprintf(ctx.stdout, "Version %s is up to date\n", info.Current)
+		}
+	}
+
+	if !info.Available {
+		return errNothingToDo
+	}
+	if *check {
+		return errUpdateAvailable
+	}
+	return nil
+}
+
+func runDaemon(ctx *cliContext, args []string) error {
+	if _, err := parseCommandArgs(ctx.newFlags("daemon"), args, 0); err != nil {
+		return err
+	}
+	if ctx.options.DataDir == "" {
+		return fmt.Errorf("the daemon needs a data directory")
+	}
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return serveDaemon(signalCtx, ctx.options, func(info InstanceInfo) {
+		// The token is left out since the output may end up in a log.
+		if ctx.json {
+			ctx.writeJSON(struct {
+				PID     int    `json:"pid"`
+				Address string `json:"address"`
+			}{info.PID, info.Address})
+		} else {
+			fmt.Fprintf(ctx.stdout, "Daemon with process ID %d is running, stop it with Ctrl+C\n", info.PID)
+		}
+	})
+}
+
+func runStress(ctx *cliContext, args []string) error {
+	flags := ctx.newFlags("stress")
+	id := flags.String("watcher", "", "use the settings of this folder pair")
+	duration := flags.Duration("duration", time.Minute, "how long to keep changing the source")
+	seed := flags.Int64("seed", 0, "seed of the random changes, 0 picks one")
+	dir := flags.String("dir", "", "folder to run in instead of the temporary folder")
+	if _, err := parseCommandArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	config, err := readCLIConfig(ctx.options)
+	if err != nil {
+		return err
+	}
+	settings := config.Defaults.resolve(&watcher.WatcherConfig{})
+	if *id != "" {
+		settings = nil
+		for _, pair := range config.Watchers {
+			if pair.ID == *id {
+				settings = config.Defaults.resolve(pair)
+			}
+		}
+		if settings == nil {
+			return fmt.Errorf("folder pair not found: %s", *id)
+		}
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	stressConfig := watcher.StressConfig{Watcher: *settings, Dir: *dir, Duration: *duration, Seed: *seed}
+	if !ctx.json {
+		fmt.Fprintf(ctx.stdout, "Stress testing for %s with seed %d, stop it with Ctrl+C\n", *duration, *seed)
+		stressConfig.Progress = func(result watcher.StressResult) {
+			fmt.Fprintf(ctx.stdout, "Round %d: %d changes, %d backups, %d violations\n", result.Rounds, result.Changes, result.Backups, len(result.Violations))
+		}
+	}
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	result, err := watcher.RunStress(signalCtx, stressConfig)
+	if err != nil {
+		return err
+	}
+
+	if ctx.json {
+		if err := ctx.writeJSON(result); err != nil {
+			return err
+		}
+	} else {
+		for _, violation := range result.Violations {
+			message := violation.Message
+			if violation.Round > 0 {
+				message = fmt.Sprintf("Round %d: %s", violation.Round, message)
+			}
+			fmt.Fprintf(ctx.stdout, "%-8s %s\n", violation.Invariant, message)
+		}
+		fmt.Fprintf(ctx.stdout, "Made %d changes in %d rounds over %s, %d backups were made\n", result.Changes, result.Rounds, time.Duration(result.Duration).Round(time.Second), result.Backups)
+	}
+	if !result.Passed() {
+		return fmt.Errorf("%w: %d invariants were broken, rerun with --seed %d to make the same changes", errStressFailed, len(result.Violations), result.Seed)
+	}
+	return nil
+}
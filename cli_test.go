@@ -0,0 +1,533 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+	"ryn-cx/i-saw-that/pkg/watcher/watchertest"
+)
+
+// writeCLIConfig writes a config file for a single folder pair and returns the options
+// to use it.
+func writeCLIConfig(t *testing.T, tempConfig watchertest.TempWatcherConfig, pair watcher.WatcherConfig) *Options {
+	pair.ID = tempConfig.Name
+	pair.Source = tempConfig.Source
+	pair.Destination = tempConfig.Destination
+	pair.FolderFormat = tempConfig.FolderFormat
+	pair.WaitTime = watcher.Duration(tempConfig.WaitTime)
+
+	config := &Config{Defaults: builtinDefaults(), Watchers: []*watcher.WatcherConfig{&pair}}
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	path := filepath.Join(tempConfig.TempPath, "config.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return &Options{ConfigPath: path}
+}
+
+func TestCLIPrune(t *testing.T) {
+	t.Parallel()
+	tempConfig := watchertest.DefaultTempWatcherConfig(t)
+	w, err := watchertest.NewTempWatcher(tempConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	for i := range 3 {
+		watchertest.CreateDummyFile(t, tempConfig.Source, filepath.Join("folder", string(rune('a'+i))), 1024)
+		w.CreateBackup()
+	}
+	options := writeCLIConfig(t, tempConfig, watcher.WatcherConfig{Retention: watcher.RetentionPolicy{KeepLast: 1}})
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI(options, []string{"prune", tempConfig.Name, "--dry-run"}, strings.NewReader(""), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if strings.Count(stdout.String(), "Would delete") != 2 || !strings.Contains(stdout.String(), "from 2 backups") {
+		t.Errorf("Unexpected dry run output:\n%s", stdout.String())
+	}
+	if _, err := os.Stat(w.BackupPath(w.Metadata[0])); err != nil {
+		t.Errorf("Expected a dry run to not delete anything")
+	}
+
+	stdout.Reset()
+	if code := runCLI(options, []string{"prune", tempConfig.Name}, strings.NewReader(""), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "Deleted") || !strings.Contains(stdout.String(), "Reclaimed") {
+		t.Errorf("Unexpected output:\n%s", stdout.String())
+	}
+	if _, err := os.Stat(w.BackupPath(w.Metadata[0])); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest backup to be deleted")
+	}
+}
+
+func TestCLIUsageErrors(t *testing.T) {
+	t.Parallel()
+	tempConfig := watchertest.DefaultTempWatcherConfig(t)
+	options := writeCLIConfig(t, tempConfig, watcher.WatcherConfig{})
+
+	tests := map[string][]string{
+		"unknown command":    {"missing"},
+		"missing argument":   {"prune"},
+		"unknown flag":       {"prune", tempConfig.Name, "--missing"},
+		"extra argument":     {"stress", tempConfig.Name},
+		"not a share":        {"share", tempConfig.Destination},
+		"share without user": {"share", `\\nas\backups`, "--json"},
+	}
+	for name, args := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := runCLI(options, args, strings.NewReader(""), &stdout, &stderr); code != exitUsage {
+			t.Errorf("%s: Expected exit code %d, got %d", name, exitUsage, code)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI(options, []string{"prune", "missing"}, strings.NewReader(""), &stdout, &stderr); code != exitError {
+		t.Errorf("Expected exit code %d for a missing folder pair, got %d", exitError, code)
+	}
+	// The folder pair has no remote to sign in to.
+	if code := runCLI(options, []string{"remote-login", tempConfig.Name}, strings.NewReader(""), &stdout, &stderr); code != exitError {
+		t.Errorf("Expected exit code %d for a folder pair without a remote, got %d", exitError, code)
+	}
+}
+
+func TestCLIRestore(t *testing.T) {
+	t.Parallel()
+	tempConfig := watchertest.DefaultTempWatcherConfig(t)
+	w, err := watchertest.NewTempWatcher(tempConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watchertest.CreateDummyFile(t, tempConfig.Source, "file1.txt", 1024)
+	w.CreateBackup()
+	watchertest.CreateDummyFile(t, tempConfig.Source, "file2.txt", 1024)
+	w.CreateBackup()
+	options := writeCLIConfig(t, tempConfig, watcher.WatcherConfig{})
+
+	// Declining the confirmation leaves the source alone.
+	var stdout, stderr bytes.Buffer
+	code := runCLI(options, []string{"restore", tempConfig.Name}, strings.NewReader("2\nn\n"), &stdout, &stderr)
+	if code != exitOK || !strings.Contains(stdout.String(), "Restore cancelled") {
+		t.Fatalf("Expected the restore to be cancelled, got %d:\n%s%s", code, stdout.String(), stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(tempConfig.Source, "file2.txt")); err != nil {
+		t.Fatalf("Expected the source to be unchanged")
+	}
+
+	// Picking the second newest backup restores the source without file2.txt.
+	stdout.Reset()
+	code = runCLI(options, []string{"restore", tempConfig.Name}, strings.NewReader("2\ny\n"), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(tempConfig.Source, "file2.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected file2.txt to be removed by the restore")
+	}
+
+	stdout.Reset()
+	code = runCLI(options, []string{"restore", tempConfig.Name, "--latest", "--yes"}, strings.NewReader(""), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	// The latest backup is the safety backup made by the previous restore.
+	if _, err := os.Stat(filepath.Join(tempConfig.Source, "file2.txt")); err != nil {
+		t.Errorf("Expected file2.txt to be restored from the safety backup")
+	}
+}
+
+func TestCLIJSONOutput(t *testing.T) {
+	t.Parallel()
+	tempConfig := watchertest.DefaultTempWatcherConfig(t)
+	w, err := watchertest.NewTempWatcher(tempConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watchertest.CreateDummyFile(t, tempConfig.Source, "file1.txt", 1024)
+	w.CreateBackup()
+	watchertest.CreateDummyFile(t, tempConfig.Source, "file2.txt", 1024)
+	w.CreateBackup()
+	options := writeCLIConfig(t, tempConfig, watcher.WatcherConfig{Enabled: true})
+
+	run := func(expectedCode int, value any, args ...string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		if code := runCLI(options, args, strings.NewReader(""), &stdout, &stderr); code != expectedCode {
+			t.Fatalf("%v: Expected exit code %d, got %d: %s", args, expectedCode, code, stderr.String())
+		}
+		if err := json.Unmarshal(stdout.Bytes(), value); err != nil {
+			t.Fatalf("%v: Output is not valid JSON: %v\n%s", args, err, stdout.String())
+		}
+	}
+
+	var status []WatcherSummary
+	run(exitOK, &status, "status", "--json")
+	if len(status) != 1 || status[0].Backups != 2 || !status[0].UpToDate || !status[0].Enabled || status[0].Size < 3072 || status[0].Status != nil {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	var table bytes.Buffer
+	runCLI(options, []string{"status"}, strings.NewReader(""), &table, io.Discard)
+	if lines := strings.Split(table.String(), "\n"); len(lines) < 2 || !strings.HasPrefix(lines[1], tempConfig.Name+"  up to date  2 ") {
+		t.Errorf("Unexpected status table:\n%s", table.String())
+	}
+
+	var listings []BackupListing
+	run(exitOK, &listings, "list", tempConfig.Name, "--json")
+	if len(listings) != 2 || listings[1].Path != w.Metadata[1].Path || listings[1].Trigger != watcher.BackupTriggerManual || listings[1].Size < 2048 {
+		t.Errorf("Unexpected listing: %+v", listings)
+	}
+
+	var annotated BackupListing
+	run(exitOK, &annotated, "annotate", tempConfig.Name, "#1", "Before installing mod X", "--json")
+	if annotated.Path != w.Metadata[0].Path || annotated.Note != "Before installing mod X" {
+		t.Errorf("Unexpected annotated backup: %+v", annotated)
+	}
+	var events []watcher.FileEvent
+	run(exitOK, &events, "events", tempConfig.Name, "#1", "--json")
+	if len(events) != 0 {
+		t.Errorf("Expected no events without audit_log, got %+v", events)
+	}
+	var list bytes.Buffer
+	runCLI(options, []string{"list", tempConfig.Name}, strings.NewReader(""), &list, io.Discard)
+	if !strings.Contains(list.String(), `"Before installing mod X"`) {
+		t.Errorf("Expected the note in the list:\n%s", list.String())
+	}
+
+	var resumed struct {
+		Resumed bool `json:"resumed"`
+	}
+	run(exitNothingToDo, &resumed, "resume", tempConfig.Name, "--json")
+	if resumed.Resumed {
+		t.Errorf("Expected nothing to resume without a mass change")
+	}
+
+	var prune watcher.PruneResult
+	run(exitNothingToDo, &prune, "prune", "--json", tempConfig.Name, "--dry-run")
+	if !prune.DryRun || len(prune.Pruned) != 0 {
+		t.Errorf("Unexpected prune result: %+v", prune)
+	}
+
+	var verified []watcher.BackupVerification
+	run(exitOK, &verified, "verify", tempConfig.Name, "--json")
+	if len(verified) != 2 || verified[0].Status != watcher.VerifyStatusOK || verified[1].Status != watcher.VerifyStatusOK {
+		t.Errorf("Unexpected verification: %+v", verified)
+	}
+
+	// A damaged backup is reported in the output and fails the command.
+	watchertest.CreateDummyFile(t, w.BackupPath(w.Metadata[0]), "file1.txt", 10)
+	run(exitVerifyFailed, &verified, "verify", tempConfig.Name, "--json", "--backup", w.Metadata[0].Path)
+	if len(verified) != 1 || verified[0].Status != watcher.VerifyStatusModified {
+		t.Errorf("Unexpected verification: %+v", verified)
+	}
+	run(exitVerifyFailed, &verified, "verify", tempConfig.Name, "--json", "--drill", "--backup", w.Metadata[0].Path)
+	if len(verified) != 1 || verified[0].Status != watcher.VerifyStatusModified {
+		t.Errorf("Unexpected restore drill: %+v", verified)
+	}
+	run(exitOK, &verified, "verify", tempConfig.Name, "--json", "--drill", "--backup", w.Metadata[1].Path)
+	if len(verified) != 1 || verified[0].Status != watcher.VerifyStatusOK {
+		t.Errorf("Unexpected restore drill: %+v", verified)
+	}
+
+	// Errors are written as JSON too.
+	var stdout, stderr bytes.Buffer
+	if code := runCLI(options, []string{"restore", tempConfig.Name, "--json"}, strings.NewReader(""), &stdout, &stderr); code != exitUsage {
+		t.Fatalf("Expected a restore that needs input to be a usage error, got %d", code)
+	}
+	var output struct {
+		Error string `json:"error"`
+		Usage string `json:"usage"`
+	}
+	if err := json.Unmarshal(stderr.Bytes(), &output); err != nil || output.Error == "" || output.Usage == "" {
+		t.Errorf("Expected a JSON error, got: %s", stderr.String())
+	}
+}
+
+func TestCLIExitCodes(t *testing.T) {
+	t.Parallel()
+	tempConfig := watchertest.DefaultTempWatcherConfig(t)
+	options := writeCLIConfig(t, tempConfig, watcher.WatcherConfig{Retention: watcher.RetentionPolicy{KeepLast: 1}})
+
+	tests := map[string]struct {
+		args []string
+		code int
+	}{
+		"no backups to restore": {[]string{"restore", tempConfig.Name, "--latest", "--yes"}, exitNothingToDo},
+		"no backups to verify":  {[]string{"verify", tempConfig.Name}, exitNothingToDo},
+		"no backups to drill":   {[]string{"verify", tempConfig.Name, "--drill"}, exitNothingToDo},
+		"no backups to prune":   {[]string{"prune", tempConfig.Name}, exitNothingToDo},
+	}
+	for name, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := runCLI(options, test.args, strings.NewReader(""), &stdout, &stderr); code != test.code {
+			t.Errorf("%s: Expected exit code %d, got %d: %s", name, test.code, code, stderr.String())
+		}
+		if stderr.Len() != 0 {
+			t.Errorf("%s: Expected nothing to do to not be reported as an error, got: %s", name, stderr.String())
+		}
+	}
+
+	// Writing the config again replaces the file used by options.
+	writeCLIConfig(t, tempConfig, watcher.WatcherConfig{Snapshot: "lvm"})
+	var stdout, stderr bytes.Buffer
+	if code := runCLI(options, []string{"list", tempConfig.Name}, strings.NewReader(""), &stdout, &stderr); code != exitValidation {
+		t.Errorf("Expected exit code %d for an invalid folder pair, got %d: %s", exitValidation, code, stderr.String())
+	}
+
+	if err := os.WriteFile(options.ConfigPath, []byte("{"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	for _, args := range [][]string{{"status"}, {"list", tempConfig.Name}} {
+		if code := runCLI(options, args, strings.NewReader(""), &stdout, &stderr); code != exitConfig {
+			t.Errorf("%v: Expected exit code %d for an unreadable config, got %d", args, exitConfig, code)
+		}
+	}
+}
+
+func TestCLIStress(t *testing.T) {
+	t.Parallel()
+	tempConfig := watchertest.DefaultTempWatcherConfig(t)
+	tempConfig.WaitTime = 200 * time.Millisecond
+	options := writeCLIConfig(t, tempConfig, watcher.WatcherConfig{})
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"stress", "--watcher", tempConfig.Name, "--duration", "1s", "--seed", "3", "--dir", t.TempDir(), "--json"}
+	code := runCLI(options, args, strings.NewReader(""), &stdout, &stderr)
+	if strings.Contains(stderr.String(), watcher.ErrNoFileEvents.Error()) {
+		t.Skip(stderr.String())
+	}
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	var result watcher.StressResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	if result.Seed != 3 || result.Rounds == 0 || !result.Passed() {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestCLICompare(t *testing.T) {
+	t.Parallel()
+	tempConfig := watchertest.DefaultTempWatcherConfig(t)
+	w, err := watchertest.NewTempWatcher(tempConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watchertest.CreateDummyFile(t, tempConfig.Source, "file1.txt", 10)
+	w.CreateBackup()
+	options := writeCLIConfig(t, tempConfig, watcher.WatcherConfig{})
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI(options, []string{"compare", tempConfig.Name, "#1", tempConfig.Source}, strings.NewReader(""), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "matches the backup") {
+		t.Errorf("Unexpected output:\n%s", stdout.String())
+	}
+
+	watchertest.CreateDummyFile(t, tempConfig.Source, "file2.txt", 10)
+	stdout.Reset()
+	if code := runCLI(options, []string{"compare", tempConfig.Name, "1", tempConfig.Source, "--json"}, strings.NewReader(""), &stdout, &stderr); code != exitDifferent {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitDifferent, code, stderr.String())
+	}
+	var comparison watcher.BackupComparison
+	if err := json.Unmarshal(stdout.Bytes(), &comparison); err != nil {
+		t.Fatalf("Failed to parse output: %v\n%s", err, stdout.String())
+	}
+	if len(comparison.Differences) != 1 || comparison.Differences[0] != (watcher.ManifestDifference{Path: "file2.txt", Kind: watcher.DifferenceAdded}) {
+		t.Errorf("Expected file2.txt to be added, got %+v", comparison.Differences)
+	}
+}
+
+func TestBackupAt(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	backups := []watcher.Backup{}
+	for _, at := range []time.Time{start, start.Add(time.Hour), start.Add(24 * time.Hour)} {
+		backups = append(backups, watcher.Backup{Timestamp: float64(at.UnixNano()) / 1e9, Path: at.Format(watcher.DefaultFolderFormat)})
+	}
+
+	tests := map[string]int{
+		backups[1].Path:       1,
+		"2024-05-01 12:30":    0,
+		"2024-05-01T13:00:00": 1,
+		"2024-05-01":          1,
+		"2024-05-03":          2,
+	}
+	for value, expected := range tests {
+		backup, err := backupAt(backups, value)
+		if err != nil {
+			t.Errorf("%s: %v", value, err)
+			continue
+		}
+		if backup.Path != backups[expected].Path {
+			t.Errorf("%s: Expected backup %d, got %s", value, expected, backup.Path)
+		}
+	}
+
+	if _, err := backupAt(backups, "2024-04-30"); err == nil {
+		t.Errorf("Expected an error for a time before every backup")
+	}
+}
+
+func TestCLIPruneMakesSafetyBackup(t *testing.T) {
+	t.Parallel()
+	tempConfig := watchertest.DefaultTempWatcherConfig(t)
+	w, err := watchertest.NewTempWatcher(tempConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	for _, name := range []string{"file1.txt", "file2.txt"} {
+		watchertest.CreateDummyFile(t, tempConfig.Source, name, 10)
+		w.CreateBackup()
+	}
+	watchertest.CreateDummyFile(t, tempConfig.Source, "file3.txt", 10)
+	options := writeCLIConfig(t, tempConfig, watcher.WatcherConfig{Retention: watcher.RetentionPolicy{KeepLast: 1}})
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI(options, []string{"prune", tempConfig.Name, "--json"}, strings.NewReader(""), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	var result watcher.PruneResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil || len(result.Pruned) != 2 {
+		t.Errorf("Expected both earlier backups to be pruned, got %+v, %v", result, err)
+	}
+
+	// Only the safety backup with the unsaved change is left.
+	stdout.Reset()
+	var listings []BackupListing
+	runCLI(options, []string{"list", tempConfig.Name, "--json"}, strings.NewReader(""), &stdout, &stderr)
+	if err := json.Unmarshal(stdout.Bytes(), &listings); err != nil || len(listings) != 1 || listings[0].Note != "Before pruning" || listings[0].Trigger != watcher.BackupTriggerSafety {
+		t.Errorf("Expected the safety backup to be kept, got %+v, %v", listings, err)
+	}
+}
+
+func TestCLIKeys(t *testing.T) {
+	t.Parallel()
+	options := &Options{DataDir: t.TempDir()}
+	run := func(args ...string) []watcher.TrustedKey {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		if code := runCLI(options, append(args, "--json"), strings.NewReader(""), &stdout, &stderr); code != exitOK {
+			t.Fatalf("%v: Expected exit code %d, got %d: %s", args, exitOK, code, stderr.String())
+		}
+		var keys []watcher.TrustedKey
+		if err := json.Unmarshal(stdout.Bytes(), &keys); err != nil {
+			t.Fatalf("%v: Output is not valid JSON: %v\n%s", args, err, stdout.String())
+		}
+		return keys
+	}
+
+	if keys := run("keys"); len(keys) != 0 {
+		t.Errorf("Expected no keys, got %+v", keys)
+	}
+	keys := run("keys", "generate")
+	if len(keys) != 1 || !keys[0].Own {
+		t.Fatalf("Expected a signing key, got %+v", keys)
+	}
+	// The public key is accepted without quotes around it.
+	other, err := watcher.LoadKeyring(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to load keyring: %v", err)
+	}
+	other.GenerateKey()
+	publicKey, _ := other.PublicKey()
+	keys = run(append([]string{"keys", "trust"}, strings.Fields(publicKey)...)...)
+	if len(keys) != 2 || keys[1].PublicKey != publicKey {
+		t.Fatalf("Expected the key to be trusted, got %+v", keys)
+	}
+	if keys = run("keys", "untrust", keys[1].ID); len(keys) != 1 {
+		t.Errorf("Expected the key to be removed, got %+v", keys)
+	}
+
+	if code := runCLI(options, []string{"keys", "remove"}, strings.NewReader(""), io.Discard, io.Discard); code != exitUsage {
+		t.Errorf("Expected an unknown action to be a usage error, got %d", code)
+	}
+}
+
+// memoryKeychain keeps secrets in memory instead of the keychain of the OS.
+type memoryKeychain map[string]string
+
+func (k memoryKeychain) Get(name string) (string, error) {
+	secret, ok := k[name]
+	if !ok {
+		return "", watcher.ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+func (k memoryKeychain) Set(name, secret string) error {
+	k[name] = secret
+	return nil
+}
+
+func (k memoryKeychain) Delete(name string) error {
+	if _, ok := k[name]; !ok {
+		return watcher.ErrSecretNotFound
+	}
+	delete(k, name)
+	return nil
+}
+
+func TestCLISecrets(t *testing.T) {
+	t.Parallel()
+	tempConfig := watchertest.DefaultTempWatcherConfig(t)
+	options := writeCLIConfig(t, tempConfig, watcher.WatcherConfig{
+		Remote: watcher.RemoteConfig{Type: watcher.RemoteWebDAV, URL: "https://nas/dav", Password: "hunter2"},
+	})
+	options.keychain = memoryKeychain{}
+	run := func(stdin string, args ...string) []SecretSummary {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		if code := runCLI(options, append(args, "--json"), strings.NewReader(stdin), &stdout, &stderr); code != exitOK {
+			t.Fatalf("%v: Expected exit code %d, got %d: %s", args, exitOK, code, stderr.String())
+		}
+		var summaries []SecretSummary
+		if err := json.Unmarshal(stdout.Bytes(), &summaries); err != nil {
+			t.Fatalf("%v: Output is not valid JSON: %v\n%s", args, err, stdout.String())
+		}
+		return summaries
+	}
+
+	summaries := run("", "secrets")
+	if len(summaries) != 1 || summaries[0].Setting != "remote.password" || summaries[0].State != "plaintext" {
+		t.Fatalf("Expected the password to be in the config file, got %+v", summaries)
+	}
+
+	name := tempConfig.Name + "/remote.password"
+	summaries = run("", "secrets", "migrate")
+	if len(summaries) != 1 || summaries[0].Secret != name || summaries[0].State != "saved" {
+		t.Fatalf("Expected the password to be moved into the keychain, got %+v", summaries)
+	}
+	config, err := readConfig(options.ConfigPath, ConfigFormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if password := config.Watchers[0].Remote.Password; password != watcher.SecretReference(name) {
+		t.Errorf("Expected the config file to refer to the keychain, got %q", password)
+	}
+
+	if summaries = run("", "secrets", "delete", name); summaries[0].State != "missing" {
+		t.Errorf("Expected the secret to be missing, got %+v", summaries)
+	}
+	if summaries = run("changed\n", "secrets", "set", name); summaries[0].State != "saved" {
+		t.Errorf("Expected the secret to be saved again, got %+v", summaries)
+	}
+	if secret, err := watcher.NewSecrets(options.keychain).Resolve(watcher.SecretReference(name)); err != nil || secret != "changed" {
+		t.Errorf("Expected the secret to be read from stdin, got %q: %v", secret, err)
+	}
+
+	if code := runCLI(options, []string{"secrets", "set"}, strings.NewReader(""), io.Discard, io.Discard); code != exitUsage {
+		t.Errorf("Expected a missing name to be a usage error, got %d", code)
+	}
+}
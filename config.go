@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"ryn-cx/i-saw-that/pkg/watcher"
+)
+
+const defaultWaitTime = watcher.Duration(time.Second)
+
+type ConfigFormat string
+
+const (
+	ConfigFormatJSON ConfigFormat = "json"
+	ConfigFormatYAML ConfigFormat = "yaml"
+	ConfigFormatTOML ConfigFormat = "toml"
+)
+
+// Config file names that are searched for in the config directory, in order of
+// preference.
+var configFileNames = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// Config is the layout of the config file.
+type Config struct {
+	Defaults Defaults                 `json:"defaults" yaml:"defaults" toml:"defaults"`
+	Watchers []*watcher.WatcherConfig `json:"watchers" yaml:"watchers" toml:"watchers"`
+	// Backups copied at the same time across every folder pair, 0 uses the default.
+	MaxConcurrentBackups int `json:"max_concurrent_backups,omitempty" yaml:"max_concurrent_backups,omitempty" toml:"max_concurrent_backups,omitempty"`
+}
+
+// Defaults are the values inherited by every folder pair that does not set its own.
+type Defaults struct {
+	WaitTime     watcher.Duration `json:"wait_time" yaml:"wait_time" toml:"wait_time"`
+	FolderFormat string           `json:"folder_format" yaml:"folder_format" toml:"folder_format"`
+	CopyEngine   string           `json:"copy_engine,omitempty" yaml:"copy_engine,omitempty" toml:"copy_engine,omitempty"`
+	// Used by folder pairs that do not set any retention rules.
+	Retention watcher.RetentionPolicy `json:"retention,omitzero" yaml:"retention,omitempty" toml:"retention,omitempty"`
+	// Used by folder pairs that do not set a debounce strategy.
+	Debounce watcher.DebounceConfig `json:"debounce,omitzero" yaml:"debounce,omitempty" toml:"debounce,omitempty"`
+}
+
+// parseConfigFormat converts a format name such as "yml" into a ConfigFormat.
+func parseConfigFormat(format string) (ConfigFormat, error) {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "json":
+		return ConfigFormatJSON, nil
+	case "yaml", "yml":
+		return ConfigFormatYAML, nil
+	case "toml":
+		return ConfigFormatTOML, nil
+	}
+	return "", fmt.Errorf("unsupported config format: %s", format)
+}
+
+// configFormatFromPath detects the format of a config file from its extension. Files
+// without a recognized extension are treated as JSON.
+func configFormatFromPath(path string) ConfigFormat {
+	format, err := parseConfigFormat(filepath.Ext(path))
+	if err != nil {
+		return ConfigFormatJSON
+	}
+	return format
+}
+
+// findConfigFile returns the first config file that exists in dir. If there are no
+// config files the JSON file name is returned so new configs are written as JSON.
+func findConfigFile(dir string) string {
+	for _, name := range configFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, configFileNames[0])
+}
+
+func builtinDefaults() Defaults {
+	return Defaults{
+		WaitTime:     defaultWaitTime,
+		FolderFormat: watcher.DefaultFolderFormat,
+	}
+}
+
+// Fill in any missing default values with the built in values so a partially written
+// defaults section still produces usable watchers.
+func (d *Defaults) applyBuiltins() {
+	builtins := builtinDefaults()
+	if d.WaitTime <= 0 {
+		d.WaitTime = builtins.WaitTime
+	}
+	if d.FolderFormat == "" {
+		d.FolderFormat = builtins.FolderFormat
+	}
+}
+
+// resolve returns a copy of the pair with any unset values inherited from the
+// defaults. The original pair is left untouched so only real overrides are saved.
+func (d Defaults) resolve(pair *watcher.WatcherConfig) *watcher.WatcherConfig {
+	resolved := *pair
+	if resolved.WaitTime <= 0 {
+		resolved.WaitTime = d.WaitTime
+	}
+	if resolved.FolderFormat == "" {
+		resolved.FolderFormat = d.FolderFormat
+	}
+	if resolved.CopyEngine == "" {
+		resolved.CopyEngine = d.CopyEngine
+	}
+	if !resolved.Retention.Enabled() {
+		resolved.Retention = d.Retention
+	}
+	if resolved.Debounce.Strategy == "" {
+		resolved.Debounce = d.Debounce
+	}
+	return &resolved
+}
+
+// readConfig reads and parses a config file. A config file that does not exist is the
+// same as an empty config.
+func readConfig(path string, format ConfigFormat) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{Defaults: builtinDefaults()}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	config, err := parseConfig(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config: %w", err)
+	}
+	return config, nil
+}
+
+// parseConfig parses the contents of a config file. Older versions of the JSON config
+// file were a bare list of folder pairs, these are still accepted and use the built in
+// defaults.
+func parseConfig(data []byte, format ConfigFormat) (*Config, error) {
+	config := &Config{Defaults: builtinDefaults()}
+
+	var err error
+	switch format {
+	case ConfigFormatYAML:
+		err = yaml.Unmarshal(data, config)
+	case ConfigFormatTOML:
+		err = toml.Unmarshal(data, config)
+	default:
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+			if err := json.Unmarshal(trimmed, &config.Watchers); err != nil {
+				return nil, fmt.Errorf("error parsing legacy config: %w", err)
+			}
+			return config, nil
+		}
+		err = json.Unmarshal(data, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config.Defaults.applyBuiltins()
+	return config, nil
+}
+
+// marshalConfig serializes the config in the given format.
+func marshalConfig(config *Config, format ConfigFormat) ([]byte, error) {
+	switch format {
+	case ConfigFormatYAML:
+		return yaml.Marshal(config)
+	case ConfigFormatTOML:
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(config); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
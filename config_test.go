@@ -0,0 +1,167 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+)
+
+func TestParseLegacyConfig(t *testing.T) {
+	t.Parallel()
+	data := []byte(`[{"id": "watcher-0", "source": "a", "destination": "b", "enabled": true}]`)
+
+	config, err := parseConfig(data, ConfigFormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if len(config.Watchers) != 1 {
+		t.Fatalf("Expected 1 watcher, got %d", len(config.Watchers))
+	}
+	if config.Defaults != builtinDefaults() {
+		t.Errorf("Expected built in defaults, got %+v", config.Defaults)
+	}
+}
+
+func TestPairsInheritDefaults(t *testing.T) {
+	t.Parallel()
+	data := []byte(`{
+		"defaults": {"wait_time": 5},
+		"watchers": [
+			{"id": "watcher-0", "source": "a", "destination": "b"},
+			{"id": "watcher-1", "source": "c", "destination": "d", "wait_time": "2s", "folder_format": "2006"}
+		]
+	}`)
+
+	config, err := parseConfig(data, ConfigFormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	inherited := config.Defaults.resolve(config.Watchers[0])
+	// Plain numbers from older configs are read as seconds.
+	if inherited.WaitTime != watcher.Duration(5*time.Second) {
+		t.Errorf("Expected wait time 5s, got %s", inherited.WaitTime)
+	}
+	if inherited.FolderFormat != watcher.DefaultFolderFormat {
+		t.Errorf("Expected folder format '%s', got '%s'", watcher.DefaultFolderFormat, inherited.FolderFormat)
+	}
+	if config.Watchers[0].WaitTime != 0 {
+		t.Errorf("Resolving defaults should not modify the pair")
+	}
+
+	overridden := config.Defaults.resolve(config.Watchers[1])
+	if overridden.WaitTime != watcher.Duration(2*time.Second) || overridden.FolderFormat != "2006" {
+		t.Errorf("Expected overrides to be kept, got %+v", overridden)
+	}
+}
+
+func TestParseYAMLAndTOMLConfig(t *testing.T) {
+	t.Parallel()
+	configs := map[ConfigFormat]string{
+		ConfigFormatYAML: `
+# Comments are allowed
+max_concurrent_backups: 3
+defaults:
+  wait_time: 3s
+watchers:
+  - id: watcher-0
+    source: a
+    destination: b
+    enabled: true
+`,
+		ConfigFormatTOML: `
+# Comments are allowed
+max_concurrent_backups = 3
+
+[defaults]
+wait_time = 3
+
+[[watchers]]
+id = "watcher-0"
+source = "a"
+destination = "b"
+enabled = true
+`,
+	}
+
+	for format, data := range configs {
+		config, err := parseConfig([]byte(data), format)
+		if err != nil {
+			t.Fatalf("Failed to parse %s config: %v", format, err)
+		}
+		if config.Defaults.WaitTime != watcher.Duration(3*time.Second) {
+			t.Errorf("%s: Expected wait time 3s, got %s", format, config.Defaults.WaitTime)
+		}
+		if config.Defaults.FolderFormat != watcher.DefaultFolderFormat {
+			t.Errorf("%s: Expected default folder format, got '%s'", format, config.Defaults.FolderFormat)
+		}
+		if len(config.Watchers) != 1 || config.Watchers[0].Source != "a" || !config.Watchers[0].Enabled {
+			t.Errorf("%s: Unexpected watchers: %+v", format, config.Watchers)
+		}
+		if config.MaxConcurrentBackups != 3 {
+			t.Errorf("%s: Expected 3 concurrent backups, got %d", format, config.MaxConcurrentBackups)
+		}
+
+		// Make sure the config survives a round trip in the same format.
+		data, err := marshalConfig(config, format)
+		if err != nil {
+			t.Fatalf("Failed to marshal %s config: %v", format, err)
+		}
+		parsed, err := parseConfig(data, format)
+		if err != nil {
+			t.Errorf("Failed to parse marshaled %s config: %v\n%s", format, err, data)
+		} else if parsed.Defaults.WaitTime != config.Defaults.WaitTime || parsed.MaxConcurrentBackups != 3 {
+			t.Errorf("%s: Expected the settings to survive a round trip, got %+v", format, parsed)
+		}
+	}
+}
+
+func TestConfigFormatFromPath(t *testing.T) {
+	t.Parallel()
+	expected := map[string]ConfigFormat{
+		"config.json": ConfigFormatJSON,
+		"config.yml":  ConfigFormatYAML,
+		"config.YAML": ConfigFormatYAML,
+		"config.toml": ConfigFormatTOML,
+		"config":      ConfigFormatJSON,
+	}
+	for path, format := range expected {
+		if got := configFormatFromPath(path); got != format {
+			t.Errorf("%s: Expected format %s, got %s", path, format, got)
+		}
+	}
+}
+
+func TestNTFSPreservationConfig(t *testing.T) {
+	t.Parallel()
+	for _, format := range []ConfigFormat{ConfigFormatJSON, ConfigFormatYAML, ConfigFormatTOML} {
+		config := &Config{
+			Defaults: builtinDefaults(),
+			Watchers: []*watcher.WatcherConfig{
+				{ID: "watcher-0", PreserveNTFS: watcher.NTFSPreservation{ACLs: true, AlternateStreams: true}},
+				{ID: "watcher-1"},
+			},
+		}
+		data, err := marshalConfig(config, format)
+		if err != nil {
+			t.Fatalf("Failed to marshal %s config: %v", format, err)
+		}
+		if strings.Count(string(data), "preserve_ntfs") != 1 {
+			t.Errorf("%s: Expected preserve_ntfs to only be written for watcher-0\n%s", format, data)
+		}
+
+		parsed, err := parseConfig(data, format)
+		if err != nil {
+			t.Fatalf("Failed to parse %s config: %v", format, err)
+		}
+		expected := watcher.NTFSPreservation{ACLs: true, AlternateStreams: true}
+		if parsed.Watchers[0].PreserveNTFS != expected {
+			t.Errorf("%s: Expected %+v, got %+v", format, expected, parsed.Watchers[0].PreserveNTFS)
+		}
+		if parsed.Watchers[1].PreserveNTFS.Enabled() {
+			t.Errorf("%s: Expected no NTFS preservation for watcher-1", format)
+		}
+	}
+}
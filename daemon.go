@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"ryn-cx/i-saw-that/pkg/watcher"
+)
+
+// Methods of the app that are served to clients. Methods that only make sense in the
+// process that calls them, such as picking a folder or the callbacks of the watchers,
+// are left out, and so is any method that is not listed here.
+var daemonMethods = map[string]bool{
+	"GetFolderPairs":         true,
+	"GetRecentLogs":          true,
+	"GetWatcherStatus":       true,
+	"SelfTest":               true,
+	"GetBackups":             true,
+	"AnnotateBackup":         true,
+	"PinBackup":              true,
+	"ResumeRetention":        true,
+	"GetHistory":             true,
+	"GetStats":               true,
+	"ResetStats":             true,
+	"ExportReport":           true,
+	"GetBackupTimeline":      true,
+	"ExportBackup":           true,
+	"CompareBackup":          true,
+	"ImportBackup":           true,
+	"RestoreFile":            true,
+	"OpenBackupInExplorer":   true,
+	"OpenDestination":        true,
+	"ToggleFolderPair":       true,
+	"AddFolderPair":          true,
+	"UpdateFolderPair":       true,
+	"GetFolderPairData":      true,
+	"CheckFolderPair":        true,
+	"RemoveFolderPair":       true,
+	"GenerateSigningKey":     true,
+	"GetSigningPublicKey":    true,
+	"GetTrustedKeys":         true,
+	"TrustSigningKey":        true,
+	"UntrustSigningKey":      true,
+	"AuthorizeRemote":        true,
+	"ForgetRemote":           true,
+	"SaveSecret":             true,
+	"DeleteSecret":           true,
+	"SaveShareCredentials":   true,
+	"ForgetShareCredentials": true,
+}
+
+// Time to wait before reconnecting to the events of the daemon.
+const daemonReconnectDelay = 5 * time.Second
+
+var errorType = reflect.TypeFor[error]()
+
+// appEvent is an event for the frontend, sent to clients of the daemon.
+type appEvent struct {
+	Name string `json:"name"`
+	Data []any  `json:"data"`
+}
+
+// eventHub passes events of the daemon to the clients that are connected to it.
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan appEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan appEvent]struct{})}
+}
+
+func (h *eventHub) subscribe() chan appEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events := make(chan appEvent, 16)
+	h.subscribers[events] = struct{}{}
+	return events
+}
+
+func (h *eventHub) unsubscribe(events chan appEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, events)
+}
+
+// publish sends an event to every client, events are dropped for clients that are not
+// keeping up rather than holding up the watchers.
+func (h *eventHub) publish(event appEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for events := range h.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// emit sends an event to the frontend, or to the clients of the daemon.
+func (a *App) emit(name string, data ...any) {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, name, data...)
+	}
+	if a.events != nil {
+		a.events.publish(appEvent{Name: name, Data: data})
+	}
+}
+
+// apiHandler serves the methods of the app to clients of the daemon. A method is called
+// with POST /api/<method> and a JSON array of its arguments, the response is its result
+// or an object with the error.
+func (a *App) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/{method}", func(w http.ResponseWriter, r *http.Request) {
+		result, err := a.callMethod(r.PathValue("method"), r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+	mux.HandleFunc("GET /api/events", func(w http.ResponseWriter, r *http.Request) {
+		events := a.events.subscribe()
+		defer a.events.unsubscribe(events)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher, _ := w.(http.Flusher)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		encoder := json.NewEncoder(w)
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event := <-events:
+				if err := encoder.Encode(event); err != nil {
+					return
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+		}
+	})
+	return mux
+}
+
+// callMethod calls an exported method of the app with arguments decoded from a JSON
+// array and returns its result.
+func (a *App) callMethod(name string, body io.Reader) (any, error) {
+	method := reflect.ValueOf(a).MethodByName(name)
+	if !method.IsValid() || !daemonMethods[name] {
+		return nil, fmt.Errorf("unknown method %s", name)
+	}
+	var raw []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("error parsing arguments of %s: %w", name, err)
+	}
+	if len(raw) != method.Type().NumIn() {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", name, method.Type().NumIn(), len(raw))
+	}
+	args := make([]reflect.Value, len(raw))
+	for i := range raw {
+		arg := reflect.New(method.Type().In(i))
+		if err := json.Unmarshal(raw[i], arg.Interface()); err != nil {
+			return nil, fmt.Errorf("error parsing arguments of %s: %w", name, err)
+		}
+		args[i] = arg.Elem()
+	}
+
+	var result any
+	for _, value := range method.Call(args) {
+		if value.Type() == errorType {
+			if !value.IsNil() {
+				return nil, value.Interface().(error)
+			}
+			continue
+		}
+		result = value.Interface()
+	}
+	return result, nil
+}
+
+// daemonClient calls the methods of the app running in a daemon.
+type daemonClient struct {
+	info   InstanceInfo
+	client *http.Client
+}
+
+func newDaemonClient(info InstanceInfo) *daemonClient {
+	return &daemonClient{info: info, client: &http.Client{}}
+}
+
+func (c *daemonClient) request(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, "http://"+c.info.Address+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set(instanceTokenHeader, c.info.Token)
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error contacting the daemon: %w", err)
+	}
+	return response, nil
+}
+
+// call calls a method of the app in the daemon and decodes its result into result,
+// which can be nil for methods that only return an error.
+func (c *daemonClient) call(method string, result any, args ...any) error {
+	if args == nil {
+		args = []any{}
+	}
+	body, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("error marshaling arguments of %s: %w", method, err)
+	}
+	response, err := c.request(context.Background(), http.MethodPost, "/api/"+method, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusInternalServerError:
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&failure); err != nil {
+			return fmt.Errorf("error calling %s: %w", method, err)
+		}
+		return errors.New(failure.Error)
+	default:
+		return fmt.Errorf("error calling %s: %s", method, response.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("error parsing result of %s: %w", method, err)
+	}
+	return nil
+}
+
+// callDaemon calls a method of the app in the daemon that returns a value.
+func callDaemon[T any](c *daemonClient, method string, args ...any) (T, error) {
+	var result T
+	err := c.call(method, &result, args...)
+	return result, err
+}
+
+// watcherStatus returns the status of a watcher in the daemon, errors reaching the
+// daemon are reported as the error of the watcher.
+func (c *daemonClient) watcherStatus(id string) watcher.WatcherStatus {
+	status, err := callDaemon[watcher.WatcherStatus](c, "GetWatcherStatus", id)
+	if err != nil {
+		return watcher.WatcherStatus{Error: err.Error()}
+	}
+	return status
+}
+
+// runningDaemon returns a client for the daemon that uses dataDir, or nil if no daemon
+// is running.
+func runningDaemon(dataDir string) *daemonClient {
+	if dataDir == "" {
+		return nil
+	}
+	info, running := probeInstance(dataDir)
+	if running && info.Mode == instanceModeDaemon && info.Address != "" {
+		return newDaemonClient(info)
+	}
+	return nil
+}
+
+// forwardEvents passes the events of the daemon to emit until ctx is done, reconnecting
+// if the connection is lost.
+func (c *daemonClient) forwardEvents(ctx context.Context, emit func(name string, data ...any)) {
+	for {
+		err := c.readEvents(ctx, emit)
+		if ctx.Err() != nil {
+			return
+		}
+		watcher.Logf("", watcher.LogLevelWarn, "Lost the connection to the daemon: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(daemonReconnectDelay):
+		}
+	}
+}
+
+func (c *daemonClient) readEvents(ctx context.Context, emit func(name string, data ...any)) error {
+	response, err := c.request(ctx, http.MethodGet, "/api/events", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("error reading events: %s", response.Status)
+	}
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		var event appEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("error parsing event: %w", err)
+		}
+		emit(event.Name, event.Data...)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// serveDaemon runs the watchers without the GUI until ctx is done. The methods of the
+// app are served on the address in the lock file so the GUI, or any other client, can
+// use the watchers of the daemon instead of running its own.
+func serveDaemon(ctx context.Context, options *Options, ready func(InstanceInfo)) error {
+	lock, err := acquireInstanceLock(options.DataDir)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	app := NewApp(options)
+	app.events = newEventHub()
+	app.startEngine()
+	lock.info.Mode = instanceModeDaemon
+	if err := lock.serve(app.apiHandler()); err != nil {
+		return errors.Join(err, app.ShutdownAll())
+	}
+	watcher.Logf("", watcher.LogLevelInfo, "Daemon listening on %s", lock.info.Address)
+	if ready != nil {
+		ready(lock.info)
+	}
+
+	<-ctx.Done()
+	watcher.Logf("", watcher.LogLevelInfo, "Shutting down")
+	return app.ShutdownAll()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+	"ryn-cx/i-saw-that/pkg/watcher/watchertest"
+)
+
+func TestDaemonClient(t *testing.T) {
+	t.Parallel()
+	tempConfig := watchertest.DefaultTempWatcherConfig(t)
+	options := writeCLIConfig(t, tempConfig, watcher.WatcherConfig{Enabled: true})
+	options.DataDir = t.TempDir()
+	lock, err := acquireInstanceLock(options.DataDir)
+	if err != nil {
+		t.Fatalf("Failed to lock data directory: %v", err)
+	}
+	defer lock.release()
+
+	daemon := NewApp(options)
+	daemon.events = newEventHub()
+	if err := daemon.loadConfig(); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	defer daemon.ShutdownAll()
+	lock.info.Mode = instanceModeDaemon
+	if err := lock.serve(daemon.apiHandler()); err != nil {
+		t.Fatalf("Failed to start daemon server: %v", err)
+	}
+
+	client := NewApp(options)
+	client.remote = newDaemonClient(lock.info)
+	pairs := client.GetFolderPairs()
+	if len(pairs) != 1 || pairs[0].ID != tempConfig.Name {
+		t.Fatalf("Expected the folder pair of the daemon, got %+v", pairs)
+	}
+	watchertest.CreateDummyFile(t, tempConfig.Source, "file1.txt", 10)
+	daemon.watchers[tempConfig.Name].CreateBackup()
+	backups, err := client.GetBackups(tempConfig.Name)
+	if err != nil || len(backups) != 1 {
+		t.Errorf("Expected the backup made by the daemon, got %+v: %v", backups, err)
+	}
+	if _, err := client.GetBackups("missing"); err == nil || err.Error() != "watcher not running" {
+		t.Errorf("Expected the error of the daemon, got %v", err)
+	}
+
+	// The status command shows the state of the watchers in the daemon.
+	var stdout, stderr bytes.Buffer
+	if code := runCLI(options, []string{"status", "--json"}, strings.NewReader(""), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	var status []WatcherSummary
+	if err := json.Unmarshal(stdout.Bytes(), &status); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, stdout.String())
+	}
+	if len(status) != 1 || status[0].Status == nil || !status[0].Status.Running {
+		t.Errorf("Expected the status of the running watcher, got %+v", status)
+	}
+	if err := client.ToggleFolderPair(tempConfig.Name, false); err != nil {
+		t.Fatalf("Failed to disable folder pair: %v", err)
+	}
+	if _, running := daemon.watchers[tempConfig.Name]; running {
+		t.Errorf("Expected the daemon to stop the watcher")
+	}
+	for _, method := range []string{"ShutdownAll", "OnSourceMoved"} {
+		if err := client.remote.call(method, nil); err == nil {
+			t.Errorf("Expected %s to be refused", method)
+		}
+	}
+
+	// Requests from several clients at once do not race over the watchers.
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.ToggleFolderPair(tempConfig.Name, i%2 == 0); err != nil {
+				t.Errorf("Failed to toggle folder pair: %v", err)
+			}
+			client.GetFolderPairs()
+		}()
+	}
+	wg.Wait()
+
+	// Events of the daemon are passed on to the client.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan string, 1)
+	go client.remote.forwardEvents(ctx, func(name string, data ...any) {
+		select {
+		case received <- name:
+		default:
+		}
+	})
+	deadline := time.After(5 * time.Second)
+	for done := false; !done; {
+		daemon.emit("backup-complete", tempConfig.Name)
+		select {
+		case name := <-received:
+			if name != "backup-complete" {
+				t.Errorf("Expected a backup-complete event, got %s", name)
+			}
+			done = true
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("Expected the event to be forwarded")
+		}
+	}
+
+	request, err := http.NewRequest(http.MethodPost, "http://"+lock.info.Address+"/api/GetFolderPairs", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a request without the token to be refused, got %s", response.Status)
+	}
+}
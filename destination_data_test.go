@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+	"ryn-cx/i-saw-that/pkg/watcher/watchertest"
+)
+
+// newRemovalTestApp returns an app with a single disabled folder pair that has two
+// backups.
+func newRemovalTestApp(t *testing.T) (*App, watchertest.TempWatcherConfig) {
+	temp := watchertest.DefaultTempWatcherConfig(t)
+	w, err := watchertest.NewTempWatcher(temp)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watchertest.CreateDummyFile(t, temp.Source, "file1.txt", 1024)
+	w.CreateBackup()
+	watchertest.CreateDummyFile(t, temp.Source, "file2.txt", 1024)
+	w.CreateBackup()
+
+	app := NewApp(&Options{ConfigPath: filepath.Join(temp.TempPath, "config.json")})
+	app.config = []*watcher.WatcherConfig{{
+		ID:           temp.Name,
+		Source:       temp.Source,
+		Destination:  temp.Destination,
+		WaitTime:     watcher.Duration(temp.WaitTime),
+		FolderFormat: temp.FolderFormat,
+	}}
+	return app, temp
+}
+
+func TestRemoveFolderPairKeepsBackups(t *testing.T) {
+	t.Parallel()
+	app, temp := newRemovalTestApp(t)
+
+	data, err := app.GetFolderPairData(temp.Name)
+	if err != nil {
+		t.Fatalf("Failed to get folder pair data: %v", err)
+	}
+	if data.Backups != 2 || data.Size < 3*1024 || len(data.Destinations) != 1 {
+		t.Errorf("Unexpected folder pair data: %+v", data)
+	}
+
+	if err := app.RemoveFolderPair(temp.Name, false); err != nil {
+		t.Fatalf("Failed to remove folder pair: %v", err)
+	}
+	if len(app.config) != 0 {
+		t.Errorf("Expected the folder pair to be removed from the config")
+	}
+	w, err := watchertest.NewTempWatcher(temp)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if backups := w.ListBackups(); len(backups) != 2 {
+		t.Errorf("Expected the backups to be kept, got %d", len(backups))
+	}
+}
+
+func TestRemoveFolderPairDeletesBackups(t *testing.T) {
+	t.Parallel()
+	app, temp := newRemovalTestApp(t)
+
+	if err := app.RemoveFolderPair(temp.Name, true); err != nil {
+		t.Fatalf("Failed to remove folder pair: %v", err)
+	}
+	if _, err := os.Stat(temp.Destination); !os.IsNotExist(err) {
+		entries, _ := os.ReadDir(temp.Destination)
+		t.Errorf("Expected the empty destination to be removed, it contains %v", entries)
+	}
+	if _, err := app.GetFolderPairData(temp.Name); err == nil {
+		t.Errorf("Expected the folder pair to be gone")
+	}
+}
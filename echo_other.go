@@ -0,0 +1,10 @@
+//go:build !windows && !linux && !darwin
+
+package main
+
+import "os"
+
+// disableEcho is not supported on other platforms, what is typed stays visible.
+func disableEcho(file *os.File) (func(), bool) {
+	return nil, false
+}
@@ -0,0 +1,26 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// disableEcho stops the terminal from showing what is typed, such as a password, and
+// returns the function that turns it back on. False is returned if file is not a
+// terminal.
+func disableEcho(file *os.File) (func(), bool) {
+	fd := int(file.Fd())
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, false
+	}
+	original := *termios
+	termios.Lflag &^= unix.ECHO
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, termios); err != nil {
+		return nil, false
+	}
+	return func() { unix.IoctlSetTermios(fd, ioctlSetTermios, &original) }, true
+}
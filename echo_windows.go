@@ -0,0 +1,24 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// disableEcho stops the console from showing what is typed, such as a password, and
+// returns the function that turns it back on. False is returned if file is not a
+// console.
+func disableEcho(file *os.File) (func(), bool) {
+	handle := windows.Handle(file.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return nil, false
+	}
+	if err := windows.SetConsoleMode(handle, mode&^windows.ENABLE_ECHO_INPUT); err != nil {
+		return nil, false
+	}
+	return func() { windows.SetConsoleMode(handle, mode) }, true
+}
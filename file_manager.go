@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// openInFileManager opens a folder in the file manager of the platform without
+// waiting for the file manager to be closed.
+func openInFileManager(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("error opening folder: %w", err)
+	}
+	cmd := fileManagerCommand(path)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error opening file manager: %w", err)
+	}
+	// Explorer exits with 1 even when it opened the folder so the result is ignored.
+	go cmd.Wait()
+	return nil
+}
+
+// openInBrowser opens an address in the default browser, the commands that open a
+// folder also open addresses.
+func openInBrowser(address string) error {
+	cmd := fileManagerCommand(address)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error opening browser: %w", err)
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build darwin
+
+package main
+
+import "os/exec"
+
+func fileManagerCommand(path string) *exec.Cmd {
+	return exec.Command("open", path)
+}
@@ -0,0 +1,10 @@
+//go:build !windows && !darwin
+
+package main
+
+import "os/exec"
+
+// fileManagerCommand opens the folder with the default application of the desktop.
+func fileManagerCommand(path string) *exec.Cmd {
+	return exec.Command("xdg-open", path)
+}
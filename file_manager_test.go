@@ -0,0 +1,13 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenInFileManager(t *testing.T) {
+	t.Parallel()
+	if err := openInFileManager(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("Expected an error for a folder that does not exist")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+import "os/exec"
+
+func fileManagerCommand(path string) *exec.Cmd {
+	return exec.Command("explorer", path)
+}
@@ -1,15 +1,90 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
+import {watcher} from '../models';
 
-export function AddFolderPair(arg1:string,arg2:string,arg3:number,arg4:string):Promise<void>;
+export function AddFolderPair(arg1:string,arg2:string,arg3:string,arg4:string):Promise<void>;
 
-export function GetFolderPairs():Promise<Array<main.WatcherConfig>>;
+export function AnnotateBackup(arg1:string,arg2:string,arg3:string):Promise<void>;
 
-export function RemoveFolderPair(arg1:string):Promise<void>;
+export function AuthorizeRemote(arg1:string):Promise<void>;
+
+export function CheckFolderPair(arg1:string,arg2:string,arg3:string):Promise<Array<watcher.PairConflict>>;
+
+export function CheckForUpdates():Promise<main.UpdateInfo>;
+
+export function CompareBackup(arg1:string,arg2:string,arg3:string):Promise<watcher.BackupComparison>;
+
+export function DeleteSecret(arg1:string):Promise<void>;
+
+export function ExportBackup(arg1:string,arg2:string,arg3:string,arg4:string):Promise<void>;
+
+export function ExportReport(arg1:string,arg2:string,arg3:string):Promise<void>;
+
+export function ForgetRemote(arg1:string):Promise<void>;
+
+export function ForgetShareCredentials(arg1:string):Promise<void>;
+
+export function GenerateSigningKey():Promise<string>;
+
+export function GetBackupTimeline(arg1:string,arg2:any,arg3:any,arg4:string):Promise<Array<watcher.TimelineBucket>>;
+
+export function GetBackups(arg1:string):Promise<Array<watcher.Backup>>;
+
+export function GetFolderPairData(arg1:string):Promise<watcher.DestinationData>;
+
+export function GetFolderPairs():Promise<Array<watcher.WatcherConfig>>;
+
+export function GetHistory(arg1:string):Promise<Array<watcher.HistoryEvent>>;
+
+export function GetProfile():Promise<string>;
+
+export function GetProfiles():Promise<Array<string>>;
+
+export function GetRecentLogs(arg1:string,arg2:string,arg3:number):Promise<Array<watcher.LogEntry>>;
+
+export function GetSigningPublicKey():Promise<string>;
+
+export function GetStartAtLogin():Promise<boolean>;
+
+export function GetStats(arg1:string):Promise<watcher.WatcherStats>;
+
+export function GetTrustedKeys():Promise<Array<watcher.TrustedKey>>;
+
+export function GetWatcherStatus(arg1:string):Promise<watcher.WatcherStatus>;
+
+export function ImportBackup(arg1:string,arg2:string):Promise<watcher.Backup>;
+
+export function OpenBackupInExplorer(arg1:string,arg2:string):Promise<void>;
+
+export function OpenDestination(arg1:string):Promise<void>;
+
+export function PinBackup(arg1:string,arg2:string,arg3:boolean):Promise<void>;
+
+export function RemoveFolderPair(arg1:string,arg2:boolean):Promise<void>;
+
+export function ResetStats(arg1:string):Promise<void>;
+
+export function RestoreFile(arg1:string,arg2:string,arg3:string,arg4:boolean):Promise<string>;
+
+export function ResumeRetention(arg1:string):Promise<void>;
+
+export function SaveSecret(arg1:string,arg2:string):Promise<void>;
+
+export function SaveShareCredentials(arg1:string,arg2:string,arg3:string):Promise<void>;
 
 export function SelectFolder():Promise<string>;
 
+export function SelfTest(arg1:string):Promise<watcher.SelfTestResult>;
+
+export function SetStartAtLogin(arg1:boolean):Promise<void>;
+
+export function ShutdownAll():Promise<void>;
+
 export function ToggleFolderPair(arg1:string,arg2:boolean):Promise<void>;
 
-export function UpdateFolderPair(arg1:string,arg2:string,arg3:string,arg4:number,arg5:string):Promise<void>;
+export function TrustSigningKey(arg1:string):Promise<string>;
+
+export function UntrustSigningKey(arg1:string):Promise<void>;
+
+export function UpdateFolderPair(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string):Promise<void>;
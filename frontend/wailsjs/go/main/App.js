@@ -6,22 +6,170 @@ export function AddFolderPair(arg1, arg2, arg3, arg4) {
   return window['go']['main']['App']['AddFolderPair'](arg1, arg2, arg3, arg4);
 }
 
+export function AnnotateBackup(arg1, arg2, arg3) {
+  return window['go']['main']['App']['AnnotateBackup'](arg1, arg2, arg3);
+}
+
+export function AuthorizeRemote(arg1) {
+  return window['go']['main']['App']['AuthorizeRemote'](arg1);
+}
+
+export function CheckFolderPair(arg1, arg2, arg3) {
+  return window['go']['main']['App']['CheckFolderPair'](arg1, arg2, arg3);
+}
+
+export function CheckForUpdates() {
+  return window['go']['main']['App']['CheckForUpdates']();
+}
+
+export function CompareBackup(arg1, arg2, arg3) {
+  return window['go']['main']['App']['CompareBackup'](arg1, arg2, arg3);
+}
+
+export function DeleteSecret(arg1) {
+  return window['go']['main']['App']['DeleteSecret'](arg1);
+}
+
+export function ExportBackup(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['ExportBackup'](arg1, arg2, arg3, arg4);
+}
+
+export function ExportReport(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ExportReport'](arg1, arg2, arg3);
+}
+
+export function ForgetRemote(arg1) {
+  return window['go']['main']['App']['ForgetRemote'](arg1);
+}
+
+export function ForgetShareCredentials(arg1) {
+  return window['go']['main']['App']['ForgetShareCredentials'](arg1);
+}
+
+export function GenerateSigningKey() {
+  return window['go']['main']['App']['GenerateSigningKey']();
+}
+
+export function GetBackupTimeline(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['GetBackupTimeline'](arg1, arg2, arg3, arg4);
+}
+
+export function GetBackups(arg1) {
+  return window['go']['main']['App']['GetBackups'](arg1);
+}
+
+export function GetFolderPairData(arg1) {
+  return window['go']['main']['App']['GetFolderPairData'](arg1);
+}
+
 export function GetFolderPairs() {
   return window['go']['main']['App']['GetFolderPairs']();
 }
 
-export function RemoveFolderPair(arg1) {
-  return window['go']['main']['App']['RemoveFolderPair'](arg1);
+export function GetHistory(arg1) {
+  return window['go']['main']['App']['GetHistory'](arg1);
+}
+
+export function GetProfile() {
+  return window['go']['main']['App']['GetProfile']();
+}
+
+export function GetProfiles() {
+  return window['go']['main']['App']['GetProfiles']();
+}
+
+export function GetRecentLogs(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetRecentLogs'](arg1, arg2, arg3);
+}
+
+export function GetSigningPublicKey() {
+  return window['go']['main']['App']['GetSigningPublicKey']();
+}
+
+export function GetStartAtLogin() {
+  return window['go']['main']['App']['GetStartAtLogin']();
+}
+
+export function GetStats(arg1) {
+  return window['go']['main']['App']['GetStats'](arg1);
+}
+
+export function GetTrustedKeys() {
+  return window['go']['main']['App']['GetTrustedKeys']();
+}
+
+export function GetWatcherStatus(arg1) {
+  return window['go']['main']['App']['GetWatcherStatus'](arg1);
+}
+
+export function ImportBackup(arg1, arg2) {
+  return window['go']['main']['App']['ImportBackup'](arg1, arg2);
+}
+
+export function OpenBackupInExplorer(arg1, arg2) {
+  return window['go']['main']['App']['OpenBackupInExplorer'](arg1, arg2);
+}
+
+export function OpenDestination(arg1) {
+  return window['go']['main']['App']['OpenDestination'](arg1);
+}
+
+export function PinBackup(arg1, arg2, arg3) {
+  return window['go']['main']['App']['PinBackup'](arg1, arg2, arg3);
+}
+
+export function RemoveFolderPair(arg1, arg2) {
+  return window['go']['main']['App']['RemoveFolderPair'](arg1, arg2);
+}
+
+export function ResetStats(arg1) {
+  return window['go']['main']['App']['ResetStats'](arg1);
+}
+
+export function RestoreFile(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['RestoreFile'](arg1, arg2, arg3, arg4);
+}
+
+export function ResumeRetention(arg1) {
+  return window['go']['main']['App']['ResumeRetention'](arg1);
+}
+
+export function SaveSecret(arg1, arg2) {
+  return window['go']['main']['App']['SaveSecret'](arg1, arg2);
+}
+
+export function SaveShareCredentials(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SaveShareCredentials'](arg1, arg2, arg3);
 }
 
 export function SelectFolder() {
   return window['go']['main']['App']['SelectFolder']();
 }
 
+export function SelfTest(arg1) {
+  return window['go']['main']['App']['SelfTest'](arg1);
+}
+
+export function SetStartAtLogin(arg1) {
+  return window['go']['main']['App']['SetStartAtLogin'](arg1);
+}
+
+export function ShutdownAll() {
+  return window['go']['main']['App']['ShutdownAll']();
+}
+
 export function ToggleFolderPair(arg1, arg2) {
   return window['go']['main']['App']['ToggleFolderPair'](arg1, arg2);
 }
 
+export function TrustSigningKey(arg1) {
+  return window['go']['main']['App']['TrustSigningKey'](arg1);
+}
+
+export function UntrustSigningKey(arg1) {
+  return window['go']['main']['App']['UntrustSigningKey'](arg1);
+}
+
 export function UpdateFolderPair(arg1, arg2, arg3, arg4, arg5) {
   return window['go']['main']['App']['UpdateFolderPair'](arg1, arg2, arg3, arg4, arg5);
 }
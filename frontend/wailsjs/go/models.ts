@@ -1,12 +1,761 @@
 export namespace main {
 	
+	export class UpdateInfo {
+	    current: string;
+	    latest: string;
+	    available: boolean;
+	    url?: string;
+	    notes?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new UpdateInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.current = source["current"];
+	        this.latest = source["latest"];
+	        this.available = source["available"];
+	        this.url = source["url"];
+	        this.notes = source["notes"];
+	    }
+	}
+
+}
+
+export namespace watcher {
+	
+	export class BackendHealth {
+	    backend: string;
+	    healthy: boolean;
+	    error?: string;
+	    // Go type: time
+	    checked_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new BackendHealth(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.backend = source["backend"];
+	        this.healthy = source["healthy"];
+	        this.error = source["error"];
+	        this.checked_at = this.convertValues(source["checked_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Backup {
+	    name?: string;
+	    timestamp: number;
+	    path: string;
+	    compressed?: boolean;
+	    folder_format?: string;
+	    destination?: string;
+	    restic_snapshot?: string;
+	    source_hash?: string;
+	    hash_algorithm?: string;
+	    snapshot?: string;
+	    snapshot_name?: string;
+	    sequence?: number;
+	    fuzzy?: boolean;
+	    fuzzy_paths?: string[];
+	    time_zone?: string;
+	    pinned?: boolean;
+	    note?: string;
+	    events?: FileEvent[];
+	    dropped_events?: number;
+	    high_entropy?: string[];
+	    remote_copy?: boolean;
+	    degraded?: string[];
+	    chunked?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Backup(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.timestamp = source["timestamp"];
+	        this.path = source["path"];
+	        this.compressed = source["compressed"];
+	        this.folder_format = source["folder_format"];
+	        this.destination = source["destination"];
+	        this.restic_snapshot = source["restic_snapshot"];
+	        this.source_hash = source["source_hash"];
+	        this.hash_algorithm = source["hash_algorithm"];
+	        this.snapshot = source["snapshot"];
+	        this.snapshot_name = source["snapshot_name"];
+	        this.sequence = source["sequence"];
+	        this.fuzzy = source["fuzzy"];
+	        this.fuzzy_paths = source["fuzzy_paths"];
+	        this.time_zone = source["time_zone"];
+	        this.pinned = source["pinned"];
+	        this.note = source["note"];
+	        this.events = this.convertValues(source["events"], FileEvent);
+	        this.dropped_events = source["dropped_events"];
+	        this.high_entropy = source["high_entropy"];
+	        this.remote_copy = source["remote_copy"];
+	        this.degraded = source["degraded"];
+	        this.chunked = source["chunked"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class BackupComparison {
+	    backup: Backup;
+	    directory: string;
+	    differences: ManifestDifference[];
+	
+	    static createFrom(source: any = {}) {
+	        return new BackupComparison(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.backup = this.convertValues(source["backup"], Backup);
+	        this.directory = source["directory"];
+	        this.differences = this.convertValues(source["differences"], ManifestDifference);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ChecksumConfig {
+	    enabled?: boolean;
+	    signing_key?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChecksumConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.signing_key = source["signing_key"];
+	    }
+	}
+	export class ChunkingConfig {
+	    mode?: string;
+	    min_file_size?: number;
+	    chunk_size?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChunkingConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.mode = source["mode"];
+	        this.min_file_size = source["min_file_size"];
+	        this.chunk_size = source["chunk_size"];
+	    }
+	}
+	export class CopyOptions {
+	    buffer_size?: number;
+	    preallocate_size?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CopyOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.buffer_size = source["buffer_size"];
+	        this.preallocate_size = source["preallocate_size"];
+	    }
+	}
+	export class DebounceConfig {
+	    strategy?: string;
+	    max_wait?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DebounceConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.strategy = source["strategy"];
+	        this.max_wait = source["max_wait"];
+	    }
+	}
+	export class DeferPolicy {
+	    battery?: boolean;
+	    metered?: boolean;
+	    cpu?: number;
+	    disk?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DeferPolicy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.battery = source["battery"];
+	        this.metered = source["metered"];
+	        this.cpu = source["cpu"];
+	        this.disk = source["disk"];
+	    }
+	}
+	export class DestinationData {
+	    destinations: string[];
+	    backups: number;
+	    size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DestinationData(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.destinations = source["destinations"];
+	        this.backups = source["backups"];
+	        this.size = source["size"];
+	    }
+	}
+	export class EntropyConfig {
+	    files?: number;
+	    sample?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new EntropyConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.files = source["files"];
+	        this.sample = source["sample"];
+	    }
+	}
+	export class FileEvent {
+	    // Go type: time
+	    time: any;
+	    path: string;
+	    op: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileEvent(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = this.convertValues(source["time"], null);
+	        this.path = source["path"];
+	        this.op = source["op"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FreeSpaceConfig {
+	    min_free_mb?: number;
+	    emergency_prune?: boolean;
+	    interval?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new FreeSpaceConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.min_free_mb = source["min_free_mb"];
+	        this.emergency_prune = source["emergency_prune"];
+	        this.interval = source["interval"];
+	    }
+	}
+	export class HistoryEvent {
+	    // Go type: time
+	    time: any;
+	    type: string;
+	    backup?: string;
+	    trigger?: string;
+	    message?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new HistoryEvent(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = this.convertValues(source["time"], null);
+	        this.type = source["type"];
+	        this.backup = source["backup"];
+	        this.trigger = source["trigger"];
+	        this.message = source["message"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class IdleTriggerConfig {
+	    after?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new IdleTriggerConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.after = source["after"];
+	    }
+	}
+	export class LogEntry {
+	    // Go type: time
+	    time: any;
+	    level: string;
+	    watcher_id?: string;
+	    message: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LogEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = this.convertValues(source["time"], null);
+	        this.level = source["level"];
+	        this.watcher_id = source["watcher_id"];
+	        this.message = source["message"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ManifestDifference {
+	    path: string;
+	    kind: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ManifestDifference(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.kind = source["kind"];
+	    }
+	}
+	export class MassChangeConfig {
+	    files?: number;
+	    window?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MassChangeConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.files = source["files"];
+	        this.window = source["window"];
+	    }
+	}
+	export class NTFSPreservation {
+	    attributes?: boolean;
+	    acls?: boolean;
+	    alternate_streams?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new NTFSPreservation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.attributes = source["attributes"];
+	        this.acls = source["acls"];
+	        this.alternate_streams = source["alternate_streams"];
+	    }
+	}
+	export class ObserverQueueConfig {
+	    size?: number;
+	    policy?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ObserverQueueConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.size = source["size"];
+	        this.policy = source["policy"];
+	    }
+	}
+	export class OpenFilesConfig {
+	    policy?: string;
+	    max_wait?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new OpenFilesConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.policy = source["policy"];
+	        this.max_wait = source["max_wait"];
+	    }
+	}
+	export class PairConflict {
+	    kind: string;
+	    pair: string;
+	    blocking: boolean;
+	    message: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PairConflict(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.pair = source["pair"];
+	        this.blocking = source["blocking"];
+	        this.message = source["message"];
+	    }
+	}
+	export class ProcessTriggerConfig {
+	    name?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProcessTriggerConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	    }
+	}
+	export class QuietHours {
+	    start: string;
+	    end: string;
+	    days?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new QuietHours(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.start = source["start"];
+	        this.end = source["end"];
+	        this.days = source["days"];
+	    }
+	}
+	export class RemoteConfig {
+	    type?: string;
+	    url?: string;
+	    user?: string;
+	    password?: string;
+	    chunk_size?: number;
+	    client_id?: string;
+	    client_secret?: string;
+	    folder?: string;
+	    bucket?: string;
+	    key_id?: string;
+	    application_key?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RemoteConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.url = source["url"];
+	        this.user = source["user"];
+	        this.password = source["password"];
+	        this.chunk_size = source["chunk_size"];
+	        this.client_id = source["client_id"];
+	        this.client_secret = source["client_secret"];
+	        this.folder = source["folder"];
+	        this.bucket = source["bucket"];
+	        this.key_id = source["key_id"];
+	        this.application_key = source["application_key"];
+	    }
+	}
+	export class ResticConfig {
+	    repository?: string;
+	    password_file?: string;
+	    executable?: string;
+	    password?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ResticConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.repository = source["repository"];
+	        this.password_file = source["password_file"];
+	        this.executable = source["executable"];
+	        this.password = source["password"];
+	    }
+	}
+	export class RestoreDrillConfig {
+	    interval?: number;
+	    recent?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RestoreDrillConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.interval = source["interval"];
+	        this.recent = source["recent"];
+	    }
+	}
+	export class RetentionPolicy {
+	    keep_last?: number;
+	    keep_hourly?: number;
+	    keep_daily?: number;
+	    keep_weekly?: number;
+	    keep_monthly?: number;
+	    trash_days?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RetentionPolicy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.keep_last = source["keep_last"];
+	        this.keep_hourly = source["keep_hourly"];
+	        this.keep_daily = source["keep_daily"];
+	        this.keep_weekly = source["keep_weekly"];
+	        this.keep_monthly = source["keep_monthly"];
+	        this.trash_days = source["trash_days"];
+	    }
+	}
+	export class SelfTestResult {
+	    passed: boolean;
+	    events_received: boolean;
+	    events_skipped?: boolean;
+	    destination_writable: boolean;
+	    problems: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new SelfTestResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.passed = source["passed"];
+	        this.events_received = source["events_received"];
+	        this.events_skipped = source["events_skipped"];
+	        this.destination_writable = source["destination_writable"];
+	        this.problems = source["problems"];
+	    }
+	}
+	export class StabilityConfig {
+	    enabled?: boolean;
+	    interval?: number;
+	    max_wait?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new StabilityConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.interval = source["interval"];
+	        this.max_wait = source["max_wait"];
+	    }
+	}
+	export class TimelineBucket {
+	    // Go type: time
+	    start: any;
+	    count: number;
+	    size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TimelineBucket(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.start = this.convertValues(source["start"], null);
+	        this.count = source["count"];
+	        this.size = source["size"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TrustedKey {
+	    id: string;
+	    public_key: string;
+	    own?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new TrustedKey(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.public_key = source["public_key"];
+	        this.own = source["own"];
+	    }
+	}
 	export class WatcherConfig {
 	    id: string;
 	    source: string;
 	    destination: string;
 	    enabled: boolean;
-	    wait_time: number;
-	    folder_format: string;
+	    wait_time?: number;
+	    folder_format?: string;
+	    rotation_destinations?: string[];
+	    copy_engine?: string;
+	    poll_on_watch_limit?: boolean;
+	    poll_interval?: number;
+	    watch_destination?: boolean;
+	    read_only_backups?: boolean;
+	    read_only_source?: boolean;
+	    hash_algorithm?: string;
+	    persist_scan_cache?: boolean;
+	    preserve_ntfs?: NTFSPreservation;
+	    retention?: RetentionPolicy;
+	    observer_queue?: ObserverQueueConfig;
+	    debounce?: DebounceConfig;
+	    quiet_hours?: QuietHours[];
+	    defer?: DeferPolicy;
+	    restic?: ResticConfig;
+	    snapshot?: string;
+	    copy?: CopyOptions;
+	    chunking?: ChunkingConfig;
+	    placeholders?: string;
+	    checksums?: ChecksumConfig;
+	    permissions?: string;
+	    open_files?: OpenFilesConfig;
+	    process_trigger?: ProcessTriggerConfig;
+	    stability?: StabilityConfig;
+	    include?: string[];
+	    close_write?: boolean;
+	    time_zone?: string;
+	    idle_trigger?: IdleTriggerConfig;
+	    priority?: string;
+	    free_space?: FreeSpaceConfig;
+	    max_staleness?: number;
+	    restore_drill?: RestoreDrillConfig;
+	    skip_safety_backups?: boolean;
+	    follow_source?: boolean;
+	    audit_log?: boolean;
+	    mass_change?: MassChangeConfig;
+	    entropy?: EntropyConfig;
+	    canaries?: string[];
+	    sign_backups?: boolean;
+	    staging?: string;
+	    remote?: RemoteConfig;
+	    health_check?: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new WatcherConfig(source);
@@ -20,7 +769,171 @@ export namespace main {
 	        this.enabled = source["enabled"];
 	        this.wait_time = source["wait_time"];
 	        this.folder_format = source["folder_format"];
+	        this.rotation_destinations = source["rotation_destinations"];
+	        this.copy_engine = source["copy_engine"];
+	        this.poll_on_watch_limit = source["poll_on_watch_limit"];
+	        this.poll_interval = source["poll_interval"];
+	        this.watch_destination = source["watch_destination"];
+	        this.read_only_backups = source["read_only_backups"];
+	        this.read_only_source = source["read_only_source"];
+	        this.hash_algorithm = source["hash_algorithm"];
+	        this.persist_scan_cache = source["persist_scan_cache"];
+	        this.preserve_ntfs = this.convertValues(source["preserve_ntfs"], NTFSPreservation);
+	        this.retention = this.convertValues(source["retention"], RetentionPolicy);
+	        this.observer_queue = this.convertValues(source["observer_queue"], ObserverQueueConfig);
+	        this.debounce = this.convertValues(source["debounce"], DebounceConfig);
+	        this.quiet_hours = this.convertValues(source["quiet_hours"], QuietHours);
+	        this.defer = this.convertValues(source["defer"], DeferPolicy);
+	        this.restic = this.convertValues(source["restic"], ResticConfig);
+	        this.snapshot = source["snapshot"];
+	        this.copy = this.convertValues(source["copy"], CopyOptions);
+	        this.chunking = this.convertValues(source["chunking"], ChunkingConfig);
+	        this.placeholders = source["placeholders"];
+	        this.checksums = this.convertValues(source["checksums"], ChecksumConfig);
+	        this.permissions = source["permissions"];
+	        this.open_files = this.convertValues(source["open_files"], OpenFilesConfig);
+	        this.process_trigger = this.convertValues(source["process_trigger"], ProcessTriggerConfig);
+	        this.stability = this.convertValues(source["stability"], StabilityConfig);
+	        this.include = source["include"];
+	        this.close_write = source["close_write"];
+	        this.time_zone = source["time_zone"];
+	        this.idle_trigger = this.convertValues(source["idle_trigger"], IdleTriggerConfig);
+	        this.priority = source["priority"];
+	        this.free_space = this.convertValues(source["free_space"], FreeSpaceConfig);
+	        this.max_staleness = source["max_staleness"];
+	        this.restore_drill = this.convertValues(source["restore_drill"], RestoreDrillConfig);
+	        this.skip_safety_backups = source["skip_safety_backups"];
+	        this.follow_source = source["follow_source"];
+	        this.audit_log = source["audit_log"];
+	        this.mass_change = this.convertValues(source["mass_change"], MassChangeConfig);
+	        this.entropy = this.convertValues(source["entropy"], EntropyConfig);
+	        this.canaries = source["canaries"];
+	        this.sign_backups = source["sign_backups"];
+	        this.staging = source["staging"];
+	        this.remote = this.convertValues(source["remote"], RemoteConfig);
+	        this.health_check = source["health_check"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class WatcherStats {
+	    // Go type: time
+	    since: any;
+	    changes: number;
+	    completed: number;
+	    failed: number;
+	    skipped: number;
+	    pruned: number;
+	    restored: number;
+	    triggers?: {[key: string]: number};
+	    backup_time: number;
+	    // Go type: time
+	    last_backup?: any;
+	    // Go type: time
+	    last_failure?: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new WatcherStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.since = this.convertValues(source["since"], null);
+	        this.changes = source["changes"];
+	        this.completed = source["completed"];
+	        this.failed = source["failed"];
+	        this.skipped = source["skipped"];
+	        this.pruned = source["pruned"];
+	        this.restored = source["restored"];
+	        this.triggers = source["triggers"];
+	        this.backup_time = source["backup_time"];
+	        this.last_backup = this.convertValues(source["last_backup"], null);
+	        this.last_failure = this.convertValues(source["last_failure"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class WatcherStatus {
+	    running: boolean;
+	    polling: boolean;
+	    error?: string;
+	    hint?: string;
+	    deferred?: string;
+	    stale?: string;
+	    mass_change?: string;
+	    health?: BackendHealth[];
+	    restarts?: number;
+	    crashed?: string;
+	    crash_dump?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new WatcherStatus(source);
 	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.running = source["running"];
+	        this.polling = source["polling"];
+	        this.error = source["error"];
+	        this.hint = source["hint"];
+	        this.deferred = source["deferred"];
+	        this.stale = source["stale"];
+	        this.mass_change = source["mass_change"];
+	        this.health = this.convertValues(source["health"], BackendHealth);
+	        this.restarts = source["restarts"];
+	        this.crashed = source["crashed"];
+	        this.crash_dump = source["crash_dump"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 
 }
@@ -2,20 +2,73 @@ package main
 
 import (
 	"embed"
+	"errors"
+	"fmt"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
+	"ryn-cx/i-saw-that/pkg/watcher"
 )
 
 //go:embed frontend
 var assets embed.FS
 
 func main() {
-	app := NewApp()
+	// Backups record the version of the app that made them.
+	watcher.Version = version
+	appOptions, args, err := parseOptions(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitUsage)
+	}
+	watcher.StdoutLogLevel, _ = watcher.ParseLogLevel(appOptions.LogLevel)
+	if _, executable, err := appOptions.updateSource(); err == nil {
+		removeOldExecutable(executable)
+	}
+
+	// Any remaining arguments are a command to run instead of the GUI.
+	if len(args) > 0 {
+		// Only warnings and errors are logged unless a level was chosen so the log
+		// does not drown out the output of the command.
+		if appOptions.LogLevel == "" {
+			watcher.StdoutLogLevel = watcher.LogLevelWarn
+		}
+		os.Exit(runCLI(appOptions, args, os.Stdin, os.Stdout, os.Stderr))
+	}
+
+	app := NewApp(appOptions)
+
+	// Only one instance can use a data directory, starting a second one shows the
+	// window of the running instance instead. If the running instance is a daemon the
+	// GUI connects to it and closing the window leaves the backups running.
+	lock, err := acquireInstanceLock(appOptions.DataDir)
+	var running *InstanceRunningError
+	switch {
+	case err == nil:
+		defer lock.release()
+		if err := lock.serve(handoffHandler(app.showWindow)); err != nil {
+			watcher.Logf("", watcher.LogLevelWarn, "%v", err)
+		}
+	case errors.As(err, &running) && running.Info.Mode == instanceModeDaemon:
+		app.remote = newDaemonClient(running.Info)
+		watcher.Logf("", watcher.LogLevelInfo, "Connected to the daemon with process ID %d", running.Info.PID)
+	case errors.As(err, &running) && running.Info.handoff() == nil:
+		fmt.Fprintln(os.Stderr, "I Saw That is already running, showing its window")
+		os.Exit(exitOK)
+	default:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitInstanceRunning)
+	}
+
+	title := "I Saw That"
+	if appOptions.Profile != "" {
+		title += " (" + appOptions.Profile + ")"
+	}
 
-	err := wails.Run(&options.App{
-		Title:  "I Saw That",
+	err = wails.Run(&options.App{
+		Title:  title,
 		Width:  800,
 		Height: 600,
 		AssetServer: &assetserver.Options{
@@ -23,12 +76,13 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 255, G: 255, B: 255, A: 1},
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
 		Bind: []interface{}{
 			app,
 		},
 	})
 
 	if err != nil {
-		println("Error:", err.Error())
+		fmt.Fprintln(os.Stderr, "Error:", err)
 	}
 }
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ryn-cx/i-saw-that/internal/filelock"
+)
+
+// Name of the file in the data directory that is locked by the running instance.
+const instanceLockFileName = "instance.lock"
+
+// Header the token of the running instance is sent in.
+const instanceTokenHeader = "X-I-Saw-That-Token"
+
+// Mode of an instance started with the daemon command, the GUI connects to it instead of
+// handing off.
+const instanceModeDaemon = "daemon"
+
+var errInstanceRunning = errors.New("another instance is already running")
+
+// InstanceInfo is written to the lock file by the instance that holds it.
+type InstanceInfo struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	// Empty for the GUI, or instanceModeDaemon.
+	Mode string `json:"mode,omitempty"`
+	// Address the server of this instance listens on, empty until the server is
+	// started.
+	Address string `json:"address,omitempty"`
+	// Secret that has to be sent with every request. The lock file is only readable by
+	// the user so other users can not send requests.
+	Token string `json:"token,omitempty"`
+}
+
+// InstanceRunningError is returned when the data directory is locked by another
+// instance.
+type InstanceRunningError struct {
+	Info InstanceInfo
+}
+
+func (e *InstanceRunningError) Error() string {
+	return fmt.Sprintf("%v with process ID %d", errInstanceRunning, e.Info.PID)
+}
+
+func (e *InstanceRunningError) Unwrap() error {
+	return errInstanceRunning
+}
+
+// instanceLock is held for as long as the app runs so a data directory, and with it
+// the config and the backups it describes, is only used by one instance at a time. The
+// lock is released by the operating system if the app crashes.
+type instanceLock struct {
+	file   *os.File
+	info   InstanceInfo
+	server *http.Server
+}
+
+func instanceLockPath(dataDir string) string {
+	return filepath.Join(dataDir, instanceLockFileName)
+}
+
+// acquireInstanceLock locks the data directory. If another instance holds the lock an
+// InstanceRunningError describing it is returned.
+func acquireInstanceLock(dataDir string) (*instanceLock, error) {
+	path := instanceLockPath(dataDir)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %w", err)
+	}
+	if err := filelock.TryLock(file); err != nil {
+		file.Close()
+		var info InstanceInfo
+		data, readErr := os.ReadFile(path)
+		if readErr == nil && json.Unmarshal(data, &info) == nil {
+			return nil, &InstanceRunningError{Info: info}
+		}
+		return nil, fmt.Errorf("%w: %w", errInstanceRunning, err)
+	}
+
+	lock := &instanceLock{file: file, info: InstanceInfo{PID: os.Getpid(), Started: time.Now()}}
+	if err := lock.write(); err != nil {
+		lock.release()
+		return nil, err
+	}
+	return lock, nil
+}
+
+// probeInstance returns the info of the instance that holds the lock of a data directory,
+// false if no instance does. Unlike acquireInstanceLock nothing is written to the lock
+// file.
+func probeInstance(dataDir string) (InstanceInfo, bool) {
+	path := instanceLockPath(dataDir)
+	file, err := os.Open(path)
+	if err != nil {
+		return InstanceInfo{}, false
+	}
+	defer file.Close()
+	if err := filelock.TryLock(file); err == nil {
+		filelock.Unlock(file)
+		return InstanceInfo{}, false
+	}
+	var info InstanceInfo
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &info) != nil {
+		return InstanceInfo{}, false
+	}
+	return info, true
+}
+
+// write replaces the contents of the lock file with the info of this instance, the
+// file is written in place since replacing it would lose the lock.
+func (l *instanceLock) write() error {
+	data, err := json.MarshalIndent(l.info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling lock file: %w", err)
+	}
+	if err := l.file.Truncate(0); err != nil {
+		return fmt.Errorf("error writing lock file: %w", err)
+	}
+	if _, err := l.file.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("error writing lock file: %w", err)
+	}
+	return nil
+}
+
+// serve starts a server on the loopback interface that other instances find through the
+// lock file. Requests without the token of this instance are rejected.
+func (l *instanceLock) serve(handler http.Handler) error {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return fmt.Errorf("error creating instance token: %w", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("error starting instance server: %w", err)
+	}
+
+	l.info.Address = listener.Addr().String()
+	l.info.Token = hex.EncodeToString(token)
+	checkToken := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(instanceTokenHeader)), []byte(l.info.Token)) != 1 {
+			http.Error(w, "invalid token", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+	l.server = &http.Server{Handler: checkToken, ReadHeaderTimeout: 5 * time.Second}
+	go l.server.Serve(listener)
+	return l.write()
+}
+
+// handoffHandler lets later instances hand off to this one instead of exiting with an
+// error. show is called when an instance asks for the window to be shown.
+func handoffHandler(show func()) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /show", func(w http.ResponseWriter, r *http.Request) {
+		show()
+	})
+	return mux
+}
+
+// release stops the instance server and unlocks the data directory.
+func (l *instanceLock) release() {
+	if l.server != nil {
+		l.server.Close()
+	}
+	// The file is emptied first so it never describes an instance that has exited.
+	l.file.Truncate(0)
+	l.file.Close()
+}
+
+// handoff asks the running instance to show its window.
+func (info InstanceInfo) handoff() error {
+	if info.Address == "" {
+		return fmt.Errorf("the running instance does not accept handoffs")
+	}
+	request, err := http.NewRequest(http.MethodPost, "http://"+info.Address+"/show", nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set(instanceTokenHeader, info.Token)
+	client := &http.Client{Timeout: 5 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error contacting the running instance: %w", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("error contacting the running instance: %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+	"ryn-cx/i-saw-that/pkg/watcher/watchertest"
+)
+
+func TestInstanceLock(t *testing.T) {
+	t.Parallel()
+	dataDir := t.TempDir()
+	lock, err := acquireInstanceLock(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to lock data directory: %v", err)
+	}
+	shown := make(chan struct{}, 1)
+	if err := lock.serve(handoffHandler(func() { shown <- struct{}{} })); err != nil {
+		t.Fatalf("Failed to start handoff server: %v", err)
+	}
+
+	_, err = acquireInstanceLock(dataDir)
+	var running *InstanceRunningError
+	if !errors.As(err, &running) || !errors.Is(err, errInstanceRunning) {
+		t.Fatalf("Expected an InstanceRunningError, got %v", err)
+	}
+	if running.Info.PID != os.Getpid() {
+		t.Errorf("Expected the process ID %d of the running instance, got %d", os.Getpid(), running.Info.PID)
+	}
+
+	// A second instance hands off to the running one.
+	if err := running.Info.handoff(); err != nil {
+		t.Fatalf("Failed to hand off: %v", err)
+	}
+	select {
+	case <-shown:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the running instance to show its window")
+	}
+	running.Info.Token = "wrong"
+	if err := running.Info.handoff(); err == nil {
+		t.Errorf("Expected a handoff with the wrong token to fail")
+	}
+
+	lock.release()
+	lock, err = acquireInstanceLock(dataDir)
+	if err != nil {
+		t.Fatalf("Expected the lock to be free after it was released: %v", err)
+	}
+	lock.release()
+}
+
+func TestCLICommandsWhileInstanceRuns(t *testing.T) {
+	t.Parallel()
+	tempConfig := watchertest.DefaultTempWatcherConfig(t)
+	options := writeCLIConfig(t, tempConfig, watcher.WatcherConfig{Retention: watcher.RetentionPolicy{KeepLast: 1}})
+	options.DataDir = t.TempDir()
+	lock, err := acquireInstanceLock(options.DataDir)
+	if err != nil {
+		t.Fatalf("Failed to lock data directory: %v", err)
+	}
+	defer lock.release()
+
+	// Commands rely on the lock of the metadata instead of the data directory.
+	var stdout, stderr bytes.Buffer
+	if code := runCLI(options, []string{"prune", tempConfig.Name}, strings.NewReader(""), &stdout, &stderr); code == exitInstanceRunning {
+		t.Errorf("Expected prune to run while another instance runs: %s", stderr.String())
+	}
+}
+
+func TestProbeInstance(t *testing.T) {
+	t.Parallel()
+	dataDir := t.TempDir()
+	if _, running := probeInstance(dataDir); running {
+		t.Fatalf("Expected no running instance")
+	}
+	lock, err := acquireInstanceLock(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to lock data directory: %v", err)
+	}
+	defer lock.release()
+	before, err := os.ReadFile(instanceLockPath(dataDir))
+	if err != nil {
+		t.Fatalf("Failed to read lock file: %v", err)
+	}
+
+	info, running := probeInstance(dataDir)
+	if !running || info.PID != os.Getpid() {
+		t.Fatalf("Expected the running instance with process ID %d, got %+v", os.Getpid(), info)
+	}
+	if after, err := os.ReadFile(instanceLockPath(dataDir)); err != nil || !bytes.Equal(before, after) {
+		t.Errorf("Expected probing not to change the lock file: %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+// Package filelock locks files across processes, the locks are released by the
+// operating system when the process exits.
+package filelock
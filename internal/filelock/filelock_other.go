@@ -0,0 +1,28 @@
+//go:build !windows
+
+package filelock
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// TryLock takes an exclusive flock on the file without waiting for it.
+func TryLock(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+}
+
+// Lock takes a flock on the file, waiting for other processes to release theirs.
+func Lock(file *os.File, exclusive bool) error {
+	how := unix.LOCK_SH
+	if exclusive {
+		how = unix.LOCK_EX
+	}
+	return unix.Flock(int(file.Fd()), how)
+}
+
+// Unlock releases a lock taken with TryLock or Lock.
+func Unlock(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_UN)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+)
+
+var errNoKeyring = errors.New("signing keys need a data directory")
+
+// loadKeyring reads the signing keys from the data directory, nil is returned without
+// a data directory.
+func loadKeyring(options *Options) (*watcher.Keyring, error) {
+	if options.DataDir == "" {
+		return nil, nil
+	}
+	return watcher.LoadKeyring(options.DataDir)
+}
+
+// GenerateSigningKey creates a new key that backups are signed with and returns its ID,
+// the key it replaces stays trusted
+func (a *App) GenerateSigningKey() (string, error) {
+	if a.remote != nil {
+		return callDaemon[string](a.remote, "GenerateSigningKey")
+	}
+	if a.keyring == nil {
+		return "", errNoKeyring
+	}
+	return a.keyring.GenerateKey()
+}
+
+// GetSigningPublicKey returns the public key of this installation, to be trusted on
+// another installation
+func (a *App) GetSigningPublicKey() (string, error) {
+	if a.remote != nil {
+		return callDaemon[string](a.remote, "GetSigningPublicKey")
+	}
+	if a.keyring == nil {
+		return "", errNoKeyring
+	}
+	return a.keyring.PublicKey()
+}
+
+// GetTrustedKeys returns the key of this installation and the other trusted keys
+func (a *App) GetTrustedKeys() ([]watcher.TrustedKey, error) {
+	if a.remote != nil {
+		return callDaemon[[]watcher.TrustedKey](a.remote, "GetTrustedKeys")
+	}
+	if a.keyring == nil {
+		return nil, errNoKeyring
+	}
+	return a.keyring.TrustedKeys(), nil
+}
+
+// TrustSigningKey accepts backups signed by the public key of another installation and
+// returns its ID
+func (a *App) TrustSigningKey(publicKey string) (string, error) {
+	if a.remote != nil {
+		return callDaemon[string](a.remote, "TrustSigningKey", publicKey)
+	}
+	if a.keyring == nil {
+		return "", errNoKeyring
+	}
+	return a.keyring.TrustKey(publicKey)
+}
+
+// UntrustSigningKey stops accepting backups signed by a key
+func (a *App) UntrustSigningKey(id string) error {
+	if a.remote != nil {
+		return a.remote.call("UntrustSigningKey", nil, id)
+	}
+	if a.keyring == nil {
+		return errNoKeyring
+	}
+	if err := a.keyring.UntrustKey(id); err != nil {
+		return fmt.Errorf("error removing key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+// loginItem starts the app when the user logs in. Each profile has its own item so
+// profiles can be started at login separately.
+type loginItem struct {
+	// Name of the item, such as i-saw-that or i-saw-that-work for the work profile.
+	name string
+	// Executable and the arguments it is started with.
+	command []string
+	// Folder the item is written to, empty for the default of the platform. Windows
+	// keeps the items in the registry so it is not used there.
+	dir string
+}
+
+// loginItem returns the item that starts the app with the current profile.
+func (o *Options) loginItem() (loginItem, error) {
+	executable, err := o.executablePath()
+	if err != nil {
+		return loginItem{}, err
+	}
+	item := loginItem{name: "i-saw-that", command: []string{executable}, dir: o.loginItemDir}
+	if o.Profile != "" {
+		item.name += "-" + o.Profile
+		item.command = append(item.command, "--profile", o.Profile)
+	}
+	return item, nil
+}
@@ -0,0 +1,78 @@
+//go:build darwin
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// path returns the LaunchAgent of the item, launchd loads it when the user logs in.
+func (item loginItem) path() (string, error) {
+	dir := item.dir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error finding home folder: %w", err)
+		}
+		dir = filepath.Join(home, "Library", "LaunchAgents")
+	}
+	return filepath.Join(dir, item.label()+".plist"), nil
+}
+
+func (item loginItem) label() string {
+	return "com.ryn-cx." + item.name
+}
+
+func (item loginItem) set(enabled bool) error {
+	path, err := item.path()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing login item: %w", err)
+		}
+		return nil
+	}
+
+	escape := func(s string) string {
+		var buffer bytes.Buffer
+		xml.EscapeText(&buffer, []byte(s))
+		return buffer.String()
+	}
+	var plist bytes.Buffer
+	plist.WriteString(xml.Header)
+	plist.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	plist.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	fmt.Fprintf(&plist, "\t<key>Label</key>\n\t<string>%s</string>\n", escape(item.label()))
+	plist.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range item.command {
+		fmt.Fprintf(&plist, "\t\t<string>%s</string>\n", escape(arg))
+	}
+	plist.WriteString("\t</array>\n\t<key>RunAtLoad</key>\n\t<true/>\n</dict>\n</plist>\n")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error adding login item: %w", err)
+	}
+	if err := os.WriteFile(path, plist.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error adding login item: %w", err)
+	}
+	return nil
+}
+
+func (item loginItem) installed() (bool, error) {
+	path, err := item.path()
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,86 @@
+//go:build !windows && !darwin
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// path returns the desktop entry of the item in the XDG autostart folder, desktop
+// environments start the entries in it when the user logs in.
+func (item loginItem) path() (string, error) {
+	dir := item.dir
+	if dir == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("error finding config folder: %w", err)
+		}
+		dir = filepath.Join(configDir, "autostart")
+	}
+	return filepath.Join(dir, item.name+".desktop"), nil
+}
+
+func (item loginItem) set(enabled bool) error {
+	path, err := item.path()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing login item: %w", err)
+		}
+		return nil
+	}
+
+	args := make([]string, len(item.command))
+	for i, arg := range item.command {
+		args[i] = quoteDesktopExecArg(arg)
+	}
+	entry := "[Desktop Entry]\nType=Application\nName=I Saw That\nExec=" + strings.Join(args, " ") + "\nX-GNOME-Autostart-enabled=true\n"
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error adding login item: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(entry), 0644); err != nil {
+		return fmt.Errorf("error adding login item: %w", err)
+	}
+	return nil
+}
+
+func (item loginItem) installed() (bool, error) {
+	path, err := item.path()
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// quoteDesktopExecArg quotes an argument for the Exec key of a desktop entry. The
+// quoting rules of the Exec key are applied first and then the escapes of string
+// values, so a backslash becomes four backslashes.
+func quoteDesktopExecArg(arg string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for _, char := range arg {
+		switch char {
+		case '\\':
+			quoted.WriteString(`\\\\`)
+		case '"', '`', '$':
+			quoted.WriteString(`\\`)
+			quoted.WriteRune(char)
+		case '%':
+			quoted.WriteString("%%")
+		default:
+			quoted.WriteRune(char)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
@@ -0,0 +1,20 @@
+//go:build !windows && !darwin
+
+package main
+
+import "testing"
+
+func TestQuoteDesktopExecArg(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"/usr/bin/i-saw-that": `"/usr/bin/i-saw-that"`,
+		`/home/a b/$HOME`:     `"/home/a b/\\$HOME"`,
+		`C:\x`:                `"C:\\\\x"`,
+		"100%":                `"100%%"`,
+	}
+	for arg, expected := range cases {
+		if quoted := quoteDesktopExecArg(arg); quoted != expected {
+			t.Errorf("Expected %s to be quoted as %s, got %s", arg, expected, quoted)
+		}
+	}
+}
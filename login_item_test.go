@@ -0,0 +1,54 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoginItem(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	options := &Options{Profile: "work", executable: "/opt/I Saw That/i-saw-that", loginItemDir: dir}
+	item, err := options.loginItem()
+	if err != nil {
+		t.Fatalf("Failed to create login item: %v", err)
+	}
+
+	if installed, err := item.installed(); err != nil || installed {
+		t.Fatalf("Expected no login item, got %t: %v", installed, err)
+	}
+	if err := item.set(true); err != nil {
+		t.Fatalf("Failed to add login item: %v", err)
+	}
+	if installed, err := item.installed(); err != nil || !installed {
+		t.Fatalf("Expected the login item to be added, got %t: %v", installed, err)
+	}
+	path, _ := item.path()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read login item: %v", err)
+	}
+	if filepath.Dir(path) != dir || !strings.Contains(path, "i-saw-that-work") {
+		t.Errorf("Expected a login item for the profile in %s, got %s", dir, path)
+	}
+	for _, expected := range []string{"I Saw That/i-saw-that", "--profile", "work"} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected the login item to contain %s:\n%s", expected, data)
+		}
+	}
+
+	if err := item.set(false); err != nil {
+		t.Fatalf("Failed to remove login item: %v", err)
+	}
+	if installed, err := item.installed(); err != nil || installed {
+		t.Errorf("Expected the login item to be removed, got %t: %v", installed, err)
+	}
+	// Removing it again is not an error.
+	if err := item.set(false); err != nil {
+		t.Errorf("Expected removing a missing login item to succeed: %v", err)
+	}
+}
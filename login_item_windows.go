@@ -0,0 +1,50 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Registry key with the programs Windows starts when the user logs in.
+const runKeyPath = `Software\Microsoft\Windows\CurrentVersion\Run`
+
+// set adds or removes the item from the Run key of the current user.
+func (item loginItem) set(enabled bool) error {
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKeyPath, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("error opening Run key: %w", err)
+	}
+	defer key.Close()
+
+	if !enabled {
+		if err := key.DeleteValue(item.name); err != nil && !errors.Is(err, registry.ErrNotExist) {
+			return fmt.Errorf("error removing login item: %w", err)
+		}
+		return nil
+	}
+	if err := key.SetStringValue(item.name, windows.ComposeCommandLine(item.command)); err != nil {
+		return fmt.Errorf("error adding login item: %w", err)
+	}
+	return nil
+}
+
+func (item loginItem) installed() (bool, error) {
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKeyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return false, fmt.Errorf("error opening Run key: %w", err)
+	}
+	defer key.Close()
+
+	if _, _, err := key.GetStringValue(item.name); err != nil {
+		if errors.Is(err, registry.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error reading login item: %w", err)
+	}
+	return true, nil
+}
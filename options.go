@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+)
+
+// Environment variables that can be used in place of the command line flags. Flags
+// take priority over environment variables.
+const (
+	envConfig       = "ISAWTHAT_CONFIG"
+	envConfigFormat = "ISAWTHAT_CONFIG_FORMAT"
+	envLogLevel     = "ISAWTHAT_LOG_LEVEL"
+	envDataDir      = "ISAWTHAT_DATA_DIR"
+	envProfile      = "ISAWTHAT_PROFILE"
+	// Set to any value to disable the update check when the GUI starts.
+	envNoUpdateCheck = "ISAWTHAT_NO_UPDATE_CHECK"
+)
+
+// Profile names are used as directory names so they are limited to characters that
+// are valid on every filesystem.
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Options that control where the app stores its files and how much it logs.
+type Options struct {
+	// Path to the config file, if empty a config file is searched for in DataDir.
+	ConfigPath string
+	// Format of the config file, if empty it is detected from the file extension.
+	ConfigFormat string
+	// Minimum level of messages written to the standard log.
+	LogLevel string
+	// Directory used for the config file and any other state the app keeps. When a
+	// profile is selected this is the directory of that profile.
+	DataDir string
+	// Name of the profile to use, if empty the default profile is used.
+	Profile string
+	// Do not check for a new release when the GUI starts.
+	NoUpdateCheck bool
+
+	// Directory that contains the named profiles.
+	profilesDir string
+	// Release API and executable used to update the app and start it at login,
+	// replaced in tests.
+	releaseURL string
+	executable string
+	// Folder the login item is written to, replaced in tests.
+	loginItemDir string
+	// Keychain secrets are kept in, the keychain of the OS if nil. Replaced in tests.
+	keychain watcher.Keychain
+}
+
+// parseOptions parses the command line flags and environment variables. Any arguments
+// left over after the flags are returned so they can be used as commands.
+func parseOptions(args []string) (*Options, []string, error) {
+	options := &Options{}
+
+	flags := flag.NewFlagSet("i-saw-that", flag.ContinueOnError)
+	flags.StringVar(&options.ConfigPath, "config", os.Getenv(envConfig), "path to the config file")
+	flags.StringVar(&options.ConfigFormat, "config-format", os.Getenv(envConfigFormat), "config file format (json, yaml, toml)")
+	flags.StringVar(&options.LogLevel, "log-level", os.Getenv(envLogLevel), "minimum log level (debug, info, warn, error)")
+	flags.StringVar(&options.DataDir, "data-dir", os.Getenv(envDataDir), "directory for the config file and app state")
+	flags.StringVar(&options.Profile, "profile", os.Getenv(envProfile), "name of the profile to use")
+	flags.BoolVar(&options.NoUpdateCheck, "no-update-check", os.Getenv(envNoUpdateCheck) != "", "do not check for updates when the GUI starts")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if err := options.validate(); err != nil {
+		return nil, nil, err
+	}
+	return options, flags.Args(), nil
+}
+
+// Validate the options and fill in the defaults for any that were not provided.
+func (o *Options) validate() error {
+	if _, err := watcher.ParseLogLevel(o.LogLevel); err != nil {
+		return err
+	}
+
+	if o.ConfigFormat != "" {
+		if _, err := parseConfigFormat(o.ConfigFormat); err != nil {
+			return err
+		}
+	}
+
+	if o.DataDir == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			watcher.Logf("", watcher.LogLevelError, "Error getting config dir: %v", err)
+			configDir = "."
+		}
+		o.DataDir = filepath.Join(configDir, "i-saw-that")
+	}
+
+	// Each profile has its own data dir so every file the app keeps is separate between
+	// profiles. The default profile uses the data dir directly.
+	o.profilesDir = filepath.Join(o.DataDir, "profiles")
+	if o.Profile != "" {
+		if !profileNamePattern.MatchString(o.Profile) {
+			return fmt.Errorf("invalid profile name: %s", o.Profile)
+		}
+		o.DataDir = filepath.Join(o.profilesDir, o.Profile)
+	}
+
+	if err := os.MkdirAll(o.DataDir, 0755); err != nil {
+		return fmt.Errorf("error creating data dir: %w", err)
+	}
+
+	if o.ConfigPath == "" {
+		o.ConfigPath = findConfigFile(o.DataDir)
+	}
+	return nil
+}
+
+// executablePath returns the path of the running executable with symlinks resolved.
+func (o *Options) executablePath() (string, error) {
+	if o.executable != "" {
+		return o.executable, nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("error finding executable: %w", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return "", fmt.Errorf("error finding executable: %w", err)
+	}
+	return executable, nil
+}
+
+// updateSource returns the release API and the executable that updates replace.
+func (o *Options) updateSource() (string, string, error) {
+	url := o.releaseURL
+	if url == "" {
+		url = latestReleaseURL
+	}
+	executable, err := o.executablePath()
+	if err != nil {
+		return "", "", err
+	}
+	return url, executable, nil
+}
+
+// crashDumpDir returns the folder the watchers write crash dumps to, empty if there is
+// no data directory.
+func (o *Options) crashDumpDir() string {
+	if o.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(o.DataDir, "crashes")
+}
+
+// osKeychain returns the keychain secrets and credentials are kept in.
+func (o *Options) osKeychain() watcher.Keychain {
+	if o.keychain == nil {
+		return watcher.OSKeychain()
+	}
+	return o.keychain
+}
+
+// configFormat returns the format set by the options or the format detected from the
+// config file extension.
+func (o *Options) configFormat() ConfigFormat {
+	if format, err := parseConfigFormat(o.ConfigFormat); err == nil {
+		return format
+	}
+	return configFormatFromPath(o.ConfigPath)
+}
+
+// listProfiles returns the names of the named profiles that have been created.
+func (o *Options) listProfiles() ([]string, error) {
+	entries, err := os.ReadDir(o.profilesDir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading profiles directory: %w", err)
+	}
+
+	profiles := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() && profileNamePattern.MatchString(entry.Name()) {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFlagsOverrideEnvironment(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv(envDataDir, dataDir)
+	t.Setenv(envLogLevel, "error")
+	t.Setenv(envConfig, "")
+
+	options, args, err := parseOptions([]string{"--log-level", "warn", "status"})
+	if err != nil {
+		t.Fatalf("Failed to parse options: %v", err)
+	}
+
+	if options.LogLevel != "warn" {
+		t.Errorf("Expected log level 'warn', got '%s'", options.LogLevel)
+	}
+	if options.DataDir != dataDir {
+		t.Errorf("Expected data dir '%s', got '%s'", dataDir, options.DataDir)
+	}
+	if options.ConfigPath != filepath.Join(dataDir, "config.json") {
+		t.Errorf("Expected config in the data dir, got '%s'", options.ConfigPath)
+	}
+	if len(args) != 1 || args[0] != "status" {
+		t.Errorf("Expected remaining args [status], got %v", args)
+	}
+}
+
+func TestInvalidLogLevel(t *testing.T) {
+	t.Setenv(envDataDir, t.TempDir())
+
+	if _, _, err := parseOptions([]string{"--log-level", "loud"}); err == nil {
+		t.Errorf("Expected an error for an invalid log level")
+	}
+}
+
+func TestProfilesHaveSeparateDataDirs(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv(envDataDir, dataDir)
+	t.Setenv(envConfig, "")
+
+	for _, profile := range []string{"work", "home"} {
+		options, _, err := parseOptions([]string{"--profile", profile})
+		if err != nil {
+			t.Fatalf("Failed to parse options: %v", err)
+		}
+		expected := filepath.Join(dataDir, "profiles", profile, "config.json")
+		if options.ConfigPath != expected {
+			t.Errorf("Expected config path '%s', got '%s'", expected, options.ConfigPath)
+		}
+	}
+
+	options, _, err := parseOptions(nil)
+	if err != nil {
+		t.Fatalf("Failed to parse options: %v", err)
+	}
+	profiles, err := options.listProfiles()
+	if err != nil {
+		t.Fatalf("Failed to list profiles: %v", err)
+	}
+	if len(profiles) != 2 || profiles[0] != "home" || profiles[1] != "work" {
+		t.Errorf("Expected profiles [home work], got %v", profiles)
+	}
+
+	if _, _, err := parseOptions([]string{"--profile", "../escape"}); err == nil {
+		t.Errorf("Expected an error for an invalid profile name")
+	}
+}
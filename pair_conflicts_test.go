@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+	"ryn-cx/i-saw-that/pkg/watcher/watchertest"
+)
+
+func TestAddFolderPairWithSharedDestination(t *testing.T) {
+	t.Parallel()
+	temp := watchertest.DefaultTempWatcherConfig(t)
+	app := NewApp(&Options{ConfigPath: filepath.Join(temp.TempPath, "config.json")})
+	app.config = []*watcher.WatcherConfig{{ID: "existing", Source: filepath.Join(temp.TempPath, "other"), Destination: temp.Destination}}
+
+	err := app.AddFolderPair(temp.Source, temp.Destination, "1s", temp.FolderFormat)
+	var conflictErr *watcher.PairConflictError
+	if !errors.As(err, &conflictErr) || len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].Kind != watcher.PairConflictSharedDestination {
+		t.Fatalf("Expected a shared destination error, got %v", err)
+	}
+	if len(app.config) != 1 {
+		t.Errorf("Expected the folder pair to not be added")
+	}
+
+	conflicts := app.CheckFolderPair("", temp.Source, temp.Destination)
+	if len(conflicts) != 1 || !conflicts[0].Blocking {
+		t.Errorf("Expected CheckFolderPair to report the conflict, got %+v", conflicts)
+	}
+}
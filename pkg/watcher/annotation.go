@@ -0,0 +1,28 @@
+package watcher
+
+// AnnotateBackup stores a note with a backup, such as what was about to change when it
+// was made. An empty note removes it.
+func (w *Watcher) AnnotateBackup(backupID, note string) error {
+	return w.updateBackup(backupID, func(backup *Backup) {
+		backup.Note = note
+	})
+}
+
+// updateBackup changes a backup in the metadata and saves the metadata of its
+// destination.
+func (w *Watcher) updateBackup(backupID string, update func(backup *Backup)) error {
+	backup, err := w.FindBackup(backupID)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	path := w.BackupPath(backup)
+	for i := range w.Metadata {
+		if w.BackupPath(w.Metadata[i]) == path {
+			update(&w.Metadata[i])
+		}
+	}
+	w.mu.Unlock()
+	return w.saveMetadata(backup.Destination)
+}
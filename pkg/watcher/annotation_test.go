@@ -0,0 +1,40 @@
+package watcher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAnnotateBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file.txt", 10)
+	watcher.CreateBackup()
+
+	if err := watcher.AnnotateBackup("#1", "Before installing mod X"); err != nil {
+		t.Fatalf("Failed to annotate backup: %v", err)
+	}
+	if err := watcher.AnnotateBackup("missing", "note"); !errors.Is(err, ErrorBackupNotFound) {
+		t.Errorf("Expected a missing backup to be reported, got %v", err)
+	}
+
+	// The note is saved with the metadata.
+	onDisk, err := loadDestinationMetadata(WatcherConfig.Destination)
+	if err != nil {
+		t.Fatalf("Failed to load metadata: %v", err)
+	}
+	if len(onDisk) != 1 || onDisk[0].Note != "Before installing mod X" {
+		t.Errorf("Expected the note in the metadata file, got %+v", onDisk)
+	}
+
+	if err := watcher.AnnotateBackup("#1", ""); err != nil {
+		t.Fatalf("Failed to remove note: %v", err)
+	}
+	if backups := watcher.ListBackups(); backups[0].Note != "" {
+		t.Errorf("Expected the note to be removed, got %q", backups[0].Note)
+	}
+}
@@ -0,0 +1,539 @@
+package watcher
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrorBackupNotFound = fmt.Errorf("backup not found")
+var ErrorInvalidArchive = fmt.Errorf("invalid archive")
+
+type ArchiveFormat string
+
+const (
+	ArchiveFormatZip   ArchiveFormat = "zip"
+	ArchiveFormatTar   ArchiveFormat = "tar"
+	ArchiveFormatTarGz ArchiveFormat = "tar.gz"
+)
+
+// parseArchiveFormat converts a format name into an ArchiveFormat. An empty format is
+// detected from the extension of path.
+func parseArchiveFormat(format string, path string) (ArchiveFormat, error) {
+	if format == "" {
+		lower := strings.ToLower(path)
+		switch {
+		case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+			return ArchiveFormatTarGz, nil
+		case strings.HasSuffix(lower, ".tar"):
+			return ArchiveFormatTar, nil
+		case strings.HasSuffix(lower, ".zip"):
+			return ArchiveFormatZip, nil
+		}
+		return "", fmt.Errorf("could not detect archive format of %s", path)
+	}
+
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "zip":
+		return ArchiveFormatZip, nil
+	case "tar":
+		return ArchiveFormatTar, nil
+	case "tar.gz", "tgz":
+		return ArchiveFormatTarGz, nil
+	}
+	return "", fmt.Errorf("unsupported archive format: %s", format)
+}
+
+// FindBackup returns the backup with the given ID, the ID of a backup is its path or
+// sequence number.
+func (w *Watcher) FindBackup(backupID string) (Backup, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if backup, ok := FindBackupByID(w.Metadata, backupID); ok {
+		return backup, nil
+	}
+	return Backup{}, fmt.Errorf("%w: %s", ErrorBackupNotFound, backupID)
+}
+
+// ExportBackup writes a backup to a standalone zip or tar archive. If format is empty
+// it is detected from the extension of targetPath. Zip archives only keep modification
+// times to the second.
+func (w *Watcher) ExportBackup(backupID, targetPath string, format ArchiveFormat) error {
+	backup, err := w.FindBackup(backupID)
+	if err != nil {
+		return err
+	}
+	format, err = parseArchiveFormat(string(format), targetPath)
+	if err != nil {
+		return err
+	}
+
+	// The archive is written to a temporary file first so a failed export never leaves
+	// a partial archive behind.
+	file, err := os.CreateTemp(filepath.Dir(targetPath), ".i-saw-that-export-*")
+	if err != nil {
+		return fmt.Errorf("error creating archive: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	root := w.BackupPath(backup)
+	if backup.ResticSnapshot != "" {
+		if root, err = w.extractResticBackup(backup); err != nil {
+			return err
+		}
+		defer os.RemoveAll(root)
+	}
+	// Chunked files are put back together so the archive stands on its own.
+	chunks := backupChunks(backup)
+	switch format {
+	case ArchiveFormatZip:
+		err = writeZip(root, file, chunks)
+	case ArchiveFormatTar:
+		err = writeTar(root, file, chunks)
+	case ArchiveFormatTarGz:
+		gzipWriter := gzip.NewWriter(file)
+		err = errors.Join(writeTar(root, gzipWriter, chunks), gzipWriter.Close())
+	}
+	if err := errors.Join(err, file.Close()); err != nil {
+		return fmt.Errorf("error writing archive: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), targetPath); err != nil {
+		return fmt.Errorf("error writing archive: %w", err)
+	}
+	Logf(w.Name, LogLevelInfo, "Exported backup %s to %s", backup.Path, targetPath)
+	return nil
+}
+
+// ImportBackup extracts an archive created by ExportBackup and registers it as a
+// backup. The time of the backup is read from its sidecar if it has one.
+func (w *Watcher) ImportBackup(archivePath string) (Backup, error) {
+	format, err := parseArchiveFormat("", archivePath)
+	if err != nil {
+		return Backup{}, err
+	}
+	destination, err := w.nextDestination()
+	if err != nil {
+		return Backup{}, fmt.Errorf("error choosing destination: %w", err)
+	}
+
+	// Extract into a hidden folder so a failed import is never mistaken for a backup.
+	tempPath, err := os.MkdirTemp(destination, ".i-saw-that-import-*")
+	if err != nil {
+		return Backup{}, fmt.Errorf("error creating import folder: %w", err)
+	}
+	defer os.RemoveAll(tempPath)
+
+	switch format {
+	case ArchiveFormatZip:
+		err = extractZip(archivePath, tempPath)
+	default:
+		err = extractTarFile(archivePath, tempPath, format == ArchiveFormatTarGz)
+	}
+	if err != nil {
+		return Backup{}, fmt.Errorf("error extracting archive: %w", err)
+	}
+
+	w.mu.Lock()
+	folderFormat := w.FolderFormat
+	timeZone := w.TimeZone
+	w.mu.Unlock()
+
+	location, err := timeZone.location()
+	if err != nil {
+		return Backup{}, err
+	}
+	timestamp := w.clock.Now().In(location)
+	if sidecar, err := ReadBackupSidecar(tempPath); err == nil && !sidecar.Time.IsZero() {
+		timestamp = sidecar.Time.In(location)
+	}
+
+	// Imported backups are numbered when they are imported, not when they were made.
+	sequence, err := w.nextSequence()
+	if err != nil {
+		return Backup{}, err
+	}
+	backup := Backup{
+		Sequence:     sequence,
+		Timestamp:    float64(timestamp.Unix()) + float64(timestamp.Nanosecond())/1e9,
+		Path:         formatBackupFolder(folderFormat, timestamp, sequence),
+		FolderFormat: folderFormat,
+		Destination:  destination,
+		TimeZone:     backupTimeZone(timestamp),
+	}
+	backupPath := w.BackupPath(backup)
+	if _, err := os.Stat(backupPath); err == nil {
+		return Backup{}, fmt.Errorf("a backup already exists at %s", backupPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
+		return Backup{}, fmt.Errorf("error creating backup folder: %w", err)
+	}
+	if err := os.Rename(tempPath, backupPath); err != nil {
+		return Backup{}, fmt.Errorf("error moving imported backup: %w", err)
+	}
+
+	if w.ReadOnlyBackups {
+		if err := protectBackup(backupPath); err != nil {
+			Logf(w.Name, LogLevelError, "Error making backup read-only: %v", err)
+		}
+	}
+
+	// Imported backups can be older than existing ones so the metadata is sorted again.
+	w.mu.Lock()
+	w.Metadata = append(w.Metadata, backup)
+	sortBackups(w.Metadata)
+	w.recentBackups[backupPath] = w.clock.Now()
+	w.mu.Unlock()
+
+	if err := w.saveMetadata(destination); err != nil {
+		return backup, err
+	}
+	Logf(w.Name, LogLevelInfo, "Imported %s as backup %s", archivePath, backupPath)
+
+	w.notifyObservers()
+	return backup, nil
+}
+
+// archivePaths returns every path inside root relative to root using forward slashes.
+func archivePaths(root string) ([]string, error) {
+	paths, err := collectPaths(root)
+	if err != nil {
+		return nil, err
+	}
+
+	relPaths := []string{}
+	for _, path := range paths[1:] {
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil, err
+		}
+		relPaths = append(relPaths, filepath.ToSlash(relPath))
+	}
+	return relPaths, nil
+}
+
+func writeZip(root string, w io.Writer, chunks chunkedFiles) error {
+	relPaths, err := archivePaths(root)
+	if err != nil {
+		return err
+	}
+
+	zipWriter := zip.NewWriter(w)
+	for _, relPath := range relPaths {
+		path := filepath.Join(root, filepath.FromSlash(relPath))
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = relPath
+		if info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(writer, target); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if err := chunks.copyFileTo(relPath, path, writer); err != nil {
+				return err
+			}
+		}
+	}
+	return zipWriter.Close()
+}
+
+func writeTar(root string, w io.Writer, chunks chunkedFiles) error {
+	relPaths, err := archivePaths(root)
+	if err != nil {
+		return err
+	}
+
+	tarWriter := tar.NewWriter(w)
+	for _, relPath := range relPaths {
+		path := filepath.Join(root, filepath.FromSlash(relPath))
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = relPath
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if chunks.contains(relPath) {
+			manifest, err := readChunkManifest(path)
+			if err != nil {
+				return err
+			}
+			header.Size = manifest.Size
+		}
+		// PAX headers keep sub-second modification times.
+		header.Format = tar.FormatPAX
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			if err := chunks.copyFileTo(relPath, path, tarWriter); err != nil {
+				return err
+			}
+		}
+	}
+	return tarWriter.Close()
+}
+
+// copyFileTo writes the contents of the file at path, relPath inside the backup, to w.
+// Chunked files are reassembled.
+func (c chunkedFiles) copyFileTo(relPath, path string, w io.Writer) error {
+	if c.contains(relPath) {
+		return c.store.reassemble(path, w)
+	}
+	return copyFileTo(path, w)
+}
+
+func copyFileTo(path string, w io.Writer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// archiveExtractor creates the entries of an archive inside root. Symlinks are created
+// after everything else so files are never written through a symlink, and folder times
+// are set last since adding files changes them.
+type archiveExtractor struct {
+	root     string
+	symlinks map[string]string
+	dirTimes map[string]time.Time
+}
+
+func newArchiveExtractor(root string) *archiveExtractor {
+	return &archiveExtractor{
+		root:     root,
+		symlinks: map[string]string{},
+		dirTimes: map[string]time.Time{},
+	}
+}
+
+// path converts an archive entry name into a path inside root. Names that would end
+// up outside of root are rejected.
+func (e *archiveExtractor) path(name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(cleaned) || filepath.VolumeName(cleaned) != "" || cleaned == ".." ||
+		strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: entry %s is outside of the archive", ErrorInvalidArchive, name)
+	}
+	return filepath.Join(e.root, cleaned), nil
+}
+
+func (e *archiveExtractor) dir(name string, modTime time.Time) error {
+	path, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	e.dirTimes[path] = modTime
+	return nil
+}
+
+func (e *archiveExtractor) file(name string, mode fs.FileMode, modTime time.Time, r io.Reader) error {
+	path, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, mode.Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(path, modTime, modTime)
+}
+
+func (e *archiveExtractor) symlink(name, target string) error {
+	path, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	e.symlinks[path] = target
+	return nil
+}
+
+func (e *archiveExtractor) finish() error {
+	for path, target := range e.symlinks {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.Symlink(target, path); err != nil {
+			return err
+		}
+	}
+
+	dirs := make([]string, 0, len(e.dirTimes))
+	for path := range e.dirTimes {
+		dirs = append(dirs, path)
+	}
+	// Children before parents so setting a child's time does not change its parent.
+	slices.Sort(dirs)
+	slices.Reverse(dirs)
+	for _, path := range dirs {
+		if err := os.Chtimes(path, e.dirTimes[path], e.dirTimes[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZip(archivePath, root string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrorInvalidArchive, err)
+	}
+	defer reader.Close()
+
+	extractor := newArchiveExtractor(root)
+	for _, file := range reader.File {
+		mode := file.Mode()
+		switch {
+		case mode.IsDir():
+			err = extractor.dir(file.Name, file.Modified)
+		case mode&fs.ModeSymlink != 0:
+			var target []byte
+			if target, err = readZipFile(file); err == nil {
+				err = extractor.symlink(file.Name, string(target))
+			}
+		default:
+			var contents io.ReadCloser
+			if contents, err = file.Open(); err == nil {
+				err = errors.Join(extractor.file(file.Name, mode, file.Modified, contents), contents.Close())
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return extractor.finish()
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	contents, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer contents.Close()
+	return io.ReadAll(contents)
+}
+
+func extractTarFile(archivePath, root string, compressed bool) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if compressed {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrorInvalidArchive, err)
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	}
+
+	extractor := newArchiveExtractor(root)
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrorInvalidArchive, err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = extractor.dir(header.Name, header.ModTime)
+		case tar.TypeSymlink:
+			err = extractor.symlink(header.Name, header.Linkname)
+		case tar.TypeReg:
+			err = extractor.file(header.Name, header.FileInfo().Mode(), header.ModTime, tarReader)
+		default:
+			err = fmt.Errorf("%w: unsupported entry type for %s", ErrorInvalidArchive, header.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return extractor.finish()
+}
+
+// BackupFolder returns the folder a backup is stored in. Backups stored in restic have
+// no folder.
+func (w *Watcher) BackupFolder(backupID string) (string, error) {
+	backup, err := w.FindBackup(backupID)
+	if err != nil {
+		return "", err
+	}
+	if backup.ResticSnapshot != "" {
+		return "", fmt.Errorf("backup %s is stored in restic snapshot %s and has no folder", backup.Path, backup.ResticSnapshot)
+	}
+	path := w.BackupPath(backup)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("error reading backup: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,143 @@
+package watcher
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExportAndImportBackup(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"backup.zip", "backup.tar", "backup.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			WatcherConfig := DefaultTempWatcherConfig(t)
+			watcher, err := NewTempWatcher(WatcherConfig)
+			if err != nil {
+				t.Fatalf("Failed to create watcher: %v", err)
+			}
+			CreateDummyFile(t, WatcherConfig.Source, "file.txt", 1024)
+			CreateDummyFile(t, WatcherConfig.Source, "folder/nested.txt", 1024)
+			// Zip archives only store modification times to the second.
+			modTime := time.Now().Truncate(time.Second)
+			for _, path := range []string{"file.txt", "folder/nested.txt", "folder"} {
+				if err := os.Chtimes(filepath.Join(WatcherConfig.Source, path), modTime, modTime); err != nil {
+					t.Fatalf("Failed to change modification time: %v", err)
+				}
+			}
+			watcher.CreateBackup()
+			exported := watcher.Metadata[0]
+
+			archivePath := filepath.Join(WatcherConfig.TempPath, name)
+			if err := watcher.ExportBackup(exported.Path, archivePath, ""); err != nil {
+				t.Fatalf("Failed to export backup: %v", err)
+			}
+
+			// Import into a different folder pair.
+			otherConfig := DefaultTempWatcherConfig(t)
+			other, err := NewTempWatcher(otherConfig)
+			if err != nil {
+				t.Fatalf("Failed to create watcher: %v", err)
+			}
+			imported, err := other.ImportBackup(archivePath)
+			if err != nil {
+				t.Fatalf("Failed to import backup: %v", err)
+			}
+
+			if imported.Timestamp != exported.Timestamp || imported.Path != exported.Path {
+				t.Errorf("Expected imported backup %+v to match exported backup %+v", imported, exported)
+			}
+			CompareSourceAndDestination(t, WatcherConfig.Source, other.BackupPath(imported))
+
+			reloaded, err := NewTempWatcher(otherConfig)
+			if err != nil {
+				t.Fatalf("Failed to create watcher: %v", err)
+			}
+			if backups := reloaded.ListBackups(); len(backups) != 1 {
+				t.Errorf("Expected the imported backup to be saved in the metadata, got %d backups", len(backups))
+			}
+
+			// Importing the same backup twice would overwrite it.
+			if _, err := other.ImportBackup(archivePath); err == nil {
+				t.Errorf("Expected importing a duplicate backup to fail")
+			}
+		})
+	}
+}
+
+func TestExportMissingBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	err = watcher.ExportBackup("missing", filepath.Join(WatcherConfig.TempPath, "backup.zip"), ArchiveFormatZip)
+	if !errors.Is(err, ErrorBackupNotFound) {
+		t.Errorf("Expected ErrorBackupNotFound, got %v", err)
+	}
+}
+
+func TestImportRejectsPathTraversal(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	archivePath := filepath.Join(WatcherConfig.TempPath, "evil.zip")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	zipWriter := zip.NewWriter(file)
+	writer, err := zipWriter.Create("../escaped.txt")
+	if err != nil {
+		t.Fatalf("Failed to add file to archive: %v", err)
+	}
+	writer.Write([]byte("escaped"))
+	zipWriter.Close()
+	file.Close()
+
+	if _, err := watcher.ImportBackup(archivePath); !errors.Is(err, ErrorInvalidArchive) {
+		t.Errorf("Expected ErrorInvalidArchive, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(WatcherConfig.Destination, "escaped.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected the file outside of the archive to not be written")
+	}
+	if len(watcher.Metadata) != 0 {
+		t.Errorf("Expected no backups after a failed import")
+	}
+}
+
+func TestBackupFolder(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	backup, _ := watcher.createTriggeredBackup(BackupTriggerManual)
+
+	path, err := watcher.BackupFolder("#1")
+	if err != nil {
+		t.Fatalf("Failed to find backup folder: %v", err)
+	}
+	if expected := filepath.Join(WatcherConfig.Destination, filepath.FromSlash(backup.Path)); path != expected {
+		t.Errorf("Expected %s, got %s", expected, path)
+	}
+	if _, err := watcher.BackupFolder("2"); !errors.Is(err, ErrorBackupNotFound) {
+		t.Errorf("Expected ErrorBackupNotFound, got %v", err)
+	}
+
+	watcher.Metadata[0].ResticSnapshot = "abc123"
+	if _, err := watcher.BackupFolder("1"); err == nil {
+		t.Errorf("Expected an error for a backup stored in restic")
+	}
+}
@@ -0,0 +1,165 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	cp "github.com/otiai10/copy"
+)
+
+// Attribute is something about the files in the source that a destination may not be
+// able to keep.
+type Attribute string
+
+const (
+	// Symlinks can not be created, such as on exFAT or FAT drives.
+	AttributeSymlinks Attribute = "symlinks"
+	// Permissions can not be changed or are not kept.
+	AttributePermissions Attribute = "permissions"
+	// Modification times can not be set.
+	AttributeTimes Attribute = "times"
+	// Modification times are only kept to the second or coarser, such as on FAT drives.
+	AttributePreciseTimes Attribute = "precise_times"
+)
+
+// probeAttributes returns the attributes that can not be kept in dir, found by
+// creating a probe file and a symlink to it and changing the permissions and time of
+// the file.
+func probeAttributes(dir string) ([]Attribute, error) {
+	file, err := os.CreateTemp(dir, selfTestProbePrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("error writing probe file: %w", err)
+	}
+	path := file.Name()
+	file.Close()
+	defer os.Remove(path)
+
+	unsupported := []Attribute{}
+	link := path + "-link"
+	if err := os.Symlink(filepath.Base(path), link); err != nil {
+		unsupported = append(unsupported, AttributeSymlinks)
+	} else {
+		os.Remove(link)
+	}
+
+	// Windows only has a read-only flag in place of permissions.
+	if runtime.GOOS != "windows" {
+		const mode = 0640
+		if err := os.Chmod(path, mode); err != nil {
+			unsupported = append(unsupported, AttributePermissions)
+		} else if info, err := os.Stat(path); err != nil || info.Mode().Perm() != mode {
+			unsupported = append(unsupported, AttributePermissions)
+		}
+	}
+
+	modTime := time.Unix(1700000001, 123456789)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		unsupported = append(unsupported, AttributeTimes)
+	} else if info, err := os.Stat(path); err != nil || !info.ModTime().Equal(modTime) {
+		unsupported = append(unsupported, AttributePreciseTimes)
+	}
+	return unsupported, nil
+}
+
+// destinationAttributes returns the attributes destination can not keep. A destination
+// is probed once while the watcher runs and a warning is given the first time it can
+// not keep something.
+func (w *Watcher) destinationAttributes(destination string) []Attribute {
+	w.mu.Lock()
+	unsupported, probed := w.unsupportedAttributes[destination]
+	w.mu.Unlock()
+	if probed {
+		return unsupported
+	}
+
+	unsupported, err := probeAttributes(destination)
+	if err != nil {
+		// The copy reports the problem with the destination.
+		Logf(w.Name, LogLevelWarn, "Error checking what %s can store: %v", destination, err)
+		return nil
+	}
+	w.mu.Lock()
+	w.unsupportedAttributes[destination] = unsupported
+	w.mu.Unlock()
+	if len(unsupported) > 0 {
+		w.notifyWarning("Destination %s can not keep the %s of files, backups there are made without them", destination, formatAttributes(unsupported))
+	}
+	return unsupported
+}
+
+func formatAttributes(attributes []Attribute) string {
+	names := make([]string, len(attributes))
+	for i, attribute := range attributes {
+		names[i] = strings.ReplaceAll(string(attribute), "_", " ")
+	}
+	return strings.Join(names, ", ")
+}
+
+// degradedCopyOptions changes options so the copy skips what the destination can not
+// keep instead of failing on every file: symlinks are left out, and permissions and
+// times are not set.
+func degradedCopyOptions(options cp.Options, unsupported []Attribute) cp.Options {
+	if slices.Contains(unsupported, AttributeSymlinks) {
+		options.OnSymlink = func(string) cp.SymlinkAction {
+			return cp.Skip
+		}
+	}
+	if slices.Contains(unsupported, AttributePermissions) {
+		options.PermissionControl = cp.DoNothing
+	}
+	if slices.Contains(unsupported, AttributeTimes) {
+		options.PreserveTimes = false
+	}
+	return options
+}
+
+// withDegradation returns the copy engine that leaves out the attributes the
+// destination can not keep.
+func withDegradation(engine CopyEngine, unsupported []Attribute) CopyEngine {
+	if len(unsupported) == 0 {
+		return engine
+	}
+	switch e := engine.(type) {
+	case goCopyEngine:
+		e.unsupported = unsupported
+		return e
+	case rsyncCopyEngine:
+		e.unsupported = unsupported
+		return e
+	}
+	return engine
+}
+
+// rsyncDegradationArgs returns the rsync flags that leave out what the destination can
+// not keep.
+func rsyncDegradationArgs(unsupported []Attribute) []string {
+	args := []string{}
+	for _, attribute := range unsupported {
+		switch attribute {
+		case AttributeSymlinks:
+			args = append(args, "--no-links")
+		case AttributePermissions:
+			args = append(args, "--no-perms")
+		case AttributeTimes:
+			args = append(args, "--no-times")
+		case AttributePreciseTimes:
+			args = append(args, "--modify-window=1")
+		}
+	}
+	return args
+}
+
+// modTimesMatch returns true if a file in a backup has the modification time of the
+// source file, allowing for destinations that round times to two seconds. The contents
+// are compared separately.
+func modTimesMatch(source, backup time.Time) bool {
+	if source.Equal(backup) {
+		return true
+	}
+	return backup.Nanosecond() == 0 && source.Sub(backup).Abs() < 2*time.Second
+}
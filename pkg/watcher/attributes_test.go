@@ -0,0 +1,86 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProbeAttributes(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("Symlinks need developer mode on Windows")
+	}
+	dir := t.TempDir()
+	unsupported, err := probeAttributes(dir)
+	if err != nil {
+		t.Fatalf("Failed to probe: %v", err)
+	}
+	if len(unsupported) != 0 {
+		t.Errorf("Expected a temporary folder to keep everything, got %v", unsupported)
+	}
+	// The probe files are removed again.
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("Expected the folder to be empty, got %v: %v", entries, err)
+	}
+}
+
+func TestBackupWithoutSymlinks(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("Symlinks need developer mode on Windows")
+	}
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	if err := os.Symlink("file1.txt", filepath.Join(WatcherConfig.Source, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	// Like an exFAT drive, which can store neither.
+	unsupported := []Attribute{AttributeSymlinks, AttributePermissions}
+	watcher.unsupportedAttributes[WatcherConfig.Destination] = unsupported
+
+	backup, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatal("Failed to create backup")
+	}
+	if !slices.Equal(backup.Degraded, unsupported) {
+		t.Errorf("Expected the backup to record %v, got %v", unsupported, backup.Degraded)
+	}
+	if _, err := os.Lstat(filepath.Join(watcher.BackupPath(backup), "link")); !os.IsNotExist(err) {
+		t.Errorf("Expected the symlink to be left out, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(watcher.BackupPath(backup), "file1.txt")); err != nil {
+		t.Errorf("Expected the file to be backed up: %v", err)
+	}
+	// The missing symlink does not make the source look changed.
+	if matches, err := watcher.SourceMatchesLatestBackup(); err != nil || !matches {
+		t.Errorf("Expected the source to match the backup, got %t: %v", matches, err)
+	}
+}
+
+func TestModTimesMatch(t *testing.T) {
+	t.Parallel()
+	modTime := time.Unix(1700000001, 123456789)
+	tests := []struct {
+		backup  time.Time
+		matches bool
+	}{
+		{modTime, true},
+		// FAT rounds to two seconds.
+		{time.Unix(1700000000, 0), true},
+		{time.Unix(1700000004, 0), false},
+		{time.Unix(1700000001, 5), false},
+	}
+	for _, test := range tests {
+		if matches := modTimesMatch(modTime, test.backup); matches != test.matches {
+			t.Errorf("Expected %v to match %v: %t, got %t", test.backup, modTime, test.matches, matches)
+		}
+	}
+}
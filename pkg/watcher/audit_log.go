@@ -0,0 +1,63 @@
+package watcher
+
+import (
+	"slices"
+	"time"
+)
+
+// Most file events kept for one backup with AuditLog, later events are only counted so
+// a mass change can not grow the metadata without limit.
+const maxAuditEvents = 1000
+
+// FileEvent is a file event from the source that led to a backup.
+type FileEvent struct {
+	Time time.Time `json:"time"`
+	Path string    `json:"path"`
+	// Operation reported by the file watcher, such as "WRITE" or "REMOVE|RENAME".
+	Op string `json:"op"`
+}
+
+// auditLog is the file events received since the last backup.
+type auditLog struct {
+	events  []FileEvent
+	dropped int
+}
+
+// recordAuditEvent keeps a file event for the next backup if AuditLog is on.
+func (w *Watcher) recordAuditEvent(path, op string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.AuditLog {
+		return
+	}
+	if len(w.auditLog.events) >= maxAuditEvents {
+		w.auditLog.dropped++
+		return
+	}
+	w.auditLog.events = append(w.auditLog.events, FileEvent{Time: w.clock.Now(), Path: path, Op: op})
+}
+
+// takeAuditLog returns the events for a backup that is starting and starts a new log.
+// Must be called while holding w.mu.
+func (w *Watcher) takeAuditLog() auditLog {
+	log := w.auditLog
+	w.auditLog = auditLog{}
+	return log
+}
+
+// returnAuditLog puts the events of a backup that was not made back in front of the
+// events received since, so they are attached to the next backup instead.
+func (w *Watcher) returnAuditLog(log auditLog) {
+	if len(log.events) == 0 && log.dropped == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	events := slices.Concat(log.events, w.auditLog.events)
+	dropped := log.dropped + w.auditLog.dropped
+	if len(events) > maxAuditEvents {
+		dropped += len(events) - maxAuditEvents
+		events = events[:maxAuditEvents]
+	}
+	w.auditLog = auditLog{events: events, dropped: dropped}
+}
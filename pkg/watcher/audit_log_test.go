@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.AuditLog = true
+
+	path := filepath.Join(WatcherConfig.Source, "file.txt")
+	CreateDummyFile(t, WatcherConfig.Source, "file.txt", 10)
+	watcher.handleSourceEvent(path, "CREATE")
+	watcher.handleSourceEvent(path, "WRITE")
+	watcher.CreateBackup()
+	watcher.handleSourceEvent(path, "REMOVE")
+	watcher.CreateBackup()
+
+	if len(watcher.Metadata) != 2 {
+		t.Fatalf("Expected 2 backups, got %d", len(watcher.Metadata))
+	}
+	first := watcher.Metadata[0].Events
+	if len(first) != 2 || first[0].Op != "CREATE" || first[1].Op != "WRITE" || first[0].Path != path {
+		t.Errorf("Expected the create and write in the first backup, got %+v", first)
+	}
+	if second := watcher.Metadata[1].Events; len(second) != 1 || second[0].Op != "REMOVE" {
+		t.Errorf("Expected only the remove in the second backup, got %+v", second)
+	}
+}
+
+func TestReturnAuditLog(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.AuditLog = true
+
+	for range maxAuditEvents + 5 {
+		watcher.recordAuditEvent("old", "WRITE")
+	}
+	watcher.mu.Lock()
+	log := watcher.takeAuditLog()
+	watcher.mu.Unlock()
+	watcher.recordAuditEvent("new", "WRITE")
+
+	// The backup failed, its events go back in front and the newest event is dropped.
+	watcher.returnAuditLog(log)
+	events, dropped := watcher.auditLog.events, watcher.auditLog.dropped
+	if len(events) != maxAuditEvents || events[len(events)-1].Path != "old" || dropped != 6 {
+		t.Errorf("Expected %d old events and 6 dropped, got %d events and %d dropped", maxAuditEvents, len(events), dropped)
+	}
+}
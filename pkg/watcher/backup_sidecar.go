@@ -0,0 +1,209 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Name of the file written inside every backup folder that describes the backup.
+const backupSidecarName = "backup.json"
+
+// BackupTrigger is the reason a backup was created.
+type BackupTrigger string
+
+const (
+	// There were no previous backups.
+	BackupTriggerInitial BackupTrigger = "initial"
+	// The source changed while the watcher was not running.
+	BackupTriggerOutdated BackupTrigger = "outdated"
+	// File events were received for the source.
+	BackupTriggerChange BackupTrigger = "change"
+	// Polling found changes in the source.
+	BackupTriggerPoll BackupTrigger = "poll"
+	// The backup was requested directly.
+	BackupTriggerManual BackupTrigger = "manual"
+	// Safety backup of the source before a backup is restored over it.
+	BackupTriggerPreRestore BackupTrigger = "pre-restore"
+	// Safety backup of the source before another operation that can lose data.
+	BackupTriggerSafety BackupTrigger = "safety"
+	// Replaces a backup that was interrupted by a crash.
+	BackupTriggerRecovery BackupTrigger = "recovery"
+	// The program of the process trigger exited.
+	BackupTriggerProcessExit BackupTrigger = "process-exit"
+	// A large number of files changed at once.
+	BackupTriggerMassChange BackupTrigger = "mass-change"
+	// A canary file was touched.
+	BackupTriggerCanary BackupTrigger = "canary"
+)
+
+// backupName returns the name of backups made for the trigger, safety backups are
+// named so they stand out.
+func (t BackupTrigger) backupName() string {
+	switch t {
+	case BackupTriggerPreRestore:
+		return "Before restore"
+	case BackupTriggerSafety:
+		return "Safety backup"
+	case BackupTriggerMassChange:
+		return "Mass change"
+	case BackupTriggerCanary:
+		return "Canary touched"
+	}
+	return ""
+}
+
+// BackupSidecar is written to backup.json inside each backup folder so the backup
+// can still be identified if metadata.json is lost or the folder is copied elsewhere.
+type BackupSidecar struct {
+	Watcher      string        `json:"watcher"`
+	Timestamp    float64       `json:"timestamp"`
+	Time         time.Time     `json:"time"`
+	Trigger      BackupTrigger `json:"trigger"`
+	ManifestHash string        `json:"manifest_hash"`
+	// Algorithm the files were hashed with for the manifest, empty for SHA-256.
+	HashAlgorithm HashAlgorithm `json:"hash_algorithm,omitempty"`
+	ToolVersion   string        `json:"tool_version"`
+	Source        string        `json:"source"`
+	FolderFormat  string        `json:"folder_format"`
+	// True if a SHA256SUMS file was written next to the sidecar.
+	Checksums bool `json:"checksums,omitempty"`
+	// Base64 ed25519 signature of signedMessage and the ID of the key that made it,
+	// empty unless SignBackups is on.
+	Signature  string `json:"signature,omitempty"`
+	SigningKey string `json:"signing_key,omitempty"`
+}
+
+// generatedFiles returns the names of the files the watcher wrote into the top of the
+// backup folder along with the copy of the source.
+func (s BackupSidecar) generatedFiles() []string {
+	files := []string{backupSidecarName}
+	if s.Checksums {
+		files = append(files, checksumsName, checksumsSignatureName)
+	}
+	return files
+}
+
+// sourceHasSidecarName returns true if a folder source contains a file that would
+// collide with the sidecar.
+func sourceHasSidecarName(source string, singleFile bool) bool {
+	if singleFile {
+		return filepath.Base(source) == backupSidecarName
+	}
+	_, err := os.Lstat(filepath.Join(source, backupSidecarName))
+	return err == nil
+}
+
+func writeBackupSidecar(backupPath string, sidecar BackupSidecar) error {
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling backup sidecar: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(backupPath, backupSidecarName), data, 0644); err != nil {
+		return fmt.Errorf("error writing backup sidecar: %w", err)
+	}
+	return nil
+}
+
+// ReadBackupSidecar reads the sidecar of a backup folder.
+func ReadBackupSidecar(backupPath string) (BackupSidecar, error) {
+	var sidecar BackupSidecar
+	data, err := os.ReadFile(filepath.Join(backupPath, backupSidecarName))
+	if err != nil {
+		return sidecar, fmt.Errorf("error reading backup sidecar: %w", err)
+	}
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return sidecar, fmt.Errorf("error parsing backup sidecar: %w", err)
+	}
+	return sidecar, nil
+}
+
+// recordedManifest is the manifest of a backup, kept so the next backup only hashes the
+// files that changed.
+type recordedManifest struct {
+	// Full path of the backup.
+	path      string
+	algorithm HashAlgorithm
+	manifest  Manifest
+}
+
+// cache returns a scan cache with the hashes of the manifest for the same files in
+// root, which are hashed again if their size or modification time is different.
+func (m recordedManifest) cache(root string) *scanCache {
+	cache := newScanCache()
+	for _, entry := range m.manifest {
+		if entry.Dir || entry.Hash == "" {
+			continue
+		}
+		path := filepath.Join(root, filepath.FromSlash(entry.Path))
+		cache.entries[path] = scanCacheEntry{Size: entry.Size, ModTime: entry.ModTime, Algorithm: m.algorithm, Hash: entry.Hash}
+	}
+	return cache
+}
+
+// writeSidecar writes the sidecar for a completed backup along with the checksum file
+// if it is enabled. Errors are only logged since the backup itself is still usable.
+func (w *Watcher) writeSidecar(source, backupPath string, backup Backup, created time.Time, trigger BackupTrigger, checksums ChecksumConfig) {
+	if sourceHasSidecarName(source, w.singleFile) {
+		Logf(w.Name, LogLevelWarn, "Source contains %s, the backup sidecar was not written", backupSidecarName)
+		return
+	}
+
+	w.mu.Lock()
+	sign := w.SignBackups
+	keyring := w.keyring
+	recorded := w.lastManifest
+	transformed := len(w.hooks.transformers) > 0
+	w.mu.Unlock()
+
+	wroteChecksums := w.writeChecksums(source, backupPath, checksums)
+	generated := BackupSidecar{Checksums: wroteChecksums}.generatedFiles()
+	algorithm := w.hashAlgorithm()
+	// Files that did not change since the previous backup keep its hashes. Transformed
+	// files can differ even when the source did not, so they are always hashed.
+	var cache *scanCache
+	if previous := w.previousBackupPath(backup.Destination); !transformed && previous != "" && recorded.path == previous && recorded.algorithm == algorithm {
+		cache = recorded.cache(backupPath)
+	}
+	manifest, err := scanManifest(backupPath, algorithm, cache, func(relPath string) bool {
+		return slices.Contains(generated, relPath)
+	})
+	if err != nil {
+		Logf(w.Name, LogLevelError, "Error writing backup sidecar: %v", err)
+		return
+	}
+	w.mu.Lock()
+	w.lastManifest = recordedManifest{path: w.BackupPath(backup), algorithm: algorithm, manifest: manifest}
+	w.mu.Unlock()
+
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		absSource = source
+	}
+
+	sidecar := BackupSidecar{
+		Watcher:       w.Name,
+		Timestamp:     backup.Timestamp,
+		Time:          created,
+		Trigger:       trigger,
+		ManifestHash:  manifest.Hash(),
+		HashAlgorithm: algorithm,
+		ToolVersion:   Version,
+		Source:        absSource,
+		FolderFormat:  backup.FolderFormat,
+		Checksums:     wroteChecksums,
+	}
+	if sign {
+		if keyring == nil {
+			Logf(w.Name, LogLevelError, "Error signing backup: %v", ErrNoSigningKey)
+		} else if sidecar.Signature, sidecar.SigningKey, err = keyring.sign(sidecar.signedMessage()); err != nil {
+			Logf(w.Name, LogLevelError, "Error signing backup: %v", err)
+		}
+	}
+	if err := writeBackupSidecar(backupPath, sidecar); err != nil {
+		Logf(w.Name, LogLevelError, "%v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupSidecar(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file.txt", 1024)
+	watcher.createTriggeredBackup(BackupTriggerInitial)
+	watcher.CreateBackup()
+
+	for i, trigger := range []BackupTrigger{BackupTriggerInitial, BackupTriggerManual} {
+		backup := watcher.Metadata[i]
+		sidecar, err := ReadBackupSidecar(watcher.BackupPath(backup))
+		if err != nil {
+			t.Fatalf("Failed to read sidecar: %v", err)
+		}
+		if sidecar.Trigger != trigger {
+			t.Errorf("Expected trigger '%s', got '%s'", trigger, sidecar.Trigger)
+		}
+		if sidecar.Timestamp != backup.Timestamp || sidecar.Watcher != WatcherConfig.Name || sidecar.ToolVersion != Version {
+			t.Errorf("Unexpected sidecar: %+v", sidecar)
+		}
+		if absSource, _ := filepath.Abs(WatcherConfig.Source); sidecar.Source != absSource {
+			t.Errorf("Expected source '%s', got '%s'", absSource, sidecar.Source)
+		}
+
+		manifest, err := buildManifest(WatcherConfig.Source, HashXXHash, nil)
+		if err != nil {
+			t.Fatalf("Failed to build manifest: %v", err)
+		}
+		if sidecar.ManifestHash != manifest.Hash() {
+			t.Errorf("Expected manifest hash of the source, got '%s'", sidecar.ManifestHash)
+		}
+	}
+
+	// The sidecar must not make the latest backup look outdated.
+	if err := watcher.createBackupIfBackupIsOutdated(); err != nil {
+		t.Fatalf("Failed to compare backup: %v", err)
+	}
+	select {
+	case <-watcher.backupRequestChan:
+		t.Errorf("Expected the latest backup to match the source")
+	default:
+	}
+}
+
+func TestSourceWithSidecarName(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, backupSidecarName, 1024)
+	watcher.CreateBackup()
+
+	// The source's own file is kept instead of being overwritten by the sidecar.
+	CompareSourceAndDestination(t, WatcherConfig.Source, watcher.BackupPath(watcher.Metadata[0]))
+}
+
+func TestManifestHashIgnoresModTime(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	CreateDummyFile(t, WatcherConfig.Source, "folder/file.txt", 1024)
+
+	before, err := buildManifest(WatcherConfig.Source, HashXXHash, nil)
+	if err != nil {
+		t.Fatalf("Failed to build manifest: %v", err)
+	}
+	if len(before) != 2 || !before[0].Dir || before[1].Path != "folder/file.txt" || before[1].Hash == "" {
+		t.Fatalf("Unexpected manifest: %+v", before)
+	}
+
+	modTime := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(WatcherConfig.Source, "folder", "file.txt"), modTime, modTime); err != nil {
+		t.Fatalf("Failed to change modification time: %v", err)
+	}
+	after, err := buildManifest(WatcherConfig.Source, HashXXHash, nil)
+	if err != nil {
+		t.Fatalf("Failed to build manifest: %v", err)
+	}
+	if before.Hash() != after.Hash() {
+		t.Errorf("Expected the manifest hash to ignore modification times")
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "folder/file2.txt", 1024)
+	changed, err := buildManifest(WatcherConfig.Source, HashXXHash, nil)
+	if err != nil {
+		t.Fatalf("Failed to build manifest: %v", err)
+	}
+	if before.Hash() == changed.Hash() {
+		t.Errorf("Expected the manifest hash to change when a file is added")
+	}
+}
+
+func TestSidecarReusesPreviousHashes(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "unchanged.txt", 1024)
+	CreateDummyFile(t, WatcherConfig.Source, "changed.txt", 1024)
+	watcher.CreateBackup()
+
+	// A hash that could only come from the previous manifest shows which files were
+	// not hashed again.
+	watcher.mu.Lock()
+	for i, entry := range watcher.lastManifest.manifest {
+		watcher.lastManifest.manifest[i].Hash = "previous " + entry.Path
+	}
+	watcher.mu.Unlock()
+	CreateDummyFile(t, WatcherConfig.Source, "changed.txt", 2048)
+	watcher.CreateBackup()
+
+	hashes := map[string]string{}
+	for _, entry := range watcher.lastManifest.manifest {
+		hashes[entry.Path] = entry.Hash
+	}
+	if hashes["unchanged.txt"] != "previous unchanged.txt" {
+		t.Errorf("Expected the hash of the unchanged file to be reused, got %s", hashes["unchanged.txt"])
+	}
+	if hashes["changed.txt"] == "previous changed.txt" || hashes["changed.txt"] == "" {
+		t.Errorf("Expected the changed file to be hashed again, got %s", hashes["changed.txt"])
+	}
+}
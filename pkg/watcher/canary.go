@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Canaries are files inside the source, relative to it with forward slashes, that
+// nothing should ever change. Touching one makes a backup right away and shows a
+// warning, since it most likely means something is going through every file.
+type Canaries []string
+
+func (c Canaries) validate(singleFile bool) error {
+	if len(c) > 0 && singleFile {
+		return fmt.Errorf("canary files require the source to be a folder")
+	}
+	for _, canary := range c {
+		clean := path.Clean(canary)
+		if canary == "" || path.IsAbs(clean) || filepath.IsAbs(canary) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("canary file %q must be a path inside the source", canary)
+		}
+	}
+	return nil
+}
+
+// touched returns the canary an event for relPath affects. Removing or renaming a
+// folder affects the canaries inside it.
+func (c Canaries) touched(relPath, op string) (string, bool) {
+	relPath = path.Clean(relPath)
+	folderGone := strings.Contains(op, "REMOVE") || strings.Contains(op, "RENAME")
+	for _, canary := range c {
+		canary = path.Clean(canary)
+		if canary == relPath || (folderGone && strings.HasPrefix(canary, relPath+"/")) {
+			return canary, true
+		}
+	}
+	return "", false
+}
+
+// checkCanary raises the alert the first time an event touches a canary file while
+// the watcher is running.
+func (w *Watcher) checkCanary(eventPath, op string) {
+	w.mu.Lock()
+	canaries := w.Canaries
+	source := w.Source
+	w.mu.Unlock()
+	if len(canaries) == 0 {
+		return
+	}
+	rel, err := filepath.Rel(source, eventPath)
+	if err != nil {
+		return
+	}
+	canary, ok := canaries.touched(filepath.ToSlash(rel), op)
+	if !ok {
+		return
+	}
+
+	w.mu.Lock()
+	alerted := w.touchedCanaries[canary]
+	w.touchedCanaries[canary] = true
+	w.mu.Unlock()
+	if alerted {
+		return
+	}
+
+	message := fmt.Sprintf("Canary file %s was touched (%s)", canary, op)
+	w.recordEvent(HistoryEvent{Type: HistoryCanaryTouched, Message: message})
+	w.notifyWarning("%s in %s, something may be changing every file. A backup is being made", message, source)
+	w.requestUrgentBackup(BackupTriggerCanary)
+}
+
+// warnMissingCanaries logs the canary files that do not exist, they can not be
+// touched so they would never raise the alert.
+func (w *Watcher) warnMissingCanaries() {
+	for _, canary := range w.Canaries {
+		if _, err := os.Lstat(filepath.Join(w.Source, filepath.FromSlash(canary))); err != nil {
+			Logf(w.Name, LogLevelWarn, "Canary file %s does not exist: %v", canary, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCanaryTouched(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	CreateDummyFile(t, WatcherConfig.Source, "canary.docx", 10)
+	// The clock is never advanced so only the canary makes a backup.
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(NewFakeClock(time.Now())))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Canaries = Canaries{"canary.docx"}
+	recorder := &warningRecorder{}
+	watcher.AddObserver(recorder)
+	observer := startBackupLoop(t, watcher)
+
+	canary := filepath.Join(WatcherConfig.Source, "canary.docx")
+	watcher.handleSourceEvent(filepath.Join(WatcherConfig.Source, "other.txt"), "WRITE")
+	watcher.handleSourceEvent(canary, "WRITE")
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatal("Expected a backup to be made right away")
+	}
+	watcher.handleSourceEvent(canary, "REMOVE")
+	watcher.flushObservers()
+
+	if len(recorder.warnings) != 1 {
+		t.Errorf("Expected 1 warning, got %v", recorder.warnings)
+	}
+	if backups := watcher.ListBackups(); len(backups) != 1 || backups[0].Name != "Canary touched" {
+		t.Errorf("Expected a canary backup, got %+v", backups)
+	}
+	history := watcher.History()
+	found := false
+	for _, event := range history {
+		found = found || (event.Type == HistoryCanaryTouched && event.Message == "Canary file canary.docx was touched (WRITE)")
+	}
+	if !found {
+		t.Errorf("Expected the canary in the history, got %+v", history)
+	}
+}
+
+func TestCanariesTouched(t *testing.T) {
+	t.Parallel()
+	canaries := Canaries{"canary.docx", "sub/inner.txt"}
+	tests := []struct {
+		path, op string
+		canary   string
+	}{
+		{"canary.docx", "CHMOD", "canary.docx"},
+		{"sub/inner.txt", "WRITE", "sub/inner.txt"},
+		{"sub", "RENAME", "sub/inner.txt"},
+		{"sub", "CREATE", ""},
+		{"su", "REMOVE", ""},
+		{"other.txt", "WRITE", ""},
+	}
+	for _, test := range tests {
+		if canary, _ := canaries.touched(test.path, test.op); canary != test.canary {
+			t.Errorf("Expected %s of %s to touch %q, got %q", test.op, test.path, test.canary, canary)
+		}
+	}
+}
+
+func TestCanariesValidate(t *testing.T) {
+	t.Parallel()
+	if err := (Canaries{"canary.docx", "sub/inner.txt"}).validate(false); err != nil {
+		t.Errorf("Expected canaries inside the source to be valid, got %v", err)
+	}
+	for _, canaries := range []Canaries{{"../outside"}, {"/absolute"}, {""}, {"."}} {
+		if err := canaries.validate(false); err == nil {
+			t.Errorf("Expected %q to be invalid", canaries)
+		}
+	}
+	if err := (Canaries{"canary.docx"}).validate(true); err == nil {
+		t.Error("Expected canaries to need a folder source")
+	}
+}
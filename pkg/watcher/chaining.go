@@ -0,0 +1,54 @@
+package watcher
+
+import "slices"
+
+// ChainedDestinations returns the destinations of the other folder pairs that are
+// inside the source of pair, as comparable paths. Backups written there would trigger
+// a backup of pair, which loops if the destination of pair is in turn inside the
+// source of the other pair. Both pair and pairs have the defaults applied.
+func ChainedDestinations(pair *WatcherConfig, pairs []*WatcherConfig) []string {
+	source := comparablePath(pair.Source)
+	chained := []string{}
+	for _, other := range pairs {
+		if other.ID == pair.ID {
+			continue
+		}
+		for _, destination := range pairDestinations(other) {
+			if isPathWithin(source, destination) && !slices.Contains(chained, destination) {
+				chained = append(chained, destination)
+			}
+		}
+	}
+	slices.Sort(chained)
+	return chained
+}
+
+// SetExcludedPaths sets the folders inside the source whose file events are ignored,
+// newly excluded folders are logged since their contents still end up in backups.
+func (w *Watcher) SetExcludedPaths(paths []string) {
+	w.mu.Lock()
+	previous := w.excludedPaths
+	w.excludedPaths = paths
+	w.mu.Unlock()
+
+	for _, path := range paths {
+		if !slices.Contains(previous, path) {
+			Logf(w.Name, LogLevelWarn, "%s is the destination of another folder pair, its changes will not trigger backups", path)
+		}
+	}
+}
+
+// IsExcludedPath returns true if path is inside a folder set by SetExcludedPaths.
+func (w *Watcher) IsExcludedPath(path string) bool {
+	w.mu.Lock()
+	excluded := w.excludedPaths
+	w.mu.Unlock()
+	if len(excluded) == 0 {
+		return false
+	}
+
+	path = comparablePath(path)
+	return slices.ContainsFunc(excluded, func(folder string) bool {
+		return isPathWithin(folder, path)
+	})
+}
@@ -0,0 +1,28 @@
+package watcher
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestChainedDestinations(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	outer := &WatcherConfig{ID: "outer", Source: filepath.Join(root, "drive"), Destination: filepath.Join(root, "backups", "drive")}
+	inner := &WatcherConfig{
+		ID:                   "inner",
+		Source:               filepath.Join(root, "documents"),
+		Destination:          filepath.Join(root, "drive", "backups"),
+		RotationDestinations: []string{filepath.Join(root, "usb")},
+	}
+	pairs := []*WatcherConfig{outer, inner}
+
+	expected := []string{comparablePath(inner.Destination)}
+	if chained := ChainedDestinations(outer, pairs); !slices.Equal(chained, expected) {
+		t.Errorf("Expected %v, got %v", expected, chained)
+	}
+	if chained := ChainedDestinations(inner, pairs); len(chained) != 0 {
+		t.Errorf("Expected no chained destinations for the inner pair, got %v", chained)
+	}
+}
@@ -0,0 +1,80 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the checksum file written inside backups and its detached signature, the
+// same names many download sites use so they can be checked with sha256sum -c and
+// gpg --verify.
+const (
+	checksumsName          = "SHA256SUMS"
+	checksumsSignatureName = "SHA256SUMS.asc"
+)
+
+// ChecksumConfig writes a SHA256SUMS file inside each backup so its integrity can be
+// checked without the watcher.
+type ChecksumConfig struct {
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty" toml:"enabled,omitempty"`
+	// GPG key that signs the checksum file, the signature is written to SHA256SUMS.asc.
+	// Setting a key also enables the checksum file.
+	SigningKey string `json:"signing_key,omitempty" yaml:"signing_key,omitempty" toml:"signing_key,omitempty"`
+}
+
+func (c ChecksumConfig) enabled() bool {
+	return c.Enabled || c.SigningKey != ""
+}
+
+// formatChecksums formats the files of a manifest in the format used by sha256sum.
+func formatChecksums(manifest Manifest) string {
+	var builder strings.Builder
+	for _, entry := range manifest {
+		if entry.Dir || entry.Hash == "" {
+			continue
+		}
+		fmt.Fprintf(&builder, "%s  %s\n", entry.Hash, entry.Path)
+	}
+	return builder.String()
+}
+
+// writeChecksums writes the checksum file of a backup and signs it if a key is set.
+// It returns true if the checksum file was written, a failed signature is only logged.
+func (w *Watcher) writeChecksums(source, backupPath string, config ChecksumConfig) bool {
+	if !config.enabled() {
+		return false
+	}
+	if !w.singleFile {
+		for _, name := range []string{checksumsName, checksumsSignatureName} {
+			if _, err := os.Lstat(filepath.Join(source, name)); err == nil {
+				Logf(w.Name, LogLevelWarn, "Source contains %s, the checksum file was not written", name)
+				return false
+			}
+		}
+	}
+
+	manifest, err := buildManifest(backupPath, HashSHA256, func(relPath string) bool {
+		return relPath == backupSidecarName
+	})
+	if err != nil {
+		Logf(w.Name, LogLevelError, "Error writing checksum file: %v", err)
+		return false
+	}
+	path := filepath.Join(backupPath, checksumsName)
+	if err := os.WriteFile(path, []byte(formatChecksums(manifest)), 0644); err != nil {
+		Logf(w.Name, LogLevelError, "Error writing checksum file: %v", err)
+		return false
+	}
+
+	if config.SigningKey != "" {
+		output, err := exec.Command("gpg", "--batch", "--yes", "--armor", "--local-user", config.SigningKey,
+			"--output", filepath.Join(backupPath, checksumsSignatureName), "--detach-sign", path).CombinedOutput()
+		if err != nil {
+			Logf(w.Name, LogLevelError, "Error signing checksum file: %v: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+	return true
+}
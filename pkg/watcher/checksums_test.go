@@ -0,0 +1,66 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupChecksums(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Checksums = ChecksumConfig{Enabled: true}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	CreateDummyFile(t, WatcherConfig.Source, "folder/file2.txt", 1024)
+	watcher.CreateBackup()
+	backupPath := watcher.BackupPath(watcher.Metadata[0])
+
+	data, err := os.ReadFile(filepath.Join(backupPath, checksumsName))
+	if err != nil {
+		t.Fatalf("Failed to read checksum file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a checksum for each file, got %q", lines)
+	}
+	for _, line := range lines {
+		hash, path, ok := strings.Cut(line, "  ")
+		if !ok {
+			t.Fatalf("Unexpected checksum line '%s'", line)
+		}
+		expected, err := hashFile(filepath.Join(backupPath, filepath.FromSlash(path)), HashSHA256)
+		if err != nil {
+			t.Fatalf("Failed to hash %s: %v", path, err)
+		}
+		if hash != expected {
+			t.Errorf("Expected checksum %s for %s, got %s", expected, path, hash)
+		}
+	}
+
+	sidecar, err := ReadBackupSidecar(backupPath)
+	if err != nil {
+		t.Fatalf("Failed to read sidecar: %v", err)
+	}
+	if !sidecar.Checksums {
+		t.Errorf("Expected the sidecar to record the checksum file")
+	}
+	if result := watcher.VerifyBackup(watcher.Metadata[0]); result.Status != VerifyStatusOK {
+		t.Errorf("Expected the backup to verify, got %s: %s", result.Status, result.Error)
+	}
+	if matches, err := watcher.SourceMatchesLatestBackup(); err != nil || !matches {
+		t.Errorf("Expected the checksum file to be ignored when comparing, got %t: %v", matches, err)
+	}
+
+	if err := restoreInto(backupPath, WatcherConfig.Source, false, nil); err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(WatcherConfig.Source, checksumsName)); !os.IsNotExist(err) {
+		t.Errorf("Expected the checksum file to not be restored")
+	}
+}
@@ -0,0 +1,363 @@
+package watcher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Folder in the state folder of a destination that holds the chunks of chunked files.
+const chunkDirName = "chunks"
+
+// Chunks that were written or reused this recently are never collected, so a backup
+// that is still being made does not lose the chunks it refers to.
+const chunkGrace = 24 * time.Hour
+
+func chunkDir(destination string) string {
+	return statePath(destination, chunkDirName)
+}
+
+// isChunkPath returns true for the chunk store of a destination and anything in it.
+func (w *Watcher) isChunkPath(path string) bool {
+	for _, destination := range w.destinations() {
+		if isPathWithin(chunkDir(destination), path) {
+			return true
+		}
+	}
+	return false
+}
+
+// chunkManifest is stored in a backup in place of a chunked file. The first field
+// marks the file as a manifest, see chunkManifestPrefix.
+type chunkManifest struct {
+	Format int   `json:"i_saw_that_chunks"`
+	Size   int64 `json:"size"`
+	// Hex encoded SHA-256 of the whole file.
+	Hash   string     `json:"hash"`
+	Chunks []chunkRef `json:"chunks"`
+}
+
+type chunkRef struct {
+	// Hex encoded SHA-256 of the chunk, which is also its name in the chunk store.
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+const chunkManifestPrefix = `{"i_saw_that_chunks":`
+
+// parseChunkManifest returns the manifest in data, false if data is not a manifest.
+func parseChunkManifest(data []byte) (chunkManifest, bool) {
+	var manifest chunkManifest
+	if !bytes.HasPrefix(data, []byte(chunkManifestPrefix)) {
+		return manifest, false
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Format != 1 {
+		return manifest, false
+	}
+	return manifest, true
+}
+
+// readChunkManifest reads the manifest of a chunked file in a backup.
+func readChunkManifest(path string) (chunkManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return chunkManifest{}, err
+	}
+	manifest, ok := parseChunkManifest(data)
+	if !ok {
+		return manifest, fmt.Errorf("%s is not a chunked file", path)
+	}
+	return manifest, nil
+}
+
+// matches returns true if contents are the file the manifest was made from.
+func (m chunkManifest) matches(contents []byte) bool {
+	sum := sha256.Sum256(contents)
+	return int64(len(contents)) == m.Size && hex.EncodeToString(sum[:]) == m.Hash
+}
+
+// chunkStore holds chunks named by their hash, in folders named by the first two
+// characters of the hash.
+type chunkStore struct {
+	dir string
+}
+
+func (s chunkStore) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+// storeFile splits the file at path into chunks, stores the chunks the store does not
+// have yet and returns the manifest of the file.
+func (s chunkStore) storeFile(path string, config ChunkingConfig) (chunkManifest, error) {
+	manifest := chunkManifest{Format: 1, Chunks: []chunkRef{}}
+	file, err := os.Open(path)
+	if err != nil {
+		return manifest, err
+	}
+	defer file.Close()
+
+	whole := sha256.New()
+	err = splitChunks(file, config, func(chunk []byte) error {
+		whole.Write(chunk)
+		sum := sha256.Sum256(chunk)
+		ref := chunkRef{Hash: hex.EncodeToString(sum[:]), Size: int64(len(chunk))}
+		if err := s.put(ref, chunk); err != nil {
+			return err
+		}
+		manifest.Chunks = append(manifest.Chunks, ref)
+		manifest.Size += ref.Size
+		return nil
+	})
+	manifest.Hash = hex.EncodeToString(whole.Sum(nil))
+	return manifest, err
+}
+
+// put writes a chunk unless the store has it, a chunk that is reused has its time
+// updated so it is not collected while the backup using it is made.
+func (s chunkStore) put(ref chunkRef, chunk []byte) error {
+	path := s.path(ref.Hash)
+	if info, err := os.Stat(path); err == nil && info.Size() == ref.Size {
+		now := time.Now()
+		return os.Chtimes(path, now, now)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Written to a temporary file first so an interrupted write never leaves a chunk
+	// with the wrong contents.
+	file, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(chunk)
+	if err := errors.Join(err, file.Close()); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
+
+// get returns the contents of a chunk, checking them against its hash.
+func (s chunkStore) get(ref chunkRef) ([]byte, error) {
+	if len(ref.Hash) != sha256.Size*2 {
+		return nil, fmt.Errorf("invalid chunk name %q", ref.Hash)
+	}
+	data, err := os.ReadFile(s.path(ref.Hash))
+	if err != nil {
+		return nil, fmt.Errorf("error reading chunk: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != ref.Hash {
+		return nil, fmt.Errorf("chunk %s is damaged", ref.Hash)
+	}
+	return data, nil
+}
+
+// reassemble writes the file the manifest at path was made from to w.
+func (s chunkStore) reassemble(path string, w io.Writer) error {
+	manifest, err := readChunkManifest(path)
+	if err != nil {
+		return err
+	}
+	whole := sha256.New()
+	for _, ref := range manifest.Chunks {
+		chunk, err := s.get(ref)
+		if err != nil {
+			return fmt.Errorf("error reassembling %s: %w", path, err)
+		}
+		whole.Write(chunk)
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	if hex.EncodeToString(whole.Sum(nil)) != manifest.Hash {
+		return fmt.Errorf("error reassembling %s: the chunks do not match the file", path)
+	}
+	return nil
+}
+
+// reassembleInPlace replaces the manifest at path, such as one copied out of a backup,
+// with the file it was made from. Its permissions and modification time are kept.
+func (s chunkStore) reassembleInPlace(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	err = s.reassemble(path, file)
+	err = errors.Join(err, file.Close(), os.Chmod(file.Name(), info.Mode().Perm()), os.Chtimes(file.Name(), info.ModTime(), info.ModTime()))
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// chunkedFiles are the files of a backup stored as chunks, relative to the backup
+// folder with forward slashes.
+type chunkedFiles struct {
+	store chunkStore
+	paths []string
+}
+
+func backupChunks(backup Backup) chunkedFiles {
+	return chunkedFiles{store: chunkStore{dir: chunkDir(backup.Destination)}, paths: backup.Chunked}
+}
+
+func (c chunkedFiles) contains(relPath string) bool {
+	return slices.Contains(c.paths, relPath)
+}
+
+// reassemble replaces the manifests of the chunked files copied from a backup into
+// root with the files they were made from. Files that were not copied are skipped.
+func (c chunkedFiles) reassemble(root string) error {
+	var errs error
+	for _, relPath := range c.paths {
+		path := filepath.Join(root, filepath.FromSlash(relPath))
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		if _, ok := parseChunkManifest(data); !ok {
+			continue
+		}
+		errs = errors.Join(errs, c.store.reassembleInPlace(path))
+	}
+	return errs
+}
+
+// expandManifest changes the entries of the chunked files in a manifest of the backup
+// at backupPath to describe the files they were made from.
+func (c chunkedFiles) expandManifest(manifest Manifest, backupPath string, algorithm HashAlgorithm) error {
+	for i, entry := range manifest {
+		if entry.Dir || !c.contains(entry.Path) {
+			continue
+		}
+		path := filepath.Join(backupPath, filepath.FromSlash(entry.Path))
+		chunked, err := readChunkManifest(path)
+		if err != nil {
+			return err
+		}
+		hash := algorithm.newHash()
+		if err := c.store.reassemble(path, hash); err != nil {
+			return err
+		}
+		manifest[i].Size = chunked.Size
+		manifest[i].Hash = hex.EncodeToString(hash.Sum(nil))
+	}
+	return nil
+}
+
+// collectChunks deletes the chunks in destination that no backup refers to, including
+// backups in the trash.
+func (w *Watcher) collectChunks(destination string) error {
+	store := chunkStore{dir: chunkDir(destination)}
+	if _, err := os.Stat(store.dir); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	used := map[string]bool{}
+	addManifest := func(path string) error {
+		manifest, err := readChunkManifest(path)
+		if err != nil {
+			return err
+		}
+		for _, ref := range manifest.Chunks {
+			used[ref.Hash] = true
+		}
+		return nil
+	}
+	w.mu.Lock()
+	backups := []Backup{}
+	for _, backup := range w.Metadata {
+		if backup.Destination == destination && len(backup.Chunked) > 0 {
+			backups = append(backups, backup)
+		}
+	}
+	w.mu.Unlock()
+	// Chunks are only deleted once every manifest was read, a manifest that can not be
+	// read could refer to any of them.
+	for _, backup := range backups {
+		for _, relPath := range backup.Chunked {
+			if err := addManifest(filepath.Join(w.BackupPath(backup), filepath.FromSlash(relPath))); err != nil {
+				return fmt.Errorf("error reading chunked file of backup %s: %w", backup.Path, err)
+			}
+		}
+	}
+	// Backups in the trash are no longer in the metadata, their manifests are found by
+	// looking at the start of every file.
+	err := filepath.WalkDir(trashDir(destination), func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) && path == trashDir(destination) {
+			return nil
+		}
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		if isChunkManifest(path) {
+			return addManifest(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error reading trash: %w", err)
+	}
+
+	removed := 0
+	err = filepath.WalkDir(store.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || used[d.Name()] {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil || time.Since(info.ModTime()) < chunkGrace {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(d.Name(), ".tmp-") {
+			removed++
+		}
+		// Fails unless the folder is empty.
+		os.Remove(filepath.Dir(path))
+		return nil
+	})
+	if removed > 0 {
+		Logf(w.Name, LogLevelInfo, "Deleted %d chunks no backup in %s uses", removed, destination)
+	}
+	return err
+}
+
+// isChunkManifest returns true if the file at path starts like a chunk manifest.
+func isChunkManifest(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	prefix := make([]byte, len(chunkManifestPrefix))
+	_, err = io.ReadFull(file, prefix)
+	return err == nil && string(prefix) == chunkManifestPrefix
+}
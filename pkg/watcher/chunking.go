@@ -0,0 +1,263 @@
+package watcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/bits"
+	"os"
+	"slices"
+	"sync"
+)
+
+// ChunkingMode decides where large files are split into chunks.
+type ChunkingMode string
+
+const (
+	// Split files every ChunkSize bytes, which is fast and suits files that are
+	// changed in place such as VM images.
+	ChunkingFixed ChunkingMode = "fixed"
+	// Split files where their contents match a pattern, so data inserted into a file
+	// only changes the chunks around it.
+	ChunkingContent ChunkingMode = "content"
+)
+
+const (
+	defaultChunkMinFileSize = 64 << 20
+	defaultChunkSize        = 4 << 20
+	minChunkSize            = 64 << 10
+)
+
+// ChunkingConfig stores large files as chunks that are shared between the backups in a
+// destination, so a backup of a big file that changed in a few places only stores the
+// chunks that changed. Chunking is off unless a mode is set.
+type ChunkingConfig struct {
+	Mode ChunkingMode `json:"mode,omitempty" yaml:"mode,omitempty" toml:"mode,omitempty"`
+	// Files smaller than this many bytes are copied as usual, defaults to 64 MB.
+	MinFileSize int64 `json:"min_file_size,omitempty" yaml:"min_file_size,omitempty" toml:"min_file_size,omitempty"`
+	// Bytes in a chunk, content defined chunks are about this size on average.
+	// Defaults to 4 MB.
+	ChunkSize int64 `json:"chunk_size,omitempty" yaml:"chunk_size,omitempty" toml:"chunk_size,omitempty"`
+}
+
+func (c ChunkingConfig) enabled() bool {
+	return c.Mode != ""
+}
+
+func (c ChunkingConfig) validate() error {
+	switch c.Mode {
+	case "", ChunkingFixed, ChunkingContent:
+	default:
+		return fmt.Errorf("unknown chunking mode: %s", c.Mode)
+	}
+	if c.MinFileSize < 0 {
+		return fmt.Errorf("chunking min file size must be at least 0 bytes")
+	}
+	if c.ChunkSize != 0 && c.ChunkSize < minChunkSize {
+		return fmt.Errorf("chunk size must be at least %s", FormatBytes(minChunkSize))
+	}
+	return nil
+}
+
+func (c ChunkingConfig) minFileSize() int64 {
+	if c.MinFileSize == 0 {
+		return defaultChunkMinFileSize
+	}
+	return c.MinFileSize
+}
+
+func (c ChunkingConfig) chunkSize() int64 {
+	if c.ChunkSize == 0 {
+		return defaultChunkSize
+	}
+	return c.ChunkSize
+}
+
+// validateChunking checks that chunked backups can be stored the way the folder pair
+// makes backups. Chunks are kept next to the backups, which restic, filesystem
+// snapshots and remote copies do not carry along.
+func validateChunking(config *WatcherConfig) error {
+	if !config.Chunking.enabled() {
+		return nil
+	}
+	if config.Restic.enabled() {
+		return fmt.Errorf("chunking can not be combined with a restic repository")
+	}
+	if config.Snapshot == SnapshotModeBtrfs || config.Snapshot == SnapshotModeZFS {
+		return fmt.Errorf("chunking can not be combined with %s snapshots", config.Snapshot)
+	}
+	if config.Remote.enabled() {
+		return fmt.Errorf("chunking can not be combined with a remote")
+	}
+	return nil
+}
+
+// apply returns a copy engine that stores large files as chunks, only the go engine can.
+func (c ChunkingConfig) apply(engine CopyEngine) (CopyEngine, error) {
+	if !c.enabled() {
+		return engine, nil
+	}
+	goEngine, ok := engine.(goCopyEngine)
+	if !ok {
+		return nil, fmt.Errorf("chunking is only supported by the %s copy engine", CopyEngineGo)
+	}
+	goEngine.chunking = c
+	return goEngine, nil
+}
+
+// chunkLog records the files a backup stored as chunks. It is shared by the copies of
+// the copy engine that make the attempts of one backup.
+type chunkLog struct {
+	mu    sync.Mutex
+	files map[string]bool
+}
+
+func (l *chunkLog) add(relPath string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.files == nil {
+		l.files = map[string]bool{}
+	}
+	l.files[relPath] = true
+}
+
+// paths returns the recorded files in order, nil if there are none.
+func (l *chunkLog) paths() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var paths []string
+	for relPath := range l.files {
+		paths = append(paths, relPath)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// withChunkStore returns the copy engine that stores chunks in store and records the
+// chunked files in log. Engines without chunking are returned unchanged.
+func withChunkStore(engine CopyEngine, store chunkStore, log *chunkLog) CopyEngine {
+	goEngine, ok := engine.(goCopyEngine)
+	if !ok || !goEngine.chunking.enabled() {
+		return engine
+	}
+	goEngine.chunkStore = store
+	goEngine.chunkLog = log
+	return goEngine
+}
+
+// copyChunked stores src, which has info, as chunks and writes a chunk manifest to
+// destination in its place if it is large enough. False is returned if the file has to
+// be copied normally, such as when it can not be read so the copy reports it.
+func (e goCopyEngine) copyChunked(info os.FileInfo, source, src, destination string) (bool, error) {
+	if !e.chunking.enabled() || e.chunkLog == nil || !info.Mode().IsRegular() || info.Size() < e.chunking.minFileSize() {
+		return false, nil
+	}
+	manifest, err := e.chunkStore.storeFile(src, e.chunking)
+	if errors.Is(err, fs.ErrPermission) {
+		return false, nil
+	}
+	if err != nil {
+		return true, fmt.Errorf("error storing %s as chunks: %w", src, err)
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return true, err
+	}
+	if err := os.WriteFile(destination, data, 0600); err != nil {
+		return true, err
+	}
+	if err := e.finishFile(info, destination); err != nil {
+		return true, err
+	}
+	e.chunkLog.add(hookRelPath(source, src))
+	return true, nil
+}
+
+// finishFile gives a file the engine wrote itself the permissions and modification
+// time of the source file, leaving out what the destination can not keep.
+func (e goCopyEngine) finishFile(info os.FileInfo, destination string) error {
+	if !slices.Contains(e.unsupported, AttributePermissions) {
+		if err := os.Chmod(destination, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if !slices.Contains(e.unsupported, AttributeTimes) {
+		return os.Chtimes(destination, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+// splitChunks reads r to the end and calls emit with each chunk in order. The slice is
+// reused once emit returns.
+func splitChunks(r io.Reader, config ChunkingConfig, emit func([]byte) error) error {
+	size := config.chunkSize()
+	if config.Mode == ChunkingContent {
+		return splitContentChunks(r, size, emit)
+	}
+	buf := make([]byte, size)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := emit(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// splitContentChunks splits r with a gear hash, a chunk ends once the top bits of the
+// hash of the last 64 bytes are zero. Chunks are between a quarter and four times size.
+func splitContentChunks(r io.Reader, size int64, emit func([]byte) error) error {
+	minSize, maxSize := int(size/4), int(size*4)
+	maskBits := bits.Len64(uint64(size)) - 1
+	mask := (uint64(1)<<maskBits - 1) << (64 - maskBits)
+	reader := bufio.NewReaderSize(r, 1<<20)
+	buf := make([]byte, 0, maxSize)
+	var gear uint64
+	for {
+		b, err := reader.ReadByte()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		buf = append(buf, b)
+		gear = gear<<1 + gearTable[b]
+		if (len(buf) >= minSize && gear&mask == 0) || len(buf) >= maxSize {
+			if err := emit(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	if len(buf) > 0 {
+		return emit(buf)
+	}
+	return nil
+}
+
+// gearTable maps bytes to random values for the gear hash. It is generated with
+// splitmix64 from a fixed seed and must never change, otherwise files are split
+// differently and nothing is shared with older backups.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0)
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		table[i] = z ^ z>>31
+	}
+	return table
+}()
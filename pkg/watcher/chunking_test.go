@@ -0,0 +1,225 @@
+package watcher
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// countChunks returns the number of chunks stored in destination.
+func countChunks(t *testing.T, destination string) int {
+	t.Helper()
+	count := 0
+	err := filepath.WalkDir(chunkDir(destination), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			count++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to read chunks: %v", err)
+	}
+	return count
+}
+
+// newChunkingWatcher returns a watcher that chunks files of 256 KB or more into 64 KB
+// chunks.
+func newChunkingWatcher(t *testing.T, config TempWatcherConfig) *Watcher {
+	t.Helper()
+	watcher, err := NewTempWatcher(config)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	chunking := ChunkingConfig{Mode: ChunkingFixed, MinFileSize: 256 << 10, ChunkSize: 64 << 10}
+	if watcher.CopyEngine, err = chunking.apply(watcher.CopyEngine); err != nil {
+		t.Fatalf("Failed to apply chunking: %v", err)
+	}
+	return watcher
+}
+
+func TestChunkedBackups(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher := newChunkingWatcher(t, WatcherConfig)
+	CreateDummyFile(t, WatcherConfig.Source, "disk.img", 1<<20)
+	CreateDummyFile(t, WatcherConfig.Source, "small.txt", 1024)
+	large := filepath.Join(WatcherConfig.Source, "disk.img")
+	original, err := os.ReadFile(large)
+	if err != nil {
+		t.Fatalf("Failed to read source: %v", err)
+	}
+
+	first, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok || !slices.Equal(first.Chunked, []string{"disk.img"}) {
+		t.Fatalf("Expected disk.img to be chunked, got %v", first.Chunked)
+	}
+	if !isChunkManifest(filepath.Join(watcher.BackupPath(first), "disk.img")) {
+		t.Error("Expected the backup to hold the chunk list of disk.img")
+	}
+	if count := countChunks(t, WatcherConfig.Destination); count != 16 {
+		t.Errorf("Expected 16 chunks, got %d", count)
+	}
+	if match, err := watcher.SourceMatchesLatestBackup(); err != nil || !match {
+		t.Errorf("Expected the source to match the chunked backup: %v", err)
+	}
+
+	// Changing a few bytes in place only stores the chunk they are in.
+	changed := bytes.Clone(original)
+	copy(changed[300<<10:], "changed")
+	if err := os.WriteFile(large, changed, 0644); err != nil {
+		t.Fatalf("Failed to change source: %v", err)
+	}
+	if match, err := watcher.SourceMatchesLatestBackup(); err != nil || match {
+		t.Errorf("Expected the changed file to not match the backup: %v", err)
+	}
+	second, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatal("Failed to create backup")
+	}
+	if count := countChunks(t, WatcherConfig.Destination); count != 17 {
+		t.Errorf("Expected 1 new chunk, got %d chunks", count)
+	}
+
+	if _, err := watcher.RestoreBackup(first.Path); err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	if restored, err := os.ReadFile(large); err != nil || !bytes.Equal(restored, original) {
+		t.Errorf("Expected the chunked file to be restored: %v", err)
+	}
+	target, err := watcher.RestoreFile(second.Path, "disk.img", false)
+	if err != nil {
+		t.Fatalf("Failed to restore file: %v", err)
+	}
+	if restored, err := os.ReadFile(target); err != nil || !bytes.Equal(restored, changed) {
+		t.Errorf("Expected the changed file to be restored: %v", err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "backup.tar")
+	if err := watcher.ExportBackup(first.Path, archive, ""); err != nil {
+		t.Fatalf("Failed to export backup: %v", err)
+	}
+	file, err := os.Open(archive)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	defer file.Close()
+	reader := tar.NewReader(file)
+	found := false
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		if header.Name == "disk.img" {
+			found = true
+			if contents, err := io.ReadAll(reader); err != nil || !bytes.Equal(contents, original) {
+				t.Errorf("Expected the archive to hold the whole file: %v", err)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected disk.img in the archive")
+	}
+}
+
+func TestCollectChunks(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher := newChunkingWatcher(t, WatcherConfig)
+	CreateDummyFile(t, WatcherConfig.Source, "disk.img", 512<<10)
+	first, _ := watcher.createTriggeredBackup(BackupTriggerManual)
+	CreateDummyFile(t, WatcherConfig.Source, "disk.img", 512<<10)
+	watcher.createTriggeredBackup(BackupTriggerManual)
+
+	// Chunks are only collected once they are old enough.
+	old := time.Now().Add(-2 * chunkGrace)
+	filepath.WalkDir(chunkDir(WatcherConfig.Destination), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			os.Chtimes(path, old, old)
+		}
+		return err
+	})
+	if err := watcher.deleteBackup(first); err != nil {
+		t.Fatalf("Failed to delete backup: %v", err)
+	}
+	watcher.Metadata = watcher.Metadata[1:]
+	if err := watcher.collectChunks(WatcherConfig.Destination); err != nil {
+		t.Fatalf("Failed to collect chunks: %v", err)
+	}
+	if count := countChunks(t, WatcherConfig.Destination); count != 8 {
+		t.Errorf("Expected the 8 chunks of the kept backup, got %d", count)
+	}
+	if result, err := watcher.RunRestoreDrill(""); err != nil || result.Status != VerifyStatusOK {
+		t.Errorf("Expected the kept backup to restore, got %v: %v", result.Status, err)
+	}
+
+	// A damaged chunk fails the restore drill.
+	manifest, err := readChunkManifest(filepath.Join(watcher.BackupPath(watcher.Metadata[0]), "disk.img"))
+	if err != nil {
+		t.Fatalf("Failed to read chunk list: %v", err)
+	}
+	store := chunkStore{dir: chunkDir(WatcherConfig.Destination)}
+	if err := os.WriteFile(store.path(manifest.Chunks[0].Hash), []byte("damaged"), 0644); err != nil {
+		t.Fatalf("Failed to damage chunk: %v", err)
+	}
+	if result, _ := watcher.RunRestoreDrill(""); result.Status != VerifyStatusRestoreFailed {
+		t.Errorf("Expected a damaged chunk to fail the restore drill, got %v", result.Status)
+	}
+}
+
+func TestContentChunksSurviveInsertions(t *testing.T) {
+	t.Parallel()
+	config := ChunkingConfig{Mode: ChunkingContent, ChunkSize: 64 << 10}
+	chunks := func(data []byte) []string {
+		hashes := []string{}
+		err := splitChunks(bytes.NewReader(data), config, func(chunk []byte) error {
+			hashes = append(hashes, string(chunk[:16])+string(chunk[len(chunk)-16:]))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to split: %v", err)
+		}
+		return hashes
+	}
+	data := createRandomFileContent(2 << 20)
+	before := chunks(data)
+	after := chunks(slices.Concat(data[:1000], []byte("inserted"), data[1000:]))
+
+	shared := 0
+	for _, chunk := range after {
+		if slices.Contains(before, chunk) {
+			shared++
+		}
+	}
+	if shared < len(before)-2 {
+		t.Errorf("Expected all but the first chunk to be shared, %d of %d are", shared, len(before))
+	}
+}
+
+func TestChunkingValidation(t *testing.T) {
+	t.Parallel()
+	if err := (ChunkingConfig{Mode: "rolling"}).validate(); err == nil {
+		t.Error("Expected an unknown mode to be rejected")
+	}
+	if err := (ChunkingConfig{Mode: ChunkingFixed, ChunkSize: 1024}).validate(); err == nil {
+		t.Error("Expected a tiny chunk size to be rejected")
+	}
+	if _, err := (ChunkingConfig{Mode: ChunkingFixed}).apply(rsyncCopyEngine{}); err == nil {
+		t.Error("Expected chunking to be rejected by the rsync engine")
+	}
+	engine, err := (ChunkingConfig{Mode: ChunkingFixed}).apply(goCopyEngine{})
+	if err != nil {
+		t.Fatalf("Failed to apply chunking: %v", err)
+	}
+	if err := (watcherHooks{transformers: []BackupTransformer{nil}}).check(engine, "", false); err == nil {
+		t.Error("Expected transformers to be rejected for chunked backups")
+	}
+}
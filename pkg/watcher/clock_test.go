@@ -0,0 +1,45 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	timer := clock.NewTimer(time.Minute)
+	stopped := clock.NewTimer(time.Minute)
+	if !stopped.Stop() {
+		t.Errorf("Expected a pending timer to be stopped")
+	}
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatalf("Expected the timer to not fire early")
+	default:
+	}
+	clock.Advance(time.Second)
+	if fired := <-timer.C(); !fired.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the timer to fire at %s, got %s", start.Add(time.Minute), fired)
+	}
+	select {
+	case <-stopped.C():
+		t.Errorf("Expected a stopped timer to not fire")
+	default:
+	}
+
+	done := make(chan struct{})
+	go func() {
+		clock.Sleep(time.Hour)
+		close(done)
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	<-done
+	if now := clock.Now(); !now.Equal(start.Add(time.Minute + time.Hour)) {
+		t.Errorf("Expected the clock to be at %s, got %s", start.Add(time.Minute+time.Hour), now)
+	}
+}
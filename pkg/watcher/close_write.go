@@ -0,0 +1,42 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// Logged as the operation of close-write events, named after the inotify event.
+const closeWriteOp = "CLOSE_WRITE"
+
+// startCloseWriteWatcher watches the source for files that are closed after being
+// written so fsnotify can ignore the writes themselves. Returns false if the events are
+// not available, in which case every write is treated as a change as usual. Must be
+// called while holding w.mu.
+func (w *Watcher) startCloseWriteWatcher(stop chan struct{}) bool {
+	if !w.CloseWrite {
+		return false
+	}
+	root, recursive := w.Source, true
+	if w.singleFile {
+		root, recursive = filepath.Dir(w.Source), false
+	}
+	watcher, err := newCloseWriteWatcher(root, recursive)
+	if errors.Is(err, errors.ErrUnsupported) {
+		Logf(w.Name, LogLevelWarn, "Close-write events are only available on Linux, every write is treated as a change")
+		return false
+	}
+	if err != nil {
+		Logf(w.Name, LogLevelWarn, "Failed to watch for close-write events, every write is treated as a change: %v", classifyWatchError(err))
+		return false
+	}
+
+	go func() {
+		if err := watcher.run(stop, func(path string) { w.handleSourceEvent(path, closeWriteOp) }); err != nil {
+			w.mu.Lock()
+			w.handleWatchError(fmt.Errorf("error watching for close-write events: %w", err), stop)
+			w.mu.Unlock()
+		}
+	}()
+	return true
+}
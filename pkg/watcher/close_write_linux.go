@@ -0,0 +1,137 @@
+//go:build linux
+
+package watcher
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// How often the inotify file descriptor is checked for events while waiting, this is
+// also how long it can take for the watcher to notice it was stopped.
+const closeWritePollTimeout = 500
+
+// closeWriteWatcher reports files that were closed after being written using inotify,
+// fsnotify does not expose these events.
+type closeWriteWatcher struct {
+	fd        int
+	recursive bool
+	// Folder watched by each watch descriptor.
+	dirs map[int]string
+}
+
+// newCloseWriteWatcher watches root, and every folder inside it if recursive is set,
+// for files that are closed after being written.
+func newCloseWriteWatcher(root string, recursive bool) (*closeWriteWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	c := &closeWriteWatcher{fd: fd, recursive: recursive, dirs: map[int]string{}}
+	if recursive {
+		err = c.addTree(root)
+	} else {
+		err = c.add(root)
+	}
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *closeWriteWatcher) add(dir string) error {
+	wd, err := unix.InotifyAddWatch(c.fd, dir, unix.IN_CLOSE_WRITE|unix.IN_CREATE|unix.IN_MOVED_TO|unix.IN_ONLYDIR)
+	if err != nil {
+		return err
+	}
+	c.dirs[wd] = dir
+	return nil
+}
+
+// addTree watches dir and every folder inside it. Folders that disappear while they
+// are being added are skipped.
+func (c *closeWriteWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if err := c.add(path); err != nil && !errors.Is(err, unix.ENOENT) {
+			return err
+		}
+		return nil
+	})
+}
+
+// run calls closed for every file that is closed after being written until stop is
+// closed. The watcher can not be used after run returns.
+func (c *closeWriteWatcher) run(stop <-chan struct{}, closed func(path string)) error {
+	defer unix.Close(c.fd)
+	buf := make([]byte, 64*1024)
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		fds := []unix.PollFd{{Fd: int32(c.fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, closeWritePollTimeout)
+		if errors.Is(err, unix.EINTR) || n == 0 {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		n, err = unix.Read(c.fd, buf)
+		if errors.Is(err, unix.EINTR) || errors.Is(err, unix.EAGAIN) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.handleEvents(buf[:n], closed)
+	}
+}
+
+func (c *closeWriteWatcher) handleEvents(buf []byte, closed func(path string)) {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + unix.SizeofInotifyEvent
+		offset = nameStart + int(event.Len)
+		if offset > len(buf) {
+			return
+		}
+
+		dir, ok := c.dirs[int(event.Wd)]
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, strings.TrimRight(string(buf[nameStart:offset]), "\x00"))
+		switch {
+		// The folder was deleted.
+		case event.Mask&unix.IN_IGNORED != 0:
+			delete(c.dirs, int(event.Wd))
+		// New folders are watched so files saved in them are reported. Files closed
+		// before the folder is added are missed, but fsnotify still reports their
+		// creation.
+		case event.Mask&unix.IN_ISDIR != 0:
+			if c.recursive && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+				c.addTree(path)
+			}
+		case event.Mask&unix.IN_CLOSE_WRITE != 0:
+			closed(path)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+//go:build linux
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCloseWriteWatcher(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	watcher, err := newCloseWriteWatcher(dir, true)
+	if err != nil {
+		t.Fatalf("Failed to create close-write watcher: %v", err)
+	}
+	closed := make(chan string, 100)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- watcher.run(stop, func(path string) { closed <- path }) }()
+	t.Cleanup(func() {
+		close(stop)
+		if err := <-done; err != nil {
+			t.Errorf("Close-write watcher failed: %v", err)
+		}
+	})
+
+	path := filepath.Join(dir, "save.dat")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	for range 5 {
+		file.Write(make([]byte, 1024))
+	}
+	select {
+	case path := <-closed:
+		t.Fatalf("Expected no event before the file is closed, got %s", path)
+	case <-time.After(200 * time.Millisecond):
+	}
+	file.Close()
+	select {
+	case got := <-closed:
+		if got != path {
+			t.Errorf("Expected an event for %s, got %s", path, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timeout waiting for the close-write event")
+	}
+
+	// Folders created after the watcher started are watched as well, the file is
+	// rewritten until the new folder is picked up.
+	nested := filepath.Join(dir, "saves", "slot1.dat")
+	if err := os.MkdirAll(filepath.Dir(nested), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	timeout := time.After(2 * time.Second)
+	for {
+		os.WriteFile(nested, []byte("save"), 0644)
+		select {
+		case got := <-closed:
+			if got == nested {
+				return
+			}
+		case <-time.After(50 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("Timeout waiting for an event in a new folder")
+		}
+	}
+}
+
+func TestCloseWriteIgnoresWrites(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 100 * time.Millisecond
+	CreateDummyFile(t, WatcherConfig.TempPath, "save.dat", 10)
+	WatcherConfig.Source = filepath.Join(WatcherConfig.TempPath, "save.dat")
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.CloseWrite = true
+	observer := NewSimplifiedObserver()
+	watcher.AddObserver(observer)
+	if err := watcher.StartWatcher(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := watcher.StopWatcher(); err != nil {
+			t.Errorf("Failed to stop watcher: %v", err)
+		}
+	})
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Timeout waiting for the initial backup")
+	}
+
+	// Pauses between writes longer than the wait time do not start a backup until the
+	// file is closed.
+	file, err := os.OpenFile(WatcherConfig.Source, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	for range 3 {
+		file.Write(make([]byte, 1024))
+		time.Sleep(200 * time.Millisecond)
+	}
+	if count := observer.getCurrentCount(); count != 1 {
+		t.Errorf("Expected no backup while the file is being written, got %d backups", count)
+	}
+	file.Close()
+	if !observer.WaitUntilCount(2, 5*time.Second) {
+		t.Fatalf("Timeout waiting for a backup after the file was closed")
+	}
+	time.Sleep(300 * time.Millisecond)
+	if count := observer.getCurrentCount(); count != 2 {
+		t.Errorf("Expected a single backup for the closed file, got %d backups", count)
+	}
+}
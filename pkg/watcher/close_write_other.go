@@ -0,0 +1,17 @@
+//go:build !linux
+
+package watcher
+
+import "errors"
+
+// closeWriteWatcher is only implemented on Linux, other platforms back up on every
+// write.
+type closeWriteWatcher struct{}
+
+func newCloseWriteWatcher(root string, recursive bool) (*closeWriteWatcher, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (c *closeWriteWatcher) run(stop <-chan struct{}, closed func(path string)) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// BackupComparison lists how a folder differs from a backup.
+type BackupComparison struct {
+	Backup    Backup `json:"backup"`
+	Directory string `json:"directory"`
+	// Empty if the folder has the same files and contents as the backup.
+	Differences []ManifestDifference `json:"differences"`
+}
+
+// CompareBackup compares a backup with any folder, such as a manually restored copy of
+// the source on another machine. Files added by the watcher, such as the sidecar, are
+// left out of the backup and files the include patterns leave out of backups are left
+// out of the folder.
+func (w *Watcher) CompareBackup(backupID, dir string) (BackupComparison, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return BackupComparison{}, fmt.Errorf("error reading folder: %w", err)
+	}
+	if !info.IsDir() {
+		return BackupComparison{}, fmt.Errorf("%s is not a folder", dir)
+	}
+
+	backup, err := w.FindBackup(backupID)
+	if err != nil {
+		return BackupComparison{}, err
+	}
+	backupPath := w.BackupPath(backup)
+	if backup.ResticSnapshot != "" {
+		if backupPath, err = w.extractResticBackup(backup); err != nil {
+			return BackupComparison{}, err
+		}
+		defer os.RemoveAll(backupPath)
+	} else if _, err := os.Stat(backupPath); err != nil {
+		return BackupComparison{}, fmt.Errorf("error reading backup: %w", err)
+	}
+
+	generated := []string{backupSidecarName}
+	if sidecar, err := ReadBackupSidecar(backupPath); err == nil {
+		generated = sidecar.generatedFiles()
+	}
+	algorithm := w.hashAlgorithm()
+	backupManifest, err := buildManifest(backupPath, algorithm, func(relPath string) bool {
+		return slices.Contains(generated, relPath)
+	})
+	if err != nil {
+		return BackupComparison{}, err
+	}
+	if err := backupChunks(backup).expandManifest(backupManifest, backupPath, algorithm); err != nil {
+		return BackupComparison{}, err
+	}
+
+	w.mu.Lock()
+	include := w.Include
+	w.mu.Unlock()
+	dirManifest, err := buildManifest(dir, algorithm, func(relPath string) bool {
+		path := filepath.Join(dir, filepath.FromSlash(relPath))
+		return !include.includesEvent(dir, path)
+	})
+	if err != nil {
+		return BackupComparison{}, err
+	}
+
+	return BackupComparison{
+		Backup:      backup,
+		Directory:   dir,
+		Differences: compareManifests(backupManifest, dirManifest),
+	}, nil
+}
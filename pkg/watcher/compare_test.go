@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	cp "github.com/otiai10/copy"
+)
+
+func TestCompareManifests(t *testing.T) {
+	t.Parallel()
+	base := Manifest{
+		{Path: "same.txt", Size: 1, Hash: "a"},
+		{Path: "changed.txt", Size: 1, Hash: "b"},
+		{Path: "missing.txt", Size: 1, Hash: "c"},
+		{Path: "folder", Dir: true},
+	}
+	other := Manifest{
+		{Path: "same.txt", Size: 1, Hash: "a"},
+		{Path: "changed.txt", Size: 1, Hash: "d"},
+		{Path: "added.txt", Size: 1, Hash: "e"},
+		{Path: "folder", Size: 1, Hash: "f"},
+	}
+	expected := []ManifestDifference{
+		{"added.txt", DifferenceAdded},
+		{"changed.txt", DifferenceChanged},
+		{"folder", DifferenceChanged},
+		{"missing.txt", DifferenceMissing},
+	}
+	if differences := compareManifests(base, other); !slices.Equal(differences, expected) {
+		t.Errorf("Expected %v, got %v", expected, differences)
+	}
+}
+
+func TestCompareBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	CreateDummyFile(t, WatcherConfig.Source, filepath.Join("folder", "file2.txt"), 10)
+	backup, _ := watcher.createTriggeredBackup(BackupTriggerManual)
+
+	// A copy of the source somewhere else matches, the sidecar in the backup is not a
+	// difference.
+	copyPath := filepath.Join(WatcherConfig.TempPath, "copy")
+	if err := cp.Copy(WatcherConfig.Source, copyPath); err != nil {
+		t.Fatalf("Failed to copy source: %v", err)
+	}
+	comparison, err := watcher.CompareBackup("1", copyPath)
+	if err != nil {
+		t.Fatalf("Failed to compare backup: %v", err)
+	}
+	if len(comparison.Differences) != 0 || comparison.Backup.Path != backup.Path {
+		t.Errorf("Expected the copy to match the backup, got %+v", comparison)
+	}
+
+	CreateDummyFile(t, copyPath, "file1.txt", 20)
+	os.Remove(filepath.Join(copyPath, "folder", "file2.txt"))
+	CreateDummyFile(t, copyPath, "file3.txt", 10)
+	comparison, err = watcher.CompareBackup(backup.Path, copyPath)
+	if err != nil {
+		t.Fatalf("Failed to compare backup: %v", err)
+	}
+	expected := []ManifestDifference{
+		{"file1.txt", DifferenceChanged},
+		{"file3.txt", DifferenceAdded},
+		{"folder/file2.txt", DifferenceMissing},
+	}
+	if !slices.Equal(comparison.Differences, expected) {
+		t.Errorf("Expected %v, got %v", expected, comparison.Differences)
+	}
+
+	if _, err := watcher.CompareBackup("1", filepath.Join(copyPath, "file3.txt")); err == nil {
+		t.Errorf("Expected an error when comparing with a file")
+	}
+	if _, err := watcher.CompareBackup("2", copyPath); err == nil {
+		t.Errorf("Expected an error for a backup that does not exist")
+	}
+}
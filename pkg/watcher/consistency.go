@@ -0,0 +1,44 @@
+package watcher
+
+import (
+	"path/filepath"
+	"slices"
+)
+
+// sourceState returns the size and modification time of every included file in the
+// source, which is compared before the copy and once the backup is finished to find
+// files that changed while it was made.
+func sourceState(source string, include IncludePatterns) (map[string]string, error) {
+	files, err := sourceFiles(source)
+	if err != nil {
+		return nil, err
+	}
+	files = slices.DeleteFunc(files, func(file string) bool {
+		return !include.includesFile(source, file)
+	})
+	return fileSizes(osFS{}, files), nil
+}
+
+// changedDuringBackup returns the files that were changed, created or removed between
+// the two states, relative to root and using forward slashes. A backup containing
+// them may mix old and new versions of the source.
+func changedDuringBackup(root string, before, after map[string]string) []string {
+	changed := []string{}
+	add := func(path string) {
+		if relPath, err := filepath.Rel(root, path); err == nil {
+			changed = append(changed, filepath.ToSlash(relPath))
+		}
+	}
+	for path, state := range before {
+		if after[path] != state {
+			add(path)
+		}
+	}
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			add(path)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
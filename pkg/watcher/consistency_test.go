@@ -0,0 +1,111 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestChangedDuringCopy(t *testing.T) {
+	t.Parallel()
+	root := filepath.Join("/", "source")
+	before := map[string]string{
+		filepath.Join(root, "same.txt"):    "10|1",
+		filepath.Join(root, "changed.txt"): "10|1",
+		filepath.Join(root, "removed.txt"): "10|1",
+	}
+	after := map[string]string{
+		filepath.Join(root, "same.txt"):              "10|1",
+		filepath.Join(root, "changed.txt"):           "20|2",
+		filepath.Join(root, "folder", "created.txt"): "5|3",
+	}
+	expected := []string{"changed.txt", "folder/created.txt", "removed.txt"}
+	if changed := changedDuringBackup(root, before, after); !slices.Equal(changed, expected) {
+		t.Errorf("Expected %v, got %v", expected, changed)
+	}
+}
+
+// changingCopyEngine appends to a file in the source after copying it, like a program
+// writing to the source while a backup is made.
+type changingCopyEngine struct {
+	goCopyEngine
+	path string
+}
+
+func (e changingCopyEngine) Copy(source, destination string) error {
+	if err := e.goCopyEngine.Copy(source, destination); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(e.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write([]byte("more"))
+	return err
+}
+
+func TestFuzzyBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "stable.txt", 10)
+	CreateDummyFile(t, WatcherConfig.Source, filepath.Join("saves", "game.sav"), 10)
+
+	watcher.CreateBackup()
+	if backup := watcher.Metadata[0]; backup.Fuzzy || len(backup.FuzzyPaths) != 0 {
+		t.Errorf("Expected a backup of an unchanged source to not be fuzzy, got %+v", backup)
+	}
+
+	watcher.CopyEngine = changingCopyEngine{path: filepath.Join(WatcherConfig.Source, "saves", "game.sav")}
+	watcher.CreateBackup()
+	backup := watcher.Metadata[1]
+	if !backup.Fuzzy || !slices.Equal(backup.FuzzyPaths, []string{"saves/game.sav"}) {
+		t.Errorf("Expected the backup to be fuzzy because of saves/game.sav, got %+v", backup)
+	}
+
+	metadata, err := loadDestinationMetadata(WatcherConfig.Destination)
+	if err != nil {
+		t.Fatalf("Failed to load metadata: %v", err)
+	}
+	if !metadata[1].Fuzzy {
+		t.Errorf("Expected the fuzzy flag to be saved in the metadata")
+	}
+}
+
+// changingTransformer appends to a file in the source while the backup is transformed,
+// after the copy has finished.
+type changingTransformer struct {
+	path string
+}
+
+func (t changingTransformer) TransformFile(relPath string, contents []byte) ([]byte, error) {
+	file, err := os.OpenFile(t.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	_, err = file.Write([]byte("more"))
+	return contents, err
+}
+
+func TestFuzzyBackupAfterCopy(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	path := filepath.Join(WatcherConfig.Source, "game.sav")
+	watcher, err := NewTempWatcher(WatcherConfig, WithBackupTransformer(changingTransformer{path: path}))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "game.sav", 10)
+
+	// Changes are recorded until the backup is finished, not only while it is copied.
+	watcher.CreateBackup()
+	if backup := watcher.Metadata[0]; !backup.Fuzzy || !slices.Equal(backup.FuzzyPaths, []string{"game.sav"}) {
+		t.Errorf("Expected the backup to be fuzzy because of game.sav, got %+v", backup)
+	}
+}
@@ -0,0 +1,185 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	cp "github.com/otiai10/copy"
+)
+
+const (
+	CopyEngineGo       = "go"
+	CopyEngineRsync    = "rsync"
+	CopyEngineRobocopy = "robocopy"
+)
+
+// CopyEngine copies the contents of the source directory into the destination
+// directory, preserving modification times. If the source is a single file it is
+// copied to the destination path, the parent of the destination must already exist.
+type CopyEngine interface {
+	Name() string
+	Copy(source, destination string) error
+}
+
+// newCopyEngine returns the copy engine with the given name. External engines are only
+// returned if their executable can be found.
+func newCopyEngine(name string) (CopyEngine, error) {
+	switch strings.ToLower(name) {
+	case "", CopyEngineGo:
+		return goCopyEngine{}, nil
+	case CopyEngineRsync:
+		path, err := exec.LookPath("rsync")
+		if err != nil {
+			return nil, fmt.Errorf("rsync copy engine is not available: %w", err)
+		}
+		return rsyncCopyEngine{path: path}, nil
+	case CopyEngineRobocopy:
+		path, err := exec.LookPath("robocopy")
+		if err != nil {
+			return nil, fmt.Errorf("robocopy copy engine is not available: %w", err)
+		}
+		return robocopyCopyEngine{path: path}, nil
+	}
+	return nil, fmt.Errorf("unknown copy engine: %s", name)
+}
+
+// Copies files using otiai10/copy, this works everywhere without any external tools.
+type goCopyEngine struct {
+	// How cloud-only placeholders in the source are copied.
+	placeholders PlaceholderPolicy
+	// What happens to files that can not be read.
+	permissions PermissionPolicy
+	// Only files matching these patterns are copied, if any are set.
+	include IncludePatterns
+	// Backup that files which did not change are cloned from, if set.
+	previous string
+	// Files are only copied if every filter includes them.
+	filters []FileFilter
+	// Keep the files an earlier attempt copied, set by withResume.
+	resume bool
+	// What the destination can not keep, set by withDegradation.
+	unsupported []Attribute
+	// Buffer size and preallocation of copied files.
+	options CopyOptions
+	// Large files are stored as chunks in chunkStore and recorded in chunkLog, which
+	// are set by withChunkStore.
+	chunking   ChunkingConfig
+	chunkStore chunkStore
+	chunkLog   *chunkLog
+}
+
+func (goCopyEngine) Name() string {
+	return CopyEngineGo
+}
+
+func (e goCopyEngine) Copy(source, destination string) error {
+	skip := func(info os.FileInfo, src, dest string) (bool, error) {
+		if !info.IsDir() && (!e.include.includesFile(source, src) || !includedByFilters(e.filters, source, src, info)) {
+			return true, nil
+		}
+		if skipped, err := e.placeholders.skipEntry(info, dest); skipped || err != nil {
+			return skipped, err
+		}
+		if chunked, err := e.copyChunked(info, source, src, dest); chunked || err != nil {
+			return chunked, err
+		}
+		if e.resume && resumeCopy(info, src, dest) {
+			return true, nil
+		}
+		if e.clonePrevious(info, source, src, dest) {
+			return true, nil
+		}
+		return e.copyPreallocated(info, src, dest), nil
+	}
+
+	// Skip is only called for the entries inside a folder, a single file source is
+	// checked here.
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if skipped, err := skip(info, source, destination); skipped || err != nil {
+			return err
+		}
+	}
+	denied := []deniedCopy{}
+	err = cp.Copy(source, destination, degradedCopyOptions(cp.Options{
+		PreserveTimes:  true,
+		Skip:           skip,
+		OnError:        e.permissions.collectDenied(&denied),
+		CopyBufferSize: uint(e.options.BufferSize),
+	}, e.unsupported))
+	if err != nil {
+		return err
+	}
+	return e.permissions.handleDenied(denied)
+}
+
+type rsyncCopyEngine struct {
+	path string
+	// What the destination can not keep, set by withDegradation.
+	unsupported []Attribute
+}
+
+func (rsyncCopyEngine) Name() string {
+	return CopyEngineRsync
+}
+
+func (e rsyncCopyEngine) Copy(source, destination string) error {
+	// The trailing separator makes rsync copy the contents of source instead of the
+	// source directory itself.
+	if !isRegularFile(source) {
+		source = strings.TrimSuffix(source, string(filepath.Separator)) + string(filepath.Separator)
+	}
+	args := append([]string{"--archive"}, rsyncDegradationArgs(e.unsupported)...)
+	output, err := exec.Command(e.path, append(args, source, destination)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("rsync failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+type robocopyCopyEngine struct {
+	path string
+	// Copy files in restartable mode so a partly copied file is continued, set by
+	// withResume.
+	restartable bool
+}
+
+func (robocopyCopyEngine) Name() string {
+	return CopyEngineRobocopy
+}
+
+func (e robocopyCopyEngine) Copy(source, destination string) error {
+	args := []string{source, destination, "/E"}
+	// robocopy only copies directories, a single file is copied by naming it as a
+	// filter on its parent directory.
+	if isRegularFile(source) {
+		if filepath.Base(source) != filepath.Base(destination) {
+			return fmt.Errorf("robocopy cannot rename %s while copying", filepath.Base(source))
+		}
+		args = []string{filepath.Dir(source), filepath.Dir(destination), filepath.Base(source)}
+	}
+	args = append(args, "/COPY:DAT", "/DCOPY:T", "/MT", "/R:0", "/NP", "/NFL", "/NDL")
+	if e.restartable {
+		args = append(args, "/Z")
+	}
+
+	output, err := exec.Command(e.path, args...).CombinedOutput()
+
+	// robocopy uses exit codes below 8 for successful copies, the lower bits describe
+	// what was copied.
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() < 8 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("robocopy failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
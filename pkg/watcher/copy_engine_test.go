@@ -0,0 +1,35 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyEngines(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{CopyEngineGo, CopyEngineRsync, CopyEngineRobocopy} {
+		engine, err := newCopyEngine(name)
+		if err != nil {
+			t.Logf("Skipping %s: %v", name, err)
+			continue
+		}
+
+		tempPath := t.TempDir()
+		source := filepath.Join(tempPath, "source")
+		destination := filepath.Join(tempPath, "destination")
+		CreateDummyFile(t, source, "file.txt", 1024)
+		CreateDummyFile(t, source, "subfolder/file.txt", 1024)
+
+		if err := engine.Copy(source, destination); err != nil {
+			t.Fatalf("%s: Failed to copy: %v", name, err)
+		}
+		CompareSourceAndDestination(t, source, destination)
+	}
+}
+
+func TestUnknownCopyEngine(t *testing.T) {
+	t.Parallel()
+	if _, err := newCopyEngine("teleport"); err == nil {
+		t.Errorf("Expected an error for an unknown copy engine")
+	}
+}
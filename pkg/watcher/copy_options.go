@@ -0,0 +1,87 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// CopyOptions tunes how the go copy engine writes files.
+type CopyOptions struct {
+	// Bytes read and written at a time, 0 lets the operating system copy files itself
+	// where it can, which is usually fastest. A large buffer can be faster on slow
+	// network drives.
+	BufferSize int64 `json:"buffer_size,omitempty" yaml:"buffer_size,omitempty" toml:"buffer_size,omitempty"`
+	// Files of at least this many bytes have their full size reserved on the
+	// destination before they are written, which keeps big files in one piece. 0 turns
+	// it off.
+	PreallocateSize int64 `json:"preallocate_size,omitempty" yaml:"preallocate_size,omitempty" toml:"preallocate_size,omitempty"`
+}
+
+func (o CopyOptions) validate() error {
+	if o.BufferSize < 0 {
+		return fmt.Errorf("copy buffer size must be at least 0 bytes")
+	}
+	if o.PreallocateSize < 0 {
+		return fmt.Errorf("copy preallocate size must be at least 0 bytes")
+	}
+	return nil
+}
+
+// apply returns a copy engine that uses the options. External copy engines manage their
+// own buffers so only the go engine supports them.
+func (o CopyOptions) apply(engine CopyEngine) (CopyEngine, error) {
+	if o == (CopyOptions{}) {
+		return engine, nil
+	}
+	goEngine, ok := engine.(goCopyEngine)
+	if !ok {
+		return nil, fmt.Errorf("copy options are only supported by the %s copy engine", CopyEngineGo)
+	}
+	goEngine.options = o
+	return goEngine, nil
+}
+
+// copyPreallocated copies src, which has info, to destination if it is large enough to
+// be preallocated. False is returned if the file has to be copied normally, which is
+// also done if copying it here fails.
+func (e goCopyEngine) copyPreallocated(info os.FileInfo, src, destination string) bool {
+	if e.options.PreallocateSize == 0 || !info.Mode().IsRegular() || info.Size() < e.options.PreallocateSize {
+		return false
+	}
+	err := copyFileData(src, destination, info.Size(), e.options.BufferSize)
+	if err == nil {
+		err = e.finishFile(info, destination)
+	}
+	if err != nil {
+		Logf("", LogLevelDebug, "Copying %s without preallocating it: %v", src, err)
+		os.Remove(destination)
+		return false
+	}
+	return true
+}
+
+// copyFileData writes the contents of src to destination after reserving size bytes
+// for it. Destinations that can not reserve space are written to anyway.
+func copyFileData(src, destination string, size, bufferSize int64) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := preallocate(target, size); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		Logf("", LogLevelDebug, "Error preallocating %s: %v", destination, err)
+	}
+	if bufferSize > 0 {
+		// Hiding ReadFrom makes the copy use the buffer instead of the operating system.
+		_, err = io.CopyBuffer(struct{ io.Writer }{target}, source, make([]byte, bufferSize))
+	} else {
+		_, err = io.Copy(target, source)
+	}
+	return errors.Join(err, target.Close())
+}
@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGoCopyEnginePreallocates(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source")
+	destination := filepath.Join(dir, "destination")
+	CreateDummyFile(t, source, "large.bin", 1<<20)
+	CreateDummyFile(t, source, "folder/small.txt", 100)
+
+	engine, err := (CopyOptions{BufferSize: 4096, PreallocateSize: 64 << 10}).apply(goCopyEngine{})
+	if err != nil {
+		t.Fatalf("Failed to apply copy options: %v", err)
+	}
+	if err := engine.Copy(source, destination); err != nil {
+		t.Fatalf("Failed to copy: %v", err)
+	}
+	for _, name := range []string{"large.bin", filepath.Join("folder", "small.txt")} {
+		expected, err := os.ReadFile(filepath.Join(source, name))
+		if err != nil {
+			t.Fatalf("Failed to read source: %v", err)
+		}
+		if actual, err := os.ReadFile(filepath.Join(destination, name)); err != nil || !bytes.Equal(actual, expected) {
+			t.Errorf("Expected %s to match the source: %v", name, err)
+		}
+		sourceInfo, _ := os.Stat(filepath.Join(source, name))
+		if info, err := os.Stat(filepath.Join(destination, name)); err != nil || !info.ModTime().Equal(sourceInfo.ModTime()) || info.Mode() != sourceInfo.Mode() {
+			t.Errorf("Expected %s to keep the time and mode of the source: %v", name, err)
+		}
+	}
+}
+
+func TestCopyOptionsValidation(t *testing.T) {
+	t.Parallel()
+	if err := (CopyOptions{BufferSize: -1}).validate(); err == nil {
+		t.Error("Expected a negative buffer size to be rejected")
+	}
+	if err := (CopyOptions{PreallocateSize: -1}).validate(); err == nil {
+		t.Error("Expected a negative preallocate size to be rejected")
+	}
+	if _, err := (CopyOptions{BufferSize: 4096}).apply(rsyncCopyEngine{}); err == nil {
+		t.Error("Expected copy options to be rejected by the rsync engine")
+	}
+	if engine, err := (CopyOptions{}).apply(rsyncCopyEngine{}); err != nil || engine.Name() != CopyEngineRsync {
+		t.Errorf("Expected no copy options to keep the engine, got %v", err)
+	}
+}
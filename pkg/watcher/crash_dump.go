@@ -0,0 +1,126 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+)
+
+// panicError is a panic recovered in a goroutine of the watcher.
+type panicError struct {
+	value any
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// runRecovered runs task and returns a panic in it as an error.
+func runRecovered(task func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r, stack: debug.Stack()}
+		}
+	}()
+	return task()
+}
+
+// crashState is the part of the watcher written to a crash dump.
+type crashState struct {
+	Source       string        `json:"source"`
+	Destinations []string      `json:"destinations"`
+	Running      bool          `json:"running"`
+	Status       WatcherStatus `json:"status"`
+	Backups      int           `json:"backups"`
+	LatestBackup *Backup       `json:"latest_backup,omitempty"`
+	// Changes seen since the last backup and backup requests the backup thread has not
+	// taken yet.
+	ChangedPaths    []string `json:"changed_paths"`
+	PendingRequests int      `json:"pending_requests"`
+	UnsavedChanges  bool     `json:"unsaved_changes"`
+}
+
+// writeCrashDump writes what is known about a panic in a thread of the watcher to the
+// crash dump folder and returns the path of the dump, empty if there is no folder or
+// it could not be written. The stack is logged either way.
+func (w *Watcher) writeCrashDump(thread string, crash *panicError) string {
+	Logf(w.Name, LogLevelError, "The %s crashed: %v\n%s", thread, crash.value, crash.stack)
+	if w.crashDumpDir == "" {
+		return ""
+	}
+
+	now := time.Now()
+	var report bytes.Buffer
+	fmt.Fprintf(&report, "i-saw-that crash dump\n\n")
+	fmt.Fprintf(&report, "Time:    %s\n", now.Format(time.RFC3339Nano))
+	fmt.Fprintf(&report, "Watcher: %s\n", w.Name)
+	fmt.Fprintf(&report, "Thread:  %s\n", thread)
+	fmt.Fprintf(&report, "Panic:   %v\n\n", crash.value)
+	fmt.Fprintf(&report, "Stack of the panic:\n%s\n", crash.stack)
+
+	// The panic may have left the lock held, the state is left out rather than waiting
+	// for it forever.
+	if tryLock(&w.mu, time.Second) {
+		state := crashState{
+			Source:          w.Source,
+			Destinations:    w.destinations(),
+			Running:         w.running,
+			Status:          w.status,
+			Backups:         len(w.Metadata),
+			PendingRequests: len(w.backupRequestChan) + len(w.urgentBackupChan),
+			UnsavedChanges:  w.unsavedChanges,
+		}
+		if len(w.Metadata) > 0 {
+			state.LatestBackup = &w.Metadata[len(w.Metadata)-1]
+		}
+		for path := range w.changedPaths {
+			state.ChangedPaths = append(state.ChangedPaths, path)
+		}
+		slices.Sort(state.ChangedPaths)
+		data, err := json.MarshalIndent(state, "", "  ")
+		w.mu.Unlock()
+		if err != nil {
+			fmt.Fprintf(&report, "Watcher state could not be written: %v\n\n", err)
+		} else {
+			fmt.Fprintf(&report, "Watcher state:\n%s\n\n", data)
+		}
+	} else {
+		fmt.Fprintf(&report, "Watcher state: left out, the lock of the watcher is held\n\n")
+	}
+
+	stacks := make([]byte, 1<<20)
+	stacks = stacks[:runtime.Stack(stacks, true)]
+	fmt.Fprintf(&report, "Goroutines:\n%s\n", stacks)
+
+	if err := os.MkdirAll(w.crashDumpDir, 0755); err != nil {
+		Logf(w.Name, LogLevelError, "Error writing crash dump: %v", err)
+		return ""
+	}
+	name := fmt.Sprintf("crash-%s-%s.txt", safeName(w.Name), now.Format("2006-01-02_15-04-05.000000"))
+	path := filepath.Join(w.crashDumpDir, name)
+	if err := os.WriteFile(path, report.Bytes(), 0644); err != nil {
+		Logf(w.Name, LogLevelError, "Error writing crash dump: %v", err)
+		return ""
+	}
+	return path
+}
+
+// tryLock locks mu if it becomes free within timeout.
+func tryLock(mu *sync.Mutex, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for !mu.TryLock() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
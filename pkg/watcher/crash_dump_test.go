@@ -0,0 +1,53 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCrashDump(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	dir := t.TempDir()
+	watcher, err := NewTempWatcher(WatcherConfig, WithCrashDumpDir(dir))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.restartDelay = time.Hour
+	watcher.changedPaths[filepath.Join(WatcherConfig.Source, "changed.txt")] = true
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		watcher.supervise("backup thread", stop, func() error {
+			var backups []Backup
+			_ = backups[1]
+			return nil
+		})
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+
+	waitUntil(t, "Expected a crash dump to be written", func() bool {
+		return watcher.Status().CrashDump != ""
+	})
+	status := watcher.Status()
+	if !strings.Contains(status.Crashed, "The backup thread crashed: runtime error: index out of range") {
+		t.Errorf("Expected the status to show the crash, got %q", status.Crashed)
+	}
+	data, err := os.ReadFile(status.CrashDump)
+	if err != nil {
+		t.Fatalf("Failed to read crash dump: %v", err)
+	}
+	dump := string(data)
+	for _, expected := range []string{"Thread:  backup thread", "index out of range", "crash_dump_test.go", WatcherConfig.Source, "changed.txt", "Goroutines:"} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("Expected the crash dump to contain %q:\n%s", expected, dump)
+		}
+	}
+}
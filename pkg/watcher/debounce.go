@@ -0,0 +1,45 @@
+package watcher
+
+import (
+	"fmt"
+	"time"
+)
+
+// DebounceStrategy decides when a backup is made after the source changes.
+type DebounceStrategy string
+
+const (
+	// Wait until there have been no changes for the wait time. Continuous changes
+	// postpone the backup until they stop.
+	DebounceTrailing DebounceStrategy = "trailing"
+	// Back up as soon as the source changes, then wait for the wait time before the
+	// next backup. Changes made while waiting are backed up once the wait is over.
+	DebounceLeading DebounceStrategy = "leading"
+	// The same as trailing, but a backup is made once changes have continued for the
+	// max wait even if they have not stopped.
+	DebounceMaxWait DebounceStrategy = "max-wait"
+)
+
+// DebounceConfig selects the debounce strategy, the default is trailing.
+type DebounceConfig struct {
+	Strategy DebounceStrategy `json:"strategy,omitempty" yaml:"strategy,omitempty" toml:"strategy,omitempty"`
+	// Longest time changes can postpone a backup, only used by max-wait.
+	MaxWait Duration `json:"max_wait,omitempty" yaml:"max_wait,omitempty" toml:"max_wait,omitempty"`
+}
+
+func (c DebounceConfig) validate() error {
+	switch c.Strategy {
+	case "", DebounceTrailing, DebounceLeading:
+		return nil
+	case DebounceMaxWait:
+		if c.MaxWait <= 0 {
+			return fmt.Errorf("max wait must be greater than 0 when using the max-wait debounce strategy")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown debounce strategy: %s", c.Strategy)
+}
+
+func (c DebounceConfig) maxWait() time.Duration {
+	return time.Duration(c.MaxWait)
+}
@@ -0,0 +1,106 @@
+package watcher
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// startBackupLoop runs only the backup thread of a watcher so backups can be requested
+// without file events.
+func startBackupLoop(t *testing.T, watcher *Watcher) *SimplifiedObserver {
+	observer := NewSimplifiedObserver()
+	watcher.AddObserver(observer)
+	stop, done := make(chan struct{}), make(chan struct{})
+	go watcher.runBackupLoop(stop, done)
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+	return observer
+}
+
+// waitForRequest waits until the backup loop has received the last requested backup,
+// the loop handles it before any timer a fake clock fires afterwards.
+func waitForRequest(watcher *Watcher) {
+	for len(watcher.backupRequestChan) > 0 {
+		runtime.Gosched()
+	}
+}
+
+func TestLeadingDebounce(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = time.Second
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Debounce = DebounceConfig{Strategy: DebounceLeading}
+	observer := startBackupLoop(t, watcher)
+
+	watcher.requestBackup(BackupTriggerChange)
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected a backup right away")
+	}
+
+	// Changes during the cool down are backed up once it ends.
+	clock.BlockUntil(1)
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+	clock.Advance(900 * time.Millisecond)
+	if count := observer.getCurrentCount(); count != 1 {
+		t.Fatalf("Expected no backup during the cool down, got %d", count)
+	}
+	clock.Advance(100 * time.Millisecond)
+	if !observer.WaitUntilCount(2, 5*time.Second) {
+		t.Fatalf("Expected a backup after the cool down")
+	}
+}
+
+func TestMaxWaitDebounce(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 500 * time.Millisecond
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Debounce = DebounceConfig{Strategy: DebounceMaxWait, MaxWait: Duration(time.Second)}
+	observer := startBackupLoop(t, watcher)
+
+	// Changes never settle for the wait time, only the max wait causes a backup.
+	change := func(steps int) {
+		for range steps {
+			watcher.requestBackup(BackupTriggerChange)
+			waitForRequest(watcher)
+			clock.Advance(100 * time.Millisecond)
+		}
+	}
+	change(9)
+	if count := observer.getCurrentCount(); count != 0 {
+		t.Fatalf("Expected the backup to wait for the max wait, got %d backups", count)
+	}
+	change(3)
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected continuous changes to be backed up after the max wait")
+	}
+}
+
+func TestDebounceConfigValidation(t *testing.T) {
+	t.Parallel()
+	invalid := []DebounceConfig{
+		{Strategy: "sometimes"},
+		{Strategy: DebounceMaxWait},
+	}
+	for _, config := range invalid {
+		if err := config.validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", config)
+		}
+	}
+	if err := (DebounceConfig{Strategy: DebounceMaxWait, MaxWait: Duration(30 * time.Second)}).validate(); err != nil {
+		t.Errorf("Expected a max wait config to be valid: %v", err)
+	}
+}
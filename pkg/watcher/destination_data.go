@@ -0,0 +1,69 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DestinationData describes what a folder pair stores in its destinations, it is shown
+// before the pair is removed so deleting its backups can be confirmed.
+type DestinationData struct {
+	Destinations []string `json:"destinations"`
+	Backups      int      `json:"backups"`
+	// Total size of the backups in bytes, restic and ZFS backups are not included
+	// since their data is shared with other snapshots.
+	Size int64 `json:"size"`
+}
+
+// DestinationData measures the backups of the watcher.
+func (w *Watcher) DestinationData() DestinationData {
+	backups := w.ListBackups()
+	data := DestinationData{Destinations: w.destinations(), Backups: len(backups)}
+	for _, backup := range backups {
+		if backup.ResticSnapshot != "" || backup.Snapshot == SnapshotModeZFS {
+			continue
+		}
+		// Missing backups are simply not counted.
+		if size, err := DirSize(w.BackupPath(backup)); err == nil {
+			data.Size += size
+		}
+	}
+	return data
+}
+
+// DeleteDestinationData deletes every backup of the watcher along with its metadata,
+// history, journal and sequence files. Destination folders that are left empty are
+// removed. The watcher must not be running.
+func (w *Watcher) DeleteDestinationData() error {
+	var errs error
+	failed := map[string]bool{}
+	for _, backup := range w.ListBackups() {
+		if err := w.deleteBackup(backup); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error deleting backup %s: %w", backup.Path, err))
+			failed[backup.Destination] = true
+			continue
+		}
+		Logf(w.Name, LogLevelInfo, "Deleted backup %s", w.BackupPath(backup))
+	}
+
+	for _, destination := range w.destinations() {
+		// The metadata is kept for destinations with backups that could not be deleted
+		// so they are not left untracked.
+		if failed[destination] {
+			continue
+		}
+		for _, name := range stateFileNames {
+			if err := os.Remove(statePath(destination, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = errors.Join(errs, err)
+			}
+		}
+		if err := os.RemoveAll(chunkDir(destination)); err != nil {
+			errs = errors.Join(errs, err)
+		}
+		// Remove fails if anything else is in the folders, which is left alone.
+		os.Remove(stateDir(destination))
+		os.Remove(destination)
+	}
+	return errs
+}
@@ -0,0 +1,29 @@
+package watcher
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDeleteDestinationDataKeepsOtherFiles(t *testing.T) {
+	t.Parallel()
+	temp := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(temp)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, temp.Source, "file1.txt", 10)
+	watcher.CreateBackup()
+	CreateDummyFile(t, temp.Destination, "notes.txt", 10)
+
+	if err := watcher.DeleteDestinationData(); err != nil {
+		t.Fatalf("Failed to delete destination data: %v", err)
+	}
+	entries, err := os.ReadDir(temp.Destination)
+	if err != nil {
+		t.Fatalf("Expected the destination to be kept: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "notes.txt" {
+		t.Errorf("Expected only notes.txt to be left, got %v", entries)
+	}
+}
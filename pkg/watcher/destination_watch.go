@@ -0,0 +1,241 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Time to wait for changes to the destination to settle before reconciling metadata.
+const reconcileDelay = 2 * time.Second
+
+// Events for a backup folder are ignored for this long after the backup completes
+// because fsnotify delivers them after the copy has finished.
+const ownWriteGracePeriod = 5 * time.Second
+
+// Optional interface for observers that also want to be told about problems the
+// watcher notices, such as backups being modified outside of the watcher.
+type WarningObserver interface {
+	OnWarning(watcher *Watcher, message string)
+}
+
+// notifyWarning logs a warning and queues it for every observer that implements
+// WarningObserver. Must not be called while holding w.mu.
+func (w *Watcher) notifyWarning(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	Logf(w.Name, LogLevelWarn, "%s", message)
+
+	for _, observer := range w.observers() {
+		if warningObserver, ok := observer.(WarningObserver); ok {
+			w.dispatch(func() { warningObserver.OnWarning(w, message) })
+		}
+	}
+}
+
+// startDestinationWatcher watches the destinations for changes made by anything other
+// than the watcher. Must be called while holding w.mu.
+func (w *Watcher) startDestinationWatcher(stop chan struct{}) {
+	fsnotifyWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		Logf(w.Name, LogLevelError, "Error creating destination watcher: %v", classifyWatchError(err))
+		return
+	}
+
+	for _, destination := range w.destinations() {
+		// Fall back to only watching the top level of the destination if recursive
+		// watching is not supported, this still catches deleted backup folders.
+		if err := fsnotifyWatcher.Add(filepath.Join(destination, "...")); err != nil {
+			if err := fsnotifyWatcher.Add(destination); err != nil {
+				Logf(w.Name, LogLevelError, "Error watching destination %s: %v", destination, classifyWatchError(err))
+			}
+		}
+	}
+
+	go w.watchDestinationEvents(fsnotifyWatcher, stop)
+}
+
+func (w *Watcher) watchDestinationEvents(fsnotifyWatcher *fsnotify.Watcher, stop chan struct{}) {
+	defer fsnotifyWatcher.Close()
+
+	for {
+		select {
+		case event, ok := <-fsnotifyWatcher.Events:
+			if !ok {
+				return
+			}
+			if w.isOwnDestinationWrite(event.Name) {
+				continue
+			}
+			Logf(w.Name, LogLevelDebug, "Destination event detected: %s, Op: %s", event.Name, event.Op)
+
+			w.mu.Lock()
+			if backup, ok := w.backupContaining(event.Name); ok {
+				w.externallyModified[backup.Path] = true
+			}
+			w.mu.Unlock()
+			w.requestReconcile()
+		case err, ok := <-fsnotifyWatcher.Errors:
+			if !ok {
+				return
+			}
+			Logf(w.Name, LogLevelError, "Error watching destination: %v", classifyWatchError(err))
+		case <-stop:
+			return
+		}
+	}
+}
+
+// isOwnDestinationWrite returns true for events caused by the watcher writing backups,
+// metadata or self test probes.
+func (w *Watcher) isOwnDestinationWrite(path string) bool {
+	if isStatePath(path) || isSelfTestProbe(path) || w.isTrashPath(path) || w.isChunkPath(path) {
+		return true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Parents are included for the year and month folders of nested layouts.
+	if w.activeBackupPath != "" && (isPathWithin(w.activeBackupPath, path) || isPathWithin(path, w.activeBackupPath)) {
+		return true
+	}
+	for backupPath, completed := range w.recentBackups {
+		if w.clock.Now().Sub(completed) > ownWriteGracePeriod {
+			delete(w.recentBackups, backupPath)
+			continue
+		}
+		if isPathWithin(backupPath, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// backupContaining returns the backup that a path belongs to. Must be called while
+// holding w.mu.
+func (w *Watcher) backupContaining(path string) (Backup, bool) {
+	for _, backup := range w.Metadata {
+		if isPathWithin(w.BackupPath(backup), path) {
+			return backup, true
+		}
+	}
+	return Backup{}, false
+}
+
+func isPathWithin(parent, path string) bool {
+	relPath, err := filepath.Rel(parent, path)
+	return err == nil && !filepath.IsAbs(relPath) && !strings.HasPrefix(relPath, "..")
+}
+
+func (w *Watcher) requestReconcile() {
+	select {
+	case w.reconcileRequestChan <- struct{}{}:
+	default:
+	}
+}
+
+// reconcileMetadata compares the metadata with what is actually in the destinations.
+// Backups that were deleted are removed from the metadata, a metadata file that was
+// changed is rewritten and anything else that changed is reported as a warning. This is
+// only called from the backup thread so it never runs at the same time as a backup.
+func (w *Watcher) reconcileMetadata() {
+	for _, destination := range w.destinations() {
+		if _, err := os.Stat(destination); err != nil {
+			continue
+		}
+		needsSave := false
+
+		onDisk, err := loadDestinationMetadata(destination)
+		if err != nil {
+			w.notifyWarning("Metadata in %s could not be read, rewriting it: %v", destination, err)
+			needsSave = true
+		}
+
+		w.mu.Lock()
+		known := map[string]bool{}
+		// First folder of each backup path, nested layouts share year and month folders.
+		knownFolders := map[string]bool{}
+		kept := []Backup{}
+		missing := []string{}
+		inMemory := []Backup{}
+		for _, backup := range w.Metadata {
+			if backup.Destination != destination {
+				kept = append(kept, backup)
+				continue
+			}
+			if backup.ResticSnapshot == "" {
+				if _, err := os.Stat(w.BackupPath(backup)); os.IsNotExist(err) {
+					missing = append(missing, backup.Path)
+					continue
+				}
+			}
+			known[backup.Path] = true
+			knownFolders[strings.SplitN(backup.Path, "/", 2)[0]] = true
+			kept = append(kept, backup)
+			inMemory = append(inMemory, backup)
+		}
+		w.Metadata = kept
+
+		modified := []string{}
+		for path := range w.externallyModified {
+			if known[path] {
+				modified = append(modified, path)
+				delete(w.externallyModified, path)
+			}
+		}
+		w.mu.Unlock()
+
+		for _, path := range missing {
+			w.notifyWarning("Backup %s was deleted from %s outside of the watcher", path, destination)
+			needsSave = true
+		}
+		for _, path := range modified {
+			w.notifyWarning("Backup %s in %s was modified outside of the watcher", path, destination)
+		}
+		if err == nil && !sameBackups(onDisk, inMemory) && len(missing) == 0 {
+			w.notifyWarning("Metadata in %s was modified outside of the watcher, restoring it", destination)
+			needsSave = true
+		}
+
+		entries, err := os.ReadDir(destination)
+		if err == nil {
+			for _, entry := range entries {
+				if entry.IsDir() && !knownFolders[entry.Name()] && !strings.HasPrefix(entry.Name(), ".") {
+					w.notifyWarning("Unknown folder %s found in %s", entry.Name(), destination)
+				}
+			}
+		}
+
+		if needsSave {
+			// The metadata of the watcher replaces what was found on disk instead of
+			// being merged with it.
+			if onDisk != nil {
+				w.mu.Lock()
+				if w.savedMetadata == nil {
+					w.savedMetadata = map[string][]Backup{}
+				}
+				w.savedMetadata[destination] = onDisk
+				w.mu.Unlock()
+			}
+			if err := w.saveMetadata(destination); err != nil {
+				Logf(w.Name, LogLevelError, "Error saving metadata: %v", err)
+			}
+		}
+	}
+}
+
+func sameBackups(a, b []Backup) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Path != b[i].Path || a[i].Timestamp != b[i].Timestamp {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type warningRecorder struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (r *warningRecorder) OnBackupCompletion(watcher *Watcher) {}
+
+func (r *warningRecorder) OnWarning(watcher *Watcher, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warnings = append(r.warnings, message)
+}
+
+func TestReconcileExternallyModifiedDestination(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	recorder := &warningRecorder{}
+	watcher.AddObserver(recorder)
+
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 1024)
+	watcher.CreateBackup()
+	remaining := watcher.Metadata[1]
+
+	// Delete a backup and add an unknown folder behind the watcher's back.
+	if err := os.RemoveAll(watcher.BackupPath(watcher.Metadata[0])); err != nil {
+		t.Fatalf("Failed to remove backup: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(WatcherConfig.Destination, "unknown"), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	watcher.reconcileMetadata()
+	watcher.flushObservers()
+
+	if len(watcher.Metadata) != 1 || watcher.Metadata[0].Path != remaining.Path {
+		t.Fatalf("Expected only the remaining backup in metadata, got %+v", watcher.Metadata)
+	}
+	if len(recorder.warnings) != 2 {
+		t.Errorf("Expected 2 warnings, got %v", recorder.warnings)
+	}
+
+	// The metadata file should have been updated to match.
+	onDisk, err := loadDestinationMetadata(WatcherConfig.Destination)
+	if err != nil {
+		t.Fatalf("Failed to load metadata: %v", err)
+	}
+	if !sameBackups(onDisk, watcher.Metadata) {
+		t.Errorf("Expected metadata file to be reconciled, got %+v", onDisk)
+	}
+}
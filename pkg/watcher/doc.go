@@ -0,0 +1,19 @@
+// Package watcher is the backup engine of I Saw That. A Watcher watches a source
+// folder and copies it into a new folder in the destination once changes settle, and
+// keeps the metadata, history and retention of those backups.
+//
+// A watcher is created from a WatcherConfig, the same folder pair the app reads from
+// its config file, and started with Run or StartWatcher:
+//
+//	w, err := watcher.NewWatcher(watcher.WatcherConfig{
+//		ID:           "documents",
+//		Source:       "/home/me/Documents",
+//		Destination:  "/mnt/backups/documents",
+//		WaitTime:     watcher.Duration(time.Second),
+//		FolderFormat: watcher.DefaultFolderFormat,
+//	}, watcher.WithObserver(observer))
+//	if err != nil {
+//		return err
+//	}
+//	return w.Run(ctx)
+package watcher
@@ -0,0 +1,99 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that is written to config files as a string such as
+// "500ms" or "5m". Plain numbers are read as seconds, which is how wait times were
+// stored before, so old config files keep working and are upgraded when saved.
+type Duration time.Duration
+
+// parseDuration reads a duration such as "2s" or a number of seconds such as "1.5".
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, use a value such as \"500ms\", \"2s\" or \"5m\"", s)
+	}
+	return d, nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := parseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var seconds float64
+		if err := json.Unmarshal(data, &seconds); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	return d.UnmarshalText([]byte(s))
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return d.UnmarshalText([]byte(node.Value))
+}
+
+// UnmarshalTOML accepts both strings and the numbers of older config files.
+func (d *Duration) UnmarshalTOML(value any) error {
+	switch v := value.(type) {
+	case string:
+		return d.UnmarshalText([]byte(v))
+	case int64:
+		*d = Duration(time.Duration(v) * time.Second)
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	default:
+		return fmt.Errorf("invalid duration %v", value)
+	}
+	return nil
+}
+
+// ParseWaitTime reads a wait time passed from the GUI. An empty or negative wait time
+// is inherited from the defaults.
+func ParseWaitTime(s string) (Duration, error) {
+	if strings.TrimSpace(s) == "" {
+		return 0, nil
+	}
+	d, err := parseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing wait time: %w", err)
+	}
+	return Duration(max(d, 0)), nil
+}
@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	t.Parallel()
+	cases := map[string]time.Duration{
+		"500ms":  500 * time.Millisecond,
+		"2s":     2 * time.Second,
+		"5m":     5 * time.Minute,
+		"1h30m":  90 * time.Minute,
+		"1.5":    1500 * time.Millisecond,
+		" 3 ":    3 * time.Second,
+		"0.0001": 100 * time.Microsecond,
+	}
+	for s, expected := range cases {
+		d, err := parseDuration(s)
+		if err != nil {
+			t.Errorf("Failed to parse '%s': %v", s, err)
+		} else if d != expected {
+			t.Errorf("Expected '%s' to be %s, got %s", s, expected, d)
+		}
+	}
+	for _, s := range []string{"", "soon", "5 minutes"} {
+		if _, err := parseDuration(s); err == nil {
+			t.Errorf("Expected an error for '%s'", s)
+		}
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	t.Parallel()
+	var config WatcherConfig
+	if err := json.Unmarshal([]byte(`{"wait_time": 0.5}`), &config); err != nil {
+		t.Fatalf("Failed to parse a wait time in seconds: %v", err)
+	}
+	if config.WaitTime != Duration(500*time.Millisecond) {
+		t.Errorf("Expected wait time 500ms, got %s", config.WaitTime)
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	var parsed map[string]any
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Failed to parse marshaled config: %v", err)
+	}
+	if parsed["wait_time"] != "500ms" {
+		t.Errorf("Expected the wait time to be saved as '500ms', got %v", parsed["wait_time"])
+	}
+
+	if err := json.Unmarshal([]byte(`{"wait_time": "soon"}`), &config); err == nil {
+		t.Errorf("Expected an error for an invalid wait time")
+	}
+}
+
+func TestOtherDurationsAcceptSeconds(t *testing.T) {
+	t.Parallel()
+	var config WatcherConfig
+	data := `{"poll_interval": 2, "debounce": {"max_wait": "5m"}, "open_files": {"max_wait": 1800}, "stability": {"interval": "500ms", "max_wait": 60}}`
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if config.PollInterval != Duration(2*time.Second) ||
+		config.Debounce.MaxWait != Duration(5*time.Minute) ||
+		config.OpenFiles.MaxWait != Duration(30*time.Minute) ||
+		config.Stability.Interval != Duration(500*time.Millisecond) ||
+		config.Stability.MaxWait != Duration(time.Minute) {
+		t.Errorf("Expected seconds and duration strings to be read, got %+v", config)
+	}
+}
@@ -0,0 +1,48 @@
+//go:build windows
+
+package watcher
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os/exec"
+	"strings"
+	"unicode/utf16"
+)
+
+// elevatedCopy copies every entry in a single elevated PowerShell process so there is
+// only one UAC prompt per backup.
+func elevatedCopy(denied []deniedCopy) error {
+	var script strings.Builder
+	script.WriteString("$ErrorActionPreference = 'Stop'\n")
+	for _, entry := range denied {
+		if entry.dir {
+			fmt.Fprintf(&script, "Get-ChildItem -LiteralPath %s -Force | Copy-Item -Destination %s -Recurse -Force\n", powershellQuote(entry.source), powershellQuote(entry.destination))
+		} else {
+			fmt.Fprintf(&script, "Copy-Item -LiteralPath %s -Destination %s -Force\n", powershellQuote(entry.source), powershellQuote(entry.destination))
+		}
+	}
+
+	command := fmt.Sprintf("$p = Start-Process powershell -Verb RunAs -Wait -PassThru -ArgumentList '-NoProfile','-NonInteractive','-EncodedCommand','%s'; exit $p.ExitCode", encodePowershell(script.String()))
+	output, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", command).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("elevated copy failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// powershellQuote quotes a string as a PowerShell literal.
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// encodePowershell encodes a script for -EncodedCommand, which takes base64 UTF-16LE.
+func encodePowershell(script string) string {
+	units := utf16.Encode([]rune(script))
+	data := make([]byte, len(units)*2)
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(data[i*2:], unit)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
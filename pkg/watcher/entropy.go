@@ -0,0 +1,180 @@
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Bytes read from the start of a file to measure its entropy.
+	entropySampleSize = 64 * 1024
+	// Files smaller than this are skipped since a few bytes always look random.
+	minEntropySample = 1024
+	// Entropy in bits per byte below which a file still compresses well, such as text
+	// or most documents.
+	compressibleEntropy = 7.0
+	// Entropy in bits per byte above which a file looks like random data, encrypted
+	// files are close to 8.
+	highEntropy = 7.5
+	// Changed files sampled per backup by default.
+	defaultEntropySample = 200
+)
+
+// EntropyConfig compares the files that changed since the previous backup with their
+// previous version and flags the backup when many files that used to compress well now
+// look like random data, which is how encrypted files look.
+type EntropyConfig struct {
+	// Number of files that have to become random-looking for the backup to be flagged,
+	// 0 turns the check off.
+	Files int `json:"files,omitempty" yaml:"files,omitempty" toml:"files,omitempty"`
+	// Most changed files that are sampled for each backup, 200 if it is 0.
+	Sample int `json:"sample,omitempty" yaml:"sample,omitempty" toml:"sample,omitempty"`
+}
+
+func (c EntropyConfig) enabled() bool {
+	return c.Files > 0
+}
+
+func (c EntropyConfig) validate() error {
+	if c.Files < 0 {
+		return fmt.Errorf("entropy files can not be negative")
+	}
+	if c.Sample < 0 {
+		return fmt.Errorf("entropy sample can not be negative")
+	}
+	return nil
+}
+
+func (c EntropyConfig) sample() int {
+	if c.Sample > 0 {
+		return c.Sample
+	}
+	return defaultEntropySample
+}
+
+// shannonEntropy returns the entropy of data in bits per byte, from 0 for a single
+// repeated byte to 8 for random data.
+func shannonEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / float64(len(data))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// readEntropySample reads the start of a file.
+func readEntropySample(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	sample := make([]byte, entropySampleSize)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return sample[:n], nil
+}
+
+// previousVersion returns the path of a file in the previous backup. Ransomware often
+// adds an extension to the files it encrypts, so without a file at the same path the
+// path without its last extension is tried.
+func previousVersion(previous, rel string) (string, os.FileInfo, bool) {
+	path := filepath.Join(previous, rel)
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		return path, info, true
+	}
+	if extension := filepath.Ext(path); extension != "" {
+		path = strings.TrimSuffix(path, extension)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, info, true
+		}
+	}
+	return "", nil, false
+}
+
+// encryptedFiles returns the files in backupPath, relative to it with forward slashes,
+// that compressed well in the previous backup and now look like random data. At most
+// sample changed files are compared.
+func encryptedFiles(backupPath, previous string, sample int) ([]string, error) {
+	encrypted := []string{}
+	sampled := 0
+	err := filepath.WalkDir(backupPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if sampled >= sample {
+			return filepath.SkipAll
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.Size() < minEntropySample {
+			return nil
+		}
+		rel, err := filepath.Rel(backupPath, path)
+		if err != nil {
+			return err
+		}
+		previousPath, previousInfo, ok := previousVersion(previous, rel)
+		if !ok || previousInfo.Size() < minEntropySample {
+			return nil
+		}
+		if previousInfo.Size() == info.Size() && previousInfo.ModTime().Equal(info.ModTime()) {
+			return nil
+		}
+
+		current, err := readEntropySample(path)
+		if err != nil {
+			return nil
+		}
+		old, err := readEntropySample(previousPath)
+		if err != nil || bytes.Equal(current, old) {
+			return nil
+		}
+		sampled++
+		if shannonEntropy(old) < compressibleEntropy && shannonEntropy(current) >= highEntropy {
+			encrypted = append(encrypted, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return encrypted, err
+}
+
+// checkEntropy flags a backup that has at least EntropyConfig.Files files that became
+// random-looking since the previous backup and warns about it.
+func (w *Watcher) checkEntropy(config EntropyConfig, backup *Backup, backupPath, previous string) {
+	if previous == "" {
+		return
+	}
+	encrypted, err := encryptedFiles(backupPath, previous, config.sample())
+	if err != nil {
+		Logf(w.Name, LogLevelWarn, "Error checking the entropy of backup %s: %v", backup.Path, err)
+	}
+	if len(encrypted) < config.Files {
+		return
+	}
+	backup.HighEntropy = encrypted
+	examples := encrypted[:min(len(encrypted), 3)]
+	w.notifyWarning("Backup %s has %d files that now look like random data and may have been encrypted, such as %s", backup.Path, len(encrypted), strings.Join(examples, ", "))
+}
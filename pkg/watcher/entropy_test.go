@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"crypto/rand"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestShannonEntropy(t *testing.T) {
+	t.Parallel()
+	if entropy := shannonEntropy(make([]byte, 1024)); entropy != 0 {
+		t.Errorf("Expected a repeated byte to have no entropy, got %f", entropy)
+	}
+	every := make([]byte, 256*4)
+	for i := range every {
+		every[i] = byte(i)
+	}
+	if entropy := shannonEntropy(every); math.Abs(entropy-8) > 1e-9 {
+		t.Errorf("Expected every byte equally often to have 8 bits of entropy, got %f", entropy)
+	}
+}
+
+func TestEntropyFlagsEncryptedFiles(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Entropy = EntropyConfig{Files: 2}
+	recorder := &warningRecorder{}
+	watcher.AddObserver(recorder)
+
+	text := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 200))
+	write := func(name string, data []byte) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(WatcherConfig.Source, name), data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	random := func() []byte {
+		data := make([]byte, 8192)
+		rand.Read(data)
+		return data
+	}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		write(name, text)
+	}
+	watcher.CreateBackup()
+
+	// a.txt is encrypted in place, b.txt is encrypted under a new extension and c.txt is
+	// only edited.
+	write("a.txt", random())
+	write("b.txt.locked", random())
+	if err := os.Remove(filepath.Join(WatcherConfig.Source, "b.txt")); err != nil {
+		t.Fatalf("Failed to remove b.txt: %v", err)
+	}
+	write("c.txt", append(text, "Edited"...))
+	watcher.CreateBackup()
+	watcher.flushObservers()
+
+	if len(watcher.Metadata) != 2 {
+		t.Fatalf("Expected 2 backups, got %d", len(watcher.Metadata))
+	}
+	if flagged := watcher.Metadata[1].HighEntropy; !slices.Equal(flagged, []string{"a.txt", "b.txt.locked"}) {
+		t.Errorf("Expected a.txt and b.txt.locked to be flagged, got %v", flagged)
+	}
+	if len(recorder.warnings) != 1 {
+		t.Errorf("Expected 1 warning, got %v", recorder.warnings)
+	}
+}
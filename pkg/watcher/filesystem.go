@@ -0,0 +1,26 @@
+package watcher
+
+import (
+	"io/fs"
+	"os"
+)
+
+// FS is the part of the filesystem that the stability check and pruning use, replacing
+// it lets tests control what the files look like and make removing them fail.
+type FS interface {
+	Stat(name string) (fs.FileInfo, error)
+	Remove(name string) error
+	RemoveAll(path string) error
+	Rename(oldpath, newpath string) error
+}
+
+// osFS uses the filesystem of the computer.
+type osFS struct{}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (osFS) Remove(name string) error { return os.Remove(name) }
+
+func (osFS) RemoveAll(path string) error { return os.RemoveAll(path) }
+
+func (osFS) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
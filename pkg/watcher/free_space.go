@@ -0,0 +1,159 @@
+package watcher
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// How often the free space of the destinations is checked by default.
+const defaultFreeSpaceInterval = time.Minute
+
+// FreeSpaceConfig watches the free space of the volumes the backups are stored on, so
+// a full disk is noticed before backups start failing.
+type FreeSpaceConfig struct {
+	// Warn when fewer than this many megabytes are free, 0 turns the check off.
+	MinFreeMB int64 `json:"min_free_mb,omitempty" yaml:"min_free_mb,omitempty" toml:"min_free_mb,omitempty"`
+	// Delete the oldest backups that are not pinned until MinFreeMB is free again. The
+	// newest backup of a destination is never deleted.
+	EmergencyPrune bool `json:"emergency_prune,omitempty" yaml:"emergency_prune,omitempty" toml:"emergency_prune,omitempty"`
+	// How often the free space is checked, every minute if it is 0.
+	Interval Duration `json:"interval,omitempty" yaml:"interval,omitempty" toml:"interval,omitempty"`
+}
+
+func (c FreeSpaceConfig) enabled() bool {
+	return c.MinFreeMB > 0
+}
+
+func (c FreeSpaceConfig) validate() error {
+	if c.MinFreeMB < 0 {
+		return fmt.Errorf("minimum free space can not be negative")
+	}
+	if c.Interval < 0 {
+		return fmt.Errorf("free space interval must be at least 0 seconds")
+	}
+	if c.EmergencyPrune && c.MinFreeMB == 0 {
+		return fmt.Errorf("emergency pruning needs a minimum free space")
+	}
+	return nil
+}
+
+func (c FreeSpaceConfig) interval() time.Duration {
+	if c.Interval > 0 {
+		return time.Duration(c.Interval)
+	}
+	return defaultFreeSpaceInterval
+}
+
+// minFree returns the minimum free space in bytes.
+func (c FreeSpaceConfig) minFree() uint64 {
+	return uint64(c.MinFreeMB) * 1024 * 1024
+}
+
+// checkFreeSpace warns about destinations with less free space than the config asks
+// for and makes room in them with emergency pruning. It is only called from the backup
+// thread so pruning never runs at the same time as a backup.
+func (w *Watcher) checkFreeSpace(config FreeSpaceConfig) {
+	minFree := config.minFree()
+	for _, destination := range w.destinations() {
+		free, err := w.freeSpace(destination)
+		if err != nil {
+			Logf(w.Name, LogLevelDebug, "Error reading free space of %s: %v", destination, err)
+			continue
+		}
+
+		w.mu.Lock()
+		wasLow := w.lowSpace[destination]
+		w.lowSpace[destination] = free < minFree
+		w.mu.Unlock()
+
+		if free >= minFree {
+			if wasLow {
+				Logf(w.Name, LogLevelInfo, "Free space in %s is above the minimum again", destination)
+			}
+			continue
+		}
+
+		pruned := 0
+		if config.EmergencyPrune && w.RetentionPaused() == "" {
+			pruned, free = w.emergencyPrune(destination, minFree, free)
+		}
+		switch {
+		case free >= minFree:
+			w.mu.Lock()
+			w.lowSpace[destination] = false
+			w.mu.Unlock()
+			if pruned == 0 {
+				w.notifyWarning("Emptied the trash of %s to keep %s free", destination, FormatBytes(int64(minFree)))
+			} else {
+				w.notifyWarning("Deleted %d old backups from %s to keep %s free", pruned, destination, FormatBytes(int64(minFree)))
+			}
+		case pruned > 0:
+			w.notifyWarning("Only %s free in %s after deleting %d old backups, the minimum is %s", FormatBytes(int64(free)), destination, pruned, FormatBytes(int64(minFree)))
+		// Only warn once until the free space recovers.
+		case !wasLow:
+			w.notifyWarning("Only %s free in %s, the minimum is %s", FormatBytes(int64(free)), destination, FormatBytes(int64(minFree)))
+		}
+	}
+}
+
+// emergencyPrune empties the trash of a destination and deletes its oldest backups that
+// are not pinned until minFree bytes are free, the newest backup is always kept. It
+// returns how many backups were deleted and the free space after deleting them.
+func (w *Watcher) emergencyPrune(destination string, minFree, free uint64) (int, uint64) {
+	// The trash is emptied before any backup is deleted.
+	if err := w.purgeTrash(destination, 0); err != nil {
+		Logf(w.Name, LogLevelWarn, "Error emptying trash to free space: %v", err)
+	}
+	if purged, err := w.freeSpace(destination); err == nil {
+		free = purged
+	}
+
+	w.mu.Lock()
+	backups := []Backup{}
+	for _, backup := range w.Metadata {
+		if backup.Destination == destination {
+			backups = append(backups, backup)
+		}
+	}
+	w.mu.Unlock()
+
+	// The newest backup is always kept. Restic backups share their data so forgetting
+	// one frees little space.
+	candidates := slices.DeleteFunc(backups[:max(len(backups)-1, 0)], func(backup Backup) bool {
+		return backup.Pinned || backup.ResticSnapshot != ""
+	})
+
+	deleted := map[string]bool{}
+	for _, backup := range candidates {
+		if free >= minFree {
+			break
+		}
+		if err := w.deleteBackup(backup); err != nil {
+			Logf(w.Name, LogLevelWarn, "Error deleting backup %s to free space: %v", backup.Path, err)
+			continue
+		}
+		path := w.BackupPath(backup)
+		deleted[path] = true
+		Logf(w.Name, LogLevelInfo, "Pruned backup %s to free space", path)
+		w.recordEvent(HistoryEvent{Type: HistoryBackupPruned, Backup: backup.Path, Message: "Low free space"})
+
+		var err error
+		if free, err = w.freeSpace(destination); err != nil {
+			Logf(w.Name, LogLevelWarn, "Error reading free space of %s: %v", destination, err)
+			break
+		}
+	}
+
+	if len(deleted) > 0 {
+		w.mu.Lock()
+		w.Metadata = slices.DeleteFunc(w.Metadata, func(backup Backup) bool {
+			return deleted[w.BackupPath(backup)]
+		})
+		w.mu.Unlock()
+		if err := w.saveMetadata(destination); err != nil {
+			Logf(w.Name, LogLevelError, "Error saving metadata: %v", err)
+		}
+	}
+	return len(deleted), free
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package watcher
+
+import "errors"
+
+// freeSpace is not supported on other platforms.
+func freeSpace(path string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
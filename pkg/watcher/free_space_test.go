@@ -0,0 +1,106 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFreeSpaceConfigValidate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		config FreeSpaceConfig
+		valid  bool
+	}{
+		{"disabled", FreeSpaceConfig{}, true},
+		{"minimum", FreeSpaceConfig{MinFreeMB: 100, EmergencyPrune: true}, true},
+		{"negative minimum", FreeSpaceConfig{MinFreeMB: -1}, false},
+		{"negative interval", FreeSpaceConfig{MinFreeMB: 100, Interval: -1}, false},
+		{"prune without minimum", FreeSpaceConfig{EmergencyPrune: true}, false},
+	}
+	for _, test := range tests {
+		if err := test.config.validate(); (err == nil) != test.valid {
+			t.Errorf("%s: Expected valid to be %t, got %v", test.name, test.valid, err)
+		}
+	}
+}
+
+func TestLowFreeSpaceWarnsOnce(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	recorder := &warningRecorder{}
+	watcher.AddObserver(recorder)
+	var free uint64
+	watcher.freeSpace = func(path string) (uint64, error) { return free, nil }
+	config := FreeSpaceConfig{MinFreeMB: 1}
+
+	watcher.checkFreeSpace(config)
+	watcher.checkFreeSpace(config)
+	watcher.flushObservers()
+	if len(recorder.warnings) != 1 {
+		t.Errorf("Expected 1 warning while space stays low, got %v", recorder.warnings)
+	}
+
+	// Once the space recovers running low again is warned about again.
+	free = config.minFree()
+	watcher.checkFreeSpace(config)
+	free = 0
+	watcher.checkFreeSpace(config)
+	watcher.flushObservers()
+	if len(recorder.warnings) != 2 {
+		t.Errorf("Expected a second warning, got %v", recorder.warnings)
+	}
+}
+
+func TestEmergencyPrune(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	for i := range 4 {
+		CreateDummyFile(t, WatcherConfig.Source, string(rune('a'+i)), 10)
+		watcher.CreateBackup()
+	}
+	backups := watcher.ListBackups()
+	if err := watcher.PinBackup(backups[0].Path, true); err != nil {
+		t.Fatalf("Failed to pin backup: %v", err)
+	}
+
+	// There is enough space once only two backups are left.
+	config := FreeSpaceConfig{MinFreeMB: 1, EmergencyPrune: true}
+	watcher.freeSpace = func(path string) (uint64, error) {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return 0, err
+		}
+		if len(entries) > 3 {
+			return 0, nil
+		}
+		return config.minFree(), nil
+	}
+	watcher.checkFreeSpace(config)
+
+	remaining := watcher.ListBackups()
+	if len(remaining) != 2 || remaining[0].Path != backups[0].Path || remaining[1].Path != backups[3].Path {
+		t.Fatalf("Expected the pinned and the newest backup to be kept, got %+v", remaining)
+	}
+	for _, backup := range backups[1:3] {
+		if _, err := os.Stat(filepath.Join(WatcherConfig.Destination, backup.Path)); !os.IsNotExist(err) {
+			t.Errorf("Expected backup %s to be deleted, got %v", backup.Path, err)
+		}
+	}
+	onDisk, err := loadDestinationMetadata(WatcherConfig.Destination)
+	if err != nil {
+		t.Fatalf("Failed to load metadata: %v", err)
+	}
+	if !sameBackups(onDisk, remaining) || !onDisk[0].Pinned {
+		t.Errorf("Expected the metadata file to match, got %+v", onDisk)
+	}
+}
@@ -0,0 +1,52 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// HashAlgorithm is used to hash files for manifests, which are compared to verify
+// backups and to check whether the source changed.
+type HashAlgorithm string
+
+const (
+	// xxHash64, much faster than SHA-256 but only detects accidental changes. This is
+	// the default.
+	HashXXHash HashAlgorithm = "xxhash"
+	// SHA-256, for backups that have to be checked against deliberate tampering.
+	HashSHA256 HashAlgorithm = "sha256"
+)
+
+func (a HashAlgorithm) validate() error {
+	switch a {
+	case "", HashXXHash, HashSHA256:
+		return nil
+	}
+	return fmt.Errorf("unknown hash algorithm: %s", a)
+}
+
+// newHash returns a hash for the algorithm. Sidecars and backups written before the
+// algorithm was recorded have no algorithm and always used SHA-256.
+func (a HashAlgorithm) newHash() hash.Hash {
+	if a == HashXXHash {
+		return xxhash.New()
+	}
+	return sha256.New()
+}
+
+// hashAlgorithm returns the algorithm new manifests of the watcher are built with.
+// Signed backups default to SHA-256 since the signature is only as strong as the hash.
+func (w *Watcher) hashAlgorithm() HashAlgorithm {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.HashAlgorithm == "" && w.SignBackups {
+		return HashSHA256
+	}
+	if w.HashAlgorithm == "" {
+		return HashXXHash
+	}
+	return w.HashAlgorithm
+}
@@ -0,0 +1,69 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestHashAlgorithm(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	fast, _ := watcher.createTriggeredBackup(BackupTriggerManual)
+	watcher.HashAlgorithm = HashSHA256
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 1024)
+	secure, _ := watcher.createTriggeredBackup(BackupTriggerManual)
+
+	for _, test := range []struct {
+		backup   Backup
+		expected HashAlgorithm
+	}{{fast, HashXXHash}, {secure, HashSHA256}} {
+		backup := test.backup
+		sidecar, err := ReadBackupSidecar(watcher.BackupPath(backup))
+		if err != nil {
+			t.Fatalf("Failed to read sidecar: %v", err)
+		}
+		if sidecar.HashAlgorithm != test.expected {
+			t.Errorf("Expected %s to be hashed with %s, got %s", backup.Path, test.expected, sidecar.HashAlgorithm)
+		}
+		// Backups are verified with the algorithm they were made with, whatever the
+		// watcher uses now.
+		if result := watcher.VerifyBackup(backup); result.Status != VerifyStatusOK {
+			t.Errorf("Expected %s to verify, got %+v", backup.Path, result)
+		}
+	}
+
+	// Sidecars from before the algorithm was recorded used SHA-256.
+	sidecar, err := ReadBackupSidecar(watcher.BackupPath(secure))
+	if err != nil {
+		t.Fatalf("Failed to read sidecar: %v", err)
+	}
+	sidecar.HashAlgorithm = ""
+	if err := writeBackupSidecar(watcher.BackupPath(secure), sidecar); err != nil {
+		t.Fatalf("Failed to write sidecar: %v", err)
+	}
+	watcher.HashAlgorithm = ""
+	if result := watcher.VerifyBackup(secure); result.Status != VerifyStatusOK {
+		t.Errorf("Expected a legacy sidecar to verify, got %+v", result)
+	}
+
+	a, err := hashFile(filepath.Join(WatcherConfig.Source, "file1.txt"), HashXXHash)
+	if err != nil {
+		t.Fatalf("Failed to hash file: %v", err)
+	}
+	b, err := hashFile(filepath.Join(WatcherConfig.Source, "file1.txt"), HashSHA256)
+	if err != nil {
+		t.Fatalf("Failed to hash file: %v", err)
+	}
+	if len(a) != 16 || len(b) != 64 {
+		t.Errorf("Expected a 64 bit xxhash and a 256 bit SHA-256, got %s and %s", a, b)
+	}
+
+	if err := HashAlgorithm("md5").validate(); err == nil {
+		t.Errorf("Expected an error for an unknown algorithm")
+	}
+}
@@ -0,0 +1,152 @@
+package watcher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	// How often the destinations and the remote are probed by default.
+	defaultHealthCheckInterval = time.Hour
+	// How long after the watcher starts the first probe is made.
+	healthCheckDelay = time.Minute
+	// How long a probe of a destination is waited for, a NAS that went away can leave
+	// file operations hanging for minutes.
+	healthProbeTimeout = 30 * time.Second
+)
+
+// Name the remote has in the health of a watcher.
+const remoteHealthName = "remote"
+
+// BackendHealth is the result of the last probe of a destination or the remote.
+type BackendHealth struct {
+	// Path of the destination, or "remote".
+	Backend   string    `json:"backend"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+func (w *Watcher) healthCheckInterval() time.Duration {
+	switch {
+	case w.HealthCheck < 0:
+		return 0
+	case w.HealthCheck == 0:
+		return defaultHealthCheckInterval
+	}
+	return time.Duration(w.HealthCheck)
+}
+
+// healthLoop probes the destinations and the remote shortly after the watcher starts
+// and then every interval, so a dead NAS or expired credentials are noticed before a
+// backup needs them.
+func (w *Watcher) healthLoop(interval time.Duration, stop chan struct{}) {
+	wait := min(interval, healthCheckDelay)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+		w.CheckHealth()
+		wait = interval
+	}
+}
+
+// CheckHealth writes, reads and deletes a probe file in every destination and on the
+// remote. The results are kept in the status of the watcher and a warning is given
+// when a backend starts failing.
+func (w *Watcher) CheckHealth() []BackendHealth {
+	w.mu.Lock()
+	destinations := w.destinations()
+	config := w.Remote
+	w.mu.Unlock()
+
+	results := []BackendHealth{}
+	for _, destination := range destinations {
+		err := probeWithTimeout(healthProbeTimeout, func() error {
+			return selfTestWrite(destination)
+		})
+		results = append(results, newBackendHealth(destination, err))
+	}
+	if config.enabled() {
+		results = append(results, newBackendHealth(remoteHealthName, w.probeRemote(config)))
+	}
+
+	w.mu.Lock()
+	previous := w.health
+	w.health = results
+	w.mu.Unlock()
+
+	for _, result := range results {
+		wasHealthy := true
+		for _, before := range previous {
+			if before.Backend == result.Backend {
+				wasHealthy = before.Healthy
+			}
+		}
+		switch {
+		case !result.Healthy && wasHealthy:
+			w.notifyWarning("Health check of %s failed: %s", result.Backend, result.Error)
+		case result.Healthy && !wasHealthy:
+			Logf(w.Name, LogLevelInfo, "Health check of %s passed again", result.Backend)
+		}
+	}
+	return results
+}
+
+func newBackendHealth(backend string, err error) BackendHealth {
+	health := BackendHealth{Backend: backend, Healthy: err == nil, CheckedAt: time.Now()}
+	if err != nil {
+		health.Error = err.Error()
+	}
+	return health
+}
+
+// probeWithTimeout returns the result of probe, or an error if it does not return
+// within timeout. A probe that hangs is left to finish on its own.
+func probeWithTimeout(timeout time.Duration, probe func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- probe()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("no answer within %s", timeout)
+	}
+}
+
+// probeRemote uploads a probe object to the remote, downloads it and deletes it. The
+// backends limit how long each request can take.
+func (w *Watcher) probeRemote(config RemoteConfig) error {
+	backend, err := w.newRemoteBackend(config)
+	if err != nil {
+		return err
+	}
+	key := w.remotePrefix() + "/" + selfTestProbePrefix + strconv.FormatInt(time.Now().UnixNano(), 10)
+	data := []byte("probe")
+	if err := backend.Put(key, bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("error writing probe: %w", err)
+	}
+	reader, err := backend.Get(key)
+	if err != nil {
+		return fmt.Errorf("error reading probe: %w", err)
+	}
+	read, err := io.ReadAll(io.LimitReader(reader, int64(len(data))+1))
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("error reading probe: %w", err)
+	}
+	if !bytes.Equal(read, data) {
+		return fmt.Errorf("probe read back does not match what was written")
+	}
+	if err := backend.Delete(key); err != nil {
+		return fmt.Errorf("error deleting probe: %w", err)
+	}
+	return nil
+}
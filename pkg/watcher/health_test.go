@@ -0,0 +1,99 @@
+package watcher
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCheckHealth(t *testing.T) {
+	t.Parallel()
+	server := newNextcloudServer(t)
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Remote = RemoteConfig{Type: RemoteWebDAV, URL: server.folderURL("Backups"), User: "alice", Password: "secret"}
+	recorder := &warningRecorder{}
+	watcher.AddObserver(recorder)
+
+	results := watcher.CheckHealth()
+	if len(results) != 2 || results[0].Backend != WatcherConfig.Destination || results[1].Backend != remoteHealthName {
+		t.Fatalf("Expected the destination and the remote to be checked, got %+v", results)
+	}
+	for _, result := range results {
+		if !result.Healthy {
+			t.Errorf("Expected %s to be healthy, got %s", result.Backend, result.Error)
+		}
+	}
+	// The probes are removed again.
+	if entries, err := os.ReadDir(WatcherConfig.Destination); err != nil || len(entries) != 0 {
+		t.Errorf("Expected the destination to be empty, got %v: %v", entries, err)
+	}
+	backend, err := watcher.newRemoteBackend(watcher.Remote)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	if objects, err := backend.List(watcher.remotePrefix()); err != nil || len(objects) != 0 {
+		t.Errorf("Expected nothing on the remote, got %+v: %v", objects, err)
+	}
+
+	// A destination that is gone and credentials that stopped working are warned about
+	// once.
+	if err := os.RemoveAll(WatcherConfig.Destination); err != nil {
+		t.Fatalf("Failed to remove destination: %v", err)
+	}
+	watcher.Remote.Password = "expired"
+	for range 2 {
+		watcher.CheckHealth()
+	}
+	health := watcher.Status().Health
+	if len(health) != 2 || health[0].Healthy || health[1].Healthy || health[0].Error == "" || health[1].Error == "" {
+		t.Errorf("Expected both backends to be unhealthy, got %+v", health)
+	}
+	watcher.flushObservers()
+	recorder.mu.Lock()
+	if len(recorder.warnings) != 2 {
+		t.Errorf("Expected 2 warnings, got %v", recorder.warnings)
+	}
+	recorder.mu.Unlock()
+
+	if err := os.MkdirAll(WatcherConfig.Destination, 0755); err != nil {
+		t.Fatalf("Failed to create destination: %v", err)
+	}
+	watcher.Remote.Password = "secret"
+	for _, result := range watcher.CheckHealth() {
+		if !result.Healthy {
+			t.Errorf("Expected %s to be healthy again, got %s", result.Backend, result.Error)
+		}
+	}
+}
+
+func TestHealthCheckInterval(t *testing.T) {
+	t.Parallel()
+	tests := map[Duration]time.Duration{
+		0:                         defaultHealthCheckInterval,
+		Duration(5 * time.Minute): 5 * time.Minute,
+		-1:                        0,
+	}
+	for configured, expected := range tests {
+		watcher := &Watcher{HealthCheck: configured}
+		if interval := watcher.healthCheckInterval(); interval != expected {
+			t.Errorf("Expected %v to check every %v, got %v", configured, expected, interval)
+		}
+	}
+}
+
+func TestProbeWithTimeout(t *testing.T) {
+	t.Parallel()
+	hang := make(chan struct{})
+	defer close(hang)
+	err := probeWithTimeout(10*time.Millisecond, func() error {
+		<-hang
+		return nil
+	})
+	if err == nil {
+		t.Error("Expected a probe that hangs to fail")
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package watcher
 
 import (
 	"errors"
@@ -7,25 +7,28 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 )
 
-// tempWatcherConfig holds configuration for creating a watcher
-type tempWatcherConfig struct {
+// TempWatcherConfig holds configuration for creating a watcher
+type TempWatcherConfig struct {
 	Name         string
 	Source       string
 	Destination  string
 	TempPath     string
-	WaitTime     float64
+	WaitTime     time.Duration
 	FolderFormat string
 	Enabled      bool
+	// Additional destinations backups rotate between.
+	RotationDestinations []string
 }
 
 // DefaultTempWatcherConfig returns a configuration with sensible defaults
-func DefaultTempWatcherConfig(t *testing.T) tempWatcherConfig {
+func DefaultTempWatcherConfig(t *testing.T) TempWatcherConfig {
 	// Create temporary directory
 	tempPath, err := os.MkdirTemp("", "watcher-test-*")
 	if err != nil {
@@ -38,37 +41,38 @@ func DefaultTempWatcherConfig(t *testing.T) tempWatcherConfig {
 		os.RemoveAll(tempPath)
 	})
 
-	return tempWatcherConfig{
+	return TempWatcherConfig{
 		Name:         "Test Watcher",
 		TempPath:     tempPath,
 		Source:       filepath.Join(tempPath, "source"),
 		Destination:  filepath.Join(tempPath, "destination"),
-		WaitTime:     1.0,
+		WaitTime:     time.Second,
 		FolderFormat: "2006-01-02_15-04-05.000000",
 		Enabled:      true,
 	}
 }
 
 // Create a default watcher for testing
-func newWatcher(config tempWatcherConfig) (*Watcher, error) {
-	return NewWatcher(
-		config.Name,
-		config.Source,
-		config.Destination,
-		config.WaitTime,
-		config.FolderFormat,
-	)
+func NewTempWatcher(config TempWatcherConfig, options ...Option) (*Watcher, error) {
+	return NewWatcher(WatcherConfig{
+		ID:                   config.Name,
+		Source:               config.Source,
+		Destination:          config.Destination,
+		WaitTime:             Duration(config.WaitTime),
+		FolderFormat:         config.FolderFormat,
+		RotationDestinations: config.RotationDestinations,
+	}, options...)
 }
 
-func CheckForWatcherError(t *testing.T, WatcherConfig tempWatcherConfig, expectedErrMsg string) {
-	_, err := newWatcher(WatcherConfig)
+func CheckForWatcherError(t *testing.T, WatcherConfig TempWatcherConfig, expectedErrMsg string) {
+	_, err := NewTempWatcher(WatcherConfig)
 	if err == nil || !strings.Contains(err.Error(), expectedErrMsg) {
 		t.Errorf("Expected error message to contain:\n%s\nBut got:\n%v", expectedErrMsg, err)
 	}
 }
 
-func CheckForWatcherErrorV2(t *testing.T, WatcherConfig tempWatcherConfig, expectedErrPtr any, expectedErrMsg string) {
-	_, err := newWatcher(WatcherConfig)
+func CheckForWatcherErrorV2(t *testing.T, WatcherConfig TempWatcherConfig, expectedErrPtr any, expectedErrMsg string) {
+	_, err := NewTempWatcher(WatcherConfig)
 
 	if err == nil {
 		t.Errorf("Expected error of type:\n%T\nBut got no error", expectedErrPtr)
@@ -83,8 +87,8 @@ func CheckForWatcherErrorV2(t *testing.T, WatcherConfig tempWatcherConfig, expec
 	}
 }
 
-func CheckForWatcherErrorV3(t *testing.T, WatcherConfig tempWatcherConfig, expectedErrPtr error, expectedErrMsg string) {
-	_, err := newWatcher(WatcherConfig)
+func CheckForWatcherErrorV3(t *testing.T, WatcherConfig TempWatcherConfig, expectedErrPtr error, expectedErrMsg string) {
+	_, err := NewTempWatcher(WatcherConfig)
 
 	if err == nil {
 		t.Errorf("Expected error of type:\n%T\nBut got no error", expectedErrPtr)
@@ -109,6 +113,13 @@ func CompareSourceAndDestination(t *testing.T, source, destination string) {
 		t.Fatalf("Error reading destination directory: %v", err)
 	}
 
+	// Backups contain a sidecar that is not in the source.
+	if _, err := os.Stat(filepath.Join(source, backupSidecarName)); os.IsNotExist(err) {
+		destEntries = slices.DeleteFunc(destEntries, func(entry os.DirEntry) bool {
+			return entry.Name() == backupSidecarName
+		})
+	}
+
 	if len(sourceEntries) != len(destEntries) {
 		t.Fatalf("Directory entry counts don't match. Source: %d, Destination: %d", len(sourceEntries), len(destEntries))
 	}
@@ -225,9 +236,9 @@ func (o *SimplifiedObserver) WaitUntilCount(targetCount int, timeout time.Durati
 	return o.getCurrentCount() >= targetCount
 }
 
-func getWatcherWithObserver(t *testing.T) (tempWatcherConfig, *Watcher, *SimplifiedObserver) {
+func getWatcherWithObserver(t *testing.T) (TempWatcherConfig, *Watcher, *SimplifiedObserver) {
 	WatcherConfig := DefaultTempWatcherConfig(t)
-	watcher, err := newWatcher(WatcherConfig)
+	watcher, err := NewTempWatcher(WatcherConfig)
 	if err != nil {
 		t.Fatalf("Failed to create watcher: %v", err)
 	}
@@ -283,3 +294,16 @@ func createRandomFileContent(size int) []byte {
 	}
 	return b
 }
+
+// waitUntil waits for condition to be true, failing the test with message if it is not
+// within 10 seconds.
+func waitUntil(t *testing.T, message string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,236 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Name of the file in the primary destination that events are appended to, one JSON
+// event per line.
+const historyFileName = "history.jsonl"
+
+// Name of the history file of earlier versions, a JSON array that was rewritten on every
+// event. It is read if there is no history file and replaced by the next event.
+const oldHistoryFileName = "history.json"
+
+// Number of events kept in the history, older events are dropped.
+const maxHistoryEvents = 1000
+
+// Number of events the history file can grow to before it is rewritten with only the
+// events that are kept.
+const maxHistoryFileEvents = 2 * maxHistoryEvents
+
+// HistoryEventType is the kind of event recorded in the history of a watcher.
+type HistoryEventType string
+
+const (
+	HistoryBackupStarted   HistoryEventType = "started"
+	HistoryBackupCompleted HistoryEventType = "completed"
+	HistoryBackupFailed    HistoryEventType = "failed"
+	// A backup was not made, such as when a backup folder with the same name exists.
+	HistoryBackupSkipped  HistoryEventType = "skipped"
+	HistoryBackupPruned   HistoryEventType = "pruned"
+	HistoryBackupRestored HistoryEventType = "restored"
+	// The clock was behind the newest backup when a backup was made.
+	HistoryClockBehind HistoryEventType = "clock_behind"
+	// A backup was restored into a temporary folder and checked, the message is the
+	// result.
+	HistoryRestoreDrill HistoryEventType = "restore_drill"
+	// The source was renamed or moved and the watcher followed it, the message has both
+	// paths.
+	HistorySourceMoved HistoryEventType = "source_moved"
+	// A large number of files changed at once and retention was paused, the message
+	// describes the change.
+	HistoryMassChange HistoryEventType = "mass_change"
+	// Retention was resumed after a mass change.
+	HistoryRetentionResumed HistoryEventType = "retention_resumed"
+	// An event touched a canary file, the message names it.
+	HistoryCanaryTouched HistoryEventType = "canary_touched"
+)
+
+// HistoryEvent is a single entry in the history of a watcher.
+type HistoryEvent struct {
+	Time time.Time        `json:"time"`
+	Type HistoryEventType `json:"type"`
+	// ID of the backup the event is about, empty if the backup was never named.
+	Backup  string        `json:"backup,omitempty"`
+	Trigger BackupTrigger `json:"trigger,omitempty"`
+	Message string        `json:"message,omitempty"`
+}
+
+func historyPath(destination string) string {
+	return statePath(destination, historyFileName)
+}
+
+// loadHistory reads every event in the history file of a destination. complete is false
+// if the file has to be rewritten before events can be appended to it, such as when the
+// history was read from the file of an earlier version or the last event was cut short.
+func loadHistory(destination string) (history []HistoryEvent, complete bool, err error) {
+	data, err := os.ReadFile(historyPath(destination))
+	if errors.Is(err, os.ErrNotExist) {
+		history, err := loadOldHistory(destination)
+		return history, false, err
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading history: %w", err)
+	}
+
+	history = []HistoryEvent{}
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var event HistoryEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			// Only the last event can be cut short by an interrupted write.
+			if i == len(lines)-1 {
+				return history, false, nil
+			}
+			return nil, false, fmt.Errorf("error parsing history: %w", err)
+		}
+		history = append(history, event)
+	}
+	return history, len(data) == 0 || data[len(data)-1] == '\n', nil
+}
+
+func loadOldHistory(destination string) ([]HistoryEvent, error) {
+	history := []HistoryEvent{}
+	data, err := os.ReadFile(statePath(destination, oldHistoryFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("error parsing history: %w", err)
+	}
+	return history, nil
+}
+
+func marshalHistory(history []HistoryEvent) ([]byte, error) {
+	var data []byte
+	for _, event := range history {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling history: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return data, nil
+}
+
+// saveHistory replaces the history file of a destination with history.
+func saveHistory(destination string, history []HistoryEvent) error {
+	data, err := marshalHistory(history)
+	if err != nil {
+		return err
+	}
+	if err := writeStateFile(historyPath(destination), data); err != nil {
+		return fmt.Errorf("error writing history: %w", err)
+	}
+	if err := os.Remove(statePath(destination, oldHistoryFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing old history: %w", err)
+	}
+	return nil
+}
+
+// appendHistory adds events to the end of the history file of a destination, which
+// must already exist.
+func appendHistory(destination string, events ...HistoryEvent) error {
+	data, err := marshalHistory(events)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(historyPath(destination), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("error writing history: %w", err)
+	}
+	_, err = file.Write(data)
+	if err = errors.Join(err, file.Close()); err != nil {
+		return fmt.Errorf("error writing history: %w", err)
+	}
+	return nil
+}
+
+// trimHistory drops the oldest events over maxHistoryEvents. While retention is paused
+// the mass change that paused it is kept so the pause lasts across restarts.
+func trimHistory(history []HistoryEvent, paused bool) []HistoryEvent {
+	if len(history) <= maxHistoryEvents {
+		return history
+	}
+	dropped := history[:len(history)-maxHistoryEvents]
+	history = history[len(history)-maxHistoryEvents:]
+	if paused && unresolvedMassChange(history) == "" {
+		for i := len(dropped) - 1; i >= 0; i-- {
+			if dropped[i].Type == HistoryMassChange {
+				return append([]HistoryEvent{dropped[i]}, history[1:]...)
+			}
+		}
+	}
+	return history
+}
+
+// History returns the recorded events ordered from oldest to newest.
+func (w *Watcher) History() []HistoryEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	history := make([]HistoryEvent, len(w.history))
+	copy(history, w.history)
+	return history
+}
+
+// recordEvent adds an event to the history and statistics and saves them. The event is
+// appended to the history file, which is only rewritten once it has grown too long or
+// no longer matches the history in memory, such as after events were kept in memory
+// while the primary destination was not available.
+func (w *Watcher) recordEvent(event HistoryEvent) {
+	if event.Time.IsZero() {
+		event.Time = w.clock.Now()
+	}
+
+	w.historyMu.Lock()
+	defer w.historyMu.Unlock()
+
+	w.mu.Lock()
+	w.history = trimHistory(append(w.history, event), w.massChange != "")
+	history := make([]HistoryEvent, len(w.history))
+	copy(history, w.history)
+	w.stats.count(event, history)
+	stats := w.stats.copy()
+	destination := w.Destination
+	w.mu.Unlock()
+
+	if _, err := os.Stat(destination); err != nil {
+		w.historyFile = ""
+		return
+	}
+	var errs error
+	saved := false
+	if w.historyFile == destination && w.historyFileEvents < maxHistoryFileEvents {
+		// A failed append can leave part of the event in the file, it is rewritten below.
+		if err := appendHistory(destination, event); err != nil {
+			errs = err
+		} else {
+			w.historyFileEvents++
+			saved = true
+		}
+	}
+	if !saved {
+		if err := saveHistory(destination, history); err != nil {
+			errs = errors.Join(errs, err)
+			w.historyFile = ""
+		} else {
+			w.historyFile, w.historyFileEvents = destination, len(history)
+		}
+	}
+	if err := errors.Join(errs, saveStats(destination, stats)); err != nil {
+		Logf(w.Name, LogLevelError, "%v", err)
+	}
+}
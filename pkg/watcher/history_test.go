@@ -0,0 +1,180 @@
+package watcher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func historyTypes(history []HistoryEvent) []HistoryEventType {
+	types := []HistoryEventType{}
+	for _, event := range history {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestBackupHistory(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 1024)
+	watcher.CreateBackup()
+	first := watcher.Metadata[0]
+
+	if _, err := watcher.RestoreBackup(first.Path); err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	watcher.Retention = RetentionPolicy{KeepLast: 2}
+	if _, err := watcher.Prune(false); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+
+	expected := []HistoryEventType{
+		HistoryBackupStarted, HistoryBackupCompleted,
+		HistoryBackupStarted, HistoryBackupCompleted,
+		// The safety backup made before restoring.
+		HistoryBackupStarted, HistoryBackupCompleted,
+		HistoryBackupRestored,
+		HistoryBackupPruned,
+	}
+	history := watcher.History()
+	if !slices.Equal(historyTypes(history), expected) {
+		t.Fatalf("Expected events %v, got %v", expected, historyTypes(history))
+	}
+	if history[0].Backup != first.Path || history[0].Trigger != BackupTriggerManual {
+		t.Errorf("Unexpected first event: %+v", history[0])
+	}
+	if history[4].Trigger != BackupTriggerPreRestore || history[6].Backup != first.Path || history[7].Backup != first.Path {
+		t.Errorf("Unexpected events: %+v", history[4:])
+	}
+
+	// The history is loaded again along with the metadata.
+	reloaded, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if !slices.Equal(historyTypes(reloaded.History()), expected) {
+		t.Errorf("Expected the history to be saved, got %v", historyTypes(reloaded.History()))
+	}
+}
+
+func TestHistoryFileIsAppendedTo(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	path := historyPath(WatcherConfig.Destination)
+
+	watcher.recordEvent(HistoryEvent{Type: HistoryBackupSkipped, Message: "first"})
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	// Replacing the file instead of appending to it would also replace the inode.
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat history: %v", err)
+	}
+	watcher.recordEvent(HistoryEvent{Type: HistoryBackupSkipped, Message: "second"})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat history: %v", err)
+	}
+	if !bytes.HasPrefix(data, first) || bytes.Count(data, []byte("\n")) != 2 || !os.SameFile(before, after) {
+		t.Errorf("Expected the second event to be appended, got %q", data)
+	}
+
+	// The file is rewritten with only the kept events once it has grown too long.
+	for range maxHistoryFileEvents - 1 {
+		watcher.recordEvent(HistoryEvent{Type: HistoryBackupSkipped})
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != maxHistoryEvents {
+		t.Errorf("Expected the history file to be rewritten with %d events, got %d", maxHistoryEvents, lines)
+	}
+	reloaded, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	expected, _ := marshalHistory(watcher.History())
+	if actual, _ := marshalHistory(reloaded.History()); !bytes.Equal(actual, expected) {
+		t.Errorf("Expected the reloaded history to match")
+	}
+}
+
+func TestHistoryFileRepaired(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	path := historyPath(WatcherConfig.Destination)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create state folder: %v", err)
+	}
+	// The last event was cut short while it was being appended.
+	data := `{"time":"2024-01-01T00:00:00Z","type":"skipped","message":"kept"}` + "\n" + `{"time":"2024-01-01T00:00:01Z","ty`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if history := watcher.History(); len(history) != 1 || history[0].Message != "kept" {
+		t.Fatalf("Expected the complete event to be loaded, got %+v", history)
+	}
+
+	watcher.recordEvent(HistoryEvent{Type: HistoryBackupSkipped, Message: "new"})
+	reloaded, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if history := reloaded.History(); len(history) != 2 || history[1].Message != "new" {
+		t.Errorf("Expected the history file to be rewritten, got %+v", history)
+	}
+}
+
+func TestOldHistoryFile(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	oldPath := statePath(WatcherConfig.Destination, oldHistoryFileName)
+	if err := os.MkdirAll(filepath.Dir(oldPath), 0755); err != nil {
+		t.Fatalf("Failed to create state folder: %v", err)
+	}
+	data := `[{"time":"2024-01-01T00:00:00Z","type":"skipped","message":"old"}]`
+	if err := os.WriteFile(oldPath, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.recordEvent(HistoryEvent{Type: HistoryBackupSkipped, Message: "new"})
+
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the old history file to be removed, got %v", err)
+	}
+	reloaded, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if history := reloaded.History(); len(history) != 2 || history[0].Message != "old" || history[1].Message != "new" {
+		t.Errorf("Expected the old history to be kept, got %+v", history)
+	}
+}
@@ -0,0 +1,190 @@
+package watcher
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// PreBackupHook is called before every backup of a watcher it is registered on, an
+// error cancels the backup.
+type PreBackupHook interface {
+	BeforeBackup(watcher *Watcher, trigger BackupTrigger) error
+}
+
+// PostBackupHook is called on the backup thread after every completed backup, before
+// the observers are notified. Errors are logged.
+type PostBackupHook interface {
+	AfterBackup(watcher *Watcher, backup Backup) error
+}
+
+// FileFilter decides which files of the source are backed up, a file is only backed up
+// if every filter includes it. Filters are only supported when the go copy engine
+// copies the source.
+type FileFilter interface {
+	// IncludeFile is called with the path of a file relative to the source using
+	// forward slashes, or the name of the file for a single file source.
+	IncludeFile(relPath string, info fs.FileInfo) bool
+}
+
+// BackupTransformer changes the contents of files before they are stored in a backup,
+// such as to strip secrets from config files. Transformers are applied in the order
+// they were added and can not be used with restic or ZFS backups.
+type BackupTransformer interface {
+	// TransformFile returns the contents to store for the file at relPath, which has
+	// the same form as for FileFilter. Returning an error fails the backup.
+	TransformFile(relPath string, contents []byte) ([]byte, error)
+}
+
+// BackupLimiter limits how many backups are made at the same time, such as across all
+// the watchers of an app. Acquire blocks until the backup of watcher may start and
+// returns a function that is called once the backup is finished.
+type BackupLimiter interface {
+	Acquire(watcher *Watcher) (release func())
+}
+
+// watcherHooks are the hooks registered on a watcher.
+type watcherHooks struct {
+	pre          []PreBackupHook
+	post         []PostBackupHook
+	filters      []FileFilter
+	transformers []BackupTransformer
+}
+
+func (w *Watcher) AddPreBackupHook(hook PreBackupHook) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hooks.pre = append(w.hooks.pre, hook)
+}
+
+func (w *Watcher) AddPostBackupHook(hook PostBackupHook) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hooks.post = append(w.hooks.post, hook)
+}
+
+func (w *Watcher) AddFileFilter(filter FileFilter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hooks.filters = append(w.hooks.filters, filter)
+}
+
+func (w *Watcher) AddBackupTransformer(transformer BackupTransformer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hooks.transformers = append(w.hooks.transformers, transformer)
+}
+
+// registeredHooks returns the hooks of the watcher so they can be called without
+// holding w.mu. Hooks are only ever appended so the slices are not changed afterwards.
+func (w *Watcher) registeredHooks() watcherHooks {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.hooks
+}
+
+// check returns an error if the hooks can not be used for a backup made with engine,
+// snapshot and restic.
+func (h watcherHooks) check(engine CopyEngine, snapshot SnapshotMode, restic bool) error {
+	e, goEngine := engine.(goCopyEngine)
+	copied := !restic && (snapshot == "" || snapshot == SnapshotModeReflink)
+	if len(h.filters) > 0 && (!goEngine || !copied) {
+		return fmt.Errorf("file filters are only supported when the %s copy engine copies the source", CopyEngineGo)
+	}
+	if len(h.transformers) > 0 && (restic || snapshot == SnapshotModeZFS) {
+		return fmt.Errorf("backup transformers can not change restic or ZFS backups")
+	}
+	if len(h.transformers) > 0 && goEngine && e.chunking.enabled() {
+		return fmt.Errorf("backup transformers can not change chunked files")
+	}
+	return nil
+}
+
+// withFilters returns a copy engine that only copies the files every filter includes.
+// check makes sure the engine is the go engine if there are any filters.
+func withFilters(engine CopyEngine, filters []FileFilter) CopyEngine {
+	goEngine, ok := engine.(goCopyEngine)
+	if !ok || len(filters) == 0 {
+		return engine
+	}
+	goEngine.filters = filters
+	return goEngine
+}
+
+// hookRelPath returns the path hooks are called with for a file inside root, the name of
+// the file if it is root itself.
+func hookRelPath(root, path string) string {
+	if filepath.Clean(root) == filepath.Clean(path) {
+		return filepath.Base(path)
+	}
+	relPath, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// includedByFilters returns true if every filter includes the file at path inside root.
+func includedByFilters(filters []FileFilter, root, path string, info fs.FileInfo) bool {
+	for _, filter := range filters {
+		if !filter.IncludeFile(hookRelPath(root, path), info) {
+			return false
+		}
+	}
+	return true
+}
+
+// filtersIncludeEvent returns true if the file filters of the watcher can include the
+// file of an event. Files that no longer exist always can since they may be in the
+// latest backup.
+func (w *Watcher) filtersIncludeEvent(path string) bool {
+	filters := w.registeredHooks().filters
+	if len(filters) == 0 {
+		return true
+	}
+	info, err := os.Lstat(path)
+	return err != nil || info.IsDir() || includedByFilters(filters, w.Source, path, info)
+}
+
+// transformContents passes the contents of the file at relPath through every
+// transformer.
+func transformContents(transformers []BackupTransformer, relPath string, contents []byte) ([]byte, error) {
+	for _, transformer := range transformers {
+		var err error
+		if contents, err = transformer.TransformFile(relPath, contents); err != nil {
+			return nil, fmt.Errorf("error transforming %s: %w", relPath, err)
+		}
+	}
+	return contents, nil
+}
+
+// transformBackup rewrites the files copied to path that the transformers change,
+// keeping their modification times. Paths are relative to root.
+func transformBackup(transformers []BackupTransformer, root, path string) error {
+	if len(transformers) == 0 {
+		return nil
+	}
+	return filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		transformed, err := transformContents(transformers, hookRelPath(root, file), contents)
+		if err != nil || bytes.Equal(transformed, contents) {
+			return err
+		}
+		if err := os.WriteFile(file, transformed, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chtimes(file, info.ModTime(), info.ModTime())
+	})
+}
@@ -0,0 +1,145 @@
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type testHooks struct {
+	cancel bool
+	after  []Backup
+}
+
+func (h *testHooks) BeforeBackup(watcher *Watcher, trigger BackupTrigger) error {
+	if h.cancel {
+		return errors.New("cancelled")
+	}
+	return nil
+}
+
+func (h *testHooks) AfterBackup(watcher *Watcher, backup Backup) error {
+	h.after = append(h.after, backup)
+	return nil
+}
+
+func (h *testHooks) IncludeFile(relPath string, info fs.FileInfo) bool {
+	return !strings.HasSuffix(relPath, ".log")
+}
+
+func (h *testHooks) TransformFile(relPath string, contents []byte) ([]byte, error) {
+	if relPath != "config/app.env" {
+		return contents, nil
+	}
+	return bytes.ReplaceAll(contents, []byte("hunter2"), []byte("[redacted]")), nil
+}
+
+func TestBackupHooks(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	hooks := &testHooks{cancel: true}
+	watcher.AddPreBackupHook(hooks)
+	watcher.AddPostBackupHook(hooks)
+	watcher.AddFileFilter(hooks)
+	watcher.AddBackupTransformer(hooks)
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	CreateDummyFile(t, WatcherConfig.Source, "debug.log", 10)
+	if err := os.MkdirAll(filepath.Join(WatcherConfig.Source, "config"), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(WatcherConfig.Source, "config", "app.env"), []byte("PASSWORD=hunter2\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, ok := watcher.createTriggeredBackup(BackupTriggerManual); ok {
+		t.Fatalf("Expected the pre-backup hook to cancel the backup")
+	}
+	if history := watcher.History(); len(history) == 0 || history[len(history)-1].Type != HistoryBackupSkipped {
+		t.Errorf("Expected the cancelled backup to be recorded as skipped, got %+v", history)
+	}
+
+	hooks.cancel = false
+	backup, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatalf("Failed to create backup")
+	}
+	if len(hooks.after) != 1 || hooks.after[0].Path != backup.Path {
+		t.Errorf("Expected the post-backup hook to be called with the backup, got %+v", hooks.after)
+	}
+	backupPath := watcher.BackupPath(backup)
+	if _, err := os.Stat(filepath.Join(backupPath, "debug.log")); !os.IsNotExist(err) {
+		t.Errorf("Expected the filtered file to be left out of the backup")
+	}
+	contents, err := os.ReadFile(filepath.Join(backupPath, "config", "app.env"))
+	if err != nil || string(contents) != "PASSWORD=[redacted]\n" {
+		t.Errorf("Expected the secret to be stripped, got %q: %v", contents, err)
+	}
+	if result := watcher.VerifyBackup(backup); result.Status != VerifyStatusOK {
+		t.Errorf("Expected the transformed backup to verify, got %+v", result)
+	}
+	if matches, err := watcher.SourceMatchesLatestBackup(); err != nil || !matches {
+		t.Errorf("Expected the source to match the transformed backup, got %t: %v", matches, err)
+	}
+	if watcher.filtersIncludeEvent(filepath.Join(WatcherConfig.Source, "debug.log")) {
+		t.Errorf("Expected events for filtered files to be ignored")
+	}
+
+	if err := watcher.registeredHooks().check(rsyncCopyEngine{}, "", false); err == nil {
+		t.Errorf("Expected file filters to require the go copy engine")
+	}
+	if err := (watcherHooks{transformers: []BackupTransformer{hooks}}).check(goCopyEngine{}, "", true); err == nil {
+		t.Errorf("Expected transformers to be refused for restic backups")
+	}
+}
+
+// gateLimiter lets backups start once the gate is closed and counts the releases.
+type gateLimiter struct {
+	gate     chan struct{}
+	released atomic.Int32
+}
+
+func (l *gateLimiter) Acquire(watcher *Watcher) func() {
+	<-l.gate
+	return func() { l.released.Add(1) }
+}
+
+func TestBackupLimiter(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	limiter := &gateLimiter{gate: make(chan struct{})}
+	watcher, err := NewTempWatcher(WatcherConfig, WithBackupLimiter(limiter))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+
+	done := make(chan struct{})
+	go func() {
+		watcher.CreateBackup()
+		close(done)
+	}()
+	waitUntil(t, "Expected the backup to wait for the limiter", func() bool {
+		return watcher.Status().Deferred != ""
+	})
+	if len(watcher.ListBackups()) != 0 {
+		t.Fatalf("Expected the backup to wait for the limiter")
+	}
+
+	close(limiter.gate)
+	<-done
+	if len(watcher.ListBackups()) != 1 || limiter.released.Load() != 1 {
+		t.Errorf("Expected one backup that released the limiter, got %d backups and %d releases", len(watcher.ListBackups()), limiter.released.Load())
+	}
+	if deferred := watcher.Status().Deferred; deferred != "" {
+		t.Errorf("Expected the status to be cleared, got '%s'", deferred)
+	}
+}
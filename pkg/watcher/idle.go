@@ -0,0 +1,22 @@
+package watcher
+
+import "fmt"
+
+// IdleTriggerConfig holds automatic backups until there has been no keyboard or mouse
+// input for a while, so changes made while working are backed up together instead of
+// in many snapshots of unfinished work.
+type IdleTriggerConfig struct {
+	// How long there must be no input before the changes are backed up.
+	After Duration `json:"after,omitempty" yaml:"after,omitempty" toml:"after,omitempty"`
+}
+
+func (c IdleTriggerConfig) enabled() bool {
+	return c.After > 0
+}
+
+func (c IdleTriggerConfig) validate() error {
+	if c.After < 0 {
+		return fmt.Errorf("idle trigger time must be at least 0 seconds")
+	}
+	return nil
+}
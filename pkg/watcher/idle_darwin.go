@@ -0,0 +1,31 @@
+//go:build darwin
+
+package watcher
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// idleTime reads HIDIdleTime, the nanoseconds since the last input, from ioreg.
+func idleTime() (time.Duration, error) {
+	output, err := exec.Command("ioreg", "-c", "IOHIDSystem", "-d", "4").Output()
+	if err != nil {
+		return 0, err
+	}
+	for line := range strings.Lines(string(output)) {
+		_, value, found := strings.Cut(line, `"HIDIdleTime" = `)
+		if !found {
+			continue
+		}
+		nanoseconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(nanoseconds), nil
+	}
+	return 0, errors.New("HIDIdleTime not found")
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package watcher
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// idleTime asks the GNOME idle monitor, or xprintidle on other X11 desktops, how long
+// there has been no input.
+func idleTime() (time.Duration, error) {
+	output, err := exec.Command("gdbus", "call", "--session", "--dest", "org.gnome.Mutter.IdleMonitor",
+		"--object-path", "/org/gnome/Mutter/IdleMonitor/Core", "--method", "org.gnome.Mutter.IdleMonitor.GetIdletime").Output()
+	if err == nil {
+		// The reply looks like "(uint64 12345,)".
+		value := strings.Trim(strings.TrimSpace(string(output)), "(,)")
+		if milliseconds, err := strconv.ParseUint(strings.TrimPrefix(value, "uint64 "), 10, 64); err == nil {
+			return time.Duration(milliseconds) * time.Millisecond, nil
+		}
+	}
+
+	output, err = exec.Command("xprintidle").Output()
+	if err != nil {
+		return 0, errors.New("the idle time can only be read on GNOME or with xprintidle")
+	}
+	milliseconds, err := strconv.ParseUint(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(milliseconds) * time.Millisecond, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !windows && !darwin
+
+package watcher
+
+import (
+	"errors"
+	"time"
+)
+
+// idleTime is not supported on other platforms.
+func idleTime() (time.Duration, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,83 @@
+package watcher
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIdleTrigger(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 100 * time.Millisecond
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	// The input stops once the user is done working.
+	var mu sync.Mutex
+	var lastInput time.Time
+	working := true
+	watcher.idleTime = func() (time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if working {
+			lastInput = clock.Now()
+		}
+		return clock.Now().Sub(lastInput), nil
+	}
+	idleAfter := 300 * time.Millisecond
+	watcher.IdleTrigger = IdleTriggerConfig{After: Duration(idleAfter)}
+	observer := startBackupLoop(t, watcher)
+
+	// The backup is deferred once the change settles and again when the user is still
+	// working after the deferral.
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+	clock.BlockUntil(1)
+	clock.Advance(WatcherConfig.WaitTime)
+	clock.BlockUntil(1)
+	clock.Advance(idleAfter)
+	clock.BlockUntil(1)
+	watcher.flushObservers()
+	if count := observer.getCurrentCount(); count != 0 {
+		t.Fatalf("Expected no backups while the user is working, got %d", count)
+	}
+	if watcher.Status().Deferred == "" {
+		t.Errorf("Expected the status to show that backups are deferred")
+	}
+
+	mu.Lock()
+	working = false
+	mu.Unlock()
+	clock.Advance(idleAfter)
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected a backup once the computer is idle")
+	}
+	watcher.flushObservers()
+	if count := observer.getCurrentCount(); count != 1 {
+		t.Errorf("Expected the changes to be backed up once, got %d backups", count)
+	}
+}
+
+func TestIdleTriggerWithoutIdleTime(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 100 * time.Millisecond
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.idleTime = func() (time.Duration, error) { return 0, errors.ErrUnsupported }
+	watcher.IdleTrigger = IdleTriggerConfig{After: Duration(time.Hour)}
+	observer := startBackupLoop(t, watcher)
+
+	// Changes are still backed up if the idle time can not be read.
+	watcher.requestBackup(BackupTriggerChange)
+	if !observer.WaitUntilCount(1, 2*time.Second) {
+		t.Fatalf("Expected a backup without the idle time")
+	}
+}
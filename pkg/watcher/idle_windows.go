@@ -0,0 +1,33 @@
+//go:build windows
+
+package watcher
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32               = windows.NewLazySystemDLL("user32.dll")
+	procGetLastInputInfo = user32.NewProc("GetLastInputInfo")
+	procGetTickCount     = kernel32.NewProc("GetTickCount")
+)
+
+// lastInputInfo is LASTINPUTINFO.
+type lastInputInfo struct {
+	Size uint32
+	Time uint32
+}
+
+// idleTime compares the tick count of the last input with the current one.
+func idleTime() (time.Duration, error) {
+	info := lastInputInfo{Size: uint32(unsafe.Sizeof(lastInputInfo{}))}
+	if ret, _, err := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info))); ret == 0 {
+		return 0, err
+	}
+	now, _, _ := procGetTickCount.Call()
+	// Both tick counts wrap around after 49.7 days, the difference is still correct.
+	return time.Duration(uint32(now)-info.Time) * time.Millisecond, nil
+}
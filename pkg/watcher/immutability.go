@@ -0,0 +1,118 @@
+package watcher
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+)
+
+// protectBackup makes every file and folder in a backup read-only so it cannot be
+// changed by accident. On Linux the immutable attribute is also set when possible,
+// this usually requires root.
+func protectBackup(path string) error {
+	paths, err := collectPaths(path)
+	if err != nil {
+		return err
+	}
+
+	// Children are changed before their parents because a read-only directory cannot
+	// be modified on some systems.
+	slices.Reverse(paths)
+	for _, p := range paths {
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			continue
+		}
+		if err := os.Chmod(p, info.Mode().Perm()&^0222); err != nil {
+			return err
+		}
+	}
+
+	setImmutable(path, true)
+	return nil
+}
+
+// unprotectBackup reverses protectBackup so a backup can be restored from or deleted.
+func unprotectBackup(path string) error {
+	setImmutable(path, false)
+
+	paths, err := collectPaths(path)
+	if err != nil {
+		return err
+	}
+
+	// Parents are changed before their children so the children can be modified.
+	for _, p := range paths {
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			continue
+		}
+		if err := os.Chmod(p, info.Mode().Perm()|0200); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withUnprotectedBackup lifts the protection of a backup while fn runs. The protection
+// is restored afterwards if the backup still exists.
+func (w *Watcher) withUnprotectedBackup(backup Backup, fn func() error) error {
+	if !w.ReadOnlyBackups || backup.ResticSnapshot != "" || backup.Snapshot == SnapshotModeZFS {
+		return fn()
+	}
+
+	path := w.BackupPath(backup)
+	if err := unprotectBackup(path); err != nil {
+		return err
+	}
+
+	err := fn()
+	if _, statErr := os.Stat(path); statErr == nil {
+		if protectErr := protectBackup(path); protectErr != nil {
+			Logf(w.Name, LogLevelError, "Error protecting backup %s: %v", backup.Path, protectErr)
+		}
+	}
+	return err
+}
+
+// collectPaths returns path and everything inside it with parents before children.
+func collectPaths(path string) ([]string, error) {
+	paths := []string{}
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	return paths, err
+}
+
+// setImmutable sets or clears the Linux immutable attribute. Failures are ignored since
+// most users will not have permission to change the attribute.
+func setImmutable(path string, immutable bool) {
+	if runtime.GOOS != "linux" {
+		return
+	}
+	chattr, err := exec.LookPath("chattr")
+	if err != nil {
+		return
+	}
+
+	flag := "-i"
+	if immutable {
+		flag = "+i"
+	}
+	if output, err := exec.Command(chattr, "-R", flag, path).CombinedOutput(); err != nil {
+		Logf("", LogLevelDebug, "Could not change immutable attribute of %s: %v: %s", path, err, output)
+	}
+}
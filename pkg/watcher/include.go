@@ -0,0 +1,101 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IncludePatterns limits backups to the files matching at least one of the patterns,
+// such as "*.sav". A pattern without a slash matches the file name anywhere in the
+// source, a pattern with a slash such as "saves/*.sav" matches the path relative to
+// the source. Folders are always backed up so the layout of the source is kept.
+type IncludePatterns []string
+
+func (p IncludePatterns) enabled() bool {
+	return len(p) > 0
+}
+
+func (p IncludePatterns) validate() error {
+	for _, pattern := range p {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// validateIncludePatterns checks that the include patterns of a folder pair can be used
+// with the rest of its settings.
+func validateIncludePatterns(config *WatcherConfig, singleFile bool) error {
+	if err := config.Include.validate(); err != nil {
+		return err
+	}
+	if !config.Include.enabled() {
+		return nil
+	}
+	if singleFile {
+		return fmt.Errorf("include patterns require the source to be a folder")
+	}
+	if config.Restic.enabled() || config.Snapshot != "" {
+		return fmt.Errorf("include patterns can not be combined with restic or filesystem snapshots")
+	}
+	return nil
+}
+
+// matches returns true if a path relative to the source, using forward slashes, is
+// included.
+func (p IncludePatterns) matches(relPath string) bool {
+	if !p.enabled() {
+		return true
+	}
+	for _, pattern := range p {
+		name := relPath
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(relPath)
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// includesFile returns true if a file inside root is included, paths outside of root
+// never are.
+func (p IncludePatterns) includesFile(root, file string) bool {
+	if !p.enabled() {
+		return true
+	}
+	relPath, err := filepath.Rel(root, file)
+	if err != nil || !isPathWithin(root, file) {
+		return false
+	}
+	return p.matches(filepath.ToSlash(relPath))
+}
+
+// includesEvent returns true if a file event inside root can change a backup. Events
+// for folders always can since a folder may have been moved with included files in it.
+func (p IncludePatterns) includesEvent(root, file string) bool {
+	if p.includesFile(root, file) {
+		return true
+	}
+	info, err := os.Stat(file)
+	return err == nil && info.IsDir()
+}
+
+// apply returns a copy engine that only copies the included files, only the go copy
+// engine supports patterns.
+func (p IncludePatterns) apply(engine CopyEngine) (CopyEngine, error) {
+	if !p.enabled() {
+		return engine, nil
+	}
+	goEngine, ok := engine.(goCopyEngine)
+	if !ok {
+		return nil, fmt.Errorf("include patterns are only supported by the %s copy engine", CopyEngineGo)
+	}
+	goEngine.include = p
+	return goEngine, nil
+}
@@ -0,0 +1,145 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIncludePatternsMatch(t *testing.T) {
+	t.Parallel()
+	patterns := IncludePatterns{"*.sav", "config/*.cfg"}
+	cases := map[string]bool{
+		"slot1.sav":           true,
+		"saves/deep/slot.sav": true,
+		"config/game.cfg":     true,
+		"game.cfg":            false,
+		"other/config.cfg":    false,
+		"notes.txt":           false,
+	}
+	for relPath, expected := range cases {
+		if matched := patterns.matches(relPath); matched != expected {
+			t.Errorf("Expected '%s' to match %t, got %t", relPath, expected, matched)
+		}
+	}
+	if !IncludePatterns(nil).matches("anything.txt") {
+		t.Errorf("Expected every file to be included without patterns")
+	}
+	if (IncludePatterns{"*.sav"}).includesFile("/source", "/elsewhere/slot.sav") {
+		t.Errorf("Expected a file outside the source to not be included")
+	}
+}
+
+// includeWatcher creates a watcher that only backs up .sav files.
+func includeWatcher(t *testing.T) (*Watcher, TempWatcherConfig) {
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Include = IncludePatterns{"*.sav"}
+	if watcher.CopyEngine, err = watcher.Include.apply(watcher.CopyEngine); err != nil {
+		t.Fatalf("Failed to apply include patterns: %v", err)
+	}
+	return watcher, WatcherConfig
+}
+
+func TestIncludePatternsBackup(t *testing.T) {
+	t.Parallel()
+	watcher, WatcherConfig := includeWatcher(t)
+	CreateDummyFile(t, WatcherConfig.Source, "slot1.sav", 10)
+	CreateDummyFile(t, WatcherConfig.Source, "log.txt", 10)
+	CreateDummyFile(t, filepath.Join(WatcherConfig.Source, "saves"), "slot2.sav", 10)
+
+	backup, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatalf("Failed to create backup")
+	}
+	backupPath := watcher.BackupPath(backup)
+	for _, name := range []string{"slot1.sav", filepath.Join("saves", "slot2.sav")} {
+		if _, err := os.Stat(filepath.Join(backupPath, name)); err != nil {
+			t.Errorf("Expected %s to be backed up: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(backupPath, "log.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected log.txt to not be backed up")
+	}
+
+	// Files that are not included should not make the source differ from the backup.
+	CreateDummyFile(t, WatcherConfig.Source, "other.txt", 10)
+	if matches, err := watcher.SourceMatchesLatestBackup(); err != nil || !matches {
+		t.Errorf("Expected the source to match the backup, got %t: %v", matches, err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "slot3.sav", 10)
+	if matches, err := watcher.SourceMatchesLatestBackup(); err != nil || matches {
+		t.Errorf("Expected a new included file to not match the backup, got %t: %v", matches, err)
+	}
+
+	if !watcher.Include.includesEvent(WatcherConfig.Source, filepath.Join(WatcherConfig.Source, "saves")) {
+		t.Errorf("Expected events for folders to be included")
+	}
+	if watcher.Include.includesEvent(WatcherConfig.Source, filepath.Join(WatcherConfig.Source, "other.txt")) {
+		t.Errorf("Expected events for other files to be ignored")
+	}
+}
+
+func TestIncludePatternsRestoreKeepsOtherFiles(t *testing.T) {
+	t.Parallel()
+	watcher, WatcherConfig := includeWatcher(t)
+	CreateDummyFile(t, WatcherConfig.Source, "slot1.sav", 10)
+	CreateDummyFile(t, WatcherConfig.Source, "log.txt", 10)
+	backup, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatalf("Failed to create backup")
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "slot2.sav", 10)
+	if _, err := watcher.RestoreBackup(backup.Path); err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	for name, expected := range map[string]bool{"slot1.sav": true, "log.txt": true, "slot2.sav": false} {
+		_, err := os.Stat(filepath.Join(WatcherConfig.Source, name))
+		if exists := err == nil; exists != expected {
+			t.Errorf("Expected %s to exist %t after the restore, got %t", name, expected, exists)
+		}
+	}
+}
+
+func TestIncludePatternsValidation(t *testing.T) {
+	t.Parallel()
+	temp := DefaultTempWatcherConfig(t)
+	CreateDummyFile(t, temp.Source, "file1.txt", 10)
+
+	config := &WatcherConfig{
+		ID:           temp.Name,
+		Source:       temp.Source,
+		Destination:  temp.Destination,
+		WaitTime:     Duration(temp.WaitTime),
+		FolderFormat: temp.FolderFormat,
+		Include:      IncludePatterns{"[*.sav"},
+	}
+	if _, err := NewWatcher(*config); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+
+	config.Include = IncludePatterns{"*.sav"}
+	config.Restic = ResticConfig{Repository: "/repo"}
+	if _, err := NewWatcher(*config); err == nil {
+		t.Errorf("Expected an error when combining include patterns with restic")
+	}
+
+	config.Restic = ResticConfig{}
+	config.Source = filepath.Join(temp.Source, "file1.txt")
+	if _, err := NewWatcher(*config); err == nil {
+		t.Errorf("Expected an error for a single file source")
+	}
+
+	config.Source = temp.Source
+	watcher, err := NewWatcher(*config)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if len(watcher.Include) != 1 || watcher.Include[0] != "*.sav" {
+		t.Errorf("Expected the include patterns to be set, got %v", watcher.Include)
+	}
+}
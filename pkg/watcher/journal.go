@@ -0,0 +1,142 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// Name of the file in each destination that lists the backups currently being written.
+const journalFileName = "journal.json"
+
+// JournalEntry records that a backup was started. The entry is removed once the backup
+// is complete, an entry that is still there when the watcher starts belongs to a
+// backup that was interrupted.
+type JournalEntry struct {
+	// Path of the backup folder relative to the destination, using forward slashes.
+	Path    string    `json:"path"`
+	Started time.Time `json:"started"`
+}
+
+func journalPath(destination string) string {
+	return statePath(destination, journalFileName)
+}
+
+func readJournal(destination string) ([]JournalEntry, error) {
+	entries := []JournalEntry{}
+	data, err := os.ReadFile(journalPath(destination))
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error parsing journal: %w", err)
+	}
+	return entries, nil
+}
+
+// writeJournal replaces the journal of a destination, the file is removed when there
+// are no entries left.
+func writeJournal(destination string, entries []JournalEntry) error {
+	path := journalPath(destination)
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error clearing journal: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling journal: %w", err)
+	}
+	if err := writeStateFile(path, data); err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+	return nil
+}
+
+// journalStart records that a backup is being written to destination.
+func (w *Watcher) journalStart(destination, path string, started time.Time) error {
+	w.journalMu.Lock()
+	defer w.journalMu.Unlock()
+
+	entries, err := readJournal(destination)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, JournalEntry{Path: path, Started: started})
+	return writeJournal(destination, entries)
+}
+
+// journalFinish removes the entry of a backup that completed.
+func (w *Watcher) journalFinish(destination, path string) error {
+	w.journalMu.Lock()
+	defer w.journalMu.Unlock()
+
+	entries, err := readJournal(destination)
+	if err != nil {
+		return err
+	}
+	entries = slices.DeleteFunc(entries, func(entry JournalEntry) bool {
+		return entry.Path == path
+	})
+	return writeJournal(destination, entries)
+}
+
+// recoverInterruptedBackups removes the partial backup folders of every journal entry
+// that was never completed and clears the journals. It returns true if a partial backup
+// was found. This must only be called while the watcher is stopped.
+func (w *Watcher) recoverInterruptedBackups() (bool, error) {
+	w.journalMu.Lock()
+	defer w.journalMu.Unlock()
+
+	w.mu.Lock()
+	completed := map[string]bool{}
+	for _, backup := range w.Metadata {
+		completed[w.BackupPath(backup)] = true
+	}
+	w.mu.Unlock()
+
+	recovered := false
+	var errs error
+	for _, destination := range w.destinations() {
+		entries, err := readJournal(destination)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error recovering interrupted backups in %s: %w", destination, err))
+			continue
+		}
+
+		remaining := []JournalEntry{}
+		for _, entry := range entries {
+			backup := Backup{Path: entry.Path, Destination: destination}
+			path := w.BackupPath(backup)
+			// The backup finished but the journal was not updated.
+			if completed[path] {
+				continue
+			}
+
+			recovered = true
+			if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+				Logf(w.Name, LogLevelWarn, "Backup started at %s was interrupted before anything was written", entry.Started.Format(time.DateTime))
+				continue
+			}
+			if err := w.deleteBackup(backup); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("error removing interrupted backup %s: %w", path, err))
+				remaining = append(remaining, entry)
+				continue
+			}
+			Logf(w.Name, LogLevelWarn, "Removed backup %s that was interrupted", path)
+		}
+
+		if len(remaining) != len(entries) {
+			errs = errors.Join(errs, writeJournal(destination, remaining))
+		}
+	}
+	return recovered, errs
+}
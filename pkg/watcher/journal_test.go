@@ -0,0 +1,96 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalClearedAfterBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+	if _, err := os.Stat(journalPath(WatcherConfig.Destination)); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal to be removed after a successful backup")
+	}
+}
+
+func TestRecoverInterruptedBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	observer := NewSimplifiedObserver()
+	watcher.AddObserver(observer)
+
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+	watcher.flushObservers()
+	observer.CurrentCount = 0
+
+	// Simulate a crash in the middle of copying a backup. The source still matches the
+	// latest backup so only the recovery causes a new backup.
+	started := time.Now()
+	partial := filepath.ToSlash(started.Format(WatcherConfig.FolderFormat))
+	if err := watcher.journalStart(WatcherConfig.Destination, partial, started); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+	CreateDummyFile(t, filepath.Join(WatcherConfig.Destination, partial), "file1.txt", 10)
+
+	if err := watcher.StartWatcher(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	t.Cleanup(func() { watcher.StopWatcher() })
+
+	if _, err := os.Stat(filepath.Join(WatcherConfig.Destination, partial)); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial backup to be removed")
+	}
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Timeout waiting for the recovery backup")
+	}
+
+	backups := watcher.ListBackups()
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %d", len(backups))
+	}
+	sidecar, err := ReadBackupSidecar(watcher.BackupPath(backups[1]))
+	if err != nil {
+		t.Fatalf("Failed to read sidecar: %v", err)
+	}
+	if sidecar.Trigger != BackupTriggerRecovery {
+		t.Errorf("Expected a recovery backup, got trigger '%s'", sidecar.Trigger)
+	}
+	if _, err := os.Stat(journalPath(WatcherConfig.Destination)); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal to be cleared")
+	}
+}
+
+func TestJournalClearedAfterFailedBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.FolderFormat = "2006/01-02_15-04-05.000000"
+	clock := NewFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local))
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	// A file where the year folder of the backup goes makes creating it fail.
+	CreateDummyFile(t, WatcherConfig.Destination, "2024", 10)
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	if _, ok := watcher.createTriggeredBackup(BackupTriggerManual); ok {
+		t.Fatalf("Expected the backup to fail")
+	}
+	if _, err := os.Stat(journalPath(WatcherConfig.Destination)); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal to be cleared after a failed backup")
+	}
+}
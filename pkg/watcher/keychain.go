@@ -0,0 +1,26 @@
+package watcher
+
+import "errors"
+
+// Name secrets are stored under in the keychain of the OS.
+const keychainService = "i-saw-that"
+
+var ErrSecretNotFound = errors.New("secret not found in the keychain")
+
+// Keychain stores secrets such as passwords outside of the config file.
+type Keychain interface {
+	// Get returns the secret stored as name, ErrSecretNotFound if there is none.
+	Get(name string) (string, error)
+	Set(name, secret string) error
+	// Delete removes a secret, ErrSecretNotFound if there is none.
+	Delete(name string) error
+}
+
+// OSKeychain returns the credential store of the OS: the Credential Manager on Windows,
+// the login keychain on macOS and the Secret Service through secret-tool elsewhere.
+func OSKeychain() Keychain {
+	return osKeychain{}
+}
+
+// osKeychain is implemented for each OS.
+type osKeychain struct{}
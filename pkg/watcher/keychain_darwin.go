@@ -0,0 +1,64 @@
+//go:build darwin
+
+package watcher
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Exit code of security when the item does not exist.
+const securityNotFound = 44
+
+// runSecurity runs the security tool that manages the login keychain.
+func runSecurity(args ...string) (string, error) {
+	output, err := exec.Command("security", args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityNotFound {
+		return "", ErrSecretNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("security failed: %w", err)
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+func (osKeychain) Get(name string) (string, error) {
+	return runSecurity("find-generic-password", "-s", keychainService, "-a", name, "-w")
+}
+
+// Set gives the command to the interactive mode of security on stdin, so the secret is
+// not in the arguments of a process where other users could see it. The secret is hex
+// encoded so it needs no quoting.
+func (osKeychain) Set(name, secret string) error {
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", quoteSecurityArg(keychainService), quoteSecurityArg(name), hex.EncodeToString([]byte(secret)))
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(command)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// The interactive mode reports a failed command on stderr but can still exit with
+	// 0.
+	err := cmd.Run()
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		return fmt.Errorf("security failed: %s", message)
+	}
+	if err != nil {
+		return fmt.Errorf("security failed: %w", err)
+	}
+	return nil
+}
+
+// quoteSecurityArg quotes an argument for the interactive mode of security, which splits
+// commands like a shell.
+func quoteSecurityArg(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
+
+func (osKeychain) Delete(name string) error {
+	_, err := runSecurity("delete-generic-password", "-s", keychainService, "-a", name)
+	return err
+}
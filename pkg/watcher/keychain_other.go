@@ -0,0 +1,50 @@
+//go:build !windows && !darwin
+
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runSecretTool runs secret-tool, which stores secrets with the Secret Service of the
+// desktop such as GNOME Keyring or KWallet.
+func runSecretTool(stdin string, args ...string) (string, error) {
+	path, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return "", fmt.Errorf("no keychain is available, install secret-tool: %w", err)
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("secret-tool failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+func (osKeychain) Get(name string) (string, error) {
+	secret, err := runSecretTool("", "lookup", "service", keychainService, "account", name)
+	// lookup fails without output when there is no secret.
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) || (err == nil && secret == "") {
+		return "", ErrSecretNotFound
+	}
+	return secret, err
+}
+
+func (osKeychain) Set(name, secret string) error {
+	_, err := runSecretTool(secret, "store", "--label", keychainService+" "+name, "service", keychainService, "account", name)
+	return err
+}
+
+// Delete can not tell a missing secret apart, clear succeeds either way.
+func (osKeychain) Delete(name string) error {
+	_, err := runSecretTool("", "clear", "service", keychainService, "account", name)
+	return err
+}
@@ -0,0 +1,147 @@
+package watcher
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	case LogLevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLogLevel converts a level name from the GUI or config into a LogLevel. An empty
+// string is treated as debug so that no entries are filtered out.
+func ParseLogLevel(level string) (LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "", "debug":
+		return LogLevelDebug, nil
+	case "info":
+		return LogLevelInfo, nil
+	case "warn", "warning":
+		return LogLevelWarn, nil
+	case "error":
+		return LogLevelError, nil
+	}
+	return LogLevelDebug, fmt.Errorf("unknown log level: %s", level)
+}
+
+type LogEntry struct {
+	Time      time.Time `json:"time"`
+	Level     string    `json:"level"`
+	WatcherID string    `json:"watcher_id,omitempty"`
+	Message   string    `json:"message"`
+
+	level LogLevel
+}
+
+// LogBuffer is a fixed size ring of the most recent log entries. Once the buffer is
+// full the oldest entries are overwritten so memory usage never grows.
+type LogBuffer struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	// Index where the next entry will be written.
+	next int
+	// Number of valid entries in the buffer.
+	count int
+}
+
+func NewLogBuffer(capacity int) *LogBuffer {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &LogBuffer{
+		entries: make([]LogEntry, capacity),
+	}
+}
+
+func (b *LogBuffer) Add(entry LogEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.count < len(b.entries) {
+		b.count++
+	}
+}
+
+// Recent returns up to limit entries ordered from oldest to newest. Entries are
+// filtered by watcherID (empty matches everything) and by the minimum level. A limit
+// of 0 or less returns every matching entry.
+func (b *LogBuffer) Recent(watcherID string, minLevel LogLevel, limit int) []LogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := []LogEntry{}
+	// Walk backwards from the newest entry so the limit keeps the most recent ones.
+	for i := range b.count {
+		index := (b.next - 1 - i + len(b.entries)) % len(b.entries)
+		entry := b.entries[index]
+		if watcherID != "" && entry.WatcherID != watcherID {
+			continue
+		}
+		if entry.level < minLevel {
+			continue
+		}
+		result = append(result, entry)
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+
+	// Reverse so the oldest entry is first.
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// Shared by every watcher so the GUI can show a single log panel.
+var Logs = NewLogBuffer(1000)
+
+// Messages below this level are not written to the standard log. They are still
+// recorded in Logs so the GUI can show them.
+var StdoutLogLevel = LogLevelDebug
+
+// Logf writes a message to the standard logger and records it in Logs. Messages
+// belonging to a watcher are prefixed with the watcher ID in the standard log.
+func Logf(watcherID string, level LogLevel, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if level >= StdoutLogLevel {
+		if watcherID != "" {
+			log.Printf("%s: %s", watcherID, message)
+		} else {
+			log.Print(message)
+		}
+	}
+
+	Logs.Add(LogEntry{
+		Time:      time.Now(),
+		Level:     level.String(),
+		WatcherID: watcherID,
+		Message:   message,
+		level:     level,
+	})
+}
@@ -0,0 +1,46 @@
+package watcher
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLogBufferDropsOldestEntries(t *testing.T) {
+	t.Parallel()
+	buffer := NewLogBuffer(3)
+
+	for i := range 5 {
+		buffer.Add(LogEntry{Message: fmt.Sprintf("message %d", i), level: LogLevelInfo})
+	}
+
+	entries := buffer.Recent("", LogLevelDebug, 0)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	if entries[0].Message != "message 2" || entries[2].Message != "message 4" {
+		t.Errorf("Expected messages 2 through 4, got %v", entries)
+	}
+}
+
+func TestLogBufferFilters(t *testing.T) {
+	t.Parallel()
+	buffer := NewLogBuffer(10)
+
+	buffer.Add(LogEntry{WatcherID: "a", Message: "a debug", level: LogLevelDebug})
+	buffer.Add(LogEntry{WatcherID: "a", Message: "a error", level: LogLevelError})
+	buffer.Add(LogEntry{WatcherID: "b", Message: "b error", level: LogLevelError})
+	buffer.Add(LogEntry{WatcherID: "a", Message: "a info", level: LogLevelInfo})
+
+	entries := buffer.Recent("a", LogLevelInfo, 0)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Message != "a error" || entries[1].Message != "a info" {
+		t.Errorf("Unexpected entries: %v", entries)
+	}
+
+	entries = buffer.Recent("", LogLevelDebug, 1)
+	if len(entries) != 1 || entries[0].Message != "a info" {
+		t.Errorf("Expected only the newest entry, got %v", entries)
+	}
+}
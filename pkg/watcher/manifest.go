@@ -0,0 +1,248 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ManifestEntry describes a single file or folder inside a backup.
+type ManifestEntry struct {
+	// Path relative to the root of the manifest, always using forward slashes.
+	Path    string    `json:"path"`
+	Dir     bool      `json:"dir,omitempty"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	// Hex encoded hash of the file contents, empty for folders.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Manifest lists everything inside a folder in the order it is walked.
+type Manifest []ManifestEntry
+
+// Number of files hashed and folders read at the same time while building a manifest.
+var manifestWorkers = max(4, runtime.NumCPU())
+
+// buildManifest hashes every file inside root with algorithm. Paths that skip returns
+// true for are left out of the manifest, folders that are skipped are not walked.
+// Manifests can only be compared if they were built with the same algorithm.
+//
+// Folders are read and files are hashed by several workers, the manifest is in the
+// same order filepath.WalkDir would visit the paths in.
+func buildManifest(root string, algorithm HashAlgorithm, skip func(relPath string) bool) (Manifest, error) {
+	return scanManifest(root, algorithm, nil, skip)
+}
+
+// scanManifest is buildManifest with the hashes of unchanged files taken from cache,
+// which can be nil. Files inside root that are no longer there are removed from the
+// cache.
+func scanManifest(root string, algorithm HashAlgorithm, cache *scanCache, skip func(relPath string) bool) (Manifest, error) {
+	// Like filepath.WalkDir a root that is not a folder, including a symbolic link to
+	// one, has no contents.
+	info, err := os.Lstat(root)
+	if err != nil {
+		return nil, fmt.Errorf("error building manifest of %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return Manifest{}, nil
+	}
+
+	scan := &manifestScan{algorithm: algorithm, cache: cache, skip: skip, workers: make(chan struct{}, manifestWorkers)}
+	tree := &manifestNode{}
+	scan.scanDir(tree, root, "")
+	scan.wg.Wait()
+	if scan.err != nil {
+		return nil, fmt.Errorf("error building manifest of %s: %w", root, scan.err)
+	}
+	manifest := Manifest{}
+	tree.flatten(&manifest)
+	if cache != nil {
+		cache.prune(root, manifest)
+	}
+	return manifest, nil
+}
+
+// manifestNode is a path found while building a manifest, children are in the order
+// they were read.
+type manifestNode struct {
+	entry    ManifestEntry
+	children []*manifestNode
+}
+
+func (n *manifestNode) flatten(manifest *Manifest) {
+	for _, child := range n.children {
+		*manifest = append(*manifest, child.entry)
+		child.flatten(manifest)
+	}
+}
+
+// manifestScan tracks the workers of buildManifest and the first error they run into.
+type manifestScan struct {
+	algorithm HashAlgorithm
+	cache     *scanCache
+	skip      func(relPath string) bool
+	workers   chan struct{}
+	wg        sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// run starts task on a new goroutine if a worker is free, otherwise it is run on this
+// one so the scan never waits for a worker.
+func (s *manifestScan) run(task func()) {
+	select {
+	case s.workers <- struct{}{}:
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() { <-s.workers }()
+			task()
+		}()
+	default:
+		task()
+	}
+}
+
+func (s *manifestScan) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *manifestScan) failed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err != nil
+}
+
+// scanDir adds the contents of the folder at path to node, files are hashed and
+// subfolders are scanned by the workers.
+func (s *manifestScan) scanDir(node *manifestNode, path, relPath string) {
+	if s.failed() {
+		return
+	}
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	for _, d := range dirEntries {
+		childPath := filepath.Join(path, d.Name())
+		childRelPath := d.Name()
+		if relPath != "" {
+			childRelPath = relPath + "/" + d.Name()
+		}
+		if s.skip != nil && s.skip(childRelPath) {
+			continue
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		child := &manifestNode{entry: ManifestEntry{
+			Path:    childRelPath,
+			Dir:     d.IsDir(),
+			ModTime: info.ModTime(),
+		}}
+		node.children = append(node.children, child)
+		switch {
+		case d.IsDir():
+			s.run(func() { s.scanDir(child, childPath, childRelPath) })
+		case info.Mode().IsRegular():
+			child.entry.Size = info.Size()
+			s.run(func() {
+				if s.failed() {
+					return
+				}
+				hash, err := s.cache.hashFile(childPath, info, s.algorithm)
+				if err != nil {
+					s.fail(err)
+					return
+				}
+				child.entry.Hash = hash
+			})
+		}
+	}
+}
+
+// Hash returns a single hash identifying the contents of the manifest. Modification
+// times are left out so identical contents always produce the same hash.
+func (m Manifest) Hash() string {
+	hash := sha256.New()
+	for _, entry := range m {
+		fmt.Fprintf(hash, "%s\x00%t\x00%d\x00%s\n", entry.Path, entry.Dir, entry.Size, entry.Hash)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func hashFile(path string, algorithm HashAlgorithm) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := algorithm.newHash()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// DifferenceKind is how a path differs between two manifests.
+type DifferenceKind string
+
+const (
+	// The path is only in the other manifest.
+	DifferenceAdded DifferenceKind = "added"
+	// The path is only in the base manifest.
+	DifferenceMissing DifferenceKind = "missing"
+	// The contents differ, or the path is a file in one manifest and a folder in the
+	// other. Modification times are not compared.
+	DifferenceChanged DifferenceKind = "changed"
+)
+
+type ManifestDifference struct {
+	Path string         `json:"path"`
+	Kind DifferenceKind `json:"kind"`
+}
+
+// compareManifests returns the differences between base and other ordered by path.
+func compareManifests(base, other Manifest) []ManifestDifference {
+	entries := make(map[string]ManifestEntry, len(base))
+	for _, entry := range base {
+		entries[entry.Path] = entry
+	}
+
+	differences := []ManifestDifference{}
+	for _, entry := range other {
+		baseEntry, ok := entries[entry.Path]
+		switch {
+		case !ok:
+			differences = append(differences, ManifestDifference{entry.Path, DifferenceAdded})
+		case baseEntry.Dir != entry.Dir || baseEntry.Size != entry.Size || baseEntry.Hash != entry.Hash:
+			differences = append(differences, ManifestDifference{entry.Path, DifferenceChanged})
+		}
+		delete(entries, entry.Path)
+	}
+	for path := range entries {
+		differences = append(differences, ManifestDifference{path, DifferenceMissing})
+	}
+	slices.SortFunc(differences, func(a, b ManifestDifference) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return differences
+}
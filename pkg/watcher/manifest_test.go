@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBuildManifestOrder(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	// Enough folders and files to keep every worker busy.
+	for i := range 10 {
+		for j := range 10 {
+			CreateDummyFile(t, root, filepath.Join(fmt.Sprintf("folder%d", i), fmt.Sprintf("sub%d", j), "file.txt"), 64)
+		}
+		CreateDummyFile(t, root, fmt.Sprintf("file%d.txt", i), 64)
+	}
+	CreateDummyFile(t, root, filepath.Join("skipped", "file.txt"), 64)
+
+	manifest, err := buildManifest(root, HashXXHash, func(relPath string) bool {
+		return relPath == "skipped"
+	})
+	if err != nil {
+		t.Fatalf("Failed to build manifest: %v", err)
+	}
+
+	expected := []string{}
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		relPath, _ := filepath.Rel(root, path)
+		switch relPath = filepath.ToSlash(relPath); relPath {
+		case ".":
+			return nil
+		case "skipped":
+			return filepath.SkipDir
+		}
+		expected = append(expected, relPath)
+		return nil
+	})
+	paths := []string{}
+	for _, entry := range manifest {
+		paths = append(paths, entry.Path)
+		if entry.Dir {
+			continue
+		}
+		hash, err := hashFile(filepath.Join(root, filepath.FromSlash(entry.Path)), HashXXHash)
+		if err != nil || entry.Hash != hash || entry.Size != 64 {
+			t.Errorf("Expected %s to have size 64 and hash %s, got %d and %s", entry.Path, hash, entry.Size, entry.Hash)
+		}
+	}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("Expected the paths in walk order %v, got %v", expected, paths)
+	}
+
+	if _, err := buildManifest(filepath.Join(root, "missing"), HashXXHash, nil); err == nil {
+		t.Errorf("Expected an error for a missing folder")
+	}
+}
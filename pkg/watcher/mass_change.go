@@ -0,0 +1,165 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Window a mass change has to happen in by default.
+const defaultMassChangeWindow = 10 * time.Second
+
+var ErrRetentionPaused = errors.New("retention is paused after a mass change, check the source and resume it")
+
+// MassChangeConfig detects the pattern ransomware leaves, a large number of files
+// changed or renamed within seconds. When it is seen a backup is made right away,
+// retention stops deleting backups until it is resumed, and a warning is shown.
+type MassChangeConfig struct {
+	// Number of different files changed within Window that counts as a mass change, 0
+	// turns detection off.
+	Files int `json:"files,omitempty" yaml:"files,omitempty" toml:"files,omitempty"`
+	// Time the changes have to happen in, 10 seconds if it is 0.
+	Window Duration `json:"window,omitempty" yaml:"window,omitempty" toml:"window,omitempty"`
+}
+
+func (c MassChangeConfig) enabled() bool {
+	return c.Files > 0
+}
+
+func (c MassChangeConfig) validate() error {
+	if c.Files < 0 {
+		return fmt.Errorf("mass change files can not be negative")
+	}
+	if c.Window < 0 {
+		return fmt.Errorf("mass change window must be at least 0 seconds")
+	}
+	return nil
+}
+
+func (c MassChangeConfig) window() time.Duration {
+	if c.Window > 0 {
+		return time.Duration(c.Window)
+	}
+	return defaultMassChangeWindow
+}
+
+// massChangeEvent is a file event counted by massChangeTracker.
+type massChangeEvent struct {
+	time time.Time
+	path string
+}
+
+// massChangeTracker counts the different files changed within the window, events are
+// kept in the order they arrived so the oldest can be dropped as the window moves.
+type massChangeTracker struct {
+	events []massChangeEvent
+	counts map[string]int
+	// True once the current burst of changes was reported, reset when the window has
+	// no changes left.
+	alerted bool
+}
+
+// add counts a change and returns the different files changed within window.
+func (t *massChangeTracker) add(now time.Time, path string, window time.Duration) int {
+	if t.counts == nil {
+		t.counts = map[string]int{}
+	}
+	drop := 0
+	for drop < len(t.events) && now.Sub(t.events[drop].time) > window {
+		old := t.events[drop].path
+		if t.counts[old]--; t.counts[old] == 0 {
+			delete(t.counts, old)
+		}
+		drop++
+	}
+	t.events = t.events[drop:]
+	if len(t.events) == 0 {
+		t.alerted = false
+	}
+	t.events = append(t.events, massChangeEvent{time: now, path: path})
+	t.counts[path]++
+	return len(t.counts)
+}
+
+// commonExtension returns the extension most of the changed files have, empty if no
+// extension is shared by more than half of them.
+func (t *massChangeTracker) commonExtension() string {
+	extensions := map[string]int{}
+	for path := range t.counts {
+		if extension := strings.ToLower(filepath.Ext(path)); extension != "" {
+			extensions[extension]++
+		}
+	}
+	for extension, count := range extensions {
+		if count*2 > len(t.counts) {
+			return extension
+		}
+	}
+	return ""
+}
+
+// recordMassChange counts a file event and raises the alert the first time a burst of
+// changes reaches MassChange.Files.
+func (w *Watcher) recordMassChange(path string) {
+	w.mu.Lock()
+	config := w.MassChange
+	if !config.enabled() {
+		w.mu.Unlock()
+		return
+	}
+	files := w.massChanges.add(w.clock.Now(), filepath.Clean(path), config.window())
+	if files < config.Files || w.massChanges.alerted {
+		w.mu.Unlock()
+		return
+	}
+	w.massChanges.alerted = true
+	message := fmt.Sprintf("%d files changed within %s", files, config.window())
+	if extension := w.massChanges.commonExtension(); extension != "" {
+		message += fmt.Sprintf(", most of them now end in %s", extension)
+	}
+	w.massChange = message
+	source := w.Source
+	w.mu.Unlock()
+
+	w.recordEvent(HistoryEvent{Type: HistoryMassChange, Message: message})
+	w.notifyWarning("Possible ransomware in %s: %s. A backup is being made and old backups will not be deleted until retention is resumed", source, message)
+	w.requestUrgentBackup(BackupTriggerMassChange)
+}
+
+// RetentionPaused returns the mass change that paused retention, empty if retention is
+// not paused.
+func (w *Watcher) RetentionPaused() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.massChange
+}
+
+// ResumeRetention lets pruning delete backups again after a mass change, once the
+// source has been checked.
+func (w *Watcher) ResumeRetention() {
+	w.mu.Lock()
+	paused := w.massChange != ""
+	w.massChange = ""
+	w.mu.Unlock()
+
+	if paused {
+		Logf(w.Name, LogLevelInfo, "Retention resumed")
+		w.recordEvent(HistoryEvent{Type: HistoryRetentionResumed})
+	}
+}
+
+// unresolvedMassChange returns the message of the newest mass change in history that
+// retention was not resumed after, so the pause lasts across restarts.
+func unresolvedMassChange(history []HistoryEvent) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		switch history[i].Type {
+		case HistoryRetentionResumed:
+			return ""
+		case HistoryMassChange:
+			return history[i].Message
+		}
+	}
+	return ""
+}
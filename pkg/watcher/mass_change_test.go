@@ -0,0 +1,78 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMassChange(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	CreateDummyFile(t, WatcherConfig.Source, "file.txt", 10)
+	// The clock is never advanced so only the mass change makes a backup.
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(NewFakeClock(time.Now())))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.MassChange = MassChangeConfig{Files: 5}
+	watcher.Retention = RetentionPolicy{KeepLast: 1}
+	watcher.CreateBackup()
+	recorder := &warningRecorder{}
+	watcher.AddObserver(recorder)
+	observer := startBackupLoop(t, watcher)
+
+	// Changing the same file again does not count twice.
+	watcher.handleSourceEvent(filepath.Join(WatcherConfig.Source, "file.txt"), "WRITE")
+	watcher.handleSourceEvent(filepath.Join(WatcherConfig.Source, "file.txt"), "WRITE")
+	for i := range 6 {
+		watcher.handleSourceEvent(filepath.Join(WatcherConfig.Source, fmt.Sprintf("%d.txt.locked", i)), "CREATE")
+	}
+
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatal("Expected a backup to be made right away")
+	}
+	watcher.flushObservers()
+	if paused := watcher.RetentionPaused(); paused != "5 files changed within 10s, most of them now end in .locked" {
+		t.Errorf("Expected retention to be paused, got %q", paused)
+	}
+	if len(recorder.warnings) != 1 {
+		t.Errorf("Expected 1 warning, got %v", recorder.warnings)
+	}
+	if len(watcher.Metadata) != 2 || watcher.Metadata[1].Name != "Mass change" {
+		t.Errorf("Expected the backup from before the mass change to be kept, got %+v", watcher.Metadata)
+	}
+	if _, err := watcher.Prune(false); !errors.Is(err, ErrRetentionPaused) {
+		t.Errorf("Expected pruning to be paused, got %v", err)
+	}
+
+	// The pause is read back from the history.
+	reloaded, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to reload watcher: %v", err)
+	}
+	if reloaded.RetentionPaused() == "" {
+		t.Error("Expected retention to still be paused after reloading")
+	}
+	reloaded.ResumeRetention()
+	if _, err := reloaded.Prune(false); err != nil {
+		t.Errorf("Expected pruning to work once resumed, got %v", err)
+	}
+}
+
+func TestMassChangeTrackerWindow(t *testing.T) {
+	t.Parallel()
+	var tracker massChangeTracker
+	start := time.Now()
+	window := 10 * time.Second
+	tracker.add(start, "a", window)
+	tracker.add(start.Add(6*time.Second), "b", window)
+	if files := tracker.add(start.Add(12*time.Second), "c", window); files != 2 {
+		t.Errorf("Expected the first change to leave the window, got %d files", files)
+	}
+	if extension := tracker.commonExtension(); extension != "" {
+		t.Errorf("Expected no common extension, got %q", extension)
+	}
+}
@@ -0,0 +1,47 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"ryn-cx/i-saw-that/internal/filelock"
+)
+
+// Name of the file in the state folder that is locked while the metadata is read or
+// written, so several processes using the same destination, such as the daemon and a
+// prune from the command line, take turns.
+const metadataLockFileName = "metadata.lock"
+
+// lockMetadata locks the metadata of a destination, waiting for other processes to
+// finish with it. Readers share the lock and writers get it to themselves. The metadata
+// is always renamed into place so readers never see a half written file, but without
+// the lock two writers can replace each other's changes and on Windows a file that is
+// being read can not be replaced.
+func lockMetadata(destination string, exclusive bool) (unlock func(), err error) {
+	// Readers only open the lock file so they work on read-only destinations.
+	flag := os.O_RDONLY
+	if exclusive {
+		flag = os.O_RDWR | os.O_CREATE
+		if err := os.MkdirAll(stateDir(destination), 0755); err != nil {
+			return nil, fmt.Errorf("error locking metadata: %w", err)
+		}
+	}
+	file, err := os.OpenFile(statePath(destination, metadataLockFileName), flag, 0644)
+	if err != nil {
+		// The lock file is created by the first write, until then there is nothing to
+		// wait for.
+		if !exclusive && errors.Is(err, os.ErrNotExist) {
+			return func() {}, nil
+		}
+		return nil, fmt.Errorf("error locking metadata: %w", err)
+	}
+	if err := filelock.Lock(file, exclusive); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error locking metadata: %w", err)
+	}
+	return func() {
+		filelock.Unlock(file)
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,102 @@
+package watcher
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMetadataLock(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	watcher.CreateBackup()
+
+	// Readers do not wait for each other.
+	unlock, err := lockMetadata(WatcherConfig.Destination, false)
+	if err != nil {
+		t.Fatalf("Failed to lock metadata: %v", err)
+	}
+	if backups, err := loadDestinationMetadata(WatcherConfig.Destination); err != nil || len(backups) != 1 {
+		t.Errorf("Expected to read the metadata while it is shared, got %+v: %v", backups, err)
+	}
+
+	// Writers wait for the readers, as they would for another process.
+	saved := make(chan error, 1)
+	go func() {
+		saved <- watcher.saveMetadata(WatcherConfig.Destination)
+	}()
+	select {
+	case err := <-saved:
+		t.Fatalf("Expected the write to wait for the lock, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case err := <-saved:
+		if err != nil {
+			t.Errorf("Failed to save metadata: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the write to finish once the lock was released")
+	}
+}
+
+func TestMetadataSaveKeepsOtherProcessChanges(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	daemon, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	daemon.CreateBackup()
+
+	// Another process, such as the command line, loads the metadata and pins the
+	// backup while the daemon makes a new one.
+	cli, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 10)
+	daemon.CreateBackup()
+	if err := cli.PinBackup(cli.Metadata[0].Path, true); err != nil {
+		t.Fatalf("Failed to pin backup: %v", err)
+	}
+
+	backups, err := loadDestinationMetadata(WatcherConfig.Destination)
+	if err != nil {
+		t.Fatalf("Failed to load metadata: %v", err)
+	}
+	if len(backups) != 2 || !backups[0].Pinned {
+		t.Errorf("Expected the backup of the daemon and the pin to be kept, got %+v", backups)
+	}
+	if len(cli.ListBackups()) != 2 {
+		t.Errorf("Expected the watcher to pick up the backup made by the other process")
+	}
+
+	// The daemon keeps the pin when it saves its own changes.
+	if err := daemon.AnnotateBackup(daemon.Metadata[1].Path, "note"); err != nil {
+		t.Fatalf("Failed to annotate backup: %v", err)
+	}
+	if backups := daemon.ListBackups(); !backups[0].Pinned || backups[1].Note != "note" {
+		t.Errorf("Expected both changes to be kept, got %+v", backups)
+	}
+}
+
+func TestMetadataLockWithoutStateFolder(t *testing.T) {
+	t.Parallel()
+	destination := t.TempDir()
+	unlock, err := lockMetadata(destination, false)
+	if err != nil {
+		t.Fatalf("Expected reading a new destination to need no lock: %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(stateDir(destination)); !os.IsNotExist(err) {
+		t.Errorf("Expected reading to leave the destination untouched, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// migrateFolderFormats records the folder format of backups created before the format
+// was saved in the metadata. Backups always keep the path they were created with, so
+// changing the folder format does not orphan older backups, but the format is needed
+// to read the time back out of a folder name.
+func (w *Watcher) migrateFolderFormats() error {
+	candidates := []string{w.FolderFormat, DefaultFolderFormat}
+	changed := map[string]bool{}
+	previousFormats := map[string]bool{}
+
+	for i, backup := range w.Metadata {
+		if backup.FolderFormat == "" {
+			format, ok := detectFolderFormat(backup, candidates)
+			if !ok {
+				Logf(w.Name, LogLevelWarn, "Could not detect the folder format of backup %s", backup.Path)
+				continue
+			}
+			w.Metadata[i].FolderFormat = format
+			changed[backup.Destination] = true
+		}
+		if format := w.Metadata[i].FolderFormat; format != w.FolderFormat {
+			previousFormats[format] = true
+		}
+	}
+
+	for format := range previousFormats {
+		Logf(w.Name, LogLevelInfo, "Folder format changed from %s to %s, existing backups keep their paths", format, w.FolderFormat)
+	}
+
+	var errs error
+	for destination := range changed {
+		if err := w.saveMetadata(destination); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error saving migrated metadata: %w", err))
+		}
+	}
+	return errs
+}
+
+// detectFolderFormat returns the first format that produces the backup's path from its
+// timestamp.
+func detectFolderFormat(backup Backup, formats []string) (string, bool) {
+	created := BackupTime(backup)
+	for _, format := range formats {
+		parsed, err := time.ParseInLocation(format, backup.Path, time.Local)
+		if err != nil || parsed.Format(format) != backup.Path {
+			continue
+		}
+		// Timestamps are stored as floats so they are only compared to the second.
+		if difference := parsed.Sub(created).Abs(); difference < time.Second {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// migrateSequences numbers backups created before sequence numbers were added, in the
+// order they were made.
+func (w *Watcher) migrateSequences() error {
+	changed := map[string]bool{}
+	for i, backup := range w.Metadata {
+		if backup.Sequence != 0 {
+			continue
+		}
+		sequence, err := w.nextSequence()
+		if err != nil {
+			return fmt.Errorf("error numbering backups: %w", err)
+		}
+		w.Metadata[i].Sequence = sequence
+		changed[backup.Destination] = true
+	}
+
+	var errs error
+	for destination := range changed {
+		if err := w.saveMetadata(destination); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error saving migrated metadata: %w", err))
+		}
+	}
+	return errs
+}
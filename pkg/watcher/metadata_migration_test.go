@@ -0,0 +1,103 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestChangingFolderFormat(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+
+	// Simulate metadata written before the folder format was recorded.
+	legacy := watcher.Metadata[0]
+	legacy.FolderFormat = ""
+	data, err := json.Marshal([]Backup{legacy})
+	if err != nil {
+		t.Fatalf("Failed to marshal metadata: %v", err)
+	}
+	if err := os.WriteFile(metadataJSONPath(WatcherConfig.Destination), data, 0644); err != nil {
+		t.Fatalf("Failed to write metadata: %v", err)
+	}
+
+	WatcherConfig.FolderFormat = "2006/01/02_15-04-05.000000"
+	watcher, err = NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if format := watcher.Metadata[0].FolderFormat; format != DefaultFolderFormat {
+		t.Errorf("Expected migrated folder format '%s', got '%s'", DefaultFolderFormat, format)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 1024)
+	watcher.CreateBackup()
+
+	backups := watcher.ListBackups()
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %d", len(backups))
+	}
+	for _, backup := range backups {
+		if _, err := os.Stat(watcher.BackupPath(backup)); err != nil {
+			t.Errorf("Expected backup %s to exist: %v", backup.Path, err)
+		}
+	}
+	if backups[1].FolderFormat != WatcherConfig.FolderFormat {
+		t.Errorf("Expected folder format '%s', got '%s'", WatcherConfig.FolderFormat, backups[1].FolderFormat)
+	}
+
+	// Both layouts in the same destination should not be reported as unknown folders.
+	recorder := &warningRecorder{}
+	watcher.AddObserver(recorder)
+	watcher.reconcileMetadata()
+	watcher.flushObservers()
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", recorder.warnings)
+	}
+}
+
+func TestInvalidFolderFormatIsNotMigrated(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+
+	// Simulate metadata written before the folder format was recorded.
+	legacy := watcher.Metadata[0]
+	legacy.FolderFormat = ""
+	data, err := json.Marshal([]Backup{legacy})
+	if err != nil {
+		t.Fatalf("Failed to marshal metadata: %v", err)
+	}
+	if err := os.WriteFile(metadataJSONPath(WatcherConfig.Destination), data, 0644); err != nil {
+		t.Fatalf("Failed to write metadata: %v", err)
+	}
+
+	WatcherConfig.FolderFormat = DefaultFolderFormat + "/.."
+	if _, err := NewTempWatcher(WatcherConfig); !errors.Is(err, ErrorInvalidFolderFormat) {
+		t.Fatalf("Expected an invalid folder format, got %v", err)
+	}
+	saved, err := os.ReadFile(metadataJSONPath(WatcherConfig.Destination))
+	if err != nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	if !bytes.Equal(saved, data) {
+		t.Errorf("Expected the metadata to be left alone, got %s", saved)
+	}
+}
@@ -0,0 +1,72 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// metadataVersion is the format of the metadata files written by this version. It is
+// only raised for changes that older versions can not read or would lose data by
+// rewriting, fields they do not know are otherwise ignored.
+const metadataVersion = 2
+
+// ErrMetadataTooNew is returned for metadata in a format that only a newer version of
+// the app can read, so it is not overwritten after a downgrade.
+var ErrMetadataTooNew = errors.New("metadata was written by a newer version of i-saw-that")
+
+// metadataFile is the layout of a metadata file.
+type metadataFile struct {
+	Version int      `json:"version"`
+	Backups []Backup `json:"backups"`
+}
+
+// metadataMigrations convert metadata from the version one above their index to the
+// next version, so there is one for every version before metadataVersion.
+var metadataMigrations = []func(data []byte) ([]byte, error){
+	// Version 1 was a bare list of backups.
+	func(data []byte) ([]byte, error) {
+		return json.Marshal(struct {
+			Version int             `json:"version"`
+			Backups json.RawMessage `json:"backups"`
+		}{2, data})
+	},
+}
+
+// decodeMetadata parses a metadata file of any version up to metadataVersion.
+func decodeMetadata(data []byte) ([]Backup, error) {
+	version := 1
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '[' {
+		var header struct {
+			Version int `json:"version"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return nil, err
+		}
+		version = header.Version
+	}
+	switch {
+	case version < 1:
+		return nil, fmt.Errorf("metadata has no valid version: %d", version)
+	case version > metadataVersion:
+		return nil, fmt.Errorf("%w: the metadata is in format %d and this version reads up to format %d, update i-saw-that to use this destination", ErrMetadataTooNew, version, metadataVersion)
+	}
+
+	for i, migrate := range metadataMigrations[version-1:] {
+		var err error
+		if data, err = migrate(data); err != nil {
+			return nil, fmt.Errorf("error migrating metadata from format %d: %w", version+i, err)
+		}
+	}
+	var file metadataFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return file.Backups, nil
+}
+
+// encodeMetadata returns the contents of a metadata file in the current format.
+func encodeMetadata(backups []Backup) ([]byte, error) {
+	return json.MarshalIndent(metadataFile{Version: metadataVersion, Backups: backups}, "", "  ")
+}
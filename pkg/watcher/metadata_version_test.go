@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestMetadataVersions(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+	path := metadataJSONPath(WatcherConfig.Destination)
+
+	var file struct {
+		Version int
+		Backups []json.RawMessage
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != metadataVersion || len(file.Backups) != 1 {
+		t.Fatalf("Expected version %d with 1 backup, got %+v: %v", metadataVersion, file, err)
+	}
+
+	// Metadata written before the version was recorded is a bare list.
+	legacy, err := json.Marshal(watcher.Metadata)
+	if err != nil {
+		t.Fatalf("Failed to marshal metadata: %v", err)
+	}
+	if err := os.WriteFile(path, legacy, 0644); err != nil {
+		t.Fatalf("Failed to write metadata: %v", err)
+	}
+	if watcher, err = NewTempWatcher(WatcherConfig); err != nil || len(watcher.Metadata) != 1 {
+		t.Fatalf("Expected legacy metadata to be read, got %d backups: %v", len(watcher.Metadata), err)
+	}
+
+	// Fields added by a newer version in the same format are ignored.
+	added := bytes.Replace(data, []byte(`"path"`), []byte(`"added_later": {"a": 1}, "path"`), 1)
+	if err := os.WriteFile(path, added, 0644); err != nil {
+		t.Fatalf("Failed to write metadata: %v", err)
+	}
+	if watcher, err = NewTempWatcher(WatcherConfig); err != nil || len(watcher.Metadata) != 1 {
+		t.Fatalf("Expected unknown fields to be ignored, got %d backups: %v", len(watcher.Metadata), err)
+	}
+
+	// A format this version does not know is refused and left alone.
+	newer := []byte(`{"version": 99, "backups": {"moved": true}}`)
+	if err := os.WriteFile(path, newer, 0644); err != nil {
+		t.Fatalf("Failed to write metadata: %v", err)
+	}
+	if _, err := NewTempWatcher(WatcherConfig); !errors.Is(err, ErrMetadataTooNew) {
+		t.Errorf("Expected newer metadata to be refused, got %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, newer) {
+		t.Errorf("Expected newer metadata to be left alone, got %s: %v", data, err)
+	}
+}
+
+func TestDecodeMetadataRejectsMissingVersion(t *testing.T) {
+	t.Parallel()
+	if _, err := decodeMetadata([]byte(`{"backups": []}`)); err == nil {
+		t.Error("Expected metadata without a version to be rejected")
+	}
+	if len(metadataMigrations) != metadataVersion-1 {
+		t.Errorf("Expected a migration for each of the %d older versions, got %d", metadataVersion-1, len(metadataMigrations))
+	}
+}
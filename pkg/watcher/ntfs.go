@@ -0,0 +1,16 @@
+package watcher
+
+// NTFSPreservation selects which Windows specific file metadata is copied into
+// backups. The copy engines only preserve file contents and modification times.
+type NTFSPreservation struct {
+	// Hidden, system, archive and not content indexed attributes.
+	Attributes bool `json:"attributes,omitempty" yaml:"attributes,omitempty" toml:"attributes,omitempty"`
+	// Access control lists.
+	ACLs bool `json:"acls,omitempty" yaml:"acls,omitempty" toml:"acls,omitempty"`
+	// Alternate data streams, such as the Zone.Identifier stream added to downloads.
+	AlternateStreams bool `json:"alternate_streams,omitempty" yaml:"alternate_streams,omitempty" toml:"alternate_streams,omitempty"`
+}
+
+func (p NTFSPreservation) Enabled() bool {
+	return p.Attributes || p.ACLs || p.AlternateStreams
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package watcher
+
+// preserveNTFSMetadata does nothing outside of Windows since other file systems do
+// not have the same metadata.
+func preserveNTFSMetadata(source, destination string, preservation NTFSPreservation) error {
+	return nil
+}
@@ -0,0 +1,215 @@
+//go:build windows
+
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Attributes that are copied, read-only is left out because it is handled by the
+// read-only backup option.
+const preservedAttributes = windows.FILE_ATTRIBUTE_HIDDEN |
+	windows.FILE_ATTRIBUTE_SYSTEM |
+	windows.FILE_ATTRIBUTE_ARCHIVE |
+	windows.FILE_ATTRIBUTE_NOT_CONTENT_INDEXED
+
+var (
+	kernel32             = windows.NewLazySystemDLL("kernel32.dll")
+	procFindFirstStreamW = kernel32.NewProc("FindFirstStreamW")
+	procFindNextStreamW  = kernel32.NewProc("FindNextStreamW")
+)
+
+// WIN32_FIND_STREAM_DATA
+type win32FindStreamData struct {
+	StreamSize int64
+	StreamName [windows.MAX_PATH + 36]uint16
+}
+
+// preserveNTFSMetadata copies the selected NTFS metadata from every entry in source to
+// the matching entry in destination. The backup must already contain every file.
+func preserveNTFSMetadata(source, destination string, preservation NTFSPreservation) error {
+	paths, err := collectPaths(source)
+	if err != nil {
+		return err
+	}
+
+	// Children are handled before their parents so writing streams does not change
+	// the modification time of a directory after it has been restored.
+	slices.Reverse(paths)
+
+	var errs error
+	for _, sourcePath := range paths {
+		relPath, err := filepath.Rel(source, sourcePath)
+		if err != nil {
+			return err
+		}
+		destinationPath := filepath.Join(destination, relPath)
+		if err := preserveEntry(sourcePath, destinationPath, preservation); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", relPath, err))
+		}
+	}
+	return errs
+}
+
+func preserveEntry(sourcePath, destinationPath string, preservation NTFSPreservation) error {
+	info, err := os.Lstat(sourcePath)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return nil
+	}
+
+	if preservation.AlternateStreams {
+		if err := copyAlternateStreams(sourcePath, destinationPath); err != nil {
+			return fmt.Errorf("error copying alternate data streams: %w", err)
+		}
+		// Writing a stream updates the modification time of the file.
+		if err := os.Chtimes(destinationPath, info.ModTime(), info.ModTime()); err != nil {
+			return err
+		}
+	}
+
+	if preservation.ACLs {
+		if err := copyDACL(sourcePath, destinationPath); err != nil {
+			return fmt.Errorf("error copying ACL: %w", err)
+		}
+	}
+
+	// Attributes are set last because a hidden or system file can block other changes.
+	if preservation.Attributes {
+		if err := copyAttributes(sourcePath, destinationPath); err != nil {
+			return fmt.Errorf("error copying attributes: %w", err)
+		}
+	}
+	return nil
+}
+
+func copyAttributes(sourcePath, destinationPath string) error {
+	sourcePtr, err := windows.UTF16PtrFromString(sourcePath)
+	if err != nil {
+		return err
+	}
+	destinationPtr, err := windows.UTF16PtrFromString(destinationPath)
+	if err != nil {
+		return err
+	}
+
+	sourceAttributes, err := windows.GetFileAttributes(sourcePtr)
+	if err != nil {
+		return err
+	}
+	destinationAttributes, err := windows.GetFileAttributes(destinationPtr)
+	if err != nil {
+		return err
+	}
+
+	attributes := destinationAttributes&^preservedAttributes | sourceAttributes&preservedAttributes
+	if attributes == destinationAttributes {
+		return nil
+	}
+	return windows.SetFileAttributes(destinationPtr, attributes)
+}
+
+func copyDACL(sourcePath, destinationPath string) error {
+	sd, err := windows.GetNamedSecurityInfo(sourcePath, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	control, _, err := sd.Control()
+	if err != nil {
+		return err
+	}
+
+	// Keep inheritance the same as the source, otherwise the destination folder's
+	// permissions would be merged into the copied ACL.
+	securityInformation := windows.SECURITY_INFORMATION(windows.DACL_SECURITY_INFORMATION)
+	if control&windows.SE_DACL_PROTECTED != 0 {
+		securityInformation |= windows.PROTECTED_DACL_SECURITY_INFORMATION
+	} else {
+		securityInformation |= windows.UNPROTECTED_DACL_SECURITY_INFORMATION
+	}
+	return windows.SetNamedSecurityInfo(destinationPath, windows.SE_FILE_OBJECT, securityInformation, nil, nil, dacl, nil)
+}
+
+func copyAlternateStreams(sourcePath, destinationPath string) error {
+	streams, err := alternateStreams(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	for _, stream := range streams {
+		if err := copyFile(sourcePath+":"+stream, destinationPath+":"+stream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alternateStreams returns the names of the named data streams of a file, the unnamed
+// default stream is not included.
+func alternateStreams(path string) ([]string, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data win32FindStreamData
+	handle, _, err := procFindFirstStreamW.Call(uintptr(unsafe.Pointer(pathPtr)), 0, uintptr(unsafe.Pointer(&data)), 0)
+	if windows.Handle(handle) == windows.InvalidHandle {
+		if errors.Is(err, windows.ERROR_HANDLE_EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer windows.FindClose(windows.Handle(handle))
+
+	streams := []string{}
+	for {
+		// Stream names have the format ":name:$DATA", the default stream is "::$DATA".
+		name := strings.TrimSuffix(strings.TrimPrefix(windows.UTF16ToString(data.StreamName[:]), ":"), ":$DATA")
+		if name != "" {
+			streams = append(streams, name)
+		}
+
+		ok, _, err := procFindNextStreamW.Call(handle, uintptr(unsafe.Pointer(&data)))
+		if ok == 0 {
+			if errors.Is(err, windows.ERROR_HANDLE_EOF) {
+				return streams, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+func copyFile(sourcePath, destinationPath string) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := os.Create(destinationPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destination, source); err != nil {
+		destination.Close()
+		return err
+	}
+	return destination.Close()
+}
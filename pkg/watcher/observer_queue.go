@@ -0,0 +1,161 @@
+package watcher
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Number of notifications that can be waiting for observers when no size is set.
+const defaultObserverQueueSize = 64
+
+// ObserverQueuePolicy decides what happens when the observer queue is full.
+type ObserverQueuePolicy string
+
+const (
+	// Wait for the observers to catch up, this delays the next backup.
+	ObserverQueueBlock ObserverQueuePolicy = "block"
+	// Drop the notification and log a warning.
+	ObserverQueueDrop ObserverQueuePolicy = "drop"
+)
+
+// ObserverQueueConfig controls how notifications are passed to observers. Observers are
+// called in order on a separate goroutine so a slow observer does not delay backups.
+type ObserverQueueConfig struct {
+	// Maximum number of notifications waiting for observers, defaults to 64.
+	Size int `json:"size,omitempty" yaml:"size,omitempty" toml:"size,omitempty"`
+	// What to do when the queue is full, "block" or "drop". Defaults to "block".
+	Policy ObserverQueuePolicy `json:"policy,omitempty" yaml:"policy,omitempty" toml:"policy,omitempty"`
+}
+
+func (c ObserverQueueConfig) validate() error {
+	if c.Size < 0 {
+		return fmt.Errorf("observer queue size must not be negative")
+	}
+	switch c.Policy {
+	case "", ObserverQueueBlock, ObserverQueueDrop:
+		return nil
+	}
+	return fmt.Errorf("unknown observer queue policy: %s", c.Policy)
+}
+
+// observerQueue runs notifications one at a time in the order they were queued. The
+// worker goroutine is only running while there are notifications to deliver so a
+// watcher that is no longer used does not leak it.
+type observerQueue struct {
+	notifications chan func()
+	policy        ObserverQueuePolicy
+
+	mu      sync.Mutex
+	running bool
+	// Counts notifications that have been queued but not delivered yet, drained is
+	// signaled when it reaches zero. A WaitGroup can not be used since notifications
+	// are queued while flush is waiting.
+	pending int
+	drained *sync.Cond
+}
+
+func newObserverQueue(config ObserverQueueConfig) *observerQueue {
+	size := config.Size
+	if size == 0 {
+		size = defaultObserverQueueSize
+	}
+	q := &observerQueue{
+		notifications: make(chan func(), size),
+		policy:        config.Policy,
+	}
+	q.drained = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues a notification, false is returned if it was dropped because the queue is
+// full.
+func (q *observerQueue) push(notification func()) bool {
+	q.mu.Lock()
+	q.pending++
+	q.mu.Unlock()
+	if q.policy == ObserverQueueDrop {
+		select {
+		case q.notifications <- notification:
+		default:
+			q.done()
+			return false
+		}
+	} else {
+		q.notifications <- notification
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.running {
+		q.running = true
+		go q.work()
+	}
+	return true
+}
+
+func (q *observerQueue) work() {
+	for {
+		select {
+		case notification := <-q.notifications:
+			notification()
+			q.done()
+			continue
+		default:
+		}
+
+		// A notification queued after the check above starts a new worker once running
+		// is false.
+		q.mu.Lock()
+		if len(q.notifications) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+	}
+}
+
+// done records that a queued notification was delivered or dropped.
+func (q *observerQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending--
+	if q.pending == 0 {
+		q.drained.Broadcast()
+	}
+}
+
+// flush waits for every queued notification to be delivered.
+func (q *observerQueue) flush() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.pending > 0 {
+		q.drained.Wait()
+	}
+}
+
+// dispatch queues a call to an observer. Observers must not wait for the watcher to
+// create a backup since that can block on a full queue.
+func (w *Watcher) dispatch(call func()) {
+	w.mu.Lock()
+	if w.observerQueue == nil {
+		w.observerQueue = newObserverQueue(w.ObserverQueue)
+	}
+	queue := w.observerQueue
+	w.mu.Unlock()
+
+	if !queue.push(func() { w.callObserver(call) }) {
+		Logf(w.Name, LogLevelWarn, "Observer queue is full, a notification was dropped")
+	}
+}
+
+// flushObservers waits for every notification that has been queued to be delivered.
+func (w *Watcher) flushObservers() {
+	w.mu.Lock()
+	queue := w.observerQueue
+	w.mu.Unlock()
+
+	if queue != nil {
+		queue.flush()
+	}
+}
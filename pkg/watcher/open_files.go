@@ -0,0 +1,119 @@
+package watcher
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OpenFilesPolicy decides what an automatic backup does when files in the source are
+// open in other programs, which may be in the middle of writing them.
+type OpenFilesPolicy string
+
+const (
+	// Back up the files anyway and show a warning.
+	OpenFilesWarn OpenFilesPolicy = "warn"
+	// Defer the backup until the files are closed, up to MaxWait.
+	OpenFilesWait OpenFilesPolicy = "wait"
+	// Skip the backup, the next change starts a new one.
+	OpenFilesSkip OpenFilesPolicy = "skip"
+)
+
+// How long the wait policy defers a backup when MaxWait is not set.
+const defaultOpenFilesMaxWait = time.Hour
+
+// OpenFilesConfig checks whether files in the source are open before automatic
+// backups. Open files are detected with the Restart Manager on Windows and /proc on
+// Linux, other platforms never find open files.
+type OpenFilesConfig struct {
+	Policy OpenFilesPolicy `json:"policy,omitempty" yaml:"policy,omitempty" toml:"policy,omitempty"`
+	// How long the wait policy defers a backup before backing up the open files anyway.
+	MaxWait Duration `json:"max_wait,omitempty" yaml:"max_wait,omitempty" toml:"max_wait,omitempty"`
+}
+
+func (c OpenFilesConfig) enabled() bool {
+	return c.Policy != ""
+}
+
+func (c OpenFilesConfig) validate() error {
+	switch c.Policy {
+	case "", OpenFilesWarn, OpenFilesWait, OpenFilesSkip:
+	default:
+		return fmt.Errorf("unknown open files policy: %s", c.Policy)
+	}
+	if c.MaxWait < 0 {
+		return fmt.Errorf("open files max wait must be at least 0 seconds")
+	}
+	return nil
+}
+
+func (c OpenFilesConfig) maxWait() time.Duration {
+	if c.MaxWait == 0 {
+		return defaultOpenFilesMaxWait
+	}
+	return time.Duration(c.MaxWait)
+}
+
+// sourceFiles returns every regular file in the source.
+func sourceFiles(root string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// formatProcesses formats the names of the programs holding files open.
+func formatProcesses(processes []string) string {
+	processes = slices.Clone(processes)
+	slices.Sort(processes)
+	return strings.Join(slices.Compact(processes), ", ")
+}
+
+// checkOpenFiles applies the open files policy before an automatic backup. It returns
+// false if the backup should not be made now, either because it was deferred or
+// skipped. openSince tracks how long the wait policy has been waiting.
+func (w *Watcher) checkOpenFiles(config OpenFilesConfig, openSince *time.Time, deferBackup func(time.Duration, string)) bool {
+	w.mu.Lock()
+	source := w.Source
+	w.mu.Unlock()
+
+	processes, err := w.openFileProcesses(source)
+	if err != nil {
+		Logf(w.Name, LogLevelWarn, "Error checking for open files: %v", err)
+		return true
+	}
+	if len(processes) == 0 {
+		return true
+	}
+	names := formatProcesses(processes)
+
+	switch config.Policy {
+	case OpenFilesWait:
+		if openSince.IsZero() {
+			*openSince = w.clock.Now()
+		}
+		if w.clock.Now().Sub(*openSince) < config.maxWait() {
+			deferBackup(w.deferPollInterval, fmt.Sprintf("Files are open in %s", names))
+			return false
+		}
+		w.notifyWarning("Files are still open in %s after %s, backing them up anyway", names, config.maxWait())
+	case OpenFilesSkip:
+		w.setDeferred("")
+		w.notifyWarning("Files are open in %s, skipping the backup", names)
+		w.recordEvent(HistoryEvent{Type: HistoryBackupSkipped, Message: fmt.Sprintf("Files are open in %s", names)})
+		return false
+	default:
+		w.notifyWarning("Files are open in %s, the backup may contain partially written files", names)
+	}
+	return true
+}
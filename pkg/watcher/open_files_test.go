@@ -0,0 +1,174 @@
+package watcher
+
+import (
+	"bufio"
+	"os/exec"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeOpenFiles replaces the open file detection of a watcher, it returns a function
+// that sets the programs reported as having files open.
+func fakeOpenFiles(watcher *Watcher) func(processes ...string) {
+	var mu sync.Mutex
+	var open []string
+	watcher.openFileProcesses = func(root string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return open, nil
+	}
+	return func(processes ...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		open = processes
+	}
+}
+
+func TestBackupWaitsForOpenFiles(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 100 * time.Millisecond
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	setOpen := fakeOpenFiles(watcher)
+	setOpen("game.exe")
+	watcher.deferPollInterval = time.Second
+	watcher.OpenFiles = OpenFilesConfig{Policy: OpenFilesWait}
+	observer := startBackupLoop(t, watcher)
+
+	// The backup is deferred once the change settles and again while the files are
+	// still open.
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+	clock.BlockUntil(1)
+	clock.Advance(WatcherConfig.WaitTime)
+	clock.BlockUntil(1)
+	clock.Advance(watcher.deferPollInterval)
+	clock.BlockUntil(1)
+	watcher.flushObservers()
+	if count := observer.getCurrentCount(); count != 0 {
+		t.Fatalf("Expected no backups while files are open, got %d", count)
+	}
+	if deferred := watcher.Status().Deferred; deferred != "Files are open in game.exe" {
+		t.Errorf("Expected the status to name the program, got '%s'", deferred)
+	}
+
+	setOpen()
+	clock.Advance(watcher.deferPollInterval)
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected a backup once the files are closed")
+	}
+}
+
+func TestBackupWaitsForOpenFilesUpToMaxWait(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 100 * time.Millisecond
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	fakeOpenFiles(watcher)("game.exe")
+	watcher.deferPollInterval = time.Second
+	watcher.OpenFiles = OpenFilesConfig{Policy: OpenFilesWait, MaxWait: Duration(3 * time.Second)}
+	recorder := &warningRecorder{}
+	watcher.AddObserver(recorder)
+	observer := startBackupLoop(t, watcher)
+
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+	clock.BlockUntil(1)
+	clock.Advance(WatcherConfig.WaitTime)
+	for range 3 {
+		clock.BlockUntil(1)
+		clock.Advance(watcher.deferPollInterval)
+	}
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected a backup once the max wait has passed")
+	}
+	watcher.flushObservers()
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.warnings) != 1 {
+		t.Errorf("Expected a warning about backing up open files, got %v", recorder.warnings)
+	}
+}
+
+func TestBackupSkippedForOpenFiles(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 100 * time.Millisecond
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	setOpen := fakeOpenFiles(watcher)
+	setOpen("game.exe")
+	watcher.OpenFiles = OpenFilesConfig{Policy: OpenFilesSkip}
+	observer := startBackupLoop(t, watcher)
+
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+	clock.BlockUntil(1)
+	clock.Advance(WatcherConfig.WaitTime)
+	waitUntil(t, "Expected a skipped event in the history", func() bool {
+		history := watcher.History()
+		return len(history) > 0 && history[len(history)-1].Type == HistoryBackupSkipped
+	})
+	watcher.flushObservers()
+	if count := observer.getCurrentCount(); count != 0 {
+		t.Fatalf("Expected the backup to be skipped, got %d", count)
+	}
+
+	// Closing the files does not back up the skipped changes, the next change does.
+	setOpen()
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+	clock.BlockUntil(1)
+	clock.Advance(WatcherConfig.WaitTime)
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected the next change to be backed up")
+	}
+}
+
+func TestOpenFileProcesses(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS != "linux" {
+		t.Skip("Only uses a shell to hold files open on Linux")
+	}
+	tempPath := t.TempDir()
+	CreateDummyFile(t, tempPath, "save.db", 10)
+
+	// The test process itself is ignored, so another process holds the file open.
+	cmd := exec.Command("sh", "-c", "exec 3<save.db; echo ready; exec sleep 10")
+	cmd.Dir = tempPath
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatalf("Failed to wait for process: %v", err)
+	}
+
+	processes, err := openFileProcesses(tempPath)
+	if err != nil {
+		t.Fatalf("Failed to find open files: %v", err)
+	}
+	if len(processes) != 1 {
+		t.Errorf("Expected the shell to have a file open, got %v", processes)
+	}
+}
@@ -0,0 +1,89 @@
+//go:build windows
+
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	rstrtmgr                = windows.NewLazySystemDLL("rstrtmgr.dll")
+	procRmStartSession      = rstrtmgr.NewProc("RmStartSession")
+	procRmRegisterResources = rstrtmgr.NewProc("RmRegisterResources")
+	procRmGetList           = rstrtmgr.NewProc("RmGetList")
+	procRmEndSession        = rstrtmgr.NewProc("RmEndSession")
+)
+
+const (
+	cchRmSessionKey = 32
+	cchRmMaxAppName = 255
+	cchRmMaxSvcName = 63
+)
+
+// rmProcessInfo is RM_PROCESS_INFO.
+type rmProcessInfo struct {
+	ProcessID        uint32
+	ProcessStartTime windows.Filetime
+	AppName          [cchRmMaxAppName + 1]uint16
+	ServiceShortName [cchRmMaxSvcName + 1]uint16
+	ApplicationType  uint32
+	AppStatus        uint32
+	TSSessionID      uint32
+	Restartable      int32
+}
+
+// openFileProcesses asks the Restart Manager which processes other than this one have
+// files inside root open.
+func openFileProcesses(root string) ([]string, error) {
+	files, err := sourceFiles(root)
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	names := make([]*uint16, 0, len(files))
+	for _, file := range files {
+		name, err := windows.UTF16PtrFromString(file)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	var session uint32
+	var key [cchRmSessionKey + 1]uint16
+	if ret, _, _ := procRmStartSession.Call(uintptr(unsafe.Pointer(&session)), 0, uintptr(unsafe.Pointer(&key[0]))); ret != 0 {
+		return nil, fmt.Errorf("RmStartSession: %w", windows.Errno(ret))
+	}
+	defer procRmEndSession.Call(uintptr(session))
+
+	if ret, _, _ := procRmRegisterResources.Call(uintptr(session), uintptr(len(names)), uintptr(unsafe.Pointer(&names[0])), 0, 0, 0, 0); ret != 0 {
+		return nil, fmt.Errorf("RmRegisterResources: %w", windows.Errno(ret))
+	}
+
+	// The list can grow between calls, so ask again until it fits.
+	infos := make([]rmProcessInfo, 8)
+	for {
+		var needed, count uint32 = 0, uint32(len(infos))
+		var reasons uint32
+		ret, _, _ := procRmGetList.Call(uintptr(session), uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&count)), uintptr(unsafe.Pointer(&infos[0])), uintptr(unsafe.Pointer(&reasons)))
+		if windows.Errno(ret) == windows.ERROR_MORE_DATA {
+			infos = make([]rmProcessInfo, needed)
+			continue
+		}
+		if ret != 0 {
+			return nil, fmt.Errorf("RmGetList: %w", windows.Errno(ret))
+		}
+
+		processes := []string{}
+		for _, info := range infos[:count] {
+			if int(info.ProcessID) == os.Getpid() {
+				continue
+			}
+			processes = append(processes, windows.UTF16ToString(info.AppName[:]))
+		}
+		return processes, nil
+	}
+}
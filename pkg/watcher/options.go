@@ -0,0 +1,104 @@
+package watcher
+
+import "context"
+
+// Option configures a watcher created by NewWatcher.
+type Option func(*Watcher)
+
+// WithObserver notifies observer of every completed backup.
+func WithObserver(observer BackupCompleteObserver) Option {
+	return func(w *Watcher) {
+		w.AddObserver(observer)
+	}
+}
+
+// WithExcludedPaths ignores file events inside paths, see SetExcludedPaths.
+func WithExcludedPaths(paths []string) Option {
+	return func(w *Watcher) {
+		w.SetExcludedPaths(paths)
+	}
+}
+
+// WithPreBackupHook runs hook before every backup.
+func WithPreBackupHook(hook PreBackupHook) Option {
+	return func(w *Watcher) {
+		w.AddPreBackupHook(hook)
+	}
+}
+
+// WithPostBackupHook runs hook after every completed backup.
+func WithPostBackupHook(hook PostBackupHook) Option {
+	return func(w *Watcher) {
+		w.AddPostBackupHook(hook)
+	}
+}
+
+// WithFileFilter leaves the files rejected by filter out of backups.
+func WithFileFilter(filter FileFilter) Option {
+	return func(w *Watcher) {
+		w.AddFileFilter(filter)
+	}
+}
+
+// WithBackupTransformer changes the contents of files as they are backed up.
+func WithBackupTransformer(transformer BackupTransformer) Option {
+	return func(w *Watcher) {
+		w.AddBackupTransformer(transformer)
+	}
+}
+
+// WithBackupLimiter makes every backup wait for limiter before it starts copying.
+func WithBackupLimiter(limiter BackupLimiter) Option {
+	return func(w *Watcher) {
+		w.limiter = limiter
+	}
+}
+
+// WithKeychain reads the credentials of network shares and remotes and the secrets
+// settings refer to from keychain instead of the keychain of the OS.
+func WithKeychain(keychain Keychain) Option {
+	return func(w *Watcher) {
+		w.keychain = keychain
+	}
+}
+
+// WithCrashDumpDir writes a crash dump to dir when a thread of the watcher panics.
+func WithCrashDumpDir(dir string) Option {
+	return func(w *Watcher) {
+		w.crashDumpDir = dir
+	}
+}
+
+// WithKeyring signs backups with the key of keyring and checks their signatures
+// against its trusted keys.
+func WithKeyring(keyring *Keyring) Option {
+	return func(w *Watcher) {
+		w.keyring = keyring
+	}
+}
+
+// WithClock times backups and the debounce with clock instead of the time of the
+// computer, a FakeClock makes the backup loop wait until it is advanced.
+func WithClock(clock Clock) Option {
+	return func(w *Watcher) {
+		w.clock = clock
+	}
+}
+
+// WithFS reads changed files and removes backups through fsys.
+func WithFS(fsys FS) Option {
+	return func(w *Watcher) {
+		w.fs = fsys
+	}
+}
+
+// Run starts the watcher and backs up changes until ctx is done, then shuts it down
+// the same way as Shutdown.
+func (w *Watcher) Run(ctx context.Context) error {
+	if err := w.StartWatcher(); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	_, err := w.Shutdown()
+	return err
+}
@@ -0,0 +1,43 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunWithOptions(t *testing.T) {
+	t.Parallel()
+	temp := DefaultTempWatcherConfig(t)
+	CreateDummyFile(t, temp.Source, "file1.txt", 10)
+	observer := NewSimplifiedObserver()
+	w, err := NewWatcher(WatcherConfig{
+		ID:           temp.Name,
+		Source:       temp.Source,
+		Destination:  temp.Destination,
+		WaitTime:     Duration(100 * time.Millisecond),
+		FolderFormat: DefaultFolderFormat,
+	}, WithObserver(observer))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected the initial backup to notify the observer")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected the watcher to shut down cleanly, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected Run to return once the context is done")
+	}
+	if w.Status().Running {
+		t.Errorf("Expected the watcher to be stopped")
+	}
+}
@@ -0,0 +1,118 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// PairConflictKind is how a folder pair overlaps with another folder pair.
+type PairConflictKind string
+
+const (
+	// Both pairs back up the same source, every change is copied twice.
+	PairConflictSameSource PairConflictKind = "same-source"
+	// The source contains the source of the other pair.
+	PairConflictSourceContains PairConflictKind = "source-contains"
+	// The source is inside the source of the other pair.
+	PairConflictSourceInside PairConflictKind = "source-inside"
+	// Both pairs store backups in the same destination and would overwrite each
+	// other's metadata.
+	PairConflictSharedDestination PairConflictKind = "shared-destination"
+	// The destination of one pair is inside the source of the other, so each backup
+	// of one triggers a backup of the other.
+	PairConflictDestinationInSource PairConflictKind = "destination-in-source"
+)
+
+// PairConflict describes an overlap between a folder pair and an existing folder pair.
+type PairConflict struct {
+	Kind PairConflictKind `json:"kind"`
+	// ID of the existing folder pair.
+	Pair string `json:"pair"`
+	// True if the folder pair can not be saved, other conflicts are warnings.
+	Blocking bool   `json:"blocking"`
+	Message  string `json:"message"`
+}
+
+// PairConflictError is returned when a folder pair can not be saved because of
+// blocking conflicts.
+type PairConflictError struct {
+	Conflicts []PairConflict
+}
+
+func (e *PairConflictError) Error() string {
+	messages := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		messages[i] = conflict.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// comparablePath returns an absolute path that can be compared with other paths,
+// Windows paths are not case sensitive.
+func comparablePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.Clean(path)
+	if runtime.GOOS == "windows" {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// FindPairConflicts compares a folder pair with the other folder pairs, both with the
+// defaults applied. Pairs with the same ID are skipped so an updated pair is not
+// compared with itself.
+func FindPairConflicts(pair *WatcherConfig, others []*WatcherConfig) []PairConflict {
+	source := comparablePath(pair.Source)
+	destinations := pairDestinations(pair)
+
+	conflicts := []PairConflict{}
+	add := func(other *WatcherConfig, kind PairConflictKind, blocking bool, format string, args ...any) {
+		conflicts = append(conflicts, PairConflict{Kind: kind, Pair: other.ID, Blocking: blocking, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, other := range others {
+		if other.ID == pair.ID {
+			continue
+		}
+		otherSource := comparablePath(other.Source)
+		otherDestinations := pairDestinations(other)
+
+		switch {
+		case source == otherSource:
+			add(other, PairConflictSameSource, false, "%s already backs up %s", other.ID, pair.Source)
+		case isPathWithin(source, otherSource):
+			add(other, PairConflictSourceContains, false, "the source contains the source of %s, %s", other.ID, other.Source)
+		case isPathWithin(otherSource, source):
+			add(other, PairConflictSourceInside, false, "the source is inside the source of %s, %s", other.ID, other.Source)
+		}
+
+		for _, destination := range destinations {
+			for _, otherDestination := range otherDestinations {
+				if destination == otherDestination {
+					add(other, PairConflictSharedDestination, true, "%s already stores backups in %s", other.ID, destination)
+				}
+			}
+			if isPathWithin(otherSource, destination) {
+				add(other, PairConflictDestinationInSource, true, "the destination %s is inside the source of %s", destination, other.ID)
+			}
+		}
+		for _, otherDestination := range otherDestinations {
+			if isPathWithin(source, otherDestination) {
+				add(other, PairConflictDestinationInSource, true, "the source contains %s, the destination of %s", otherDestination, other.ID)
+			}
+		}
+	}
+	return conflicts
+}
+
+// pairDestinations returns every destination of a folder pair as comparable paths.
+func pairDestinations(pair *WatcherConfig) []string {
+	destinations := []string{comparablePath(pair.Destination)}
+	for _, destination := range pair.RotationDestinations {
+		destinations = append(destinations, comparablePath(destination))
+	}
+	return destinations
+}
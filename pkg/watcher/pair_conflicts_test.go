@@ -0,0 +1,71 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFindPairConflicts(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	path := func(parts ...string) string {
+		return filepath.Join(append([]string{root}, parts...)...)
+	}
+	existing := []*WatcherConfig{
+		{ID: "documents", Source: path("documents"), Destination: path("backups", "documents")},
+		{ID: "games", Source: path("games", "saves"), Destination: path("backups", "games"), RotationDestinations: []string{path("usb", "games")}},
+	}
+
+	tests := map[string]struct {
+		pair     *WatcherConfig
+		kind     PairConflictKind
+		other    string
+		blocking bool
+	}{
+		"same source": {
+			&WatcherConfig{Source: path("documents"), Destination: path("other")},
+			PairConflictSameSource, "documents", false,
+		},
+		"source contains": {
+			&WatcherConfig{Source: path("games"), Destination: path("other")},
+			PairConflictSourceContains, "games", false,
+		},
+		"source inside": {
+			&WatcherConfig{Source: path("documents", "work"), Destination: path("other")},
+			PairConflictSourceInside, "documents", false,
+		},
+		"shared destination": {
+			&WatcherConfig{Source: path("music"), Destination: path("backups", "documents")},
+			PairConflictSharedDestination, "documents", true,
+		},
+		"shared rotation destination": {
+			&WatcherConfig{Source: path("music"), Destination: path("usb", "games")},
+			PairConflictSharedDestination, "games", true,
+		},
+		"destination in other source": {
+			&WatcherConfig{Source: path("music"), Destination: path("documents", "music")},
+			PairConflictDestinationInSource, "documents", true,
+		},
+		"source contains other destination": {
+			&WatcherConfig{Source: path("usb"), Destination: path("other")},
+			PairConflictDestinationInSource, "games", true,
+		},
+	}
+	for name, test := range tests {
+		conflicts := FindPairConflicts(test.pair, existing)
+		if len(conflicts) != 1 {
+			t.Errorf("%s: Expected 1 conflict, got %+v", name, conflicts)
+			continue
+		}
+		conflict := conflicts[0]
+		if conflict.Kind != test.kind || conflict.Pair != test.other || conflict.Blocking != test.blocking {
+			t.Errorf("%s: Expected a %s conflict with %s, got %+v", name, test.kind, test.other, conflict)
+		}
+	}
+
+	// An updated pair is not compared with itself.
+	updated := *existing[0]
+	if conflicts := FindPairConflicts(&updated, existing); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts for an unchanged pair, got %+v", conflicts)
+	}
+}
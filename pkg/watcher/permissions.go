@@ -0,0 +1,94 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// PermissionPolicy decides what happens to files in the source that the watcher is
+// not allowed to read.
+type PermissionPolicy string
+
+const (
+	// The backup fails, this is the default.
+	PermissionFail PermissionPolicy = "fail"
+	// The files are left out of the backup and a warning is shown.
+	PermissionSkip PermissionPolicy = "skip"
+	// The files are copied again with elevated privileges, using a UAC prompt on
+	// Windows and sudo elsewhere.
+	PermissionElevate PermissionPolicy = "elevate"
+)
+
+func (p PermissionPolicy) validate() error {
+	switch p {
+	case "", PermissionFail, PermissionSkip, PermissionElevate:
+		return nil
+	}
+	return fmt.Errorf("unknown permission policy: %s", p)
+}
+
+// apply returns a copy engine that follows the policy, only the go engine can handle
+// single files that could not be read.
+func (p PermissionPolicy) apply(engine CopyEngine) (CopyEngine, error) {
+	if p == "" || p == PermissionFail {
+		return engine, nil
+	}
+	goEngine, ok := engine.(goCopyEngine)
+	if !ok {
+		return nil, fmt.Errorf("the %s permission policy is only supported by the %s copy engine", p, CopyEngineGo)
+	}
+	goEngine.permissions = p
+	return goEngine, nil
+}
+
+// deniedCopy is a file or folder in the source that could not be read.
+type deniedCopy struct {
+	source      string
+	destination string
+	dir         bool
+}
+
+// skippedFilesError is returned by a copy that left out files it could not read, the
+// rest of the source was copied.
+type skippedFilesError struct {
+	paths []string
+}
+
+func (e *skippedFilesError) Error() string {
+	return fmt.Sprintf("skipped %d files without permission to read them: %s", len(e.paths), strings.Join(e.paths, ", "))
+}
+
+// handleDenied copies the entries that could not be read according to the policy.
+func (p PermissionPolicy) handleDenied(denied []deniedCopy) error {
+	if len(denied) == 0 {
+		return nil
+	}
+	if p == PermissionElevate {
+		if err := elevatedCopy(denied); err != nil {
+			return fmt.Errorf("error copying files with elevated privileges: %w", err)
+		}
+		return nil
+	}
+
+	paths := make([]string, 0, len(denied))
+	for _, entry := range denied {
+		paths = append(paths, entry.source)
+	}
+	return &skippedFilesError{paths: paths}
+}
+
+// collectDenied returns an OnError handler for otiai10/copy that records entries that
+// could not be read instead of stopping the copy.
+func (p PermissionPolicy) collectDenied(denied *[]deniedCopy) func(src, dest string, err error) error {
+	return func(src, dest string, err error) error {
+		if err == nil || !errors.Is(err, fs.ErrPermission) || p == "" || p == PermissionFail {
+			return err
+		}
+		info, statErr := os.Lstat(src)
+		*denied = append(*denied, deniedCopy{source: src, destination: dest, dir: statErr == nil && info.IsDir()})
+		return nil
+	}
+}
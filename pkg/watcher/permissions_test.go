@@ -0,0 +1,89 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// createUnreadableFile creates a file the test can not read, the test is skipped where
+// permissions can not take away read access.
+func createUnreadableFile(t *testing.T, directoryPath, filePath string) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("Files are always readable on Windows and as root")
+	}
+	CreateDummyFile(t, directoryPath, filePath, 10)
+	fullPath := filepath.Join(directoryPath, filePath)
+	if err := os.Chmod(fullPath, 0); err != nil {
+		t.Fatalf("Failed to remove permissions: %v", err)
+	}
+	t.Cleanup(func() { os.Chmod(fullPath, 0644) })
+}
+
+func TestPermissionPolicyValidation(t *testing.T) {
+	t.Parallel()
+	if err := PermissionPolicy("ignore").validate(); err == nil {
+		t.Errorf("Expected an error for an unknown permission policy")
+	}
+	if _, err := PermissionElevate.apply(rsyncCopyEngine{}); err == nil {
+		t.Errorf("Expected an error for an external copy engine")
+	}
+
+	engine, err := PermissionSkip.apply(goCopyEngine{placeholders: PlaceholderStub})
+	if err != nil {
+		t.Fatalf("Failed to apply permission policy: %v", err)
+	}
+	if goEngine := engine.(goCopyEngine); goEngine.permissions != PermissionSkip || goEngine.placeholders != PlaceholderStub {
+		t.Errorf("Expected both policies on the copy engine, got %+v", goEngine)
+	}
+}
+
+func TestPermissionSkip(t *testing.T) {
+	t.Parallel()
+	tempPath := t.TempDir()
+	source := filepath.Join(tempPath, "source")
+	destination := filepath.Join(tempPath, "destination")
+	CreateDummyFile(t, source, "readable.txt", 1024)
+	createUnreadableFile(t, source, "secret.txt")
+
+	err := goCopyEngine{permissions: PermissionSkip}.Copy(source, destination)
+	var skipped *skippedFilesError
+	if !errors.As(err, &skipped) {
+		t.Fatalf("Expected the unreadable file to be skipped, got %v", err)
+	}
+	if len(skipped.paths) != 1 || skipped.paths[0] != filepath.Join(source, "secret.txt") {
+		t.Errorf("Expected only secret.txt to be skipped, got %v", skipped.paths)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "readable.txt")); err != nil {
+		t.Errorf("Expected the readable file to be copied: %v", err)
+	}
+}
+
+func TestPermissionDeniedFailsBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "readable.txt", 1024)
+	createUnreadableFile(t, WatcherConfig.Source, "secret.txt")
+	if _, ok := watcher.createTriggeredBackup(BackupTriggerManual); ok {
+		t.Fatalf("Expected the backup to fail")
+	}
+	if len(watcher.ListBackups()) != 0 {
+		t.Errorf("Expected no backups to be recorded")
+	}
+	entries, err := os.ReadDir(WatcherConfig.Destination)
+	if err != nil {
+		t.Fatalf("Failed to read destination: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			t.Errorf("Expected the incomplete backup to be removed, found %s", entry.Name())
+		}
+	}
+}
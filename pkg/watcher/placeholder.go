@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// PlaceholderPolicy decides how cloud-only placeholder files, such as OneDrive files
+// that are not stored on the device, are backed up. Placeholders only exist on Windows.
+type PlaceholderPolicy string
+
+const (
+	// Download the file and back up its contents, this is the default.
+	PlaceholderHydrate PlaceholderPolicy = "hydrate"
+	// Leave placeholders out of backups.
+	PlaceholderSkip PlaceholderPolicy = "skip"
+	// Back up an empty file with the same name and modification time.
+	PlaceholderStub PlaceholderPolicy = "stub"
+)
+
+func (p PlaceholderPolicy) validate() error {
+	switch p {
+	case "", PlaceholderHydrate, PlaceholderSkip, PlaceholderStub:
+		return nil
+	}
+	return fmt.Errorf("unknown placeholder policy: %s", p)
+}
+
+// apply returns a copy engine that follows the policy. External copy engines always
+// hydrate placeholders so only the go engine supports the other policies.
+func (p PlaceholderPolicy) apply(engine CopyEngine) (CopyEngine, error) {
+	if p == "" || p == PlaceholderHydrate {
+		return engine, nil
+	}
+	goEngine, ok := engine.(goCopyEngine)
+	if !ok {
+		return nil, fmt.Errorf("the %s placeholder policy is only supported by the %s copy engine", p, CopyEngineGo)
+	}
+	goEngine.placeholders = p
+	return goEngine, nil
+}
+
+// skipEntry returns true if an entry of the source is left out of a backup. Junctions
+// are always skipped since they point outside of the source and can form loops.
+func (p PlaceholderPolicy) skipEntry(info fs.FileInfo, destination string) (bool, error) {
+	if isJunction(info) {
+		return true, nil
+	}
+	if info.IsDir() || !isPlaceholder(info) {
+		return false, nil
+	}
+	return p.skipPlaceholder(info, destination)
+}
+
+// skipPlaceholder returns true if a placeholder is not copied, a stub is written to
+// destination in its place if the policy asks for one.
+func (p PlaceholderPolicy) skipPlaceholder(info fs.FileInfo, destination string) (bool, error) {
+	switch p {
+	case PlaceholderSkip:
+		return true, nil
+	case PlaceholderStub:
+		if err := os.WriteFile(destination, nil, 0644); err != nil {
+			return true, err
+		}
+		return true, os.Chtimes(destination, info.ModTime(), info.ModTime())
+	}
+	return false, nil
+}
+
+// placeholderMatches compares a placeholder in the source with its copy in a backup
+// without reading the placeholder, which would download it.
+func (p PlaceholderPolicy) placeholderMatches(source fs.FileInfo, destination string) (bool, error) {
+	if p != PlaceholderStub {
+		return false, nil
+	}
+	info, err := os.Stat(destination)
+	if err != nil {
+		return false, err
+	}
+	return info.Size() == 0 && info.ModTime().Equal(source.ModTime()), nil
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package watcher
+
+import "io/fs"
+
+// isPlaceholder is always false outside of Windows since other systems do not have
+// cloud-only placeholders.
+func isPlaceholder(info fs.FileInfo) bool {
+	return false
+}
+
+func isJunction(info fs.FileInfo) bool {
+	return false
+}
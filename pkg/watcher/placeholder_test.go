@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlaceholderPolicyValidation(t *testing.T) {
+	t.Parallel()
+	if err := PlaceholderPolicy("download").validate(); err == nil {
+		t.Errorf("Expected an error for an unknown placeholder policy")
+	}
+
+	engine, err := PlaceholderStub.apply(goCopyEngine{})
+	if err != nil {
+		t.Fatalf("Failed to apply placeholder policy: %v", err)
+	}
+	if engine.(goCopyEngine).placeholders != PlaceholderStub {
+		t.Errorf("Expected the go copy engine to use the stub policy")
+	}
+	if _, err := PlaceholderSkip.apply(rsyncCopyEngine{}); err == nil {
+		t.Errorf("Expected an error for an external copy engine")
+	}
+	if _, err := PlaceholderHydrate.apply(rsyncCopyEngine{}); err != nil {
+		t.Errorf("Expected hydrating to work with any copy engine: %v", err)
+	}
+}
+
+func TestSkipPlaceholder(t *testing.T) {
+	t.Parallel()
+	tempPath := t.TempDir()
+	CreateDummyFile(t, tempPath, "placeholder.txt", 1024)
+	modTime := time.Date(2024, 5, 1, 18, 30, 0, 0, time.UTC)
+	source := filepath.Join(tempPath, "placeholder.txt")
+	if err := os.Chtimes(source, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+
+	if skipped, err := PlaceholderHydrate.skipPlaceholder(info, filepath.Join(tempPath, "hydrated.txt")); skipped || err != nil {
+		t.Errorf("Expected a hydrated placeholder to be copied, got %t: %v", skipped, err)
+	}
+
+	skippedPath := filepath.Join(tempPath, "skipped.txt")
+	if skipped, err := PlaceholderSkip.skipPlaceholder(info, skippedPath); !skipped || err != nil {
+		t.Errorf("Expected the placeholder to be skipped, got %t: %v", skipped, err)
+	}
+	if _, err := os.Stat(skippedPath); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written for a skipped placeholder")
+	}
+
+	stubPath := filepath.Join(tempPath, "stub.txt")
+	if skipped, err := PlaceholderStub.skipPlaceholder(info, stubPath); !skipped || err != nil {
+		t.Fatalf("Expected a stub to replace the placeholder, got %t: %v", skipped, err)
+	}
+	if matches, err := PlaceholderStub.placeholderMatches(info, stubPath); !matches || err != nil {
+		t.Errorf("Expected the stub to match the placeholder, got %t: %v", matches, err)
+	}
+
+	newer := modTime.Add(time.Hour)
+	if err := os.Chtimes(source, newer, newer); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+	if info, err = os.Stat(source); err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if matches, _ := PlaceholderStub.placeholderMatches(info, stubPath); matches {
+		t.Errorf("Expected a changed placeholder to not match its stub")
+	}
+}
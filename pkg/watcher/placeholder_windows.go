@@ -0,0 +1,35 @@
+//go:build windows
+
+package watcher
+
+import (
+	"io/fs"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// Attributes set on files whose contents are not stored on the device, reading them
+// downloads the contents first.
+const placeholderAttributes = windows.FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS |
+	windows.FILE_ATTRIBUTE_RECALL_ON_OPEN |
+	windows.FILE_ATTRIBUTE_OFFLINE
+
+func fileAttributes(info fs.FileInfo) uint32 {
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return data.FileAttributes
+	}
+	return 0
+}
+
+// isPlaceholder returns true for cloud-only files such as OneDrive files that are not
+// available offline.
+func isPlaceholder(info fs.FileInfo) bool {
+	return fileAttributes(info)&placeholderAttributes != 0
+}
+
+// isJunction returns true for junctions and mount points, Go reports them as irregular
+// files instead of symlinks.
+func isJunction(info fs.FileInfo) bool {
+	return info.Mode()&fs.ModeIrregular != 0 && fileAttributes(info)&windows.FILE_ATTRIBUTE_REPARSE_POINT != 0
+}
@@ -0,0 +1,104 @@
+package watcher
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// How often deferred backups check whether the conditions have changed.
+const defaultDeferPollInterval = time.Minute
+
+// DeferPolicy defers automatic backups while the computer is in a state where a backup
+// would be costly. Conditions that can not be detected on the current platform never
+// defer backups.
+type DeferPolicy struct {
+	// Defer while running on battery power.
+	Battery bool `json:"battery,omitempty" yaml:"battery,omitempty" toml:"battery,omitempty"`
+	// Defer while the network connection is metered, useful for network destinations.
+	// Only detected on Linux with NetworkManager.
+	Metered bool `json:"metered,omitempty" yaml:"metered,omitempty" toml:"metered,omitempty"`
+	// Defer while the CPU is busy for at least this percentage of the time, 0 disables
+	// the check.
+	CPU float64 `json:"cpu,omitempty" yaml:"cpu,omitempty" toml:"cpu,omitempty"`
+	// Defer while programs wait on the disks for at least this percentage of the time,
+	// 0 disables the check. Only detected on Linux.
+	Disk float64 `json:"disk,omitempty" yaml:"disk,omitempty" toml:"disk,omitempty"`
+}
+
+func (p DeferPolicy) enabled() bool {
+	return p.Battery || p.Metered || p.CPU > 0 || p.Disk > 0
+}
+
+func (p DeferPolicy) validate() error {
+	if p.CPU < 0 || p.CPU > 100 {
+		return fmt.Errorf("defer CPU load must be between 0 and 100 percent")
+	}
+	if p.Disk < 0 || p.Disk > 100 {
+		return fmt.Errorf("defer disk load must be between 0 and 100 percent")
+	}
+	return nil
+}
+
+// powerState is the state of the computer that backups can be deferred for.
+type powerState struct {
+	OnBattery bool
+	Metered   bool
+	// Percentage of the time the CPU and disks were busy recently, 0 if it is not
+	// known.
+	CPULoad  float64
+	DiskLoad float64
+}
+
+// reason returns why the policy defers backups in the given state, an empty string
+// means backups are allowed.
+func (p DeferPolicy) reason(state powerState) string {
+	switch {
+	case p.Battery && state.OnBattery:
+		return "Running on battery"
+	case p.Metered && state.Metered:
+		return "Metered connection"
+	// The load is not part of the reason so the reason only changes when another
+	// condition applies.
+	case p.CPU > 0 && state.CPULoad >= p.CPU:
+		return "High CPU load"
+	case p.Disk > 0 && state.DiskLoad >= p.Disk:
+		return "High disk load"
+	}
+	return ""
+}
+
+// loadSampler turns counters of busy and total time that only ever increase into the
+// percentage of the time that was busy since the previous sample. The previous sample is
+// kept between backups so measuring the load never waits.
+type loadSampler struct {
+	mu          sync.Mutex
+	busy, total uint64
+	// Load measured by the previous sample, returned when the counters have not moved
+	// since, such as when several watchers back up at once.
+	last float64
+	// Reads the counters, false if they are not available.
+	read func() (busy, total uint64, ok bool)
+}
+
+func (s *loadSampler) load() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	busy, total, ok := s.read()
+	if !ok {
+		return 0
+	}
+	// The first sample has nothing to compare with so the load is not known yet.
+	if s.total == 0 {
+		s.busy, s.total = busy, total
+		return 0
+	}
+	if total <= s.total {
+		return s.last
+	}
+	load := float64(busy-s.busy) / float64(total-s.total) * 100
+	s.busy, s.total = busy, total
+	s.last = min(max(load, 0), 100)
+	return s.last
+}
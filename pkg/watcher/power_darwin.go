@@ -0,0 +1,18 @@
+//go:build darwin
+
+package watcher
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// readPowerState asks pmset which power source is in use. Metered connections and the
+// load are not detected on macOS.
+func readPowerState() powerState {
+	output, err := exec.Command("pmset", "-g", "batt").Output()
+	if err != nil {
+		return powerState{}
+	}
+	return powerState{OnBattery: strings.Contains(string(output), "'Battery Power'")}
+}
@@ -0,0 +1,115 @@
+//go:build linux
+
+package watcher
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var cpuLoad = &loadSampler{read: func() (uint64, uint64, bool) { return readProcStat("/proc/stat") }}
+
+// readPowerState reads the battery state from sysfs, asks NetworkManager if the
+// connection is metered and reads the load from procfs.
+func readPowerState() powerState {
+	return powerState{
+		OnBattery: onBattery("/sys/class/power_supply"),
+		Metered:   networkManagerMetered(),
+		CPULoad:   cpuLoad.load(),
+		DiskLoad:  pressure("/proc/pressure/io"),
+	}
+}
+
+// onBattery returns true if any battery is discharging.
+func onBattery(powerSupplies string) bool {
+	supplies, err := os.ReadDir(powerSupplies)
+	if err != nil {
+		return false
+	}
+	for _, supply := range supplies {
+		path := filepath.Join(powerSupplies, supply.Name())
+		supplyType, err := os.ReadFile(filepath.Join(path, "type"))
+		if err != nil || strings.TrimSpace(string(supplyType)) != "Battery" {
+			continue
+		}
+		status, err := os.ReadFile(filepath.Join(path, "status"))
+		if err == nil && strings.TrimSpace(string(status)) == "Discharging" {
+			return true
+		}
+	}
+	return false
+}
+
+// networkManagerMetered returns true if any device is on a metered connection,
+// including connections NetworkManager guessed are metered such as phone hotspots.
+func networkManagerMetered() bool {
+	nmcli, err := exec.LookPath("nmcli")
+	if err != nil {
+		return false
+	}
+	output, err := exec.Command(nmcli, "--terse", "--get-values", "GENERAL.METERED", "device", "show").Output()
+	if err != nil {
+		return false
+	}
+	for line := range strings.Lines(string(output)) {
+		if strings.HasPrefix(strings.TrimSpace(line), "yes") {
+			return true
+		}
+	}
+	return false
+}
+
+// readProcStat returns the time all CPUs were busy and the total time since boot from
+// the first line of /proc/stat. Idle and waiting for IO count as not busy.
+func readProcStat(path string) (busy, total uint64, ok bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 6 || fields[0] != "cpu" {
+		return 0, 0, false
+	}
+	// Guest time is already included in user time so only the first 8 columns are used.
+	for i, field := range fields[1:min(len(fields), 9)] {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		total += value
+		if i != 3 && i != 4 {
+			busy += value
+		}
+	}
+	return busy, total, true
+}
+
+// pressure returns the percentage of the last 10 seconds that some programs were
+// stalled, from a pressure stall information file. It is 0 if the kernel does not
+// provide the file.
+func pressure(path string) float64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	for line := range strings.Lines(string(data)) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+		value, found := strings.CutPrefix(fields[1], "avg10=")
+		if !found {
+			return 0
+		}
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0
+		}
+		return percent
+	}
+	return 0
+}
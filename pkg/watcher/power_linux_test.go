@@ -0,0 +1,55 @@
+//go:build linux
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOnBattery(t *testing.T) {
+	t.Parallel()
+	supplies := t.TempDir()
+	writeSupply := func(name, supplyType, status string) {
+		path := filepath.Join(supplies, name)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatalf("Failed to create power supply: %v", err)
+		}
+		os.WriteFile(filepath.Join(path, "type"), []byte(supplyType+"\n"), 0644)
+		if status != "" {
+			os.WriteFile(filepath.Join(path, "status"), []byte(status+"\n"), 0644)
+		}
+	}
+
+	writeSupply("AC", "Mains", "")
+	writeSupply("BAT0", "Battery", "Charging")
+	if onBattery(supplies) {
+		t.Errorf("Expected a charging battery to not be on battery")
+	}
+
+	writeSupply("BAT0", "Battery", "Discharging")
+	if !onBattery(supplies) {
+		t.Errorf("Expected a discharging battery to be on battery")
+	}
+}
+
+func TestReadLoad(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	stat := filepath.Join(dir, "stat")
+	os.WriteFile(stat, []byte("cpu  100 0 50 800 50 0 0 0 20 0\ncpu0 100 0 50 800 50 0 0 0 20 0\n"), 0644)
+	busy, total, ok := readProcStat(stat)
+	if !ok || busy != 150 || total != 1000 {
+		t.Errorf("Expected 150 of 1000 busy, got %d of %d (%t)", busy, total, ok)
+	}
+
+	io := filepath.Join(dir, "io")
+	os.WriteFile(io, []byte("some avg10=42.50 avg60=10.00 avg300=1.00 total=123\nfull avg10=30.00 avg60=5.00 avg300=0.50 total=100\n"), 0644)
+	if load := pressure(io); load != 42.5 {
+		t.Errorf("Expected a disk load of 42.5, got %f", load)
+	}
+	if load := pressure(filepath.Join(dir, "missing")); load != 0 {
+		t.Errorf("Expected no load without pressure information, got %f", load)
+	}
+}
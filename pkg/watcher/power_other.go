@@ -0,0 +1,9 @@
+//go:build !linux && !windows && !darwin
+
+package watcher
+
+// readPowerState does not detect anything on other platforms so backups are never
+// deferred.
+func readPowerState() powerState {
+	return powerState{}
+}
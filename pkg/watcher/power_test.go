@@ -0,0 +1,112 @@
+package watcher
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackupDeferredOnBattery(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 100 * time.Millisecond
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	var mu sync.Mutex
+	state := powerState{OnBattery: true}
+	watcher.powerState = func() powerState {
+		mu.Lock()
+		defer mu.Unlock()
+		return state
+	}
+	watcher.deferPollInterval = time.Second
+	watcher.Defer = DeferPolicy{Battery: true}
+	observer := startBackupLoop(t, watcher)
+
+	// The first change is deferred once it settles, the second waits for the deferral.
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+	clock.BlockUntil(1)
+	clock.Advance(WatcherConfig.WaitTime)
+	clock.BlockUntil(1)
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+	clock.BlockUntil(2)
+	clock.Advance(WatcherConfig.WaitTime)
+	watcher.flushObservers()
+	if count := observer.getCurrentCount(); count != 0 {
+		t.Fatalf("Expected no backups on battery, got %d", count)
+	}
+	if watcher.Status().Deferred == "" {
+		t.Errorf("Expected the status to show that backups are deferred")
+	}
+
+	// The deferred changes are backed up in one backup once the computer is plugged in.
+	mu.Lock()
+	state.OnBattery = false
+	mu.Unlock()
+	clock.Advance(watcher.deferPollInterval)
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected a backup once the computer is plugged in")
+	}
+	watcher.flushObservers()
+	if count := observer.getCurrentCount(); count != 1 {
+		t.Errorf("Expected the deferred changes to be backed up once, got %d backups", count)
+	}
+	if watcher.Status().Deferred != "" {
+		t.Errorf("Expected the deferral to be cleared, got '%s'", watcher.Status().Deferred)
+	}
+}
+
+func TestDeferPolicyReason(t *testing.T) {
+	t.Parallel()
+	metered := powerState{Metered: true}
+	if reason := (DeferPolicy{Battery: true}).reason(metered); reason != "" {
+		t.Errorf("Expected a battery policy to ignore metered connections, got '%s'", reason)
+	}
+	if reason := (DeferPolicy{Metered: true}).reason(metered); reason == "" {
+		t.Errorf("Expected a metered policy to defer on a metered connection")
+	}
+
+	policy := DeferPolicy{CPU: 80, Disk: 50}
+	if reason := policy.reason(powerState{CPULoad: 79, DiskLoad: 49}); reason != "" {
+		t.Errorf("Expected a load below the thresholds to not defer, got '%s'", reason)
+	}
+	if reason := policy.reason(powerState{CPULoad: 80}); reason != "High CPU load" {
+		t.Errorf("Expected a high CPU load to defer, got '%s'", reason)
+	}
+	if reason := policy.reason(powerState{DiskLoad: 75}); reason != "High disk load" {
+		t.Errorf("Expected a high disk load to defer, got '%s'", reason)
+	}
+	if err := (DeferPolicy{CPU: 120}).validate(); err == nil {
+		t.Errorf("Expected an error for a CPU load above 100 percent")
+	}
+}
+
+func TestLoadSampler(t *testing.T) {
+	t.Parallel()
+	samples := [][2]uint64{{100, 1000}, {150, 1100}, {150, 1100}, {250, 1200}}
+	sampler := &loadSampler{read: func() (uint64, uint64, bool) {
+		sample := samples[0]
+		samples = samples[1:]
+		return sample[0], sample[1], true
+	}}
+	// The first sample only records the counters, it does not wait for a second one.
+	if load := sampler.load(); load != 0 {
+		t.Errorf("Expected an unknown load for the first sample, got %f", load)
+	}
+	if load := sampler.load(); load != 50 {
+		t.Errorf("Expected a load of 50 percent, got %f", load)
+	}
+	// Counters that have not moved keep the previous load.
+	if load := sampler.load(); load != 50 {
+		t.Errorf("Expected the previous load of 50 percent, got %f", load)
+	}
+	if load := sampler.load(); load != 100 {
+		t.Errorf("Expected a load of 100 percent, got %f", load)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build windows
+
+package watcher
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procGetSystemPowerStatus = kernel32.NewProc("GetSystemPowerStatus")
+	procGetSystemTimes       = kernel32.NewProc("GetSystemTimes")
+)
+
+var cpuLoad = &loadSampler{read: readSystemTimes}
+
+// systemPowerStatus is SYSTEM_POWER_STATUS.
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+// readPowerState reads the battery state and the CPU load. Metered connections and the
+// disk load are not detected on Windows.
+func readPowerState() powerState {
+	state := powerState{CPULoad: cpuLoad.load()}
+	var status systemPowerStatus
+	if ret, _, _ := procGetSystemPowerStatus.Call(uintptr(unsafe.Pointer(&status))); ret == 0 {
+		return state
+	}
+	// An AC line status of 0 is offline, 128 in the battery flag means there is no
+	// battery.
+	state.OnBattery = status.ACLineStatus == 0 && status.BatteryFlag != 128
+	return state
+}
+
+// readSystemTimes returns the time all CPUs were busy and the total time since boot.
+// The kernel time includes the idle time.
+func readSystemTimes() (busy, total uint64, ok bool) {
+	var idle, kernel, user windows.Filetime
+	ret, _, _ := procGetSystemTimes.Call(uintptr(unsafe.Pointer(&idle)), uintptr(unsafe.Pointer(&kernel)), uintptr(unsafe.Pointer(&user)))
+	if ret == 0 {
+		return 0, 0, false
+	}
+	ticks := func(t windows.Filetime) uint64 {
+		return uint64(t.HighDateTime)<<32 | uint64(t.LowDateTime)
+	}
+	total = ticks(kernel) + ticks(user)
+	return total - ticks(idle), total, true
+}
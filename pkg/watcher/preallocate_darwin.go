@@ -0,0 +1,19 @@
+//go:build darwin
+
+package watcher
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate reserves size bytes for file with F_PREALLOCATE, which does not change
+// the size of the file.
+func preallocate(file *os.File, size int64) error {
+	return unix.FcntlFstore(file.Fd(), unix.F_PREALLOCATE, &unix.Fstore_t{
+		Flags:   unix.F_ALLOCATEALL,
+		Posmode: unix.F_PEOFPOSMODE,
+		Length:  size,
+	})
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !windows
+
+package watcher
+
+import (
+	"errors"
+	"os"
+)
+
+func preallocate(file *os.File, size int64) error {
+	return errors.ErrUnsupported
+}
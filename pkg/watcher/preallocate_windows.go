@@ -0,0 +1,18 @@
+//go:build windows
+
+package watcher
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// preallocate reserves size bytes for file by setting its allocation size, which does
+// not change the end of the file. SetFileValidData is not used since it needs an
+// administrator privilege and exposes whatever the reserved clusters held before.
+func preallocate(file *os.File, size int64) error {
+	info := struct{ AllocationSize int64 }{size}
+	return windows.SetFileInformationByHandle(windows.Handle(file.Fd()), windows.FileAllocationInfo, (*byte)(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+}
@@ -0,0 +1,34 @@
+package watcher
+
+import "fmt"
+
+// Priority decides which watcher backs up first when backups wait for each other, such
+// as for the limit on concurrent backups of an app. It does not interrupt a backup that
+// has already started.
+type Priority string
+
+const (
+	PriorityLow Priority = "low"
+	// The default.
+	PriorityNormal Priority = "normal"
+	PriorityHigh   Priority = "high"
+)
+
+func (p Priority) validate() error {
+	switch p {
+	case "", PriorityLow, PriorityNormal, PriorityHigh:
+		return nil
+	}
+	return fmt.Errorf("unknown priority: %s", p)
+}
+
+// Rank orders priorities, a watcher with a higher rank backs up first.
+func (p Priority) Rank() int {
+	switch p {
+	case PriorityLow:
+		return -1
+	case PriorityHigh:
+		return 1
+	}
+	return 0
+}
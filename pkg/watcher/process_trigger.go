@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ProcessTriggerConfig backs up the source as soon as a program exits, for programs
+// such as games that only write their files when they are closed.
+type ProcessTriggerConfig struct {
+	// Executable name of the program such as "game.exe", the case is ignored.
+	Name string `json:"name,omitempty" yaml:"name,omitempty" toml:"name,omitempty"`
+}
+
+func (c ProcessTriggerConfig) enabled() bool {
+	return c.Name != ""
+}
+
+func (c ProcessTriggerConfig) validate() error {
+	if strings.ContainsAny(c.Name, `/\`) {
+		return fmt.Errorf("process trigger name must not be a path: %s", c.Name)
+	}
+	return nil
+}
+
+// How often the running programs are checked for the process trigger.
+const defaultProcessPollInterval = 5 * time.Second
+
+// Linux truncates the names in /proc/<pid>/comm to this many characters.
+const processCommLength = 15
+
+// processRunning returns true if a program with the given name is in processes.
+func processRunning(processes []string, name string) bool {
+	return slices.ContainsFunc(processes, func(process string) bool {
+		process = filepath.Base(process)
+		if strings.EqualFold(process, name) {
+			return true
+		}
+		return len(process) == processCommLength && len(name) > processCommLength && strings.EqualFold(process, name[:processCommLength])
+	})
+}
+
+// processLoop watches for the program of the process trigger to exit and asks the
+// backup thread to back up right away. There is no portable event for a process
+// exiting so the running programs are polled.
+func (w *Watcher) processLoop(config ProcessTriggerConfig, stop chan struct{}) {
+	running := false
+	for {
+		timer := w.clock.NewTimer(w.processPollInterval)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		processes, err := w.runningProcesses()
+		if err != nil {
+			Logf(w.Name, LogLevelWarn, "Error listing running programs: %v", err)
+			continue
+		}
+		current := processRunning(processes, config.Name)
+		if running && !current {
+			Logf(w.Name, LogLevelInfo, "%s exited, creating backup", config.Name)
+			select {
+			case w.processExitChan <- struct{}{}:
+			default:
+			}
+		}
+		running = current
+	}
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runningProcesses returns the names of the running programs. The executable is only
+// readable for processes of the same user, comm is used for the rest.
+func runningProcesses() ([]string, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	processes := []string{}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		dir := filepath.Join("/proc", entry.Name())
+		if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+			processes = append(processes, filepath.Base(exe))
+		}
+		// Programs run through an interpreter or Wine have a more useful comm.
+		if comm, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+			processes = append(processes, strings.TrimSpace(string(comm)))
+		}
+	}
+	return processes, nil
+}
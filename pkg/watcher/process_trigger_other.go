@@ -0,0 +1,23 @@
+//go:build !linux && !windows
+
+package watcher
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// runningProcesses returns the names of the running programs using ps.
+func runningProcesses() ([]string, error) {
+	output, err := exec.Command("ps", "-axco", "comm=").Output()
+	if err != nil {
+		return nil, err
+	}
+	processes := []string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			processes = append(processes, line)
+		}
+	}
+	return processes, nil
+}
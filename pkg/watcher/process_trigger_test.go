@@ -0,0 +1,111 @@
+package watcher
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProcessRunning(t *testing.T) {
+	t.Parallel()
+	processes := []string{"bash", "Game.exe", "very-long-progr"}
+	tests := map[string]bool{
+		"game.exe":               true,
+		"GAME.EXE":               true,
+		"game":                   false,
+		"very-long-program-name": true,
+		"very-long-progr":        true,
+		"bash.exe":               false,
+	}
+	for name, expected := range tests {
+		if running := processRunning(processes, name); running != expected {
+			t.Errorf("%s: Expected %t, got %t", name, expected, running)
+		}
+	}
+}
+
+func TestBackupOnProcessExit(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	// Long enough that only the process trigger can cause a backup during the test.
+	WatcherConfig.WaitTime = time.Minute
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "save.dat", 10)
+
+	var mu sync.Mutex
+	processes := []string{"game.exe"}
+	watcher.runningProcesses = func() ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return processes, nil
+	}
+	watcher.processPollInterval = time.Second
+	observer := startBackupLoop(t, watcher)
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	go watcher.processLoop(ProcessTriggerConfig{Name: "game.exe"}, stop)
+
+	// A change while the game runs waits for the files to settle as usual.
+	clock.BlockUntil(1)
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+	clock.BlockUntil(2)
+	clock.Advance(watcher.processPollInterval)
+	clock.BlockUntil(2)
+	watcher.flushObservers()
+	if count := observer.getCurrentCount(); count != 0 {
+		t.Fatalf("Expected no backups while the game is running, got %d", count)
+	}
+
+	mu.Lock()
+	processes = []string{}
+	mu.Unlock()
+	clock.Advance(watcher.processPollInterval)
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected a backup when the game exits")
+	}
+
+	// The change was covered by the backup so no second backup is made once the wait
+	// time would have passed.
+	clock.BlockUntil(1)
+	clock.Advance(WatcherConfig.WaitTime)
+	clock.BlockUntil(1)
+	watcher.flushObservers()
+	if count := observer.getCurrentCount(); count != 1 {
+		t.Errorf("Expected 1 backup, got %d", count)
+	}
+	sidecar, err := ReadBackupSidecar(watcher.BackupPath(watcher.ListBackups()[0]))
+	if err != nil {
+		t.Fatalf("Failed to read sidecar: %v", err)
+	}
+	if sidecar.Trigger != BackupTriggerChange {
+		t.Errorf("Expected the pending change to be the trigger, got '%s'", sidecar.Trigger)
+	}
+}
+
+func TestProcessTriggerWithoutChanges(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "save.dat", 10)
+	observer := startBackupLoop(t, watcher)
+
+	watcher.processExitChan <- struct{}{}
+	if !observer.WaitUntilCount(1, 2*time.Second) {
+		t.Fatalf("Expected a backup without any file events")
+	}
+	sidecar, err := ReadBackupSidecar(watcher.BackupPath(watcher.ListBackups()[0]))
+	if err != nil {
+		t.Fatalf("Failed to read sidecar: %v", err)
+	}
+	if sidecar.Trigger != BackupTriggerProcessExit {
+		t.Errorf("Expected trigger '%s', got '%s'", BackupTriggerProcessExit, sidecar.Trigger)
+	}
+}
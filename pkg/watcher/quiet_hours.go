@@ -0,0 +1,93 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Time of day format used by quiet hours.
+const quietHoursLayout = "15:04"
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// QuietHours is a time window during which automatic backups are deferred. Changes made
+// during the window are backed up in a single backup once it ends.
+type QuietHours struct {
+	// Local time the window starts and ends, such as "09:00". A window that ends
+	// before it starts continues past midnight.
+	Start string `json:"start" yaml:"start" toml:"start"`
+	End   string `json:"end" yaml:"end" toml:"end"`
+	// Days the window starts on, such as "mon", every day if empty.
+	Days []string `json:"days,omitempty" yaml:"days,omitempty" toml:"days,omitempty"`
+}
+
+func (q QuietHours) validate() error {
+	var errs error
+	if _, err := time.Parse(quietHoursLayout, q.Start); err != nil {
+		errs = errors.Join(errs, fmt.Errorf("invalid quiet hours start %q, expected a time such as 09:00", q.Start))
+	}
+	if _, err := time.Parse(quietHoursLayout, q.End); err != nil {
+		errs = errors.Join(errs, fmt.Errorf("invalid quiet hours end %q, expected a time such as 18:00", q.End))
+	}
+	if q.Start == q.End {
+		errs = errors.Join(errs, fmt.Errorf("quiet hours start and end must be different"))
+	}
+	for _, day := range q.Days {
+		if !slices.Contains(weekdayNames, strings.ToLower(day)) {
+			errs = errors.Join(errs, fmt.Errorf("invalid quiet hours day %q, expected one of %s", day, strings.Join(weekdayNames, ", ")))
+		}
+	}
+	return errs
+}
+
+// window returns the occurrence of the window that starts on the same day as day.
+func (q QuietHours) window(day time.Time) (time.Time, time.Time, bool) {
+	if len(q.Days) > 0 && !slices.ContainsFunc(q.Days, func(name string) bool {
+		return strings.ToLower(name) == weekdayNames[day.Weekday()]
+	}) {
+		return time.Time{}, time.Time{}, false
+	}
+
+	startTime, err := time.Parse(quietHoursLayout, q.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	endTime, err := time.Parse(quietHoursLayout, q.End)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	year, month, date := day.Date()
+	start := time.Date(year, month, date, startTime.Hour(), startTime.Minute(), 0, 0, day.Location())
+	end := time.Date(year, month, date, endTime.Hour(), endTime.Minute(), 0, 0, day.Location())
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, true
+}
+
+// quietUntil returns the time the quiet hours that now is in end, false is returned if
+// backups are allowed now. Windows that overlap or follow each other are joined.
+func quietUntil(windows []QuietHours, now time.Time) (time.Time, bool) {
+	now = now.In(time.Local)
+	until := now
+	for {
+		extended := false
+		for _, window := range windows {
+			// A window that started yesterday can still be open after midnight.
+			for _, day := range []time.Time{until, until.AddDate(0, 0, -1)} {
+				start, end, ok := window.window(day)
+				if ok && !until.Before(start) && until.Before(end) {
+					until = end
+					extended = true
+				}
+			}
+		}
+		if !extended {
+			return until, !until.Equal(now)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietUntil(t *testing.T) {
+	t.Parallel()
+	// 2024-05-06 is a Monday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 5, day, hour, minute, 0, 0, time.Local)
+	}
+	windows := []QuietHours{
+		{Start: "09:00", End: "18:00", Days: []string{"mon", "Tue"}},
+		{Start: "18:00", End: "19:30", Days: []string{"mon"}},
+		{Start: "23:00", End: "07:00"},
+	}
+
+	tests := []struct {
+		now   time.Time
+		until time.Time
+		quiet bool
+	}{
+		{at(6, 8, 59), time.Time{}, false},
+		// Windows that follow each other are joined.
+		{at(6, 9, 0), at(6, 19, 30), true},
+		{at(7, 17, 0), at(7, 18, 0), true},
+		{at(8, 12, 0), time.Time{}, false},
+		// A window that continues past midnight.
+		{at(8, 23, 30), at(9, 7, 0), true},
+		{at(9, 6, 59), at(9, 7, 0), true},
+		{at(9, 7, 0), time.Time{}, false},
+	}
+	for _, test := range tests {
+		until, quiet := quietUntil(windows, test.now)
+		if quiet != test.quiet || (quiet && !until.Equal(test.until)) {
+			t.Errorf("%s: Expected %t until %s, got %t until %s", test.now, test.quiet, test.until, quiet, until)
+		}
+	}
+}
+
+func TestQuietHoursValidation(t *testing.T) {
+	t.Parallel()
+	invalid := []QuietHours{
+		{Start: "9am", End: "18:00"},
+		{Start: "09:00", End: "09:00"},
+		{Start: "09:00", End: "18:00", Days: []string{"someday"}},
+	}
+	for _, window := range invalid {
+		if err := window.validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", window)
+		}
+	}
+}
+
+func TestBackupDeferredDuringQuietHours(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 100 * time.Millisecond
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local))
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.QuietHours = []QuietHours{{Start: "09:00", End: "18:00"}}
+	observer := startBackupLoop(t, watcher)
+
+	watcher.requestBackup(BackupTriggerChange)
+	clock.BlockUntil(1)
+	clock.Advance(100 * time.Millisecond)
+	// The wait timer has fired and been replaced by the timer for the end of the quiet
+	// hours.
+	clock.BlockUntil(1)
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+
+	if count := observer.getCurrentCount(); count != 0 {
+		t.Errorf("Expected no backups during quiet hours, got %d", count)
+	}
+	if watcher.Status().Deferred == "" {
+		t.Errorf("Expected the status to show that backups are deferred")
+	}
+
+	clock.Advance(6 * time.Hour)
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected the changes to be backed up when the quiet hours end")
+	}
+}
+
+func TestUrgentBackupDuringQuietHours(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 100 * time.Millisecond
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local))
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.QuietHours = []QuietHours{{Start: "09:00", End: "18:00"}}
+	observer := NewSimplifiedObserver()
+	watcher.AddObserver(observer)
+	stop, done := make(chan struct{}), make(chan struct{})
+	go watcher.runBackupLoop(stop, done)
+
+	watcher.requestBackup(BackupTriggerChange)
+	clock.BlockUntil(1)
+	clock.Advance(100 * time.Millisecond)
+	clock.BlockUntil(1)
+
+	// The urgent backup covers the deferred changes, they are not backed up again when
+	// the quiet hours end.
+	watcher.requestUrgentBackup(BackupTriggerMassChange)
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected the urgent backup to be made during quiet hours")
+	}
+	if deferred := watcher.Status().Deferred; deferred != "" {
+		t.Errorf("Expected the deferral to be cleared, got '%s'", deferred)
+	}
+	// Changes that are still pending when the watcher stops are recorded as unsaved.
+	close(stop)
+	<-done
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	if watcher.unsavedChanges {
+		t.Errorf("Expected no pending changes after the urgent backup")
+	}
+}
@@ -0,0 +1,17 @@
+package watcher
+
+import "errors"
+
+var errReadOnlySource = errors.New("the source is read-only")
+
+// checkSourceWritable returns errReadOnlySource if the watcher is not allowed to write
+// inside the source. Backups, the sidecar files and the metadata only ever live in the
+// destinations, the only writes to the source are restores and the self test probe.
+func (w *Watcher) checkSourceWritable() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ReadOnlySource {
+		return errReadOnlySource
+	}
+	return nil
+}
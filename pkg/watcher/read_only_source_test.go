@@ -0,0 +1,69 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// sourceTree records the path, size and modification time of everything in a folder.
+func sourceTree(t *testing.T, root string) map[string]string {
+	tree := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		tree[path] = fmt.Sprintf("%d %s", info.Size(), info.ModTime())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read source: %v", err)
+	}
+	return tree
+}
+
+func TestReadOnlySource(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.ReadOnlySource = true
+	watcher.Checksums.Enabled = true
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	CreateDummyFile(t, WatcherConfig.Source, filepath.Join("folder", "file2.txt"), 10)
+	before := sourceTree(t, WatcherConfig.Source)
+
+	if err := watcher.StartWatcher(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.StopWatcher()
+	backup, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatalf("Failed to create backup")
+	}
+	result := watcher.SelfTest()
+	if !result.Passed || !result.EventsSkipped {
+		t.Errorf("Expected the self test to pass without writing to the source, got %+v", result)
+	}
+	if _, err := watcher.RestoreBackup(backup.Path); !errors.Is(err, errReadOnlySource) {
+		t.Errorf("Expected restoring a backup to be refused, got %v", err)
+	}
+	if _, err := watcher.RestoreFile(backup.Path, "file1.txt", true); !errors.Is(err, errReadOnlySource) {
+		t.Errorf("Expected restoring a file to be refused, got %v", err)
+	}
+	time.Sleep(WatcherConfig.WaitTime)
+
+	if after := sourceTree(t, WatcherConfig.Source); !maps.Equal(before, after) {
+		t.Errorf("Expected the source to be unchanged, got %v instead of %v", after, before)
+	}
+}
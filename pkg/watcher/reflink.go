@@ -0,0 +1,77 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errCloneUnsupported is returned by cloneFile if the files can not be cloned, because
+// of the platform or the filesystem.
+var errCloneUnsupported = fmt.Errorf("cloning files is not supported: %w", errors.ErrUnsupported)
+
+// previousBackupPath returns the folder of the newest backup in destination that
+// unchanged files can be cloned from, or an empty string if there is none.
+func (w *Watcher) previousBackupPath(destination string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := len(w.Metadata) - 1; i >= 0; i-- {
+		backup := w.Metadata[i]
+		if backup.Destination != destination || backup.ResticSnapshot != "" || backup.Snapshot != "" {
+			continue
+		}
+		// A backup that was changed outside of the watcher could hold files that no
+		// longer match the source they were copied from.
+		if w.externallyModified[backup.Path] {
+			return ""
+		}
+		return filepath.Join(destination, filepath.FromSlash(backup.Path))
+	}
+	return ""
+}
+
+// withPrevious returns a copy engine that clones the files that did not change since
+// previous from it instead of copying them. Only the go engine can do this, validated
+// by validateSnapshotMode.
+func withPrevious(engine CopyEngine, previous string) CopyEngine {
+	goEngine, ok := engine.(goCopyEngine)
+	if !ok || previous == "" {
+		return engine
+	}
+	goEngine.previous = previous
+	return goEngine
+}
+
+// clonePrevious clones the file at destination from the previous backup if it has the
+// same size and modification time as src, which has info and is inside source. False
+// is returned if the file has to be copied, including when the filesystem can not
+// clone files.
+func (e goCopyEngine) clonePrevious(info os.FileInfo, source, src, destination string) bool {
+	if e.previous == "" || !info.Mode().IsRegular() {
+		return false
+	}
+	relPath, err := filepath.Rel(source, src)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return false
+	}
+	previous := filepath.Join(e.previous, relPath)
+	previousInfo, err := os.Lstat(previous)
+	if err != nil || !previousInfo.Mode().IsRegular() || previousInfo.Size() != info.Size() || !previousInfo.ModTime().Equal(info.ModTime()) {
+		return false
+	}
+
+	if err := cloneFile(previous, destination); err != nil {
+		if !errors.Is(err, errors.ErrUnsupported) {
+			Logf("", LogLevelDebug, "Copying %s instead of cloning it: %v", src, err)
+		}
+		return false
+	}
+	if err := errors.Join(os.Chmod(destination, info.Mode().Perm()), os.Chtimes(destination, info.ModTime(), info.ModTime())); err != nil {
+		Logf("", LogLevelDebug, "Copying %s instead of cloning it: %v", src, err)
+		os.Remove(destination)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,20 @@
+//go:build darwin
+
+package watcher
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates destination as a clone of source with clonefile, which shares the
+// blocks of source until either file is changed. It fails unless both files are on the
+// same APFS volume.
+func cloneFile(source, destination string) error {
+	err := unix.Clonefile(source, destination, unix.CLONE_NOFOLLOW)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EXDEV) {
+		return errCloneUnsupported
+	}
+	return err
+}
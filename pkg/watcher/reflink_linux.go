@@ -0,0 +1,34 @@
+//go:build linux
+
+package watcher
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates destination as a reflink of source with FICLONE, which shares the
+// blocks of source until either file is changed. It fails unless both files are on the
+// same filesystem and it supports reflinks, such as btrfs and XFS.
+func cloneFile(source, destination string) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())); err != nil {
+		dst.Close()
+		os.Remove(destination)
+		if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EXDEV) || errors.Is(err, unix.EINVAL) {
+			return errCloneUnsupported
+		}
+		return err
+	}
+	return dst.Close()
+}
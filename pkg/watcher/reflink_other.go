@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package watcher
+
+func cloneFile(source, destination string) error {
+	return errCloneUnsupported
+}
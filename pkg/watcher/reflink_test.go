@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReflinkBackups(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Snapshot = SnapshotModeReflink
+	CreateDummyFile(t, WatcherConfig.Source, "unchanged.txt", 1024)
+	CreateDummyFile(t, WatcherConfig.Source, "changed.txt", 1024)
+	first, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatalf("Failed to create backup")
+	}
+	if previous := watcher.previousBackupPath(WatcherConfig.Destination); previous != watcher.BackupPath(first) {
+		t.Fatalf("Expected files to be cloned from %s, got %s", watcher.BackupPath(first), previous)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "changed.txt", 2048)
+	second, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatalf("Failed to create backup")
+	}
+	// Whether or not this filesystem can clone files, every backup is complete.
+	for _, name := range []string{"unchanged.txt", "changed.txt"} {
+		expected, _ := os.ReadFile(filepath.Join(WatcherConfig.Source, name))
+		actual, err := os.ReadFile(filepath.Join(watcher.BackupPath(second), name))
+		if err != nil || !bytes.Equal(actual, expected) {
+			t.Errorf("Expected %s to match the source: %v", name, err)
+		}
+	}
+	if matches, err := watcher.SourceMatchesLatestBackup(); err != nil || !matches {
+		t.Errorf("Expected the source to match the backup, got %t: %v", matches, err)
+	}
+
+	// Only files with the size and modification time of the previous backup are cloned.
+	engine := withPrevious(goCopyEngine{}, watcher.BackupPath(first)).(goCopyEngine)
+	destination := t.TempDir()
+	CreateDummyFile(t, WatcherConfig.Source, "missing.txt", 10)
+	for _, test := range []struct {
+		name   string
+		cloned bool
+	}{{"unchanged.txt", true}, {"changed.txt", false}, {"missing.txt", false}} {
+		src := filepath.Join(WatcherConfig.Source, test.name)
+		info, err := os.Stat(src)
+		if err != nil {
+			t.Fatalf("Failed to stat file: %v", err)
+		}
+		dest := filepath.Join(destination, test.name)
+		cloned := engine.clonePrevious(info, WatcherConfig.Source, src, dest)
+		if test.cloned {
+			if err := cloneFile(src, filepath.Join(destination, "probe")); errors.Is(err, errCloneUnsupported) {
+				continue
+			}
+		}
+		if cloned != test.cloned {
+			t.Errorf("Expected %s to be cloned: %t, got %t", test.name, test.cloned, cloned)
+		}
+	}
+}
+
+func TestReflinkValidation(t *testing.T) {
+	t.Parallel()
+	config := &WatcherConfig{Snapshot: SnapshotModeReflink, CopyEngine: CopyEngineRsync}
+	if err := validateSnapshotMode(config, false); err == nil {
+		t.Errorf("Expected an error for reflink snapshots with an external copy engine")
+	}
+	config.CopyEngine = "Go"
+	if err := validateSnapshotMode(config, false); err != nil {
+		t.Errorf("Expected reflink snapshots with the go copy engine to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,499 @@
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RemoteType is the kind of storage copies of backups are uploaded to.
+type RemoteType string
+
+const (
+	// RemoteWebDAV uploads to a folder on a WebDAV server such as Nextcloud or ownCloud.
+	RemoteWebDAV RemoteType = "webdav"
+	// RemoteGoogleDrive uploads to a folder in Google Drive.
+	RemoteGoogleDrive RemoteType = "gdrive"
+	// RemoteOneDrive uploads to a folder in OneDrive.
+	RemoteOneDrive RemoteType = "onedrive"
+	// RemoteB2 uploads to a bucket in Backblaze B2.
+	RemoteB2 RemoteType = "b2"
+)
+
+// Folder in Google Drive, OneDrive or a B2 bucket that copies are uploaded to by default.
+const defaultRemoteFolder = "i-saw-that"
+
+var ErrRemoteNotFound = errors.New("not found on the remote")
+
+// How long a remote upload that failed waits before it is tried again.
+const defaultRemoteRetryInterval = 5 * time.Minute
+
+// Size of the chunks files larger than it are uploaded in by default.
+const defaultRemoteChunkSize = 10 << 20
+
+// RemoteConfig uploads a copy of every backup to storage off the machine once it has
+// been made in the destination, which keeps its copy.
+type RemoteConfig struct {
+	Type RemoteType `json:"type,omitempty" yaml:"type,omitempty" toml:"type,omitempty"`
+	// Folder the copies are uploaded to, such as
+	// https://cloud.example.com/remote.php/dav/files/alice/Backups for Nextcloud.
+	URL      string `json:"url,omitempty" yaml:"url,omitempty" toml:"url,omitempty"`
+	User     string `json:"user,omitempty" yaml:"user,omitempty" toml:"user,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty" toml:"password,omitempty"`
+	// Size in bytes of the chunks larger files are uploaded in, 10 MiB if 0. A negative
+	// size uploads every file in a single request.
+	ChunkSize int64 `json:"chunk_size,omitempty" yaml:"chunk_size,omitempty" toml:"chunk_size,omitempty"`
+	// OAuth client that Google Drive and OneDrive are signed in to with, registered by
+	// the user. The token is kept in the keychain.
+	ClientID     string `json:"client_id,omitempty" yaml:"client_id,omitempty" toml:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty" yaml:"client_secret,omitempty" toml:"client_secret,omitempty"`
+	// Folder in Google Drive, OneDrive or the B2 bucket the copies are uploaded to, such
+	// as "Backups/Laptop", i-saw-that if empty.
+	Folder string `json:"folder,omitempty" yaml:"folder,omitempty" toml:"folder,omitempty"`
+	// B2 bucket and the application key it is reached with, a key restricted to the
+	// bucket is enough.
+	Bucket         string `json:"bucket,omitempty" yaml:"bucket,omitempty" toml:"bucket,omitempty"`
+	KeyID          string `json:"key_id,omitempty" yaml:"key_id,omitempty" toml:"key_id,omitempty"`
+	ApplicationKey string `json:"application_key,omitempty" yaml:"application_key,omitempty" toml:"application_key,omitempty"`
+}
+
+func (c RemoteConfig) enabled() bool {
+	return c.Type != ""
+}
+
+func (c RemoteConfig) validate() error {
+	switch c.Type {
+	case "":
+		if c != (RemoteConfig{}) {
+			return fmt.Errorf("remote settings require a remote type")
+		}
+		return nil
+	case RemoteWebDAV:
+		if c.ClientID != "" || c.ClientSecret != "" || c.Folder != "" || c.usesKey() {
+			return fmt.Errorf("webdav remotes take the folder from the URL and do not use a client or key")
+		}
+		_, err := parseWebDAVURL(c.URL)
+		return err
+	case RemoteGoogleDrive, RemoteOneDrive:
+		if c.ClientID == "" {
+			return fmt.Errorf("%s remote requires a client ID", c.Type)
+		}
+		if c.URL != "" || c.User != "" || c.Password != "" || c.usesKey() {
+			return fmt.Errorf("%s remotes sign in with remote-login instead of a URL, user, password or key", c.Type)
+		}
+		return c.validateFolder()
+	case RemoteB2:
+		if c.Bucket == "" || c.KeyID == "" || c.ApplicationKey == "" {
+			return fmt.Errorf("b2 remote requires a bucket, key ID and application key")
+		}
+		if c.URL != "" || c.User != "" || c.Password != "" || c.ClientID != "" || c.ClientSecret != "" {
+			return fmt.Errorf("b2 remotes sign in with an application key instead of a URL, user, password or client")
+		}
+		return c.validateFolder()
+	}
+	return fmt.Errorf("unsupported remote type: %s", c.Type)
+}
+
+// usesKey returns true if any of the B2 settings are set.
+func (c RemoteConfig) usesKey() bool {
+	return c.Bucket != "" || c.KeyID != "" || c.ApplicationKey != ""
+}
+
+func (c RemoteConfig) validateFolder() error {
+	for _, part := range strings.Split(c.remoteFolder(), "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("remote folder must be a relative path without empty, '.' or '..' folders")
+		}
+	}
+	return nil
+}
+
+// remoteFolder returns the folder in the drive or bucket the copies are uploaded to.
+func (c RemoteConfig) remoteFolder() string {
+	if c.Folder == "" {
+		return defaultRemoteFolder
+	}
+	return strings.Trim(filepath.ToSlash(c.Folder), "/")
+}
+
+// validateRemote checks that the backups of a folder pair can be uploaded to its remote.
+func validateRemote(config *WatcherConfig) error {
+	if err := config.Remote.validate(); err != nil {
+		return err
+	}
+	if config.Remote.enabled() && config.Restic.enabled() {
+		return fmt.Errorf("a remote can not be combined with a restic repository")
+	}
+	return nil
+}
+
+// RemoteObject is a file stored on a remote.
+type RemoteObject struct {
+	// Path of the object, separated by slashes.
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// RemoteBackend stores objects by key, keys are paths separated by slashes.
+type RemoteBackend interface {
+	Put(key string, r io.ReaderAt, size int64) error
+	// Get returns the contents of an object, ErrRemoteNotFound if there is none.
+	Get(key string) (io.ReadCloser, error)
+	// List returns every object below prefix, nothing if there are none.
+	List(prefix string) ([]RemoteObject, error)
+	// Delete removes an object, or on storage with folders a folder and everything in
+	// it. ErrRemoteNotFound is returned if there is nothing to remove.
+	Delete(key string) error
+}
+
+// remoteHTTPError describes a failed request to a remote, including the start of the
+// message the server sent unless it is XML.
+func remoteHTTPError(method string, target *url.URL, response *http.Response) error {
+	if response.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", method, target.Path, ErrRemoteNotFound)
+	}
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+	if text := strings.TrimSpace(string(message)); text != "" && !strings.HasPrefix(text, "<") {
+		return fmt.Errorf("%s %s: %s: %s", method, target.Path, response.Status, text)
+	}
+	return fmt.Errorf("%s %s: %s", method, target.Path, response.Status)
+}
+
+// newRemoteBackend returns the backend of a remote, the drives sign in with the token
+// of the watcher in its keychain.
+func (w *Watcher) newRemoteBackend(config RemoteConfig) (RemoteBackend, error) {
+	config, err := w.resolveRemote(config)
+	if err != nil {
+		return nil, err
+	}
+	switch config.Type {
+	case RemoteWebDAV:
+		return newWebDAVBackend(config)
+	case RemoteGoogleDrive:
+		client := newOAuthClient(oauthProviders[config.Type], config, w.keychain, remoteTokenName(config.Type, w.Name))
+		return newGoogleDriveBackend(config, client, googleDriveAPI, googleDriveUploadAPI), nil
+	case RemoteOneDrive:
+		client := newOAuthClient(oauthProviders[config.Type], config, w.keychain, remoteTokenName(config.Type, w.Name))
+		return newOneDriveBackend(config, client, oneDriveAPI), nil
+	case RemoteB2:
+		return newB2Backend(config, b2AuthorizeURL), nil
+	}
+	return nil, fmt.Errorf("unsupported remote type: %s", config.Type)
+}
+
+// uploadChunkSize returns the size of the chunks files are uploaded in, rounded up to
+// a multiple of the unit the service needs. 0 uploads files in a single request.
+func uploadChunkSize(configured, unit int64) int64 {
+	if configured < 0 {
+		return 0
+	}
+	if configured == 0 {
+		configured = defaultRemoteChunkSize
+	}
+	return (configured + unit - 1) / unit * unit
+}
+
+// remotePrefix returns the key the remote copies of the watcher are stored below.
+func (w *Watcher) remotePrefix() string {
+	return safeName(w.Name)
+}
+
+// remoteMetadataKey is the key of the list of backups that have a remote copy, so the
+// copies can be found without the destination.
+func (w *Watcher) remoteMetadataKey() string {
+	return w.remotePrefix() + "/metadata.json"
+}
+
+// remoteBackupKey returns the key the files of a backup are stored below.
+func (w *Watcher) remoteBackupKey(backup Backup) string {
+	return w.remotePrefix() + "/backups/" + path.Clean(filepath.ToSlash(backup.Path))
+}
+
+// requestRemoteUpload asks the remote thread to upload the backups without a remote
+// copy, this never blocks.
+func (w *Watcher) requestRemoteUpload() {
+	select {
+	case w.remoteRequestChan <- struct{}{}:
+	default:
+	}
+}
+
+// remoteLoop uploads the backups without a remote copy whenever it is asked to, and
+// tries again after a while when an upload fails.
+func (w *Watcher) remoteLoop(config RemoteConfig, stop chan struct{}) {
+	backend, err := w.newRemoteBackend(config)
+	if err != nil {
+		Logf(w.Name, LogLevelError, "Error connecting to remote: %v", err)
+		return
+	}
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			return
+		case <-w.remoteRequestChan:
+		case <-retry:
+		}
+		retry = nil
+		if err := w.uploadPending(backend, stop); err != nil {
+			Logf(w.Name, LogLevelWarn, "Error uploading to remote, trying again in %v: %v", w.remoteRetryInterval, err)
+			retry = time.After(w.remoteRetryInterval)
+		}
+	}
+}
+
+// UploadRemoteCopies uploads every backup that does not have a remote copy yet.
+func (w *Watcher) UploadRemoteCopies() error {
+	w.mu.Lock()
+	config := w.Remote
+	w.mu.Unlock()
+	if !config.enabled() {
+		return errors.New("no remote is configured")
+	}
+	backend, err := w.newRemoteBackend(config)
+	if err != nil {
+		return err
+	}
+	return w.uploadPending(backend, nil)
+}
+
+// uploadPending uploads the backups without a remote copy from oldest to newest, it
+// stops between files once stop is closed.
+func (w *Watcher) uploadPending(backend RemoteBackend, stop chan struct{}) error {
+	w.mu.Lock()
+	pending := []Backup{}
+	for _, backup := range w.Metadata {
+		if !backup.RemoteCopy && backup.ResticSnapshot == "" && backup.Snapshot != SnapshotModeZFS {
+			pending = append(pending, backup)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, backup := range pending {
+		if err := w.uploadBackup(backend, backup, stop); err != nil {
+			return fmt.Errorf("error uploading backup %s: %w", backup.Path, err)
+		}
+		if isStopped(stop) {
+			return nil
+		}
+
+		backup.RemoteCopy = true
+		if err := w.updateRemoteMetadata(backend, []Backup{backup}, nil); err != nil {
+			return err
+		}
+		w.mu.Lock()
+		path := w.BackupPath(backup)
+		found := false
+		for i := range w.Metadata {
+			if w.BackupPath(w.Metadata[i]) == path {
+				w.Metadata[i].RemoteCopy = true
+				found = true
+			}
+		}
+		w.mu.Unlock()
+		if !found {
+			// The backup was pruned while it was uploaded.
+			if err := w.deleteRemoteCopies(backend, []Backup{backup}); err != nil {
+				Logf(w.Name, LogLevelWarn, "%v", err)
+			}
+			continue
+		}
+		if err := w.saveMetadata(backup.Destination); err != nil {
+			return err
+		}
+		Logf(w.Name, LogLevelInfo, "Uploaded backup %s to remote", backup.Path)
+	}
+	return nil
+}
+
+// isStopped returns true once stop is closed, a nil channel is never closed.
+func isStopped(stop chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// uploadBackup uploads the files of a backup. Files that are already on the remote with
+// the same size are skipped, so an interrupted upload continues where it stopped.
+func (w *Watcher) uploadBackup(backend RemoteBackend, backup Backup, stop chan struct{}) error {
+	root := w.BackupPath(backup)
+	prefix := w.remoteBackupKey(backup)
+	objects, err := backend.List(prefix)
+	if err != nil {
+		return err
+	}
+	uploaded := map[string]int64{}
+	for _, object := range objects {
+		uploaded[object.Key] = object.Size
+	}
+
+	return filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if isStopped(stop) {
+			return filepath.SkipAll
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		key := path.Join(prefix, filepath.ToSlash(rel))
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if size, ok := uploaded[key]; ok && size == info.Size() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return backend.Put(key, f, info.Size())
+	})
+}
+
+// RemoteBackups returns the backups that have a copy on the remote, read from the
+// remote itself so it includes copies of backups that are gone from the destination.
+func (w *Watcher) RemoteBackups() ([]Backup, error) {
+	w.mu.Lock()
+	config := w.Remote
+	w.mu.Unlock()
+	if !config.enabled() {
+		return nil, errors.New("no remote is configured")
+	}
+	backend, err := w.newRemoteBackend(config)
+	if err != nil {
+		return nil, err
+	}
+	return w.loadRemoteMetadata(backend)
+}
+
+func (w *Watcher) loadRemoteMetadata(backend RemoteBackend) ([]Backup, error) {
+	reader, err := backend.Get(w.remoteMetadataKey())
+	if errors.Is(err, ErrRemoteNotFound) {
+		return []Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading remote metadata: %w", err)
+	}
+	backups, err := decodeMetadata(data)
+	if err != nil {
+		return nil, fmt.Errorf("error reading remote metadata: %w", err)
+	}
+	if backups == nil {
+		backups = []Backup{}
+	}
+	return backups, nil
+}
+
+// updateRemoteMetadata adds and removes backups from the list of backups with a remote
+// copy.
+func (w *Watcher) updateRemoteMetadata(backend RemoteBackend, added, removed []Backup) error {
+	w.remoteMu.Lock()
+	defer w.remoteMu.Unlock()
+	backups, err := w.loadRemoteMetadata(backend)
+	if err != nil {
+		return err
+	}
+	for _, backup := range append(slices.Clone(added), removed...) {
+		backups = slices.DeleteFunc(backups, func(other Backup) bool {
+			return other.Path == backup.Path
+		})
+	}
+	for _, backup := range added {
+		// The destination is a local path that means nothing to other machines.
+		backup.Destination = ""
+		backups = append(backups, backup)
+	}
+	slices.SortStableFunc(backups, func(a, b Backup) int {
+		return BackupTime(a).Compare(BackupTime(b))
+	})
+
+	data, err := encodeMetadata(backups)
+	if err != nil {
+		return fmt.Errorf("error marshaling remote metadata: %w", err)
+	}
+	if err := backend.Put(w.remoteMetadataKey(), bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("error writing remote metadata: %w", err)
+	}
+	return nil
+}
+
+// deleteRemoteCopies deletes the remote copies of backups and removes them from the
+// remote metadata.
+func (w *Watcher) deleteRemoteCopies(backend RemoteBackend, backups []Backup) error {
+	var errs error
+	deleted := []Backup{}
+	for _, backup := range backups {
+		if err := w.deleteRemoteCopy(backend, backup); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error deleting remote copy of %s: %w", backup.Path, err))
+			continue
+		}
+		deleted = append(deleted, backup)
+	}
+	if len(deleted) > 0 {
+		errs = errors.Join(errs, w.updateRemoteMetadata(backend, nil, deleted))
+	}
+	return errs
+}
+
+func (w *Watcher) deleteRemoteCopy(backend RemoteBackend, backup Backup) error {
+	prefix := w.remoteBackupKey(backup)
+	err := backend.Delete(prefix)
+	if !errors.Is(err, ErrRemoteNotFound) {
+		return err
+	}
+	// Storage without folders deletes the files one by one.
+	objects, err := backend.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := backend.Delete(object.Key); err != nil && !errors.Is(err, ErrRemoteNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneRemoteCopies deletes the remote copies of backups that were pruned.
+func (w *Watcher) pruneRemoteCopies(pruned []Backup) error {
+	w.mu.Lock()
+	config := w.Remote
+	w.mu.Unlock()
+	copies := slices.DeleteFunc(slices.Clone(pruned), func(backup Backup) bool {
+		return !backup.RemoteCopy
+	})
+	if !config.enabled() || len(copies) == 0 {
+		return nil
+	}
+	backend, err := w.newRemoteBackend(config)
+	if err != nil {
+		return err
+	}
+	return w.deleteRemoteCopies(backend, copies)
+}
@@ -0,0 +1,587 @@
+package watcher
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	b2AuthorizeURL = "https://api.backblazeb2.com/b2api/v2/b2_authorize_account"
+	// Most parts a large file can be uploaded in.
+	b2MaxParts = 10000
+)
+
+var errB2TokenExpired = errors.New("b2 token expired")
+
+// b2Backend stores objects as files in a Backblaze B2 bucket, named by the folder and
+// the key. B2 has no folders, a folder is the files whose names start with it.
+type b2Backend struct {
+	client         *http.Client
+	authorizeURL   string
+	keyID          string
+	applicationKey string
+	bucket         string
+	folder         string
+	chunkSize      int64
+
+	mu      sync.Mutex
+	session *b2Session
+	// Address a file can be uploaded to, it is only used by one upload at a time.
+	upload *b2UploadURL
+}
+
+// b2Session is what signing in to B2 with the application key returns.
+type b2Session struct {
+	token       string
+	apiURL      string
+	downloadURL string
+	minPartSize int64
+	bucketID    string
+	// lifecycle is true if a lifecycle rule of the bucket deletes hidden files and old
+	// versions below the folder, files are then hidden instead of deleted.
+	lifecycle bool
+}
+
+type b2UploadURL struct {
+	UploadURL          string `json:"uploadUrl"`
+	AuthorizationToken string `json:"authorizationToken"`
+}
+
+// b2File is a version of a file in B2, a file that is uploaded again gets a new version.
+type b2File struct {
+	FileID        string `json:"fileId"`
+	FileName      string `json:"fileName"`
+	ContentLength int64  `json:"contentLength"`
+	ContentSha1   string `json:"contentSha1"`
+	// "upload" for a file, "hide" for a marker that hides the versions before it and
+	// "start" for a large file that is not finished.
+	Action string `json:"action"`
+}
+
+// b2LifecycleRule hides and deletes the files whose names start with a prefix.
+type b2LifecycleRule struct {
+	FileNamePrefix            string `json:"fileNamePrefix"`
+	DaysFromUploadingToHiding *int   `json:"daysFromUploadingToHiding"`
+	DaysFromHidingToDeleting  *int   `json:"daysFromHidingToDeleting"`
+}
+
+func newB2Backend(config RemoteConfig, authorizeURL string) *b2Backend {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 2 * time.Minute
+	return &b2Backend{
+		client:         &http.Client{Transport: transport},
+		authorizeURL:   authorizeURL,
+		keyID:          config.KeyID,
+		applicationKey: config.ApplicationKey,
+		bucket:         config.Bucket,
+		folder:         config.remoteFolder(),
+		chunkSize:      uploadChunkSize(config.ChunkSize, 1),
+	}
+}
+
+// b2Decode reads the answer of B2 into v, or the error it sent.
+func b2Decode(response *http.Response, v any) error {
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusOK {
+		if v == nil {
+			return nil
+		}
+		if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+			return fmt.Errorf("invalid answer from b2: %w", err)
+		}
+		return nil
+	}
+	var failure struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(io.LimitReader(response.Body, 4096)).Decode(&failure)
+	message := response.Status
+	if failure.Message != "" {
+		message += ": " + failure.Message
+	}
+	switch {
+	case response.StatusCode == http.StatusNotFound || failure.Code == "file_not_present":
+		return fmt.Errorf("%s: %w", message, ErrRemoteNotFound)
+	case failure.Code == "expired_auth_token" || failure.Code == "bad_auth_token":
+		return fmt.Errorf("%s: %w", message, errB2TokenExpired)
+	}
+	return errors.New(message)
+}
+
+// b2Name escapes the name of a file for a header or URL, slashes are kept.
+func b2Name(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = strings.ReplaceAll(url.PathEscape(part), "+", "%2B")
+	}
+	return strings.Join(parts, "/")
+}
+
+func (b *b2Backend) fileName(key string) string {
+	return path.Join(b.folder, key)
+}
+
+func apiRequest(session *b2Session, name string, body any) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(http.MethodPost, session.apiURL+"/b2api/v2/"+name, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", session.token)
+	return request, nil
+}
+
+// connect signs in with the application key the first time it is needed and again when
+// renew is true, after the token expired. Lifecycle rules of the bucket that hide the
+// copies are refused, retention decides how long they are kept.
+func (b *b2Backend) connect(renew bool) (*b2Session, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.session != nil && !renew {
+		return b.session, nil
+	}
+
+	request, err := http.NewRequest(http.MethodGet, b.authorizeURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.SetBasicAuth(b.keyID, b.applicationKey)
+	response, err := b.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error signing in to b2: %w", err)
+	}
+	var account struct {
+		AccountID               string `json:"accountId"`
+		AuthorizationToken      string `json:"authorizationToken"`
+		APIURL                  string `json:"apiUrl"`
+		DownloadURL             string `json:"downloadUrl"`
+		AbsoluteMinimumPartSize int64  `json:"absoluteMinimumPartSize"`
+	}
+	if err := b2Decode(response, &account); err != nil {
+		return nil, fmt.Errorf("error signing in to b2: %w", err)
+	}
+	session := &b2Session{
+		token:       account.AuthorizationToken,
+		apiURL:      account.APIURL,
+		downloadURL: account.DownloadURL,
+		minPartSize: account.AbsoluteMinimumPartSize,
+	}
+
+	request, err = apiRequest(session, "b2_list_buckets", map[string]string{"accountId": account.AccountID, "bucketName": b.bucket})
+	if err != nil {
+		return nil, err
+	}
+	response, err = b.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	var buckets struct {
+		Buckets []struct {
+			BucketID       string            `json:"bucketId"`
+			LifecycleRules []b2LifecycleRule `json:"lifecycleRules"`
+		} `json:"buckets"`
+	}
+	if err := b2Decode(response, &buckets); err != nil {
+		return nil, fmt.Errorf("error reading b2 bucket %s: %w", b.bucket, err)
+	}
+	if len(buckets.Buckets) == 0 {
+		return nil, fmt.Errorf("b2 bucket %s does not exist or the application key can not list it", b.bucket)
+	}
+	session.bucketID = buckets.Buckets[0].BucketID
+	folder := b.folder + "/"
+	for _, rule := range buckets.Buckets[0].LifecycleRules {
+		covers := strings.HasPrefix(folder, rule.FileNamePrefix)
+		if hide := rule.DaysFromUploadingToHiding; hide != nil && (covers || strings.HasPrefix(rule.FileNamePrefix, folder)) {
+			return nil, fmt.Errorf("a lifecycle rule of b2 bucket %s hides files starting with %q %d days after they are uploaded, which would remove copies that retention keeps", b.bucket, rule.FileNamePrefix, *hide)
+		}
+		if rule.DaysFromHidingToDeleting != nil && covers {
+			session.lifecycle = true
+		}
+	}
+	b.session = session
+	b.upload = nil
+	return session, nil
+}
+
+// send sends a request made with the token of the session, it signs in again once if
+// the token expired.
+func (b *b2Backend) send(newRequest func(session *b2Session) (*http.Request, error)) (*http.Response, error) {
+	for renew := false; ; renew = true {
+		session, err := b.connect(renew)
+		if err != nil {
+			return nil, err
+		}
+		request, err := newRequest(session)
+		if err != nil {
+			return nil, err
+		}
+		response, err := b.client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusUnauthorized || renew {
+			return response, nil
+		}
+		response.Body.Close()
+	}
+}
+
+// call calls a function of the B2 API.
+func (b *b2Backend) call(name string, body, out any) error {
+	response, err := b.send(func(session *b2Session) (*http.Request, error) {
+		return apiRequest(session, name, body)
+	})
+	if err != nil {
+		return err
+	}
+	if err := b2Decode(response, out); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// sha1Hex returns the checksum of a part of a file that B2 checks uploads with.
+func sha1Hex(r io.ReaderAt, offset, length int64) (string, error) {
+	hash := sha1.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(r, offset, length)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// uploadPart sends a file or a part of a large file. A new address is asked for once if
+// the token of the address expired.
+func (b *b2Backend) uploadPart(target func() (b2UploadURL, error), headers map[string]string, r io.ReaderAt, offset, length int64, v any) error {
+	for attempt := 0; ; attempt++ {
+		address, err := target()
+		if err != nil {
+			return err
+		}
+		request, err := http.NewRequest(http.MethodPost, address.UploadURL, io.NewSectionReader(r, offset, length))
+		if err != nil {
+			return err
+		}
+		request.ContentLength = length
+		request.Header.Set("Authorization", address.AuthorizationToken)
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		response, err := b.client.Do(request)
+		if err != nil {
+			return err
+		}
+		err = b2Decode(response, v)
+		if !errors.Is(err, errB2TokenExpired) || attempt > 0 {
+			return err
+		}
+	}
+}
+
+// uploadURL returns an address to upload a file to, the address of an earlier upload is
+// reused if it succeeded.
+func (b *b2Backend) uploadURL() (b2UploadURL, error) {
+	b.mu.Lock()
+	address := b.upload
+	b.upload = nil
+	b.mu.Unlock()
+	if address != nil {
+		return *address, nil
+	}
+	session, err := b.connect(false)
+	if err != nil {
+		return b2UploadURL{}, err
+	}
+	var fresh b2UploadURL
+	err = b.call("b2_get_upload_url", map[string]string{"bucketId": session.bucketID}, &fresh)
+	return fresh, err
+}
+
+// Put uploads a file in one request if it fits in a chunk and as a large file in parts
+// otherwise. B2 keeps every version of a file, older versions are deleted unless a
+// lifecycle rule of the bucket deletes them.
+func (b *b2Backend) Put(key string, r io.ReaderAt, size int64) error {
+	session, err := b.connect(false)
+	if err != nil {
+		return err
+	}
+	name := b.fileName(key)
+	partSize := b.chunkSize
+	if partSize > 0 {
+		partSize = max(partSize, session.minPartSize, (size+b2MaxParts-1)/b2MaxParts)
+	}
+	var file b2File
+	if partSize <= 0 || size <= partSize {
+		file, err = b.uploadFile(name, r, size)
+	} else {
+		file, err = b.uploadLargeFile(session, name, r, size, partSize)
+	}
+	if err != nil {
+		return fmt.Errorf("error uploading %s to b2: %w", name, err)
+	}
+	if session.lifecycle {
+		return nil
+	}
+	versions, err := b.versions(name)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, version := range versions {
+		if version.FileID != file.FileID && version.Action != "start" {
+			errs = errors.Join(errs, b.deleteVersion(version))
+		}
+	}
+	return errs
+}
+
+func (b *b2Backend) uploadFile(name string, r io.ReaderAt, size int64) (b2File, error) {
+	hash, err := sha1Hex(r, 0, size)
+	if err != nil {
+		return b2File{}, err
+	}
+	headers := map[string]string{
+		"X-Bz-File-Name":    b2Name(name),
+		"Content-Type":      "b2/x-auto",
+		"X-Bz-Content-Sha1": hash,
+	}
+	var address b2UploadURL
+	target := func() (b2UploadURL, error) {
+		var err error
+		address, err = b.uploadURL()
+		return address, err
+	}
+	var file b2File
+	if err := b.uploadPart(target, headers, r, 0, size, &file); err != nil {
+		return b2File{}, err
+	}
+	b.mu.Lock()
+	b.upload = &address
+	b.mu.Unlock()
+	return file, nil
+}
+
+// uploadLargeFile uploads a file in parts. The parts of an earlier upload of the same
+// file that did not finish are kept, so only the missing parts are sent.
+func (b *b2Backend) uploadLargeFile(session *b2Session, name string, r io.ReaderAt, size, partSize int64) (b2File, error) {
+	hashes := []string{}
+	for offset := int64(0); offset < size; offset += partSize {
+		hash, err := sha1Hex(r, offset, min(partSize, size-offset))
+		if err != nil {
+			return b2File{}, err
+		}
+		hashes = append(hashes, hash)
+	}
+	file, uploaded, err := b.unfinishedLargeFile(session, name, hashes)
+	if err != nil {
+		return b2File{}, err
+	}
+	if file.FileID == "" {
+		body := map[string]string{"bucketId": session.bucketID, "fileName": name, "contentType": "b2/x-auto"}
+		if err := b.call("b2_start_large_file", body, &file); err != nil {
+			return b2File{}, err
+		}
+	}
+
+	var address *b2UploadURL
+	target := func() (b2UploadURL, error) {
+		if address == nil {
+			address = &b2UploadURL{}
+			if err := b.call("b2_get_upload_part_url", map[string]string{"fileId": file.FileID}, address); err != nil {
+				address = nil
+				return b2UploadURL{}, err
+			}
+		}
+		return *address, nil
+	}
+	for i, hash := range hashes {
+		if uploaded[i+1] {
+			continue
+		}
+		offset := int64(i) * partSize
+		headers := map[string]string{"X-Bz-Part-Number": strconv.Itoa(i + 1), "X-Bz-Content-Sha1": hash}
+		if err := b.uploadPart(target, headers, r, offset, min(partSize, size-offset), nil); err != nil {
+			return b2File{}, err
+		}
+	}
+	body := map[string]any{"fileId": file.FileID, "partSha1Array": hashes}
+	if err := b.call("b2_finish_large_file", body, &file); err != nil {
+		return b2File{}, err
+	}
+	return file, nil
+}
+
+// unfinishedLargeFile returns a large file of the same name that did not finish and the
+// numbers of its parts. Unfinished files with parts that do not match are cancelled.
+func (b *b2Backend) unfinishedLargeFile(session *b2Session, name string, hashes []string) (b2File, map[int]bool, error) {
+	var unfinished struct {
+		Files []b2File `json:"files"`
+	}
+	body := map[string]any{"bucketId": session.bucketID, "namePrefix": name, "maxFileCount": 100}
+	if err := b.call("b2_list_unfinished_large_files", body, &unfinished); err != nil {
+		return b2File{}, nil, err
+	}
+	for _, file := range unfinished.Files {
+		if file.FileName != name {
+			continue
+		}
+		uploaded, matches := map[int]bool{}, true
+		body := map[string]any{"fileId": file.FileID, "maxPartCount": 1000}
+		for matches {
+			var parts struct {
+				Parts []struct {
+					PartNumber  int    `json:"partNumber"`
+					ContentSha1 string `json:"contentSha1"`
+				} `json:"parts"`
+				NextPartNumber *int `json:"nextPartNumber"`
+			}
+			if err := b.call("b2_list_parts", body, &parts); err != nil {
+				return b2File{}, nil, err
+			}
+			for _, part := range parts.Parts {
+				if part.PartNumber > len(hashes) || hashes[part.PartNumber-1] != part.ContentSha1 {
+					matches = false
+				}
+				uploaded[part.PartNumber] = true
+			}
+			if parts.NextPartNumber == nil {
+				break
+			}
+			body["startPartNumber"] = *parts.NextPartNumber
+		}
+		if matches {
+			return file, uploaded, nil
+		}
+		if err := b.call("b2_cancel_large_file", map[string]string{"fileId": file.FileID}, nil); err != nil {
+			return b2File{}, nil, err
+		}
+	}
+	return b2File{}, nil, nil
+}
+
+// versions returns the versions of a file from newest to oldest.
+func (b *b2Backend) versions(name string) ([]b2File, error) {
+	session, err := b.connect(false)
+	if err != nil {
+		return nil, err
+	}
+	versions := []b2File{}
+	body := map[string]any{"bucketId": session.bucketID, "startFileName": name, "prefix": name, "maxFileCount": 1000}
+	for {
+		var page struct {
+			Files        []b2File `json:"files"`
+			NextFileName *string  `json:"nextFileName"`
+			NextFileID   *string  `json:"nextFileId"`
+		}
+		if err := b.call("b2_list_file_versions", body, &page); err != nil {
+			return nil, err
+		}
+		for _, file := range page.Files {
+			if file.FileName == name {
+				versions = append(versions, file)
+			}
+		}
+		if page.NextFileName == nil || *page.NextFileName != name {
+			return versions, nil
+		}
+		body["startFileName"], body["startFileId"] = *page.NextFileName, *page.NextFileID
+	}
+}
+
+func (b *b2Backend) deleteVersion(file b2File) error {
+	err := b.call("b2_delete_file_version", map[string]string{"fileName": file.FileName, "fileId": file.FileID}, nil)
+	if errors.Is(err, ErrRemoteNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (b *b2Backend) Get(key string) (io.ReadCloser, error) {
+	response, err := b.send(func(session *b2Session) (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodGet, session.downloadURL+"/file/"+url.PathEscape(b.bucket)+"/"+b2Name(b.fileName(key)), nil)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Authorization", session.token)
+		return request, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading %s from b2: %w", key, b2Decode(response, nil))
+	}
+	return response.Body, nil
+}
+
+func (b *b2Backend) List(prefix string) ([]RemoteObject, error) {
+	session, err := b.connect(false)
+	if err != nil {
+		return nil, err
+	}
+	objects := []RemoteObject{}
+	body := map[string]any{"bucketId": session.bucketID, "prefix": b.fileName(prefix) + "/", "maxFileCount": 1000}
+	for {
+		var page struct {
+			Files        []b2File `json:"files"`
+			NextFileName *string  `json:"nextFileName"`
+		}
+		if err := b.call("b2_list_file_names", body, &page); err != nil {
+			return nil, err
+		}
+		for _, file := range page.Files {
+			if file.Action == "upload" {
+				objects = append(objects, RemoteObject{Key: strings.TrimPrefix(file.FileName, b.folder+"/"), Size: file.ContentLength})
+			}
+		}
+		if page.NextFileName == nil {
+			return objects, nil
+		}
+		body["startFileName"] = *page.NextFileName
+	}
+}
+
+// Delete removes a file. With a lifecycle rule that deletes hidden files the file is
+// only hidden and B2 deletes it later, otherwise every version is deleted.
+func (b *b2Backend) Delete(key string) error {
+	name := b.fileName(key)
+	versions, err := b.versions(name)
+	if err != nil {
+		return err
+	}
+	// Versions that are hidden or large files that did not finish are not a file.
+	latest := slices.IndexFunc(versions, func(file b2File) bool { return file.Action != "start" })
+	if latest < 0 || versions[latest].Action != "upload" {
+		return fmt.Errorf("%s: %w", key, ErrRemoteNotFound)
+	}
+	session, err := b.connect(false)
+	if err != nil {
+		return err
+	}
+	if session.lifecycle {
+		return b.call("b2_hide_file", map[string]string{"bucketId": session.bucketID, "fileName": name}, nil)
+	}
+	var errs error
+	for _, version := range versions {
+		if version.Action != "start" {
+			errs = errors.Join(errs, b.deleteVersion(version))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,415 @@
+package watcher
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeB2Version is a version of a file in fakeB2, or a large file that is not finished.
+type fakeB2Version struct {
+	b2File
+	data  []byte
+	parts map[int][]byte
+}
+
+// fakeB2 serves the parts of the B2 API that the backend uses from memory, with a single
+// bucket called "bucket".
+type fakeB2 struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// Versions from oldest to newest.
+	versions []*fakeB2Version
+	rules    []b2LifecycleRule
+	token    string
+	nextID   int
+	// Number of part uploads that fail before the next one succeeds.
+	failParts int
+	// Calls by the name of the function.
+	calls map[string]int
+}
+
+func newFakeB2(t *testing.T) *fakeB2 {
+	t.Helper()
+	b := &fakeB2{token: "token0", calls: map[string]int{}}
+	b.Server = httptest.NewServer(http.HandlerFunc(b.serve))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func (b *fakeB2) newID() string {
+	b.nextID++
+	return "file" + strconv.Itoa(b.nextID)
+}
+
+// expireToken makes the token that was handed out stop working.
+func (b *fakeB2) expireToken() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.token += "x"
+}
+
+func (b *fakeB2) count(name string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls[name]
+}
+
+// stored returns the versions of a file from newest to oldest.
+func (b *fakeB2) stored(name string) []fakeB2Version {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	versions := []fakeB2Version{}
+	for _, version := range slices.Backward(b.versions) {
+		if version.FileName == name {
+			versions = append(versions, *version)
+		}
+	}
+	return versions
+}
+
+func b2Fail(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"status": status, "code": code, "message": code})
+}
+
+func (b *fakeB2) serve(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.URL.Path == "/b2api/v2/b2_authorize_account" {
+		if user, password, _ := r.BasicAuth(); user != "key" || password != "secret" {
+			b2Fail(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"accountId": "account", "authorizationToken": b.token, "apiUrl": b.URL,
+			"downloadUrl": b.URL, "absoluteMinimumPartSize": 1000,
+		})
+		return
+	}
+	if r.Header.Get("Authorization") != b.token {
+		b2Fail(w, http.StatusUnauthorized, "expired_auth_token")
+		return
+	}
+	if name, ok := strings.CutPrefix(r.URL.Path, "/file/bucket/"); ok {
+		b.download(w, name)
+		return
+	}
+	if id, ok := strings.CutPrefix(r.URL.Path, "/upload_part/"); ok {
+		b.uploadPart(w, r, id)
+		return
+	}
+	if r.URL.Path == "/upload" {
+		b.upload(w, r)
+		return
+	}
+	function := strings.TrimPrefix(r.URL.Path, "/b2api/v2/")
+	b.calls[function]++
+	var body struct {
+		BucketName      string   `json:"bucketName"`
+		FileName        string   `json:"fileName"`
+		FileID          string   `json:"fileId"`
+		Prefix          string   `json:"prefix"`
+		NamePrefix      string   `json:"namePrefix"`
+		StartFileName   string   `json:"startFileName"`
+		MaxFileCount    int      `json:"maxFileCount"`
+		PartSha1Array   []string `json:"partSha1Array"`
+		StartPartNumber int      `json:"startPartNumber"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	encode := json.NewEncoder(w).Encode
+	switch function {
+	case "b2_list_buckets":
+		encode(map[string]any{"buckets": []any{map[string]any{"bucketId": "bucket-id", "bucketName": "bucket", "lifecycleRules": b.rules}}})
+	case "b2_get_upload_url":
+		encode(b2UploadURL{UploadURL: b.URL + "/upload", AuthorizationToken: b.token})
+	case "b2_get_upload_part_url":
+		encode(b2UploadURL{UploadURL: b.URL + "/upload_part/" + body.FileID, AuthorizationToken: b.token})
+	case "b2_list_file_names":
+		// Only the newest version of each file that is not hidden, two at a time.
+		latest := map[string]*fakeB2Version{}
+		for _, version := range b.versions {
+			if version.Action != "start" && strings.HasPrefix(version.FileName, body.Prefix) && version.FileName >= body.StartFileName {
+				latest[version.FileName] = version
+			}
+		}
+		names := []string{}
+		for name, version := range latest {
+			if version.Action == "upload" {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		page := map[string]any{"nextFileName": nil}
+		if len(names) > 2 {
+			page["nextFileName"] = names[2]
+			names = names[:2]
+		}
+		files := []b2File{}
+		for _, name := range names {
+			files = append(files, latest[name].b2File)
+		}
+		page["files"] = files
+		encode(page)
+	case "b2_list_file_versions":
+		files := []b2File{}
+		for _, version := range slices.Backward(b.versions) {
+			if strings.HasPrefix(version.FileName, body.Prefix) {
+				files = append(files, version.b2File)
+			}
+		}
+		slices.SortStableFunc(files, func(a, b b2File) int { return strings.Compare(a.FileName, b.FileName) })
+		encode(map[string]any{"files": files, "nextFileName": nil})
+	case "b2_delete_file_version":
+		i := slices.IndexFunc(b.versions, func(v *fakeB2Version) bool { return v.FileID == body.FileID && v.FileName == body.FileName })
+		if i < 0 {
+			b2Fail(w, http.StatusBadRequest, "file_not_present")
+			return
+		}
+		b.versions = slices.Delete(b.versions, i, i+1)
+		encode(map[string]any{})
+	case "b2_hide_file":
+		b.versions = append(b.versions, &fakeB2Version{b2File: b2File{FileID: b.newID(), FileName: body.FileName, Action: "hide"}})
+		encode(map[string]any{})
+	case "b2_start_large_file":
+		version := &fakeB2Version{b2File: b2File{FileID: b.newID(), FileName: body.FileName, Action: "start"}, parts: map[int][]byte{}}
+		b.versions = append(b.versions, version)
+		encode(version.b2File)
+	case "b2_list_unfinished_large_files":
+		files := []b2File{}
+		for _, version := range b.versions {
+			if version.Action == "start" && strings.HasPrefix(version.FileName, body.NamePrefix) {
+				files = append(files, version.b2File)
+			}
+		}
+		encode(map[string]any{"files": files})
+	case "b2_list_parts", "b2_finish_large_file", "b2_cancel_large_file":
+		i := slices.IndexFunc(b.versions, func(v *fakeB2Version) bool { return v.FileID == body.FileID && v.Action == "start" })
+		if i < 0 {
+			b2Fail(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		version := b.versions[i]
+		switch function {
+		case "b2_list_parts":
+			parts := []map[string]any{}
+			for number, data := range version.parts {
+				sum := sha1.Sum(data)
+				parts = append(parts, map[string]any{"partNumber": number, "contentSha1": hex.EncodeToString(sum[:])})
+			}
+			encode(map[string]any{"parts": parts, "nextPartNumber": nil})
+		case "b2_finish_large_file":
+			if len(body.PartSha1Array) != len(version.parts) {
+				b2Fail(w, http.StatusBadRequest, "bad_request")
+				return
+			}
+			for number := 1; number <= len(version.parts); number++ {
+				version.data = append(version.data, version.parts[number]...)
+			}
+			version.Action, version.ContentLength, version.parts = "upload", int64(len(version.data)), nil
+			encode(version.b2File)
+		case "b2_cancel_large_file":
+			b.versions = slices.Delete(b.versions, i, i+1)
+			encode(map[string]any{})
+		}
+	default:
+		b2Fail(w, http.StatusBadRequest, "bad_request")
+	}
+}
+
+func (b *fakeB2) upload(w http.ResponseWriter, r *http.Request) {
+	b.calls["upload"]++
+	name, err := url.PathUnescape(r.Header.Get("X-Bz-File-Name"))
+	data, _ := io.ReadAll(r.Body)
+	sum := sha1.Sum(data)
+	if err != nil || hex.EncodeToString(sum[:]) != r.Header.Get("X-Bz-Content-Sha1") {
+		b2Fail(w, http.StatusBadRequest, "bad_request")
+		return
+	}
+	version := &fakeB2Version{b2File: b2File{FileID: b.newID(), FileName: name, ContentLength: int64(len(data)), Action: "upload"}, data: data}
+	b.versions = append(b.versions, version)
+	json.NewEncoder(w).Encode(version.b2File)
+}
+
+func (b *fakeB2) uploadPart(w http.ResponseWriter, r *http.Request, id string) {
+	b.calls["upload_part"]++
+	data, _ := io.ReadAll(r.Body)
+	sum := sha1.Sum(data)
+	number, _ := strconv.Atoi(r.Header.Get("X-Bz-Part-Number"))
+	i := slices.IndexFunc(b.versions, func(v *fakeB2Version) bool { return v.FileID == id && v.Action == "start" })
+	if i < 0 || hex.EncodeToString(sum[:]) != r.Header.Get("X-Bz-Content-Sha1") {
+		b2Fail(w, http.StatusBadRequest, "bad_request")
+		return
+	}
+	if b.failParts > 0 && len(b.versions[i].parts) > 0 {
+		b.failParts--
+		b2Fail(w, http.StatusServiceUnavailable, "service_unavailable")
+		return
+	}
+	b.versions[i].parts[number] = data
+	json.NewEncoder(w).Encode(map[string]any{})
+}
+
+func (b *fakeB2) download(w http.ResponseWriter, name string) {
+	for _, version := range slices.Backward(b.versions) {
+		if version.FileName != name || version.Action == "start" {
+			continue
+		}
+		if version.Action == "upload" {
+			w.Write(version.data)
+			return
+		}
+		break
+	}
+	b2Fail(w, http.StatusNotFound, "not_found")
+}
+
+func newTestB2Backend(server *fakeB2, config RemoteConfig) *b2Backend {
+	config.Type, config.Bucket, config.KeyID, config.ApplicationKey = RemoteB2, "bucket", "key", "secret"
+	return newB2Backend(config, server.URL+"/b2api/v2/b2_authorize_account")
+}
+
+func TestB2Backend(t *testing.T) {
+	t.Parallel()
+	server := newFakeB2(t)
+	backend := newTestB2Backend(server, RemoteConfig{Folder: "Backups/Laptop", ChunkSize: 1})
+
+	small := []byte("a+b c")
+	large := createRandomFileContent(2500)
+	files := map[string][]byte{"a/a+b c.txt": small, "a/b/large.bin": large, "a/c": small, "a/d": {}}
+	for key, data := range files {
+		if err := backend.Put(key, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("Failed to put %s: %v", key, err)
+		}
+		if got := readRemote(t, backend, key); !bytes.Equal(got, data) {
+			t.Errorf("Expected %s to match what was put", key)
+		}
+	}
+	// Parts are at least as large as the minimum B2 allows.
+	if parts := server.count("upload_part"); parts != 3 {
+		t.Errorf("Expected the large file in 3 parts, got %d", parts)
+	}
+	// The upload address of a file is used again for the next one.
+	if addresses := server.count("b2_get_upload_url"); addresses != 1 {
+		t.Errorf("Expected 1 upload address, got %d", addresses)
+	}
+
+	// Without a lifecycle rule the old version is deleted when a file is put again.
+	server.expireToken()
+	if err := backend.Put("a/c", bytes.NewReader(large), int64(len(large))); err != nil {
+		t.Fatalf("Failed to replace file after the token expired: %v", err)
+	}
+	if versions := server.stored("Backups/Laptop/a/c"); len(versions) != 1 || !bytes.Equal(versions[0].data, large) {
+		t.Errorf("Expected only the new version to be kept, got %d versions", len(versions))
+	}
+	files["a/c"] = large
+
+	objects, err := backend.List("a")
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	expected := []RemoteObject{}
+	for key, data := range files {
+		expected = append(expected, RemoteObject{key, int64(len(data))})
+	}
+	slices.SortFunc(expected, func(a, b RemoteObject) int { return strings.Compare(a.Key, b.Key) })
+	if !slices.Equal(objects, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, objects)
+	}
+
+	// There are no folders, only files can be deleted.
+	if err := backend.Delete("a/b"); !errors.Is(err, ErrRemoteNotFound) {
+		t.Errorf("Expected deleting a folder to find nothing, got %v", err)
+	}
+	if err := backend.Delete("a/b/large.bin"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if versions := server.stored("Backups/Laptop/a/b/large.bin"); len(versions) != 0 {
+		t.Errorf("Expected every version to be deleted, got %d", len(versions))
+	}
+	if _, err := backend.Get("a/b/large.bin"); !errors.Is(err, ErrRemoteNotFound) {
+		t.Errorf("Expected the file to be deleted, got %v", err)
+	}
+	if err := backend.Delete("a/b/large.bin"); !errors.Is(err, ErrRemoteNotFound) {
+		t.Errorf("Expected deleting a missing file to fail, got %v", err)
+	}
+}
+
+func TestB2LargeFileResumes(t *testing.T) {
+	t.Parallel()
+	server := newFakeB2(t)
+	server.failParts = 1
+	backend := newTestB2Backend(server, RemoteConfig{ChunkSize: 1})
+	data := createRandomFileContent(4500)
+
+	if err := backend.Put("large.bin", bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Fatal("Expected the upload to fail")
+	}
+	if err := backend.Put("large.bin", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("Failed to resume upload: %v", err)
+	}
+	// The part that was uploaded before the failure is not sent again.
+	if parts := server.count("upload_part"); parts != 6 {
+		t.Errorf("Expected 5 parts and 1 failure, got %d uploads", parts)
+	}
+	if server.count("b2_start_large_file") != 1 {
+		t.Error("Expected the unfinished file to be used again")
+	}
+	if got := readRemote(t, backend, "large.bin"); !bytes.Equal(got, data) {
+		t.Error("Expected the file to match what was put")
+	}
+}
+
+func TestB2LifecycleRules(t *testing.T) {
+	t.Parallel()
+	days := 1
+
+	// A rule that deletes hidden files makes deleting a file hide it.
+	server := newFakeB2(t)
+	server.rules = []b2LifecycleRule{{FileNamePrefix: "", DaysFromHidingToDeleting: &days}}
+	backend := newTestB2Backend(server, RemoteConfig{})
+	for range 2 {
+		if err := backend.Put("file", bytes.NewReader([]byte("data")), 4); err != nil {
+			t.Fatalf("Failed to put file: %v", err)
+		}
+	}
+	if err := backend.Delete("file"); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	versions := server.stored("i-saw-that/file")
+	if len(versions) != 3 || versions[0].Action != "hide" {
+		t.Errorf("Expected the versions to be left for the lifecycle rule after a hide marker, got %+v", versions)
+	}
+	if _, err := backend.Get("file"); !errors.Is(err, ErrRemoteNotFound) {
+		t.Errorf("Expected the file to be hidden, got %v", err)
+	}
+	if err := backend.Delete("file"); !errors.Is(err, ErrRemoteNotFound) {
+		t.Errorf("Expected deleting a hidden file to find nothing, got %v", err)
+	}
+
+	// A rule for another folder is ignored, a rule that hides the copies is refused.
+	server = newFakeB2(t)
+	server.rules = []b2LifecycleRule{{FileNamePrefix: "other/", DaysFromHidingToDeleting: &days}}
+	backend = newTestB2Backend(server, RemoteConfig{})
+	if session, err := backend.connect(false); err != nil || session.lifecycle {
+		t.Errorf("Expected a rule of another folder to be ignored, got %+v: %v", session, err)
+	}
+	for _, prefix := range []string{"", "i-saw-that/", "i-saw-that/laptop/"} {
+		server.rules = []b2LifecycleRule{{FileNamePrefix: prefix, DaysFromUploadingToHiding: &days}}
+		if _, err := backend.connect(true); err == nil {
+			t.Errorf("Expected a rule hiding files starting with %q to be refused", prefix)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	googleDriveAPI       = "https://www.googleapis.com/drive/v3"
+	googleDriveUploadAPI = "https://www.googleapis.com/upload/drive/v3"
+	googleDriveFolder    = "application/vnd.google-apps.folder"
+	// Chunks of a resumable upload to Google Drive are multiples of this size.
+	googleDriveChunkUnit = 256 << 10
+)
+
+// googleDriveBackend stores objects as files below a folder in Google Drive. Drive finds
+// files by ID instead of by path, so the path of a key is looked up one folder at a
+// time.
+type googleDriveBackend struct {
+	client    *oauthClient
+	api       string
+	uploadAPI string
+	folder    string
+	chunkSize int64
+
+	mu sync.Mutex
+	// IDs of the folders that were looked up by their path in the drive.
+	folders map[string]string
+}
+
+// driveFile is a file or folder in Google Drive.
+type driveFile struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+	Size     int64  `json:"size,string"`
+}
+
+func newGoogleDriveBackend(config RemoteConfig, client *oauthClient, api, uploadAPI string) *googleDriveBackend {
+	return &googleDriveBackend{
+		client:    client,
+		api:       api,
+		uploadAPI: uploadAPI,
+		folder:    config.remoteFolder(),
+		chunkSize: uploadChunkSize(config.ChunkSize, googleDriveChunkUnit),
+		folders:   map[string]string{"": "root"},
+	}
+}
+
+// driveQuote quotes a value for a Drive query.
+func driveQuote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// list returns every file matching a Drive query.
+func (b *googleDriveBackend) list(query string) ([]driveFile, error) {
+	files := []driveFile{}
+	pageToken := ""
+	for {
+		params := url.Values{
+			"q":        {query + " and trashed = false"},
+			"fields":   {"nextPageToken,files(id,name,mimeType,size)"},
+			"pageSize": {"1000"},
+			"spaces":   {"drive"},
+		}
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+		response, err := b.client.do(jsonRequest(http.MethodGet, b.api+"/files?"+params.Encode(), nil), true)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			NextPageToken string      `json:"nextPageToken"`
+			Files         []driveFile `json:"files"`
+		}
+		if err := decodeJSON(response, &page, http.StatusOK); err != nil {
+			return nil, err
+		}
+		files = append(files, page.Files...)
+		if page.NextPageToken == "" {
+			return files, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// child returns the file called name in a folder, ErrRemoteNotFound if there is none.
+func (b *googleDriveBackend) child(parentID, name string) (driveFile, error) {
+	files, err := b.list(fmt.Sprintf("%s in parents and name = %s", driveQuote(parentID), driveQuote(name)))
+	if err != nil {
+		return driveFile{}, err
+	}
+	if len(files) == 0 {
+		return driveFile{}, fmt.Errorf("%s: %w", name, ErrRemoteNotFound)
+	}
+	return files[0], nil
+}
+
+// folderID returns the ID of a folder in the drive, the folder and its parents are
+// created if they are missing and create is true.
+func (b *googleDriveBackend) folderID(folder string, create bool) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id, current := "root", ""
+	for _, name := range strings.Split(folder, "/") {
+		current = path.Join(current, name)
+		if cached, ok := b.folders[current]; ok {
+			id = cached
+			continue
+		}
+		file, err := b.child(id, name)
+		if errors.Is(err, ErrRemoteNotFound) && create {
+			file, err = b.createFolder(id, name)
+		}
+		if err != nil {
+			return "", err
+		}
+		if file.MimeType != googleDriveFolder {
+			return "", fmt.Errorf("%s in Google Drive is a file, not a folder", current)
+		}
+		id = file.ID
+		b.folders[current] = id
+	}
+	return id, nil
+}
+
+func (b *googleDriveBackend) createFolder(parentID, name string) (driveFile, error) {
+	metadata := map[string]any{"name": name, "mimeType": googleDriveFolder, "parents": []string{parentID}}
+	response, err := b.client.do(jsonRequest(http.MethodPost, b.api+"/files?fields=id,name,mimeType", metadata), true)
+	if err != nil {
+		return driveFile{}, err
+	}
+	var file driveFile
+	return file, decodeJSON(response, &file, http.StatusOK)
+}
+
+// find returns the file or folder of a key.
+func (b *googleDriveBackend) find(key string) (driveFile, error) {
+	full := path.Join(b.folder, key)
+	parentID, err := b.folderID(path.Dir(full), false)
+	if err != nil {
+		return driveFile{}, err
+	}
+	return b.child(parentID, path.Base(full))
+}
+
+// Put uploads a file with a resumable upload, a file that is already there is
+// replaced so Drive keeps a single file of that name.
+func (b *googleDriveBackend) Put(key string, r io.ReaderAt, size int64) error {
+	full := path.Join(b.folder, key)
+	parentID, err := b.folderID(path.Dir(full), true)
+	if err != nil {
+		return err
+	}
+	existing, err := b.child(parentID, path.Base(full))
+	var start func() (*http.Request, error)
+	switch {
+	case errors.Is(err, ErrRemoteNotFound):
+		metadata := map[string]any{"name": path.Base(full), "parents": []string{parentID}}
+		start = jsonRequest(http.MethodPost, b.uploadAPI+"/files?uploadType=resumable", metadata)
+	case err != nil:
+		return err
+	default:
+		start = jsonRequest(http.MethodPatch, b.uploadAPI+"/files/"+url.PathEscape(existing.ID)+"?uploadType=resumable", map[string]any{})
+	}
+	response, err := b.client.do(start, true)
+	if err != nil {
+		return err
+	}
+	if err := decodeJSON(response, nil, http.StatusOK); err != nil {
+		return err
+	}
+	session := response.Header.Get("Location")
+	if session == "" {
+		return errors.New("google drive did not return an upload address")
+	}
+	return b.upload(session, r, size)
+}
+
+// upload sends the chunks of a resumable upload. Drive answers each chunk with the
+// bytes it has so far, which the next chunk starts after.
+func (b *googleDriveBackend) upload(session string, r io.ReaderAt, size int64) error {
+	chunkSize := b.chunkSize
+	if chunkSize <= 0 {
+		chunkSize = size
+	}
+	for offset := int64(0); ; {
+		length := min(chunkSize, size-offset)
+		response, err := b.client.do(rangeRequest(http.MethodPut, session, r, offset, length, size), true)
+		if err != nil {
+			return err
+		}
+		// 308 is "Resume Incomplete" for Drive.
+		if response.StatusCode != http.StatusPermanentRedirect {
+			return decodeJSON(response, nil, http.StatusOK, http.StatusCreated)
+		}
+		response.Body.Close()
+		received := int64(0)
+		if _, end, ok := strings.Cut(response.Header.Get("Range"), "-"); ok {
+			last, err := strconv.ParseInt(end, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid range from google drive: %w", err)
+			}
+			received = last + 1
+		}
+		if received <= offset && length > 0 {
+			return errors.New("google drive did not accept the chunk")
+		}
+		offset = received
+	}
+}
+
+func (b *googleDriveBackend) Get(key string) (io.ReadCloser, error) {
+	file, err := b.find(key)
+	if err != nil {
+		return nil, err
+	}
+	response, err := b.client.do(jsonRequest(http.MethodGet, b.api+"/files/"+url.PathEscape(file.ID)+"?alt=media", nil), true)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, decodeJSON(response, nil, http.StatusOK)
+	}
+	return response.Body, nil
+}
+
+func (b *googleDriveBackend) List(prefix string) ([]RemoteObject, error) {
+	type folder struct{ path, id string }
+	root := path.Join(b.folder, prefix)
+	id, err := b.folderID(root, false)
+	if errors.Is(err, ErrRemoteNotFound) {
+		return []RemoteObject{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []RemoteObject{}
+	folders := []folder{{root, id}}
+	for len(folders) > 0 {
+		current := folders[0]
+		folders = folders[1:]
+		files, err := b.list(driveQuote(current.id) + " in parents")
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			filePath := path.Join(current.path, file.Name)
+			if file.MimeType == googleDriveFolder {
+				folders = append(folders, folder{filePath, file.ID})
+				continue
+			}
+			objects = append(objects, RemoteObject{Key: strings.TrimPrefix(filePath, b.folder+"/"), Size: file.Size})
+		}
+	}
+	return objects, nil
+}
+
+func (b *googleDriveBackend) Delete(key string) error {
+	file, err := b.find(key)
+	if err != nil {
+		return err
+	}
+	response, err := b.client.do(jsonRequest(http.MethodDelete, b.api+"/files/"+url.PathEscape(file.ID), nil), true)
+	if err != nil {
+		return err
+	}
+	// Folders below a deleted folder have to be looked up again.
+	b.mu.Lock()
+	b.folders = map[string]string{"": "root"}
+	b.mu.Unlock()
+	return decodeJSON(response, nil, http.StatusNoContent, http.StatusOK)
+}
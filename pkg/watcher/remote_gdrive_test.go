@@ -0,0 +1,224 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDriveFile is a file or folder in fakeDrive.
+type fakeDriveFile struct {
+	driveFile
+	parent string
+	data   []byte
+}
+
+// fakeDrive serves the parts of the Google Drive API that the backend uses from
+// memory.
+type fakeDrive struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	files  map[string]*fakeDriveFile
+	nextID int
+	// Resumable uploads by their ID, with the file they create or replace.
+	sessions map[string]*fakeDriveFile
+	// Chunks that were uploaded.
+	chunks int
+}
+
+var driveQuery = regexp.MustCompile(`^'((?:[^'\\]|\\.)*)' in parents(?: and name = '((?:[^'\\]|\\.)*)')? and trashed = false$`)
+
+func newFakeDrive(t *testing.T) *fakeDrive {
+	t.Helper()
+	d := &fakeDrive{files: map[string]*fakeDriveFile{}, sessions: map[string]*fakeDriveFile{}}
+	d.Server = httptest.NewServer(http.HandlerFunc(d.serve))
+	t.Cleanup(d.Close)
+	return d
+}
+
+func (d *fakeDrive) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer access" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := strings.TrimPrefix(r.URL.Path, "/drive/v3/files/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/drive/v3/files":
+		match := driveQuery.FindStringSubmatch(r.URL.Query().Get("q"))
+		if match == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		unquote := strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace
+		files := []driveFile{}
+		for _, file := range d.files {
+			if file.parent == unquote(match[1]) && (match[2] == "" || file.Name == unquote(match[2])) {
+				files = append(files, file.driveFile)
+			}
+		}
+		slices.SortFunc(files, func(a, b driveFile) int { return strings.Compare(a.ID, b.ID) })
+		json.NewEncoder(w).Encode(map[string]any{"files": files})
+	case r.Method == http.MethodPost && r.URL.Path == "/drive/v3/files":
+		file := d.create(r)
+		json.NewEncoder(w).Encode(file.driveFile)
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/drive/v3/files":
+		d.startUpload(w, d.create(r))
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/upload/drive/v3/files/"):
+		file, ok := d.files[strings.TrimPrefix(r.URL.Path, "/upload/drive/v3/files/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		d.startUpload(w, file)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/session/"):
+		d.uploadChunk(w, r, d.sessions[strings.TrimPrefix(r.URL.Path, "/session/")])
+	case r.Method == http.MethodGet && d.files[id] != nil && r.URL.Query().Get("alt") == "media":
+		w.Write(d.files[id].data)
+	case r.Method == http.MethodDelete && d.files[id] != nil:
+		d.remove(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// create adds the file or folder described by the body of a request.
+func (d *fakeDrive) create(r *http.Request) *fakeDriveFile {
+	var metadata struct {
+		Name     string   `json:"name"`
+		MimeType string   `json:"mimeType"`
+		Parents  []string `json:"parents"`
+	}
+	json.NewDecoder(r.Body).Decode(&metadata)
+	d.nextID++
+	file := &fakeDriveFile{driveFile: driveFile{ID: "id" + strconv.Itoa(d.nextID), Name: metadata.Name, MimeType: metadata.MimeType}, parent: metadata.Parents[0]}
+	d.files[file.ID] = file
+	return file
+}
+
+func (d *fakeDrive) startUpload(w http.ResponseWriter, file *fakeDriveFile) {
+	id := strconv.Itoa(len(d.sessions))
+	d.sessions[id] = &fakeDriveFile{driveFile: file.driveFile, parent: file.parent}
+	w.Header().Set("Location", d.URL+"/session/"+id)
+	w.WriteHeader(http.StatusOK)
+}
+
+// uploadChunk adds a chunk to an upload, which replaces the file once it is complete.
+func (d *fakeDrive) uploadChunk(w http.ResponseWriter, r *http.Request, session *fakeDriveFile) {
+	var start, end, total int64
+	if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes */%d", &total); err != nil || total != 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	} else {
+		data, _ := io.ReadAll(r.Body)
+		if start != int64(len(session.data)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		session.data = append(session.data, data...)
+		d.chunks++
+	}
+	if int64(len(session.data)) < total {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(session.data)-1))
+		w.WriteHeader(http.StatusPermanentRedirect)
+		return
+	}
+	session.Size = total
+	file := d.files[session.ID]
+	file.data, file.Size = session.data, total
+	json.NewEncoder(w).Encode(file.driveFile)
+}
+
+func (d *fakeDrive) remove(id string) {
+	delete(d.files, id)
+	for childID, file := range d.files {
+		if file.parent == id {
+			d.remove(childID)
+		}
+	}
+}
+
+func (d *fakeDrive) count(name string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	count := 0
+	for _, file := range d.files {
+		if file.Name == name {
+			count++
+		}
+	}
+	return count
+}
+
+func (d *fakeDrive) uploadedChunks() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.chunks
+}
+
+func TestGoogleDriveBackend(t *testing.T) {
+	t.Parallel()
+	drive := newFakeDrive(t)
+	client, _ := newTestOAuthClient(t, drive.URL+"/token")
+	config := RemoteConfig{Type: RemoteGoogleDrive, ClientID: "client", Folder: "Backups/Laptop", ChunkSize: 1}
+	backend := newGoogleDriveBackend(config, client, drive.URL+"/drive/v3", drive.URL+"/upload/drive/v3")
+
+	small := []byte("it's small")
+	large := createRandomFileContent(2*googleDriveChunkUnit + 100)
+	for key, data := range map[string][]byte{"a/it's small.txt": small, "a/b/large.bin": large, "a/empty": {}} {
+		if err := backend.Put(key, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("Failed to put %s: %v", key, err)
+		}
+		if got := readRemote(t, backend, key); !bytes.Equal(got, data) {
+			t.Errorf("Expected %s to match what was put", key)
+		}
+	}
+	if chunks := drive.uploadedChunks(); chunks != 4 {
+		t.Errorf("Expected 4 chunks, 3 for the large file, got %d", chunks)
+	}
+	// The folders are only created once and a file that is put again is replaced.
+	if err := backend.Put("a/it's small.txt", bytes.NewReader(large), int64(len(large))); err != nil {
+		t.Fatalf("Failed to replace file: %v", err)
+	}
+	if drive.count("a") != 1 || drive.count("it's small.txt") != 1 {
+		t.Error("Expected a single folder and file of each name")
+	}
+
+	objects, err := backend.List("a")
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	slices.SortFunc(objects, func(a, b RemoteObject) int { return strings.Compare(a.Key, b.Key) })
+	expected := []RemoteObject{{"a/b/large.bin", int64(len(large))}, {"a/empty", 0}, {"a/it's small.txt", int64(len(large))}}
+	if !slices.Equal(objects, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, objects)
+	}
+
+	if err := backend.Delete("a"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if _, err := backend.Get("a/b/large.bin"); !errors.Is(err, ErrRemoteNotFound) {
+		t.Errorf("Expected the file to be deleted, got %v", err)
+	}
+	if objects, err := backend.List("a"); err != nil || len(objects) != 0 {
+		t.Errorf("Expected nothing to be listed, got %+v: %v", objects, err)
+	}
+	if err := backend.Delete("a"); !errors.Is(err, ErrRemoteNotFound) {
+		t.Errorf("Expected deleting a missing folder to fail, got %v", err)
+	}
+}
@@ -0,0 +1,420 @@
+package watcher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long signing in waits for the browser to come back.
+const oauthLoginTimeout = 5 * time.Minute
+
+// Most times a request is sent when the service keeps limiting the rate of requests.
+const maxRemoteAttempts = 8
+
+// Longest wait before a limited request is sent again when the service does not say
+// how long to wait.
+const maxRemoteBackoff = time.Minute
+
+// oauthProvider is a service remotes sign in to with OAuth.
+type oauthProvider struct {
+	authURL  string
+	tokenURL string
+	scopes   []string
+	// Added to the address the user signs in at.
+	authParams url.Values
+	// Host of the address the browser is sent back to, the app listens on 127.0.0.1
+	// either way.
+	redirectHost string
+}
+
+var oauthProviders = map[RemoteType]oauthProvider{
+	RemoteGoogleDrive: {
+		authURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+		tokenURL: "https://oauth2.googleapis.com/token",
+		// Only the files the app created can be read, not the rest of the drive.
+		scopes: []string{"https://www.googleapis.com/auth/drive.file"},
+		// A refresh token is only returned when the user is asked for consent.
+		authParams:   url.Values{"access_type": {"offline"}, "prompt": {"consent"}},
+		redirectHost: "127.0.0.1",
+	},
+	RemoteOneDrive: {
+		authURL:      "https://login.microsoftonline.com/common/oauth2/v2.0/authorize",
+		tokenURL:     "https://login.microsoftonline.com/common/oauth2/v2.0/token",
+		scopes:       []string{"Files.ReadWrite", "offline_access"},
+		redirectHost: "localhost",
+	},
+}
+
+// oauthToken is saved in the keychain after signing in.
+type oauthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+// remoteTokenName is the name the token of the remote of a watcher is saved under.
+func remoteTokenName(remoteType RemoteType, watcherID string) string {
+	return "remote:" + string(remoteType) + ":" + watcherID
+}
+
+// AuthorizeRemote signs in to the account of the remote in the browser and saves the
+// token in the keychain. open is called with the address the user signs in at.
+func (w *Watcher) AuthorizeRemote(open func(address string) error) error {
+	w.mu.Lock()
+	config := w.Remote
+	w.mu.Unlock()
+	provider, ok := oauthProviders[config.Type]
+	if !ok {
+		return fmt.Errorf("the remote of %s does not sign in with a browser", w.Name)
+	}
+	config, err := w.resolveRemote(config)
+	if err != nil {
+		return err
+	}
+	token, err := provider.authorize(config, open, oauthLoginTimeout)
+	if err != nil {
+		return err
+	}
+	if err := saveOAuthToken(w.keychain, remoteTokenName(config.Type, w.Name), token); err != nil {
+		return err
+	}
+	// Uploads that failed without the token are tried again now.
+	w.requestRemoteUpload()
+	return nil
+}
+
+// ForgetRemote removes the token of the remote from the keychain.
+func (w *Watcher) ForgetRemote() error {
+	w.mu.Lock()
+	config := w.Remote
+	w.mu.Unlock()
+	if _, ok := oauthProviders[config.Type]; !ok {
+		return fmt.Errorf("the remote of %s does not sign in with a browser", w.Name)
+	}
+	return w.keychain.Delete(remoteTokenName(config.Type, w.Name))
+}
+
+func saveOAuthToken(keychain Keychain, name string, token oauthToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return keychain.Set(name, string(data))
+}
+
+// randomString returns a random string that is safe in a URL.
+func randomString() string {
+	data := make([]byte, 32)
+	rand.Read(data)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// authorize signs in with the authorization code flow, the browser is sent back to a
+// server on the loopback interface. PKCE keeps the code useless to anything else that
+// sees it.
+func (p oauthProvider) authorize(config RemoteConfig, open func(address string) error, timeout time.Duration) (oauthToken, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return oauthToken{}, fmt.Errorf("error listening for the sign in: %w", err)
+	}
+	redirect := fmt.Sprintf("http://%s:%d/", p.redirectHost, listener.Addr().(*net.TCPAddr).Port)
+	verifier, state := randomString(), randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(rw, "Unexpected sign in", http.StatusBadRequest)
+			return
+		}
+		var res result
+		if message := query.Get("error"); message != "" {
+			res.err = fmt.Errorf("sign in failed: %s %s", message, query.Get("error_description"))
+			fmt.Fprintln(rw, "Signing in failed, you can close this window.")
+		} else {
+			res.code = query.Get("code")
+			fmt.Fprintln(rw, "Signed in, you can close this window.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	params := url.Values{
+		"client_id":             {config.ClientID},
+		"redirect_uri":          {redirect},
+		"response_type":         {"code"},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	for name, values := range p.authParams {
+		params[name] = values
+	}
+	if err := open(p.authURL + "?" + params.Encode()); err != nil {
+		return oauthToken{}, err
+	}
+
+	var res result
+	select {
+	case res = <-results:
+	case <-time.After(timeout):
+		return oauthToken{}, errors.New("timed out waiting for the sign in")
+	}
+	if res.err != nil {
+		return oauthToken{}, res.err
+	}
+	return p.requestToken(http.DefaultClient, config, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {res.code},
+		"redirect_uri":  {redirect},
+		"code_verifier": {verifier},
+	}, time.Now())
+}
+
+// requestToken asks the token endpoint for a token, for a code or a refresh token.
+func (p oauthProvider) requestToken(client *http.Client, config RemoteConfig, form url.Values, now time.Time) (oauthToken, error) {
+	form.Set("client_id", config.ClientID)
+	if config.ClientSecret != "" {
+		form.Set("client_secret", config.ClientSecret)
+	}
+	response, err := client.PostForm(p.tokenURL, form)
+	if err != nil {
+		return oauthToken{}, fmt.Errorf("error requesting token: %w", err)
+	}
+	defer response.Body.Close()
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		RefreshToken     string `json:"refresh_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return oauthToken{}, fmt.Errorf("error requesting token: %s", response.Status)
+	}
+	if response.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return oauthToken{}, fmt.Errorf("error requesting token: %s %s", body.Error, body.ErrorDescription)
+	}
+	return oauthToken{
+		AccessToken:  body.AccessToken,
+		RefreshToken: body.RefreshToken,
+		Expiry:       now.Add(time.Duration(body.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// oauthClient sends requests to a service with the token saved in the keychain,
+// refreshing it when it expires and waiting when the service limits the rate of
+// requests.
+type oauthClient struct {
+	client     *http.Client
+	provider   oauthProvider
+	config     RemoteConfig
+	keychain   Keychain
+	secretName string
+	// Waits before a limited request is sent again, replaced in tests.
+	sleep func(time.Duration)
+
+	mu    sync.Mutex
+	token oauthToken
+}
+
+func newOAuthClient(provider oauthProvider, config RemoteConfig, keychain Keychain, secretName string) *oauthClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 2 * time.Minute
+	return &oauthClient{
+		client:     &http.Client{Transport: transport},
+		provider:   provider,
+		config:     config,
+		keychain:   keychain,
+		secretName: secretName,
+		sleep:      time.Sleep,
+	}
+}
+
+// accessToken returns a token that is valid for at least another minute, refresh
+// forces a new one.
+func (c *oauthClient) accessToken(refresh bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token.RefreshToken == "" {
+		data, err := c.keychain.Get(c.secretName)
+		if errors.Is(err, ErrSecretNotFound) {
+			return "", fmt.Errorf("not signed in to the %s remote, sign in with remote-login", c.config.Type)
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal([]byte(data), &c.token); err != nil {
+			return "", fmt.Errorf("error reading token: %w", err)
+		}
+	}
+	if !refresh && c.token.AccessToken != "" && time.Until(c.token.Expiry) > time.Minute {
+		return c.token.AccessToken, nil
+	}
+
+	token, err := c.provider.requestToken(c.client, c.config, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {c.token.RefreshToken},
+	}, time.Now())
+	if err != nil {
+		return "", err
+	}
+	// Google keeps the refresh token, Microsoft replaces it with every refresh.
+	if token.RefreshToken == "" {
+		token.RefreshToken = c.token.RefreshToken
+	}
+	c.token = token
+	if err := saveOAuthToken(c.keychain, c.secretName, token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// do sends the request newRequest creates, a new one for every attempt. With
+// authorize the token is added, and the request is sent again once with a fresh token
+// if it is refused. Requests the service limits are sent again after the wait it asks
+// for, or after a wait that doubles with every attempt.
+func (c *oauthClient) do(newRequest func() (*http.Request, error), authorize bool) (*http.Response, error) {
+	backoff := time.Second
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		if authorize {
+			token, err := c.accessToken(false)
+			if err != nil {
+				return nil, err
+			}
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := c.client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode == http.StatusUnauthorized && authorize && !refreshed {
+			response.Body.Close()
+			refreshed = true
+			if _, err := c.accessToken(true); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		wait, limited := rateLimited(response, backoff)
+		if !limited || attempt == maxRemoteAttempts {
+			return response, nil
+		}
+		response.Body.Close()
+		c.sleep(wait)
+		backoff = min(backoff*2, maxRemoteBackoff)
+	}
+}
+
+// rateLimited returns true if a response asks for the request to be sent again later,
+// along with how long to wait. Google also refuses requests with 403 when a user sends
+// too many, which is told apart from other refusals by the reason in the body.
+func rateLimited(response *http.Response, backoff time.Duration) (time.Duration, bool) {
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+	case http.StatusForbidden:
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+		response.Body.Close()
+		response.Body = io.NopCloser(bytes.NewReader(body))
+		// The reasons are rateLimitExceeded and userRateLimitExceeded.
+		if !bytes.Contains(bytes.ToLower(body), []byte("ratelimitexceeded")) {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	return backoff, true
+}
+
+// jsonRequest returns a function that creates a request with a JSON body.
+func jsonRequest(method, address string, body any) func() (*http.Request, error) {
+	return func() (*http.Request, error) {
+		var reader io.Reader
+		if body != nil {
+			data, err := json.Marshal(body)
+			if err != nil {
+				return nil, err
+			}
+			reader = bytes.NewReader(data)
+		}
+		request, err := http.NewRequest(method, address, reader)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		return request, nil
+	}
+}
+
+// rangeRequest returns a function that creates a request sending part of a file, as
+// the chunk of an upload.
+func rangeRequest(method, address string, r io.ReaderAt, offset, length, size int64) func() (*http.Request, error) {
+	return func() (*http.Request, error) {
+		var body io.Reader = http.NoBody
+		if length > 0 {
+			body = io.NewSectionReader(r, offset, length)
+		}
+		request, err := http.NewRequest(method, address, body)
+		if err != nil {
+			return nil, err
+		}
+		request.ContentLength = length
+		if length > 0 {
+			request.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size))
+		} else {
+			request.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		}
+		return request, nil
+	}
+}
+
+// decodeJSON reads a JSON response into v, responses with another status than ok are
+// returned as errors.
+func decodeJSON(response *http.Response, v any, ok ...int) error {
+	defer response.Body.Close()
+	if !slices.Contains(ok, response.StatusCode) {
+		return remoteHTTPError(response.Request.Method, response.Request.URL, response)
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		return fmt.Errorf("error reading response of %s: %w", response.Request.URL.Path, err)
+	}
+	return nil
+}
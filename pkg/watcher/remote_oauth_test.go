@@ -0,0 +1,228 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestOAuthClient returns a client that is signed in with the access token "access"
+// and refreshes it at tokenURL.
+func newTestOAuthClient(t *testing.T, tokenURL string) (*oauthClient, *memoryKeychain) {
+	t.Helper()
+	keychain := &memoryKeychain{}
+	token := oauthToken{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}
+	if err := saveOAuthToken(keychain, "token", token); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+	client := newOAuthClient(oauthProvider{tokenURL: tokenURL}, RemoteConfig{ClientID: "client"}, keychain, "token")
+	client.sleep = func(time.Duration) {}
+	return client, keychain
+}
+
+func TestOAuthAuthorize(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var challenge string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		mu.Lock()
+		expected := challenge
+		mu.Unlock()
+		if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != "code" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != expected || r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"access","refresh_token":"refresh","expires_in":3600}`)
+	}))
+	t.Cleanup(server.Close)
+
+	provider := oauthProvider{authURL: server.URL + "/auth", tokenURL: server.URL + "/token", scopes: []string{"files"}, redirectHost: "127.0.0.1"}
+	config := RemoteConfig{Type: RemoteGoogleDrive, ClientID: "client", ClientSecret: "secret"}
+	// The browser signs in and is sent back with a code.
+	open := func(address string) error {
+		authURL, err := url.Parse(address)
+		if err != nil {
+			return err
+		}
+		query := authURL.Query()
+		if query.Get("client_id") != "client" || query.Get("scope") != "files" || query.Get("code_challenge_method") != "S256" {
+			return fmt.Errorf("unexpected sign in address %s", address)
+		}
+		mu.Lock()
+		challenge = query.Get("code_challenge")
+		mu.Unlock()
+		response, err := http.Get(query.Get("redirect_uri") + "?code=code&state=" + url.QueryEscape(query.Get("state")))
+		if err != nil {
+			return err
+		}
+		return response.Body.Close()
+	}
+	token, err := provider.authorize(config, open, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to sign in: %v", err)
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" || time.Until(token.Expiry) < 59*time.Minute {
+		t.Errorf("Unexpected token %+v", token)
+	}
+
+	// A browser that comes back with an error fails the sign in.
+	deny := func(address string) error {
+		authURL, _ := url.Parse(address)
+		query := authURL.Query()
+		response, err := http.Get(query.Get("redirect_uri") + "?error=access_denied&state=" + url.QueryEscape(query.Get("state")))
+		if err != nil {
+			return err
+		}
+		return response.Body.Close()
+	}
+	if _, err := provider.authorize(config, deny, time.Minute); err == nil {
+		t.Error("Expected a denied sign in to fail")
+	}
+}
+
+func TestOAuthClientRefreshesToken(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	refreshes := 0
+	refreshed := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return refreshes
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			r.ParseForm()
+			if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != "refresh" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_grant"}`)
+				return
+			}
+			mu.Lock()
+			refreshes++
+			mu.Unlock()
+			// Like Google, the refresh token is not sent again.
+			fmt.Fprint(w, `{"access_token":"fresh","expires_in":3600}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+
+	// The token is revoked before it expires, so it is refreshed once it is refused.
+	client, keychain := newTestOAuthClient(t, server.URL+"/token")
+	response, err := client.do(jsonRequest(http.MethodGet, server.URL+"/api", nil), true)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || refreshed() != 1 {
+		t.Errorf("Expected the request to succeed after 1 refresh, got %s after %d", response.Status, refreshed())
+	}
+	data, err := keychain.Get("token")
+	if err != nil {
+		t.Fatalf("Failed to read token: %v", err)
+	}
+	var saved oauthToken
+	if err := json.Unmarshal([]byte(data), &saved); err != nil || saved.AccessToken != "fresh" || saved.RefreshToken != "refresh" {
+		t.Errorf("Expected the refreshed token to be saved with the refresh token, got %+v: %v", saved, err)
+	}
+
+	// A token that expired is refreshed before it is sent.
+	expired := oauthToken{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Minute)}
+	if err := saveOAuthToken(keychain, "token", expired); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+	client = newOAuthClient(client.provider, client.config, keychain, "token")
+	if token, err := client.accessToken(false); err != nil || token != "fresh" || refreshed() != 2 {
+		t.Errorf("Expected the expired token to be refreshed, got %q after %d refreshes: %v", token, refreshed(), err)
+	}
+
+	missing := newOAuthClient(client.provider, client.config, &memoryKeychain{}, "token")
+	if _, err := missing.accessToken(false); err == nil {
+		t.Error("Expected an error without a token")
+	}
+}
+
+func TestOAuthClientWaitsForRateLimits(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		count := requests
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/denied":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error":{"errors":[{"reason":"insufficientPermissions"}]}}`)
+		case count <= 2:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case count == 3:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error":{"errors":[{"reason":"userRateLimitExceeded"}]}}`)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, _ := newTestOAuthClient(t, server.URL+"/token")
+	waits := []time.Duration{}
+	client.sleep = func(d time.Duration) { waits = append(waits, d) }
+	response, err := client.do(jsonRequest(http.MethodGet, server.URL+"/api", nil), true)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected the request to succeed, got %s", response.Status)
+	}
+	// The wait the service asks for is used, otherwise it doubles with every attempt.
+	if expected := []time.Duration{7 * time.Second, 7 * time.Second, 4 * time.Second}; !slices.Equal(waits, expected) {
+		t.Errorf("Expected waits of %v, got %v", expected, waits)
+	}
+
+	waits = waits[:0]
+	response, err = client.do(jsonRequest(http.MethodGet, server.URL+"/denied", nil), true)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	if err := decodeJSON(response, nil, http.StatusOK); err == nil || len(waits) != 0 {
+		t.Errorf("Expected a refused request to fail without waiting, got %v after %v", err, waits)
+	}
+}
+
+func TestUploadChunkSize(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		configured, unit, expected int64
+	}{
+		{0, googleDriveChunkUnit, defaultRemoteChunkSize},
+		{1, googleDriveChunkUnit, googleDriveChunkUnit},
+		{oneDriveChunkUnit + 1, oneDriveChunkUnit, 2 * oneDriveChunkUnit},
+		{-1, oneDriveChunkUnit, 0},
+	}
+	for _, test := range tests {
+		if size := uploadChunkSize(test.configured, test.unit); size != test.expected {
+			t.Errorf("Expected %d rounded to %d to be %d, got %d", test.configured, test.unit, test.expected, size)
+		}
+	}
+}
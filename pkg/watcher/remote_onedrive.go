@@ -0,0 +1,171 @@
+package watcher
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	oneDriveAPI = "https://graph.microsoft.com/v1.0/me/drive"
+	// Chunks of an upload session are multiples of this size.
+	oneDriveChunkUnit = 320 << 10
+	// Largest file sent in a single request, larger files use an upload session.
+	oneDriveMaxSimpleUpload = 4 << 20
+)
+
+// oneDriveBackend stores objects as files below a folder in OneDrive, through the
+// Microsoft Graph API which finds files by their path.
+type oneDriveBackend struct {
+	client    *oauthClient
+	api       string
+	folder    string
+	chunkSize int64
+}
+
+func newOneDriveBackend(config RemoteConfig, client *oauthClient, api string) *oneDriveBackend {
+	return &oneDriveBackend{
+		client:    client,
+		api:       api,
+		folder:    config.remoteFolder(),
+		chunkSize: uploadChunkSize(config.ChunkSize, oneDriveChunkUnit),
+	}
+}
+
+// itemURL returns the address of the file or folder of a key.
+func (b *oneDriveBackend) itemURL(key string) string {
+	parts := strings.Split(path.Join(b.folder, key), "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return b.api + "/root:/" + strings.Join(parts, "/") + ":"
+}
+
+// Put uploads a small file in one request and larger files in the chunks of an upload
+// session. Missing folders are created by OneDrive.
+func (b *oneDriveBackend) Put(key string, r io.ReaderAt, size int64) error {
+	if b.chunkSize <= 0 || size <= min(b.chunkSize, oneDriveMaxSimpleUpload) {
+		request := func() (*http.Request, error) {
+			request, err := http.NewRequest(http.MethodPut, b.itemURL(key)+"/content", io.NewSectionReader(r, 0, size))
+			if err != nil {
+				return nil, err
+			}
+			request.ContentLength = size
+			return request, nil
+		}
+		response, err := b.client.do(request, true)
+		if err != nil {
+			return err
+		}
+		return decodeJSON(response, nil, http.StatusOK, http.StatusCreated)
+	}
+
+	body := map[string]any{"item": map[string]string{"@microsoft.graph.conflictBehavior": "replace"}}
+	response, err := b.client.do(jsonRequest(http.MethodPost, b.itemURL(key)+"/createUploadSession", body), true)
+	if err != nil {
+		return err
+	}
+	var session struct {
+		UploadURL string `json:"uploadUrl"`
+	}
+	if err := decodeJSON(response, &session, http.StatusOK); err != nil {
+		return err
+	}
+
+	// The upload address is signed, sending the token with it is refused.
+	for offset := int64(0); ; {
+		length := min(b.chunkSize, size-offset)
+		response, err := b.client.do(rangeRequest(http.MethodPut, session.UploadURL, r, offset, length, size), false)
+		if err != nil {
+			return err
+		}
+		if response.StatusCode != http.StatusAccepted {
+			return decodeJSON(response, nil, http.StatusOK, http.StatusCreated)
+		}
+		var status struct {
+			NextExpectedRanges []string `json:"nextExpectedRanges"`
+		}
+		if err := decodeJSON(response, &status, http.StatusAccepted); err != nil {
+			return err
+		}
+		if len(status.NextExpectedRanges) == 0 {
+			return fmt.Errorf("onedrive did not say which part of %s to send next", key)
+		}
+		start, _, _ := strings.Cut(status.NextExpectedRanges[0], "-")
+		next, err := strconv.ParseInt(start, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid range from onedrive: %w", err)
+		}
+		if next <= offset {
+			return fmt.Errorf("onedrive did not accept the chunk of %s", key)
+		}
+		offset = next
+	}
+}
+
+func (b *oneDriveBackend) Get(key string) (io.ReadCloser, error) {
+	response, err := b.client.do(jsonRequest(http.MethodGet, b.itemURL(key)+"/content", nil), true)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, decodeJSON(response, nil, http.StatusOK)
+	}
+	return response.Body, nil
+}
+
+func (b *oneDriveBackend) List(prefix string) ([]RemoteObject, error) {
+	type page struct{ key, address string }
+	children := func(key string) page {
+		return page{key, b.itemURL(key) + "/children?$select=name,size,folder"}
+	}
+	objects := []RemoteObject{}
+	pages := []page{children(prefix)}
+	for first := true; len(pages) > 0; first = false {
+		current := pages[0]
+		pages = pages[1:]
+		response, err := b.client.do(jsonRequest(http.MethodGet, current.address, nil), true)
+		if err != nil {
+			return nil, err
+		}
+		if first && response.StatusCode == http.StatusNotFound {
+			response.Body.Close()
+			return objects, nil
+		}
+		var items struct {
+			Value []struct {
+				Name   string    `json:"name"`
+				Size   int64     `json:"size"`
+				Folder *struct{} `json:"folder"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		if err := decodeJSON(response, &items, http.StatusOK); err != nil {
+			return nil, err
+		}
+		for _, item := range items.Value {
+			key := path.Join(current.key, item.Name)
+			if item.Folder != nil {
+				pages = append(pages, children(key))
+			} else {
+				objects = append(objects, RemoteObject{Key: key, Size: item.Size})
+			}
+		}
+		if items.NextLink != "" {
+			pages = append(pages, page{current.key, items.NextLink})
+		}
+	}
+	return objects, nil
+}
+
+func (b *oneDriveBackend) Delete(key string) error {
+	response, err := b.client.do(jsonRequest(http.MethodDelete, b.itemURL(key), nil), true)
+	if err != nil {
+		return err
+	}
+	return decodeJSON(response, nil, http.StatusNoContent)
+}
@@ -0,0 +1,216 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeOneDrive serves the parts of the Microsoft Graph API that the backend uses from
+// memory. Folders exist while there are files in them.
+type fakeOneDrive struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	files map[string][]byte
+	// Upload sessions by their ID, with the path of the file and what was uploaded.
+	sessions map[string]*fakeUploadSession
+	// Requests that were sent with a token to the upload address.
+	authorizedUploads int
+}
+
+type fakeUploadSession struct {
+	path string
+	data []byte
+}
+
+// oneDrivePageSize is the number of children the fake returns at a time.
+const oneDrivePageSize = 2
+
+func newFakeOneDrive(t *testing.T) *fakeOneDrive {
+	t.Helper()
+	d := &fakeOneDrive{files: map[string][]byte{}, sessions: map[string]*fakeUploadSession{}}
+	d.Server = httptest.NewServer(http.HandlerFunc(d.serve))
+	t.Cleanup(d.Close)
+	return d
+}
+
+func (d *fakeOneDrive) serve(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if id, ok := strings.CutPrefix(r.URL.Path, "/upload/"); ok {
+		d.uploadChunk(w, r, id)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer access" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	item, ok := strings.CutPrefix(r.URL.Path, "/drive/root:/")
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	item, action, _ := strings.Cut(item, ":")
+	switch {
+	case r.Method == http.MethodPut && action == "/content":
+		d.files[item], _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "{}")
+	case r.Method == http.MethodPost && action == "/createUploadSession":
+		id := strconv.Itoa(len(d.sessions))
+		d.sessions[id] = &fakeUploadSession{path: item}
+		json.NewEncoder(w).Encode(map[string]string{"uploadUrl": d.URL + "/upload/" + id})
+	case r.Method == http.MethodGet && action == "/content" && d.files[item] != nil:
+		w.Write(d.files[item])
+	case r.Method == http.MethodGet && action == "/children":
+		d.children(w, r, item)
+	case r.Method == http.MethodDelete && action == "" && d.remove(item):
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (d *fakeOneDrive) uploadChunk(w http.ResponseWriter, r *http.Request, id string) {
+	session, ok := d.sessions[id]
+	if !ok || r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Header.Get("Authorization") != "" {
+		d.authorizedUploads++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var start, end, total int64
+	if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil || start != int64(len(session.data)) {
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	data, _ := io.ReadAll(r.Body)
+	session.data = append(session.data, data...)
+	if int64(len(session.data)) < total {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string][]string{"nextExpectedRanges": {fmt.Sprintf("%d-", len(session.data))}})
+		return
+	}
+	d.files[session.path] = session.data
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprint(w, "{}")
+}
+
+// children lists a folder a page at a time.
+func (d *fakeOneDrive) children(w http.ResponseWriter, r *http.Request, folder string) {
+	type item struct {
+		Name   string    `json:"name"`
+		Size   int64     `json:"size"`
+		Folder *struct{} `json:"folder,omitempty"`
+	}
+	items := []item{}
+	for file, data := range d.files {
+		rest, ok := strings.CutPrefix(file, folder+"/")
+		if !ok {
+			continue
+		}
+		if name, _, nested := strings.Cut(rest, "/"); nested {
+			if !slices.ContainsFunc(items, func(i item) bool { return i.Name == name }) {
+				items = append(items, item{Name: name, Folder: &struct{}{}})
+			}
+		} else {
+			items = append(items, item{Name: name, Size: int64(len(data))})
+		}
+	}
+	if len(items) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	slices.SortFunc(items, func(a, b item) int { return strings.Compare(a.Name, b.Name) })
+	skip, _ := strconv.Atoi(r.URL.Query().Get("$skiptoken"))
+	page := map[string]any{"value": items[skip:min(skip+oneDrivePageSize, len(items))]}
+	if skip+oneDrivePageSize < len(items) {
+		query := r.URL.Query()
+		query.Set("$skiptoken", strconv.Itoa(skip+oneDrivePageSize))
+		page["@odata.nextLink"] = d.URL + r.URL.EscapedPath() + "?" + query.Encode()
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+// remove deletes a file or a folder and what is in it, false if there is neither.
+func (d *fakeOneDrive) remove(item string) bool {
+	removed := false
+	for file := range d.files {
+		if file == item || strings.HasPrefix(file, item+"/") {
+			delete(d.files, file)
+			removed = true
+		}
+	}
+	return removed
+}
+
+func (d *fakeOneDrive) uploadSessions() (sessions, authorized int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.sessions), d.authorizedUploads
+}
+
+func TestOneDriveBackend(t *testing.T) {
+	t.Parallel()
+	drive := newFakeOneDrive(t)
+	client, _ := newTestOAuthClient(t, drive.URL+"/token")
+	config := RemoteConfig{Type: RemoteOneDrive, ClientID: "client", Folder: "Backups/My Laptop", ChunkSize: 1}
+	backend := newOneDriveBackend(config, client, drive.URL+"/drive")
+
+	small := []byte("it's small")
+	large := createRandomFileContent(2*oneDriveChunkUnit + 100)
+	files := map[string][]byte{"a/it's small.txt": small, "a/b/large.bin": large, "a/b/c": small, "a/d/e": small, "a/f": small}
+	for key, data := range files {
+		if err := backend.Put(key, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("Failed to put %s: %v", key, err)
+		}
+		if got := readRemote(t, backend, key); !bytes.Equal(got, data) {
+			t.Errorf("Expected %s to match what was put", key)
+		}
+	}
+	// Only the large file needs an upload session and its address is sent no token.
+	if sessions, authorized := drive.uploadSessions(); sessions != 1 || authorized != 0 {
+		t.Errorf("Expected 1 upload session without a token, got %d with %d tokens", sessions, authorized)
+	}
+
+	// The folder has more children than fit on a page.
+	objects, err := backend.List("a")
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	slices.SortFunc(objects, func(a, b RemoteObject) int { return strings.Compare(a.Key, b.Key) })
+	expected := []RemoteObject{}
+	for key, data := range files {
+		expected = append(expected, RemoteObject{key, int64(len(data))})
+	}
+	slices.SortFunc(expected, func(a, b RemoteObject) int { return strings.Compare(a.Key, b.Key) })
+	if !slices.Equal(objects, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, objects)
+	}
+
+	if err := backend.Delete("a/b"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if _, err := backend.Get("a/b/large.bin"); !errors.Is(err, ErrRemoteNotFound) {
+		t.Errorf("Expected the file to be deleted, got %v", err)
+	}
+	if objects, err := backend.List("a/b"); err != nil || len(objects) != 0 {
+		t.Errorf("Expected nothing to be listed, got %+v: %v", objects, err)
+	}
+	if err := backend.Delete("a/b"); !errors.Is(err, ErrRemoteNotFound) {
+		t.Errorf("Expected deleting a missing folder to fail, got %v", err)
+	}
+}
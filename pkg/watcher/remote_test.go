@@ -0,0 +1,85 @@
+package watcher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoteCopies(t *testing.T) {
+	t.Parallel()
+	server := newNextcloudServer(t)
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Remote = RemoteConfig{Type: RemoteWebDAV, URL: server.folderURL("Backups"), User: "alice", Password: "secret", ChunkSize: 1024}
+
+	CreateDummyFile(t, WatcherConfig.Source, "folder/file1.txt", 4096)
+	watcher.CreateBackup()
+	if err := watcher.UploadRemoteCopies(); err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	backup := watcher.ListBackups()[0]
+	if !backup.RemoteCopy {
+		t.Error("Expected the backup to be marked as uploaded")
+	}
+	backend, err := watcher.newRemoteBackend(watcher.Remote)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	expected, err := os.ReadFile(filepath.Join(WatcherConfig.Source, "folder", "file1.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read source: %v", err)
+	}
+	if got := readRemote(t, backend, watcher.remoteBackupKey(backup)+"/folder/file1.txt"); !bytes.Equal(got, expected) {
+		t.Error("Expected the remote copy to match the source")
+	}
+
+	// The mark survives a restart so the backup is not uploaded again.
+	reloaded, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to reload watcher: %v", err)
+	}
+	if !reloaded.ListBackups()[0].RemoteCopy {
+		t.Error("Expected the mark to be saved in the metadata")
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "folder/file2.txt", 100)
+	watcher.CreateBackup()
+	if err := watcher.UploadRemoteCopies(); err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	remote, err := watcher.RemoteBackups()
+	if err != nil {
+		t.Fatalf("Failed to read remote backups: %v", err)
+	}
+	if len(remote) != 2 || remote[0].Path != backup.Path || remote[0].Destination != "" {
+		t.Errorf("Expected both backups in the remote metadata, got %+v", remote)
+	}
+
+	// Pruning deletes the remote copy along with the backup.
+	watcher.Retention = RetentionPolicy{KeepLast: 1}
+	if _, err := watcher.Prune(false); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+	if objects, err := backend.List(watcher.remoteBackupKey(backup)); err != nil || len(objects) != 0 {
+		t.Errorf("Expected the remote copy to be deleted, got %+v: %v", objects, err)
+	}
+	if remote, err := watcher.RemoteBackups(); err != nil || len(remote) != 1 {
+		t.Errorf("Expected 1 backup in the remote metadata, got %+v: %v", remote, err)
+	}
+}
+
+func TestRemoteRequiresCopies(t *testing.T) {
+	t.Parallel()
+	config := WatcherConfig{
+		Remote: RemoteConfig{Type: RemoteWebDAV, URL: "https://cloud.example.com/remote.php/dav/files/alice/"},
+		Restic: ResticConfig{Repository: "/srv/restic"},
+	}
+	if err := validateRemote(&config); err == nil {
+		t.Error("Expected a remote to be refused with a restic repository")
+	}
+}
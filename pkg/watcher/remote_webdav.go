@@ -0,0 +1,331 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Nextcloud accepts at most this many chunks for one file, larger chunks are used for
+// files that would need more.
+const maxWebDAVChunks = 10000
+
+// webDAVBackend stores objects as files below a folder on a WebDAV server. On Nextcloud
+// large files are uploaded in chunks, so a dropped connection only loses one chunk.
+type webDAVBackend struct {
+	client    *http.Client
+	base      *url.URL
+	user      string
+	password  string
+	chunkSize int64
+	// Folder chunked uploads are made in, nil if the server is not Nextcloud.
+	uploads *url.URL
+
+	mu sync.Mutex
+	// Folders that are known to exist, relative to base.
+	folders map[string]bool
+}
+
+// parseWebDAVURL checks that the URL of a WebDAV folder can be used.
+func parseWebDAVURL(rawURL string) (*url.URL, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("webdav remote requires a URL")
+	}
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webdav URL: %w", err)
+	}
+	if (base.Scheme != "https" && base.Scheme != "http") || base.Host == "" {
+		return nil, fmt.Errorf("webdav URL must start with https:// or http://")
+	}
+	if base.User != nil {
+		return nil, fmt.Errorf("webdav URL must not contain a user, use the user setting")
+	}
+	return base, nil
+}
+
+func newWebDAVBackend(config RemoteConfig) (*webDAVBackend, error) {
+	base, err := parseWebDAVURL(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/") + "/"
+	base.RawPath = ""
+	chunkSize := config.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = defaultRemoteChunkSize
+	}
+	// A whole chunk has to be sent before the server answers, so only the wait for the
+	// answer is limited.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 2 * time.Minute
+	return &webDAVBackend{
+		client:    &http.Client{Transport: transport},
+		base:      base,
+		user:      config.User,
+		password:  config.Password,
+		chunkSize: chunkSize,
+		uploads:   nextcloudUploads(base),
+		folders:   map[string]bool{},
+	}, nil
+}
+
+// nextcloudUploads returns the folder chunked uploads are made in on Nextcloud, files
+// are below /remote.php/dav/files/<user>/ and uploads below
+// /remote.php/dav/uploads/<user>/. Nil is returned for other servers.
+func nextcloudUploads(base *url.URL) *url.URL {
+	root, files, ok := strings.Cut(base.Path, "/remote.php/dav/files/")
+	if !ok {
+		return nil
+	}
+	user, _, _ := strings.Cut(files, "/")
+	if user == "" {
+		return nil
+	}
+	uploads := *base
+	uploads.Path = root + "/remote.php/dav/uploads/" + user + "/"
+	return &uploads
+}
+
+// objectURL returns the URL of the file or folder of a key.
+func (b *webDAVBackend) objectURL(key string) *url.URL {
+	return b.base.JoinPath(strings.Split(key, "/")...)
+}
+
+func (b *webDAVBackend) request(method string, target *url.URL, body io.Reader, size int64, header map[string]string) (*http.Response, error) {
+	if body != nil && size == 0 {
+		body = http.NoBody
+	}
+	request, err := http.NewRequest(method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.ContentLength = size
+	}
+	if b.user != "" {
+		request.SetBasicAuth(b.user, b.password)
+	}
+	for name, value := range header {
+		request.Header.Set(name, value)
+	}
+	return b.client.Do(request)
+}
+
+// call makes a request that has no response body, it fails unless the status is one
+// of ok.
+func (b *webDAVBackend) call(method string, target *url.URL, body io.Reader, size int64, header map[string]string, ok ...int) error {
+	response, err := b.request(method, target, body, size, header)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if !slices.Contains(ok, response.StatusCode) {
+		return remoteHTTPError(method, target, response)
+	}
+	return nil
+}
+
+// mkdirAll creates a folder relative to base along with its parents, folders that
+// exist are left alone.
+func (b *webDAVBackend) mkdirAll(folder string) error {
+	parts := []string{""}
+	if folder != "." && folder != "" {
+		parts = append(parts, strings.Split(folder, "/")...)
+	}
+	for i := range parts {
+		rel := path.Join(parts[:i+1]...)
+		b.mu.Lock()
+		exists := b.folders[rel]
+		b.mu.Unlock()
+		if exists {
+			continue
+		}
+		// Creating a folder that exists fails with 405.
+		if err := b.call("MKCOL", b.objectURL(rel), nil, 0, nil, http.StatusCreated, http.StatusMethodNotAllowed); err != nil {
+			return err
+		}
+		b.mu.Lock()
+		b.folders[rel] = true
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+func (b *webDAVBackend) Put(key string, r io.ReaderAt, size int64) error {
+	if err := b.mkdirAll(path.Dir(key)); err != nil {
+		return err
+	}
+	if b.uploads != nil && b.chunkSize > 0 && size > b.chunkSize {
+		return b.putChunked(key, r, size)
+	}
+	return b.call(http.MethodPut, b.objectURL(key), io.NewSectionReader(r, 0, size), size, nil, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+}
+
+// putChunked uploads a file with the chunked upload of Nextcloud. The chunks are
+// uploaded into a folder that is named after the key and size, so an upload that was
+// interrupted continues with the chunks that are missing. The server joins the chunks
+// once the folder is moved to the file.
+func (b *webDAVBackend) putChunked(key string, r io.ReaderAt, size int64) error {
+	sum := sha256.Sum256([]byte(key + ":" + strconv.FormatInt(size, 10)))
+	folder := b.uploads.JoinPath("i-saw-that-" + hex.EncodeToString(sum[:16]))
+	header := map[string]string{
+		"Destination":     b.objectURL(key).String(),
+		"OC-Total-Length": strconv.FormatInt(size, 10),
+	}
+
+	chunks, err := b.propfind(folder)
+	if errors.Is(err, ErrRemoteNotFound) {
+		chunks = nil
+		if err := b.call("MKCOL", folder, nil, 0, header, http.StatusCreated); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	uploaded := map[string]int64{}
+	for _, chunk := range chunks {
+		uploaded[path.Base(chunk.Key)] = chunk.Size
+	}
+
+	chunkSize := max(b.chunkSize, (size+maxWebDAVChunks-1)/maxWebDAVChunks)
+	for number, offset := 1, int64(0); offset < size; number, offset = number+1, offset+chunkSize {
+		length := min(chunkSize, size-offset)
+		name := strconv.Itoa(number)
+		if uploaded[name] == length {
+			continue
+		}
+		chunk := io.NewSectionReader(r, offset, length)
+		if err := b.call(http.MethodPut, folder.JoinPath(name), chunk, length, header, http.StatusCreated, http.StatusNoContent); err != nil {
+			return err
+		}
+	}
+	return b.call("MOVE", folder.JoinPath(".file"), nil, 0, header, http.StatusCreated, http.StatusNoContent)
+}
+
+func (b *webDAVBackend) Get(key string) (io.ReadCloser, error) {
+	target := b.objectURL(key)
+	response, err := b.request(http.MethodGet, target, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		return nil, remoteHTTPError(http.MethodGet, target, response)
+	}
+	return response.Body, nil
+}
+
+func (b *webDAVBackend) List(prefix string) ([]RemoteObject, error) {
+	objects := []RemoteObject{}
+	folders := []*url.URL{b.objectURL(prefix)}
+	for len(folders) > 0 {
+		folder := folders[0]
+		folders = folders[1:]
+		entries, err := b.propfind(folder)
+		if errors.Is(err, ErrRemoteNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.folder {
+				folders = append(folders, b.objectURL(entry.Key))
+			} else {
+				objects = append(objects, entry.RemoteObject)
+			}
+		}
+	}
+	return objects, nil
+}
+
+func (b *webDAVBackend) Delete(key string) error {
+	// Folders below a deleted folder have to be created again.
+	b.mu.Lock()
+	clear(b.folders)
+	b.mu.Unlock()
+	return b.call(http.MethodDelete, b.objectURL(key), nil, 0, nil, http.StatusOK, http.StatusNoContent)
+}
+
+// webDAVEntry is a file or folder listed by PROPFIND, its key is relative to base.
+type webDAVEntry struct {
+	RemoteObject
+	folder bool
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/></d:prop></d:propfind>`
+
+// multistatus is the response to PROPFIND.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ContentLength string `xml:"getcontentlength"`
+				ResourceType  struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// propfind lists the files and folders directly inside a folder.
+func (b *webDAVBackend) propfind(folder *url.URL) ([]webDAVEntry, error) {
+	body := strings.NewReader(propfindBody)
+	header := map[string]string{"Depth": "1", "Content-Type": "application/xml"}
+	response, err := b.request("PROPFIND", folder, body, int64(body.Len()), header)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusMultiStatus {
+		return nil, remoteHTTPError("PROPFIND", folder, response)
+	}
+	var status multistatus
+	if err := xml.NewDecoder(response.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("error reading PROPFIND response: %w", err)
+	}
+
+	self := strings.TrimSuffix(folder.Path, "/")
+	entries := []webDAVEntry{}
+	for _, item := range status.Responses {
+		href, err := url.Parse(item.Href)
+		if err != nil {
+			return nil, fmt.Errorf("invalid href in PROPFIND response: %w", err)
+		}
+		entryPath := strings.TrimSuffix(href.Path, "/")
+		if entryPath == self {
+			continue
+		}
+		var entry webDAVEntry
+		entry.Key = strings.TrimPrefix(entryPath, b.base.Path)
+		for _, propstat := range item.Propstat {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			entry.folder = entry.folder || propstat.Prop.ResourceType.Collection != nil
+			if propstat.Prop.ContentLength != "" {
+				if entry.Size, err = strconv.ParseInt(propstat.Prop.ContentLength, 10, 64); err != nil {
+					return nil, fmt.Errorf("invalid size in PROPFIND response: %w", err)
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,236 @@
+package watcher
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ResticConfig stores backups as snapshots in an existing restic repository instead of
+// copying them into the destination. The destination still holds the metadata and
+// history of the watcher.
+type ResticConfig struct {
+	// Location of the repository, anything restic accepts for --repo such as a local
+	// path or "s3:...".
+	Repository string `json:"repository,omitempty" yaml:"repository,omitempty" toml:"repository,omitempty"`
+	// File containing the repository password. If empty restic reads RESTIC_PASSWORD
+	// and the other variables it supports from the environment.
+	PasswordFile string `json:"password_file,omitempty" yaml:"password_file,omitempty" toml:"password_file,omitempty"`
+	// Password of the repository, usually a reference such as "keychain:backups" to a
+	// secret in the keychain. Can not be combined with a password file.
+	Password string `json:"password,omitempty" yaml:"password,omitempty" toml:"password,omitempty"`
+	// Path of the restic binary, "restic" is looked up in PATH by default.
+	Executable string `json:"executable,omitempty" yaml:"executable,omitempty" toml:"executable,omitempty"`
+}
+
+func (c ResticConfig) enabled() bool {
+	return c.Repository != ""
+}
+
+func (c ResticConfig) validate() error {
+	if !c.enabled() && (c.PasswordFile != "" || c.Password != "" || c.Executable != "") {
+		return fmt.Errorf("restic password, password file and executable require a repository")
+	}
+	if c.PasswordFile != "" && c.Password != "" {
+		return fmt.Errorf("restic password and password file can not both be set")
+	}
+	return nil
+}
+
+// resticTag marks every snapshot made by the watcher so they can be told apart from
+// other snapshots in the same repository.
+const resticTag = "i-saw-that"
+
+// run runs a restic command against the repository and returns its stdout.
+func (c ResticConfig) run(args ...string) ([]byte, error) {
+	executable := c.Executable
+	if executable == "" {
+		executable = "restic"
+	}
+	global := []string{"--repo", c.Repository}
+	if c.PasswordFile != "" {
+		global = append(global, "--password-file", c.PasswordFile)
+	}
+
+	cmd := exec.Command(executable, append(global, args...)...)
+	// The password is passed in the environment so it is not in the arguments that
+	// other users can see.
+	if c.Password != "" {
+		cmd.Env = append(os.Environ(), "RESTIC_PASSWORD="+c.Password)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return output, fmt.Errorf("restic %s: %w: %s", args[0], err, message)
+		}
+		return output, fmt.Errorf("restic %s: %w", args[0], err)
+	}
+	return output, nil
+}
+
+// backup creates a snapshot of source and returns its ID.
+func (c ResticConfig) backup(source, watcher string, created time.Time, trigger BackupTrigger) (string, error) {
+	output, err := c.run(
+		"backup", "--json",
+		"--tag", resticTag,
+		"--tag", "watcher:"+watcher,
+		"--tag", "trigger:"+string(trigger),
+		"--time", created.Format(time.DateTime),
+		source,
+	)
+	if err != nil {
+		return "", err
+	}
+	return parseResticSummary(output)
+}
+
+// parseResticSummary returns the snapshot ID from the output of "restic backup --json",
+// which is a status message per line followed by a summary.
+func parseResticSummary(output []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var message struct {
+			MessageType string `json:"message_type"`
+			SnapshotID  string `json:"snapshot_id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			continue
+		}
+		if message.MessageType == "summary" && message.SnapshotID != "" {
+			return message.SnapshotID, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading restic output: %w", err)
+	}
+	return "", errors.New("restic did not report a snapshot ID")
+}
+
+// snapshotPath returns the path that a snapshot was made of.
+func (c ResticConfig) snapshotPath(id string) (string, error) {
+	output, err := c.run("snapshots", "--json", id)
+	if err != nil {
+		return "", err
+	}
+	var snapshots []struct {
+		Paths []string `json:"paths"`
+	}
+	if err := json.Unmarshal(output, &snapshots); err != nil {
+		return "", fmt.Errorf("error reading restic snapshots: %w", err)
+	}
+	if len(snapshots) == 0 || len(snapshots[0].Paths) == 0 {
+		return "", fmt.Errorf("restic snapshot %s not found", id)
+	}
+	return snapshots[0].Paths[0], nil
+}
+
+// extract writes the contents of a snapshot to target using the same layout as a
+// backup folder, a single file is placed inside target.
+func (c ResticConfig) extract(id, target string, singleFile bool) error {
+	path, err := c.snapshotPath(id)
+	if err != nil {
+		return err
+	}
+
+	if !singleFile {
+		_, err := c.run("restore", id+":"+resticPath(path), "--target", target)
+		return err
+	}
+
+	output, err := c.run("dump", id, resticPath(path))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(target, filepath.Base(path)), output, 0644)
+}
+
+// forget removes a snapshot and the data only it uses from the repository.
+func (c ResticConfig) forget(id string) error {
+	_, err := c.run("forget", "--prune", id)
+	return err
+}
+
+// resticPath converts a path to the form restic uses inside snapshots, Windows drive
+// letters become the first folder such as /C/Users.
+func resticPath(path string) string {
+	if volume := filepath.VolumeName(path); len(volume) == 2 && volume[1] == ':' {
+		path = "/" + volume[:1] + path[2:]
+	}
+	return filepath.ToSlash(path)
+}
+
+// sourceHash identifies the contents of the source, it is stored with restic backups
+// since there is no backup folder to compare the source with. Unchanged files are not
+// hashed again if cache is set.
+func sourceHash(source string, singleFile bool, algorithm HashAlgorithm, cache *scanCache) (string, error) {
+	if singleFile {
+		info, err := os.Stat(source)
+		if err != nil {
+			return "", err
+		}
+		return cache.hashFile(source, info, algorithm)
+	}
+	manifest, err := scanManifest(source, algorithm, cache, nil)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Hash(), nil
+}
+
+// createResticBackup stores the source in the restic repository and returns the
+// backup without a path.
+func (w *Watcher) createResticBackup(config ResticConfig, source string, created time.Time, trigger BackupTrigger) (Backup, error) {
+	config, err := w.resolveRestic(config)
+	if err != nil {
+		return Backup{}, err
+	}
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return Backup{}, err
+	}
+	// The hash is taken first so changes made while restic runs cause another backup.
+	algorithm := w.hashAlgorithm()
+	hash, err := w.hashSource(absSource, algorithm)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	Logf(w.Name, LogLevelInfo, "Creating restic snapshot of %s in %s", absSource, config.Repository)
+	id, err := config.backup(absSource, w.Name, created, trigger)
+	if err != nil {
+		return Backup{}, err
+	}
+	return Backup{ResticSnapshot: id, SourceHash: hash, HashAlgorithm: algorithm}, nil
+}
+
+// extractResticBackup restores a restic backup into a new temporary folder, the
+// caller removes the folder when done.
+func (w *Watcher) extractResticBackup(backup Backup) (string, error) {
+	w.mu.Lock()
+	config := w.Restic
+	w.mu.Unlock()
+	config, err := w.resolveRestic(config)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := os.MkdirTemp("", "i-saw-that-restic-*")
+	if err != nil {
+		return "", err
+	}
+	if err := config.extract(backup.ResticSnapshot, target, w.singleFile); err != nil {
+		os.RemoveAll(target)
+		return "", fmt.Errorf("error restoring restic snapshot %s: %w", backup.ResticSnapshot, err)
+	}
+	return target, nil
+}
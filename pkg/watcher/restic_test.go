@@ -0,0 +1,107 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseResticSummary(t *testing.T) {
+	t.Parallel()
+	output := strings.Join([]string{
+		`{"message_type":"status","percent_done":0.5}`,
+		`not json`,
+		`{"message_type":"summary","files_new":1,"snapshot_id":"4f2a9c1b"}`,
+	}, "\n")
+	id, err := parseResticSummary([]byte(output))
+	if err != nil {
+		t.Fatalf("Failed to parse summary: %v", err)
+	}
+	if id != "4f2a9c1b" {
+		t.Errorf("Expected snapshot ID '4f2a9c1b', got '%s'", id)
+	}
+
+	if _, err := parseResticSummary([]byte(`{"message_type":"status"}`)); err == nil {
+		t.Errorf("Expected an error for output without a summary")
+	}
+}
+
+// fakeRestic writes a script that records its arguments to a log file and prints a
+// backup summary, it returns the paths of the script and the log.
+func fakeRestic(t *testing.T) (string, string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The fake restic is a shell script")
+	}
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "restic.log")
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + logPath + "\n" +
+		"case \" $* \" in *\" backup \"*) echo '{\"message_type\":\"summary\",\"snapshot_id\":\"snap'$(wc -l < " + logPath + " | tr -d ' ')'\"}' ;; esac\n"
+	path := filepath.Join(dir, "restic")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake restic: %v", err)
+	}
+	return path, logPath
+}
+
+func TestResticBackup(t *testing.T) {
+	t.Parallel()
+	executable, logPath := fakeRestic(t)
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Restic = ResticConfig{Repository: "/repo", PasswordFile: "/password", Executable: executable}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	backup, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatalf("Failed to create restic backup")
+	}
+	if backup.ResticSnapshot != "snap1" {
+		t.Errorf("Expected snapshot 'snap1', got '%s'", backup.ResticSnapshot)
+	}
+	if _, err := os.Stat(watcher.BackupPath(backup)); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup folder for a restic backup")
+	}
+
+	metadata, err := loadDestinationMetadata(WatcherConfig.Destination)
+	if err != nil {
+		t.Fatalf("Failed to load metadata: %v", err)
+	}
+	if len(metadata) != 1 || metadata[0].ResticSnapshot != "snap1" {
+		t.Errorf("Expected the snapshot to be saved in the metadata, got %+v", metadata)
+	}
+
+	if matches, err := watcher.SourceMatchesLatestBackup(); err != nil || !matches {
+		t.Errorf("Expected the source to match the restic backup, got %t: %v", matches, err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 10)
+	if matches, err := watcher.SourceMatchesLatestBackup(); err != nil || matches {
+		t.Errorf("Expected a changed source to not match the restic backup, got %t: %v", matches, err)
+	}
+
+	if err := watcher.deleteBackup(backup); err != nil {
+		t.Fatalf("Failed to delete restic backup: %v", err)
+	}
+
+	log, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read restic log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 restic calls, got %q", lines)
+	}
+	for _, expected := range []string{"--repo /repo --password-file /password backup --json", "--tag watcher:" + WatcherConfig.Name, "--tag trigger:manual", WatcherConfig.Source} {
+		if !strings.Contains(lines[0], expected) {
+			t.Errorf("Expected backup call to contain '%s', got '%s'", expected, lines[0])
+		}
+	}
+	if !strings.HasSuffix(lines[1], "forget --prune snap1") {
+		t.Errorf("Expected the snapshot to be forgotten, got '%s'", lines[1])
+	}
+}
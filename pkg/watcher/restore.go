@@ -0,0 +1,206 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	cp "github.com/otiai10/copy"
+)
+
+// Deprecated: Use ErrSafetyBackup.
+var ErrPreRestoreBackup = ErrSafetyBackup
+
+// RestoreBackup replaces the contents of the source with a backup. A safety backup of
+// the current source is created first so the restore can be undone, it is returned
+// along with any error. The safety backup is empty if SkipSafetyBackups is set.
+func (w *Watcher) RestoreBackup(backupID string) (Backup, error) {
+	if err := w.checkSourceWritable(); err != nil {
+		return Backup{}, err
+	}
+	backup, err := w.FindBackup(backupID)
+	if err != nil {
+		return Backup{}, err
+	}
+	if err := w.checkSignedBackup(backup); err != nil {
+		return Backup{}, err
+	}
+	backupPath := w.BackupPath(backup)
+	if backup.ResticSnapshot != "" {
+		// Restic backups are extracted before the source is touched so a failure
+		// leaves it as it was.
+		if backupPath, err = w.extractResticBackup(backup); err != nil {
+			return Backup{}, err
+		}
+		defer os.RemoveAll(backupPath)
+	} else if _, err := os.Stat(backupPath); err != nil {
+		return Backup{}, fmt.Errorf("error reading backup: %w", err)
+	}
+
+	safety, err := w.safetyBackup(BackupTriggerPreRestore, "restoring backup "+backup.Path)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	w.mu.Lock()
+	source := w.Source
+	include := w.Include
+	w.mu.Unlock()
+
+	Logf(w.Name, LogLevelInfo, "Restoring backup %s to %s", backupPath, source)
+	root := source
+	if w.singleFile {
+		root = filepath.Dir(source)
+	}
+	if err := restoreInto(backupPath, source, w.singleFile, include); err != nil {
+		return safety, fmt.Errorf("error restoring backup, the previous source is in backup %s: %w", safety.Path, err)
+	}
+	if err := backupChunks(backup).reassemble(root); err != nil {
+		return safety, fmt.Errorf("error restoring chunked files, the previous source is in backup %s: %w", safety.Path, err)
+	}
+	Logf(w.Name, LogLevelInfo, "Restored backup %s", backupPath)
+	message := ""
+	if safety.Path != "" {
+		message = fmt.Sprintf("The previous source was saved as %s", safety.Path)
+	}
+	w.recordEvent(HistoryEvent{Type: HistoryBackupRestored, Backup: backup.Path, Message: message})
+	return safety, nil
+}
+
+// Added to the name of a restored file that is not allowed to overwrite the file in the
+// source.
+const restoredSuffix = ".restored"
+
+// RestoreFile restores a single file from a backup, relPath is relative to the source
+// and uses forward slashes. If overwrite is false and the file exists in the source the
+// backup is restored next to it with .restored added to its name. Overwriting a file
+// creates a backup of the source first, like restoring a whole backup. The path the
+// file was restored to is returned.
+func (w *Watcher) RestoreFile(backupID, relPath string, overwrite bool) (string, error) {
+	local := filepath.FromSlash(relPath)
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("%s is not a path inside the source", relPath)
+	}
+	if err := w.checkSourceWritable(); err != nil {
+		return "", err
+	}
+	backup, err := w.FindBackup(backupID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := w.checkSignedBackup(backup); err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	source := w.Source
+	w.mu.Unlock()
+	target := filepath.Join(source, local)
+	if w.singleFile {
+		if local != filepath.Base(source) {
+			return "", fmt.Errorf("%s is not in the backup", relPath)
+		}
+		target = source
+	}
+
+	backupPath := w.BackupPath(backup)
+	if backup.ResticSnapshot != "" {
+		if backupPath, err = w.extractResticBackup(backup); err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(backupPath)
+	}
+	backupFile := filepath.Join(backupPath, local)
+	info, err := os.Stat(backupFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s from backup: %w", relPath, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a folder", relPath)
+	}
+
+	message := fmt.Sprintf("Restored %s", relPath)
+	if _, err := os.Lstat(target); err == nil {
+		if overwrite {
+			safety, err := w.safetyBackup(BackupTriggerPreRestore, "restoring "+relPath)
+			if err != nil {
+				return "", err
+			}
+			if safety.Path != "" {
+				message += fmt.Sprintf(", the previous source was saved as %s", safety.Path)
+			}
+		} else {
+			target += restoredSuffix
+			message += fmt.Sprintf(" as %s", filepath.Base(target))
+		}
+	}
+
+	Logf(w.Name, LogLevelInfo, "Restoring %s from backup %s to %s", relPath, backup.Path, target)
+	options := cp.Options{
+		PreserveTimes:     true,
+		PermissionControl: cp.AddPermission(0200),
+	}
+	if err := cp.Copy(backupFile, target, options); err != nil {
+		return "", fmt.Errorf("error restoring %s: %w", relPath, err)
+	}
+	if chunks := backupChunks(backup); chunks.contains(filepath.ToSlash(filepath.Clean(local))) {
+		if err := chunks.store.reassembleInPlace(target); err != nil {
+			os.Remove(target)
+			return "", fmt.Errorf("error restoring %s: %w", relPath, err)
+		}
+	}
+	w.recordEvent(HistoryEvent{Type: HistoryBackupRestored, Backup: backup.Path, Message: message})
+	return target, nil
+}
+
+// restoreInto replaces the contents of source with the contents of a backup folder.
+// With include patterns only the included files are replaced, the rest of the source
+// was never backed up.
+func restoreInto(backupPath, source string, singleFile bool, include IncludePatterns) error {
+	// Restored files are made writable since the backup may be read-only.
+	options := cp.Options{
+		PreserveTimes:     true,
+		PermissionControl: cp.AddPermission(0200),
+	}
+
+	if singleFile {
+		return cp.Copy(filepath.Join(backupPath, filepath.Base(source)), source, options)
+	}
+
+	// The sidecar and checksum files are only skipped if they were written by the
+	// watcher, a source that had its own backup.json has it restored.
+	if sidecar, err := ReadBackupSidecar(backupPath); err == nil && sidecar.ManifestHash != "" {
+		generated := sidecar.generatedFiles()
+		options.Skip = func(info os.FileInfo, src, dest string) (bool, error) {
+			return filepath.Dir(src) == filepath.Clean(backupPath) && slices.Contains(generated, filepath.Base(src)), nil
+		}
+	}
+
+	if include.enabled() {
+		files, err := sourceFiles(source)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if include.includesFile(source, file) {
+				if err := os.Remove(file); err != nil {
+					return err
+				}
+			}
+		}
+		return cp.Copy(backupPath, source, options)
+	}
+
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(source, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return cp.Copy(backupPath, source, options)
+}
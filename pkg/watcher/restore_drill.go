@@ -0,0 +1,170 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// Number of the newest backups a restore drill picks from by default.
+const defaultRestoreDrillRecent = 5
+
+var ErrNoBackupsToDrill = errors.New("there are no backups to restore")
+
+// RestoreDrillConfig periodically restores a random recent backup into a temporary
+// folder and checks the restored files against the manifest of the backup, proving
+// that backups can be restored without touching the source.
+type RestoreDrillConfig struct {
+	// Time between drills, 0 turns them off.
+	Interval Duration `json:"interval,omitempty" yaml:"interval,omitempty" toml:"interval,omitempty"`
+	// Number of the newest backups to pick from, 5 if it is 0.
+	Recent int `json:"recent,omitempty" yaml:"recent,omitempty" toml:"recent,omitempty"`
+}
+
+func (c RestoreDrillConfig) enabled() bool {
+	return c.Interval > 0
+}
+
+func (c RestoreDrillConfig) validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("restore drill interval must be at least 0 seconds")
+	}
+	if c.Recent < 0 {
+		return fmt.Errorf("restore drill can not pick from a negative number of backups")
+	}
+	return nil
+}
+
+func (c RestoreDrillConfig) recent() int {
+	if c.Recent > 0 {
+		return c.Recent
+	}
+	return defaultRestoreDrillRecent
+}
+
+// RunRestoreDrill restores a backup into a temporary folder and checks the restored
+// files against the manifest hash in the sidecar of the backup. With an empty backupID
+// a random one of the newest backups is used. Restic backups can not be checked this
+// way. The temporary folder is removed afterwards.
+func (w *Watcher) RunRestoreDrill(backupID string) (BackupVerification, error) {
+	var backup Backup
+	if backupID != "" {
+		var err error
+		if backup, err = w.FindBackup(backupID); err != nil {
+			return BackupVerification{}, err
+		}
+	} else {
+		w.mu.Lock()
+		candidates := []Backup{}
+		for _, backup := range w.Metadata[max(len(w.Metadata)-w.RestoreDrill.recent(), 0):] {
+			if backup.ResticSnapshot == "" {
+				candidates = append(candidates, backup)
+			}
+		}
+		w.mu.Unlock()
+		if len(candidates) == 0 {
+			return BackupVerification{}, ErrNoBackupsToDrill
+		}
+		backup = candidates[rand.Intn(len(candidates))]
+	}
+
+	result := w.restoreDrill(backup)
+	message := string(result.Status)
+	if result.Error != "" {
+		message += ": " + result.Error
+	}
+	w.recordEvent(HistoryEvent{Type: HistoryRestoreDrill, Backup: backup.Path, Message: message})
+	return result, nil
+}
+
+func (w *Watcher) restoreDrill(backup Backup) BackupVerification {
+	result := BackupVerification{Backup: backup}
+	if backup.ResticSnapshot != "" {
+		result.Status = VerifyStatusUnverifiable
+		result.Error = "stored in a restic repository, use restic check to verify it"
+		return result
+	}
+
+	path := w.BackupPath(backup)
+	if _, err := os.Stat(path); err != nil {
+		result.Status = VerifyStatusMissing
+		if !errors.Is(err, os.ErrNotExist) {
+			result.Error = err.Error()
+		}
+		return result
+	}
+	sidecar, err := ReadBackupSidecar(path)
+	if err != nil || sidecar.ManifestHash == "" {
+		result.Status = VerifyStatusUnverifiable
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	dir, err := os.MkdirTemp("", "i-saw-that-drill-*")
+	if err != nil {
+		result.Status = VerifyStatusRestoreFailed
+		result.Error = err.Error()
+		return result
+	}
+	defer os.RemoveAll(dir)
+
+	// Everything in the backup is restored, include patterns only limit what is
+	// replaced in a real source.
+	target := dir
+	if w.singleFile {
+		w.mu.Lock()
+		target = filepath.Join(dir, filepath.Base(w.Source))
+		w.mu.Unlock()
+	}
+	Logf(w.Name, LogLevelDebug, "Restoring backup %s to %s for a restore drill", path, dir)
+	if err := restoreInto(path, target, w.singleFile, nil); err != nil {
+		result.Status = VerifyStatusRestoreFailed
+		result.Error = err.Error()
+		return result
+	}
+	manifest, err := buildManifest(dir, sidecar.HashAlgorithm, nil)
+	if err != nil {
+		result.Status = VerifyStatusRestoreFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = VerifyStatusOK
+	if manifest.Hash() != sidecar.ManifestHash {
+		result.Status = VerifyStatusModified
+		return result
+	}
+	// The manifest covers the chunk lists of chunked files, the chunks are checked by
+	// putting the files back together.
+	if err := backupChunks(backup).reassemble(dir); err != nil {
+		result.Status = VerifyStatusRestoreFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// runScheduledRestoreDrill runs a restore drill for RestoreDrill and warns if the
+// backup could not be restored intact.
+func (w *Watcher) runScheduledRestoreDrill() {
+	result, err := w.RunRestoreDrill("")
+	switch {
+	case errors.Is(err, ErrNoBackupsToDrill):
+		Logf(w.Name, LogLevelDebug, "Skipping restore drill: %v", err)
+	case err != nil:
+		Logf(w.Name, LogLevelWarn, "Error running restore drill: %v", err)
+	case result.Failed():
+		message := fmt.Sprintf("Restore drill of backup %s failed: %s", result.Backup.Path, result.Status)
+		if result.Error != "" {
+			message += ", " + result.Error
+		}
+		w.notifyWarning("%s", message)
+	case result.Status == VerifyStatusUnverifiable:
+		Logf(w.Name, LogLevelInfo, "Restore drill could not check backup %s", result.Backup.Path)
+	default:
+		Logf(w.Name, LogLevelInfo, "Restore drill of backup %s passed", result.Backup.Path)
+	}
+}
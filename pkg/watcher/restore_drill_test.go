@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestoreDrill(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if _, err := watcher.RunRestoreDrill(""); !errors.Is(err, ErrNoBackupsToDrill) {
+		t.Errorf("Expected no backups to drill, got %v", err)
+	}
+
+	for i := range 3 {
+		CreateDummyFile(t, WatcherConfig.Source, filepath.Join("folder", string(rune('a'+i))), 1024)
+		watcher.CreateBackup()
+	}
+	backups := watcher.ListBackups()
+
+	// Only the newest backup can be picked.
+	watcher.RestoreDrill.Recent = 1
+	result, err := watcher.RunRestoreDrill("")
+	if err != nil {
+		t.Fatalf("Failed to run restore drill: %v", err)
+	}
+	if result.Status != VerifyStatusOK || result.Backup.Path != backups[2].Path {
+		t.Errorf("Expected the newest backup to pass, got %+v", result)
+	}
+
+	// A backup changed after it was made restores with different contents.
+	if err := os.WriteFile(filepath.Join(watcher.BackupPath(backups[0]), "folder", "a"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to change backup: %v", err)
+	}
+	result, err = watcher.RunRestoreDrill(backups[0].Path)
+	if err != nil {
+		t.Fatalf("Failed to run restore drill: %v", err)
+	}
+	if result.Status != VerifyStatusModified || !result.Failed() {
+		t.Errorf("Expected the changed backup to fail, got %+v", result)
+	}
+
+	history := watcher.History()
+	if last := history[len(history)-1]; last.Type != HistoryRestoreDrill || last.Backup != backups[0].Path {
+		t.Errorf("Expected the drill in the history, got %+v", last)
+	}
+}
+
+func TestRestoreDrillSingleFile(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.Source = filepath.Join(WatcherConfig.TempPath, "save.dat")
+	if err := os.WriteFile(WatcherConfig.Source, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.CreateBackup()
+
+	result, err := watcher.RunRestoreDrill("")
+	if err != nil {
+		t.Fatalf("Failed to run restore drill: %v", err)
+	}
+	if result.Status != VerifyStatusOK {
+		t.Errorf("Expected the backup to pass, got %+v", result)
+	}
+}
@@ -0,0 +1,166 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRestoreBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.ReadOnlyBackups = true
+
+	CreateDummyFile(t, WatcherConfig.Source, "folder/file1.txt", 1024)
+	watcher.CreateBackup()
+	restored := watcher.Metadata[0]
+
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 1024)
+	if err := os.RemoveAll(filepath.Join(WatcherConfig.Source, "folder")); err != nil {
+		t.Fatalf("Failed to remove folder: %v", err)
+	}
+
+	safety, err := watcher.RestoreBackup(restored.Path)
+	if err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	CompareSourceAndDestination(t, WatcherConfig.Source, watcher.BackupPath(restored))
+
+	// The state before the restore is kept in the safety backup.
+	if safety.Name != "Before restore" || safety.Note != "Before restoring backup "+restored.Path {
+		t.Errorf("Expected the safety backup to be labeled, got '%s' '%s'", safety.Name, safety.Note)
+	}
+	if _, err := os.Stat(filepath.Join(watcher.BackupPath(safety), "file2.txt")); err != nil {
+		t.Errorf("Expected the safety backup to contain the replaced file: %v", err)
+	}
+
+	// Restored files must be writable even though the backup is read-only.
+	CreateDummyFile(t, WatcherConfig.Source, "folder/file1.txt", 10)
+}
+
+// failingCopyEngine fails every copy, like a destination that stopped accepting writes.
+type failingCopyEngine struct {
+	goCopyEngine
+}
+
+func (failingCopyEngine) Copy(source, destination string) error {
+	return errors.New("destination is gone")
+}
+
+// noSleepClock does not wait between copy attempts.
+type noSleepClock struct {
+	realClock
+}
+
+func (noSleepClock) Sleep(d time.Duration) {}
+
+func TestRestoreAbortsWhenSafetyBackupFails(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+	restored := watcher.Metadata[0]
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 1024)
+
+	watcher.CopyEngine = failingCopyEngine{}
+	watcher.clock = noSleepClock{}
+	if _, err := watcher.RestoreBackup(restored.Path); !errors.Is(err, ErrSafetyBackup) {
+		t.Fatalf("Expected the restore to fail without a safety backup, got %v", err)
+	}
+	if len(watcher.Metadata) != 1 {
+		t.Errorf("Expected the failed safety backup not to be recorded, got %d backups", len(watcher.Metadata))
+	}
+	if _, err := os.Stat(filepath.Join(WatcherConfig.Source, "file2.txt")); err != nil {
+		t.Errorf("Expected the source to be left alone: %v", err)
+	}
+}
+
+func TestRestoreSingleFile(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.Source = filepath.Join(WatcherConfig.TempPath, "save.dat")
+	if err := os.WriteFile(WatcherConfig.Source, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.CreateBackup()
+
+	if err := os.WriteFile(WatcherConfig.Source, []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if _, err := watcher.RestoreBackup(watcher.Metadata[0].Path); err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	if data, _ := os.ReadFile(WatcherConfig.Source); string(data) != "original" {
+		t.Errorf("Expected the original contents to be restored, got '%s'", data)
+	}
+}
+
+func TestRestoreFile(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	path := filepath.Join(WatcherConfig.Source, "folder", "file1.txt")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	os.WriteFile(path, []byte("original"), 0644)
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 10)
+	watcher.CreateBackup()
+	os.WriteFile(path, []byte("changed"), 0644)
+
+	// Without overwrite the changed file is kept.
+	restored, err := watcher.RestoreFile("1", "folder/file1.txt", false)
+	if err != nil {
+		t.Fatalf("Failed to restore file: %v", err)
+	}
+	if restored != path+restoredSuffix {
+		t.Errorf("Expected the file to be restored next to the source, got %s", restored)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "changed" {
+		t.Errorf("Expected the file in the source to be kept, got '%s'", data)
+	}
+	if data, _ := os.ReadFile(restored); string(data) != "original" {
+		t.Errorf("Expected the original contents to be restored, got '%s'", data)
+	}
+
+	// Overwriting backs up the source first.
+	if restored, err = watcher.RestoreFile("1", "folder/file1.txt", true); err != nil || restored != path {
+		t.Fatalf("Failed to restore file over the source: %s, %v", restored, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "original" {
+		t.Errorf("Expected the original contents to be restored, got '%s'", data)
+	}
+	backups := watcher.ListBackups()
+	if len(backups) != 2 || backups[1].Name != "Before restore" {
+		t.Errorf("Expected a backup before overwriting the file, got %+v", backups)
+	}
+
+	// Files that were deleted are restored under their own name.
+	os.Remove(filepath.Join(WatcherConfig.Source, "file2.txt"))
+	if restored, err = watcher.RestoreFile("1", "file2.txt", false); err != nil || restored != filepath.Join(WatcherConfig.Source, "file2.txt") {
+		t.Errorf("Failed to restore deleted file: %s, %v", restored, err)
+	}
+
+	for _, relPath := range []string{"../outside.txt", "folder", "missing.txt"} {
+		if _, err := watcher.RestoreFile("1", relPath, true); err == nil {
+			t.Errorf("Expected an error restoring %s", relPath)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	// Files at least this large that were partly copied when a copy failed are continued
+	// from where the copy stopped instead of being copied again.
+	resumeMinSize = 16 * 1024 * 1024
+	// Bytes at the end of a partial copy that are compared with the source before the
+	// copy is continued.
+	resumeCheckSize = 1024 * 1024
+)
+
+// withResume returns the copy engine that retries a copy which failed part way, such as
+// when the connection to a network destination dropped. It keeps the files that were
+// already copied and continues large files that were partly copied. rsync already skips
+// the files it copied, robocopy is switched to restartable mode.
+func withResume(engine CopyEngine) CopyEngine {
+	switch e := engine.(type) {
+	case goCopyEngine:
+		e.resume = true
+		return e
+	case robocopyCopyEngine:
+		e.restartable = true
+		return e
+	}
+	return engine
+}
+
+// resumeCopy finishes copying src, which has info, to destination if an earlier
+// attempt copied all or part of it. False is returned if the file has to be copied.
+func resumeCopy(info os.FileInfo, src, destination string) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	destinationInfo, err := os.Lstat(destination)
+	if err != nil || !destinationInfo.Mode().IsRegular() {
+		return false
+	}
+	// Modification times are set once a file is completely copied.
+	if destinationInfo.Size() == info.Size() && destinationInfo.ModTime().Equal(info.ModTime()) {
+		return true
+	}
+	if info.Size() < resumeMinSize || destinationInfo.Size() == 0 || destinationInfo.Size() >= info.Size() {
+		return false
+	}
+
+	if err := appendRemaining(src, destination, destinationInfo.Size()); err != nil {
+		Logf("", LogLevelDebug, "Copying %s again instead of resuming it: %v", src, err)
+		return false
+	}
+	if err := errors.Join(os.Chmod(destination, info.Mode().Perm()), os.Chtimes(destination, info.ModTime(), info.ModTime())); err != nil {
+		Logf("", LogLevelDebug, "Copying %s again instead of resuming it: %v", src, err)
+		return false
+	}
+	Logf("", LogLevelDebug, "Resumed copying %s from %s", src, FormatBytes(destinationInfo.Size()))
+	return true
+}
+
+// appendRemaining copies the part of src after offset to the end of destination, which
+// holds the first offset bytes of src.
+func appendRemaining(src, destination string, offset int64) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(destination, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	// The end of the partial copy has to match the source, otherwise the source changed
+	// since or the partial copy is damaged.
+	check := min(offset, resumeCheckSize)
+	expected := make([]byte, check)
+	actual := make([]byte, check)
+	if _, err := source.ReadAt(expected, offset-check); err != nil {
+		return errors.Join(err, target.Close())
+	}
+	if _, err := target.ReadAt(actual, offset-check); err != nil {
+		return errors.Join(err, target.Close())
+	}
+	if !bytes.Equal(expected, actual) {
+		return errors.Join(fmt.Errorf("partial copy does not match the source"), target.Close())
+	}
+
+	if _, err := source.Seek(offset, io.SeekStart); err != nil {
+		return errors.Join(err, target.Close())
+	}
+	if _, err := target.Seek(offset, io.SeekStart); err != nil {
+		return errors.Join(err, target.Close())
+	}
+	_, err = io.Copy(target, source)
+	return errors.Join(err, target.Close())
+}
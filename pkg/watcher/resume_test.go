@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResumeCopy(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	CreateDummyFile(t, dir, "source/large.bin", resumeMinSize+4096)
+	src := filepath.Join(dir, "source", "large.bin")
+	content, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("Failed to read source: %v", err)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		t.Fatalf("Failed to stat source: %v", err)
+	}
+
+	partial := filepath.Join(dir, "partial.bin")
+	if err := os.WriteFile(partial, content[:resumeMinSize/2], 0644); err != nil {
+		t.Fatalf("Failed to write partial copy: %v", err)
+	}
+	if !resumeCopy(info, src, partial) {
+		t.Fatal("Expected the partial copy to be resumed")
+	}
+	resumed, err := os.ReadFile(partial)
+	if err != nil || !bytes.Equal(resumed, content) {
+		t.Errorf("Expected the resumed copy to match the source: %v", err)
+	}
+	if resumedInfo, err := os.Stat(partial); err != nil || !resumedInfo.ModTime().Equal(info.ModTime()) {
+		t.Errorf("Expected the modification time of the source, got %v: %v", resumedInfo.ModTime(), err)
+	}
+
+	// A partial copy that does not match the source is copied again.
+	damaged := filepath.Join(dir, "damaged.bin")
+	changed := bytes.Clone(content[:resumeMinSize/2])
+	changed[len(changed)-1]++
+	if err := os.WriteFile(damaged, changed, 0644); err != nil {
+		t.Fatalf("Failed to write partial copy: %v", err)
+	}
+	if resumeCopy(info, src, damaged) {
+		t.Error("Expected a partial copy that does not match to be copied again")
+	}
+	if resumeCopy(info, src, filepath.Join(dir, "missing.bin")) {
+		t.Error("Expected a missing file to be copied")
+	}
+}
+
+func TestGoCopyEngineResume(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source")
+	destination := filepath.Join(dir, "destination")
+	CreateDummyFile(t, source, "copied.txt", 100)
+	CreateDummyFile(t, source, "folder/missing.txt", 100)
+	if err := (goCopyEngine{}).Copy(source, destination); err != nil {
+		t.Fatalf("Failed to copy: %v", err)
+	}
+	// The retry must keep a file the first attempt finished, so its content is changed
+	// to tell the copies apart.
+	copied := filepath.Join(destination, "copied.txt")
+	info, err := os.Stat(copied)
+	if err != nil {
+		t.Fatalf("Failed to stat copy: %v", err)
+	}
+	marker := bytes.Repeat([]byte("x"), 100)
+	if err := os.WriteFile(copied, marker, 0644); err != nil {
+		t.Fatalf("Failed to write copy: %v", err)
+	}
+	if err := os.Chtimes(copied, time.Now(), info.ModTime()); err != nil {
+		t.Fatalf("Failed to set time: %v", err)
+	}
+	if err := os.Remove(filepath.Join(destination, "folder", "missing.txt")); err != nil {
+		t.Fatalf("Failed to remove copy: %v", err)
+	}
+
+	if err := withResume(goCopyEngine{}).Copy(source, destination); err != nil {
+		t.Fatalf("Failed to resume copy: %v", err)
+	}
+	if data, _ := os.ReadFile(copied); !bytes.Equal(data, marker) {
+		t.Error("Expected the finished file to be kept")
+	}
+	if _, err := os.Stat(filepath.Join(destination, "folder", "missing.txt")); err != nil {
+		t.Errorf("Expected the missing file to be copied: %v", err)
+	}
+}
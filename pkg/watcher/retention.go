@@ -0,0 +1,294 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// RetentionPolicy decides which backups are kept. A backup is kept if any of the rules
+// keep it, the newest backup is always kept. A policy with every rule set to zero keeps
+// everything.
+type RetentionPolicy struct {
+	// Number of most recent backups to keep.
+	KeepLast int `json:"keep_last,omitempty" yaml:"keep_last,omitempty" toml:"keep_last,omitempty"`
+	// Number of hours, days, weeks and months to keep the newest backup of.
+	KeepHourly  int `json:"keep_hourly,omitempty" yaml:"keep_hourly,omitempty" toml:"keep_hourly,omitempty"`
+	KeepDaily   int `json:"keep_daily,omitempty" yaml:"keep_daily,omitempty" toml:"keep_daily,omitempty"`
+	KeepWeekly  int `json:"keep_weekly,omitempty" yaml:"keep_weekly,omitempty" toml:"keep_weekly,omitempty"`
+	KeepMonthly int `json:"keep_monthly,omitempty" yaml:"keep_monthly,omitempty" toml:"keep_monthly,omitempty"`
+	// Days pruned backups are kept in the .trash folder of their destination before they
+	// are deleted, 0 deletes them right away.
+	TrashDays int `json:"trash_days,omitempty" yaml:"trash_days,omitempty" toml:"trash_days,omitempty"`
+}
+
+func (p RetentionPolicy) validate() error {
+	if p.KeepLast < 0 || p.KeepHourly < 0 || p.KeepDaily < 0 || p.KeepWeekly < 0 || p.KeepMonthly < 0 {
+		return fmt.Errorf("retention can not keep a negative number of backups")
+	}
+	if p.TrashDays < 0 {
+		return fmt.Errorf("retention can not keep backups in the trash for a negative number of days")
+	}
+	return nil
+}
+
+// trashGrace returns how long pruned backups stay in the trash.
+func (p RetentionPolicy) trashGrace() time.Duration {
+	return time.Duration(p.TrashDays) * 24 * time.Hour
+}
+
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepLast > 0 || p.KeepHourly > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0
+}
+
+// prunable returns the backups that the policy does not keep, pinned backups are always
+// kept. Backups must be ordered from oldest to newest.
+func (p RetentionPolicy) prunable(backups []Backup) []Backup {
+	if !p.Enabled() || len(backups) == 0 {
+		return nil
+	}
+
+	type bucketRule struct {
+		remaining int
+		bucket    func(time.Time) string
+		last      string
+	}
+	rules := []*bucketRule{
+		{remaining: p.KeepHourly, bucket: func(t time.Time) string { return t.Format("2006-01-02 15") }},
+		{remaining: p.KeepDaily, bucket: func(t time.Time) string { return t.Format("2006-01-02") }},
+		{remaining: p.KeepWeekly, bucket: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%d", year, week)
+		}},
+		{remaining: p.KeepMonthly, bucket: func(t time.Time) string { return t.Format("2006-01") }},
+	}
+
+	prunable := []Backup{}
+	// Walk from newest to oldest so each bucket keeps its newest backup.
+	for i := len(backups) - 1; i >= 0; i-- {
+		backup := backups[i]
+		created := BackupTime(backup)
+		keep := i == len(backups)-1 || len(backups)-1-i < p.KeepLast
+
+		for _, rule := range rules {
+			if rule.remaining <= 0 {
+				continue
+			}
+			if bucket := rule.bucket(created); bucket != rule.last {
+				rule.last = bucket
+				rule.remaining--
+				keep = true
+			}
+		}
+
+		if !keep && !backup.Pinned {
+			prunable = append(prunable, backup)
+		}
+	}
+
+	// Return the backups in the same order they were given.
+	slices.Reverse(prunable)
+	return prunable
+}
+
+// PrunedBackup is a backup that was or would be deleted by Prune.
+type PrunedBackup struct {
+	Backup Backup `json:"backup"`
+	// Size of the backup in bytes.
+	Size int64 `json:"size"`
+}
+
+type PruneResult struct {
+	DryRun bool           `json:"dry_run"`
+	Pruned []PrunedBackup `json:"pruned"`
+	// Total size of the pruned backups in bytes.
+	Reclaimed int64 `json:"reclaimed"`
+	// True if the backups were moved to the trash, their space is only reclaimed once
+	// the trash is emptied.
+	Trashed bool `json:"trashed,omitempty"`
+}
+
+// Prune deletes the backups that are not kept by the retention policy. With dryRun
+// nothing is deleted and the result lists what would have been deleted. The metadata
+// is only updated for backups that were deleted successfully. ErrRetentionPaused is
+// returned after a mass change until retention is resumed.
+func (w *Watcher) Prune(dryRun bool) (PruneResult, error) {
+	w.mu.Lock()
+	if w.massChange != "" && !dryRun {
+		w.mu.Unlock()
+		return PruneResult{Pruned: []PrunedBackup{}}, ErrRetentionPaused
+	}
+	policy := w.Retention
+	prunable := policy.prunable(w.Metadata)
+	w.mu.Unlock()
+
+	result := PruneResult{DryRun: dryRun, Pruned: []PrunedBackup{}, Trashed: policy.TrashDays > 0}
+	var errs error
+	deleted := map[string]bool{}
+	destinations := map[string]bool{}
+	for _, backup := range prunable {
+		path := w.BackupPath(backup)
+		// The size of a restic backup is not known since it shares data with others.
+		var size int64
+		if backup.ResticSnapshot == "" {
+			var err error
+			if size, err = DirSize(path); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("error measuring backup %s: %w", backup.Path, err))
+				continue
+			}
+		}
+
+		if !dryRun && result.Trashed {
+			if err := w.trashBackup(backup); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("error moving backup %s to the trash: %w", backup.Path, err))
+				continue
+			}
+			Logf(w.Name, LogLevelInfo, "Moved backup %s to the trash", path)
+			w.recordEvent(HistoryEvent{Type: HistoryBackupPruned, Backup: backup.Path, Message: "Moved to the trash"})
+		} else if !dryRun {
+			if err := w.deleteBackup(backup); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("error deleting backup %s: %w", backup.Path, err))
+				continue
+			}
+			Logf(w.Name, LogLevelInfo, "Pruned backup %s", path)
+			w.recordEvent(HistoryEvent{Type: HistoryBackupPruned, Backup: backup.Path})
+		}
+		if !dryRun {
+			deleted[path] = true
+			destinations[backup.Destination] = true
+		}
+
+		result.Pruned = append(result.Pruned, PrunedBackup{Backup: backup, Size: size})
+		result.Reclaimed += size
+	}
+
+	if len(deleted) > 0 {
+		w.mu.Lock()
+		w.Metadata = slices.DeleteFunc(w.Metadata, func(backup Backup) bool {
+			return deleted[w.BackupPath(backup)]
+		})
+		w.mu.Unlock()
+
+		for destination := range destinations {
+			if err := w.saveMetadata(destination); err != nil {
+				errs = errors.Join(errs, err)
+			}
+		}
+		// The trash only holds the copies in the destinations.
+		pruned := []Backup{}
+		for _, backup := range result.Pruned {
+			pruned = append(pruned, backup.Backup)
+		}
+		errs = errors.Join(errs, w.pruneRemoteCopies(pruned))
+	}
+
+	// Backups that have been in the trash long enough are deleted with every prune, and
+	// all of them once the trash is turned off.
+	if !dryRun {
+		for _, destination := range w.destinations() {
+			if err := w.purgeTrash(destination, policy.trashGrace()); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("error emptying trash: %w", err))
+			}
+			if err := w.collectChunks(destination); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("error deleting unused chunks: %w", err))
+			}
+		}
+	}
+	return result, errs
+}
+
+// PinBackup pins or unpins a backup, pinned backups are never deleted by pruning.
+func (w *Watcher) PinBackup(backupID string, pinned bool) error {
+	return w.updateBackup(backupID, func(backup *Backup) {
+		backup.Pinned = pinned
+	})
+}
+
+// deleteBackup removes a backup folder along with any year or month folders of a
+// nested layout that are left empty. Restic and filesystem snapshots are removed with
+// their own tools.
+func (w *Watcher) deleteBackup(backup Backup) error {
+	if backup.ResticSnapshot != "" {
+		w.mu.Lock()
+		config := w.Restic
+		w.mu.Unlock()
+		config, err := w.resolveRestic(config)
+		if err != nil {
+			return err
+		}
+		return config.forget(backup.ResticSnapshot)
+	}
+	if backup.Snapshot == SnapshotModeZFS {
+		_, err := runSnapshotTool("zfs", "destroy", backup.SnapshotName)
+		return err
+	}
+
+	path := w.BackupPath(backup)
+	w.mu.Lock()
+	w.recentBackups[path] = w.clock.Now()
+	w.mu.Unlock()
+
+	if backup.Snapshot == SnapshotModeBtrfs {
+		if err := btrfsDelete(path); err != nil {
+			return err
+		}
+	} else {
+		if err := unprotectBackup(path); err != nil {
+			return err
+		}
+		if err := w.fs.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	w.removeEmptyParents(backup.Destination, path)
+	return nil
+}
+
+// removeEmptyParents removes the year or month folders of a nested layout that held a
+// deleted backup if they are left empty.
+func (w *Watcher) removeEmptyParents(destination, path string) {
+	destination = filepath.Clean(destination)
+	for parent := filepath.Dir(path); parent != destination && isPathWithin(destination, parent); parent = filepath.Dir(parent) {
+		// Remove fails if the folder is not empty, which ends the cleanup.
+		if err := w.fs.Remove(parent); err != nil {
+			break
+		}
+	}
+}
+
+// DirSize returns the total size of the files inside path.
+func DirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// FormatBytes formats a size using binary units, such as "1.5 MiB".
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,239 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func backupsAt(times ...time.Time) []Backup {
+	backups := []Backup{}
+	for _, t := range times {
+		backups = append(backups, Backup{
+			Timestamp: float64(t.UnixNano()) / 1e9,
+			Path:      t.Format(DefaultFolderFormat),
+		})
+	}
+	return backups
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	backups := backupsAt(
+		start,
+		start.Add(time.Hour),
+		start.Add(24*time.Hour),
+		start.Add(25*time.Hour),
+		start.Add(48*time.Hour),
+	)
+
+	tests := []struct {
+		name     string
+		policy   RetentionPolicy
+		expected int
+	}{
+		{"disabled", RetentionPolicy{}, 0},
+		{"keep last", RetentionPolicy{KeepLast: 2}, 3},
+		{"keep daily", RetentionPolicy{KeepDaily: 2}, 3},
+		{"keep daily and last", RetentionPolicy{KeepLast: 3, KeepDaily: 3}, 1},
+		{"keep monthly", RetentionPolicy{KeepMonthly: 1}, 4},
+	}
+	for _, test := range tests {
+		prunable := test.policy.prunable(backups)
+		if len(prunable) != test.expected {
+			t.Errorf("%s: Expected %d prunable backups, got %d", test.name, test.expected, len(prunable))
+		}
+		for _, backup := range prunable {
+			if backup.Path == backups[len(backups)-1].Path {
+				t.Errorf("%s: The newest backup must never be pruned", test.name)
+			}
+		}
+	}
+
+	// The daily rule keeps the newest backup of each day.
+	prunable := RetentionPolicy{KeepDaily: 3}.prunable(backups)
+	if len(prunable) != 2 || prunable[0].Path != backups[0].Path || prunable[1].Path != backups[2].Path {
+		t.Errorf("Expected the older backup of each day to be pruned, got %+v", prunable)
+	}
+
+	// Pinned backups are kept whatever the rules say.
+	backups[0].Pinned = true
+	prunable = RetentionPolicy{KeepLast: 1}.prunable(backups)
+	if len(prunable) != 3 || prunable[0].Path != backups[1].Path {
+		t.Errorf("Expected the pinned backup to be kept, got %+v", prunable)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.FolderFormat = "2006/01/2006-01-02_15-04-05.000000"
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	for i := range 3 {
+		CreateDummyFile(t, WatcherConfig.Source, filepath.Join("folder", string(rune('a'+i))), 1024)
+		watcher.CreateBackup()
+	}
+	oldest := watcher.Metadata[0]
+	watcher.Retention = RetentionPolicy{KeepLast: 2}
+
+	result, err := watcher.Prune(true)
+	if err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+	// The reclaimed space includes the backup's sidecar.
+	if len(result.Pruned) != 1 || result.Reclaimed <= 1024 || result.Pruned[0].Backup.Path != oldest.Path {
+		t.Errorf("Unexpected dry run result: %+v", result)
+	}
+	if _, err := os.Stat(watcher.BackupPath(oldest)); err != nil || len(watcher.Metadata) != 3 {
+		t.Fatalf("Expected a dry run to not delete anything")
+	}
+
+	if _, err := watcher.Prune(false); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+	if _, err := os.Stat(watcher.BackupPath(oldest)); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest backup to be deleted")
+	}
+
+	reloaded, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if backups := reloaded.ListBackups(); len(backups) != 2 {
+		t.Errorf("Expected 2 backups in the metadata, got %d", len(backups))
+	}
+}
+
+func TestPruneRemovesEmptyNestedFolders(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	backup := Backup{Path: "2023/01/old", Destination: WatcherConfig.Destination}
+	CreateDummyFile(t, watcher.BackupPath(backup), "file.txt", 10)
+	if err := watcher.deleteBackup(backup); err != nil {
+		t.Fatalf("Failed to delete backup: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(WatcherConfig.Destination, "2023")); !os.IsNotExist(err) {
+		t.Errorf("Expected the empty year folder to be removed")
+	}
+	if _, err := os.Stat(WatcherConfig.Destination); err != nil {
+		t.Errorf("Expected the destination to be kept: %v", err)
+	}
+}
+
+// failingFS can not remove anything.
+type failingFS struct {
+	osFS
+}
+
+func (failingFS) RemoveAll(path string) error {
+	return fmt.Errorf("error removing %s: %w", path, os.ErrPermission)
+}
+
+func TestPruneKeepsBackupsThatFailToDelete(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig, WithFS(failingFS{}))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	for i := range 2 {
+		CreateDummyFile(t, WatcherConfig.Source, string(rune('a'+i)), 10)
+		watcher.CreateBackup()
+	}
+	watcher.Retention = RetentionPolicy{KeepLast: 1}
+
+	result, err := watcher.Prune(false)
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("Expected the removal error, got %v", err)
+	}
+	if len(result.Pruned) != 0 || len(watcher.ListBackups()) != 2 {
+		t.Errorf("Expected the backup to stay in the metadata, pruned %+v", result.Pruned)
+	}
+}
+
+func TestPruneMovesBackupsToTrash(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	for i := range 3 {
+		CreateDummyFile(t, WatcherConfig.Source, string(rune('a'+i)), 10)
+		watcher.CreateBackup()
+		clock.Advance(time.Second)
+	}
+	backups := watcher.ListBackups()
+	watcher.Retention = RetentionPolicy{KeepLast: 1, TrashDays: 7}
+
+	result, err := watcher.Prune(false)
+	if err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+	if !result.Trashed || len(result.Pruned) != 2 || len(watcher.ListBackups()) != 1 {
+		t.Errorf("Expected 2 backups to be moved to the trash, got %+v", result)
+	}
+	trashed := filepath.Join(trashDir(WatcherConfig.Destination), clock.Now().UTC().Format(trashTimeLayout))
+	for _, backup := range backups[:2] {
+		if _, err := os.Stat(filepath.Join(trashed, backup.Path)); err != nil {
+			t.Errorf("Expected backup %s in the trash: %v", backup.Path, err)
+		}
+	}
+
+	// The trash is only emptied once the backups have been in it for long enough.
+	clock.Advance(6 * 24 * time.Hour)
+	if _, err := watcher.Prune(false); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+	if _, err := os.Stat(trashed); err != nil {
+		t.Errorf("Expected the trash to be kept during the grace period: %v", err)
+	}
+	clock.Advance(2 * 24 * time.Hour)
+	if _, err := watcher.Prune(false); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+	if _, err := os.Stat(trashDir(WatcherConfig.Destination)); !os.IsNotExist(err) {
+		t.Errorf("Expected the trash to be emptied, got %v", err)
+	}
+}
+
+func TestBackupLoopEmptiesTrash(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	for i := range 2 {
+		CreateDummyFile(t, WatcherConfig.Source, string(rune('a'+i)), 10)
+		watcher.CreateBackup()
+		clock.Advance(time.Second)
+	}
+	watcher.Retention = RetentionPolicy{KeepLast: 1, TrashDays: 1}
+	if _, err := watcher.Prune(false); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+
+	// Nothing is pruned again, the backup thread still empties the trash.
+	startBackupLoop(t, watcher)
+	clock.BlockUntil(1)
+	clock.Advance(2 * 24 * time.Hour)
+	waitUntil(t, "Expected the trash to be emptied by the backup thread", func() bool {
+		_, err := os.Stat(trashDir(WatcherConfig.Destination))
+		return os.IsNotExist(err)
+	})
+}
@@ -0,0 +1,91 @@
+package watcher
+
+import "errors"
+
+var ErrSafetyBackup = errors.New("error creating a safety backup of the source")
+
+// SafetyBackup backs up the source before an operation that deletes backups, such as
+// pruning. The backup is tagged with the operation in its note and pinned so a later
+// prune does not delete it either. Nothing is backed up if the source matches the
+// newest backup, which is never pruned, or if SkipSafetyBackups is set, and an empty
+// backup is returned.
+func (w *Watcher) SafetyBackup(operation string) (Backup, error) {
+	if matches, err := w.SourceMatchesLatestBackup(); err == nil && matches {
+		return Backup{}, nil
+	}
+	safety, err := w.safetyBackup(BackupTriggerSafety, operation)
+	if err != nil || safety.Path == "" {
+		return safety, err
+	}
+	if err := w.PinBackup(safety.Path, true); err != nil {
+		Logf(w.Name, LogLevelWarn, "Error pinning safety backup: %v", err)
+	} else {
+		safety.Pinned = true
+	}
+	return safety, nil
+}
+
+func (w *Watcher) safetyBackup(trigger BackupTrigger, operation string) (Backup, error) {
+	w.mu.Lock()
+	skip := w.SkipSafetyBackups
+	w.mu.Unlock()
+	if skip {
+		Logf(w.Name, LogLevelInfo, "Safety backups are turned off, %s without backing up the source", operation)
+		return Backup{}, nil
+	}
+
+	safety, ok := w.backupOnThread(trigger)
+	if !ok {
+		return Backup{}, ErrSafetyBackup
+	}
+	safety.Note = "Before " + operation
+	if err := w.AnnotateBackup(safety.Path, safety.Note); err != nil {
+		Logf(w.Name, LogLevelWarn, "Error adding note to safety backup: %v", err)
+	}
+	return safety, nil
+}
+
+// backupCall asks the backup thread for a backup, the result is sent on result.
+type backupCall struct {
+	trigger BackupTrigger
+	result  chan backupCallResult
+}
+
+type backupCallResult struct {
+	backup Backup
+	ok     bool
+}
+
+// backupOnThread makes a backup on the backup thread while the watcher is running, so
+// it is never made at the same time as another backup of the watcher, and right away
+// otherwise.
+func (w *Watcher) backupOnThread(trigger BackupTrigger) (Backup, bool) {
+	w.mu.Lock()
+	running := w.running
+	stop := w.stopChan
+	done := w.backupLoopDone
+	w.mu.Unlock()
+	if !running {
+		return w.createTriggeredBackup(trigger)
+	}
+
+	call := backupCall{trigger: trigger, result: make(chan backupCallResult, 1)}
+	select {
+	case w.backupCallChan <- call:
+		result := <-call.result
+		return result.backup, result.ok
+	// The watcher stopped, the backup is made once the backup thread finished the
+	// backup it was making.
+	case <-stop:
+		<-done
+		return w.createTriggeredBackup(trigger)
+	}
+}
+
+// answerBackupCall makes the backup for call on the backup thread. The caller gets a
+// failed backup if making it panics.
+func (w *Watcher) answerBackupCall(call backupCall) {
+	var result backupCallResult
+	defer func() { call.result <- result }()
+	result.backup, result.ok = w.createTriggeredBackup(call.trigger)
+}
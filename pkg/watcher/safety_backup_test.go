@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestSafetyBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Retention = RetentionPolicy{KeepLast: 1}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	watcher.CreateBackup()
+
+	// The newest backup already holds an unchanged source.
+	safety, err := watcher.SafetyBackup("pruning")
+	if err != nil || safety.Path != "" || len(watcher.ListBackups()) != 1 {
+		t.Errorf("Expected no safety backup of an unchanged source, got %+v, %v", safety, err)
+	}
+
+	// The safety backup is not pruned right away, the operation it was made for does
+	// that.
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 10)
+	safety, err = watcher.SafetyBackup("pruning")
+	if err != nil {
+		t.Fatalf("Failed to create safety backup: %v", err)
+	}
+	backups := watcher.ListBackups()
+	if len(backups) != 2 || backups[1].Path != safety.Path || backups[1].Note != "Before pruning" || backups[1].Name != "Safety backup" || !backups[1].Pinned {
+		t.Errorf("Expected a tagged and pinned safety backup, got %+v", backups)
+	}
+}
+
+func TestSkipSafetyBackups(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.SkipSafetyBackups = true
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	watcher.CreateBackup()
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 10)
+
+	safety, err := watcher.RestoreBackup("#1")
+	if err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	if safety.Path != "" || len(watcher.ListBackups()) != 1 {
+		t.Errorf("Expected no safety backup, got %+v", safety)
+	}
+}
+
+func TestSafetyBackupWhileRunning(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	if err := watcher.StartWatcher(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	t.Cleanup(func() { watcher.Shutdown() })
+
+	// Safety backups are made by the backup thread, so they never pick the same folder
+	// as a backup it is making.
+	for i := range 3 {
+		CreateDummyFile(t, WatcherConfig.Source, fmt.Sprintf("file%d.txt", i+2), 10)
+		watcher.requestUrgentBackup(BackupTriggerManual)
+		if _, err := watcher.SafetyBackup("pruning"); err != nil {
+			t.Fatalf("Failed to create safety backup: %v", err)
+		}
+	}
+	seen := map[string]bool{}
+	for _, backup := range watcher.ListBackups() {
+		if seen[backup.Path] {
+			t.Errorf("Expected every backup to have its own folder, %s is used twice", backup.Path)
+		}
+		seen[backup.Path] = true
+		if _, err := os.Stat(watcher.BackupPath(backup)); err != nil {
+			t.Errorf("Expected backup %s to exist: %v", backup.Path, err)
+		}
+	}
+}
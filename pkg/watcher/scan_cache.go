@@ -0,0 +1,177 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name of the file in the primary destination the scan cache is saved to when
+// PersistScanCache is set.
+const scanCacheFileName = "scan_cache.json"
+
+// scanCacheEntry is the hash of a file along with the size and modification time it had
+// when it was hashed.
+type scanCacheEntry struct {
+	Size      int64         `json:"size"`
+	ModTime   time.Time     `json:"mod_time"`
+	Algorithm HashAlgorithm `json:"algorithm"`
+	Hash      string        `json:"hash"`
+}
+
+// scanCache remembers the hashes of the files in the source so scanning it again only
+// hashes the files that changed. A file is hashed again if its size or modification
+// time changed, or if a file event for it was seen since it was hashed.
+type scanCache struct {
+	mu      sync.Mutex
+	entries map[string]scanCacheEntry
+	// True once the saved cache has been read, or there was nothing to read.
+	loaded bool
+	// True if there are entries that have not been saved.
+	dirty bool
+}
+
+func newScanCache() *scanCache {
+	return &scanCache{entries: map[string]scanCacheEntry{}}
+}
+
+// hashFile returns the hash of the file at path, which was last seen with info. A nil
+// cache always hashes the file.
+func (c *scanCache) hashFile(path string, info fs.FileInfo, algorithm HashAlgorithm) (string, error) {
+	if c == nil {
+		return hashFile(path, algorithm)
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) && entry.Algorithm == algorithm {
+		return entry.Hash, nil
+	}
+
+	hash, err := hashFile(path, algorithm)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[path] = scanCacheEntry{Size: info.Size(), ModTime: info.ModTime(), Algorithm: algorithm, Hash: hash}
+	c.dirty = true
+	return hash, nil
+}
+
+// invalidate forgets path and everything inside it, so a change that kept the size and
+// modification time of a file is still noticed.
+func (c *scanCache) invalidate(path string) {
+	path = filepath.Clean(path)
+	prefix := path + string(filepath.Separator)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for cached := range c.entries {
+		if cached == path || strings.HasPrefix(cached, prefix) {
+			delete(c.entries, cached)
+			c.dirty = true
+		}
+	}
+}
+
+// prune forgets the files inside root that were not found by the last scan of it.
+func (c *scanCache) prune(root string, manifest Manifest) {
+	found := make(map[string]bool, len(manifest))
+	for _, entry := range manifest {
+		found[filepath.Join(root, filepath.FromSlash(entry.Path))] = true
+	}
+	prefix := filepath.Clean(root) + string(filepath.Separator)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for cached := range c.entries {
+		if strings.HasPrefix(cached, prefix) && !found[cached] {
+			delete(c.entries, cached)
+			c.dirty = true
+		}
+	}
+}
+
+func scanCachePath(destination string) string {
+	return statePath(destination, scanCacheFileName)
+}
+
+// load reads the cache saved to destination the first time it is called. Entries
+// already in the cache are kept.
+func (c *scanCache) load(destination string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loaded {
+		return nil
+	}
+	c.loaded = true
+	data, err := os.ReadFile(scanCachePath(destination))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading scan cache: %w", err)
+	}
+	var entries map[string]scanCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("error parsing scan cache: %w", err)
+	}
+	for path, entry := range entries {
+		if _, ok := c.entries[path]; !ok {
+			c.entries[path] = entry
+		}
+	}
+	return nil
+}
+
+// save writes the cache to destination if it changed since it was last saved.
+func (c *scanCache) save(destination string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("error marshaling scan cache: %w", err)
+	}
+	if err := writeStateFile(scanCachePath(destination), data); err != nil {
+		return fmt.Errorf("error writing scan cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// hashSource returns the sourceHash of source, only hashing the files that changed
+// since the source was last hashed.
+func (w *Watcher) hashSource(source string, algorithm HashAlgorithm) (string, error) {
+	source, err := filepath.Abs(source)
+	if err != nil {
+		return "", err
+	}
+	w.mu.Lock()
+	persist := w.PersistScanCache
+	destination := w.Destination
+	w.mu.Unlock()
+	if persist {
+		if err := w.scanCache.load(destination); err != nil {
+			Logf(w.Name, LogLevelWarn, "%v", err)
+		}
+	}
+
+	hash, err := sourceHash(source, w.singleFile, algorithm, w.scanCache)
+	if err != nil {
+		return "", err
+	}
+	if persist {
+		if err := w.scanCache.save(destination); err != nil {
+			Logf(w.Name, LogLevelWarn, "%v", err)
+		}
+	}
+	return hash, nil
+}
@@ -0,0 +1,94 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanCache(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	CreateDummyFile(t, root, "file1.txt", 64)
+	CreateDummyFile(t, root, filepath.Join("folder", "file2.txt"), 64)
+	cache := newScanCache()
+	first, err := scanManifest(root, HashXXHash, cache, nil)
+	if err != nil {
+		t.Fatalf("Failed to build manifest: %v", err)
+	}
+
+	// A change that keeps the size and modification time is only noticed through a file
+	// event.
+	path := filepath.Join(root, "file1.txt")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	CreateDummyFile(t, root, "file1.txt", 64)
+	if err := os.Chtimes(path, time.Time{}, info.ModTime()); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+	cached, err := scanManifest(root, HashXXHash, cache, nil)
+	if err != nil {
+		t.Fatalf("Failed to build manifest: %v", err)
+	}
+	if cached.Hash() != first.Hash() {
+		t.Errorf("Expected the unchanged size and modification time to reuse the cached hash")
+	}
+	cache.invalidate(path)
+	changed, err := scanManifest(root, HashXXHash, cache, nil)
+	if err != nil {
+		t.Fatalf("Failed to build manifest: %v", err)
+	}
+	if changed.Hash() == first.Hash() {
+		t.Errorf("Expected the file to be hashed again after it was invalidated")
+	}
+	if uncached, _ := buildManifest(root, HashXXHash, nil); uncached.Hash() != changed.Hash() {
+		t.Errorf("Expected the cached manifest to match a manifest built without the cache")
+	}
+
+	if err := os.RemoveAll(filepath.Join(root, "folder")); err != nil {
+		t.Fatalf("Failed to remove folder: %v", err)
+	}
+	if _, err := scanManifest(root, HashXXHash, cache, nil); err != nil {
+		t.Fatalf("Failed to build manifest: %v", err)
+	}
+	if _, ok := cache.entries[filepath.Join(root, "folder", "file2.txt")]; ok || len(cache.entries) != 1 {
+		t.Errorf("Expected removed files to be pruned, got %v", cache.entries)
+	}
+}
+
+func TestPersistScanCache(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 64)
+	if _, err := watcher.hashSource(WatcherConfig.Source, HashXXHash); err != nil {
+		t.Fatalf("Failed to hash source: %v", err)
+	}
+	if _, err := os.Stat(scanCachePath(WatcherConfig.Destination)); !os.IsNotExist(err) {
+		t.Errorf("Expected the scan cache to only be kept in memory by default")
+	}
+
+	watcher.PersistScanCache = true
+	hash, err := watcher.hashSource(WatcherConfig.Source, HashXXHash)
+	if err != nil {
+		t.Fatalf("Failed to hash source: %v", err)
+	}
+	loaded := newScanCache()
+	if err := loaded.load(WatcherConfig.Destination); err != nil {
+		t.Fatalf("Failed to load scan cache: %v", err)
+	}
+	source, _ := filepath.Abs(WatcherConfig.Source)
+	entry, ok := loaded.entries[filepath.Join(source, "file1.txt")]
+	if !ok || entry.Algorithm != HashXXHash {
+		t.Errorf("Expected the hash of the file to be saved, got %+v", loaded.entries)
+	}
+	if reloaded, err := sourceHash(source, false, HashXXHash, loaded); err != nil || reloaded != hash {
+		t.Errorf("Expected the saved cache to give the same hash, got %s: %v", reloaded, err)
+	}
+}
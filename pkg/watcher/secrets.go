@@ -0,0 +1,150 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Secret settings such as the password of a remote may hold "keychain:<name>" instead
+// of the secret, which is then read from the keychain when it is needed.
+const secretReferencePrefix = "keychain:"
+
+// SecretReference returns the value of a setting that refers to the secret stored as
+// name.
+func SecretReference(name string) string {
+	return secretReferencePrefix + name
+}
+
+// secretReferenceName returns the name of the secret value refers to, false if value is
+// the secret itself.
+func secretReferenceName(value string) (string, bool) {
+	return strings.CutPrefix(value, secretReferencePrefix)
+}
+
+func secretKeychainName(name string) string {
+	return "secret:" + name
+}
+
+// Secrets stores the secrets that settings refer to in a keychain, so passwords and
+// keys do not have to be written into the config file.
+type Secrets struct {
+	keychain Keychain
+}
+
+func NewSecrets(keychain Keychain) Secrets {
+	return Secrets{keychain: keychain}
+}
+
+func (s Secrets) Set(name, secret string) error {
+	if name == "" {
+		return fmt.Errorf("secret name is empty")
+	}
+	return s.keychain.Set(secretKeychainName(name), secret)
+}
+
+// Delete removes a secret, ErrSecretNotFound if there is none.
+func (s Secrets) Delete(name string) error {
+	return s.keychain.Delete(secretKeychainName(name))
+}
+
+// Exists returns true if a secret is stored as name.
+func (s Secrets) Exists(name string) (bool, error) {
+	_, err := s.keychain.Get(secretKeychainName(name))
+	if errors.Is(err, ErrSecretNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Resolve returns the secret a setting refers to, or the value of the setting if it
+// does not refer to one.
+func (s Secrets) Resolve(value string) (string, error) {
+	name, ok := secretReferenceName(value)
+	if !ok {
+		return value, nil
+	}
+	secret, err := s.keychain.Get(secretKeychainName(name))
+	if errors.Is(err, ErrSecretNotFound) {
+		return "", fmt.Errorf("secret %s is not in the keychain, save it with: i-saw-that secrets set %s", name, name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading secret %s: %w", name, err)
+	}
+	return secret, nil
+}
+
+// SecretSetting is a setting of a folder pair that holds a secret.
+type SecretSetting struct {
+	// Name of the setting such as "remote.password".
+	Setting string
+	Value   *string
+}
+
+// Reference returns the name of the secret the setting refers to, false if it holds
+// the secret itself or is empty.
+func (s SecretSetting) Reference() (string, bool) {
+	return secretReferenceName(*s.Value)
+}
+
+// SecretSettings returns the settings of the folder pair that can hold secrets.
+func (c *WatcherConfig) SecretSettings() []SecretSetting {
+	return []SecretSetting{
+		{"remote.password", &c.Remote.Password},
+		{"remote.client_secret", &c.Remote.ClientSecret},
+		{"remote.application_key", &c.Remote.ApplicationKey},
+		{"restic.password", &c.Restic.Password},
+	}
+}
+
+// MigrateSecrets moves the secrets written into the settings of the folder pair into
+// the keychain as "<id>/<setting>" and replaces them with references. The names of the
+// secrets that were moved are returned.
+func (s Secrets) MigrateSecrets(config *WatcherConfig) ([]string, error) {
+	moved := []string{}
+	for _, setting := range config.SecretSettings() {
+		if _, ok := setting.Reference(); ok || *setting.Value == "" {
+			continue
+		}
+		name := config.ID + "/" + setting.Setting
+		if err := s.Set(name, *setting.Value); err != nil {
+			return moved, fmt.Errorf("error saving %s: %w", name, err)
+		}
+		*setting.Value = SecretReference(name)
+		moved = append(moved, name)
+	}
+	return moved, nil
+}
+
+// validateSecretReferences checks that the settings that refer to a secret name one.
+func validateSecretReferences(config *WatcherConfig) error {
+	for _, setting := range config.SecretSettings() {
+		if name, ok := setting.Reference(); ok && strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%s refers to a secret without a name", setting.Setting)
+		}
+	}
+	return nil
+}
+
+// resolveRemote returns config with the secrets it refers to read from the keychain.
+func (w *Watcher) resolveRemote(config RemoteConfig) (RemoteConfig, error) {
+	secrets := NewSecrets(w.keychain)
+	for _, value := range []*string{&config.Password, &config.ClientSecret, &config.ApplicationKey} {
+		secret, err := secrets.Resolve(*value)
+		if err != nil {
+			return config, err
+		}
+		*value = secret
+	}
+	return config, nil
+}
+
+// resolveRestic returns config with the password it refers to read from the keychain.
+func (w *Watcher) resolveRestic(config ResticConfig) (ResticConfig, error) {
+	password, err := NewSecrets(w.keychain).Resolve(config.Password)
+	if err != nil {
+		return config, err
+	}
+	config.Password = password
+	return config, nil
+}
@@ -0,0 +1,143 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSecretsResolve(t *testing.T) {
+	t.Parallel()
+	keychain := &memoryKeychain{}
+	secrets := NewSecrets(keychain)
+	if err := secrets.Set("nas", "hunter2"); err != nil {
+		t.Fatalf("Failed to set secret: %v", err)
+	}
+
+	if secret, err := secrets.Resolve(SecretReference("nas")); err != nil || secret != "hunter2" {
+		t.Errorf("Expected the secret to be read from the keychain, got %q: %v", secret, err)
+	}
+	// Values that are not references are the secret itself.
+	if secret, err := secrets.Resolve("plain"); err != nil || secret != "plain" {
+		t.Errorf("Expected the value itself, got %q: %v", secret, err)
+	}
+	if _, err := secrets.Resolve(SecretReference("missing")); err == nil || !strings.Contains(err.Error(), "secrets set missing") {
+		t.Errorf("Expected a missing secret to explain how to save it, got %v", err)
+	}
+
+	if err := secrets.Delete("nas"); err != nil {
+		t.Fatalf("Failed to delete secret: %v", err)
+	}
+	if exists, err := secrets.Exists("nas"); err != nil || exists {
+		t.Errorf("Expected the secret to be deleted, got %t: %v", exists, err)
+	}
+	if err := secrets.Delete("nas"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected deleting a missing secret to fail, got %v", err)
+	}
+}
+
+func TestMigrateSecrets(t *testing.T) {
+	t.Parallel()
+	keychain := &memoryKeychain{}
+	secrets := NewSecrets(keychain)
+	config := &WatcherConfig{
+		ID:     "photos",
+		Remote: RemoteConfig{Type: RemoteWebDAV, Password: "hunter2"},
+		Restic: ResticConfig{Password: SecretReference("shared")},
+	}
+
+	moved, err := secrets.MigrateSecrets(config)
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if !slices.Equal(moved, []string{"photos/remote.password"}) {
+		t.Errorf("Expected only the remote password to be moved, got %v", moved)
+	}
+	if config.Remote.Password != SecretReference("photos/remote.password") || config.Restic.Password != SecretReference("shared") {
+		t.Errorf("Expected the settings to refer to the keychain, got %+v and %+v", config.Remote, config.Restic)
+	}
+	if secret, err := secrets.Resolve(config.Remote.Password); err != nil || secret != "hunter2" {
+		t.Errorf("Expected the password to be in the keychain, got %q: %v", secret, err)
+	}
+	if moved, err := secrets.MigrateSecrets(config); err != nil || len(moved) != 0 {
+		t.Errorf("Expected nothing left to migrate, got %v: %v", moved, err)
+	}
+}
+
+func TestRemotePasswordFromKeychain(t *testing.T) {
+	t.Parallel()
+	server := newNextcloudServer(t)
+	keychain := &memoryKeychain{}
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig, WithKeychain(keychain))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	config := RemoteConfig{Type: RemoteWebDAV, URL: server.folderURL("Backups"), User: "alice", Password: SecretReference("nas")}
+
+	if _, err := watcher.newRemoteBackend(config); err == nil {
+		t.Error("Expected a remote to fail without its password")
+	}
+	if err := NewSecrets(keychain).Set("nas", "secret"); err != nil {
+		t.Fatalf("Failed to set secret: %v", err)
+	}
+	backend, err := watcher.newRemoteBackend(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	if _, err := backend.List(watcher.remotePrefix()); err != nil {
+		t.Errorf("Expected to sign in with the password from the keychain, got %v", err)
+	}
+}
+
+func TestResticPasswordFromKeychain(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("The fake restic is a shell script")
+	}
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "restic.log")
+	script := "#!/bin/sh\necho \"$RESTIC_PASSWORD $@\" >> " + logPath + "\n"
+	executable := filepath.Join(dir, "restic")
+	if err := os.WriteFile(executable, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake restic: %v", err)
+	}
+	keychain := &memoryKeychain{}
+	if err := NewSecrets(keychain).Set("repo", "hunter2"); err != nil {
+		t.Fatalf("Failed to set secret: %v", err)
+	}
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig, WithKeychain(keychain))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Restic = ResticConfig{Repository: "/repo", Password: SecretReference("repo"), Executable: executable}
+
+	if err := watcher.deleteBackup(Backup{ResticSnapshot: "snap1"}); err != nil {
+		t.Fatalf("Failed to delete restic backup: %v", err)
+	}
+	log, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read restic log: %v", err)
+	}
+	// The password is passed in the environment instead of the arguments.
+	if line := strings.TrimSpace(string(log)); line != "hunter2 --repo /repo forget --prune snap1" {
+		t.Errorf("Expected restic to get the password from the keychain, got %q", line)
+	}
+}
+
+func TestSecretReferenceValidation(t *testing.T) {
+	t.Parallel()
+	config := &WatcherConfig{Restic: ResticConfig{Repository: "/repo", Password: SecretReference(" ")}}
+	if err := validateSecretReferences(config); err == nil {
+		t.Error("Expected a reference without a name to be rejected")
+	}
+	config.Restic.PasswordFile = "/password"
+	if err := config.Restic.validate(); err == nil {
+		t.Error("Expected a password and a password file to be rejected")
+	}
+}
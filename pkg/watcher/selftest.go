@@ -0,0 +1,127 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Probe files are ignored by the watcher so a self test does not trigger a backup.
+const selfTestProbePrefix = ".i-saw-that-probe-"
+
+const selfTestTimeout = 5 * time.Second
+
+type SelfTestResult struct {
+	Passed bool `json:"passed"`
+	// True if a file event was received for the probe file in the source.
+	EventsReceived bool `json:"events_received"`
+	// True if file events were not checked since the source is read-only.
+	EventsSkipped bool `json:"events_skipped,omitempty"`
+	// True if a probe file could be written to every destination.
+	DestinationWritable bool `json:"destination_writable"`
+	// Description of every check that failed.
+	Problems []string `json:"problems"`
+}
+
+func isSelfTestProbe(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), selfTestProbePrefix)
+}
+
+// SelfTest checks that file events are received for the source and that backups can be
+// written to the destinations. This catches filesystems where file events silently do
+// not work before a backup is actually needed.
+func (w *Watcher) SelfTest() SelfTestResult {
+	result := SelfTestResult{Problems: []string{}}
+
+	if w.checkSourceWritable() != nil {
+		result.EventsSkipped = true
+	} else if err := w.selfTestEvents(); err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("file events: %v", err))
+	} else {
+		result.EventsReceived = true
+	}
+
+	result.DestinationWritable = true
+	for _, destination := range w.destinations() {
+		if err := selfTestWrite(destination); err != nil {
+			result.DestinationWritable = false
+			result.Problems = append(result.Problems, fmt.Sprintf("destination %s: %v", destination, err))
+		}
+	}
+
+	result.Passed = len(result.Problems) == 0
+	if result.Passed {
+		Logf(w.Name, LogLevelInfo, "Self test passed")
+	} else {
+		Logf(w.Name, LogLevelWarn, "Self test failed: %s", strings.Join(result.Problems, "; "))
+	}
+	return result
+}
+
+// Write and remove a probe file in the source and wait for an event for it.
+func (w *Watcher) selfTestEvents() error {
+	fsnotifyWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating file watcher: %w", classifyWatchError(err))
+	}
+	defer fsnotifyWatcher.Close()
+
+	// Only the directory itself is watched, for a single file source this is the
+	// directory that contains the file.
+	probeDir := w.Source
+	if w.singleFile {
+		probeDir = filepath.Dir(w.Source)
+	}
+	if err := fsnotifyWatcher.Add(probeDir); err != nil {
+		return fmt.Errorf("error watching source: %w", classifyWatchError(err))
+	}
+
+	probePath := filepath.Join(probeDir, fmt.Sprintf("%s%d", selfTestProbePrefix, time.Now().UnixNano()))
+	if err := os.WriteFile(probePath, []byte("probe"), 0644); err != nil {
+		return fmt.Errorf("error writing probe file: %w", err)
+	}
+	defer os.Remove(probePath)
+
+	timeout := time.After(selfTestTimeout)
+	for {
+		select {
+		case event, ok := <-fsnotifyWatcher.Events:
+			if !ok {
+				return fmt.Errorf("file watcher closed unexpectedly")
+			}
+			if event.Name == probePath {
+				return nil
+			}
+		case err, ok := <-fsnotifyWatcher.Errors:
+			if ok {
+				return classifyWatchError(err)
+			}
+		case <-timeout:
+			return fmt.Errorf("no event received within %s, file events may not be supported on this filesystem", selfTestTimeout)
+		}
+	}
+}
+
+// selfTestWrite writes a probe file to a destination, reads it back and removes it.
+func selfTestWrite(destination string) error {
+	probePath := filepath.Join(destination, fmt.Sprintf("%s%d", selfTestProbePrefix, time.Now().UnixNano()))
+	if err := os.WriteFile(probePath, []byte("probe"), 0644); err != nil {
+		return fmt.Errorf("error writing probe file: %w", err)
+	}
+	data, err := os.ReadFile(probePath)
+	if err == nil && string(data) != "probe" {
+		err = fmt.Errorf("read back %d bytes that do not match what was written", len(data))
+	}
+	if err != nil {
+		os.Remove(probePath)
+		return fmt.Errorf("error reading probe file: %w", err)
+	}
+	if err := os.Remove(probePath); err != nil {
+		return fmt.Errorf("error removing probe file: %w", err)
+	}
+	return nil
+}
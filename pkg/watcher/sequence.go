@@ -0,0 +1,164 @@
+package watcher
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Name of the file in the primary destination that stores the last sequence number
+// given to a backup.
+const sequenceFileName = "sequence.json"
+
+// sequenceToken is replaced with the sequence number of a backup in folder formats,
+// such as "{seq}_2006-01-02".
+const sequenceToken = "{seq}"
+
+type sequenceState struct {
+	Last int64 `json:"last"`
+}
+
+func sequencePath(destination string) string {
+	return statePath(destination, sequenceFileName)
+}
+
+func readSequence(destination string) (int64, error) {
+	data, err := os.ReadFile(sequencePath(destination))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading sequence: %w", err)
+	}
+	var state sequenceState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return 0, fmt.Errorf("error parsing sequence: %w", err)
+	}
+	return state.Last, nil
+}
+
+func writeSequence(destination string, last int64) error {
+	data, err := json.Marshal(sequenceState{Last: last})
+	if err != nil {
+		return fmt.Errorf("error marshaling sequence: %w", err)
+	}
+	path := sequencePath(destination)
+	if err := writeStateFile(path, data); err != nil {
+		return fmt.Errorf("error writing sequence: %w", err)
+	}
+	return nil
+}
+
+// nextSequence reserves the sequence number of a new backup. Numbers are never reused,
+// even after the newest backup is deleted, so they can be used to refer to a backup.
+func (w *Watcher) nextSequence() (int64, error) {
+	w.sequenceMu.Lock()
+	defer w.sequenceMu.Unlock()
+
+	last, err := readSequence(w.Destination)
+	if err != nil {
+		return 0, err
+	}
+	// The metadata is also checked in case the sequence file was lost.
+	w.mu.Lock()
+	for _, backup := range w.Metadata {
+		last = max(last, backup.Sequence)
+	}
+	w.mu.Unlock()
+
+	if err := writeSequence(w.Destination, last+1); err != nil {
+		return 0, err
+	}
+	return last + 1, nil
+}
+
+// sortBackups orders backups from oldest to newest. Backups with the same timestamp,
+// such as ones made while the clock was behind, are ordered by their sequence number.
+func sortBackups(backups []Backup) {
+	slices.SortStableFunc(backups, func(a, b Backup) int {
+		return cmp.Or(cmp.Compare(a.Timestamp, b.Timestamp), cmp.Compare(a.Sequence, b.Sequence))
+	})
+}
+
+// orderedTimestamp returns the timestamp recorded for a backup made at t. If the clock
+// was set back since the newest backup was made, the timestamp of the newest backup is
+// used instead so the new backup is still ordered last, and a warning is recorded.
+func (w *Watcher) orderedTimestamp(t time.Time, backupID string) float64 {
+	timestamp := float64(t.Unix()) + float64(t.Nanosecond())/1e9
+	var latest float64
+	w.mu.Lock()
+	for _, backup := range w.Metadata {
+		latest = max(latest, backup.Timestamp)
+	}
+	w.mu.Unlock()
+	if timestamp >= latest {
+		return timestamp
+	}
+
+	behind := time.Duration((latest - timestamp) * 1e9).Round(time.Millisecond)
+	message := fmt.Sprintf("The clock is %s behind the newest backup, backup %s is ordered by its sequence number", behind, backupID)
+	Logf(w.Name, LogLevelWarn, "%s", message)
+	w.recordEvent(HistoryEvent{Type: HistoryClockBehind, Backup: backupID, Message: message})
+	return latest
+}
+
+// formatBackupFolder returns the path of a backup made at the given time, using
+// forward slashes.
+func formatBackupFolder(folderFormat string, created time.Time, sequence int64) string {
+	folder := created.Format(folderFormat)
+	folder = strings.ReplaceAll(folder, sequenceToken, strconv.FormatInt(sequence, 10))
+	return filepath.ToSlash(folder)
+}
+
+// uniqueBackupFolder returns folder, or folder with a suffix such as "_2" if a backup
+// with that path already exists. This happens when the folder format is not precise
+// enough or the clock moved backwards, and the backup would otherwise be lost.
+func (w *Watcher) uniqueBackupFolder(destination, folder string) string {
+	w.mu.Lock()
+	taken := map[string]bool{}
+	for _, backup := range w.Metadata {
+		taken[backup.Path] = true
+	}
+	w.mu.Unlock()
+
+	candidate := folder
+	for suffix := 2; ; suffix++ {
+		_, err := os.Lstat(filepath.Join(destination, filepath.FromSlash(candidate)))
+		// Other errors are left for the copy to report.
+		if !taken[candidate] && err != nil {
+			break
+		}
+		candidate = fmt.Sprintf("%s_%d", folder, suffix)
+	}
+	if candidate != folder {
+		Logf(w.Name, LogLevelWarn, "Backup folder %s already exists, using %s instead", folder, candidate)
+	}
+	return candidate
+}
+
+// FindBackupByID returns the backup with the given ID. An ID is either the path of a
+// backup or its sequence number, paths are checked first since a folder format can
+// produce numeric paths.
+func FindBackupByID(backups []Backup, backupID string) (Backup, bool) {
+	path := filepath.ToSlash(backupID)
+	for _, backup := range backups {
+		if backup.Path == path {
+			return backup, true
+		}
+	}
+	if sequence, err := strconv.ParseInt(strings.TrimPrefix(backupID, "#"), 10, 64); err == nil {
+		for _, backup := range backups {
+			if backup.Sequence == sequence {
+				return backup, true
+			}
+		}
+	}
+	return Backup{}, false
+}
@@ -0,0 +1,200 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSequenceNumbersAreNotReused(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	watcher.CreateBackup()
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 10)
+	watcher.CreateBackup()
+	if len(watcher.Metadata) != 2 || watcher.Metadata[0].Sequence != 1 || watcher.Metadata[1].Sequence != 2 {
+		t.Fatalf("Expected sequences 1 and 2, got %+v", watcher.Metadata)
+	}
+
+	for _, id := range []string{"2", "#2", watcher.Metadata[1].Path} {
+		backup, err := watcher.FindBackup(id)
+		if err != nil {
+			t.Errorf("Failed to find backup '%s': %v", id, err)
+		} else if backup.Sequence != 2 {
+			t.Errorf("Expected '%s' to find backup 2, got %d", id, backup.Sequence)
+		}
+	}
+
+	// Deleting the newest backup should not free its number.
+	if err := watcher.deleteBackup(watcher.Metadata[1]); err != nil {
+		t.Fatalf("Failed to delete backup: %v", err)
+	}
+	watcher.Metadata = watcher.Metadata[:1]
+	if err := watcher.saveMetadata(WatcherConfig.Destination); err != nil {
+		t.Fatalf("Failed to save metadata: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file3.txt", 10)
+	watcher.CreateBackup()
+	if sequence := watcher.Metadata[1].Sequence; sequence != 3 {
+		t.Errorf("Expected sequence 3, got %d", sequence)
+	}
+	if _, err := watcher.FindBackup("2"); err == nil {
+		t.Errorf("Expected deleted backup 2 to not be found")
+	}
+}
+
+func TestSequenceFolderFormat(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.FolderFormat = "backup-" + sequenceToken
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	watcher.CreateBackup()
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 10)
+	watcher.CreateBackup()
+
+	backups := watcher.ListBackups()
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %d", len(backups))
+	}
+	for i, expected := range []string{"backup-1", "backup-2"} {
+		if backups[i].Path != expected {
+			t.Errorf("Expected path '%s', got '%s'", expected, backups[i].Path)
+		}
+		if _, err := os.Stat(watcher.BackupPath(backups[i])); err != nil {
+			t.Errorf("Expected backup %s to exist: %v", backups[i].Path, err)
+		}
+	}
+}
+
+func TestMigratingSequences(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	watcher.CreateBackup()
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 10)
+	watcher.CreateBackup()
+
+	// Simulate metadata written before sequence numbers were added.
+	legacy := append([]Backup{}, watcher.Metadata...)
+	for i := range legacy {
+		legacy[i].Sequence = 0
+	}
+	data, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatalf("Failed to marshal metadata: %v", err)
+	}
+	if err := os.WriteFile(metadataJSONPath(WatcherConfig.Destination), data, 0644); err != nil {
+		t.Fatalf("Failed to write metadata: %v", err)
+	}
+	if err := os.Remove(sequencePath(WatcherConfig.Destination)); err != nil {
+		t.Fatalf("Failed to remove sequence file: %v", err)
+	}
+
+	if _, err := NewTempWatcher(WatcherConfig); err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	metadata, err := loadDestinationMetadata(WatcherConfig.Destination)
+	if err != nil {
+		t.Fatalf("Failed to load metadata: %v", err)
+	}
+	for i, backup := range metadata {
+		if backup.Sequence != int64(i+1) {
+			t.Errorf("Expected backup %s to be numbered %d, got %d", backup.Path, i+1, backup.Sequence)
+		}
+	}
+	if last, err := readSequence(WatcherConfig.Destination); err != nil || last != 2 {
+		t.Errorf("Expected the last sequence to be 2, got %d: %v", last, err)
+	}
+}
+
+func TestBackupFolderCollisions(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	// A format without any time, like a clock that keeps jumping back to the same
+	// second.
+	watcher.FolderFormat = "backup"
+	// A folder that is not a backup also takes the name.
+	if err := os.MkdirAll(filepath.Join(WatcherConfig.Destination, "backup_2"), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	for i := range 3 {
+		CreateDummyFile(t, WatcherConfig.Source, fmt.Sprintf("file%d.txt", i), 10)
+		if _, ok := watcher.createTriggeredBackup(BackupTriggerManual); !ok {
+			t.Fatalf("Failed to create backup %d", i+1)
+		}
+	}
+	backups := watcher.ListBackups()
+	for i, expected := range []string{"backup", "backup_3", "backup_4"} {
+		if backups[i].Path != expected {
+			t.Errorf("Expected backup %d to be named '%s', got '%s'", i+1, expected, backups[i].Path)
+		}
+	}
+	CompareSourceAndDestination(t, WatcherConfig.Source, watcher.BackupPath(backups[2]))
+}
+
+func TestClockSetBack(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	first, _ := watcher.createTriggeredBackup(BackupTriggerManual)
+
+	// Pretend the first backup was made an hour from now, as if the clock was set back
+	// an hour since.
+	watcher.Metadata[0].Timestamp += time.Hour.Seconds()
+	first.Timestamp = watcher.Metadata[0].Timestamp
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 10)
+	second, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatalf("Failed to create backup")
+	}
+	if second.Timestamp != first.Timestamp {
+		t.Errorf("Expected the timestamp of the newest backup %f, got %f", first.Timestamp, second.Timestamp)
+	}
+	if !slices.ContainsFunc(watcher.History(), func(event HistoryEvent) bool {
+		return event.Type == HistoryClockBehind && event.Backup == second.Path
+	}) {
+		t.Errorf("Expected a warning in the history")
+	}
+
+	// The order is kept when the metadata is loaded again.
+	reloaded, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	backups := reloaded.ListBackups()
+	if len(backups) != 2 || backups[1].Path != second.Path {
+		t.Fatalf("Expected %s to be the newest backup, got %+v", second.Path, backups)
+	}
+	if matches, err := reloaded.SourceMatchesLatestBackup(); err != nil || !matches {
+		t.Errorf("Expected the source to match the newest backup, got %t: %v", matches, err)
+	}
+}
@@ -0,0 +1,139 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// ShareCredentials are the user and password a network share is connected with.
+type ShareCredentials struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// SharePath returns the share a UNC path such as \\nas\backups\photos is on, as
+// \\nas\backups. Forward slashes are accepted too.
+func SharePath(path string) (string, bool) {
+	path = strings.ReplaceAll(path, "/", `\`)
+	rest, ok := strings.CutPrefix(path, `\\`)
+	// \\?\ and \\.\ are device paths, not shares.
+	if !ok || strings.HasPrefix(rest, `?\`) || strings.HasPrefix(rest, `.\`) {
+		return "", false
+	}
+	parts := strings.SplitN(rest, `\`, 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return `\\` + rest, true
+	}
+	return `\\` + parts[0] + `\` + parts[1], true
+}
+
+// validateSharePath checks that a UNC path names a server and a share and can be used
+// on this OS.
+func validateSharePath(path string) error {
+	share, ok := SharePath(path)
+	if !ok {
+		return nil
+	}
+	if runtime.GOOS != "windows" {
+		return fmt.Errorf("UNC paths such as %s only work on Windows, mount the share and use the folder it is mounted on", share)
+	}
+	if parts := strings.Split(strings.TrimPrefix(share, `\\`), `\`); len(parts) != 2 || parts[1] == "" {
+		return fmt.Errorf(`%s must name a server and a share, such as \\nas\backups`, path)
+	}
+	return nil
+}
+
+// shareSecretName is the name the credentials of a share are stored under, shares are
+// not case sensitive.
+func shareSecretName(share string) string {
+	return "share:" + strings.ToLower(share)
+}
+
+// SaveShareCredentials stores the credentials of the share path is on in keychain.
+func SaveShareCredentials(keychain Keychain, path string, credentials ShareCredentials) error {
+	share, ok := SharePath(path)
+	if !ok {
+		return fmt.Errorf(`%s is not a network share, such as \\nas\backups`, path)
+	}
+	data, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+	return keychain.Set(shareSecretName(share), string(data))
+}
+
+// DeleteShareCredentials removes the credentials of the share path is on from keychain.
+func DeleteShareCredentials(keychain Keychain, path string) error {
+	share, ok := SharePath(path)
+	if !ok {
+		return fmt.Errorf(`%s is not a network share, such as \\nas\backups`, path)
+	}
+	return keychain.Delete(shareSecretName(share))
+}
+
+// loadShareCredentials returns the stored credentials of a share, false if there are
+// none so the share is connected as the current user.
+func loadShareCredentials(keychain Keychain, share string) (ShareCredentials, bool, error) {
+	data, err := keychain.Get(shareSecretName(share))
+	if errors.Is(err, ErrSecretNotFound) {
+		return ShareCredentials{}, false, nil
+	}
+	if err != nil {
+		return ShareCredentials{}, false, err
+	}
+	var credentials ShareCredentials
+	if err := json.Unmarshal([]byte(data), &credentials); err != nil {
+		return ShareCredentials{}, false, fmt.Errorf("error reading credentials of %s: %w", share, err)
+	}
+	return credentials, true, nil
+}
+
+// ConnectShare connects the share path is on with the credentials stored in keychain,
+// nothing is done for paths that are not on a share.
+func ConnectShare(keychain Keychain, path string) error {
+	share, ok := SharePath(path)
+	if !ok {
+		return nil
+	}
+	credentials, found, err := loadShareCredentials(keychain, share)
+	if err != nil {
+		return err
+	}
+	var user *ShareCredentials
+	if found {
+		user = &credentials
+	}
+	if err := connectShare(share, user); err != nil {
+		return fmt.Errorf("error connecting to %s: %w", share, err)
+	}
+	return nil
+}
+
+// reachShare returns the error of statting path, after trying to connect its share
+// again if it is on one. Shares drop when the NAS sleeps or the network changes.
+func reachShare(keychain Keychain, path string) (os.FileInfo, error) {
+	info, err := os.Stat(path)
+	share, ok := SharePath(path)
+	if err == nil || !ok {
+		return info, err
+	}
+	// Windows reports a share that can not be reached as not existing too, so a path
+	// is only missing if the share itself can be statted.
+	if _, rootErr := os.Stat(share); rootErr == nil {
+		return info, err
+	}
+	if connectErr := ConnectShare(keychain, path); connectErr != nil {
+		return nil, fmt.Errorf("share %s is not reachable: %w", share, errors.Join(err, connectErr))
+	}
+	if info, err = os.Stat(path); err != nil {
+		if _, rootErr := os.Stat(share); rootErr == nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("share %s is not reachable: %w", share, err)
+	}
+	return info, nil
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package watcher
+
+import (
+	"errors"
+	"fmt"
+)
+
+// connectShare is only supported on Windows, other systems mount shares as folders.
+func connectShare(share string, credentials *ShareCredentials) error {
+	return fmt.Errorf("UNC paths only work on Windows: %w", errors.ErrUnsupported)
+}
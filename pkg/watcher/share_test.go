@@ -0,0 +1,109 @@
+package watcher
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// memoryKeychain keeps secrets in memory instead of the keychain of the OS.
+type memoryKeychain struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func (k *memoryKeychain) Get(name string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	secret, ok := k.secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+func (k *memoryKeychain) Set(name, secret string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.secrets == nil {
+		k.secrets = map[string]string{}
+	}
+	k.secrets[name] = secret
+	return nil
+}
+
+func (k *memoryKeychain) Delete(name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.secrets[name]; !ok {
+		return ErrSecretNotFound
+	}
+	delete(k.secrets, name)
+	return nil
+}
+
+func TestSharePath(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path, share string
+		ok          bool
+	}{
+		{`\\nas\backups\photos`, `\\nas\backups`, true},
+		{`\\nas\backups`, `\\nas\backups`, true},
+		{`//nas/backups/photos`, `\\nas\backups`, true},
+		{`\\nas`, `\\nas`, true},
+		{`\\?\C:\backups`, "", false},
+		{`C:\backups`, "", false},
+		{`/mnt/nas/backups`, "", false},
+	}
+	for _, test := range tests {
+		if share, ok := SharePath(test.path); share != test.share || ok != test.ok {
+			t.Errorf("Expected %s to be on %q (%v), got %q (%v)", test.path, test.share, test.ok, share, ok)
+		}
+	}
+}
+
+func TestValidateSharePath(t *testing.T) {
+	t.Parallel()
+	if err := validateSharePath(`\\nas`); err == nil {
+		t.Error("Expected a share without a name to be invalid")
+	}
+	err := validateSharePath(`\\nas\backups\photos`)
+	if runtime.GOOS == "windows" && err != nil {
+		t.Errorf("Expected a share to be valid, got %v", err)
+	}
+	if runtime.GOOS != "windows" && err == nil {
+		t.Error("Expected UNC paths to be refused outside of Windows")
+	}
+	if err := validateSharePath("/mnt/nas/backups"); err != nil {
+		t.Errorf("Expected a mounted share to be valid, got %v", err)
+	}
+}
+
+func TestShareCredentials(t *testing.T) {
+	t.Parallel()
+	keychain := &memoryKeychain{}
+	credentials := ShareCredentials{User: `NAS\backup`, Password: "secret"}
+	if err := SaveShareCredentials(keychain, `\\NAS\Backups\photos`, credentials); err != nil {
+		t.Fatalf("Failed to save credentials: %v", err)
+	}
+	// Shares are not case sensitive.
+	loaded, found, err := loadShareCredentials(keychain, `\\nas\backups`)
+	if err != nil || !found || loaded != credentials {
+		t.Errorf("Expected the saved credentials, got %+v (%v): %v", loaded, found, err)
+	}
+	if err := SaveShareCredentials(keychain, "/mnt/nas", credentials); err == nil {
+		t.Error("Expected credentials to need a share")
+	}
+
+	if err := DeleteShareCredentials(keychain, `\\nas\backups`); err != nil {
+		t.Fatalf("Failed to delete credentials: %v", err)
+	}
+	if _, found, err := loadShareCredentials(keychain, `\\nas\backups`); found || err != nil {
+		t.Errorf("Expected no credentials, got %v: %v", found, err)
+	}
+	if err := DeleteShareCredentials(keychain, `\\nas\backups`); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected deleting missing credentials to fail, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build windows
+
+package watcher
+
+import (
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	mpr                     = windows.NewLazySystemDLL("mpr.dll")
+	procWNetAddConnection2W = mpr.NewProc("WNetAddConnection2W")
+)
+
+const resourceTypeDisk = 1
+
+// netResource is NETRESOURCEW.
+type netResource struct {
+	Scope       uint32
+	Type        uint32
+	DisplayType uint32
+	Usage       uint32
+	LocalName   *uint16
+	RemoteName  *uint16
+	Comment     *uint16
+	Provider    *uint16
+}
+
+// connectShare connects a share without mapping it to a drive letter, as the current
+// user if credentials is nil.
+func connectShare(share string, credentials *ShareCredentials) error {
+	remote, err := windows.UTF16PtrFromString(share)
+	if err != nil {
+		return err
+	}
+	resource := netResource{Type: resourceTypeDisk, RemoteName: remote}
+	var user, password *uint16
+	if credentials != nil {
+		if user, err = windows.UTF16PtrFromString(credentials.User); err != nil {
+			return err
+		}
+		if password, err = windows.UTF16PtrFromString(credentials.Password); err != nil {
+			return err
+		}
+	}
+	result, _, _ := procWNetAddConnection2W.Call(uintptr(unsafe.Pointer(&resource)), uintptr(unsafe.Pointer(password)), uintptr(unsafe.Pointer(user)), 0)
+	switch errno := windows.Errno(result); {
+	case errno == 0:
+		return nil
+	// The share is already connected, with other credentials in the case of the
+	// conflict which Windows does not allow to be replaced while it is in use.
+	case errors.Is(errno, windows.ERROR_ALREADY_ASSIGNED), errors.Is(errno, windows.ERROR_SESSION_CREDENTIAL_CONFLICT):
+		return nil
+	default:
+		return errno
+	}
+}
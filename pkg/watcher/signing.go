@@ -0,0 +1,298 @@
+package watcher
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Files in the data folder of the app that hold the key this installation signs
+// backups with and the public keys whose signatures are trusted.
+const (
+	signingKeyName  = "signing.key"
+	trustedKeysName = "trusted_keys"
+)
+
+// Prefix of an exported public key, so the type of key is clear when it is copied to
+// another installation.
+const publicKeyPrefix = "ed25519 "
+
+var (
+	ErrNoSigningKey = errors.New("no signing key, generate one first")
+	// The backup is not signed by a trusted key, or its files or sidecar were changed
+	// after it was signed.
+	ErrUntrustedBackup = errors.New("backup is not signed by a trusted key")
+)
+
+// TrustedKey is a public key whose signatures are accepted.
+type TrustedKey struct {
+	ID        string `json:"id"`
+	PublicKey string `json:"public_key"`
+	// True for the key of this installation.
+	Own bool `json:"own,omitempty"`
+}
+
+// Keyring holds the ed25519 key this installation signs backups with and the public
+// keys of other installations, or of keys that were replaced, that are also trusted.
+// It is shared by every watcher.
+type Keyring struct {
+	mu      sync.Mutex
+	dir     string
+	private ed25519.PrivateKey
+	// Public keys of other installations and replaced keys, in the order they were
+	// trusted.
+	trusted []ed25519.PublicKey
+}
+
+// KeyID returns a short name for a public key, the start of its SHA-256 hash.
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// FormatPublicKey formats a public key the way TrustKey reads it.
+func FormatPublicKey(key ed25519.PublicKey) string {
+	return publicKeyPrefix + base64.StdEncoding.EncodeToString(key)
+}
+
+// ParsePublicKey reads a key formatted by FormatPublicKey.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(s), publicKeyPrefix)
+	if !ok {
+		return nil, fmt.Errorf("public key must start with %q", publicKeyPrefix)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding public key: %w", err)
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(data))
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// LoadKeyring reads the keys saved in dir, a missing file is the same as having no
+// keys.
+func LoadKeyring(dir string) (*Keyring, error) {
+	k := &Keyring{dir: dir}
+
+	data, err := os.ReadFile(filepath.Join(dir, signingKeyName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading signing key: %w", err)
+	}
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("error reading signing key: not a PEM file")
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error reading signing key: %w", err)
+		}
+		private, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("error reading signing key: not an ed25519 key")
+		}
+		k.private = private
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, trustedKeysName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading trusted keys: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := ParsePublicKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("error reading trusted keys: %w", err)
+		}
+		k.trusted = append(k.trusted, key)
+	}
+	return k, nil
+}
+
+// GenerateKey creates a new signing key and returns its ID. The public key of a key it
+// replaces stays trusted so the backups it signed can still be restored.
+func (k *Keyring) GenerateKey() (string, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("error generating signing key: %w", err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	trusted := k.trusted
+	if k.private != nil {
+		trusted = appendKey(trusted, k.private.Public().(ed25519.PublicKey))
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", fmt.Errorf("error saving signing key: %w", err)
+	}
+	if err := k.saveTrusted(trusted); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(k.dir, signingKeyName), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return "", fmt.Errorf("error saving signing key: %w", err)
+	}
+	k.private = private
+	k.trusted = trusted
+	return KeyID(public), nil
+}
+
+// PublicKey returns the public key of this installation formatted for TrustKey on
+// another installation.
+func (k *Keyring) PublicKey() (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.private == nil {
+		return "", ErrNoSigningKey
+	}
+	return FormatPublicKey(k.private.Public().(ed25519.PublicKey)), nil
+}
+
+// TrustKey accepts the signatures of a public key formatted by FormatPublicKey and
+// returns its ID.
+func (k *Keyring) TrustKey(publicKey string) (string, error) {
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	trusted := appendKey(k.trusted, key)
+	if err := k.saveTrusted(trusted); err != nil {
+		return "", err
+	}
+	k.trusted = trusted
+	return KeyID(key), nil
+}
+
+// UntrustKey stops accepting the signatures of a key by its ID. The key of this
+// installation is always trusted.
+func (k *Keyring) UntrustKey(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	i := slices.IndexFunc(k.trusted, func(key ed25519.PublicKey) bool { return KeyID(key) == id })
+	if i == -1 {
+		return fmt.Errorf("key %s is not trusted", id)
+	}
+	trusted := slices.Delete(slices.Clone(k.trusted), i, i+1)
+	if err := k.saveTrusted(trusted); err != nil {
+		return err
+	}
+	k.trusted = trusted
+	return nil
+}
+
+// TrustedKeys returns the key of this installation followed by the other trusted keys.
+func (k *Keyring) TrustedKeys() []TrustedKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys := []TrustedKey{}
+	if k.private != nil {
+		public := k.private.Public().(ed25519.PublicKey)
+		keys = append(keys, TrustedKey{ID: KeyID(public), PublicKey: FormatPublicKey(public), Own: true})
+	}
+	for _, key := range k.trusted {
+		keys = append(keys, TrustedKey{ID: KeyID(key), PublicKey: FormatPublicKey(key)})
+	}
+	return keys
+}
+
+// saveTrusted writes the trusted keys, must be called while holding k.mu.
+func (k *Keyring) saveTrusted(trusted []ed25519.PublicKey) error {
+	var builder strings.Builder
+	builder.WriteString("# Public keys whose backup signatures are trusted, one per line\n")
+	for _, key := range trusted {
+		builder.WriteString(FormatPublicKey(key) + "\n")
+	}
+	if err := writeFileAtomic(filepath.Join(k.dir, trustedKeysName), []byte(builder.String()), 0600); err != nil {
+		return fmt.Errorf("error saving trusted keys: %w", err)
+	}
+	return nil
+}
+
+// appendKey adds key to keys unless it is already there.
+func appendKey(keys []ed25519.PublicKey, key ed25519.PublicKey) []ed25519.PublicKey {
+	if slices.ContainsFunc(keys, func(other ed25519.PublicKey) bool { return key.Equal(other) }) {
+		return keys
+	}
+	return append(slices.Clone(keys), key)
+}
+
+// sign signs message with the key of this installation and returns the signature and
+// the ID of the key.
+func (k *Keyring) sign(message []byte) (string, string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.private == nil {
+		return "", "", ErrNoSigningKey
+	}
+	signature := ed25519.Sign(k.private, message)
+	return base64.StdEncoding.EncodeToString(signature), KeyID(k.private.Public().(ed25519.PublicKey)), nil
+}
+
+// verify checks a signature made by sign against the trusted keys.
+func (k *Keyring) verify(message []byte, signature, keyID string) error {
+	data, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("error decoding signature: %w", err)
+	}
+	k.mu.Lock()
+	keys := slices.Clone(k.trusted)
+	if k.private != nil {
+		keys = append(keys, k.private.Public().(ed25519.PublicKey))
+	}
+	k.mu.Unlock()
+
+	for _, key := range keys {
+		if KeyID(key) != keyID {
+			continue
+		}
+		if ed25519.Verify(key, message, data) {
+			return nil
+		}
+		return errors.New("the signature does not match")
+	}
+	return fmt.Errorf("signed by key %s, which is not trusted", keyID)
+}
+
+// validateSigning rejects signing backups whose manifests are hashed with xxHash, a
+// backup could be swapped for one with the same hash. Signed backups use SHA-256 unless
+// the algorithm is set.
+func validateSigning(config *WatcherConfig) error {
+	if config.SignBackups && config.HashAlgorithm == HashXXHash {
+		return fmt.Errorf("signed backups need the %s hash algorithm, %s is not collision resistant", HashSHA256, HashXXHash)
+	}
+	return nil
+}
+
+// signedMessage is what the signature of a backup covers: the watcher, when the backup
+// was made and the hash of its files.
+func (s BackupSidecar) signedMessage() []byte {
+	algorithm := s.HashAlgorithm
+	if algorithm == "" {
+		algorithm = HashSHA256
+	}
+	return []byte(fmt.Sprintf("i-saw-that backup\nwatcher %s\ntimestamp %s\nmanifest %s %s\n",
+		s.Watcher, strconv.FormatFloat(s.Timestamp, 'f', -1, 64), algorithm, s.ManifestHash))
+}
@@ -0,0 +1,194 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSignedBackup(t *testing.T) {
+	t.Parallel()
+	keyring, err := LoadKeyring(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to load keyring: %v", err)
+	}
+	if _, err := keyring.GenerateKey(); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig, WithKeyring(keyring))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+	watcher.SignBackups = true
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 1024)
+	watcher.CreateBackup()
+	unsigned, signed := watcher.Metadata[0], watcher.Metadata[1]
+
+	if result := watcher.VerifyBackup(signed); result.Status != VerifyStatusOK {
+		t.Fatalf("Expected a signed backup to verify, got %s: %s", result.Status, result.Error)
+	}
+	if result := watcher.VerifyBackup(unsigned); result.Status != VerifyStatusUnsigned {
+		t.Errorf("Expected a backup without a signature to be unsigned, got %s", result.Status)
+	}
+	if _, err := watcher.RestoreBackup(unsigned.Path); !errors.Is(err, ErrUntrustedBackup) {
+		t.Errorf("Expected restoring an unsigned backup to be refused, got %v", err)
+	}
+
+	// Changing the sidecar to match files that were swapped breaks the signature.
+	sidecar, err := ReadBackupSidecar(watcher.BackupPath(signed))
+	if err != nil {
+		t.Fatalf("Failed to read sidecar: %v", err)
+	}
+	if sidecar.HashAlgorithm != HashSHA256 {
+		t.Errorf("Expected signed backups to hash with %s, got %s", HashSHA256, sidecar.HashAlgorithm)
+	}
+	sidecar.Watcher = "other"
+	if err := writeBackupSidecar(watcher.BackupPath(signed), sidecar); err != nil {
+		t.Fatalf("Failed to write sidecar: %v", err)
+	}
+	if result := watcher.VerifyBackup(signed); result.Status != VerifyStatusBadSignature {
+		t.Errorf("Expected a changed sidecar to fail the signature, got %s", result.Status)
+	}
+	if _, err := watcher.RestoreBackup(signed.Path); !errors.Is(err, ErrUntrustedBackup) {
+		t.Errorf("Expected restoring a changed backup to be refused, got %v", err)
+	}
+}
+
+func TestSignedSidecarOfOtherBackup(t *testing.T) {
+	t.Parallel()
+	keyring, err := LoadKeyring(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to load keyring: %v", err)
+	}
+	if _, err := keyring.GenerateKey(); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig, WithKeyring(keyring))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.SignBackups = true
+
+	// Both backups have the same files, so only the sidecar ties a signature to one.
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+	watcher.CreateBackup()
+	first, second := watcher.Metadata[0], watcher.Metadata[1]
+	sidecar, err := ReadBackupSidecar(watcher.BackupPath(second))
+	if err != nil {
+		t.Fatalf("Failed to read sidecar: %v", err)
+	}
+	if err := writeBackupSidecar(watcher.BackupPath(first), sidecar); err != nil {
+		t.Fatalf("Failed to write sidecar: %v", err)
+	}
+	if result := watcher.VerifyBackup(first); result.Status != VerifyStatusBadSignature {
+		t.Errorf("Expected the sidecar of another backup to be refused, got %s", result.Status)
+	}
+	if result := watcher.VerifyBackup(second); result.Status != VerifyStatusOK {
+		t.Errorf("Expected the other backup to verify, got %s: %s", result.Status, result.Error)
+	}
+}
+
+func TestSignedBackupWithoutKeyring(t *testing.T) {
+	t.Parallel()
+	config := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(config)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, config.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+
+	// Signatures that can not be checked are not trusted.
+	watcher.SignBackups = true
+	watcher.keyring = nil
+	if _, err := watcher.RestoreBackup(watcher.Metadata[0].Path); !errors.Is(err, ErrUntrustedBackup) {
+		t.Errorf("Expected restoring without a keyring to be refused, got %v", err)
+	}
+
+	if err := validateSigning(&WatcherConfig{SignBackups: true, HashAlgorithm: HashXXHash}); err == nil {
+		t.Error("Expected signing xxhash manifests to be rejected")
+	}
+	if err := validateSigning(&WatcherConfig{SignBackups: true}); err != nil {
+		t.Errorf("Expected signing with the default algorithm to be accepted, got %v", err)
+	}
+}
+
+func TestKeyringTrust(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	keyring, err := LoadKeyring(dir)
+	if err != nil {
+		t.Fatalf("Failed to load keyring: %v", err)
+	}
+	if _, err := keyring.PublicKey(); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("Expected no signing key, got %v", err)
+	}
+	first, err := keyring.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signature, id, err := keyring.sign([]byte("message"))
+	if err != nil || id != first {
+		t.Fatalf("Expected a signature by %s, got %s: %v", first, id, err)
+	}
+
+	// The replaced key stays trusted so older backups can still be restored.
+	second, err := keyring.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	other, err := LoadKeyring(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to load keyring: %v", err)
+	}
+	if _, err := other.GenerateKey(); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	publicKey, err := other.PublicKey()
+	if err != nil {
+		t.Fatalf("Failed to read public key: %v", err)
+	}
+	third, err := keyring.TrustKey(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to trust key: %v", err)
+	}
+
+	reloaded, err := LoadKeyring(dir)
+	if err != nil {
+		t.Fatalf("Failed to reload keyring: %v", err)
+	}
+	keys := reloaded.TrustedKeys()
+	if len(keys) != 3 || keys[0].ID != second || !keys[0].Own || keys[1].ID != first || keys[2].ID != third {
+		t.Fatalf("Expected keys %s, %s and %s, got %+v", second, first, third, keys)
+	}
+	if err := reloaded.verify([]byte("message"), signature, first); err != nil {
+		t.Errorf("Expected the replaced key to verify, got %v", err)
+	}
+	if err := reloaded.verify([]byte("changed"), signature, first); err == nil {
+		t.Error("Expected a changed message not to verify")
+	}
+
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(filepath.Join(dir, trustedKeysName)); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("Expected the trusted keys to only be readable by the owner, got %v: %v", info.Mode(), err)
+		}
+	}
+
+	if err := reloaded.UntrustKey(first); err != nil {
+		t.Fatalf("Failed to untrust key: %v", err)
+	}
+	if err := reloaded.verify([]byte("message"), signature, first); err == nil {
+		t.Error("Expected an untrusted key not to verify")
+	}
+	if err := reloaded.UntrustKey(second); err == nil {
+		t.Error("Expected the key of this installation to stay trusted")
+	}
+}
@@ -0,0 +1,140 @@
+package watcher
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SnapshotMode replaces copying the source with a snapshot of the filesystem it is on,
+// which is instant and only uses space for files that change afterwards.
+type SnapshotMode string
+
+const (
+	// The source is a btrfs subvolume that is snapshotted into the backup folder, the
+	// destination must be on the same filesystem.
+	SnapshotModeBtrfs SnapshotMode = "btrfs"
+	// The source is the mountpoint of a ZFS dataset. Snapshots are stored in the
+	// dataset and read from its .zfs folder, the destination only holds the metadata.
+	SnapshotModeZFS SnapshotMode = "zfs"
+	// The source is copied, but files that did not change since the previous backup are
+	// cloned from it. Clones share their blocks until either file is changed, so every
+	// backup is complete but only changed files use space. The destination must be on a
+	// filesystem with reflinks such as btrfs, XFS or APFS, files are copied otherwise.
+	SnapshotModeReflink SnapshotMode = "reflink"
+)
+
+func (m SnapshotMode) validate() error {
+	switch m {
+	case "", SnapshotModeBtrfs, SnapshotModeZFS, SnapshotModeReflink:
+		return nil
+	}
+	return fmt.Errorf("unknown snapshot mode: %s", m)
+}
+
+// validateSnapshotMode checks that the snapshot mode of a folder pair can be used with
+// the rest of its settings.
+func validateSnapshotMode(config *WatcherConfig, singleFile bool) error {
+	if err := config.Snapshot.validate(); err != nil {
+		return err
+	}
+	if config.Snapshot == "" {
+		return nil
+	}
+	if singleFile {
+		return fmt.Errorf("%s snapshots require the source to be a folder", config.Snapshot)
+	}
+	if config.Restic.enabled() {
+		return fmt.Errorf("%s snapshots can not be combined with a restic repository", config.Snapshot)
+	}
+	if config.Snapshot == SnapshotModeReflink && !strings.EqualFold(config.CopyEngine, CopyEngineGo) && config.CopyEngine != "" {
+		return fmt.Errorf("%s snapshots are only supported by the %s copy engine", config.Snapshot, CopyEngineGo)
+	}
+	return nil
+}
+
+// runSnapshotTool runs btrfs or zfs and returns its output, stderr is included in the
+// error since the tools explain what went wrong there.
+func runSnapshotTool(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return output, fmt.Errorf("%s %s: %w: %s", name, args[0], err, message)
+		}
+		return output, fmt.Errorf("%s %s: %w", name, args[0], err)
+	}
+	return output, nil
+}
+
+// btrfsSnapshot creates a writable snapshot of the source subvolume at destination so
+// the sidecar can still be written into it.
+func btrfsSnapshot(source, destination string) error {
+	_, err := runSnapshotTool("btrfs", "subvolume", "snapshot", source, destination)
+	return err
+}
+
+func btrfsDelete(path string) error {
+	_, err := runSnapshotTool("btrfs", "subvolume", "delete", path)
+	return err
+}
+
+// zfsDataset returns the name of the ZFS dataset mounted at source.
+func zfsDataset(source string) (string, error) {
+	output, err := runSnapshotTool("zfs", "list", "-H", "-o", "name,mountpoint", source)
+	if err != nil {
+		return "", err
+	}
+	return parseZFSList(output, source)
+}
+
+// parseZFSList reads the output of "zfs list -H -o name,mountpoint". Snapshots are only
+// reachable through the .zfs folder of the mountpoint, so a source inside a dataset is
+// not enough.
+func parseZFSList(output []byte, source string) (string, error) {
+	name, mountpoint, ok := strings.Cut(strings.TrimSpace(string(output)), "\t")
+	if !ok {
+		return "", fmt.Errorf("unexpected output from zfs list: %q", output)
+	}
+	if filepath.Clean(mountpoint) != filepath.Clean(source) {
+		return "", fmt.Errorf("source must be the mountpoint of a ZFS dataset, %s is inside %s mounted at %s", source, name, mountpoint)
+	}
+	return name, nil
+}
+
+// zfsSnapshotName returns a snapshot name for a backup, ZFS does not allow / in the
+// part after the @.
+func zfsSnapshotName(dataset, backupPath string) string {
+	return dataset + "@" + strings.ReplaceAll(backupPath, "/", "_")
+}
+
+// zfsSnapshotFolder returns the folder a snapshot of the dataset mounted at source can
+// be read from.
+func zfsSnapshotFolder(source, snapshot string) string {
+	_, name, _ := strings.Cut(snapshot, "@")
+	return filepath.Join(source, ".zfs", "snapshot", name)
+}
+
+// createZFSBackup snapshots the dataset mounted at source and returns the backup
+// without a path.
+func (w *Watcher) createZFSBackup(source, timestampFolder string) (Backup, error) {
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return Backup{}, err
+	}
+	dataset, err := zfsDataset(absSource)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	snapshot := zfsSnapshotName(dataset, timestampFolder)
+	Logf(w.Name, LogLevelInfo, "Creating ZFS snapshot %s", snapshot)
+	if _, err := runSnapshotTool("zfs", "snapshot", snapshot); err != nil {
+		return Backup{}, err
+	}
+	return Backup{Snapshot: SnapshotModeZFS, SnapshotName: snapshot}, nil
+}
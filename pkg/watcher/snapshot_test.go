@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseZFSList(t *testing.T) {
+	t.Parallel()
+	dataset, err := parseZFSList([]byte("tank/data\t/tank/data\n"), "/tank/data/")
+	if err != nil {
+		t.Fatalf("Failed to parse zfs list output: %v", err)
+	}
+	if dataset != "tank/data" {
+		t.Errorf("Expected dataset 'tank/data', got '%s'", dataset)
+	}
+
+	if _, err := parseZFSList([]byte("tank/data\t/tank/data\n"), "/tank/data/projects"); err == nil {
+		t.Errorf("Expected an error for a source inside a dataset")
+	}
+	if _, err := parseZFSList([]byte("garbage"), "/tank/data"); err == nil {
+		t.Errorf("Expected an error for unexpected output")
+	}
+}
+
+func TestZFSSnapshotFolder(t *testing.T) {
+	t.Parallel()
+	snapshot := zfsSnapshotName("tank/data", "2024/05/2024-05-01_18-30-00")
+	if snapshot != "tank/data@2024_05_2024-05-01_18-30-00" {
+		t.Errorf("Expected slashes to be replaced in the snapshot name, got '%s'", snapshot)
+	}
+
+	expected := filepath.Join("/tank/data", ".zfs", "snapshot", "2024_05_2024-05-01_18-30-00")
+	if folder := zfsSnapshotFolder("/tank/data", snapshot); folder != expected {
+		t.Errorf("Expected snapshot folder '%s', got '%s'", expected, folder)
+	}
+}
+
+func TestSnapshotModeValidation(t *testing.T) {
+	t.Parallel()
+	temp := DefaultTempWatcherConfig(t)
+	CreateDummyFile(t, temp.Source, "file1.txt", 10)
+
+	config := &WatcherConfig{
+		ID:           temp.Name,
+		Source:       temp.Source,
+		Destination:  temp.Destination,
+		WaitTime:     Duration(temp.WaitTime),
+		FolderFormat: temp.FolderFormat,
+		Snapshot:     "lvm",
+	}
+	if _, err := NewWatcher(*config); err == nil {
+		t.Errorf("Expected an error for an unknown snapshot mode")
+	}
+
+	config.Snapshot = SnapshotModeBtrfs
+	config.Restic = ResticConfig{Repository: "/repo"}
+	if _, err := NewWatcher(*config); err == nil {
+		t.Errorf("Expected an error when combining snapshots with restic")
+	}
+
+	config.Restic = ResticConfig{}
+	config.Source = filepath.Join(temp.Source, "file1.txt")
+	if _, err := NewWatcher(*config); err == nil {
+		t.Errorf("Expected an error for a single file source")
+	}
+
+	config.Source = temp.Source
+	watcher, err := NewWatcher(*config)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if watcher.Snapshot != SnapshotModeBtrfs {
+		t.Errorf("Expected the snapshot mode to be set, got '%s'", watcher.Snapshot)
+	}
+}
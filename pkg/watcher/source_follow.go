@@ -0,0 +1,142 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// How often a watcher with FollowSource checks that its source still exists.
+const defaultFollowSourceInterval = 10 * time.Second
+
+// How many folders below the parent of a missing source are searched for it, folders
+// that were also missing add one more level each.
+const followSourceDepth = 2
+
+// Optional interface for observers that also want to be told when a watcher with
+// FollowSource finds its source in a new place, so the new path can be saved.
+type SourceMovedObserver interface {
+	OnSourceMoved(watcher *Watcher, oldSource, newSource string)
+}
+
+// followSourceLoop checks that the source still exists and, once it is gone, looks for
+// the same folder or file under the nearest parent that still exists. identity is the
+// source as it was when the watcher started.
+func (w *Watcher) followSourceLoop(identity os.FileInfo, stop chan struct{}) {
+	for {
+		timer := w.clock.NewTimer(w.followSourceInterval)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		w.mu.Lock()
+		source := w.Source
+		w.mu.Unlock()
+		if _, err := os.Lstat(source); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		moved, ok := findMovedSource(source, identity)
+		if !ok {
+			continue
+		}
+		// Following restarts the watcher, which starts a new loop.
+		go w.followSource(source, moved, stop)
+		return
+	}
+}
+
+// findMovedSource searches below the nearest existing parent of source for a path that
+// is the same file as identity.
+func findMovedSource(source string, identity os.FileInfo) (string, bool) {
+	root := filepath.Dir(source)
+	depth := followSourceDepth
+	for {
+		if _, err := os.Stat(root); err == nil {
+			break
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return "", false
+		}
+		root = parent
+		depth++
+	}
+
+	level := []string{root}
+	for range depth {
+		var next []string
+		for _, dir := range level {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				path := filepath.Join(dir, entry.Name())
+				// Lstat instead of entry.Info since SameFile needs the file ID on Windows.
+				info, err := os.Lstat(path)
+				if err != nil {
+					continue
+				}
+				if os.SameFile(info, identity) {
+					return path, true
+				}
+				if info.IsDir() {
+					next = append(next, path)
+				}
+			}
+		}
+		level = next
+	}
+	return "", false
+}
+
+// followSource restarts the watcher with newSource as its source and tells the
+// observers, a source that can not be used with the destinations only gives a warning.
+// Nothing is done if the watcher was stopped or restarted since stop was created.
+func (w *Watcher) followSource(oldSource, newSource string, stop chan struct{}) {
+	w.mu.Lock()
+	if !w.running || w.stopChan != stop {
+		w.mu.Unlock()
+		return
+	}
+	destination := w.Destination
+	rotationDestinations := w.RotationDestinations
+	done := w.backupLoopDone
+	w.mu.Unlock()
+
+	var errs error
+	validateSourceAndDestination(newSource, destination, &errs)
+	validateRotationDestinations(newSource, destination, rotationDestinations, &errs)
+	if errs != nil {
+		w.notifyWarning("Source %s was moved to %s but can not be followed: %v", oldSource, newSource, errs)
+		return
+	}
+
+	if err := w.StopWatcher(); err != nil {
+		Logf(w.Name, LogLevelError, "Error stopping watcher: %v", err)
+	}
+	if done != nil {
+		<-done
+	}
+
+	w.mu.Lock()
+	w.Source = newSource
+	w.mu.Unlock()
+	message := fmt.Sprintf("Source moved from %s to %s", oldSource, newSource)
+	Logf(w.Name, LogLevelInfo, "%s, following it", message)
+	w.recordEvent(HistoryEvent{Type: HistorySourceMoved, Message: message})
+	for _, observer := range w.observers() {
+		if movedObserver, ok := observer.(SourceMovedObserver); ok {
+			w.dispatch(func() { movedObserver.OnSourceMoved(w, oldSource, newSource) })
+		}
+	}
+
+	if err := w.StartWatcher(); err != nil {
+		Logf(w.Name, LogLevelError, "Error restarting watcher after the source moved: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sourceMovedRecorder struct {
+	mu    sync.Mutex
+	moves [][2]string
+}
+
+func (r *sourceMovedRecorder) OnBackupCompletion(watcher *Watcher) {}
+
+func (r *sourceMovedRecorder) OnSourceMoved(watcher *Watcher, oldSource, newSource string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.moves = append(r.moves, [2]string{oldSource, newSource})
+}
+
+func TestFollowSource(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	CreateDummyFile(t, WatcherConfig.Source, "file.txt", 10)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.FollowSource = true
+	watcher.followSourceInterval = 10 * time.Millisecond
+	recorder := &sourceMovedRecorder{}
+	watcher.AddObserver(recorder)
+	if err := watcher.StartWatcher(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	t.Cleanup(func() { watcher.Shutdown() })
+
+	moved := filepath.Join(filepath.Dir(WatcherConfig.Source), "renamed", "source")
+	if err := os.MkdirAll(filepath.Dir(moved), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	if err := os.Rename(WatcherConfig.Source, moved); err != nil {
+		t.Fatalf("Failed to move source: %v", err)
+	}
+
+	waitUntil(t, fmt.Sprintf("Expected the watcher to follow the source to %s", moved), func() bool {
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+		return watcher.Source == moved && watcher.running
+	})
+	watcher.flushObservers()
+
+	recorder.mu.Lock()
+	moves := recorder.moves
+	recorder.mu.Unlock()
+	if len(moves) != 1 || moves[0] != [2]string{WatcherConfig.Source, moved} {
+		t.Errorf("Expected the observer to be told about the move, got %v", moves)
+	}
+	history := watcher.History()
+	if last := history[len(history)-1]; last.Type != HistorySourceMoved {
+		t.Errorf("Expected the move in the history, got %+v", last)
+	}
+}
+
+func TestFindMovedSourceMissingParent(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	source := filepath.Join(root, "games", "save")
+	if err := os.MkdirAll(source, 0755); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	identity, err := os.Stat(source)
+	if err != nil {
+		t.Fatalf("Failed to stat source: %v", err)
+	}
+	// The parent of the source is renamed, so the search starts one level higher.
+	if err := os.Rename(filepath.Join(root, "games"), filepath.Join(root, "old games")); err != nil {
+		t.Fatalf("Failed to rename parent: %v", err)
+	}
+	moved, ok := findMovedSource(source, identity)
+	if expected := filepath.Join(root, "old games", "save"); !ok || moved != expected {
+		t.Errorf("Expected the source to be found at %s, got %q", expected, moved)
+	}
+
+	if err := os.RemoveAll(filepath.Join(root, "old games")); err != nil {
+		t.Fatalf("Failed to remove source: %v", err)
+	}
+	if moved, ok := findMovedSource(source, identity); ok {
+		t.Errorf("Expected a deleted source not to be found, got %s", moved)
+	}
+}
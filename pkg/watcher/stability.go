@@ -0,0 +1,151 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// StabilityConfig checks that the files changed since the last backup have stopped
+// growing before they are copied, so a short wait time does not back up half written
+// large files.
+type StabilityConfig struct {
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty" toml:"enabled,omitempty"`
+	// Time between the two size checks.
+	Interval Duration `json:"interval,omitempty" yaml:"interval,omitempty" toml:"interval,omitempty"`
+	// How long a backup is deferred for files that keep changing before they are backed
+	// up anyway.
+	MaxWait Duration `json:"max_wait,omitempty" yaml:"max_wait,omitempty" toml:"max_wait,omitempty"`
+}
+
+const (
+	defaultStabilityInterval = time.Second
+	defaultStabilityMaxWait  = 10 * time.Minute
+)
+
+func (c StabilityConfig) enabled() bool {
+	return c.Enabled
+}
+
+func (c StabilityConfig) validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("stability interval must be at least 0 seconds")
+	}
+	if c.MaxWait < 0 {
+		return fmt.Errorf("stability max wait must be at least 0 seconds")
+	}
+	return nil
+}
+
+func (c StabilityConfig) interval() time.Duration {
+	if c.Interval == 0 {
+		return defaultStabilityInterval
+	}
+	return time.Duration(c.Interval)
+}
+
+func (c StabilityConfig) maxWait() time.Duration {
+	if c.MaxWait == 0 {
+		return defaultStabilityMaxWait
+	}
+	return time.Duration(c.MaxWait)
+}
+
+// recordChange adds a path from a file event to the files checked by the stability
+// check.
+func (w *Watcher) recordChange(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Stability.enabled() {
+		w.changedPaths[filepath.Clean(path)] = true
+	}
+}
+
+// clearChangedPaths starts a new set of changed paths when a backup is made, changes
+// made during the backup are checked before the next one.
+func (w *Watcher) clearChangedPaths() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	clear(w.changedPaths)
+}
+
+// fileSizes returns the size and modification time of each regular file in paths,
+// paths that were removed or are folders are left out.
+func fileSizes(fsys FS, paths []string) map[string]string {
+	sizes := map[string]string{}
+	for _, path := range paths {
+		info, err := fsys.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		sizes[path] = fmt.Sprintf("%d|%d", info.Size(), info.ModTime().UnixNano())
+	}
+	return sizes
+}
+
+// growingFiles returns the files whose size or modification time changed between two
+// calls to fileSizes, and the files that were not in the first one.
+func growingFiles(before, after map[string]string) []string {
+	growing := []string{}
+	for path, size := range after {
+		if previous, ok := before[path]; !ok || previous != size {
+			growing = append(growing, path)
+		}
+	}
+	slices.Sort(growing)
+	return growing
+}
+
+// stabilityState is kept by the backup thread between the checks of a deferred backup,
+// so checking the sizes never sleeps on the backup thread.
+type stabilityState struct {
+	// Sizes found by the previous check, nil before the first one.
+	sizes map[string]string
+	// When the check first found files still being written.
+	unstableSince time.Time
+}
+
+// checkStability defers an automatic backup while files changed since the last backup
+// are still being written. The sizes are compared with the ones from the previous call,
+// the first call only records them and defers the backup for the interval. It returns
+// false if the backup was deferred.
+func (w *Watcher) checkStability(config StabilityConfig, state *stabilityState, deferBackup func(time.Duration, string)) bool {
+	w.mu.Lock()
+	paths := make([]string, 0, len(w.changedPaths))
+	for path := range w.changedPaths {
+		paths = append(paths, path)
+	}
+	w.mu.Unlock()
+
+	sizes := fileSizes(w.fs, paths)
+	if len(sizes) == 0 {
+		return true
+	}
+	if state.sizes == nil {
+		state.sizes = sizes
+		deferBackup(config.interval(), "Checking that the changed files are not still being written")
+		return false
+	}
+	growing := growingFiles(state.sizes, sizes)
+	state.sizes = sizes
+	if len(growing) == 0 {
+		return true
+	}
+	names := make([]string, len(growing))
+	for i, path := range growing {
+		names[i] = filepath.Base(path)
+	}
+	list := strings.Join(names, ", ")
+
+	if state.unstableSince.IsZero() {
+		state.unstableSince = w.clock.Now()
+	}
+	if w.clock.Now().Sub(state.unstableSince) < config.maxWait() {
+		deferBackup(config.interval(), fmt.Sprintf("Files are still being written: %s", list))
+		return false
+	}
+	w.notifyWarning("Files are still being written after %s, backing them up anyway: %s", config.maxWait(), list)
+	return true
+}
@@ -0,0 +1,160 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// appendKilobyte appends to a file, like a large file being copied into the source.
+func appendKilobyte(t *testing.T, path string) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(make([]byte, 1024)); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
+func TestGrowingFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	CreateDummyFile(t, dir, "stable.txt", 10)
+	CreateDummyFile(t, dir, "growing.bin", 10)
+	growing := filepath.Join(dir, "growing.bin")
+
+	paths := []string{filepath.Join(dir, "stable.txt"), growing, filepath.Join(dir, "deleted.txt")}
+	before := fileSizes(osFS{}, paths)
+	// The file grows between the two checks.
+	CreateDummyFile(t, dir, "growing.bin", 20)
+	after := fileSizes(osFS{}, paths)
+	if files := growingFiles(before, after); len(files) != 1 || files[0] != growing {
+		t.Errorf("Expected only growing.bin to be growing, got %v", files)
+	}
+}
+
+func TestStabilityCheckDoesNotSleep(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(NewFakeClock(time.Now())))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Stability = StabilityConfig{Enabled: true}
+	CreateDummyFile(t, WatcherConfig.Source, "file.txt", 10)
+	watcher.recordChange(filepath.Join(WatcherConfig.Source, "file.txt"))
+
+	// The first check only records the sizes and asks to be called again, the fake
+	// clock would block a sleep forever.
+	var state stabilityState
+	deferred := []time.Duration{}
+	deferBackup := func(wait time.Duration, reason string) { deferred = append(deferred, wait) }
+	if watcher.checkStability(watcher.Stability, &state, deferBackup) || len(deferred) != 1 || deferred[0] != defaultStabilityInterval {
+		t.Fatalf("Expected the first check to defer the backup for the interval, got %v", deferred)
+	}
+	if !watcher.checkStability(watcher.Stability, &state, deferBackup) || len(deferred) != 1 {
+		t.Errorf("Expected an unchanged file to be stable, got %v", deferred)
+	}
+}
+
+func TestBackupWaitsForGrowingFiles(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 100 * time.Millisecond
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	interval := time.Second
+	watcher.Stability = StabilityConfig{Enabled: true, Interval: Duration(interval)}
+	observer := startBackupLoop(t, watcher)
+
+	path := filepath.Join(WatcherConfig.Source, "large.bin")
+	appendKilobyte(t, path)
+	watcher.recordChange(path)
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+	clock.BlockUntil(1)
+	clock.Advance(WatcherConfig.WaitTime)
+
+	// The file grows between every check.
+	for range 3 {
+		clock.BlockUntil(1)
+		appendKilobyte(t, path)
+		clock.Advance(interval)
+	}
+	clock.BlockUntil(1)
+	watcher.flushObservers()
+	if count := observer.getCurrentCount(); count != 0 {
+		t.Fatalf("Expected no backups while the file is growing, got %d", count)
+	}
+	if deferred := watcher.Status().Deferred; !strings.Contains(deferred, "large.bin") {
+		t.Errorf("Expected the status to name the file, got '%s'", deferred)
+	}
+
+	clock.Advance(interval)
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected a backup once the file stopped growing")
+	}
+	source, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat source: %v", err)
+	}
+	backup, err := os.Stat(filepath.Join(watcher.BackupPath(watcher.ListBackups()[0]), "large.bin"))
+	if err != nil {
+		t.Fatalf("Failed to stat backup: %v", err)
+	}
+	if backup.Size() != source.Size() {
+		t.Errorf("Expected the complete file to be backed up, got %d of %d bytes", backup.Size(), source.Size())
+	}
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	if len(watcher.changedPaths) != 0 {
+		t.Errorf("Expected the changed paths to be cleared by the backup")
+	}
+}
+
+func TestBackupWaitsForGrowingFilesUpToMaxWait(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 100 * time.Millisecond
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	interval := time.Second
+	watcher.Stability = StabilityConfig{Enabled: true, Interval: Duration(interval), MaxWait: Duration(3 * interval)}
+	recorder := &warningRecorder{}
+	watcher.AddObserver(recorder)
+	observer := startBackupLoop(t, watcher)
+
+	path := filepath.Join(WatcherConfig.Source, "large.bin")
+	appendKilobyte(t, path)
+	watcher.recordChange(path)
+	watcher.requestBackup(BackupTriggerChange)
+	waitForRequest(watcher)
+	clock.BlockUntil(1)
+	clock.Advance(WatcherConfig.WaitTime)
+
+	// The file never stops growing, only the max wait causes a backup.
+	for range 4 {
+		clock.BlockUntil(1)
+		appendKilobyte(t, path)
+		clock.Advance(interval)
+	}
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Expected a backup once the max wait has passed")
+	}
+	watcher.flushObservers()
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.warnings) != 1 || !strings.Contains(recorder.warnings[0], "large.bin") {
+		t.Errorf("Expected a warning naming the file, got %v", recorder.warnings)
+	}
+}
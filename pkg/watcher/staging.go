@@ -0,0 +1,99 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cp "github.com/otiai10/copy"
+)
+
+// Prefix of the folders backups are staged in, followed by the name of the watcher so
+// watchers can share a staging folder.
+const stagingPrefix = ".i-saw-that-stage-"
+
+// validateStaging checks that backups can be staged in the staging folder of a folder
+// pair before they are moved to the destination.
+func validateStaging(config *WatcherConfig) error {
+	if config.Staging == "" {
+		return nil
+	}
+	if !filepath.IsAbs(config.Staging) {
+		return fmt.Errorf("staging folder must be an absolute path")
+	}
+	if config.Restic.enabled() {
+		return fmt.Errorf("a staging folder can not be combined with a restic repository")
+	}
+	if config.Snapshot != "" {
+		return fmt.Errorf("a staging folder can not be combined with %s snapshots", config.Snapshot)
+	}
+	// Staging inside the source would back up the backup being made, and the
+	// destinations would see backups appear before they are complete.
+	for _, dir := range append([]string{config.Source, config.Destination}, config.RotationDestinations...) {
+		if dir != "" && isPathWithin(dir, config.Staging) {
+			return fmt.Errorf("staging folder must be outside the source and destinations")
+		}
+	}
+	return nil
+}
+
+// safeName reduces the name of a watcher to characters that are safe in a file name.
+func safeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 128 && (r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// stagingPattern returns the pattern of the staging folders of the watcher.
+func (w *Watcher) stagingPattern() string {
+	return stagingPrefix + safeName(w.Name) + "-*"
+}
+
+// createStagingFolder creates an empty folder in staging for a backup to be made in.
+// The backup itself is made in a "backup" folder inside it so it is created with the
+// same permissions as one made in the destination.
+func (w *Watcher) createStagingFolder(staging string) (string, error) {
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return "", fmt.Errorf("error creating staging folder: %w", err)
+	}
+	root, err := os.MkdirTemp(staging, w.stagingPattern())
+	if err != nil {
+		return "", fmt.Errorf("error creating staging folder: %w", err)
+	}
+	return root, nil
+}
+
+// removeStaleStaging removes the staging folders left behind when the app stopped
+// while a backup was being made.
+func (w *Watcher) removeStaleStaging() {
+	w.mu.Lock()
+	staging := w.Staging
+	w.mu.Unlock()
+	if staging == "" {
+		return
+	}
+	matches, err := filepath.Glob(filepath.Join(staging, w.stagingPattern()))
+	if err != nil {
+		return
+	}
+	for _, match := range matches {
+		Logf(w.Name, LogLevelInfo, "Removing staging folder of an incomplete backup %s", match)
+		if err := os.RemoveAll(match); err != nil {
+			Logf(w.Name, LogLevelWarn, "Error removing staging folder: %v", err)
+		}
+	}
+}
+
+// moveStagedBackup moves a backup from its staging folder to the destination. The
+// staging folder is usually on another disk, so the backup is copied if it can not be
+// renamed. The caller removes the staging folder and a partial copy.
+func moveStagedBackup(staged, destinationPath string, unsupported []Attribute) error {
+	if err := os.Rename(staged, destinationPath); err == nil {
+		return nil
+	}
+	return cp.Copy(staged, destinationPath, degradedCopyOptions(cp.Options{PreserveTimes: true}, unsupported))
+}
@@ -0,0 +1,98 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStagedBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	staging := filepath.Join(WatcherConfig.TempPath, "staging")
+	watcher.Staging = staging
+
+	CreateDummyFile(t, WatcherConfig.Source, "folder/file1.txt", 1024)
+	watcher.CreateBackup()
+	if len(watcher.Metadata) != 1 {
+		t.Fatalf("Expected 1 backup, got %d", len(watcher.Metadata))
+	}
+	backup := watcher.Metadata[0]
+	if _, err := os.Stat(filepath.Join(watcher.BackupPath(backup), "folder", "file1.txt")); err != nil {
+		t.Errorf("Expected the backup in the destination: %v", err)
+	}
+	if result := watcher.VerifyBackup(backup); result.Status != VerifyStatusOK {
+		t.Errorf("Expected the staged backup to verify, got %s: %s", result.Status, result.Error)
+	}
+	if entries, err := os.ReadDir(staging); err != nil || len(entries) != 0 {
+		t.Errorf("Expected the staging folder to be emptied, got %v: %v", entries, err)
+	}
+}
+
+func TestMoveStagedBackup(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	staged := filepath.Join(dir, "staged")
+	CreateDummyFile(t, staged, "folder/file1.txt", 1024)
+	if err := moveStagedBackup(staged, filepath.Join(dir, "backup"), nil); err != nil {
+		t.Fatalf("Failed to move backup: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "backup", "folder", "file1.txt")); err != nil {
+		t.Errorf("Expected the backup to be moved: %v", err)
+	}
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Errorf("Expected the staged backup to be gone, got %v", err)
+	}
+}
+
+func TestRemoveStaleStaging(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	staging := filepath.Join(WatcherConfig.TempPath, "staging")
+	watcher.Staging = staging
+	stale, err := watcher.createStagingFolder(staging)
+	if err != nil {
+		t.Fatalf("Failed to create staging folder: %v", err)
+	}
+	CreateDummyFile(t, stale, "backup/file1.txt", 10)
+	// Staging folders of other watchers are left alone.
+	other := filepath.Join(staging, stagingPrefix+"Other-1")
+	CreateDummyFile(t, other, "backup/file1.txt", 10)
+
+	watcher.removeStaleStaging()
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale staging folder to be removed, got %v", err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("Expected the staging folder of another watcher to be kept: %v", err)
+	}
+}
+
+func TestValidateStaging(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	config := WatcherConfig{Source: filepath.Join(dir, "source"), Destination: filepath.Join(dir, "destination")}
+	config.Staging = filepath.Join(dir, "staging")
+	if err := validateStaging(&config); err != nil {
+		t.Errorf("Expected a separate staging folder to be valid, got %v", err)
+	}
+	for _, staging := range []string{"staging", filepath.Join(config.Source, "staging"), filepath.Join(config.Destination, "staging")} {
+		config.Staging = staging
+		if err := validateStaging(&config); err == nil {
+			t.Errorf("Expected staging folder %s to be invalid", staging)
+		}
+	}
+	config.Staging = filepath.Join(dir, "staging")
+	config.Snapshot = SnapshotModeReflink
+	if err := validateStaging(&config); err == nil {
+		t.Error("Expected staging to be refused with snapshots")
+	}
+}
@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"fmt"
+	"time"
+)
+
+// How often a watcher with MaxStaleness checks how old its newest backup is.
+const stalenessCheckInterval = time.Minute
+
+// checkStaleness warns when the newest backup is older than maxStaleness, restarting
+// the watcher does not reset the age. Without any backups the age is counted from when
+// the watcher started. The warning is shown once and stays in the status until the next
+// backup.
+func (w *Watcher) checkStaleness(maxStaleness time.Duration) {
+	now := w.clock.Now()
+	w.mu.Lock()
+	since := w.startedAt
+	if len(w.Metadata) > 0 {
+		since = BackupTime(w.Metadata[len(w.Metadata)-1])
+	}
+	age := now.Sub(since)
+	wasStale := w.status.Stale != ""
+	w.status.Stale = ""
+	if age >= maxStaleness {
+		w.status.Stale = fmt.Sprintf("No backup for %s, more than the %s limit", formatStaleness(age), formatStaleness(maxStaleness))
+	}
+	stale := w.status.Stale
+	w.mu.Unlock()
+
+	switch {
+	case stale != "" && !wasStale:
+		w.notifyWarning("%s", stale)
+	case stale == "" && wasStale:
+		Logf(w.Name, LogLevelInfo, "Backups are up to date again")
+	}
+}
+
+// formatStaleness writes a duration in hours and minutes, such as "26h5m".
+func formatStaleness(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, minutes)
+}
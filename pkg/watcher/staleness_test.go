@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxStaleness(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.MaxStaleness = Duration(time.Hour)
+	watcher.startedAt = clock.Now()
+	recorder := &warningRecorder{}
+	watcher.AddObserver(recorder)
+	startBackupLoop(t, watcher)
+
+	// Check every minute for an hour and a bit, the warning is only given once.
+	for range 70 {
+		clock.BlockUntil(1)
+		clock.Advance(stalenessCheckInterval)
+	}
+	clock.BlockUntil(1)
+	watcher.flushObservers()
+	if stale := watcher.Status().Stale; stale != "No backup for 1h10m, more than the 1h limit" {
+		t.Errorf("Expected the watcher to be stale, got %q", stale)
+	}
+	if len(recorder.warnings) != 1 {
+		t.Errorf("Expected 1 warning, got %v", recorder.warnings)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file.txt", 10)
+	watcher.CreateBackup()
+	if stale := watcher.Status().Stale; stale != "" {
+		t.Errorf("Expected the backup to end the warning, got %q", stale)
+	}
+}
+
+func TestMaxStalenessAfterRestart(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file.txt", 10)
+	watcher.CreateBackup()
+	watcher.Metadata[0].Timestamp = float64(clock.Now().Add(-2*time.Hour).UnixNano()) / 1e9
+
+	// The watcher was just started but its newest backup is already too old.
+	watcher.startedAt = clock.Now()
+	watcher.checkStaleness(time.Hour)
+	if stale := watcher.Status().Stale; stale != "No backup for 2h, more than the 1h limit" {
+		t.Errorf("Expected the age of the newest backup to count, got %q", stale)
+	}
+}
+
+func TestFormatStaleness(t *testing.T) {
+	t.Parallel()
+	tests := map[time.Duration]string{
+		30 * time.Second:             "1m",
+		45 * time.Minute:             "45m",
+		24 * time.Hour:               "24h",
+		26*time.Hour + 5*time.Minute: "26h5m",
+	}
+	for duration, expected := range tests {
+		if got := formatStaleness(duration); got != expected {
+			t.Errorf("Expected %s to be written as %q, got %q", duration, expected, got)
+		}
+	}
+}
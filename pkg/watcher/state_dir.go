@@ -0,0 +1,90 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Hidden folder in each destination that holds the metadata and the other files of the
+// watcher, so they can not collide with the name of a backup folder.
+const stateDirName = ".i-saw-that"
+
+const metadataFileName = "metadata.json"
+
+// Files kept in the state folder, earlier versions wrote the metadata, journal, history,
+// sequence and stats directly into the destination.
+var stateFileNames = []string{metadataFileName, metadataLockFileName, journalFileName, historyFileName, oldHistoryFileName, sequenceFileName, statsFileName, scanCacheFileName}
+
+func stateDir(destination string) string {
+	return filepath.Join(destination, stateDirName)
+}
+
+func statePath(destination, name string) string {
+	return filepath.Join(stateDir(destination), name)
+}
+
+// isStatePath returns true for the state folder of a destination and anything in it.
+func isStatePath(path string) bool {
+	return filepath.Base(path) == stateDirName || filepath.Base(filepath.Dir(path)) == stateDirName
+}
+
+// writeStateFile writes a file in the state folder, creating the folder if needed. The
+// data is written to a temporary file that is renamed into place so the file is never
+// left half written.
+func writeStateFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// writeFileAtomic writes data to a temporary file that is renamed to path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tempPath := path + ".tmp"
+	// Removed first since writing an existing file keeps its permissions.
+	if err := os.Remove(tempPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.WriteFile(tempPath, data, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// migrateStateFiles moves the files that earlier versions wrote directly into the
+// destination into the state folder. Anything with the name of a state file that is
+// not a regular file, such as a backup folder that happened to get the same name, is
+// left where it is.
+func migrateStateFiles(destination string) error {
+	var errs error
+	for _, name := range stateFileNames {
+		oldPath := filepath.Join(destination, name)
+		info, err := os.Lstat(oldPath)
+		if errors.Is(err, os.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+			continue
+		}
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error migrating %s: %w", oldPath, err))
+			continue
+		}
+		newPath := statePath(destination, name)
+		if _, err := os.Lstat(newPath); err == nil {
+			Logf("", LogLevelWarn, "%s was already migrated to %s, the old file is left in place", oldPath, newPath)
+			continue
+		}
+		if err := os.MkdirAll(stateDir(destination), 0755); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error migrating %s: %w", oldPath, err))
+			continue
+		}
+		if err := os.Rename(oldPath, newPath); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error migrating %s: %w", oldPath, err))
+		}
+	}
+	return errs
+}
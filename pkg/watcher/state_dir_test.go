@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateStateFiles(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	watcher.CreateBackup()
+
+	// Move the files to where earlier versions kept them.
+	for _, name := range []string{metadataFileName, historyFileName, sequenceFileName} {
+		if err := os.Rename(statePath(WatcherConfig.Destination, name), filepath.Join(WatcherConfig.Destination, name)); err != nil {
+			t.Fatalf("Failed to move %s: %v", name, err)
+		}
+	}
+	// A folder with the name of a state file is not touched.
+	if err := os.Mkdir(filepath.Join(WatcherConfig.Destination, statsFileName), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	watcher, err = NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if backups := watcher.ListBackups(); len(backups) != 1 {
+		t.Errorf("Expected the migrated metadata to be loaded, got %+v", backups)
+	}
+	for _, name := range []string{metadataFileName, historyFileName, sequenceFileName} {
+		if _, err := os.Stat(statePath(WatcherConfig.Destination, name)); err != nil {
+			t.Errorf("Expected %s to be migrated: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(WatcherConfig.Destination, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be moved, got %v", name, err)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(WatcherConfig.Destination, statsFileName)); err != nil || !info.IsDir() {
+		t.Errorf("Expected the folder to be left in place: %v", err)
+	}
+}
+
+func TestMetadataIsFolder(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	if err := os.MkdirAll(metadataJSONPath(WatcherConfig.Destination), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	_, err := NewTempWatcher(WatcherConfig)
+	if err == nil || !strings.Contains(err.Error(), "is not a file") {
+		t.Errorf("Expected an error for a metadata folder, got %v", err)
+	}
+}
+
+func TestFolderFormatInStateDir(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.FolderFormat = stateDirName + "/2006-01-02_15-04-05.000000"
+	CheckForWatcherErrorV2(t, WatcherConfig, &ErrorInvalidFolderFormat, "is used for the metadata")
+}
+
+func TestFolderFormatInTrash(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.FolderFormat = trashDirName + "/2006-01-02_15-04-05.000000"
+	CheckForWatcherErrorV2(t, WatcherConfig, &ErrorInvalidFolderFormat, "is used for the trash")
+}
@@ -0,0 +1,272 @@
+package watcher
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Name of the file in the primary destination that the statistics are saved to.
+const statsFileName = "stats.json"
+
+// WatcherStats counts what a watcher has done since the counters were last reset.
+type WatcherStats struct {
+	// When the counters were last reset.
+	Since time.Time `json:"since"`
+	// File changes noticed in the source, several changes are usually grouped into a
+	// single backup.
+	Changes   int `json:"changes"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+	Pruned    int `json:"pruned"`
+	Restored  int `json:"restored"`
+	// Completed backups by what triggered them.
+	Triggers map[BackupTrigger]int `json:"triggers,omitempty"`
+	// Total time spent making the completed backups.
+	BackupTime  Duration  `json:"backup_time"`
+	LastBackup  time.Time `json:"last_backup,omitzero"`
+	LastFailure time.Time `json:"last_failure,omitzero"`
+}
+
+func newWatcherStats() WatcherStats {
+	return WatcherStats{Since: time.Now(), Triggers: map[BackupTrigger]int{}}
+}
+
+// count adds an event to the counters. history is used to find when a completed
+// backup started.
+func (s *WatcherStats) count(event HistoryEvent, history []HistoryEvent) {
+	switch event.Type {
+	case HistoryBackupCompleted:
+		s.Completed++
+		s.Triggers[event.Trigger]++
+		s.LastBackup = event.Time
+		for i := len(history) - 1; i >= 0; i-- {
+			if history[i].Type == HistoryBackupStarted && history[i].Backup == event.Backup {
+				s.BackupTime += Duration(event.Time.Sub(history[i].Time))
+				break
+			}
+		}
+	case HistoryBackupFailed:
+		s.Failed++
+		s.LastFailure = event.Time
+	case HistoryBackupSkipped:
+		s.Skipped++
+	case HistoryBackupPruned:
+		s.Pruned++
+	case HistoryBackupRestored:
+		s.Restored++
+	}
+}
+
+func statsPath(destination string) string {
+	return statePath(destination, statsFileName)
+}
+
+func loadStats(destination string) (WatcherStats, error) {
+	stats := newWatcherStats()
+	data, err := os.ReadFile(statsPath(destination))
+	if errors.Is(err, os.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, fmt.Errorf("error reading statistics: %w", err)
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return newWatcherStats(), fmt.Errorf("error parsing statistics: %w", err)
+	}
+	if stats.Triggers == nil {
+		stats.Triggers = map[BackupTrigger]int{}
+	}
+	return stats, nil
+}
+
+func saveStats(destination string, stats WatcherStats) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling statistics: %w", err)
+	}
+	path := statsPath(destination)
+	if err := writeStateFile(path, data); err != nil {
+		return fmt.Errorf("error writing statistics: %w", err)
+	}
+	return nil
+}
+
+// copy returns stats with its own copy of the trigger counts.
+func (s WatcherStats) copy() WatcherStats {
+	s.Triggers = maps.Clone(s.Triggers)
+	return s
+}
+
+// Stats returns the counters since they were last reset.
+func (w *Watcher) Stats() WatcherStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats.copy()
+}
+
+// ResetStats sets every counter back to zero, the backups and history are kept.
+func (w *Watcher) ResetStats() error {
+	w.historyMu.Lock()
+	defer w.historyMu.Unlock()
+
+	w.mu.Lock()
+	w.stats = newWatcherStats()
+	stats := w.stats.copy()
+	destination := w.Destination
+	w.mu.Unlock()
+
+	Logf(w.Name, LogLevelInfo, "Statistics reset")
+	return saveStats(destination, stats)
+}
+
+// countChange counts a file change in the source, the counters are saved with the next
+// history event.
+func (w *Watcher) countChange() {
+	w.mu.Lock()
+	w.stats.Changes++
+	w.mu.Unlock()
+}
+
+// WatcherReport is everything known about a watcher, for analyzing its backups with
+// other programs.
+type WatcherReport struct {
+	Name        string         `json:"name"`
+	Source      string         `json:"source"`
+	Destination string         `json:"destination"`
+	Generated   time.Time      `json:"generated"`
+	Status      WatcherStatus  `json:"status"`
+	Stats       WatcherStats   `json:"stats"`
+	Backups     []Backup       `json:"backups"`
+	History     []HistoryEvent `json:"history"`
+}
+
+// Report returns the statistics, backups and history of the watcher.
+func (w *Watcher) Report() WatcherReport {
+	w.mu.Lock()
+	name, source, destination := w.Name, w.Source, w.Destination
+	w.mu.Unlock()
+	return WatcherReport{
+		Name:        name,
+		Source:      source,
+		Destination: destination,
+		Generated:   w.clock.Now(),
+		Status:      w.Status(),
+		Stats:       w.Stats(),
+		Backups:     w.ListBackups(),
+		History:     w.History(),
+	}
+}
+
+type ReportFormat string
+
+const (
+	ReportFormatJSON ReportFormat = "json"
+	ReportFormatCSV  ReportFormat = "csv"
+)
+
+// parseReportFormat converts a format name into a ReportFormat. An empty format is
+// detected from the extension of path.
+func parseReportFormat(format string, path string) (ReportFormat, error) {
+	if format == "" {
+		format = filepath.Ext(path)
+		if format == "" {
+			return "", fmt.Errorf("could not detect report format of %s", path)
+		}
+	}
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "json":
+		return ReportFormatJSON, nil
+	case "csv":
+		return ReportFormatCSV, nil
+	}
+	return "", fmt.Errorf("unsupported report format: %s", format)
+}
+
+// ExportReport writes the report of the watcher to targetPath as JSON or CSV, an empty
+// format is detected from the extension of targetPath.
+func (w *Watcher) ExportReport(targetPath string, format ReportFormat) error {
+	format, err := parseReportFormat(string(format), targetPath)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(targetPath)
+	if err != nil {
+		return fmt.Errorf("error creating report: %w", err)
+	}
+	report := w.Report()
+	if format == ReportFormatCSV {
+		err = writeReportCSV(file, report)
+	} else {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	}
+	if err = errors.Join(err, file.Close()); err != nil {
+		os.Remove(targetPath)
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	Logf(w.Name, LogLevelInfo, "Exported report to %s", targetPath)
+	return nil
+}
+
+// writeReportCSV writes the statistics as metric and value rows, followed by an empty
+// row and a table of the backups. The history is only included in JSON reports.
+func writeReportCSV(file *os.File, report WatcherReport) error {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	stats := report.Stats
+	rows := [][]string{
+		{"metric", "value"},
+		{"name", report.Name},
+		{"source", report.Source},
+		{"destination", report.Destination},
+		{"generated", formatTime(report.Generated)},
+		{"since", formatTime(stats.Since)},
+		{"changes", strconv.Itoa(stats.Changes)},
+		{"completed", strconv.Itoa(stats.Completed)},
+		{"failed", strconv.Itoa(stats.Failed)},
+		{"skipped", strconv.Itoa(stats.Skipped)},
+		{"pruned", strconv.Itoa(stats.Pruned)},
+		{"restored", strconv.Itoa(stats.Restored)},
+		{"backup_time_seconds", strconv.FormatFloat(time.Duration(stats.BackupTime).Seconds(), 'f', -1, 64)},
+		{"last_backup", formatTime(stats.LastBackup)},
+		{"last_failure", formatTime(stats.LastFailure)},
+	}
+	for _, trigger := range slices.Sorted(maps.Keys(stats.Triggers)) {
+		rows = append(rows, []string{"trigger:" + string(trigger), strconv.Itoa(stats.Triggers[trigger])})
+	}
+
+	rows = append(rows, []string{}, []string{"sequence", "path", "time", "destination", "fuzzy", "restic_snapshot", "snapshot"})
+	for _, backup := range report.Backups {
+		rows = append(rows, []string{
+			strconv.FormatInt(backup.Sequence, 10),
+			backup.Path,
+			formatTime(BackupTime(backup)),
+			backup.Destination,
+			strconv.FormatBool(backup.Fuzzy),
+			backup.ResticSnapshot,
+			string(backup.Snapshot),
+		})
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
@@ -0,0 +1,118 @@
+package watcher
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStatsAreCountedAndReset(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	watcher.createTriggeredBackup(BackupTriggerManual)
+	// Changes are saved along with the next event.
+	watcher.countChange()
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 10)
+	watcher.createTriggeredBackup(BackupTriggerChange)
+
+	stats := watcher.Stats()
+	if stats.Completed != 2 || stats.Triggers[BackupTriggerManual] != 1 || stats.Triggers[BackupTriggerChange] != 1 {
+		t.Errorf("Expected 2 completed backups, one of each trigger, got %+v", stats)
+	}
+	if stats.LastBackup.IsZero() {
+		t.Errorf("Expected the time of the last backup to be set")
+	}
+
+	// The counters are saved with the history.
+	reloaded, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if got := reloaded.Stats(); got.Completed != 2 || got.Changes != 1 {
+		t.Errorf("Expected the counters to be loaded, got %+v", got)
+	}
+
+	if err := watcher.ResetStats(); err != nil {
+		t.Fatalf("Failed to reset statistics: %v", err)
+	}
+	if got := watcher.Stats(); got.Completed != 0 || got.Changes != 0 || !got.Since.After(stats.Since) {
+		t.Errorf("Expected the counters to be reset, got %+v", got)
+	}
+	if len(watcher.History()) == 0 || len(watcher.ListBackups()) != 2 {
+		t.Errorf("Expected resetting the counters to keep the history and backups")
+	}
+	reloaded, err = NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if got := reloaded.Stats(); got.Completed != 0 {
+		t.Errorf("Expected the reset counters to be saved, got %+v", got)
+	}
+}
+
+func TestExportReport(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+	backup, _ := watcher.createTriggeredBackup(BackupTriggerManual)
+
+	jsonPath := filepath.Join(WatcherConfig.TempPath, "report.json")
+	if err := watcher.ExportReport(jsonPath, ""); err != nil {
+		t.Fatalf("Failed to export JSON report: %v", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	var report WatcherReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Failed to parse report: %v", err)
+	}
+	if report.Name != WatcherConfig.Name || report.Stats.Completed != 1 || len(report.Backups) != 1 || len(report.History) != 2 {
+		t.Errorf("Expected the report to contain the stats, backup and history, got %+v", report)
+	}
+
+	csvPath := filepath.Join(WatcherConfig.TempPath, "report.txt")
+	if err := watcher.ExportReport(csvPath, ReportFormatCSV); err != nil {
+		t.Fatalf("Failed to export CSV report: %v", err)
+	}
+	file, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatalf("Failed to open report: %v", err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV report: %v", err)
+	}
+	for _, expected := range [][]string{{"completed", "1"}, {"trigger:manual", "1"}} {
+		if !slices.ContainsFunc(rows, func(row []string) bool { return slices.Equal(row, expected) }) {
+			t.Errorf("Expected the row %v in the CSV report", expected)
+		}
+	}
+	if last := rows[len(rows)-1]; last[0] != "1" || last[1] != backup.Path {
+		t.Errorf("Expected the last row to be the backup, got %v", last)
+	}
+
+	if err := watcher.ExportReport(filepath.Join(WatcherConfig.TempPath, "report"), ""); err == nil {
+		t.Errorf("Expected an error for a path without an extension")
+	}
+	if err := watcher.ExportReport(jsonPath, "xml"); err == nil {
+		t.Errorf("Expected an error for an unsupported format")
+	}
+}
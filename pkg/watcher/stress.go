@@ -0,0 +1,391 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Extra time a round waits for its changes to be backed up on top of the wait time.
+const stressSettleTimeout = 30 * time.Second
+
+// Most files the stress test keeps in the source, with more files it only removes them.
+const stressMaxFiles = 200
+
+// Folders the stress test creates files in, the empty name is the source itself.
+var stressFolders = []string{"", "docs", "docs/drafts", "images", "saves/slot 1"}
+
+// ErrNoFileEvents is returned by RunStress when the source can not be watched, so
+// changes would never be backed up.
+var ErrNoFileEvents = errors.New("file events are not available")
+
+// StressConfig configures RunStress.
+type StressConfig struct {
+	// Settings of the watcher being tested. The source and destination are replaced
+	// with temporary folders and settings that postpone or delete backups on purpose
+	// are turned off.
+	Watcher WatcherConfig
+	// Folder the temporary source and destination are created in, such as the drive
+	// backups are made to. The system temporary folder is used if it is empty.
+	Dir string
+	// How long to keep changing the source.
+	Duration time.Duration
+	// Seed of the random changes, the same seed makes the same changes.
+	Seed int64
+	// Called after every round, nil if progress is not reported.
+	Progress func(StressResult)
+}
+
+// StressViolation is a broken promise of the watcher found by the stress test.
+type StressViolation struct {
+	// Round the violation was found in, 0 if it was found in the final checks.
+	Round int `json:"round"`
+	// One of captured, complete or metadata.
+	Invariant string `json:"invariant"`
+	Message   string `json:"message"`
+}
+
+// StressResult is the outcome of a stress test.
+type StressResult struct {
+	Seed       int64             `json:"seed"`
+	Rounds     int               `json:"rounds"`
+	Changes    int               `json:"changes"`
+	Backups    int               `json:"backups"`
+	Duration   Duration          `json:"duration"`
+	Violations []StressViolation `json:"violations"`
+}
+
+// Passed returns true if no invariant was broken.
+func (r StressResult) Passed() bool {
+	return len(r.Violations) == 0
+}
+
+// RunStress makes random changes to a temporary source in rounds until the duration
+// has passed or ctx is done, while a watcher backs it up. After every round it checks
+// that the settled source was backed up. At the end it checks that every backup matches
+// a settled state of the source and verifies, and that the metadata matches the
+// backups in the destination. Broken invariants are reported in the result, an error
+// is only returned if the test could not run.
+func RunStress(ctx context.Context, config StressConfig) (StressResult, error) {
+	result := StressResult{Seed: config.Seed, Violations: []StressViolation{}}
+	root, err := os.MkdirTemp(config.Dir, ".i-saw-that-stress-*")
+	if err != nil {
+		return result, fmt.Errorf("error creating stress test folder: %w", err)
+	}
+	defer os.RemoveAll(root)
+
+	settings := config.Watcher
+	settings.Source = filepath.Join(root, "source")
+	settings.Destination = filepath.Join(root, "destination")
+	settings.RotationDestinations = nil
+	settings.Retention = RetentionPolicy{}
+	settings.QuietHours = nil
+	settings.Defer = DeferPolicy{}
+	settings.OpenFiles = OpenFilesConfig{}
+	settings.ProcessTrigger = ProcessTriggerConfig{}
+	settings.IdleTrigger = IdleTriggerConfig{}
+	settings.Restic = ResticConfig{}
+	// Filesystem snapshots need the source to be a subvolume or dataset.
+	if settings.Snapshot != SnapshotModeReflink {
+		settings.Snapshot = ""
+	}
+	if settings.ID == "" {
+		settings.ID = "stress"
+	}
+
+	churn := &stressChurn{source: settings.Source, rand: newStressRand(config.Seed)}
+	if err := os.MkdirAll(settings.Source, 0755); err != nil {
+		return result, fmt.Errorf("error creating stress test source: %w", err)
+	}
+	if err := os.MkdirAll(settings.Destination, 0755); err != nil {
+		return result, fmt.Errorf("error creating stress test destination: %w", err)
+	}
+	if err := churn.burst(20); err != nil {
+		return result, err
+	}
+
+	w, err := NewWatcher(settings)
+	if err != nil {
+		return result, err
+	}
+	algorithm := w.hashAlgorithm()
+	settled := map[string]bool{}
+	state, err := churn.state(algorithm)
+	if err != nil {
+		return result, err
+	}
+	settled[state] = true
+
+	if err := w.StartWatcher(); err != nil {
+		return result, err
+	}
+	if status := w.Status(); status.Error != "" && !status.Polling {
+		w.Shutdown()
+		return result, fmt.Errorf("%w: %s", ErrNoFileEvents, status.Error)
+	}
+
+	start := time.Now()
+	timeout := time.Duration(settings.WaitTime) + stressSettleTimeout
+	if w.Status().Polling {
+		timeout += w.pollInterval()
+	}
+	for round := 1; ; round++ {
+		// The initial backup is the first round, it captures the files created before
+		// the watcher started.
+		if round > 1 {
+			changes := 1 + churn.rand.Intn(20)
+			if err := churn.burst(changes); err != nil {
+				w.Shutdown()
+				return result, err
+			}
+			result.Changes += changes
+			if state, err = churn.state(algorithm); err != nil {
+				w.Shutdown()
+				return result, err
+			}
+			settled[state] = true
+		}
+		result.Rounds = round
+
+		if !waitForBackupOf(ctx, w, state, timeout) {
+			if ctx.Err() != nil {
+				break
+			}
+			result.Violations = append(result.Violations, StressViolation{
+				Round:     round,
+				Invariant: "captured",
+				Message:   fmt.Sprintf("The source was not backed up within %s of settling", timeout),
+			})
+		}
+		result.Backups = len(w.ListBackups())
+		result.Duration = Duration(time.Since(start))
+		if config.Progress != nil {
+			config.Progress(result)
+		}
+		if ctx.Err() != nil || time.Since(start) >= config.Duration {
+			break
+		}
+	}
+
+	if _, err := w.Shutdown(); err != nil {
+		result.Violations = append(result.Violations, StressViolation{Invariant: "metadata", Message: err.Error()})
+	}
+	result.Backups = len(w.ListBackups())
+	result.Violations = append(result.Violations, checkStressBackups(w, settled)...)
+	result.Violations = append(result.Violations, checkStressMetadata(w)...)
+	result.Duration = Duration(time.Since(start))
+	return result, nil
+}
+
+// waitForBackupOf waits until the newest backup of w has the manifest hash state.
+func waitForBackupOf(ctx context.Context, w *Watcher, state string, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		backups := w.ListBackups()
+		if len(backups) > 0 {
+			sidecar, err := ReadBackupSidecar(w.BackupPath(backups[len(backups)-1]))
+			if err == nil && sidecar.ManifestHash == state {
+				return true
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-deadline:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkStressBackups checks that every backup verifies and has the contents the source
+// had once it settled, a backup of anything else caught the source half way through a
+// round.
+func checkStressBackups(w *Watcher, settled map[string]bool) []StressViolation {
+	violations := []StressViolation{}
+	for _, backup := range w.ListBackups() {
+		if result := w.VerifyBackup(backup); result.Status != VerifyStatusOK {
+			violations = append(violations, StressViolation{
+				Invariant: "complete",
+				Message:   fmt.Sprintf("Backup %s did not verify: %s %s", backup.Path, result.Status, result.Error),
+			})
+			continue
+		}
+		sidecar, err := ReadBackupSidecar(w.BackupPath(backup))
+		if err != nil {
+			violations = append(violations, StressViolation{Invariant: "complete", Message: err.Error()})
+			continue
+		}
+		if !settled[sidecar.ManifestHash] {
+			violations = append(violations, StressViolation{
+				Invariant: "complete",
+				Message:   fmt.Sprintf("Backup %s does not match any settled state of the source", backup.Path),
+			})
+		}
+	}
+	return violations
+}
+
+// checkStressMetadata checks that the saved metadata lists exactly the backups in the
+// destination and that no backup was left unfinished.
+func checkStressMetadata(w *Watcher) []StressViolation {
+	violations := []StressViolation{}
+	add := func(format string, args ...any) {
+		violations = append(violations, StressViolation{Invariant: "metadata", Message: fmt.Sprintf(format, args...)})
+	}
+
+	onDisk, err := loadDestinationMetadata(w.Destination)
+	if err != nil {
+		add("%v", err)
+		return violations
+	}
+	if !sameBackups(onDisk, w.ListBackups()) {
+		add("The saved metadata lists %d backups, the watcher has %d", len(onDisk), len(w.ListBackups()))
+	}
+	folders := map[string]bool{}
+	for _, backup := range onDisk {
+		if _, err := os.Stat(w.BackupPath(backup)); err != nil {
+			add("Backup %s is in the metadata but not in the destination", backup.Path)
+		}
+		folders[strings.SplitN(backup.Path, "/", 2)[0]] = true
+	}
+	entries, err := os.ReadDir(w.Destination)
+	if err != nil {
+		add("%v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && !folders[entry.Name()] && !strings.HasPrefix(entry.Name(), ".") {
+			add("Folder %s in the destination is not in the metadata", entry.Name())
+		}
+	}
+	if journal, err := readJournal(w.Destination); err != nil {
+		add("%v", err)
+	} else if len(journal) > 0 {
+		add("%d backups were left unfinished", len(journal))
+	}
+	return violations
+}
+
+// stressChurn makes random changes to a source.
+type stressChurn struct {
+	source string
+	rand   *rand.Rand
+	// Counter for the names of new files so they never repeat.
+	created int
+}
+
+func newStressRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// burst makes changes to the source as fast as possible, so they settle together.
+func (c *stressChurn) burst(changes int) error {
+	for range changes {
+		files, err := c.files()
+		if err != nil {
+			return err
+		}
+		if err := c.change(files); err != nil {
+			return fmt.Errorf("error changing stress test source: %w", err)
+		}
+	}
+	return nil
+}
+
+// change makes a single random change to one of files.
+func (c *stressChurn) change(files []string) error {
+	if len(files) == 0 {
+		return c.create()
+	}
+	file := files[c.rand.Intn(len(files))]
+	action := c.rand.Intn(10)
+	if len(files) >= stressMaxFiles {
+		action = 7
+	}
+	switch action {
+	case 0, 1, 2:
+		return c.create()
+	case 3, 4:
+		return os.WriteFile(file, c.content(), 0644)
+	case 5:
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(c.content())
+		return errors.Join(err, f.Close())
+	case 6:
+		path := c.newPath()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return os.Rename(file, path)
+	case 7, 8:
+		return os.Remove(file)
+	default:
+		// Removing a folder removes many files at once.
+		folder := stressFolders[1+c.rand.Intn(len(stressFolders)-1)]
+		return os.RemoveAll(filepath.Join(c.source, filepath.FromSlash(folder)))
+	}
+}
+
+func (c *stressChurn) create() error {
+	path := c.newPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, c.content(), 0644)
+}
+
+// newPath returns a path in a random folder that no file has had before.
+func (c *stressChurn) newPath() string {
+	c.created++
+	folder := stressFolders[c.rand.Intn(len(stressFolders))]
+	return filepath.Join(c.source, filepath.FromSlash(folder), fmt.Sprintf("file %d.txt", c.created))
+}
+
+// content returns up to 64 KiB of random bytes, some files are empty.
+func (c *stressChurn) content() []byte {
+	data := make([]byte, c.rand.Intn(64*1024))
+	c.rand.Read(data)
+	return data
+}
+
+// files returns the files in the source in a stable order so the same seed picks the
+// same files.
+func (c *stressChurn) files() ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(c.source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading stress test source: %w", err)
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// state returns the manifest hash of the source, which is the manifest hash a backup
+// of it has.
+func (c *stressChurn) state(algorithm HashAlgorithm) (string, error) {
+	manifest, err := buildManifest(c.source, algorithm, nil)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Hash(), nil
+}
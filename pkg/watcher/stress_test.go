@@ -0,0 +1,93 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStressChurnIsReproducible(t *testing.T) {
+	t.Parallel()
+	states := []string{}
+	for range 2 {
+		source := t.TempDir()
+		churn := &stressChurn{source: source, rand: newStressRand(42)}
+		if err := churn.burst(100); err != nil {
+			t.Fatalf("Failed to change source: %v", err)
+		}
+		state, err := churn.state(HashXXHash)
+		if err != nil {
+			t.Fatalf("Failed to hash source: %v", err)
+		}
+		states = append(states, state)
+	}
+	if states[0] != states[1] {
+		t.Errorf("Expected the same seed to make the same changes")
+	}
+}
+
+func TestStressChecksFindBrokenBackups(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	churn := &stressChurn{source: WatcherConfig.Source, rand: newStressRand(1)}
+	if err := churn.burst(5); err != nil {
+		t.Fatalf("Failed to change source: %v", err)
+	}
+	watcher.CreateBackup()
+	state, err := churn.state(watcher.hashAlgorithm())
+	if err != nil {
+		t.Fatalf("Failed to hash source: %v", err)
+	}
+	if violations := append(checkStressBackups(watcher, map[string]bool{state: true}), checkStressMetadata(watcher)...); len(violations) != 0 {
+		t.Fatalf("Expected no violations, got %+v", violations)
+	}
+
+	// A backup of a state that was never settled and a backup missing from the
+	// destination are both found.
+	CreateDummyFile(t, WatcherConfig.Source, "half way.txt", 10)
+	watcher.CreateBackup()
+	if violations := checkStressBackups(watcher, map[string]bool{state: true}); len(violations) != 1 || violations[0].Invariant != "complete" {
+		t.Errorf("Expected the unsettled backup to be found, got %+v", violations)
+	}
+	if err := os.RemoveAll(watcher.BackupPath(watcher.ListBackups()[0])); err != nil {
+		t.Fatalf("Failed to remove backup: %v", err)
+	}
+	violations := checkStressMetadata(watcher)
+	if len(violations) != 1 || !strings.Contains(violations[0].Message, "not in the destination") {
+		t.Errorf("Expected the missing backup to be found, got %+v", violations)
+	}
+}
+
+func TestRunStress(t *testing.T) {
+	t.Parallel()
+	config := StressConfig{
+		Watcher: WatcherConfig{
+			WaitTime:     Duration(200 * time.Millisecond),
+			FolderFormat: DefaultFolderFormat,
+		},
+		Dir:      t.TempDir(),
+		Duration: 2 * time.Second,
+		Seed:     7,
+	}
+	result, err := RunStress(context.Background(), config)
+	if errors.Is(err, ErrNoFileEvents) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("Failed to run stress test: %v", err)
+	}
+	if !result.Passed() || result.Rounds < 2 || result.Backups < 2 {
+		t.Errorf("Expected the stress test to pass with several backups, got %+v", result)
+	}
+	if entries, _ := os.ReadDir(config.Dir); len(entries) != 0 {
+		t.Errorf("Expected the stress test folder to be removed, found %s", filepath.Join(config.Dir, entries[0].Name()))
+	}
+}
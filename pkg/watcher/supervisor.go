@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// Delay before a goroutine that stopped unexpectedly is started again, it doubles
+	// with every failure in a row up to maxRestartDelay.
+	defaultRestartDelay = time.Second
+	maxRestartDelay     = 5 * time.Minute
+	// A goroutine that ran at least this long before failing is started again after
+	// the initial delay.
+	restartResetAfter = 10 * time.Minute
+	// Longer than anything holds the lock of a watcher that is working, such as
+	// checking the latest backup while starting.
+	defaultCrashLockWait = time.Minute
+)
+
+// supervise runs task until stop is closed. A task that returns or panics before then
+// stopped unexpectedly, which is recorded in the status and warned about, and it is
+// started again after a delay so a dead goroutine does not silently disable backups. A
+// panic also writes a crash dump. A panic that left the lock of the watcher held can
+// not be recovered from, it is logged as an error and the task is not started again.
+func (w *Watcher) supervise(name string, stop chan struct{}, task func() error) {
+	delay := w.restartDelay
+	for {
+		started := w.clock.Now()
+		err := runRecovered(task)
+		if err == nil {
+			err = errors.New("returned without an error")
+		}
+		if w.clock.Now().Sub(started) >= restartResetAfter {
+			delay = w.restartDelay
+		}
+
+		message := fmt.Sprintf("The %s stopped unexpectedly: %v", name, err)
+		var crash *panicError
+		dump := ""
+		if errors.As(err, &crash) {
+			message = fmt.Sprintf("The %s crashed: %v", name, crash.value)
+			dump = w.writeCrashDump(name, crash)
+			if !tryLock(&w.mu, w.crashLockWait) {
+				// Waiting for the lock would hang the supervisor along with everything
+				// else that needs it, and warnings need it to reach the observers.
+				Logf(w.Name, LogLevelError, "%s while holding the lock of the watcher, it can not continue until the app is restarted", message)
+				return
+			}
+		} else {
+			w.mu.Lock()
+		}
+		select {
+		case <-stop:
+			w.mu.Unlock()
+			return
+		default:
+		}
+		w.status.Restarts++
+		w.status.Error = message
+		if crash != nil {
+			w.status.Crashed = message
+			w.status.CrashDump = dump
+		}
+		w.mu.Unlock()
+		w.notifyWarning("%s, restarting it in %v", message, delay)
+
+		timer := w.clock.NewTimer(delay)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		delay = min(delay*2, maxRestartDelay)
+		Logf(w.Name, LogLevelInfo, "Restarting the %s", name)
+		w.mu.Lock()
+		if w.status.Error == message {
+			w.status.Error = ""
+		}
+		w.mu.Unlock()
+	}
+}
@@ -0,0 +1,137 @@
+package watcher
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSuperviseRestartsTasks(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.restartDelay = time.Second
+	recorder := &warningRecorder{}
+	watcher.AddObserver(recorder)
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	runs := 0
+	running := make(chan struct{})
+	go func() {
+		defer close(done)
+		watcher.supervise("test task", stop, func() error {
+			runs++
+			switch runs {
+			case 1:
+				panic("broken")
+			case 2:
+				return errors.New("channel closed")
+			}
+			close(running)
+			<-stop
+			return nil
+		})
+	}()
+
+	// The delay doubles after the second failure in a row.
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	clock.BlockUntil(1)
+	clock.Advance(2 * time.Second)
+	select {
+	case <-running:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the task to be restarted")
+	}
+	if restarts := watcher.Status().Restarts; restarts != 2 {
+		t.Errorf("Expected 2 restarts, got %d", restarts)
+	}
+	// The error is cleared once the task is running again.
+	if status := watcher.Status(); status.Error != "" {
+		t.Errorf("Expected no error after the restart, got %q", status.Error)
+	}
+	watcher.flushObservers()
+	recorder.mu.Lock()
+	if len(recorder.warnings) != 2 {
+		t.Errorf("Expected a warning for each failure, got %v", recorder.warnings)
+	}
+	recorder.mu.Unlock()
+
+	// A task that returns because the watcher stopped is not restarted.
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected supervising to end when the watcher stops")
+	}
+	if runs != 3 {
+		t.Errorf("Expected 3 runs, got %d", runs)
+	}
+}
+
+func TestSuperviseStopsWhenPanicHoldsLock(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.restartDelay = time.Millisecond
+	watcher.crashLockWait = 10 * time.Millisecond
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	defer close(stop)
+	runs := 0
+	go func() {
+		defer close(done)
+		watcher.supervise("test task", stop, func() error {
+			runs++
+			watcher.mu.Lock()
+			panic("broken while locked")
+		})
+	}()
+
+	// The supervisor gives up instead of waiting for the lock forever.
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected supervising to end when the panic left the lock held")
+	}
+	if runs != 1 {
+		t.Errorf("Expected the task not to be restarted, got %d runs", runs)
+	}
+	watcher.mu.Unlock()
+}
+
+func TestFileWatcherIsRestarted(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.restartDelay = time.Millisecond
+	if err := watcher.StartWatcher(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	t.Cleanup(func() { watcher.Shutdown() })
+
+	// fsnotify closes its channels when it is closed.
+	watcher.mu.Lock()
+	closed := watcher.fsnotifyWatcher
+	watcher.mu.Unlock()
+	closed.Close()
+
+	waitUntil(t, "Expected a new file watcher to be created", func() bool {
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+		return watcher.fsnotifyWatcher != nil && watcher.fsnotifyWatcher != closed
+	})
+	if restarts := watcher.Status().Restarts; restarts != 1 {
+		t.Errorf("Expected 1 restart, got %d", restarts)
+	}
+}
@@ -0,0 +1,83 @@
+package watcher
+
+import (
+	"fmt"
+	"time"
+)
+
+// Most buckets a single timeline request can return.
+const maxTimelineBuckets = 24 * 366
+
+// TimelineGranularity is the length of a bucket in a backup timeline.
+type TimelineGranularity string
+
+const (
+	TimelineHour TimelineGranularity = "hour"
+	TimelineDay  TimelineGranularity = "day"
+)
+
+// TimelineBucket counts the backups made during one hour or day.
+type TimelineBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+	// Total size of the backups in bytes.
+	Size int64 `json:"size"`
+}
+
+// bucketStart returns the start of the local hour or day that t is in.
+func (g TimelineGranularity) bucketStart(t time.Time) time.Time {
+	// Truncate works on absolute time, which is not the local hour in time zones with
+	// an offset that is not whole hours.
+	t = t.In(time.Local)
+	if g == TimelineHour {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func (g TimelineGranularity) next(start time.Time) time.Time {
+	if g == TimelineHour {
+		return start.Add(time.Hour)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Timeline groups the backups made between from and to into hour or day buckets. Every
+// bucket in the range is returned, including the empty ones, ordered from oldest to
+// newest.
+func (w *Watcher) Timeline(from, to time.Time, granularity TimelineGranularity) ([]TimelineBucket, error) {
+	if granularity != TimelineHour && granularity != TimelineDay {
+		return nil, fmt.Errorf("unknown timeline granularity: %s", granularity)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("timeline ends before it starts")
+	}
+
+	buckets := []TimelineBucket{}
+	index := map[time.Time]int{}
+	for start := granularity.bucketStart(from); !start.After(to); start = granularity.next(start) {
+		if len(buckets) == maxTimelineBuckets {
+			return nil, fmt.Errorf("timeline has more than %d buckets, use a shorter range or a larger granularity", maxTimelineBuckets)
+		}
+		index[start] = len(buckets)
+		buckets = append(buckets, TimelineBucket{Start: start})
+	}
+
+	for _, backup := range w.ListBackups() {
+		created := BackupTime(backup)
+		if created.Before(from) || created.After(to) {
+			continue
+		}
+		i, ok := index[granularity.bucketStart(created)]
+		if !ok {
+			continue
+		}
+		buckets[i].Count++
+		// A backup that can not be measured, such as one on a missing drive, is still
+		// counted.
+		if size, err := DirSize(w.BackupPath(backup)); err == nil {
+			buckets[i].Size += size
+		}
+	}
+	return buckets, nil
+}
@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	now := start.Add(72 * time.Hour)
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(NewFakeClock(now)))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 1024)
+	watcher.CreateBackup()
+	existing := watcher.Metadata[0]
+
+	// The other backups do not exist on disk so they have no size.
+	watcher.Metadata = append(backupsAt(start, start.Add(10*time.Minute), start.Add(2*time.Hour), start.Add(48*time.Hour)), existing)
+
+	hours, err := watcher.Timeline(start, start.Add(3*time.Hour-time.Second), TimelineHour)
+	if err != nil {
+		t.Fatalf("Failed to build timeline: %v", err)
+	}
+	counts := []int{}
+	for _, bucket := range hours {
+		counts = append(counts, bucket.Count)
+	}
+	if len(hours) != 3 || counts[0] != 2 || counts[1] != 0 || counts[2] != 1 {
+		t.Errorf("Unexpected hourly counts: %v", counts)
+	}
+
+	days, err := watcher.Timeline(start.AddDate(0, 0, -1), now, TimelineDay)
+	if err != nil {
+		t.Fatalf("Failed to build timeline: %v", err)
+	}
+	if days[0].Count != 0 || days[1].Count != 3 || days[2].Count != 0 || days[3].Count != 1 {
+		t.Errorf("Unexpected daily buckets: %+v", days[:4])
+	}
+	last := days[len(days)-1]
+	if last.Count != 1 || last.Size < 1024 {
+		t.Errorf("Expected the last day to have the existing backup, got %+v", last)
+	}
+
+	if _, err := watcher.Timeline(start, start.AddDate(10, 0, 0), TimelineHour); err == nil {
+		t.Errorf("Expected an error for too many buckets")
+	}
+	if _, err := watcher.Timeline(start, start, "week"); err == nil {
+		t.Errorf("Expected an error for an unknown granularity")
+	}
+}
@@ -0,0 +1,43 @@
+package watcher
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// Windows and some minimal Linux installs have no time zone database.
+	_ "time/tzdata"
+)
+
+// TimeZone is the zone backup folder names are formatted in, "UTC" or a name from the
+// IANA database such as "Europe/Berlin". Local time is used if it is empty, which can
+// produce folder names that sort out of order or repeat when the clocks change.
+type TimeZone string
+
+func (z TimeZone) location() (*time.Location, error) {
+	switch {
+	case z == "" || strings.EqualFold(string(z), "local"):
+		return time.Local, nil
+	case strings.EqualFold(string(z), "utc"):
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(string(z))
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %s: %w", z, err)
+	}
+	return location, nil
+}
+
+func (z TimeZone) validate() error {
+	_, err := z.location()
+	return err
+}
+
+// backupTimeZone returns the zone recorded in the metadata of a backup whose folder
+// name was formatted at t. Local time is recorded as its offset from UTC since the
+// name of the local zone is usually not known.
+func backupTimeZone(t time.Time) string {
+	if t.Location() == time.Local {
+		return t.Format("-07:00")
+	}
+	return t.Location().String()
+}
@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeZoneBackupFolders(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	// Minutes are enough to tell the zones apart without the rounding of the timestamp
+	// in the metadata getting in the way.
+	watcher.FolderFormat = "{seq}_2006-01-02_15-04"
+	watcher.TimeZone = "Asia/Tokyo"
+	CreateDummyFile(t, WatcherConfig.Source, "file1.txt", 10)
+
+	backup, ok := watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatalf("Failed to create backup")
+	}
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("Failed to load time zone: %v", err)
+	}
+	expected := formatBackupFolder(watcher.FolderFormat, BackupTime(backup).In(tokyo), backup.Sequence)
+	if backup.Path != expected || backup.TimeZone != "Asia/Tokyo" {
+		t.Errorf("Expected backup %s in Asia/Tokyo, got %s in %s", expected, backup.Path, backup.TimeZone)
+	}
+
+	watcher.TimeZone = "utc"
+	backup, ok = watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatalf("Failed to create backup")
+	}
+	expected = formatBackupFolder(watcher.FolderFormat, BackupTime(backup).UTC(), backup.Sequence)
+	if backup.Path != expected || backup.TimeZone != "UTC" {
+		t.Errorf("Expected backup %s in UTC, got %s in %s", expected, backup.Path, backup.TimeZone)
+	}
+
+	watcher.TimeZone = ""
+	backup, ok = watcher.createTriggeredBackup(BackupTriggerManual)
+	if !ok {
+		t.Fatalf("Failed to create backup")
+	}
+	if offset := BackupTime(backup).Format("-07:00"); backup.TimeZone != offset {
+		t.Errorf("Expected local time to be recorded as %s, got %s", offset, backup.TimeZone)
+	}
+}
+
+func TestTimeZoneValidation(t *testing.T) {
+	t.Parallel()
+	temp := DefaultTempWatcherConfig(t)
+	config := &WatcherConfig{
+		ID:           temp.Name,
+		Source:       temp.Source,
+		Destination:  temp.Destination,
+		WaitTime:     Duration(temp.WaitTime),
+		FolderFormat: temp.FolderFormat,
+		TimeZone:     "Mars/Olympus_Mons",
+	}
+	if _, err := NewWatcher(*config); err == nil {
+		t.Errorf("Expected an error for an unknown time zone")
+	}
+	config.TimeZone = "Europe/Berlin"
+	watcher, err := NewWatcher(*config)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if watcher.TimeZone != "Europe/Berlin" {
+		t.Errorf("Expected the time zone to be set, got %s", watcher.TimeZone)
+	}
+}
@@ -0,0 +1,102 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Hidden folder in each destination that pruned backups are moved to when the retention
+// policy keeps them in the trash.
+const trashDirName = ".trash"
+
+// Name of the folder in the trash that holds the backups pruned at the same time, in
+// UTC so purging is not affected by changes to the time zone.
+const trashTimeLayout = "2006-01-02_15-04-05.000000"
+
+// How often the backup thread deletes backups that have been in the trash long enough,
+// so the trash is emptied even when nothing is pruned.
+const trashPurgeInterval = time.Hour
+
+func trashDir(destination string) string {
+	return filepath.Join(destination, trashDirName)
+}
+
+// isTrashPath returns true for the trash of a destination and anything in it.
+func (w *Watcher) isTrashPath(path string) bool {
+	for _, destination := range w.destinations() {
+		if isPathWithin(trashDir(destination), path) {
+			return true
+		}
+	}
+	return false
+}
+
+// trashBackup moves a backup folder into the trash of its destination, where it stays
+// until purgeTrash deletes it. Restic and filesystem snapshots can not be moved so they
+// are deleted right away.
+func (w *Watcher) trashBackup(backup Backup) error {
+	if backup.ResticSnapshot != "" || backup.Snapshot == SnapshotModeZFS || backup.Snapshot == SnapshotModeBtrfs {
+		return w.deleteBackup(backup)
+	}
+
+	path := w.BackupPath(backup)
+	now := w.clock.Now()
+	w.mu.Lock()
+	w.recentBackups[path] = now
+	w.mu.Unlock()
+
+	target := filepath.Join(trashDir(backup.Destination), now.UTC().Format(trashTimeLayout), filepath.FromSlash(backup.Path))
+	// The backup is made writable so it can be moved and later deleted.
+	if err := unprotectBackup(path); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := w.fs.Rename(path, target); err != nil {
+		return err
+	}
+	w.removeEmptyParents(backup.Destination, path)
+	return nil
+}
+
+// purgeTrash deletes the backups that have been in the trash of a destination for
+// longer than grace.
+func (w *Watcher) purgeTrash(destination string, grace time.Duration) error {
+	entries, err := os.ReadDir(trashDir(destination))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, entry := range entries {
+		// Anything the watcher did not put in the trash is left alone.
+		trashed, err := time.Parse(trashTimeLayout, entry.Name())
+		if err != nil || !entry.IsDir() || w.clock.Now().Sub(trashed) < grace {
+			continue
+		}
+		path := filepath.Join(trashDir(destination), entry.Name())
+		if err := w.fs.RemoveAll(path); err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		Logf(w.Name, LogLevelInfo, "Deleted the backups pruned at %s from the trash", trashed.Local().Format(time.DateTime))
+	}
+	// Fails while the trash still holds backups.
+	_ = w.fs.Remove(trashDir(destination))
+	return errs
+}
+
+// purgeTrashes runs purgeTrash on every destination.
+func (w *Watcher) purgeTrashes(grace time.Duration) {
+	for _, destination := range w.destinations() {
+		if err := w.purgeTrash(destination, grace); err != nil {
+			Logf(w.Name, LogLevelError, "Error emptying trash: %v", err)
+		}
+	}
+}
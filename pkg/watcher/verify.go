@@ -0,0 +1,143 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// VerifyStatus is the result of checking a backup against its sidecar.
+type VerifyStatus string
+
+const (
+	// The contents of the backup match the manifest hash in the sidecar.
+	VerifyStatusOK VerifyStatus = "ok"
+	// Files in the backup were added, removed or changed after it was made.
+	VerifyStatusModified VerifyStatus = "modified"
+	// The backup folder does not exist.
+	VerifyStatusMissing VerifyStatus = "missing"
+	// The backup has no sidecar with a manifest hash to check against, such as backups
+	// made by older versions.
+	VerifyStatusUnverifiable VerifyStatus = "unverifiable"
+	// A restore drill could not restore the backup.
+	VerifyStatusRestoreFailed VerifyStatus = "restore_failed"
+	// SignBackups is on but the backup has no signature.
+	VerifyStatusUnsigned VerifyStatus = "unsigned"
+	// The signature does not match the sidecar or was made by a key that is not
+	// trusted.
+	VerifyStatusBadSignature VerifyStatus = "bad_signature"
+)
+
+type BackupVerification struct {
+	Backup Backup       `json:"backup"`
+	Status VerifyStatus `json:"status"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// failed returns true if the backup is known to be damaged.
+func (v BackupVerification) Failed() bool {
+	switch v.Status {
+	case VerifyStatusModified, VerifyStatusMissing, VerifyStatusRestoreFailed, VerifyStatusUnsigned, VerifyStatusBadSignature:
+		return true
+	}
+	return false
+}
+
+// VerifyBackup hashes the contents of a backup and compares it with the manifest hash
+// that was recorded in its sidecar when the backup was made. With a keyring the
+// signature of the sidecar is checked too, it is required if SignBackups is on.
+func (w *Watcher) VerifyBackup(backup Backup) BackupVerification {
+	result := BackupVerification{Backup: backup}
+	if backup.ResticSnapshot != "" {
+		result.Status = VerifyStatusUnverifiable
+		result.Error = "stored in a restic repository, use restic check to verify it"
+		return result
+	}
+
+	path := w.BackupPath(backup)
+	if _, err := os.Stat(path); err != nil {
+		result.Status = VerifyStatusMissing
+		if !errors.Is(err, os.ErrNotExist) {
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	sidecar, err := ReadBackupSidecar(path)
+	if err != nil || sidecar.ManifestHash == "" {
+		result.Status = VerifyStatusUnverifiable
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	generated := sidecar.generatedFiles()
+	manifest, err := buildManifest(path, sidecar.HashAlgorithm, func(relPath string) bool {
+		return slices.Contains(generated, relPath)
+	})
+	if err != nil {
+		result.Status = VerifyStatusModified
+		result.Error = err.Error()
+		return result
+	}
+
+	if manifest.Hash() != sidecar.ManifestHash {
+		result.Status = VerifyStatusModified
+		return result
+	}
+
+	w.mu.Lock()
+	required := w.SignBackups
+	keyring := w.keyring
+	w.mu.Unlock()
+	result.Status = VerifyStatusOK
+	switch {
+	case keyring == nil || (!required && sidecar.Signature == ""):
+	case sidecar.Signature == "":
+		result.Status = VerifyStatusUnsigned
+	case sidecar.HashAlgorithm == HashXXHash:
+		result.Status = VerifyStatusBadSignature
+		result.Error = fmt.Sprintf("signed over an %s manifest, which is not collision resistant", HashXXHash)
+	case sidecar.Watcher != w.Name || sidecar.Timestamp != backup.Timestamp:
+		// A signed sidecar copied from another backup is still validly signed.
+		result.Status = VerifyStatusBadSignature
+		result.Error = fmt.Sprintf("signed for the backup of %s at %v, not this one", sidecar.Watcher, sidecar.Time)
+	default:
+		if err := keyring.verify(sidecar.signedMessage(), sidecar.Signature, sidecar.SigningKey); err != nil {
+			result.Status = VerifyStatusBadSignature
+			result.Error = err.Error()
+		}
+	}
+	return result
+}
+
+// checkSignedBackup returns ErrUntrustedBackup unless the backup is signed by a trusted
+// key and unchanged, only if SignBackups is on. Without a keyring the signature can not
+// be checked so every backup is refused.
+func (w *Watcher) checkSignedBackup(backup Backup) error {
+	w.mu.Lock()
+	required := w.SignBackups
+	keyring := w.keyring
+	w.mu.Unlock()
+	if !required || backup.ResticSnapshot != "" {
+		return nil
+	}
+	if keyring == nil {
+		return fmt.Errorf("%w: there are no keys to check its signature with", ErrUntrustedBackup)
+	}
+	// A missing backup is reported by the restore itself.
+	result := w.VerifyBackup(backup)
+	switch result.Status {
+	case VerifyStatusOK, VerifyStatusMissing:
+		return nil
+	case VerifyStatusModified:
+		return fmt.Errorf("%w: its files were changed after it was made", ErrUntrustedBackup)
+	case VerifyStatusUnsigned:
+		return fmt.Errorf("%w: it has no signature", ErrUntrustedBackup)
+	case VerifyStatusUnverifiable:
+		return fmt.Errorf("%w: it has no sidecar", ErrUntrustedBackup)
+	}
+	return fmt.Errorf("%w: %s", ErrUntrustedBackup, result.Error)
+}
@@ -0,0 +1,42 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "folder/file1.txt", 1024)
+	watcher.CreateBackup()
+	backup := watcher.Metadata[0]
+	if result := watcher.VerifyBackup(backup); result.Status != VerifyStatusOK {
+		t.Fatalf("Expected a new backup to verify, got %s: %s", result.Status, result.Error)
+	}
+
+	CreateDummyFile(t, watcher.BackupPath(backup), "folder/file1.txt", 10)
+	if result := watcher.VerifyBackup(backup); result.Status != VerifyStatusModified {
+		t.Errorf("Expected a changed file to be detected, got %s", result.Status)
+	}
+
+	if err := os.Remove(filepath.Join(watcher.BackupPath(backup), backupSidecarName)); err != nil {
+		t.Fatalf("Failed to remove sidecar: %v", err)
+	}
+	if result := watcher.VerifyBackup(backup); result.Status != VerifyStatusUnverifiable {
+		t.Errorf("Expected a backup without a sidecar to be unverifiable, got %s", result.Status)
+	}
+
+	if err := os.RemoveAll(watcher.BackupPath(backup)); err != nil {
+		t.Fatalf("Failed to remove backup: %v", err)
+	}
+	if result := watcher.VerifyBackup(backup); result.Status != VerifyStatusMissing {
+		t.Errorf("Expected a deleted backup to be missing, got %s", result.Status)
+	}
+}
@@ -0,0 +1,5 @@
+package watcher
+
+// Version is recorded in the sidecar file of every backup, programs that embed the
+// engine can set it to their own version.
+var Version = "0.1.0"
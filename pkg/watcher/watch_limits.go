@@ -0,0 +1,141 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const defaultPollInterval = 10 * time.Second
+
+// WatchLimitError is returned when the OS refuses to create more file watches. On Linux
+// this happens when the inotify limits set by sysctl are reached.
+type WatchLimitError struct {
+	// Name of the sysctl setting that was exceeded.
+	Setting string
+	// Current value of the setting, 0 if it could not be read.
+	Current int
+	// Value that should be enough for the watcher to work.
+	Suggested int
+	Err       error
+}
+
+func (e *WatchLimitError) Error() string {
+	return fmt.Sprintf("file watch limit reached (%s): %v", e.Setting, e.Err)
+}
+
+func (e *WatchLimitError) Unwrap() error {
+	return e.Err
+}
+
+// Hint returns the command needed to raise the limit.
+func (e *WatchLimitError) Hint() string {
+	return fmt.Sprintf("Raise the limit with: sudo sysctl %s=%d", e.Setting, e.Suggested)
+}
+
+// classifyWatchError converts errors caused by the inotify limits into a
+// WatchLimitError. Any other error is returned unchanged.
+func classifyWatchError(err error) error {
+	if err == nil || runtime.GOOS != "linux" {
+		return err
+	}
+
+	var setting string
+	var minimum int
+	switch {
+	// inotify_add_watch returns ENOSPC when max_user_watches is reached.
+	case errors.Is(err, syscall.ENOSPC):
+		setting = "fs.inotify.max_user_watches"
+		minimum = 524288
+	// inotify_init returns EMFILE when max_user_instances is reached.
+	case errors.Is(err, syscall.EMFILE):
+		setting = "fs.inotify.max_user_instances"
+		minimum = 1024
+	default:
+		return err
+	}
+
+	current := readSysctl(setting)
+	return &WatchLimitError{
+		Setting:   setting,
+		Current:   current,
+		Suggested: max(current*2, minimum),
+		Err:       err,
+	}
+}
+
+func readSysctl(setting string) int {
+	path := filepath.Join("/proc/sys", strings.ReplaceAll(setting, ".", "/"))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func (w *Watcher) pollInterval() time.Duration {
+	if w.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return w.PollInterval
+}
+
+// pollLoop periodically scans the source and requests a backup when anything changed.
+// It is only used when file events are not available.
+func (w *Watcher) pollLoop(stop chan struct{}) {
+	ticker := time.NewTicker(w.pollInterval())
+	defer ticker.Stop()
+
+	previous, err := sourceSignature(w.Source)
+	if err != nil {
+		Logf(w.Name, LogLevelError, "Error polling source: %v", err)
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			current, err := sourceSignature(w.Source)
+			if err != nil {
+				Logf(w.Name, LogLevelError, "Error polling source: %v", err)
+				continue
+			}
+			if current != previous {
+				Logf(w.Name, LogLevelDebug, "Change detected while polling")
+				w.requestBackup(BackupTriggerPoll)
+			}
+			previous = current
+		}
+	}
+}
+
+// sourceSignature returns a hash of the path, size and modification time of every
+// entry in the directory. Any change to the directory changes the signature.
+func sourceSignature(dir string) (uint64, error) {
+	hash := fnv.New64a()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s|%d|%d|%v\n", path, info.Size(), info.ModTime().UnixNano(), d.IsDir())
+		return nil
+	})
+	return hash.Sum64(), err
+}
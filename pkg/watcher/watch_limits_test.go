@@ -0,0 +1,63 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassifyWatchLimitError(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS != "linux" {
+		t.Skip("Skipping Linux-specific test")
+	}
+
+	err := classifyWatchError(fmt.Errorf("inotify_add_watch: %w", syscall.ENOSPC))
+	var limitErr *WatchLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Expected a WatchLimitError, got %v", err)
+	}
+	if limitErr.Setting != "fs.inotify.max_user_watches" {
+		t.Errorf("Expected max_user_watches, got %s", limitErr.Setting)
+	}
+	if !strings.Contains(limitErr.Hint(), "sysctl fs.inotify.max_user_watches=") {
+		t.Errorf("Expected a sysctl hint, got %s", limitErr.Hint())
+	}
+
+	other := errors.New("something else")
+	if classifyWatchError(other) != other {
+		t.Errorf("Expected other errors to be returned unchanged")
+	}
+}
+
+func TestPollingFallback(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS != "linux" {
+		t.Skip("Skipping Linux-specific test")
+	}
+	WatcherConfig, watcher, observer := getWatcherWithObserver(t)
+	watcher.PollOnWatchLimit = true
+	watcher.PollInterval = 200 * time.Millisecond
+
+	// Simulate fsnotify running out of watches.
+	watcher.mu.Lock()
+	watcher.handleWatchError(classifyWatchError(syscall.ENOSPC), watcher.stopChan)
+	watcher.mu.Unlock()
+
+	status := watcher.Status()
+	if !status.Polling || status.Hint == "" {
+		t.Fatalf("Expected the watcher to be polling with a hint, got %+v", status)
+	}
+
+	// Make sure the file is not created in the same poll as the initial scan.
+	time.Sleep(300 * time.Millisecond)
+	CreateDummyFile(t, WatcherConfig.Source, "file.txt", 1024)
+	if !observer.WaitUntilCount(1, 10*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion")
+	}
+	CompareSourceAndDestination(t, WatcherConfig.Source, watcher.BackupPath(watcher.Metadata[1]))
+}
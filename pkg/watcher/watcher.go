@@ -0,0 +1,1917 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"slices"
+
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Interface used for tests and potential GUI in the future
+type BackupCompleteObserver interface {
+	OnBackupCompletion(watcher *Watcher)
+}
+
+// WatcherStatus describes the state of a watcher for the GUI.
+type WatcherStatus struct {
+	Running bool `json:"running"`
+	// True if the source is being polled because file events are not available.
+	Polling bool   `json:"polling"`
+	Error   string `json:"error,omitempty"`
+	// Suggested fix for the error.
+	Hint string `json:"hint,omitempty"`
+	// Reason changes are currently not being backed up, such as quiet hours.
+	Deferred string `json:"deferred,omitempty"`
+	// Warning shown while no backup has been made for longer than MaxStaleness.
+	Stale string `json:"stale,omitempty"`
+	// Mass change that paused retention, until ResumeRetention is called.
+	MassChange string `json:"mass_change,omitempty"`
+	// Results of the last health check of the destinations and the remote.
+	Health []BackendHealth `json:"health,omitempty"`
+	// Times the file watcher or the backup thread stopped unexpectedly and was
+	// restarted since the watcher started.
+	Restarts int `json:"restarts,omitempty"`
+	// Set when a thread of the watcher crashed since it started, with the path of the
+	// crash dump if one was written.
+	Crashed   string `json:"crashed,omitempty"`
+	CrashDump string `json:"crash_dump,omitempty"`
+}
+
+type Backup struct {
+	// Number that increases with every backup of the watcher and is never reused.
+	Sequence   int64   `json:"sequence,omitempty"`
+	Name       string  `json:"name,omitempty"`
+	Timestamp  float64 `json:"timestamp"`
+	Path       string  `json:"path"`
+	Compressed bool    `json:"compressed,omitempty"`
+	// Folder format the backup was named with.
+	FolderFormat string `json:"folder_format,omitempty"`
+	// Destination the backup is stored in, this is set when the metadata is loaded so
+	// it is always the current location of the destination.
+	Destination string `json:"destination,omitempty"`
+	// ID of the snapshot if the backup is stored in a restic repository, Path is
+	// still used to identify the backup.
+	ResticSnapshot string `json:"restic_snapshot,omitempty"`
+	// Hash of the source when a restic backup was made, used to check if the source
+	// changed since.
+	SourceHash string `json:"source_hash,omitempty"`
+	// Algorithm SourceHash was made with, empty for SHA-256.
+	HashAlgorithm HashAlgorithm `json:"hash_algorithm,omitempty"`
+	// Filesystem snapshot the backup was made with, if any.
+	Snapshot SnapshotMode `json:"snapshot,omitempty"`
+	// Full name of a ZFS snapshot, such as pool/data@2006-01-02_15-04-05.000000.
+	SnapshotName string `json:"snapshot_name,omitempty"`
+	// True if files changed while they were copied, so the backup may mix old and new
+	// versions of the source.
+	Fuzzy bool `json:"fuzzy,omitempty"`
+	// Files that changed during the copy, relative to the source.
+	FuzzyPaths []string `json:"fuzzy_paths,omitempty"`
+	// What the destination could not keep of the source, such as symlinks on exFAT.
+	Degraded []Attribute `json:"degraded,omitempty"`
+	// Files stored as chunks in the destination, relative to the backup folder with
+	// forward slashes.
+	Chunked []string `json:"chunked,omitempty"`
+	// Zone the folder name was formatted in, such as "UTC", or the offset of local time
+	// such as "+02:00".
+	TimeZone string `json:"time_zone,omitempty"`
+	// Pinned backups are never deleted by pruning.
+	Pinned bool `json:"pinned,omitempty"`
+	// Free text added with AnnotateBackup.
+	Note string `json:"note,omitempty"`
+	// File events that led to the backup, only recorded with AuditLog.
+	Events []FileEvent `json:"events,omitempty"`
+	// Events that were not kept because there were more than maxAuditEvents.
+	DroppedEvents int `json:"dropped_events,omitempty"`
+	// Files that compressed well in the previous backup and now look like random data,
+	// only set when Entropy flagged the backup.
+	HighEntropy []string `json:"high_entropy,omitempty"`
+	// True once a copy of the backup has been uploaded to the remote of the watcher.
+	RemoteCopy bool `json:"remote_copy,omitempty"`
+}
+
+// BackupTime returns the time a backup was made.
+func BackupTime(backup Backup) time.Time {
+	return time.Unix(0, int64(backup.Timestamp*1e9))
+}
+
+type Watcher struct {
+	Name         string        `json:"name"`
+	Source       string        `json:"source"`
+	Destination  string        `json:"destination"`
+	WaitTime     time.Duration `json:"wait_time"`
+	FolderFormat string        `json:"folder_format"`
+	// Additional destinations that backups alternate between along with Destination.
+	RotationDestinations []string `json:"rotation_destinations,omitempty"`
+	// Backups from every destination ordered from oldest to newest.
+	Metadata []Backup `json:"metadata"`
+	// Backups of each destination as its metadata file held when it was last read or
+	// written, what changed since then is what this process changed.
+	savedMetadata map[string][]Backup
+	// Engine used to copy the source into a backup folder.
+	CopyEngine CopyEngine `json:"-"`
+	// Poll the source for changes if the OS limits on file watches are reached.
+	PollOnWatchLimit bool `json:"poll_on_watch_limit,omitempty"`
+	// Time between polls when polling the source.
+	PollInterval time.Duration `json:"poll_interval,omitempty"`
+	// Watch the destinations for changes made outside of the watcher.
+	WatchDestination bool `json:"watch_destination,omitempty"`
+	// Make backups read-only once they are complete.
+	ReadOnlyBackups bool `json:"read_only_backups,omitempty"`
+	// Never write inside the source, restores are refused and the self test does not
+	// write a probe file.
+	ReadOnlySource bool `json:"read_only_source,omitempty"`
+	// Algorithm files are hashed with for manifests, xxhash if empty.
+	HashAlgorithm HashAlgorithm `json:"hash_algorithm,omitempty"`
+	// Save the hashes of the source files to the primary destination so they survive a
+	// restart.
+	PersistScanCache bool `json:"persist_scan_cache,omitempty"`
+	// Windows file metadata copied into backups in addition to the file contents.
+	PreserveNTFS NTFSPreservation `json:"preserve_ntfs,omitzero"`
+	// Backups that are not kept by the policy are deleted after each backup.
+	Retention RetentionPolicy `json:"retention,omitzero"`
+	// Size and policy of the queue that notifications are passed to observers through,
+	// this can not be changed after the first notification.
+	ObserverQueue ObserverQueueConfig `json:"observer_queue,omitzero"`
+	// How changes are grouped into backups, takes effect when the watcher starts.
+	Debounce DebounceConfig `json:"debounce,omitzero"`
+	// Windows during which automatic backups are deferred.
+	QuietHours []QuietHours `json:"quiet_hours,omitempty"`
+	// Conditions such as running on battery that automatic backups are deferred for.
+	Defer DeferPolicy `json:"defer,omitzero"`
+	// Restic repository that backups are stored in instead of the destination.
+	Restic ResticConfig `json:"restic,omitzero"`
+	// Create btrfs or ZFS snapshots instead of copying the source.
+	Snapshot SnapshotMode `json:"snapshot,omitempty"`
+	// How cloud-only placeholder files are backed up, the copy engine must be created
+	// with the same policy.
+	Placeholders PlaceholderPolicy `json:"placeholders,omitempty"`
+	// Write a SHA256SUMS file inside each backup.
+	Checksums ChecksumConfig `json:"checksums,omitzero"`
+	// What happens to files in the source that can not be read, the copy engine must
+	// be created with the same policy.
+	Permissions PermissionPolicy `json:"permissions,omitempty"`
+	// What automatic backups do when files in the source are open in other programs.
+	OpenFiles OpenFilesConfig `json:"open_files,omitzero"`
+	// Back up right away when a program exits.
+	ProcessTrigger ProcessTriggerConfig `json:"process_trigger,omitzero"`
+	// Hold automatic backups until there has been no input for a while.
+	IdleTrigger IdleTriggerConfig `json:"idle_trigger,omitzero"`
+	// Wait for changed files to stop growing before automatic backups.
+	Stability StabilityConfig `json:"stability,omitzero"`
+	// Only back up the files matching these patterns, the copy engine must be created
+	// with the same patterns.
+	Include IncludePatterns `json:"include,omitempty"`
+	// Only treat writes as changes once the file is closed, Linux only.
+	CloseWrite bool `json:"close_write,omitempty"`
+	// Zone backup folder names are formatted in, local time if empty.
+	TimeZone TimeZone `json:"time_zone,omitempty"`
+	// Which waiting backups go first when backups are limited by a BackupLimiter.
+	Priority Priority `json:"priority,omitempty"`
+	// Warns about and makes room in destinations that run low on free space.
+	FreeSpace FreeSpaceConfig `json:"free_space,omitempty"`
+	// Longest time the watcher can run without making a backup before it warns, 0 never
+	// warns.
+	MaxStaleness Duration `json:"max_staleness,omitempty"`
+	// Periodically restores a recent backup into a temporary folder and checks it.
+	RestoreDrill RestoreDrillConfig `json:"restore_drill,omitempty"`
+	// Do not back up the source before restoring or pruning.
+	SkipSafetyBackups bool `json:"skip_safety_backups,omitempty"`
+	// Follow the source to its new path when it is renamed or moved while the watcher
+	// is running.
+	FollowSource bool `json:"follow_source,omitempty"`
+	// Keep the file events that led to each backup in its metadata.
+	AuditLog bool `json:"audit_log,omitempty"`
+	// Detects a large number of files changing at once, as ransomware does.
+	MassChange MassChangeConfig `json:"mass_change,omitzero"`
+	// Files in the source that should never change.
+	Canaries Canaries `json:"canaries,omitempty"`
+	// Sign the sidecar of every backup with the key of the keyring, and refuse to
+	// restore backups that are not signed by a trusted key.
+	SignBackups bool `json:"sign_backups,omitempty"`
+	// Folder on a fast local disk that backups are made in before they are moved to
+	// the destination, empty to make them in the destination.
+	Staging string `json:"staging,omitempty"`
+	// Flags backups where many files turned into random-looking data.
+	Entropy EntropyConfig `json:"entropy,omitzero"`
+	// Storage off the machine that a copy of every backup is uploaded to.
+	Remote RemoteConfig `json:"remote,omitzero"`
+	// How often the destinations and the remote are probed, every hour if 0 and never if
+	// negative.
+	HealthCheck Duration `json:"health_check,omitempty"`
+
+	mu sync.Mutex
+	// Serializes changes to the journals, separate from mu since it is held during
+	// file operations.
+	journalMu sync.Mutex
+	// Serializes saving the history so saves are not written out of order.
+	historyMu sync.Mutex
+	// Serializes reserving sequence numbers.
+	sequenceMu sync.Mutex
+	// Serializes changes to the metadata on the remote.
+	remoteMu sync.Mutex
+	// True if the source is a single file instead of a directory.
+	singleFile      bool
+	running         bool
+	status          WatcherStatus
+	fsnotifyWatcher *fsnotify.Watcher
+	customObservers []BackupCompleteObserver
+	// Hooks registered by library users to change how backups are made.
+	hooks             watcherHooks
+	observerQueue     *observerQueue
+	stopChan          chan struct{}
+	backupRequestChan chan BackupTrigger
+	// Receives a value when the program of the process trigger exits.
+	processExitChan chan struct{}
+	// Closed when the backup thread exits, after any backup in progress has finished.
+	backupLoopDone chan struct{}
+	// Backups made for other operations, such as safety backups, that wait for the
+	// result.
+	backupCallChan chan backupCall
+	// True if the watcher was stopped while changes were waiting to be backed up.
+	unsavedChanges bool
+
+	reconcileRequestChan chan struct{}
+	// Backup folder currently being written.
+	activeBackupPath string
+	// Completion times of recent backups, used to ignore their delayed file events.
+	recentBackups map[string]time.Time
+	// Backups that were changed by something other than the watcher.
+	externallyModified map[string]bool
+	// Paths from file events since the last backup, only recorded for the stability
+	// check.
+	changedPaths map[string]bool
+	// Hashes of the files in the source from the last time it was hashed.
+	scanCache *scanCache
+	// Manifest in the sidecar of the last backup this watcher made.
+	lastManifest recordedManifest
+	// Destinations of other watchers inside the source, their events are ignored so
+	// the watchers do not trigger each other.
+	excludedPaths []string
+	// Events ordered from oldest to newest, saved to the primary destination.
+	history []HistoryEvent
+	// Primary destination whose history file holds the events in history, empty if the
+	// file has to be rewritten before events are appended. historyFileEvents is the
+	// number of events in the file. Guarded by historyMu.
+	historyFile       string
+	historyFileEvents int
+	// Counters since they were last reset, saved to the primary destination along with
+	// the history.
+	stats WatcherStats
+	// Reads the battery and network state, replaced in tests.
+	powerState func() powerState
+	// How often a backup deferred by Defer checks the conditions again.
+	deferPollInterval time.Duration
+	// Returns how long there has been no input for the idle trigger, replaced in tests.
+	idleTime func() (time.Duration, error)
+	// Returns the programs that have files inside the source open, replaced in tests.
+	openFileProcesses func(root string) ([]string, error)
+	// Lists the running programs for the process trigger, replaced in tests.
+	runningProcesses    func() ([]string, error)
+	processPollInterval time.Duration
+	// Times backups and the debounce, replaced in tests.
+	clock Clock
+	// Reads changed files and removes backups, replaced in tests.
+	fs FS
+	// Shared with other watchers to limit how many backups run at the same time, nil
+	// if backups never wait.
+	limiter BackupLimiter
+	// Returns the bytes free on the volume of a destination, replaced in tests.
+	freeSpace func(path string) (uint64, error)
+	// Destinations that had less free space than FreeSpace asks for when they were last
+	// checked, so the warning is only given once.
+	lowSpace map[string]bool
+	// Attributes each destination that was probed can not keep.
+	unsupportedAttributes map[string][]Attribute
+	// When the watcher was last started, MaxStaleness is counted from here if there is no
+	// newer backup.
+	startedAt time.Time
+	// How often a watcher with FollowSource checks that its source still exists.
+	followSourceInterval time.Duration
+	// File events since the last backup was started, only recorded with AuditLog.
+	auditLog auditLog
+	// Changes within the MassChange window.
+	massChanges massChangeTracker
+	// Message of the mass change that paused retention, empty if it is not paused.
+	massChange string
+	// Receives the trigger of a backup that is made right away, without waiting for
+	// the changes to settle.
+	urgentBackupChan chan BackupTrigger
+	// Canaries that were touched since the watcher started, each is only reported once.
+	touchedCanaries map[string]bool
+	// Signs and checks backups, shared with the other watchers, nil without one.
+	keyring *Keyring
+	// Credentials of the shares the destinations are on, used to connect them again
+	// when they drop.
+	keychain Keychain
+	// Receives a value when there are backups to upload to the remote.
+	remoteRequestChan chan struct{}
+	// How long the remote thread waits before trying a failed upload again.
+	remoteRetryInterval time.Duration
+	// Results of the last health check, destinations first.
+	health []BackendHealth
+	// How long a goroutine that stopped unexpectedly is first waited for before it is
+	// started again.
+	restartDelay time.Duration
+	// How long a goroutine that crashed waits for the lock before deciding the crash
+	// left it held.
+	crashLockWait time.Duration
+	// Folder crash dumps are written to, crashes are only logged if empty.
+	crashDumpDir string
+}
+
+// NewWatcher creates a watcher for a folder pair and applies options to it, the watcher
+// is not started. Values the config leaves empty are not filled in with the defaults of
+// the app, so the wait time and folder format have to be set. Every problem with the
+// config is returned joined together, each wrapping one of the ErrorInvalid errors.
+func NewWatcher(config WatcherConfig, options ...Option) (*Watcher, error) {
+	var errs error
+	name, source, destination := config.ID, config.Source, config.Destination
+	waitTime := time.Duration(config.WaitTime)
+	validateName(name, &errs)
+	validateWaitTime(waitTime, &errs)
+	var formatErrs error
+	validateFolderFormat(waitTime, config.FolderFormat, &formatErrs)
+	errs = errors.Join(errs, formatErrs)
+	validateSourceAndDestination(source, destination, &errs)
+	validateRotationDestinations(source, destination, config.RotationDestinations, &errs)
+	singleFile := isRegularFile(source)
+	copyEngine := validateSettings(&config, singleFile, &errs)
+
+	w := &Watcher{
+		Name:                  name,
+		Source:                source,
+		Destination:           destination,
+		WaitTime:              waitTime,
+		FolderFormat:          config.FolderFormat,
+		RotationDestinations:  config.RotationDestinations,
+		Metadata:              []Backup{},
+		CopyEngine:            copyEngine,
+		PollOnWatchLimit:      config.PollOnWatchLimit,
+		PollInterval:          time.Duration(config.PollInterval),
+		WatchDestination:      config.WatchDestination,
+		ReadOnlyBackups:       config.ReadOnlyBackups,
+		ReadOnlySource:        config.ReadOnlySource,
+		HashAlgorithm:         config.HashAlgorithm,
+		PersistScanCache:      config.PersistScanCache,
+		PreserveNTFS:          config.PreserveNTFS,
+		Retention:             config.Retention,
+		ObserverQueue:         config.ObserverQueue,
+		Debounce:              config.Debounce,
+		QuietHours:            config.QuietHours,
+		Defer:                 config.Defer,
+		Restic:                config.Restic,
+		Snapshot:              config.Snapshot,
+		Placeholders:          config.Placeholders,
+		Checksums:             config.Checksums,
+		Permissions:           config.Permissions,
+		OpenFiles:             config.OpenFiles,
+		ProcessTrigger:        config.ProcessTrigger,
+		IdleTrigger:           config.IdleTrigger,
+		Stability:             config.Stability,
+		Include:               config.Include,
+		CloseWrite:            config.CloseWrite,
+		TimeZone:              config.TimeZone,
+		Priority:              config.Priority,
+		FreeSpace:             config.FreeSpace,
+		MaxStaleness:          config.MaxStaleness,
+		RestoreDrill:          config.RestoreDrill,
+		SkipSafetyBackups:     config.SkipSafetyBackups,
+		FollowSource:          config.FollowSource,
+		AuditLog:              config.AuditLog,
+		MassChange:            config.MassChange,
+		Entropy:               config.Entropy,
+		Canaries:              config.Canaries,
+		SignBackups:           config.SignBackups,
+		Staging:               config.Staging,
+		Remote:                config.Remote,
+		HealthCheck:           config.HealthCheck,
+		singleFile:            singleFile,
+		stopChan:              make(chan struct{}),
+		backupRequestChan:     make(chan BackupTrigger, 1),
+		processExitChan:       make(chan struct{}, 1),
+		urgentBackupChan:      make(chan BackupTrigger, 1),
+		backupCallChan:        make(chan backupCall),
+		touchedCanaries:       map[string]bool{},
+		reconcileRequestChan:  make(chan struct{}, 1),
+		recentBackups:         map[string]time.Time{},
+		externallyModified:    map[string]bool{},
+		changedPaths:          map[string]bool{},
+		scanCache:             newScanCache(),
+		history:               []HistoryEvent{},
+		powerState:            readPowerState,
+		deferPollInterval:     defaultDeferPollInterval,
+		idleTime:              idleTime,
+		openFileProcesses:     openFileProcesses,
+		runningProcesses:      runningProcesses,
+		processPollInterval:   defaultProcessPollInterval,
+		clock:                 realClock{},
+		fs:                    osFS{},
+		freeSpace:             freeSpace,
+		lowSpace:              map[string]bool{},
+		unsupportedAttributes: map[string][]Attribute{},
+		followSourceInterval:  defaultFollowSourceInterval,
+		keychain:              OSKeychain(),
+		remoteRequestChan:     make(chan struct{}, 1),
+		remoteRetryInterval:   defaultRemoteRetryInterval,
+		restartDelay:          defaultRestartDelay,
+		crashLockWait:         defaultCrashLockWait,
+	}
+
+	for _, destination := range w.destinations() {
+		if err := migrateStateFiles(destination); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	// Loading metadata relies on metadataJSONPath so it is easier to load the metadata
+	// after the struct is created.
+	if err := w.loadMetadata(); err != nil {
+		errs = errors.Join(errs, fmt.Errorf("error loading metadata: %w", err))
+	} else {
+		// Backups are not marked with a folder format that failed validation.
+		if formatErrs == nil {
+			errs = errors.Join(errs, w.migrateFolderFormats())
+		}
+		errs = errors.Join(errs, w.migrateSequences())
+	}
+
+	// A damaged history is not worth refusing to start over, it is replaced by the next
+	// event.
+	if history, complete, err := loadHistory(destination); err != nil {
+		Logf(name, LogLevelWarn, "%v", err)
+	} else {
+		w.massChange = unresolvedMassChange(history)
+		w.history = trimHistory(history, w.massChange != "")
+		if complete {
+			w.historyFile, w.historyFileEvents = destination, len(history)
+		}
+	}
+	stats, err := loadStats(destination)
+	if err != nil {
+		Logf(name, LogLevelWarn, "%v", err)
+	}
+	w.stats = stats
+
+	for _, option := range options {
+		option(w)
+	}
+	return w, errs
+}
+
+// destinations returns every destination backups can be stored in, starting with the
+// primary destination.
+func (w *Watcher) destinations() []string {
+	return append([]string{w.Destination}, w.RotationDestinations...)
+}
+
+func metadataJSONPath(destination string) string {
+	return statePath(destination, metadataFileName)
+}
+
+// BackupPath returns the full path of a backup folder.
+func (w *Watcher) BackupPath(backup Backup) string {
+	if backup.Snapshot == SnapshotModeZFS {
+		return zfsSnapshotFolder(w.Source, backup.SnapshotName)
+	}
+	destination := backup.Destination
+	if destination == "" {
+		destination = w.Destination
+	}
+	return filepath.Join(destination, filepath.FromSlash(backup.Path))
+}
+
+// ListBackups returns the backups from every destination ordered from oldest to newest.
+func (w *Watcher) ListBackups() []Backup {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	backups := make([]Backup, len(w.Metadata))
+	copy(backups, w.Metadata)
+	return backups
+}
+
+// Each destination has its own metadata file so a destination is self contained and
+// can be read even if the other destinations are not available.
+func (w *Watcher) loadMetadata() error {
+	metadata := []Backup{}
+	saved := map[string][]Backup{}
+	for _, destination := range w.destinations() {
+		backups, err := loadDestinationMetadata(destination)
+		if err != nil {
+			return err
+		}
+		metadata = append(metadata, backups...)
+		saved[destination] = backups
+	}
+	w.savedMetadata = saved
+
+	sortBackups(metadata)
+
+	w.Metadata = metadata
+	return nil
+}
+
+func loadDestinationMetadata(destination string) ([]Backup, error) {
+	unlock, err := lockMetadata(destination, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return readDestinationMetadata(destination)
+}
+
+// readDestinationMetadata reads the metadata file of a destination, the caller must
+// hold the metadata lock.
+func readDestinationMetadata(destination string) ([]Backup, error) {
+	path := metadataJSONPath(destination)
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("metadata file %s is not a file, move it out of the way so the metadata can be written", path)
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading metadata file: %w", err)
+	}
+
+	metadata, err := decodeMetadata(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing metadata of %s: %w", destination, err)
+	}
+
+	for i := range metadata {
+		metadata[i].Destination = destination
+	}
+	return metadata, nil
+}
+
+// saveMetadata writes the metadata file for a single destination. The file is read
+// again under the same lock so the backups another process added, deleted or changed
+// since this one last read or wrote it are kept, and the metadata of the watcher is
+// updated to match. A file that can not be read is replaced.
+func (w *Watcher) saveMetadata(destination string) error {
+	unlock, err := lockMetadata(destination, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	onDisk, readErr := readDestinationMetadata(destination)
+
+	w.mu.Lock()
+	metadata := []Backup{}
+	for _, backup := range w.Metadata {
+		if backup.Destination == destination {
+			metadata = append(metadata, backup)
+		}
+	}
+	if readErr == nil {
+		metadata = mergeMetadata(w.savedMetadata[destination], metadata, onDisk)
+	}
+	w.mu.Unlock()
+
+	data, err := encodeMetadata(metadata)
+	if err != nil {
+		return fmt.Errorf("error marshaling metadata: %w", err)
+	}
+	path := metadataJSONPath(destination)
+	if err := writeStateFile(path, data); err != nil {
+		return fmt.Errorf("error writing metadata file: %w", err)
+	}
+
+	w.mu.Lock()
+	w.Metadata = slices.DeleteFunc(w.Metadata, func(backup Backup) bool { return backup.Destination == destination })
+	w.Metadata = append(w.Metadata, metadata...)
+	sortBackups(w.Metadata)
+	if w.savedMetadata == nil {
+		w.savedMetadata = map[string][]Backup{}
+	}
+	w.savedMetadata[destination] = metadata
+	w.mu.Unlock()
+	return nil
+}
+
+// mergeMetadata applies the changes this process made to the backups of a destination,
+// from base to ours, to the backups in its metadata file. Backups another process
+// deleted stay deleted, and the ones it added or changed are kept unless this process
+// changed them too.
+func mergeMetadata(base, ours, onDisk []Backup) []Backup {
+	byPath := func(backups []Backup) map[string]Backup {
+		paths := map[string]Backup{}
+		for _, backup := range backups {
+			paths[backup.Path] = backup
+		}
+		return paths
+	}
+	baseByPath, oursByPath, diskByPath := byPath(base), byPath(ours), byPath(onDisk)
+
+	merged := []Backup{}
+	for _, backup := range ours {
+		old, inBase := baseByPath[backup.Path]
+		current, inFile := diskByPath[backup.Path]
+		switch {
+		case inBase && !inFile:
+		case inBase && reflect.DeepEqual(old, backup):
+			merged = append(merged, current)
+		default:
+			merged = append(merged, backup)
+		}
+	}
+	for _, backup := range onDisk {
+		_, inBase := baseByPath[backup.Path]
+		_, inOurs := oursByPath[backup.Path]
+		if !inBase && !inOurs {
+			merged = append(merged, backup)
+		}
+	}
+	sortBackups(merged)
+	return merged
+}
+
+// nextDestination picks the destination for the next backup. Destinations are used in
+// turn, starting after the destination of the latest backup. Destinations that are not
+// currently available, such as an unplugged drive, are skipped.
+func (w *Watcher) nextDestination() (string, error) {
+	w.mu.Lock()
+	destinations := w.destinations()
+	start := 0
+	if len(w.Metadata) > 0 {
+		latest := w.Metadata[len(w.Metadata)-1].Destination
+		for i, destination := range destinations {
+			if destination == latest {
+				start = i + 1
+				break
+			}
+		}
+	}
+	w.mu.Unlock()
+
+	for i := range destinations {
+		destination := destinations[(start+i)%len(destinations)]
+		info, err := reachShare(w.keychain, destination)
+		if err == nil && info.IsDir() {
+			return destination, nil
+		}
+		if err == nil {
+			err = errors.New("not a folder")
+		}
+		Logf(w.Name, LogLevelWarn, "Destination %s is not available, skipping: %v", destination, err)
+	}
+	return "", errors.New("no destinations are available")
+}
+
+func (w *Watcher) StartWatcher() error {
+	Logf(w.Name, LogLevelInfo, "Starting watcher")
+	w.mu.Lock()
+	running := w.running
+	w.mu.Unlock()
+	if running {
+		return errors.New("watcher is already running")
+	}
+
+	// Backups that were interrupted by a crash are removed before anything starts
+	// watching the destinations.
+	recovered, err := w.recoverInterruptedBackups()
+	if err != nil {
+		Logf(w.Name, LogLevelError, "%v", err)
+	}
+	w.removeStaleStaging()
+
+	// The lock is held while the goroutines are started so StopWatcher stops all of
+	// them, but not while the latest backup is checked since that can take a while.
+	w.mu.Lock()
+	if w.running {
+		w.mu.Unlock()
+		return errors.New("watcher is already running")
+	}
+	w.running = true
+	w.status = WatcherStatus{Running: true}
+	w.startedAt = w.clock.Now()
+	clear(w.touchedCanaries)
+	w.warnMissingCanaries()
+
+	// A new stop channel is used every time the watcher is started so the goroutines
+	// from a previous run are not affected by a restart.
+	w.stopChan = make(chan struct{})
+	w.backupLoopDone = make(chan struct{})
+	w.unsavedChanges = false
+	closeWrites := w.startCloseWriteWatcher(w.stopChan)
+	w.startFSNotifyWatcher(w.stopChan, closeWrites)
+	if w.WatchDestination {
+		w.startDestinationWatcher(w.stopChan)
+	}
+	go w.runBackupLoop(w.stopChan, w.backupLoopDone)
+	if w.ProcessTrigger.enabled() {
+		go w.processLoop(w.ProcessTrigger, w.stopChan)
+	}
+	if w.FollowSource {
+		if identity, err := os.Stat(w.Source); err == nil {
+			go w.followSourceLoop(identity, w.stopChan)
+		}
+	}
+	// Backups made while the watcher was stopped or the remote was unreachable are
+	// uploaded right away.
+	if w.Remote.enabled() {
+		go w.remoteLoop(w.Remote, w.stopChan)
+		w.requestRemoteUpload()
+	}
+	if interval := w.healthCheckInterval(); interval > 0 {
+		go w.healthLoop(interval, w.stopChan)
+	}
+
+	Logf(w.Name, LogLevelInfo, "Watcher Started")
+	w.mu.Unlock()
+
+	// The interrupted backup is replaced even if the source matches the latest backup.
+	if recovered {
+		Logf(w.Name, LogLevelInfo, "Creating a backup to replace the interrupted backup")
+		w.requestBackup(BackupTriggerRecovery)
+		return nil
+	}
+
+	// Create an initial backup if no backups are present.
+	err = w.createBackupIfBackupIsOutdated()
+	if err != nil {
+		return fmt.Errorf("error checking if backup is up to date: %w", err)
+	}
+	return nil
+}
+
+// StopWatcher stops watching the source directory
+func (w *Watcher) StopWatcher() error {
+	// Easiest to lock the thread for the whole function since StopWatcher isn't a
+	// function that will be called frequently.
+	Logf(w.Name, LogLevelInfo, "Stopping watcher")
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.running {
+		return nil // Already stopped
+	}
+	w.running = false
+	w.status = WatcherStatus{}
+	close(w.stopChan)
+
+	var err error
+	if w.fsnotifyWatcher != nil {
+		err = w.fsnotifyWatcher.Close()
+		w.fsnotifyWatcher = nil
+	}
+
+	return err
+}
+
+// Shutdown stops the watcher, waits for a backup that is in progress to finish and for
+// observers to be notified, then saves the metadata of every available destination. It
+// returns true if there were changes in the source that had not been backed up yet.
+func (w *Watcher) Shutdown() (bool, error) {
+	w.mu.Lock()
+	done := w.backupLoopDone
+	w.mu.Unlock()
+
+	errs := w.StopWatcher()
+	if done != nil {
+		<-done
+	}
+	w.flushObservers()
+
+	// Metadata is normally saved after every backup, saving it again covers a save that
+	// failed. Destinations without any backups are left untouched.
+	w.mu.Lock()
+	used := map[string]bool{}
+	for _, backup := range w.Metadata {
+		used[backup.Destination] = true
+	}
+	w.mu.Unlock()
+	for _, destination := range w.destinations() {
+		if !used[destination] {
+			continue
+		}
+		if _, err := os.Stat(destination); err != nil {
+			continue
+		}
+		if err := w.saveMetadata(destination); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.unsavedChanges, errs
+}
+
+// setDeferred records why backups are currently deferred, an empty reason clears it.
+// It returns true if the reason changed.
+func (w *Watcher) setDeferred(reason string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := w.status.Deferred != reason
+	w.status.Deferred = reason
+	return changed
+}
+
+// Status returns the current state of the watcher.
+func (w *Watcher) Status() WatcherStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := w.status
+	status.MassChange = w.massChange
+	status.Health = slices.Clone(w.health)
+	return status
+}
+
+// requestBackup asks the backup thread to start waiting for changes to settle. A
+// request that is already pending covers any new requests so this never blocks.
+func (w *Watcher) requestBackup(trigger BackupTrigger) {
+	select {
+	case w.backupRequestChan <- trigger:
+	default:
+	}
+}
+
+// requestUrgentBackup asks the backup thread to back up right away, a request that is
+// already pending covers it.
+func (w *Watcher) requestUrgentBackup(trigger BackupTrigger) {
+	select {
+	case w.urgentBackupChan <- trigger:
+	default:
+	}
+}
+
+// startFSNotifyWatcher must be called while holding w.mu. With closeWrites, writes are
+// ignored since the close-write watcher reports the files once they are closed.
+func (w *Watcher) startFSNotifyWatcher(stop chan struct{}, closeWrites bool) {
+	fsnotifyWatcher := w.openFSNotifyWatcher(stop)
+	if fsnotifyWatcher == nil {
+		return
+	}
+
+	// The events stop if fsnotify closes its channels, a new fsnotify watcher is
+	// created then.
+	go w.supervise("file watcher", stop, func() error {
+		if fsnotifyWatcher == nil {
+			w.mu.Lock()
+			fsnotifyWatcher = w.openFSNotifyWatcher(stop)
+			w.mu.Unlock()
+			if fsnotifyWatcher == nil {
+				return errors.New("could not create a file watcher")
+			}
+		}
+		defer func() {
+			fsnotifyWatcher.Close()
+			fsnotifyWatcher = nil
+		}()
+		return w.watchEvents(fsnotifyWatcher, stop, closeWrites)
+	})
+}
+
+// openFSNotifyWatcher creates an fsnotify watcher for the source, nil if it could not
+// be created or the watcher was stopped. Must be called while holding w.mu.
+func (w *Watcher) openFSNotifyWatcher(stop chan struct{}) *fsnotify.Watcher {
+	select {
+	case <-stop:
+		return nil
+	default:
+	}
+	fsnotifyWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		w.handleWatchError(fmt.Errorf("error creating file watcher: %w", classifyWatchError(err)), stop)
+		return nil
+	}
+	w.fsnotifyWatcher = fsnotifyWatcher
+
+	if err := fsnotifyWatcher.Add(w.watchPath()); err != nil {
+		w.handleWatchError(fmt.Errorf("error watching source: %w", classifyWatchError(err)), stop)
+	}
+	return fsnotifyWatcher
+}
+
+// watchEvents handles the events of fsnotifyWatcher until stop is closed, or returns
+// an error if fsnotify stops sending them.
+func (w *Watcher) watchEvents(fsnotifyWatcher *fsnotify.Watcher, stop chan struct{}, closeWrites bool) error {
+	for {
+		select {
+		case event, ok := <-fsnotifyWatcher.Events:
+			if !ok {
+				return errors.New("file events channel was closed")
+			}
+			// event.Op is a bitmask depending on the type of event, for now just
+			// run the backup for any file event, but this is here in case some
+			// events should not trigger a backup.
+			if event.Op != 0 && !(closeWrites && event.Op == fsnotify.Write) {
+				w.handleSourceEvent(event.Name, event.Op.String())
+			}
+		case err, ok := <-fsnotifyWatcher.Errors:
+			if !ok {
+				return errors.New("file errors channel was closed")
+			}
+			w.mu.Lock()
+			w.handleWatchError(fmt.Errorf("error watching files: %w", classifyWatchError(err)), stop)
+			w.mu.Unlock()
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// handleSourceEvent starts a backup for a change to a file in the source.
+func (w *Watcher) handleSourceEvent(path, op string) {
+	if isSelfTestProbe(path) || !w.isSourceEvent(path) || w.IsExcludedPath(path) {
+		return
+	}
+	// Canaries are checked even if they are not backed up.
+	w.checkCanary(path, op)
+	if !w.Include.includesEvent(w.Source, path) || !w.filtersIncludeEvent(path) {
+		return
+	}
+	Logf(w.Name, LogLevelDebug, "File event detected: %s, Op: %s", path, op)
+	w.recordChange(path)
+	w.recordAuditEvent(path, op)
+	w.recordMassChange(path)
+	w.scanCache.invalidate(path)
+	w.countChange()
+	w.requestBackup(BackupTriggerChange)
+}
+
+// watchPath returns the path given to fsnotify. A single file source is watched through
+// its parent directory so the file can be replaced by editors that save atomically.
+func (w *Watcher) watchPath() string {
+	if w.singleFile {
+		return filepath.Dir(w.Source)
+	}
+	// The current version of fsnotify unofficially supports recursive watching by
+	// appending ... to the path and modifying a single line in the fsnotify code.
+	// TODO: Decide how this program should be built and distributed.
+	return filepath.Join(w.Source, "...")
+}
+
+// isSourceEvent filters out events for the other files in the parent directory of a
+// single file source.
+func (w *Watcher) isSourceEvent(path string) bool {
+	return !w.singleFile || filepath.Clean(path) == filepath.Clean(w.Source)
+}
+
+// handleWatchError records an error from fsnotify in the watcher status. If the error
+// is caused by the OS watch limits the watcher can fall back to polling the source.
+// Must be called while holding w.mu.
+func (w *Watcher) handleWatchError(err error, stop chan struct{}) {
+	Logf(w.Name, LogLevelError, "%v", err)
+	w.status.Error = err.Error()
+
+	var limitErr *WatchLimitError
+	if !errors.As(err, &limitErr) {
+		return
+	}
+	w.status.Hint = limitErr.Hint()
+	Logf(w.Name, LogLevelWarn, "%s", w.status.Hint)
+
+	if w.PollOnWatchLimit && !w.status.Polling {
+		Logf(w.Name, LogLevelWarn, "Falling back to polling the source every %v", w.pollInterval())
+		w.status.Polling = true
+		go w.pollLoop(stop)
+	}
+}
+
+// runBackupLoop runs the backup thread until stop is closed, restarting it if it stops
+// before then, and closes done once it has returned.
+func (w *Watcher) runBackupLoop(stop, done chan struct{}) {
+	defer close(done)
+	restarted := false
+	w.supervise("backup thread", stop, func() error {
+		// Changes seen before the thread stopped would otherwise wait for the next
+		// event.
+		if restarted {
+			if err := w.createBackupIfBackupIsOutdated(); err != nil {
+				Logf(w.Name, LogLevelWarn, "Error checking if backup is up to date: %v", err)
+			}
+		}
+		restarted = true
+		w.backupLoop(stop)
+		return nil
+	})
+}
+
+// Thread responsible for creating backups.
+func (w *Watcher) backupLoop(stop chan struct{}) {
+	w.mu.Lock()
+	debounce := w.Debounce
+	quietHours := w.QuietHours
+	deferPolicy := w.Defer
+	idleTrigger := w.IdleTrigger
+	openFiles := w.OpenFiles
+	stability := w.Stability
+	waitTime := w.WaitTime
+	freeSpace := w.FreeSpace
+	maxStaleness := time.Duration(w.MaxStaleness)
+	restoreDrill := w.RestoreDrill
+	trashGrace := w.Retention.trashGrace()
+	w.mu.Unlock()
+
+	// The load is measured since the previous sample, one is taken now so the first
+	// backup has something to compare with.
+	if deferPolicy.CPU > 0 {
+		w.powerState()
+	}
+
+	// Waits for changes to settle, or for the cool down to end with the leading
+	// strategy.
+	var timer Timer
+	var timerChan <-chan time.Time
+	// Limits how long changes can postpone a backup with the max-wait strategy.
+	var maxTimer Timer
+	var maxTimerChan <-chan time.Time
+	// Fires when a deferred backup can be made.
+	var deferTimer Timer
+	var deferTimerChan <-chan time.Time
+	// True while the leading strategy is waiting before it allows the next backup.
+	coolingDown := false
+	// Reason for the next backup, the first request since the last backup wins.
+	var pendingTrigger BackupTrigger
+	// When the wait policy first found open files for the pending backup.
+	var openSince time.Time
+	// Sizes of the changed files from the last stability check of the pending backup.
+	var stabilityCheck stabilityState
+	var reconcileTimer Timer
+	var reconcileTimerChan <-chan time.Time
+	// Fires when the free space of the destinations is checked, right away and then
+	// every interval.
+	var freeSpaceTimer Timer
+	var freeSpaceTimerChan <-chan time.Time
+	if freeSpace.enabled() {
+		freeSpaceTimer = w.clock.NewTimer(0)
+		freeSpaceTimerChan = freeSpaceTimer.C()
+	}
+	// Fires when the age of the newest backup is checked.
+	var staleTimer Timer
+	var staleTimerChan <-chan time.Time
+	if maxStaleness > 0 {
+		staleTimer = w.clock.NewTimer(stalenessCheckInterval)
+		staleTimerChan = staleTimer.C()
+	}
+	// Fires when the next restore drill is due.
+	var drillTimer Timer
+	var drillTimerChan <-chan time.Time
+	if restoreDrill.enabled() {
+		drillTimer = w.clock.NewTimer(time.Duration(restoreDrill.Interval))
+		drillTimerChan = drillTimer.C()
+	}
+	// Fires when backups that have been in the trash long enough are deleted.
+	var trashTimer Timer
+	var trashTimerChan <-chan time.Time
+	if trashGrace > 0 {
+		trashTimer = w.clock.NewTimer(trashPurgeInterval)
+		trashTimerChan = trashTimer.C()
+	}
+
+	stopTimers := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		if maxTimer != nil {
+			maxTimer.Stop()
+		}
+		timer, timerChan = nil, nil
+		maxTimer, maxTimerChan = nil, nil
+	}
+	startTimer := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = w.clock.NewTimer(waitTime)
+		timerChan = timer.C()
+	}
+	deferBackup := func(wait time.Duration, reason string) {
+		if w.setDeferred(reason) {
+			Logf(w.Name, LogLevelInfo, "%s, deferring backup", reason)
+		}
+		deferTimer = w.clock.NewTimer(wait)
+		deferTimerChan = deferTimer.C()
+	}
+	backupPending := func() {
+		stopTimers()
+		if deferTimer != nil {
+			return
+		}
+		now := w.clock.Now()
+		if until, ok := quietUntil(quietHours, now); ok {
+			deferBackup(until.Sub(now), fmt.Sprintf("Quiet hours until %s", until.Format(quietHoursLayout)))
+			return
+		}
+		// There is no event for the conditions changing so they are checked again
+		// periodically.
+		if deferPolicy.enabled() {
+			if reason := deferPolicy.reason(w.powerState()); reason != "" {
+				deferBackup(w.deferPollInterval, reason)
+				return
+			}
+		}
+		// The backup is deferred until the input could have been idle long enough, and
+		// the idle time is checked again then.
+		if idleTrigger.enabled() {
+			if idle, err := w.idleTime(); err != nil {
+				Logf(w.Name, LogLevelWarn, "Error reading idle time, creating backup without waiting: %v", err)
+			} else if after := time.Duration(idleTrigger.After); idle < after {
+				deferBackup(after-idle, "Waiting for the computer to be idle")
+				return
+			}
+		}
+		if openFiles.enabled() && !w.checkOpenFiles(openFiles, &openSince, deferBackup) {
+			// A deferred backup keeps its trigger, a skipped backup drops it.
+			if deferTimer == nil {
+				pendingTrigger = ""
+			}
+			return
+		}
+		openSince = time.Time{}
+		if stability.enabled() && !w.checkStability(stability, &stabilityCheck, deferBackup) {
+			return
+		}
+		stabilityCheck = stabilityState{}
+		w.setDeferred("")
+		w.clearChangedPaths()
+		w.createTriggeredBackup(pendingTrigger)
+		pendingTrigger = ""
+	}
+
+	for {
+		select {
+		case <-stop:
+			stopTimers()
+			if deferTimer != nil {
+				deferTimer.Stop()
+			}
+			if pendingTrigger != "" {
+				w.mu.Lock()
+				w.unsavedChanges = true
+				w.mu.Unlock()
+			}
+			if reconcileTimer != nil {
+				reconcileTimer.Stop()
+			}
+			if freeSpaceTimer != nil {
+				freeSpaceTimer.Stop()
+			}
+			if staleTimer != nil {
+				staleTimer.Stop()
+			}
+			if drillTimer != nil {
+				drillTimer.Stop()
+			}
+			if trashTimer != nil {
+				trashTimer.Stop()
+			}
+			return
+
+		// The destination was changed outside of the watcher, wait for the changes to
+		// settle before checking the metadata.
+		case <-w.reconcileRequestChan:
+			if reconcileTimer != nil {
+				reconcileTimer.Stop()
+			}
+			reconcileTimer = w.clock.NewTimer(reconcileDelay)
+			reconcileTimerChan = reconcileTimer.C()
+
+		case <-reconcileTimerChan:
+			w.reconcileMetadata()
+			reconcileTimer = nil
+			reconcileTimerChan = nil
+
+		case <-freeSpaceTimerChan:
+			w.checkFreeSpace(freeSpace)
+			freeSpaceTimer = w.clock.NewTimer(freeSpace.interval())
+			freeSpaceTimerChan = freeSpaceTimer.C()
+
+		case <-staleTimerChan:
+			w.checkStaleness(maxStaleness)
+			staleTimer = w.clock.NewTimer(stalenessCheckInterval)
+			staleTimerChan = staleTimer.C()
+
+		// Drills run on the backup thread so pruning can not delete the backup while
+		// it is restored.
+		case <-drillTimerChan:
+			w.runScheduledRestoreDrill()
+			drillTimer = w.clock.NewTimer(time.Duration(restoreDrill.Interval))
+			drillTimerChan = drillTimer.C()
+
+		case <-trashTimerChan:
+			w.purgeTrashes(trashGrace)
+			trashTimer = w.clock.NewTimer(trashPurgeInterval)
+			trashTimerChan = trashTimer.C()
+
+		case trigger := <-w.backupRequestChan:
+			if pendingTrigger == "" {
+				pendingTrigger = trigger
+			}
+
+			switch {
+			// Back up right away and cool down before the next backup.
+			case debounce.Strategy == DebounceLeading && !coolingDown:
+				Logf(w.Name, LogLevelInfo, "File change detected, creating backup")
+				backupPending()
+				coolingDown = true
+				startTimer()
+
+			// Changes during the cool down are backed up when it ends.
+			case debounce.Strategy == DebounceLeading:
+				Logf(w.Name, LogLevelDebug, "File change detected while cooling down")
+
+			// An file was changed, start a timer to wait for all file changes to
+			// settle before creating a backup.
+			default:
+				Logf(w.Name, LogLevelDebug, "File change detected, starting timer for %s", waitTime)
+				startTimer()
+				if debounce.Strategy == DebounceMaxWait && maxTimer == nil {
+					maxTimer = w.clock.NewTimer(debounce.maxWait())
+					maxTimerChan = maxTimer.C()
+				}
+			}
+
+		// The program of the process trigger has finished writing its files, back them
+		// up without waiting for the changes to settle.
+		case <-w.processExitChan:
+			if pendingTrigger == "" {
+				pendingTrigger = BackupTriggerProcessExit
+			}
+			backupPending()
+			// The backup replaces one the leading strategy may have been cooling down
+			// for, so a new cool down starts.
+			if debounce.Strategy == DebounceLeading && pendingTrigger == "" {
+				coolingDown = true
+				startTimer()
+			}
+
+		// Something may be changing every file, such as a mass change or a touched
+		// canary, back up what is left of the source now instead of waiting for the
+		// changes to settle.
+		case trigger := <-w.urgentBackupChan:
+			Logf(w.Name, LogLevelWarn, "Creating backup right away, trigger: %s", trigger)
+			// The backup covers the pending changes, so a deferred or waiting backup of
+			// them is not made afterwards. The cool down of the leading strategy keeps
+			// running and ends on its own.
+			pendingTrigger = ""
+			if debounce.Strategy != DebounceLeading {
+				stopTimers()
+			}
+			if deferTimer != nil {
+				deferTimer.Stop()
+				deferTimer, deferTimerChan = nil, nil
+				w.setDeferred("")
+			}
+			w.clearChangedPaths()
+			w.createTriggeredBackup(trigger)
+
+		case call := <-w.backupCallChan:
+			w.answerBackupCall(call)
+
+		// The timer has expired, which means the changes have settled and it's time to
+		// create a backup.
+		case <-timerChan:
+			timer, timerChan = nil, nil
+			if debounce.Strategy == DebounceLeading {
+				if pendingTrigger == "" {
+					coolingDown = false
+					continue
+				}
+				Logf(w.Name, LogLevelInfo, "Cool down ended, creating backup of the changes made during it")
+				backupPending()
+				// A deferred backup starts the next cool down once it is made.
+				if pendingTrigger == "" {
+					startTimer()
+				}
+				continue
+			}
+
+			Logf(w.Name, LogLevelInfo, "Timer expired, creating backup")
+			backupPending()
+
+		// Changes have not stopped for the max wait, back up what is there now.
+		case <-maxTimerChan:
+			Logf(w.Name, LogLevelInfo, "Changes have continued for %v, creating backup", debounce.MaxWait)
+			backupPending()
+
+		// The changes made while backups were deferred are backed up together. The
+		// conditions may still hold or another window may have started so this can be
+		// deferred again.
+		case <-deferTimerChan:
+			deferTimer, deferTimerChan = nil, nil
+			if pendingTrigger != "" {
+				Logf(w.Name, LogLevelInfo, "Creating deferred backup")
+				backupPending()
+				if debounce.Strategy == DebounceLeading && pendingTrigger == "" {
+					startTimer()
+				}
+			}
+		}
+	}
+}
+
+// CreateBackup creates a backup right away without waiting for changes to settle.
+func (w *Watcher) CreateBackup() {
+	w.createTriggeredBackup(BackupTriggerManual)
+}
+
+// createTriggeredBackup creates a backup and returns it, false is returned if the
+// backup could not be created.
+func (w *Watcher) createTriggeredBackup(trigger BackupTrigger) (Backup, bool) {
+	// Snapshot the values for this backup operation to avoid them being incorrect if
+	// the watcher is modified while the backup is being created.
+	w.mu.Lock()
+	sourceSnapshot := w.Source
+	folderFormatSnapshot := w.FolderFormat
+	copyEngineSnapshot := w.CopyEngine
+	preserveNTFSSnapshot := w.PreserveNTFS
+	resticSnapshot := w.Restic
+	snapshotModeSnapshot := w.Snapshot
+	checksumsSnapshot := w.Checksums
+	includeSnapshot := w.Include
+	timeZoneSnapshot := w.TimeZone
+	entropySnapshot := w.Entropy
+	stagingSnapshot := w.Staging
+	hooks := w.hooks
+	audit := w.takeAuditLog()
+	w.mu.Unlock()
+
+	// fail logs why the backup was not created and records it in the history.
+	var timestampFolder string
+	fail := func(eventType HistoryEventType, level LogLevel, format string, args ...any) (Backup, bool) {
+		w.returnAuditLog(audit)
+		message := fmt.Sprintf(format, args...)
+		Logf(w.Name, level, "%s", message)
+		w.recordEvent(HistoryEvent{Type: eventType, Backup: timestampFolder, Trigger: trigger, Message: message})
+		return Backup{}, false
+	}
+
+	if err := hooks.check(copyEngineSnapshot, snapshotModeSnapshot, resticSnapshot.enabled()); err != nil {
+		return fail(HistoryBackupFailed, LogLevelError, "Error creating backup: %v", err)
+	}
+	for _, hook := range hooks.pre {
+		if err := hook.BeforeBackup(w, trigger); err != nil {
+			return fail(HistoryBackupSkipped, LogLevelInfo, "Backup cancelled by a hook: %v", err)
+		}
+	}
+	// Only backups that are going ahead wait for their turn.
+	if w.limiter != nil {
+		w.setDeferred("Waiting for other backups to finish")
+		release := w.limiter.Acquire(w)
+		w.setDeferred("")
+		defer release()
+	}
+
+	destinationSnapshot, err := w.nextDestination()
+	if err != nil {
+		return fail(HistoryBackupFailed, LogLevelError, "Error choosing destination: %v", err)
+	}
+
+	sequence, err := w.nextSequence()
+	if err != nil {
+		return fail(HistoryBackupFailed, LogLevelError, "Error numbering backup: %v", err)
+	}
+
+	location, err := timeZoneSnapshot.location()
+	if err != nil {
+		return fail(HistoryBackupFailed, LogLevelError, "Error naming backup: %v", err)
+	}
+	timestamp := w.clock.Now().In(location)
+	// The folder format can contain separators to nest backups in folders such as
+	// <year>/<month>/<timestamp>, metadata always uses forward slashes.
+	timestampFolder = w.uniqueBackupFolder(destinationSnapshot, formatBackupFolder(folderFormatSnapshot, timestamp, sequence))
+	destinationPath := filepath.Join(destinationSnapshot, filepath.FromSlash(timestampFolder))
+	recordedTimestamp := w.orderedTimestamp(timestamp, timestampFolder)
+	w.recordEvent(HistoryEvent{Type: HistoryBackupStarted, Backup: timestampFolder, Trigger: trigger, Time: timestamp})
+
+	// Restic and ZFS backups only add metadata to the destination. Their snapshots are
+	// created atomically so no journal is needed.
+	if resticSnapshot.enabled() || snapshotModeSnapshot == SnapshotModeZFS {
+		var backup Backup
+		var err error
+		if resticSnapshot.enabled() {
+			backup, err = w.createResticBackup(resticSnapshot, sourceSnapshot, timestamp, trigger)
+		} else {
+			backup, err = w.createZFSBackup(sourceSnapshot, timestampFolder)
+		}
+		if err != nil {
+			return fail(HistoryBackupFailed, LogLevelError, "Error creating backup: %v", err)
+		}
+		backup.Sequence = sequence
+		backup.Timestamp = recordedTimestamp
+		backup.Path = timestampFolder
+		backup.FolderFormat = folderFormatSnapshot
+		backup.TimeZone = backupTimeZone(timestamp)
+		backup.Destination = destinationSnapshot
+		backup.Name = trigger.backupName()
+		backup.Events = audit.events
+		backup.DroppedEvents = audit.dropped
+		w.finishBackup(backup, trigger)
+		return backup, true
+	}
+
+	// The entry is only removed once the backup is complete, if the app crashes
+	// before then the partial backup is removed the next time the watcher starts.
+	if err := w.journalStart(destinationSnapshot, timestampFolder, timestamp); err != nil {
+		return fail(HistoryBackupFailed, LogLevelError, "Error starting backup: %v", err)
+	}
+	// Until the backup is finished any return removes what was written of it and
+	// finishes its journal entry.
+	finished := false
+	defer func() {
+		if finished {
+			return
+		}
+		w.mu.Lock()
+		w.activeBackupPath = ""
+		w.mu.Unlock()
+		if _, err := os.Lstat(destinationPath); err == nil {
+			if err := w.deleteBackup(Backup{Path: timestampFolder, Destination: destinationSnapshot}); err != nil {
+				Logf(w.Name, LogLevelError, "Error removing incomplete backup: %v", err)
+				return
+			}
+		}
+		if err := w.journalFinish(destinationSnapshot, timestampFolder); err != nil {
+			Logf(w.Name, LogLevelError, "Error finishing backup: %v", err)
+		}
+	}()
+
+	// Not every copy engine creates missing parent folders.
+	if err := os.MkdirAll(filepath.Dir(destinationPath), 0755); err != nil {
+		return fail(HistoryBackupFailed, LogLevelError, "Error creating backup folder: %v", err)
+	}
+
+	// With a staging folder the backup is made, transformed and checked there and only
+	// moved to the destination once it is complete.
+	backupPath := destinationPath
+	if stagingSnapshot != "" {
+		stagingRoot, err := w.createStagingFolder(stagingSnapshot)
+		if err != nil {
+			return fail(HistoryBackupFailed, LogLevelError, "Error creating backup folder: %v", err)
+		}
+		defer os.RemoveAll(stagingRoot)
+		backupPath = filepath.Join(stagingRoot, "backup")
+	}
+
+	// A single file is copied into the backup folder so backups have the same layout
+	// no matter what the source is.
+	copyDestination := backupPath
+	if w.singleFile {
+		copyDestination = filepath.Join(backupPath, filepath.Base(sourceSnapshot))
+		if err := os.MkdirAll(backupPath, 0755); err != nil {
+			return fail(HistoryBackupFailed, LogLevelError, "Error creating backup folder: %v", err)
+		}
+	}
+
+	// Files in the previous backup may have been transformed already, so they are only
+	// cloned without transformers.
+	if snapshotModeSnapshot == SnapshotModeReflink && len(hooks.transformers) == 0 {
+		copyEngineSnapshot = withPrevious(copyEngineSnapshot, w.previousBackupPath(destinationSnapshot))
+	}
+	copyEngineSnapshot = withFilters(copyEngineSnapshot, hooks.filters)
+	var unsupported []Attribute
+	if snapshotModeSnapshot != SnapshotModeBtrfs {
+		unsupported = w.destinationAttributes(destinationSnapshot)
+		copyEngineSnapshot = withDegradation(copyEngineSnapshot, unsupported)
+	}
+	chunks := &chunkLog{}
+	copyEngineSnapshot = withChunkStore(copyEngineSnapshot, chunkStore{dir: chunkDir(destinationSnapshot)}, chunks)
+
+	w.mu.Lock()
+	w.activeBackupPath = destinationPath
+	w.mu.Unlock()
+
+	if backupPath != destinationPath {
+		Logf(w.Name, LogLevelInfo, "Creating backup at %s, staged in %s", destinationPath, backupPath)
+	} else {
+		Logf(w.Name, LogLevelInfo, "Creating backup at %s", destinationPath)
+	}
+	// Files that change from the scan before the copy until the backup is finished are
+	// recorded in the metadata, a btrfs snapshot is atomic so it is never fuzzy.
+	var before map[string]string
+	if snapshotModeSnapshot == SnapshotModeBtrfs {
+		if err := btrfsSnapshot(sourceSnapshot, destinationPath); err != nil {
+			return fail(HistoryBackupFailed, LogLevelError, "Error creating btrfs snapshot: %v", err)
+		}
+	} else {
+		before, err = sourceState(sourceSnapshot, includeSnapshot)
+		if err != nil {
+			Logf(w.Name, LogLevelWarn, "Error reading source before copying: %v", err)
+		}
+		// Try copying files 100 times waiting 0.1 second between attempt to bypass locked files
+		// TODO: A more reasonable appproach to handling locked files
+		var copyErr error
+		for attempt := range 100 {
+			// Retries keep what was already copied, a dropped connection to a network
+			// destination does not start a large backup over.
+			engine := copyEngineSnapshot
+			if attempt > 0 {
+				engine = withResume(engine)
+			}
+			err := engine.Copy(sourceSnapshot, copyDestination)
+			copyErr = nil
+			var skipped *skippedFilesError
+			if errors.As(err, &skipped) {
+				w.notifyWarning("Backup %s is missing files: %v", timestampFolder, err)
+				break
+			}
+			// Retrying does not help with files the watcher is not allowed to read.
+			if errors.Is(err, fs.ErrPermission) {
+				return fail(HistoryBackupFailed, LogLevelError, "Error copying source to destination, the permissions setting can skip or elevate files that can not be read: %v", err)
+			}
+			if err != nil {
+				copyErr = err
+				Logf(w.Name, LogLevelError, "Error copying source to destination: %v", err)
+				// The share the destination is on may have dropped during the copy.
+				if _, onShare := SharePath(destinationSnapshot); onShare {
+					if _, err := reachShare(w.keychain, destinationSnapshot); err != nil {
+						Logf(w.Name, LogLevelWarn, "%v", err)
+					}
+				}
+				w.clock.Sleep(100 * time.Millisecond)
+				continue
+			}
+			break
+		}
+		// A backup that is missing files because every attempt failed is not kept, a
+		// restore relies on its safety backup holding the whole source.
+		if copyErr != nil {
+			return fail(HistoryBackupFailed, LogLevelError, "Error copying source to destination, giving up after 100 attempts: %v", copyErr)
+		}
+	}
+
+	if err := transformBackup(hooks.transformers, copyDestination, copyDestination); err != nil {
+		// The backup is removed since it could hold files the transformers were meant
+		// to change.
+		return fail(HistoryBackupFailed, LogLevelError, "Error transforming backup: %v", err)
+	}
+
+	if preserveNTFSSnapshot.Enabled() {
+		if err := preserveNTFSMetadata(sourceSnapshot, copyDestination, preserveNTFSSnapshot); err != nil {
+			Logf(w.Name, LogLevelWarn, "Error preserving NTFS metadata: %v", err)
+		}
+	}
+
+	// Add the backup to metadata
+	backup := Backup{
+		Sequence:      sequence,
+		Timestamp:     recordedTimestamp,
+		Path:          timestampFolder,
+		FolderFormat:  folderFormatSnapshot,
+		Destination:   destinationSnapshot,
+		TimeZone:      backupTimeZone(timestamp),
+		Events:        audit.events,
+		DroppedEvents: audit.dropped,
+		Degraded:      unsupported,
+		Chunked:       chunks.paths(),
+	}
+	backup.Name = trigger.backupName()
+	if entropySnapshot.enabled() {
+		w.checkEntropy(entropySnapshot, &backup, backupPath, w.previousBackupPath(destinationSnapshot))
+	}
+
+	w.writeSidecar(sourceSnapshot, backupPath, backup, timestamp, trigger, checksumsSnapshot)
+
+	if backupPath != destinationPath {
+		if err := moveStagedBackup(backupPath, destinationPath, unsupported); err != nil {
+			return fail(HistoryBackupFailed, LogLevelError, "Error moving backup from the staging folder: %v", err)
+		}
+	}
+
+	if w.ReadOnlyBackups {
+		if err := protectBackup(destinationPath); err != nil {
+			Logf(w.Name, LogLevelError, "Error making backup read-only: %v", err)
+		}
+	}
+
+	// The source is read again after the copy, such as for its NTFS metadata, so it is
+	// only checked once everything is done.
+	if before != nil {
+		if after, err := sourceState(sourceSnapshot, includeSnapshot); err != nil {
+			Logf(w.Name, LogLevelWarn, "Error reading source after backing up: %v", err)
+		} else {
+			root := sourceSnapshot
+			if w.singleFile {
+				root = filepath.Dir(sourceSnapshot)
+			}
+			if fuzzyPaths := changedDuringBackup(root, before, after); len(fuzzyPaths) > 0 {
+				backup.Fuzzy = true
+				backup.FuzzyPaths = fuzzyPaths
+				Logf(w.Name, LogLevelWarn, "%d files changed while backup %s was made, it may be inconsistent", len(fuzzyPaths), timestampFolder)
+			}
+		}
+	}
+
+	w.mu.Lock()
+	w.activeBackupPath = ""
+	w.recentBackups[destinationPath] = w.clock.Now()
+	w.mu.Unlock()
+
+	finished = true
+	w.finishBackup(backup, trigger)
+	return backup, true
+}
+
+// finishBackup adds a completed backup to the metadata, prunes old backups and
+// notifies the observers.
+func (w *Watcher) finishBackup(backup Backup, trigger BackupTrigger) {
+	w.mu.Lock()
+	w.Metadata = append(w.Metadata, backup)
+	maxStaleness := time.Duration(w.MaxStaleness)
+	w.mu.Unlock()
+
+	// Backups are also finished outside of the backup thread, such as by commands and
+	// imports, saveMetadata holds the metadata lock so their writes do not overlap.
+	if err := w.saveMetadata(backup.Destination); err != nil {
+		Logf(w.Name, LogLevelError, "Error saving metadata: %v", err)
+	}
+	if backup.ResticSnapshot == "" {
+		if err := w.journalFinish(backup.Destination, backup.Path); err != nil {
+			Logf(w.Name, LogLevelError, "Error finishing backup: %v", err)
+		}
+		Logf(w.Name, LogLevelInfo, "Backup created successfully at %s", w.BackupPath(backup))
+	} else {
+		Logf(w.Name, LogLevelInfo, "Backup created successfully as restic snapshot %s", backup.ResticSnapshot)
+	}
+	w.recordEvent(HistoryEvent{Type: HistoryBackupCompleted, Backup: backup.Path, Trigger: trigger})
+	// The backup ends any staleness warning.
+	if maxStaleness > 0 {
+		w.checkStaleness(maxStaleness)
+	}
+
+	for _, hook := range w.registeredHooks().post {
+		if err := hook.AfterBackup(w, backup); err != nil {
+			Logf(w.Name, LogLevelWarn, "Error running post-backup hook: %v", err)
+		}
+	}
+
+	// Pruning is skipped before a restore since it could delete the backup that is
+	// about to be restored, and before a prune since the prune follows. After a mass
+	// change it is paused so the backups from before it are kept.
+	if w.Retention.Enabled() && trigger != BackupTriggerPreRestore && trigger != BackupTriggerSafety && w.RetentionPaused() == "" {
+		if _, err := w.Prune(false); err != nil {
+			Logf(w.Name, LogLevelError, "Error pruning backups: %v", err)
+		}
+	}
+	if w.Remote.enabled() && backup.ResticSnapshot == "" {
+		w.requestRemoteUpload()
+	}
+
+	w.notifyObservers()
+}
+
+func (w *Watcher) AddObserver(observer BackupCompleteObserver) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, obs := range w.customObservers {
+		if obs == observer {
+			return // Already added
+		}
+	}
+	w.customObservers = append(w.customObservers, observer)
+}
+
+func (w *Watcher) RemoveObserver(observer BackupCompleteObserver) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i, obs := range w.customObservers {
+		if obs == observer {
+			w.customObservers = append(w.customObservers[:i], w.customObservers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Notify observers that a backup has been completed
+func (w *Watcher) notifyObservers() {
+	for _, observer := range w.observers() {
+		w.dispatch(func() { observer.OnBackupCompletion(w) })
+	}
+}
+
+// observers returns a copy of the observers so they can be called without holding w.mu,
+// observers are free to call back into the watcher.
+func (w *Watcher) observers() []BackupCompleteObserver {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	observers := make([]BackupCompleteObserver, len(w.customObservers))
+	copy(observers, w.customObservers)
+	return observers
+}
+
+// callObserver calls an observer and recovers from a panic so a broken observer cannot
+// stop the backup thread or the other observers from being notified.
+func (w *Watcher) callObserver(call func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			Logf(w.Name, LogLevelError, "Observer panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+	call()
+}
+
+func (w *Watcher) createBackupIfBackupIsOutdated() error {
+	w.mu.Lock()
+	backups := len(w.Metadata)
+	w.mu.Unlock()
+	// If no backups have been made it has to be outdated
+	if backups == 0 {
+		Logf(w.Name, LogLevelInfo, "No backups found, creating initial backup")
+		w.requestBackup(BackupTriggerInitial)
+		return nil
+	}
+
+	foldersMatch, err := w.SourceMatchesLatestBackup()
+	if err != nil {
+		return err
+	}
+
+	if !foldersMatch {
+		Logf(w.Name, LogLevelInfo, "Source and latest backup do not match, creating new backup")
+		w.requestBackup(BackupTriggerOutdated)
+	}
+
+	return nil
+}
+
+// SourceMatchesLatestBackup compares the source with the newest backup, it is false if
+// there are no backups.
+func (w *Watcher) SourceMatchesLatestBackup() (bool, error) {
+	w.mu.Lock()
+	if len(w.Metadata) == 0 {
+		w.mu.Unlock()
+		return false, nil
+	}
+	latest := w.Metadata[len(w.Metadata)-1]
+	source := w.Source
+	include := w.Include
+	placeholders := w.Placeholders
+	hooks := w.hooks
+	latestBackupPath := w.BackupPath(latest)
+	w.mu.Unlock()
+
+	if latest.ResticSnapshot != "" {
+		hash, err := w.hashSource(source, latest.HashAlgorithm)
+		if err != nil {
+			return false, fmt.Errorf("error comparing source and latest backup: %w", err)
+		}
+		return hash == latest.SourceHash, nil
+	}
+
+	// Files are compared with the backup as the transformers would store them.
+	var transform func(path string, contents []byte) ([]byte, error)
+	if len(hooks.transformers) > 0 {
+		transform = func(path string, contents []byte) ([]byte, error) {
+			return transformContents(hooks.transformers, hookRelPath(source, path), contents)
+		}
+	}
+
+	var foldersMatch bool
+	var err error
+	if w.singleFile {
+		foldersMatch, err = doFilesMatch(source, filepath.Join(latestBackupPath, filepath.Base(source)), transform)
+	} else {
+		var ignore []string
+		if !sourceHasSidecarName(source, false) {
+			ignore = []string{backupSidecarName}
+			if sidecar, err := ReadBackupSidecar(latestBackupPath); err == nil {
+				ignore = sidecar.generatedFiles()
+			}
+		}
+		included := func(path string) bool {
+			if !include.includesFile(source, path) {
+				return false
+			}
+			info, err := os.Lstat(path)
+			if err == nil && info.Mode()&fs.ModeSymlink != 0 && slices.Contains(latest.Degraded, AttributeSymlinks) {
+				return false
+			}
+			return err != nil || includedByFilters(hooks.filters, source, path, info)
+		}
+		foldersMatch, err = doFoldersMatch(source, latestBackupPath, placeholders, included, transform, ignore...)
+	}
+	if err != nil {
+		return false, fmt.Errorf("error comparing source and latest backup: %w", err)
+	}
+	return foldersMatch, nil
+}
+
+// doFoldersMatch compares two folders recursively. Names in ignore are skipped in the
+// top level of the destination, such as the sidecar of a backup. Placeholders in the
+// source are compared according to the policy they were backed up with and files in
+// the source that included returns false for are skipped. The contents of source
+// files are passed through transform if it is set.
+func doFoldersMatch(source, destination string, placeholders PlaceholderPolicy, included func(path string) bool, transform func(path string, contents []byte) ([]byte, error), ignore ...string) (bool, error) {
+	sourceEntries, err := os.ReadDir(source)
+	if err != nil {
+		return false, fmt.Errorf("error reading source directory: %w", err)
+	}
+	sourceEntries = slices.DeleteFunc(sourceEntries, func(entry os.DirEntry) bool {
+		info, err := entry.Info()
+		if err != nil {
+			return false
+		}
+		if !entry.IsDir() && !included(filepath.Join(source, entry.Name())) {
+			return true
+		}
+		return isJunction(info) || (placeholders == PlaceholderSkip && isPlaceholder(info))
+	})
+	destEntries, err := os.ReadDir(destination)
+	if err != nil {
+		return false, fmt.Errorf("error reading destination directory: %w", err)
+	}
+	destEntries = slices.DeleteFunc(destEntries, func(entry os.DirEntry) bool {
+		return slices.Contains(ignore, entry.Name())
+	})
+
+	if len(sourceEntries) != len(destEntries) {
+		return false, nil
+	}
+
+	for i := range sourceEntries {
+		sourceEntry := sourceEntries[i]
+		destinationEntry := destEntries[i]
+
+		if sourceEntry.Name() != destinationEntry.Name() {
+			return false, nil
+		}
+
+		sourceString := filepath.Join(source, sourceEntry.Name())
+		destinationString := filepath.Join(destination, destinationEntry.Name())
+
+		if sourceEntry.IsDir() && destinationEntry.IsDir() {
+			subfolderMatch, err := doFoldersMatch(sourceString, destinationString, placeholders, included, transform)
+			if err != nil {
+				return false, fmt.Errorf("error comparing directories: %w", err)
+			}
+			if !subfolderMatch {
+				return false, nil
+			}
+		} else if !sourceEntry.IsDir() && !destinationEntry.IsDir() {
+			var fileMatch bool
+			info, err := sourceEntry.Info()
+			if err == nil && placeholders == PlaceholderStub && isPlaceholder(info) {
+				fileMatch, err = placeholders.placeholderMatches(info, destinationString)
+			} else if err == nil {
+				fileMatch, err = doFilesMatch(sourceString, destinationString, transform)
+			}
+			if err != nil {
+				return false, fmt.Errorf("error comparing files: %w", err)
+			}
+
+			if !fileMatch {
+				return false, nil
+			}
+
+		} else {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func doFilesMatch(source, destination string, transform func(path string, contents []byte) ([]byte, error)) (bool, error) {
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return false, fmt.Errorf("error stating source file: %v", err)
+	}
+	destInfo, err := os.Stat(destination)
+	if err != nil {
+		return false, fmt.Errorf("error stating destination file: %v", err)
+	}
+
+	sourceContent, err := os.ReadFile(source)
+	if err != nil {
+		return false, fmt.Errorf("error reading source file: %v", err)
+	}
+	if transform != nil {
+		if sourceContent, err = transform(source, sourceContent); err != nil {
+			return false, err
+		}
+	}
+
+	destContent, err := os.ReadFile(destination)
+	if err != nil {
+		return false, fmt.Errorf("error reading destination file: %v", err)
+	}
+
+	// A large file may be stored as the manifest of its chunks.
+	if string(sourceContent) != string(destContent) {
+		manifest, chunked := parseChunkManifest(destContent)
+		if !chunked || !manifest.matches(sourceContent) {
+			return false, nil
+		}
+	}
+
+	if !modTimesMatch(sourceInfo.ModTime(), destInfo.ModTime()) {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,110 @@
+package watcher
+
+// DefaultFolderFormat names backup folders by the time of the backup.
+const DefaultFolderFormat = "2006-01-02_15-04-05.000000"
+
+// WatcherConfig describes a folder pair, it is the options a watcher is created from.
+type WatcherConfig struct {
+	ID           string   `json:"id" yaml:"id" toml:"id"`
+	Source       string   `json:"source" yaml:"source" toml:"source"`
+	Destination  string   `json:"destination" yaml:"destination" toml:"destination"`
+	Enabled      bool     `json:"enabled" yaml:"enabled" toml:"enabled"`
+	WaitTime     Duration `json:"wait_time,omitempty" yaml:"wait_time,omitempty" toml:"wait_time,omitempty"`
+	FolderFormat string   `json:"folder_format,omitempty" yaml:"folder_format,omitempty" toml:"folder_format,omitempty"`
+	// Additional destinations that backups alternate between along with Destination.
+	RotationDestinations []string `json:"rotation_destinations,omitempty" yaml:"rotation_destinations,omitempty" toml:"rotation_destinations,omitempty"`
+	// Name of the copy engine, "go", "rsync" or "robocopy".
+	CopyEngine string `json:"copy_engine,omitempty" yaml:"copy_engine,omitempty" toml:"copy_engine,omitempty"`
+	// Poll the source for changes if the OS limits on file watches are reached.
+	PollOnWatchLimit bool `json:"poll_on_watch_limit,omitempty" yaml:"poll_on_watch_limit,omitempty" toml:"poll_on_watch_limit,omitempty"`
+	// Time between polls when polling the source.
+	PollInterval Duration `json:"poll_interval,omitempty" yaml:"poll_interval,omitempty" toml:"poll_interval,omitempty"`
+	// Warn about and reconcile changes made to the destination outside of the watcher.
+	WatchDestination bool `json:"watch_destination,omitempty" yaml:"watch_destination,omitempty" toml:"watch_destination,omitempty"`
+	// Make backups read-only once they are complete.
+	ReadOnlyBackups bool `json:"read_only_backups,omitempty" yaml:"read_only_backups,omitempty" toml:"read_only_backups,omitempty"`
+	// Never write inside the source, for synced folders that must not be changed.
+	ReadOnlySource bool `json:"read_only_source,omitempty" yaml:"read_only_source,omitempty" toml:"read_only_source,omitempty"`
+	// Algorithm files are hashed with for manifests, "xxhash" or "sha256".
+	HashAlgorithm HashAlgorithm `json:"hash_algorithm,omitempty" yaml:"hash_algorithm,omitempty" toml:"hash_algorithm,omitempty"`
+	// Keep the hashes of the source files across restarts.
+	PersistScanCache bool `json:"persist_scan_cache,omitempty" yaml:"persist_scan_cache,omitempty" toml:"persist_scan_cache,omitempty"`
+	// Windows file metadata copied into backups, ignored on other platforms.
+	PreserveNTFS NTFSPreservation `json:"preserve_ntfs,omitzero" yaml:"preserve_ntfs,omitempty" toml:"preserve_ntfs,omitempty"`
+	// Which backups to keep, if no rules are set the default policy is used.
+	Retention RetentionPolicy `json:"retention,omitzero" yaml:"retention,omitempty" toml:"retention,omitempty"`
+	// Size and policy of the queue that backup notifications wait in.
+	ObserverQueue ObserverQueueConfig `json:"observer_queue,omitzero" yaml:"observer_queue,omitempty" toml:"observer_queue,omitempty"`
+	// When changes are backed up, if no strategy is set the default is used.
+	Debounce DebounceConfig `json:"debounce,omitzero" yaml:"debounce,omitempty" toml:"debounce,omitempty"`
+	// Windows during which changes are not backed up until the window ends.
+	QuietHours []QuietHours `json:"quiet_hours,omitempty" yaml:"quiet_hours,omitempty" toml:"quiet_hours,omitempty"`
+	// Conditions, such as running on battery, that changes are not backed up during.
+	Defer DeferPolicy `json:"defer,omitzero" yaml:"defer,omitempty" toml:"defer,omitempty"`
+	// Restic repository to store backups in instead of copying them to Destination.
+	Restic ResticConfig `json:"restic,omitzero" yaml:"restic,omitempty" toml:"restic,omitempty"`
+	// Create "btrfs" or "zfs" snapshots instead of copying the source, or clone unchanged
+	// files from the previous backup with "reflink".
+	Snapshot SnapshotMode `json:"snapshot,omitempty" yaml:"snapshot,omitempty" toml:"snapshot,omitempty"`
+	// Buffer size and preallocation of files copied by the go copy engine.
+	Copy CopyOptions `json:"copy,omitzero" yaml:"copy,omitempty" toml:"copy,omitempty"`
+	// Store large files as chunks shared between backups.
+	Chunking ChunkingConfig `json:"chunking,omitzero" yaml:"chunking,omitempty" toml:"chunking,omitempty"`
+	// How cloud-only placeholder files are backed up, "hydrate", "skip" or "stub".
+	Placeholders PlaceholderPolicy `json:"placeholders,omitempty" yaml:"placeholders,omitempty" toml:"placeholders,omitempty"`
+	// Write a SHA256SUMS file, optionally signed, inside each backup.
+	Checksums ChecksumConfig `json:"checksums,omitzero" yaml:"checksums,omitempty" toml:"checksums,omitempty"`
+	// What happens to files that can not be read, "fail", "skip" or "elevate".
+	Permissions PermissionPolicy `json:"permissions,omitempty" yaml:"permissions,omitempty" toml:"permissions,omitempty"`
+	// What automatic backups do when files in the source are open in other programs.
+	OpenFiles OpenFilesConfig `json:"open_files,omitzero" yaml:"open_files,omitempty" toml:"open_files,omitempty"`
+	// Back up right away when a program, such as a game, exits.
+	ProcessTrigger ProcessTriggerConfig `json:"process_trigger,omitzero" yaml:"process_trigger,omitempty" toml:"process_trigger,omitempty"`
+	// Hold automatic backups until there has been no keyboard or mouse input for a while.
+	IdleTrigger IdleTriggerConfig `json:"idle_trigger,omitzero" yaml:"idle_trigger,omitempty" toml:"idle_trigger,omitempty"`
+	// Wait for changed files to stop growing before automatic backups.
+	Stability StabilityConfig `json:"stability,omitzero" yaml:"stability,omitempty" toml:"stability,omitempty"`
+	// Only back up the files matching these patterns, such as "*.sav".
+	Include IncludePatterns `json:"include,omitempty" yaml:"include,omitempty" toml:"include,omitempty"`
+	// Treat writes as changes once the file is closed instead of on every write, Linux only.
+	CloseWrite bool `json:"close_write,omitempty" yaml:"close_write,omitempty" toml:"close_write,omitempty"`
+	// Zone backup folder names are formatted in, "UTC" or a name such as "Europe/Berlin".
+	TimeZone TimeZone `json:"time_zone,omitempty" yaml:"time_zone,omitempty" toml:"time_zone,omitempty"`
+	// Which waiting backups go first, "low", "normal" or "high".
+	Priority Priority `json:"priority,omitempty" yaml:"priority,omitempty" toml:"priority,omitempty"`
+	// Warn, and optionally delete old backups, when a destination runs low on space.
+	FreeSpace FreeSpaceConfig `json:"free_space,omitempty" yaml:"free_space,omitempty" toml:"free_space,omitempty"`
+	// Warn when no backup has been made for this long while the watcher is running.
+	MaxStaleness Duration `json:"max_staleness,omitempty" yaml:"max_staleness,omitempty" toml:"max_staleness,omitempty"`
+	// Periodically restore a recent backup into a temporary folder and check it.
+	RestoreDrill RestoreDrillConfig `json:"restore_drill,omitempty" yaml:"restore_drill,omitempty" toml:"restore_drill,omitempty"`
+	// Do not back up the source before a restore or prune started from the app or the
+	// command line.
+	SkipSafetyBackups bool `json:"skip_safety_backups,omitempty" yaml:"skip_safety_backups,omitempty" toml:"skip_safety_backups,omitempty"`
+	// Keep watching the source when it is renamed or moved within its parent folder, the
+	// new path is saved to the config.
+	FollowSource bool `json:"follow_source,omitempty" yaml:"follow_source,omitempty" toml:"follow_source,omitempty"`
+	// Keep the file events that led to each backup, see the events command.
+	AuditLog bool `json:"audit_log,omitempty" yaml:"audit_log,omitempty" toml:"audit_log,omitempty"`
+	// Back up right away and stop deleting old backups when a large number of files
+	// change at once, as they do when ransomware encrypts them.
+	MassChange MassChangeConfig `json:"mass_change,omitzero" yaml:"mass_change,omitempty" toml:"mass_change,omitempty"`
+	// Flag backups where many files that compressed well now look like random data, as
+	// encrypted files do.
+	Entropy EntropyConfig `json:"entropy,omitzero" yaml:"entropy,omitempty" toml:"entropy,omitempty"`
+	// Files inside the source, such as "do-not-touch.docx", that nothing should ever
+	// change. Touching one backs up the source right away and shows a warning.
+	Canaries Canaries `json:"canaries,omitempty" yaml:"canaries,omitempty" toml:"canaries,omitempty"`
+	// Sign every backup with the ed25519 key of this installation and only restore
+	// backups signed by a trusted key.
+	SignBackups bool `json:"sign_backups,omitempty" yaml:"sign_backups,omitempty" toml:"sign_backups,omitempty"`
+	// Folder on a fast local disk that backups are made, transformed and checked in
+	// before they are moved to the destination, for destinations on a slow network.
+	Staging string `json:"staging,omitempty" yaml:"staging,omitempty" toml:"staging,omitempty"`
+	// Storage off the machine, such as a Nextcloud folder over WebDAV, that a copy of
+	// every backup is uploaded to after it is made.
+	Remote RemoteConfig `json:"remote,omitzero" yaml:"remote,omitempty" toml:"remote,omitempty"`
+	// How often to write, read and delete a probe file in the destinations and the
+	// remote, every hour if 0. A negative interval turns the probes off.
+	HealthCheck Duration `json:"health_check,omitempty" yaml:"health_check,omitempty" toml:"health_check,omitempty"`
+}
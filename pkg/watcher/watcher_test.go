@@ -0,0 +1,741 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cp "github.com/otiai10/copy"
+)
+
+func TestInitialization(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	if watcher.Name != "Test Watcher" {
+		t.Errorf("Expected name 'Test Watcher', got '%s'", watcher.Name)
+	}
+	if watcher.Source != WatcherConfig.Source {
+		t.Errorf("Expected source '%s', got '%s'", WatcherConfig.Source, watcher.Source)
+	}
+	if watcher.Destination != WatcherConfig.Destination {
+		t.Errorf("Expected destination '%s', got '%s'", WatcherConfig.Destination, watcher.Destination)
+	}
+	if watcher.WaitTime != time.Second {
+		t.Errorf("Expected wait time 1s, got %s", watcher.WaitTime)
+	}
+	if watcher.FolderFormat != "2006-01-02_15-04-05.000000" {
+		t.Errorf("Expected folder format '2006-01-02_15-04-05.000000', got '%s'", watcher.FolderFormat)
+	}
+}
+
+func TestDestinationInSource(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+
+	WatcherConfig.Destination = filepath.Join(WatcherConfig.Source, "destination")
+	expectedErrMsg := "destination path cannot be inside the source path"
+	CheckForWatcherError(t, WatcherConfig, expectedErrMsg)
+}
+
+func TestSourceIsDestination(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+
+	WatcherConfig.Destination = WatcherConfig.Source
+	expectedErrMsg := "destination and source paths cannot be the same"
+	CheckForWatcherError(t, WatcherConfig, expectedErrMsg)
+}
+
+func TestSingleFileSource(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+
+	CreateDummyFile(t, WatcherConfig.TempPath, "save.dat", 1024)
+	CreateDummyFile(t, WatcherConfig.TempPath, "other.dat", 1024)
+	WatcherConfig.Source = filepath.Join(WatcherConfig.TempPath, "save.dat")
+
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	if !watcher.isSourceEvent(WatcherConfig.Source) {
+		t.Errorf("Expected events for the source file to be used")
+	}
+	if watcher.isSourceEvent(filepath.Join(WatcherConfig.TempPath, "other.dat")) {
+		t.Errorf("Expected events for other files to be ignored")
+	}
+
+	watcher.CreateBackup()
+	backupPath := watcher.BackupPath(watcher.Metadata[0])
+	if err := CompareFiles(WatcherConfig.Source, filepath.Join(backupPath, "save.dat")); err != nil {
+		t.Fatalf("Backup does not match the source: %v", err)
+	}
+
+	entries, err := os.ReadDir(backupPath)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected only the source file and sidecar in the backup, got %d entries", len(entries))
+	}
+}
+
+func TestDestinationNotDirectory(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+
+	err := os.MkdirAll(filepath.Dir(WatcherConfig.Destination), 0755)
+	if err != nil {
+		t.Fatalf("Failed to create parent directory: %v", err)
+	}
+
+	file, err := os.Create(WatcherConfig.Destination)
+	if err != nil {
+		t.Fatalf("Failed to create destination file: %v", err)
+	}
+	file.Close()
+
+	expectedErrMsg := "destination exists but is not a directory"
+	CheckForWatcherError(t, WatcherConfig, expectedErrMsg)
+
+}
+
+func TestInvalidName(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.Name = ""
+	CheckForWatcherErrorV2(t, WatcherConfig, &ErrorInvalidNameV2, "name cannot be empty")
+
+}
+
+func TestInvalidWaitTime(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.WaitTime = 0
+	CheckForWatcherErrorV2(t, WatcherConfig, &ErrorInvalidWaitTime, "wait time must be at least 0 seconds")
+}
+
+func TestImpreciseFolderFormat(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.FolderFormat = "2006-01-02"
+	CheckForWatcherErrorV2(t, WatcherConfig, &ErrorInvalidFolderFormat, "folder format lacks adequate precision")
+}
+
+func TestInvalidFolderFormat(t *testing.T) {
+	t.Parallel()
+	if os := os.Getenv("OS"); os != "Windows_NT" {
+		t.Skip("Skipping Windows-specific test")
+	}
+
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	// Includes ":" in the format, which is invalid on Windows
+	WatcherConfig.FolderFormat = "2006-01-02_15:04:05.000000"
+	CheckForWatcherErrorV2(t, WatcherConfig, &ErrorInvalidFolderFormat, "invalid name")
+}
+
+func TestInvalidSourceName(t *testing.T) {
+	t.Parallel()
+	if os := os.Getenv("OS"); os != "Windows_NT" {
+		t.Skip("Skipping Windows-specific test")
+	}
+
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.Source = filepath.Join(WatcherConfig.Source, ":?<>")
+	CheckForWatcherErrorV2(t, WatcherConfig, &ErrorInvalidSource, "invalid name:")
+}
+
+func TestInvalidDestinationName(t *testing.T) {
+	t.Parallel()
+	if os := os.Getenv("OS"); os != "Windows_NT" {
+		t.Skip("Skipping Windows-specific test")
+	}
+
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.Destination = filepath.Join(WatcherConfig.Destination, ":?<>")
+	CheckForWatcherErrorV2(t, WatcherConfig, &ErrorInvalidDestination, "invalid name:")
+}
+
+func TestInvalidSettingsAreAllReported(t *testing.T) {
+	t.Parallel()
+	temp := DefaultTempWatcherConfig(t)
+	_, err := NewWatcher(WatcherConfig{
+		Source:       temp.Source,
+		Destination:  temp.Destination,
+		WaitTime:     Duration(temp.WaitTime),
+		FolderFormat: temp.FolderFormat,
+		CopyEngine:   "teleport",
+		Retention:    RetentionPolicy{KeepLast: -1},
+		Restic:       ResticConfig{PasswordFile: "password.txt"},
+		Priority:     "urgent",
+	})
+	if !errors.Is(err, ErrorInvalidNameV2) || !errors.Is(err, ErrorInvalidSettings) {
+		t.Fatalf("Expected name and settings errors, got %v", err)
+	}
+	for _, expected := range []string{"name cannot be empty", "teleport", "negative number of backups", "require a repository", "unknown priority"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected the error to contain %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestInitialBackupWithExistingContent(t *testing.T) {
+	t.Parallel()
+	// This code cannot use getWatcherWithObserver because it starts the watcher with
+	// empty directories.
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	observer := NewSimplifiedObserver()
+
+	watcher.AddObserver(observer)
+
+	for i := range 5 {
+		CreateDummyFile(t, WatcherConfig.Source, fmt.Sprintf("file%d.txt", i), 1024)
+		CreateDummyFile(t, WatcherConfig.Source, fmt.Sprintf("subfolder%d/file%d.txt", i, i), 1024)
+		CreateDummyFile(t, WatcherConfig.Source, fmt.Sprintf("subfolder%d/subsubfolder%d/file%d.txt", i, i, i), 1024)
+	}
+
+	err = watcher.StartWatcher()
+	if err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion")
+	}
+
+	backupPath := filepath.Join(WatcherConfig.Destination, watcher.Metadata[0].Path)
+	CompareSourceAndDestination(t, WatcherConfig.Source, backupPath)
+
+	// Make sure an additional backup is not accidentally created after the initial
+	// backup.
+	// TODO: The value tested here should be 1, it is 2 to make sure that the current
+	// functionality is not changed.
+	if !observer.WaitUntilCount(2, 10*time.Second) {
+		t.Fatalf("Timeout waiting for the second backup")
+	}
+	if count := observer.getCurrentCount(); count != 2 {
+		t.Fatalf("Expected 1 backup, got %d", count)
+	}
+
+	// Watcher needs to be stopped manually because this tests cannot use
+	// getWatcherWithObserver.
+	watcher.StopWatcher()
+	if err := watcher.StopWatcher(); err != nil {
+		t.Fatalf("Failed to stop watcher: %v", err)
+	}
+}
+
+func TestEmptyInitialBackup(t *testing.T) {
+	t.Parallel()
+	WatcherConfig, watcher, _ := getWatcherWithObserver(t)
+	backupPath := filepath.Join(WatcherConfig.Destination, watcher.Metadata[0].Path)
+	CompareSourceAndDestination(t, WatcherConfig.Source, backupPath)
+}
+
+func TestAddingMultipleGroupedFiles(t *testing.T) {
+	t.Parallel()
+	WatcherConfig, watcher, observer := getWatcherWithObserver(t)
+
+	numberOfLoops := 5
+	for i := range numberOfLoops {
+		// Offset the number by the number of loops to ensure unique file names
+		i2 := i + numberOfLoops
+
+		CreateDummyFile(t, WatcherConfig.Source, fmt.Sprintf("file%d.txt", i), 1024)
+		CreateDummyFile(t, WatcherConfig.Source, fmt.Sprintf("file%d.txt", i2), 1024)
+
+		// Wait for the new files to be backed up
+		if !observer.WaitUntilCount(i+1, 10*time.Second) {
+			t.Fatalf("Timeout waiting for backup completion")
+		}
+
+		backupPath := filepath.Join(WatcherConfig.Destination, watcher.Metadata[i+1].Path)
+
+		CompareSourceAndDestination(t, WatcherConfig.Source, backupPath)
+	}
+}
+func TestAddingFilesSlowly(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	clock := NewFakeClock(time.Now())
+	watcher, err := NewTempWatcher(WatcherConfig, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	observer := NewSimplifiedObserver()
+	watcher.AddObserver(observer)
+	if err := watcher.StartWatcher(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	t.Cleanup(func() { watcher.StopWatcher() })
+
+	// The initial backup waits for the wait time like any other change.
+	clock.BlockUntil(1)
+	clock.Advance(WatcherConfig.WaitTime)
+	if !observer.WaitUntilCount(1, 5*time.Second) {
+		t.Fatalf("Timeout waiting for the initial backup")
+	}
+	observer.CurrentCount = 0
+	changes := func() int {
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+		return watcher.stats.Changes
+	}
+
+	// Each file is added before the changes have settled, so they are backed up
+	// together.
+	for i := range 5 {
+		before := changes()
+		CreateDummyFile(t, WatcherConfig.Source, fmt.Sprintf("file%d.txt", i), 1024)
+		waitUntil(t, "Timeout waiting for the file to be seen", func() bool { return changes() > before })
+		waitForRequest(watcher)
+		clock.BlockUntil(1)
+		clock.Advance(WatcherConfig.WaitTime / 2)
+	}
+	clock.Advance(WatcherConfig.WaitTime)
+
+	if !observer.WaitUntilCount(1, 10*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion")
+	}
+
+	backupPath := filepath.Join(WatcherConfig.Destination, watcher.Metadata[1].Path)
+
+	CompareSourceAndDestination(t, WatcherConfig.Source, backupPath)
+}
+
+func TestAddFileDuringBackups(t *testing.T) {
+	t.Parallel()
+	WatcherConfig, watcher, observer := getWatcherWithObserver(t)
+	testPath := filepath.Dir(WatcherConfig.Source)
+	tempFolderPath := filepath.Join(testPath, "temp")
+
+	// Create a large file in a temporary folder. This is not in the source folder
+	// because it is used later on to make sure the first backup matches the source at
+	// the time that the backup started.
+	CreateDummyFile(t, tempFolderPath, "file1.txt", 1024*1024*1024)
+
+	// Copy the large file to the source folder
+	if err := cp.Copy(tempFolderPath, WatcherConfig.Source, cp.Options{PreserveTimes: true}); err != nil {
+		t.Fatalf("Failed to copy source file to temp file: %v", err)
+	}
+
+	// Wait for the backup to start
+	waitUntil(t, "Timeout waiting for the backup to start", func() bool {
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+		return watcher.activeBackupPath != ""
+	})
+
+	// Create another file to can a second backup to trigger immediately after the first
+	// one completes.
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 1024)
+
+	// Make sure the first backup is still running after the file was copied.
+	if !observer.WaitUntilCount(0, 10*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion")
+	}
+
+	// Wait for the first backup to complete
+	if !observer.WaitUntilCount(1, 10*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion")
+	}
+
+	// Check that the first backup has just a single file
+	backupPath := filepath.Join(WatcherConfig.Destination, watcher.Metadata[1].Path)
+	CompareSourceAndDestination(t, tempFolderPath, backupPath)
+
+	// Wait for the second backup to complete
+	if !observer.WaitUntilCount(2, 10*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion")
+	}
+
+	backupPath = filepath.Join(WatcherConfig.Destination, watcher.Metadata[2].Path)
+	CompareSourceAndDestination(t, WatcherConfig.Source, backupPath)
+}
+
+func TestAddingFilesInNewSubfolder(t *testing.T) {
+	t.Parallel()
+	WatcherConfig, watcher, observer := getWatcherWithObserver(t)
+
+	CreateDummyFile(t, WatcherConfig.Source, "subfolder/file1.txt", 1024)
+	if !observer.WaitUntilCount(1, 10*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion")
+	}
+
+	backupPath := filepath.Join(WatcherConfig.Destination, watcher.Metadata[1].Path)
+	CompareSourceAndDestination(t, WatcherConfig.Source, backupPath)
+}
+
+func TestAddingFilesInExistingSubfolder(t *testing.T) {
+	t.Parallel()
+	WatcherConfig, watcher, observer := getWatcherWithObserver(t)
+
+	// Create a subfolder in the source directory
+	CreateDummyFile(t, WatcherConfig.Source, "subfolder/file1.txt", 1024)
+
+	// Wait for completion
+	if !observer.WaitUntilCount(1, 10*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion")
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "subfolder/file2.txt", 1024)
+
+	// Wait for completion
+	if !observer.WaitUntilCount(2, 10*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion")
+	}
+
+	backupPath := filepath.Join(WatcherConfig.Destination, watcher.Metadata[2].Path)
+	CompareSourceAndDestination(t, WatcherConfig.Source, backupPath)
+
+}
+func TestAddingEmptyFolder(t *testing.T) {
+	t.Parallel()
+	WatcherConfig, watcher, observer := getWatcherWithObserver(t)
+
+	// Create a subfolder in the source directory
+	subFolder := filepath.Join(WatcherConfig.Source, "subfolder")
+	err := os.MkdirAll(subFolder, 0755)
+	if err != nil {
+		t.Fatalf("Failed to create subfolder: %v", err)
+	}
+
+	// Wait for completion
+	if !observer.WaitUntilCount(1, 10*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion")
+	}
+
+	backupPath := filepath.Join(WatcherConfig.Destination, watcher.Metadata[1].Path)
+	CompareSourceAndDestination(t, WatcherConfig.Source, backupPath)
+}
+
+func TestFilesChangeWhileWatcherIsOff(t *testing.T) {
+	t.Parallel()
+	WatcherConfig, watcher, observer := getWatcherWithObserver(t)
+
+	CreateDummyFile(t, WatcherConfig.Source, "file.txt", 1024)
+
+	if !observer.WaitUntilCount(1, 10*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion")
+	}
+
+	watcher.StopWatcher()
+
+	CreateDummyFile(t, WatcherConfig.Source, "file2.txt", 1024)
+
+	watcher.StartWatcher()
+	if !observer.WaitUntilCount(2, 10*time.Second) {
+		t.Fatalf("Timeout waiting for backup completion, expected 2 backups, got %d", observer.CurrentCount)
+	}
+
+	backupPath := filepath.Join(WatcherConfig.Destination, watcher.Metadata[2].Path)
+	CompareSourceAndDestination(t, WatcherConfig.Source, backupPath)
+}
+
+func TestRotatingDestinations(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	secondDestination := filepath.Join(WatcherConfig.TempPath, "destination2")
+	WatcherConfig.RotationDestinations = []string{secondDestination}
+
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	for i := range 3 {
+		CreateDummyFile(t, WatcherConfig.Source, fmt.Sprintf("file%d.txt", i), 1024)
+		watcher.CreateBackup()
+	}
+
+	expected := []string{WatcherConfig.Destination, secondDestination, WatcherConfig.Destination}
+	for i, backup := range watcher.Metadata {
+		if backup.Destination != expected[i] {
+			t.Errorf("Expected backup %d in '%s', got '%s'", i, expected[i], backup.Destination)
+		}
+	}
+	CompareSourceAndDestination(t, WatcherConfig.Source, watcher.BackupPath(watcher.Metadata[2]))
+
+	// A new watcher should list the backups from both destinations.
+	reloaded, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if backups := reloaded.ListBackups(); len(backups) != 3 {
+		t.Errorf("Expected 3 backups, got %d", len(backups))
+	}
+}
+
+func TestDuplicateRotationDestination(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.RotationDestinations = []string{WatcherConfig.Destination}
+	CheckForWatcherErrorV3(t, WatcherConfig, ErrorInvalidDestination, "used as a destination more than once")
+}
+
+func TestSelfTest(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	result := watcher.SelfTest()
+	if !result.Passed {
+		t.Fatalf("Expected the self test to pass, got %+v", result)
+	}
+
+	// The probe files should be cleaned up.
+	CompareSourceAndDestination(t, WatcherConfig.Source, WatcherConfig.Destination)
+}
+
+func TestReadOnlyBackups(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.ReadOnlyBackups = true
+
+	CreateDummyFile(t, WatcherConfig.Source, "subfolder/file.txt", 1024)
+	watcher.CreateBackup()
+	backup := watcher.Metadata[0]
+	backupFile := filepath.Join(watcher.BackupPath(backup), "subfolder", "file.txt")
+
+	info, err := os.Stat(backupFile)
+	if err != nil {
+		t.Fatalf("Failed to stat backup file: %v", err)
+	}
+	if info.Mode().Perm()&0222 != 0 {
+		t.Errorf("Expected backup file to be read-only, got %v", info.Mode())
+	}
+
+	// The protection is lifted while working with the backup.
+	err = watcher.withUnprotectedBackup(backup, func() error {
+		info, err := os.Stat(backupFile)
+		if err != nil {
+			return err
+		}
+		if info.Mode().Perm()&0200 == 0 {
+			t.Errorf("Expected backup file to be writable, got %v", info.Mode())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to unprotect backup: %v", err)
+	}
+
+	// Remove the protection so the temporary directory can be cleaned up.
+	if err := unprotectBackup(watcher.BackupPath(backup)); err != nil {
+		t.Fatalf("Failed to unprotect backup: %v", err)
+	}
+}
+
+func TestNestedFolderFormat(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.FolderFormat = "2006/01/2006-01-02_15-04-05.000000"
+
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	CreateDummyFile(t, WatcherConfig.Source, "file.txt", 1024)
+	watcher.CreateBackup()
+
+	if len(watcher.Metadata) != 1 {
+		t.Fatalf("Expected 1 backup, got %d", len(watcher.Metadata))
+	}
+	backup := watcher.Metadata[0]
+	timestamp := time.Unix(int64(backup.Timestamp), 0)
+	expectedParent := filepath.Join(WatcherConfig.Destination, timestamp.Format("2006"), timestamp.Format("01"))
+	if filepath.Dir(watcher.BackupPath(backup)) != expectedParent {
+		t.Errorf("Expected backup inside '%s', got '%s'", expectedParent, watcher.BackupPath(backup))
+	}
+	CompareSourceAndDestination(t, WatcherConfig.Source, watcher.BackupPath(backup))
+
+	// The nested path should be read back from the metadata.
+	reloaded, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	if backups := reloaded.ListBackups(); len(backups) != 1 || backups[0].Path != backup.Path {
+		t.Errorf("Expected reloaded backup '%s', got %+v", backup.Path, backups)
+	}
+}
+
+func TestFolderFormatOutsideDestination(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.FolderFormat = "../2006-01-02_15-04-05.000000"
+	CheckForWatcherErrorV2(t, WatcherConfig, &ErrorInvalidFolderFormat, "folder format must be a relative path")
+}
+
+func TestFolderFormatValidationLeavesNoFolders(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	WatcherConfig.FolderFormat = "format-check-2006/backup-{seq}"
+	if _, err := NewTempWatcher(WatcherConfig); err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	for _, path := range []string{"format-check-2006", "format-check-1970"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			os.RemoveAll(path)
+			t.Errorf("Expected validating the folder format not to create %s", path)
+		}
+	}
+}
+
+// reentrantObserver calls back into the watcher and then panics.
+type reentrantObserver struct {
+	backups int
+}
+
+func (o *reentrantObserver) OnBackupCompletion(watcher *Watcher) {
+	o.backups = len(watcher.ListBackups())
+	panic("observer failed")
+}
+
+func TestMisbehavingObserver(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	reentrant := &reentrantObserver{}
+	observer := NewSimplifiedObserver()
+	watcher.AddObserver(reentrant)
+	watcher.AddObserver(observer)
+
+	done := make(chan struct{})
+	go func() {
+		watcher.CreateBackup()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for the backup, an observer deadlocked the watcher")
+	}
+	watcher.flushObservers()
+
+	if reentrant.backups != 1 {
+		t.Errorf("Expected the observer to see 1 backup, got %d", reentrant.backups)
+	}
+	// The panic does not stop the observers after it from being notified.
+	if observer.getCurrentCount() != 1 {
+		t.Errorf("Expected the second observer to be notified")
+	}
+}
+
+// blockingObserver waits until it is released before returning.
+type blockingObserver struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (o *blockingObserver) OnBackupCompletion(watcher *Watcher) {
+	o.started <- struct{}{}
+	<-o.release
+}
+
+func TestSlowObserver(t *testing.T) {
+	t.Parallel()
+	WatcherConfig := DefaultTempWatcherConfig(t)
+	watcher, err := NewTempWatcher(WatcherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.ObserverQueue = ObserverQueueConfig{Size: 2, Policy: ObserverQueueDrop}
+	slow := &blockingObserver{started: make(chan struct{}, 1), release: make(chan struct{})}
+	observer := NewSimplifiedObserver()
+	watcher.AddObserver(slow)
+	watcher.AddObserver(observer)
+
+	CreateDummyFile(t, WatcherConfig.Source, "file0.txt", 1024)
+	watcher.CreateBackup()
+	<-slow.started
+
+	// The first observer is still handling the first backup. The queue fills up with the
+	// second observer's notification and the next one, the rest are dropped.
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i < 3; i++ {
+			CreateDummyFile(t, WatcherConfig.Source, fmt.Sprintf("file%d.txt", i), 1024)
+			watcher.CreateBackup()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for backups, a slow observer delayed them")
+	}
+
+	close(slow.release)
+	watcher.flushObservers()
+	if len(watcher.ListBackups()) != 3 {
+		t.Errorf("Expected 3 backups, got %d", len(watcher.ListBackups()))
+	}
+	if count := observer.getCurrentCount(); count != 1 {
+		t.Errorf("Expected the queued notification to be delivered and the rest dropped, got %d", count)
+	}
+}
+
+func TestFlushWhileQueueing(t *testing.T) {
+	t.Parallel()
+	queue := newObserverQueue(ObserverQueueConfig{})
+	var mu sync.Mutex
+	delivered := 0
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				queue.push(func() {
+					mu.Lock()
+					delivered++
+					mu.Unlock()
+				})
+				queue.flush()
+			}
+		}()
+	}
+	wg.Wait()
+	queue.flush()
+	if delivered != 800 {
+		t.Errorf("Expected every notification to be delivered, got %d", delivered)
+	}
+}
+
+// TODO:
+// Test replacing the entire source directory with a new one to see what happpens to the
+// recursive watcher .
+// Test removing the entire source directory to see what happpens to the recursive
+// watcher .
+// Test renaming the source directory to see what happpens to the recursive watcher.
+// Test starting an existing watcher after it has been started
+// Test stopping an existing watcher
+// Test stopping an existing watcher after it has been stopped
+// Test restarting an existing watcher after it has been stopped
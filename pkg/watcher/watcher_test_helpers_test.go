@@ -1,6 +1,6 @@
 // Tests for the helper test functions
 
-package main
+package watcher
 
 import (
 	"os"
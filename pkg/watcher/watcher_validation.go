@@ -0,0 +1,271 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrorInvalidNameV2 = fmt.Errorf("error validating name")
+var ErrorInvalidWaitTime = fmt.Errorf("error validating wait time")
+var ErrorInvalidSource = fmt.Errorf("error validating source")
+var ErrorInvalidDestination = fmt.Errorf("error validating destination")
+var ErrorInvalidFolderFormat = fmt.Errorf("error validating folder format")
+var ErrorInvalidSettings = fmt.Errorf("error validating settings")
+
+func validateName(name string, errs *error) {
+	if name == "" {
+		*errs = errors.Join(*errs, fmt.Errorf("%w: name cannot be empty", ErrorInvalidNameV2))
+	}
+}
+
+func validateWaitTime(waitTime time.Duration, errs *error) {
+	if waitTime <= 0 {
+		*errs = errors.Join(*errs, fmt.Errorf("%w: wait time must be at least 0 seconds", ErrorInvalidWaitTime))
+	}
+}
+
+// validateSettings checks the settings of a folder pair other than its folders and
+// returns the copy engine they describe, the go copy engine is returned if they are
+// invalid.
+func validateSettings(config *WatcherConfig, singleFile bool, errs *error) CopyEngine {
+	invalid := *errs != nil
+	add := func(err error) {
+		if err != nil {
+			*errs = errors.Join(*errs, fmt.Errorf("%w: %w", ErrorInvalidSettings, err))
+			invalid = true
+		}
+	}
+	add(config.ObserverQueue.validate())
+	add(config.Debounce.validate())
+	add(config.HashAlgorithm.validate())
+	add(validateSigning(config))
+	add(config.TimeZone.validate())
+	add(config.Defer.validate())
+	for _, window := range config.QuietHours {
+		add(window.validate())
+	}
+	add(config.Retention.validate())
+	add(config.Restic.validate())
+	add(validateSecretReferences(config))
+	add(validateSnapshotMode(config, singleFile))
+	add(config.Placeholders.validate())
+	add(config.Copy.validate())
+	add(config.Chunking.validate())
+	add(config.OpenFiles.validate())
+	add(validateIncludePatterns(config, singleFile))
+	add(config.Stability.validate())
+	add(config.ProcessTrigger.validate())
+	add(config.IdleTrigger.validate())
+	add(config.Permissions.validate())
+	add(config.Priority.validate())
+	add(config.FreeSpace.validate())
+	if config.MaxStaleness < 0 {
+		add(fmt.Errorf("max staleness must be at least 0 seconds"))
+	}
+	add(config.RestoreDrill.validate())
+	add(config.MassChange.validate())
+	add(config.Entropy.validate())
+	add(config.Canaries.validate(singleFile))
+	add(validateStaging(config))
+	add(validateRemote(config))
+	add(validateChunking(config))
+
+	copyEngine, err := newCopyEngine(config.CopyEngine)
+	add(err)
+	if invalid {
+		return goCopyEngine{}
+	}
+	// The policies wrap the copy engine, so they are applied once everything is valid.
+	for _, apply := range []func(CopyEngine) (CopyEngine, error){config.Placeholders.apply, config.Include.apply, config.Permissions.apply, config.Copy.apply, config.Chunking.apply} {
+		if copyEngine, err = apply(copyEngine); err != nil {
+			add(err)
+			return goCopyEngine{}
+		}
+	}
+	return copyEngine
+}
+
+// Validate the folder format.
+// Make sure that file names cannot overlap.
+// Make sure the format is supported by the filesystem.
+// Nested folders are allowed but must stay inside the destination.
+func validateFolderFormat(waitTime time.Duration, folderFormat string, errs *error) {
+	for _, part := range strings.Split(filepath.ToSlash(folderFormat), "/") {
+		if part == "" || part == "." || part == ".." || filepath.VolumeName(part) != "" {
+			err := fmt.Errorf("%w: folder format must be a relative path without empty, '.' or '..' folders", ErrorInvalidFolderFormat)
+			*errs = errors.Join(*errs, err)
+			return
+		}
+	}
+	switch strings.Split(filepath.ToSlash(folderFormat), "/")[0] {
+	case stateDirName:
+		err := fmt.Errorf("%w: %s is used for the metadata of the watcher", ErrorInvalidFolderFormat, stateDirName)
+		*errs = errors.Join(*errs, err)
+		return
+	case trashDirName:
+		err := fmt.Errorf("%w: %s is used for the trash of the watcher", ErrorInvalidFolderFormat, trashDirName)
+		*errs = errors.Join(*errs, err)
+		return
+	}
+
+	// The name is created in a temporary folder to check that the filesystem supports
+	// it, so nothing is left behind.
+	tempPath, err := os.MkdirTemp("", "i-saw-that-format-*")
+	if err != nil {
+		*errs = errors.Join(*errs, fmt.Errorf("error checking folder format: %w", err))
+	} else {
+		validateDir(filepath.Join(tempPath, formatBackupFolder(folderFormat, time.Unix(0, 0), 1)), ErrorInvalidFolderFormat, errs)
+		os.RemoveAll(tempPath)
+	}
+
+	// The sequence number is unique on its own.
+	if strings.Contains(folderFormat, sequenceToken) {
+		return
+	}
+
+	// Attempt to create two different times exactly one waitTime apart and make sure
+	// that the names are different to avoid potential collisions
+	format1 := time.Unix(0, 0).Format(folderFormat)
+	format2 := time.Unix(0, 0).Add(waitTime).Format(folderFormat)
+	if format1 == format2 {
+		err := fmt.Errorf("%w: folder format lacks adequate precision for wait time", ErrorInvalidFolderFormat)
+		*errs = errors.Join(*errs, err)
+	}
+}
+
+// Validate a path is a directory.
+// The path must be supported by the filesystem.
+// The path must not be a file.
+// If the path does not exist, it will be created.
+func validateDir(path string, invalidNameError error, errs *error) {
+	var pathErr *os.PathError
+
+	info, err := os.Stat(path)
+
+	// errors.As(err, &pathErr) returns true if the file does not exist, so it must be
+	// checked after checking if the file exists
+	// os.IsNotExist(err) returns false if the name is invalid so it can be checked
+	// before checking if the name is invalid
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			*errs = errors.Join(*errs, err)
+		}
+	} else if errors.As(err, &pathErr) {
+		*errs = errors.Join(*errs, fmt.Errorf("%w: invalid name: %w", invalidNameError, err))
+	} else if err == nil && !info.IsDir() {
+		*errs = errors.Join(*errs, fmt.Errorf("%w: %s exists but is not a directory", invalidNameError, path))
+	} else if err != nil {
+		*errs = errors.Join(*errs, fmt.Errorf("%w: %w", invalidNameError, err))
+	}
+}
+
+// TODO: Deprecate
+func validateDirOld(path string, invalidNameError error) error {
+	var errs error
+	var pathErr *os.PathError
+
+	if err := validateSharePath(path); err != nil {
+		return fmt.Errorf("%w: %w", invalidNameError, err)
+	}
+	// A share that dropped is connected again before it is checked.
+	_, onShare := SharePath(path)
+	info, err := reachShare(OSKeychain(), path)
+
+	// errors.As(err, &pathErr) returns true if the file does not exist, so it must be
+	// checked after checking if the file exists
+	// os.IsNotExist(err) returns false if the name is invalid so it can be checked
+	// before checking if the name is invalid
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	} else if onShare && err != nil {
+		errs = errors.Join(errs, fmt.Errorf("%w: %w", invalidNameError, err))
+	} else if errors.As(err, &pathErr) {
+		errs = errors.Join(errs, fmt.Errorf("%w: invalid name: %w", invalidNameError, err))
+	} else if err == nil && !info.IsDir() {
+		errs = errors.Join(errs, fmt.Errorf("%w: %s exists but is not a directory", invalidNameError, path))
+	} else if err != nil {
+		errs = errors.Join(errs, fmt.Errorf("%w: %w", invalidNameError, err))
+	}
+
+	return errs
+}
+
+// Validate source and destination directories.
+// The values rely on one another so both must be validated at the same time.
+// The paths must be supported by the filesystem.
+// The source may be a single file, the destination must not be a file.
+// If the paths do not exist, they will be created as directories.
+// The paths must not be the same.
+// The destination must not be inside the source.
+func validateSourceAndDestination(source string, destination string, errs *error) {
+	// Generic directory validation, a source that is a single file is allowed
+	if !isRegularFile(source) {
+		*errs = errors.Join(*errs, validateDirOld(source, ErrorInvalidSource))
+	}
+	*errs = errors.Join(*errs, validateDirOld(destination, ErrorInvalidDestination))
+
+	// Get absolute paths so validation cannot be bypassed by using relative paths
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		*errs = errors.Join(*errs, fmt.Errorf("%w: error getting absolute path: %w", ErrorInvalidSource, err))
+	}
+	absDest, err := filepath.Abs(destination)
+	if err != nil {
+		err = fmt.Errorf("%w: error getting absolute path: %w", ErrorInvalidDestination, err)
+		*errs = errors.Join(*errs, err)
+	}
+
+	// Make sure source and destination are different
+	if absSource == absDest {
+		err = fmt.Errorf("%w: source and destination paths cannot be the same", ErrorInvalidSource)
+		*errs = errors.Join(*errs, err)
+		err = fmt.Errorf("%w: destination and source paths cannot be the same", ErrorInvalidDestination)
+		*errs = errors.Join(*errs, err)
+	}
+
+	// Make sure destination is not inside of source
+	relPath, err := filepath.Rel(absSource, absDest)
+	if err != nil {
+		err := fmt.Errorf("%w: error checking relative path from source to destination: %w", ErrorInvalidDestination, err)
+		*errs = errors.Join(*errs, err)
+	}
+	if !filepath.IsAbs(relPath) && !strings.HasPrefix(relPath, "..") && relPath != "." {
+		err := fmt.Errorf("%w: destination path cannot be inside the source path", ErrorInvalidDestination)
+		*errs = errors.Join(*errs, err)
+	}
+}
+
+// Validate the destinations that backups rotate between.
+// Each destination must pass the same checks as the primary destination.
+// The destinations must all be different.
+func validateRotationDestinations(source string, destination string, rotationDestinations []string, errs *error) {
+	seen := map[string]bool{}
+	if absDest, err := filepath.Abs(destination); err == nil {
+		seen[absDest] = true
+	}
+
+	for _, rotationDestination := range rotationDestinations {
+		validateSourceAndDestination(source, rotationDestination, errs)
+
+		absRotation, err := filepath.Abs(rotationDestination)
+		if err != nil {
+			continue
+		}
+		if seen[absRotation] {
+			err := fmt.Errorf("%w: %s is used as a destination more than once", ErrorInvalidDestination, rotationDestination)
+			*errs = errors.Join(*errs, err)
+		}
+		seen[absRotation] = true
+	}
+}
+
+func isRegularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,82 @@
+// Package watchertest creates watchers in temporary folders for the tests of packages
+// that use the watcher.
+package watchertest
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+)
+
+// TempWatcherConfig holds configuration for creating a watcher
+type TempWatcherConfig struct {
+	Name         string
+	Source       string
+	Destination  string
+	TempPath     string
+	WaitTime     time.Duration
+	FolderFormat string
+	Enabled      bool
+}
+
+// DefaultTempWatcherConfig returns a configuration in a temporary folder that is
+// removed when the test ends.
+func DefaultTempWatcherConfig(t *testing.T) TempWatcherConfig {
+	t.Helper()
+	tempPath := t.TempDir()
+	return TempWatcherConfig{
+		Name:         "Test Watcher",
+		TempPath:     tempPath,
+		Source:       filepath.Join(tempPath, "source"),
+		Destination:  filepath.Join(tempPath, "destination"),
+		WaitTime:     time.Second,
+		FolderFormat: "2006-01-02_15-04-05.000000",
+		Enabled:      true,
+	}
+}
+
+// NewTempWatcher creates a watcher for config.
+func NewTempWatcher(config TempWatcherConfig, options ...watcher.Option) (*watcher.Watcher, error) {
+	return watcher.NewWatcher(watcher.WatcherConfig{
+		ID:           config.Name,
+		Source:       config.Source,
+		Destination:  config.Destination,
+		WaitTime:     watcher.Duration(config.WaitTime),
+		FolderFormat: config.FolderFormat,
+	}, options...)
+}
+
+// CreateDummyFile writes fileSize random bytes to filePath in directoryPath, creating
+// the folders it is in.
+func CreateDummyFile(t *testing.T, directoryPath string, filePath string, fileSize int) {
+	t.Helper()
+	fullPath := filepath.Join(directoryPath, filePath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	content := make([]byte, fileSize)
+	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	for i := range content {
+		content[i] = charset[rand.Intn(len(charset))]
+	}
+	if err := os.WriteFile(fullPath, content, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
+// WaitUntil waits for condition to be true, failing the test with message if it is not
+// within 10 seconds.
+func WaitUntil(t *testing.T, message string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
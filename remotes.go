@@ -0,0 +1,28 @@
+package main
+
+import "fmt"
+
+// AuthorizeRemote signs in to the Google Drive or OneDrive account a watcher copies its
+// backups to, in the browser, and saves the token in the keychain.
+func (a *App) AuthorizeRemote(id string) error {
+	if a.remote != nil {
+		return a.remote.call("AuthorizeRemote", nil, id)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return fmt.Errorf("watcher not running")
+	}
+	return w.AuthorizeRemote(openInBrowser)
+}
+
+// ForgetRemote removes the token of the remote of a watcher from the keychain.
+func (a *App) ForgetRemote(id string) error {
+	if a.remote != nil {
+		return a.remote.call("ForgetRemote", nil, id)
+	}
+	w, exists := a.runningWatcher(id)
+	if !exists {
+		return fmt.Errorf("watcher not running")
+	}
+	return w.ForgetRemote()
+}
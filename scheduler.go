@@ -0,0 +1,74 @@
+package main
+
+import (
+	"slices"
+	"sync"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+)
+
+// Backups copied at the same time across every watcher of the app when the config does
+// not set max_concurrent_backups.
+const defaultMaxConcurrentBackups = 2
+
+// backupScheduler limits how many watchers of the app copy at the same time, so many
+// watchers that are triggered together do not all compete for the disk. The other
+// watchers wait until a backup finishes, those with a higher priority go first.
+type backupScheduler struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	// Ordered by priority and then by when they started waiting.
+	waiting []*scheduledBackup
+}
+
+// scheduledBackup is a backup waiting for a slot, ready is closed once it has one.
+type scheduledBackup struct {
+	rank  int
+	ready chan struct{}
+}
+
+// newBackupScheduler creates a scheduler that runs up to limit backups at the same
+// time, the default is used if limit is not positive.
+func newBackupScheduler(limit int) *backupScheduler {
+	if limit <= 0 {
+		limit = defaultMaxConcurrentBackups
+	}
+	return &backupScheduler{limit: limit}
+}
+
+func (s *backupScheduler) Acquire(w *watcher.Watcher) func() {
+	s.mu.Lock()
+	if s.running < s.limit {
+		s.running++
+		s.mu.Unlock()
+		return s.release
+	}
+	backup := &scheduledBackup{rank: w.Priority.Rank(), ready: make(chan struct{})}
+	// Behind every waiting backup with the same or a higher priority.
+	i := slices.IndexFunc(s.waiting, func(other *scheduledBackup) bool {
+		return other.rank < backup.rank
+	})
+	if i == -1 {
+		i = len(s.waiting)
+	}
+	s.waiting = slices.Insert(s.waiting, i, backup)
+	s.mu.Unlock()
+
+	watcher.Logf(w.Name, watcher.LogLevelInfo, "Waiting for other backups to finish")
+	<-backup.ready
+	return s.release
+}
+
+// release hands the slot of a finished backup to the first waiting backup.
+func (s *backupScheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.waiting) == 0 {
+		s.running--
+		return
+	}
+	next := s.waiting[0]
+	s.waiting = s.waiting[1:]
+	close(next.ready)
+}
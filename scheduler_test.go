@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+	"ryn-cx/i-saw-that/pkg/watcher/watchertest"
+)
+
+func TestBackupSchedulerLimitsConcurrentBackups(t *testing.T) {
+	t.Parallel()
+	scheduler := newBackupScheduler(2)
+	w := &watcher.Watcher{Name: "Test Watcher"}
+
+	var running, most atomic.Int32
+	var wg sync.WaitGroup
+	// The backups that get a slot hold it until every other backup is waiting.
+	finish := make(chan struct{})
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := scheduler.Acquire(w)
+			defer release()
+			current := running.Add(1)
+			for {
+				previous := most.Load()
+				if current <= previous || most.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			<-finish
+			running.Add(-1)
+		}()
+	}
+	waitForWaiting(t, scheduler, 4)
+	close(finish)
+	wg.Wait()
+
+	if most.Load() != 2 {
+		t.Errorf("Expected at most 2 backups to run at the same time, got %d", most.Load())
+	}
+	if newBackupScheduler(0).limit != defaultMaxConcurrentBackups {
+		t.Errorf("Expected the default limit when none is set")
+	}
+}
+
+func TestBackupSchedulerRunsHigherPrioritiesFirst(t *testing.T) {
+	t.Parallel()
+	scheduler := newBackupScheduler(1)
+	release := scheduler.Acquire(&watcher.Watcher{Name: "running"})
+
+	var mu sync.Mutex
+	order := []string{}
+	var wg sync.WaitGroup
+	for i, priority := range []watcher.Priority{watcher.PriorityLow, "", watcher.PriorityHigh, watcher.PriorityNormal} {
+		w := &watcher.Watcher{Name: fmt.Sprintf("%d %s", i, priority), Priority: priority}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := scheduler.Acquire(w)
+			mu.Lock()
+			order = append(order, w.Name)
+			mu.Unlock()
+			release()
+		}()
+		// Each backup starts waiting before the next one so their order is known.
+		waitForWaiting(t, scheduler, i+1)
+	}
+	release()
+	wg.Wait()
+
+	expected := []string{"2 high", "1 ", "3 normal", "0 low"}
+	if !slices.Equal(order, expected) {
+		t.Errorf("Expected the backups to run in the order %q, got %q", expected, order)
+	}
+	if scheduler.running != 0 {
+		t.Errorf("Expected every slot to be released, %d are still taken", scheduler.running)
+	}
+}
+
+// waitForWaiting waits until n backups are waiting for a slot of the scheduler.
+func waitForWaiting(t *testing.T, scheduler *backupScheduler, n int) {
+	t.Helper()
+	watchertest.WaitUntil(t, fmt.Sprintf("Expected %d backups to be waiting", n), func() bool {
+		scheduler.mu.Lock()
+		defer scheduler.mu.Unlock()
+		return len(scheduler.waiting) == n
+	})
+}
@@ -0,0 +1,20 @@
+package main
+
+import "ryn-cx/i-saw-that/pkg/watcher"
+
+// SaveSecret stores a secret in the keychain of the OS, settings refer to it as
+// "keychain:<name>".
+func (a *App) SaveSecret(name, secret string) error {
+	if a.remote != nil {
+		return a.remote.call("SaveSecret", nil, name, secret)
+	}
+	return watcher.NewSecrets(watcher.OSKeychain()).Set(name, secret)
+}
+
+// DeleteSecret removes a secret from the keychain of the OS.
+func (a *App) DeleteSecret(name string) error {
+	if a.remote != nil {
+		return a.remote.call("DeleteSecret", nil, name)
+	}
+	return watcher.NewSecrets(watcher.OSKeychain()).Delete(name)
+}
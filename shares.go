@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+)
+
+// SaveShareCredentials stores the user and password a network share is connected with
+// in the keychain of the OS and connects the share with them.
+func (a *App) SaveShareCredentials(share, user, password string) error {
+	if a.remote != nil {
+		return a.remote.call("SaveShareCredentials", nil, share, user, password)
+	}
+	keychain := watcher.OSKeychain()
+	credentials := watcher.ShareCredentials{User: user, Password: password}
+	if err := watcher.SaveShareCredentials(keychain, share, credentials); err != nil {
+		return err
+	}
+	// Shares are only connected by the app on Windows, elsewhere they are mounted.
+	if err := watcher.ConnectShare(keychain, share); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		return err
+	}
+	return nil
+}
+
+// ForgetShareCredentials removes the credentials of a network share from the keychain.
+func (a *App) ForgetShareCredentials(share string) error {
+	if a.remote != nil {
+		return a.remote.call("ForgetShareCredentials", nil, share)
+	}
+	return watcher.DeleteShareCredentials(watcher.OSKeychain(), share)
+}
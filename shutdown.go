@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+)
+
+// Name of the file in the data directory that records whether the app shut down
+// cleanly.
+const sessionFileName = "session.json"
+
+// Session is written when the app starts and rewritten when it shuts down. If the app
+// starts and the previous session is not marked as clean the app crashed or was killed.
+type Session struct {
+	Started time.Time `json:"started"`
+	Stopped time.Time `json:"stopped,omitzero"`
+	Clean   bool      `json:"clean"`
+	// Folder pairs that had changes waiting to be backed up when the app shut down.
+	Pending []string `json:"pending,omitempty"`
+}
+
+func sessionPath(dataDir string) string {
+	return filepath.Join(dataDir, sessionFileName)
+}
+
+// readSession reads the session of the previous run, false is returned if there was no
+// previous run.
+func readSession(dataDir string) (Session, bool, error) {
+	var session Session
+	data, err := os.ReadFile(sessionPath(dataDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return session, false, nil
+	}
+	if err != nil {
+		return session, false, fmt.Errorf("error reading session file: %w", err)
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return session, true, fmt.Errorf("error parsing session file: %w", err)
+	}
+	return session, true, nil
+}
+
+func writeSession(dataDir string, session Session) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling session file: %w", err)
+	}
+	path := sessionPath(dataDir)
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing session file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("error writing session file: %w", err)
+	}
+	return nil
+}
+
+// startSession checks how the previous run ended and marks the current run as
+// started. It returns true if the previous run did not shut down cleanly.
+func (a *App) startSession() bool {
+	dataDir := a.options.DataDir
+	if dataDir == "" {
+		return false
+	}
+
+	previous, exists, err := readSession(dataDir)
+	if err != nil {
+		watcher.Logf("", watcher.LogLevelWarn, "%v", err)
+	}
+	unclean := exists && (err != nil || !previous.Clean)
+	if unclean {
+		watcher.Logf("", watcher.LogLevelWarn, "The previous session started at %s did not shut down cleanly", previous.Started.Format(time.DateTime))
+	}
+	for _, id := range previous.Pending {
+		watcher.Logf(id, watcher.LogLevelInfo, "Changes were waiting to be backed up when the app last shut down")
+	}
+
+	a.session = Session{Started: time.Now()}
+	if err := writeSession(dataDir, a.session); err != nil {
+		watcher.Logf("", watcher.LogLevelError, "%v", err)
+	}
+	return unclean
+}
+
+// checkLatestBackups verifies the newest backup of every watcher after a run that did
+// not shut down cleanly, a crash while metadata or backups were written can leave the
+// newest backup damaged without the source looking changed.
+func (a *App) checkLatestBackups(watchers []*watcher.Watcher) {
+	for _, w := range watchers {
+		backups := w.ListBackups()
+		if len(backups) == 0 {
+			continue
+		}
+		result := w.VerifyBackup(backups[len(backups)-1])
+		if !result.Failed() {
+			continue
+		}
+		message := fmt.Sprintf("The latest backup is %s after the app did not shut down cleanly, make a new backup before relying on it", result.Status)
+		watcher.Logf(w.Name, watcher.LogLevelWarn, "%s", message)
+		a.OnWarning(w, message)
+	}
+}
+
+// ShutdownAll stops every watcher, waiting for backups that are in progress and
+// saving their metadata, then marks the session as cleanly shut down.
+func (a *App) ShutdownAll() error {
+	a.pairsMu.Lock()
+	defer a.pairsMu.Unlock()
+	a.mu.Lock()
+	watchers := maps.Clone(a.watchers)
+	a.mu.Unlock()
+
+	var errs error
+	pending := []string{}
+	for id, w := range watchers {
+		unsaved, err := w.Shutdown()
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error shutting down %s: %w", id, err))
+		}
+		if unsaved {
+			pending = append(pending, id)
+		}
+	}
+	slices.Sort(pending)
+
+	if a.options.DataDir != "" && !a.session.Started.IsZero() {
+		a.session.Stopped = time.Now()
+		a.session.Pending = pending
+		// A session with errors is not clean so the next start checks for damage.
+		a.session.Clean = errs == nil
+		if err := writeSession(a.options.DataDir, a.session); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+// shutdown is called by Wails when the app is closing.
+func (a *App) shutdown(ctx context.Context) {
+	watcher.Logf("", watcher.LogLevelInfo, "Shutting down")
+	if err := a.ShutdownAll(); err != nil {
+		watcher.Logf("", watcher.LogLevelError, "%v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+	"ryn-cx/i-saw-that/pkg/watcher/watchertest"
+)
+
+func TestShutdownWithPendingChanges(t *testing.T) {
+	t.Parallel()
+	temp := watchertest.DefaultTempWatcherConfig(t)
+	temp.WaitTime = 10 * time.Second
+	clock := watcher.NewFakeClock(time.Now())
+	w, err := watchertest.NewTempWatcher(temp, watcher.WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	// The initial backup is requested on start and waits for the wait time.
+	if err := w.StartWatcher(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	clock.BlockUntil(1)
+
+	unsaved, err := w.Shutdown()
+	if err != nil {
+		t.Fatalf("Failed to shut down watcher: %v", err)
+	}
+	if !unsaved {
+		t.Errorf("Expected the pending initial backup to be reported")
+	}
+	if w.Status().Running {
+		t.Errorf("Expected the watcher to be stopped")
+	}
+
+	// Shutting down again does not wait and still reports the changes.
+	if unsaved, err := w.Shutdown(); err != nil || !unsaved {
+		t.Errorf("Expected a stopped watcher to still report its changes, got %t, %v", unsaved, err)
+	}
+}
+
+func TestCleanShutdownMarker(t *testing.T) {
+	t.Parallel()
+	options := &Options{DataDir: t.TempDir()}
+
+	if NewApp(options).startSession() {
+		t.Errorf("Expected the first run to not need recovery")
+	}
+	// The previous app was never shut down.
+	app := NewApp(options)
+	if !app.startSession() {
+		t.Errorf("Expected a run without a shutdown to need recovery")
+	}
+
+	if err := app.ShutdownAll(); err != nil {
+		t.Fatalf("Failed to shut down: %v", err)
+	}
+	session, exists, err := readSession(options.DataDir)
+	if err != nil || !exists || !session.Clean || session.Stopped.IsZero() {
+		t.Errorf("Expected a clean session to be recorded, got %+v, %v", session, err)
+	}
+	if NewApp(options).startSession() {
+		t.Errorf("Expected a run after a clean shutdown to not need recovery")
+	}
+}
+
+func TestCheckLatestBackupsAfterUncleanShutdown(t *testing.T) {
+	t.Parallel()
+	temp := watchertest.DefaultTempWatcherConfig(t)
+	w, err := watchertest.NewTempWatcher(temp)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watchertest.CreateDummyFile(t, temp.Source, "file1.txt", 10)
+	w.CreateBackup()
+
+	app := NewApp(&Options{})
+	app.events = newEventHub()
+	events := app.events.subscribe()
+
+	// An undamaged backup is not reported.
+	app.checkLatestBackups([]*watcher.Watcher{w})
+	if len(events) != 0 {
+		t.Errorf("Expected no warning for an undamaged backup, got %+v", <-events)
+	}
+
+	backups := w.ListBackups()
+	if err := os.Remove(filepath.Join(w.BackupPath(backups[0]), "file1.txt")); err != nil {
+		t.Fatalf("Failed to damage backup: %v", err)
+	}
+	app.checkLatestBackups([]*watcher.Watcher{w})
+	if len(events) != 1 {
+		t.Fatalf("Expected a warning for a damaged backup")
+	}
+	if event := <-events; event.Name != "watcher-warning" {
+		t.Errorf("Expected a watcher warning, got %+v", event)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape codes for the colors used in command output.
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// colorEnabled returns true if w is a terminal that colors can be written to. Colors are
+// turned off by setting NO_COLOR, see https://no-color.org.
+func colorEnabled(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	return enableTerminalColors(file)
+}
+
+// tableCell is a cell of a table written by writeTable, color is empty for the default
+// color.
+type tableCell struct {
+	text  string
+	color string
+}
+
+// writeTable writes rows as columns separated by two spaces. Cells are padded before
+// they are colored so the escape codes do not throw off the alignment.
+func (ctx *cliContext) writeTable(rows [][]tableCell) {
+	widths := []int{}
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell.text))
+		}
+	}
+	for _, row := range rows {
+		var line strings.Builder
+		for i, cell := range row {
+			text := cell.text
+			if i < len(row)-1 {
+				text += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(text)+2)
+			}
+			if ctx.color && cell.color != "" {
+				text = cell.color + text + colorReset
+			}
+			line.WriteString(text)
+		}
+		fmt.Fprintln(ctx.stdout, line.String())
+	}
+}
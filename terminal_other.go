@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+func enableTerminalColors(file *os.File) bool {
+	return true
+}
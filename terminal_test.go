@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteTable(t *testing.T) {
+	t.Parallel()
+	var stdout bytes.Buffer
+	ctx := &cliContext{stdout: &stdout}
+	rows := [][]tableCell{
+		{{text: "WATCHER"}, {text: "STATE"}, {text: "SIZE"}},
+		{{text: "documents"}, {text: "up to date", color: colorGreen}, {text: "1.5 MiB"}},
+		{{text: "ü"}, {text: "error", color: colorRed}, {text: "0 B"}},
+	}
+	ctx.writeTable(rows)
+	expected := "WATCHER    STATE       SIZE\n" +
+		"documents  up to date  1.5 MiB\n" +
+		"ü          error       0 B\n"
+	if stdout.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, stdout.String())
+	}
+
+	// Colors do not change the alignment.
+	stdout.Reset()
+	ctx.color = true
+	ctx.writeTable(rows)
+	expected = "WATCHER    STATE       SIZE\n" +
+		"documents  " + colorGreen + "up to date  " + colorReset + "1.5 MiB\n" +
+		"ü          " + colorRed + "error       " + colorReset + "0 B\n"
+	if stdout.String() != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, stdout.String())
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	t.Parallel()
+	if colorEnabled(&bytes.Buffer{}) {
+		t.Errorf("Expected no colors when writing to a buffer")
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"ryn-cx/i-saw-that/pkg/watcher"
+)
+
+// Releases are published on GitHub, the latest one is found with the release API.
+const latestReleaseURL = "https://api.github.com/repos/ryn-cx/i-saw-that/releases/latest"
+
+// Name of the release asset that lists the SHA-256 checksums of the other assets, in
+// the format written by sha256sum. The checksums are not signed, so they only detect
+// damaged downloads.
+const checksumsAssetName = "checksums.txt"
+
+// Added to the name of the executable that was replaced by an update, it can only be
+// deleted once it is no longer running.
+const oldExecutableSuffix = ".old"
+
+var updateClient = &http.Client{Timeout: 5 * time.Minute}
+
+// UpdateInfo describes the latest release compared with the running version.
+type UpdateInfo struct {
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+	// True if the latest release is newer than the running version.
+	Available bool `json:"available"`
+	// Page of the latest release.
+	URL   string `json:"url,omitempty"`
+	Notes string `json:"notes,omitempty"`
+
+	// Download links of the executable for this platform and the checksums, empty if
+	// the release has no executable for this platform.
+	assetURL     string
+	checksumsURL string
+}
+
+// githubRelease is the part of a release from the GitHub API that is used.
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+	Body    string `json:"body"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// updateAssetName returns the name of the release asset with the executable for this
+// platform, such as i-saw-that_windows_amd64.exe.
+func updateAssetName() string {
+	name := fmt.Sprintf("i-saw-that_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// checkForUpdates reads the latest release from url and compares it with current.
+func checkForUpdates(client *http.Client, url, current string) (UpdateInfo, error) {
+	response, err := client.Get(url)
+	if err != nil {
+		return UpdateInfo{}, fmt.Errorf("error checking for updates: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return UpdateInfo{}, fmt.Errorf("error checking for updates: %s", response.Status)
+	}
+	var release githubRelease
+	if err := json.NewDecoder(response.Body).Decode(&release); err != nil {
+		return UpdateInfo{}, fmt.Errorf("error parsing release: %w", err)
+	}
+
+	info := UpdateInfo{
+		Current:   current,
+		Latest:    strings.TrimPrefix(release.TagName, "v"),
+		Available: compareVersions(release.TagName, current) > 0,
+		URL:       release.HTMLURL,
+		Notes:     release.Body,
+	}
+	assetName := updateAssetName()
+	for _, asset := range release.Assets {
+		switch asset.Name {
+		case assetName:
+			info.assetURL = asset.URL
+		case checksumsAssetName:
+			info.checksumsURL = asset.URL
+		}
+	}
+	return info, nil
+}
+
+// compareVersions compares two versions such as "v1.2.3", it returns a negative
+// number if a is older than b and a positive number if it is newer. A pre-release such
+// as 1.2.3-beta is older than the release.
+func compareVersions(a, b string) int {
+	parse := func(version string) ([]int, bool) {
+		version, preRelease, _ := strings.Cut(strings.TrimPrefix(version, "v"), "-")
+		parts := []int{}
+		for _, part := range strings.Split(version, ".") {
+			number, _ := strconv.Atoi(part)
+			parts = append(parts, number)
+		}
+		return parts, preRelease != ""
+	}
+	aParts, aPreRelease := parse(a)
+	bParts, bPreRelease := parse(b)
+	for i := range max(len(aParts), len(bParts)) {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if aPart != bPart {
+			return aPart - bPart
+		}
+	}
+	switch {
+	case aPreRelease && !bPreRelease:
+		return -1
+	case !aPreRelease && bPreRelease:
+		return 1
+	}
+	return 0
+}
+
+// selfUpdate replaces executable with the release described by info. The download is
+// compared with the checksums published in the same release before the executable is
+// touched. This only catches a corrupted or truncated download, anyone able to replace
+// the executable of a release can replace its checksums too. The old executable is
+// kept next to it until the next start since a running executable can not be deleted
+// on Windows.
+func selfUpdate(client *http.Client, info UpdateInfo, executable string) error {
+	if info.assetURL == "" {
+		return fmt.Errorf("release %s has no executable for %s/%s", info.Latest, runtime.GOOS, runtime.GOARCH)
+	}
+	if info.checksumsURL == "" {
+		return fmt.Errorf("release %s has no checksums", info.Latest)
+	}
+	expected, err := downloadChecksum(client, info.checksumsURL, updateAssetName())
+	if err != nil {
+		return err
+	}
+
+	// The update is downloaded next to the executable so it can be renamed into place.
+	file, err := os.CreateTemp(filepath.Dir(executable), ".i-saw-that-update-*")
+	if err != nil {
+		return fmt.Errorf("error creating update: %w", err)
+	}
+	defer os.Remove(file.Name())
+	hash := sha256.New()
+	err = download(client, info.assetURL, io.MultiWriter(file, hash))
+	if err = errors.Join(err, file.Close()); err != nil {
+		return fmt.Errorf("error downloading update: %w", err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		return fmt.Errorf("update was damaged while downloading, checksum is %s, expected %s", actual, expected)
+	}
+	if err := os.Chmod(file.Name(), 0755); err != nil {
+		return fmt.Errorf("error making update executable: %w", err)
+	}
+
+	old := executable + oldExecutableSuffix
+	os.Remove(old)
+	if err := os.Rename(executable, old); err != nil {
+		return fmt.Errorf("error replacing executable: %w", err)
+	}
+	if err := os.Rename(file.Name(), executable); err != nil {
+		return errors.Join(fmt.Errorf("error replacing executable: %w", err), os.Rename(old, executable))
+	}
+	return nil
+}
+
+// removeOldExecutable deletes the executable left behind by the last update.
+func removeOldExecutable(executable string) {
+	if err := os.Remove(executable + oldExecutableSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		watcher.Logf("", watcher.LogLevelWarn, "Error removing old executable: %v", err)
+	}
+}
+
+func download(client *http.Client, url string, w io.Writer) error {
+	response, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("error downloading %s: %s", url, response.Status)
+	}
+	_, err = io.Copy(w, response.Body)
+	return err
+}
+
+// downloadChecksum returns the checksum of the asset called name from a checksums
+// file.
+func downloadChecksum(client *http.Client, url, name string) (string, error) {
+	var checksums strings.Builder
+	if err := download(client, url, &checksums); err != nil {
+		return "", fmt.Errorf("error downloading checksums: %w", err)
+	}
+	scanner := bufio.NewScanner(strings.NewReader(checksums.String()))
+	for scanner.Scan() {
+		// sha256sum marks files read in binary mode with a *.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s", name)
+}
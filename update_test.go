@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// releaseServer serves a release of the given version with an executable containing
+// binary. The checksum of the executable is replaced with checksum if it is not empty.
+func releaseServer(t *testing.T, tag string, binary []byte, checksum string) *httptest.Server {
+	if checksum == "" {
+		sum := sha256.Sum256(binary)
+		checksum = hex.EncodeToString(sum[:])
+	}
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name": %q, "html_url": "%s/release", "assets": [
+			{"name": %q, "browser_download_url": "%s/binary"},
+			{"name": "checksums.txt", "browser_download_url": "%s/checksums"}
+		]}`, tag, server.URL, updateAssetName(), server.URL, server.URL)
+	})
+	mux.HandleFunc("/binary", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	})
+	mux.HandleFunc("/checksums", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  other_file\n%s *%s\n", strings.Repeat("0", 64), checksum, updateAssetName())
+	})
+	return server
+}
+
+func TestCompareVersions(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.10.0", "1.9.9", 1},
+		{"0.1.0", "0.2", -1},
+		{"1.0.0-beta", "1.0.0", -1},
+		{"1.0.1-beta", "1.0.0", 1},
+	}
+	for _, c := range cases {
+		if result := compareVersions(c.a, c.b); (result > 0) != (c.expected > 0) || (result < 0) != (c.expected < 0) {
+			t.Errorf("Expected comparing %s with %s to be %d, got %d", c.a, c.b, c.expected, result)
+		}
+	}
+}
+
+func TestSelfUpdate(t *testing.T) {
+	t.Parallel()
+	server := releaseServer(t, "v9.0.0", []byte("new version"), "")
+	info, err := checkForUpdates(server.Client(), server.URL+"/latest", "1.0.0")
+	if err != nil {
+		t.Fatalf("Failed to check for updates: %v", err)
+	}
+	if !info.Available || info.Latest != "9.0.0" || info.URL != server.URL+"/release" {
+		t.Fatalf("Expected version 9.0.0 to be available, got %+v", info)
+	}
+
+	executable := filepath.Join(t.TempDir(), "i-saw-that")
+	os.WriteFile(executable, []byte("old version"), 0755)
+	if err := selfUpdate(server.Client(), info, executable); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if data, _ := os.ReadFile(executable); string(data) != "new version" {
+		t.Errorf("Expected the executable to be replaced, got '%s'", data)
+	}
+	if data, _ := os.ReadFile(executable + oldExecutableSuffix); string(data) != "old version" {
+		t.Errorf("Expected the old executable to be kept until the next start, got '%s'", data)
+	}
+	removeOldExecutable(executable)
+	if _, err := os.Stat(executable + oldExecutableSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected the old executable to be removed")
+	}
+}
+
+func TestSelfUpdateChecksumMismatch(t *testing.T) {
+	t.Parallel()
+	server := releaseServer(t, "v9.0.0", []byte("tampered"), strings.Repeat("a", 64))
+	info, err := checkForUpdates(server.Client(), server.URL+"/latest", "1.0.0")
+	if err != nil {
+		t.Fatalf("Failed to check for updates: %v", err)
+	}
+
+	dir := t.TempDir()
+	executable := filepath.Join(dir, "i-saw-that")
+	os.WriteFile(executable, []byte("old version"), 0755)
+	if err := selfUpdate(server.Client(), info, executable); err == nil {
+		t.Fatalf("Expected an error for a checksum mismatch")
+	}
+	if data, _ := os.ReadFile(executable); string(data) != "old version" {
+		t.Errorf("Expected the executable to be kept, got '%s'", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected the download to be removed, found %d files", len(entries))
+	}
+}
+
+func TestCLISelfUpdate(t *testing.T) {
+	t.Parallel()
+	server := releaseServer(t, "v"+version, []byte("same version"), "")
+	executable := filepath.Join(t.TempDir(), "i-saw-that")
+	os.WriteFile(executable, []byte("old version"), 0755)
+	options := &Options{releaseURL: server.URL + "/latest", executable: executable}
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI(options, []string{"self-update"}, strings.NewReader(""), &stdout, &stderr); code != exitNothingToDo {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitNothingToDo, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "up to date") {
+		t.Errorf("Unexpected output:\n%s", stdout.String())
+	}
+
+	newer := releaseServer(t, "v99.0.0", []byte("new version"), "")
+	options.releaseURL = newer.URL + "/latest"
+	stdout.Reset()
+	if code := runCLI(options, []string{"self-update", "--check"}, strings.NewReader(""), &stdout, &stderr); code != exitUpdateAvailable {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitUpdateAvailable, code, stderr.String())
+	}
+	if data, _ := os.ReadFile(executable); string(data) != "old version" {
+		t.Errorf("Expected --check to not replace the executable")
+	}
+	if code := runCLI(options, []string{"self-update"}, strings.NewReader(""), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if data, _ := os.ReadFile(executable); string(data) != "new version" {
+		t.Errorf("Expected the executable to be replaced, got '%s'", data)
+	}
+}
@@ -0,0 +1,5 @@
+package main
+
+// version is the version of I Saw That, release builds set it with
+// -ldflags "-X main.version=<version>".
+var version = "0.1.0"